@@ -6,15 +6,18 @@ import (
 
 var defaultIntegrationGroups = []models.IntegrationGroup{
 	{
-		Name:  "active",
-		Query: `SELECT integration_id FROM platform_integrations WHERE state = 'ACTIVE'`,
+		Name:   "active",
+		Query:  `SELECT integration_id FROM platform_integrations WHERE state = 'ACTIVE'`,
+		Source: models.IntegrationGroupSourceMigration,
 	},
 	{
-		Name:  "inactive",
-		Query: `SELECT integration_id FROM platform_integrations WHERE state = 'INACTIVE'`,
+		Name:   "inactive",
+		Query:  `SELECT integration_id FROM platform_integrations WHERE state = 'INACTIVE'`,
+		Source: models.IntegrationGroupSourceMigration,
 	},
 	{
-		Name:  "archived",
-		Query: `SELECT integration_id FROM platform_integrations WHERE state = 'ARCHIVED'`,
+		Name:   "archived",
+		Query:  `SELECT integration_id FROM platform_integrations WHERE state = 'ARCHIVED'`,
+		Source: models.IntegrationGroupSourceMigration,
 	},
 }
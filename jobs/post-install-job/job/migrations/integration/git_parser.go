@@ -41,8 +41,9 @@ func (g *GitParser) ExtractConnectionGroups(queryPath string) error {
 			}
 
 			g.integrationGroups = append(g.integrationGroups, models.IntegrationGroup{
-				Name:  fileName,
-				Query: cg.Query,
+				Name:   fileName,
+				Query:  cg.Query,
+				Source: models.IntegrationGroupSourceMigration,
 			})
 		}
 
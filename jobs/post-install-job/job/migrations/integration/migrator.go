@@ -7,6 +7,7 @@ import (
 	"github.com/opengovern/opensecurity/jobs/post-install-job/db"
 	integrationModels "github.com/opengovern/opensecurity/services/integration/models"
 	"gorm.io/gorm/clause"
+	"strings"
 
 	"github.com/opengovern/og-util/pkg/postgres"
 	"go.uber.org/zap"
@@ -43,13 +44,41 @@ func (m Migration) Run(ctx context.Context, conf config.MigratorConfig, logger *
 		return err
 	}
 
+	err = dbm.ORM.AutoMigrate(&integrationModels.IntegrationGroup{})
+	if err != nil {
+		logger.Error("failed to migrate integration groups table", zap.Error(err))
+		return err
+	}
+
 	err = dbm.ORM.Transaction(func(tx *gorm.DB) error {
-		err := tx.Model(&integrationModels.IntegrationGroup{}).Where("1 = 1").Unscoped().Delete(&integrationModels.IntegrationGroup{}).Error
+		// user defined groups are managed through the integration service and kept across migrations
+		err := tx.Model(&integrationModels.IntegrationGroup{}).
+			Where("source = ?", integrationModels.IntegrationGroupSourceMigration).
+			Unscoped().Delete(&integrationModels.IntegrationGroup{}).Error
 		if err != nil {
 			logger.Error("failed to delete integration groups", zap.Error(err))
 			return err
 		}
 
+		// a user defined group would otherwise silently take the place of the content group
+		var names []string
+		for _, integrationGroup := range parser.integrationGroups {
+			names = append(names, integrationGroup.Name)
+		}
+		var clashes []string
+		err = tx.Model(&integrationModels.IntegrationGroup{}).
+			Where("source = ?", integrationModels.IntegrationGroupSourceUser).
+			Where("name IN ?", names).
+			Pluck("name", &clashes).Error
+		if err != nil {
+			logger.Error("failed to list user integration groups", zap.Error(err))
+			return err
+		}
+		if len(clashes) > 0 {
+			return fmt.Errorf("user defined integration groups %s clash with platform content groups, delete them or recreate them under another name",
+				strings.Join(clashes, ", "))
+		}
+
 		for _, integrationGroup := range parser.integrationGroups {
 			err = tx.Clauses(clause.OnConflict{
 				DoNothing: true,
@@ -6,9 +6,18 @@ import (
 	"github.com/opengovern/og-util/pkg/api"
 )
 
+// APIKeyScopes restricts where a key can be used, an empty list does not restrict that dimension
+type APIKeyScopes struct {
+	Services     []string `json:"services,omitempty" example:"compliance"`               // First path segment of the request, e.g. compliance
+	PathPrefixes []string `json:"path_prefixes,omitempty" example:"/compliance/api/v1/"` // Allowed prefixes of the request path
+	Methods      []string `json:"methods,omitempty" example:"GET"`                       // Allowed HTTP methods
+}
+
 type CreateAPIKeyRequest struct {
-	Name string   `json:"name"`                                             // Name of the key
-	Role api.Role `json:"role" enums:"admin,editor,viewer" example:"admin"` // Name of the role
+	Name          string        `json:"name"`                                             // Name of the key
+	Role          api.Role      `json:"role" enums:"admin,editor,viewer" example:"admin"` // Name of the role
+	ExpiresInDays int           `json:"expires_in_days,omitempty" example:"90"`           // Lifetime of the key, defaults to the configured default
+	Scopes        *APIKeyScopes `json:"scopes,omitempty"`                                 // Optional restrictions of the key
 }
 type EditAPIKeyRequest struct {
 	Role     api.Role `json:"role" enums:"admin,editor,viewer" example:"admin"` // Name of the role
@@ -17,23 +26,32 @@ type EditAPIKeyRequest struct {
 }
 
 type CreateAPIKeyResponse struct {
-	ID        uint      `json:"id" example:"1"`                                       // Unique identifier for the key
-	Name      string    `json:"name" example:"example"`                               // Name of the key
-	Active    bool      `json:"active" example:"true"`                                // Activity state of the key
-	CreatedAt time.Time `json:"created_at" example:"2023-03-31T09:36:09.855Z"`        // Creation timestamp in UTC
-	RoleName  api.Role  `json:"roleName" enums:"admin,editor,viewer" example:"admin"` // Name of the role
-	Token     string    `json:"token"`                                                // Token of the key
+	ID        uint         `json:"id" example:"1"`                                       // Unique identifier for the key
+	Name      string       `json:"name" example:"example"`                               // Name of the key
+	Active    bool         `json:"active" example:"true"`                                // Activity state of the key
+	CreatedAt time.Time    `json:"created_at" example:"2023-03-31T09:36:09.855Z"`        // Creation timestamp in UTC
+	RoleName  api.Role     `json:"roleName" enums:"admin,editor,viewer" example:"admin"` // Name of the role
+	ExpiresAt time.Time    `json:"expires_at" example:"2023-06-29T09:36:09.855Z"`        // Expiry timestamp in UTC
+	Scopes    APIKeyScopes `json:"scopes"`                                               // Restrictions of the key
+	Token     string       `json:"token"`                                                // Token of the key
 }
 
 type APIKeyResponse struct {
-	ID            uint      `json:"id" example:"1"`                                        // Unique identifier for the key
-	CreatedAt     time.Time `json:"created_at" example:"2023-03-31T09:36:09.855Z"`         // Creation timestamp in UTC
-	UpdatedAt     time.Time `json:"updated_at" example:"2023-04-21T08:53:09.928Z"`         // Last update timestamp in UTC
-	Name          string    `json:"name" example:"example"`                                // Name of the key
-	RoleName      api.Role  `json:"role_name" enums:"admin,editor,viewer" example:"admin"` // Name of the role
-	CreatorUserID string    `json:"creator_user_id" example:"auth|123456789"`              // Unique identifier of the user who created the key
-	Active        bool      `json:"active" example:"true"`                                 // Activity state of the key
-	MaskedKey     string    `json:"maskedKey" example:"abc...de"`                          // Masked key
+	ID            uint         `json:"id" example:"1"`                                          // Unique identifier for the key
+	CreatedAt     time.Time    `json:"created_at" example:"2023-03-31T09:36:09.855Z"`           // Creation timestamp in UTC
+	UpdatedAt     time.Time    `json:"updated_at" example:"2023-04-21T08:53:09.928Z"`           // Last update timestamp in UTC
+	Name          string       `json:"name" example:"example"`                                  // Name of the key
+	RoleName      api.Role     `json:"role_name" enums:"admin,editor,viewer" example:"admin"`   // Name of the role
+	CreatorUserID string       `json:"creator_user_id" example:"auth|123456789"`                // Unique identifier of the user who created the key
+	Active        bool         `json:"active" example:"true"`                                   // Activity state of the key
+	MaskedKey     string       `json:"maskedKey" example:"abc...de"`                            // Masked key
+	ExpiresAt     *time.Time   `json:"expires_at,omitempty" example:"2023-06-29T09:36:09.855Z"` // Expiry timestamp in UTC
+	RevokedAt     *time.Time   `json:"revoked_at,omitempty"`                                    // Revocation timestamp in UTC
+	RevokedBy     string       `json:"revoked_by,omitempty"`                                    // User who revoked the key
+	LastUsedAt    *time.Time   `json:"last_used_at,omitempty"`                                  // Last time the key passed an auth check
+	LastUsedIP    string       `json:"last_used_ip,omitempty" example:"10.0.0.1"`               // Client address of the last usage
+	UsageCount    int64        `json:"usage_count" example:"42"`                                // Requests authorized with the key
+	Scopes        APIKeyScopes `json:"scopes"`                                                  // Restrictions of the key
 }
 
 type UpdateKeyRoleRequest struct {
@@ -0,0 +1,41 @@
+package api
+
+import "time"
+
+// AuditEvent is an entry of the audit log. Hash chains the event to the previous one,
+// see the verify endpoint to check the chain has not been tampered with.
+type AuditEvent struct {
+	ID         uint64    `json:"id" example:"1"`
+	Timestamp  time.Time `json:"timestamp"`
+	EventType  string    `json:"event_type" enums:"api_call,auth_denied,scim_provisioning" example:"api_call"`
+	ActorID    string    `json:"actor_id,omitempty" example:"auth|123456789"` // External ID of the user, empty if the caller was not authenticated
+	ActorEmail string    `json:"actor_email,omitempty"`
+	ActorRole  string    `json:"actor_role,omitempty" example:"admin"`
+	APIKeyID   string    `json:"api_key_id,omitempty"` // Set when the request was made with an API key
+	Method     string    `json:"method" example:"POST"`
+	Path       string    `json:"path" example:"/auth/api/v1/user"`
+	TargetIDs  []string  `json:"target_ids,omitempty"`                              // Identifiers found in the path and query of the request
+	Decision   string    `json:"decision" enums:"allowed,denied" example:"allowed"` // Decision of the auth check, not the response status of the service
+	Reason     string    `json:"reason,omitempty"`
+	RequestID  string    `json:"request_id,omitempty"`
+	SourceIP   string    `json:"source_ip,omitempty"`
+	PrevHash   string    `json:"prev_hash"`
+	Hash       string    `json:"hash"`
+	Keyed      bool      `json:"keyed"` // Hash is an HMAC under the audit key held outside of the database
+}
+
+type ListAuditEventsResponse struct {
+	Events     []AuditEvent `json:"events"`
+	NextCursor uint64       `json:"next_cursor,omitempty"` // Pass as cursor to get the next page, empty on the last page
+}
+
+// VerifyAuditLogResponse is the result of walking the whole audit chain
+type VerifyAuditLogResponse struct {
+	Valid         bool   `json:"valid"`
+	CheckedEvents int64  `json:"checked_events"`
+	BrokenAtID    uint64 `json:"broken_at_id,omitempty"` // First event whose hash does not match the chain
+	Reason        string `json:"reason,omitempty"`
+	UnkeyedEvents int64  `json:"unkeyed_events"` // Events written before the audit key was set, only chained by SHA-256
+	HeadID        uint64 `json:"head_id,omitempty"`
+	HeadHash      string `json:"head_hash,omitempty"` // Hash of the last event, record it elsewhere to anchor the chain
+}
@@ -1,15 +1,21 @@
 package api
 
+import "github.com/opengovern/og-util/pkg/api"
+
 // CreateConnectorRequest represents the expected payload for creating or updating a connector.
+// OIDC connectors need the client credentials, LDAP and SAML connectors their own configuration.
 type CreateConnectorRequest struct {
-	ConnectorType    string `json:"connector_type" validate:"required,oneof=oidc"`                                  // 'oidc' is supported for now
-	ConnectorSubType string `json:"connector_sub_type" validate:"omitempty,oneof=general google-workspace entraid"` // Optional sub-type
-	Issuer           string `json:"issuer,omitempty" validate:"omitempty,url"`
-	TenantID         string `json:"tenant_id,omitempty" validate:"omitempty,uuid"`
-	ClientID         string `json:"client_id" validate:"required"`
-	ClientSecret     string `json:"client_secret" validate:"required"`
-	ID               string `json:"id,omitempty"`   // Optional
-	Name             string `json:"name,omitempty"` // Optional
+	ConnectorType    string                     `json:"connector_type" validate:"required,oneof=oidc ldap saml"`                                                  // oidc, ldap or saml
+	ConnectorSubType string                     `json:"connector_sub_type" validate:"omitempty,oneof=general google-workspace entraid active-directory openldap"` // Optional sub-type
+	Issuer           string                     `json:"issuer,omitempty" validate:"omitempty,url"`
+	TenantID         string                     `json:"tenant_id,omitempty" validate:"omitempty,uuid"`
+	ClientID         string                     `json:"client_id" validate:"required_without_all=LDAP SAML"`
+	ClientSecret     string                     `json:"client_secret" validate:"required_without_all=LDAP SAML"`
+	ID               string                     `json:"id,omitempty"`   // Optional
+	Name             string                     `json:"name,omitempty"` // Optional
+	LDAP             *LDAPConnectorConfig       `json:"ldap,omitempty"` // Required for ldap connectors
+	SAML             *SAMLConnectorConfig       `json:"saml,omitempty"` // Required for saml connectors
+	AttributeMapping *ConnectorAttributeMapping `json:"attribute_mapping,omitempty"`
 }
 type CreateAuth0ConnectorRequest struct {
 	Issuer       string `json:"issuer,omitempty" validate:"omitempty,url"`
@@ -21,16 +27,92 @@ type CreateAuth0ConnectorRequest struct {
 	PrivateURIS []string `json:"private_uris" validate:"required"`
 }
 type UpdateConnectorRequest struct {
-	ConnectorID      string `json:"connector_id" validate:"required"`
-	ConnectorType    string `json:"connector_type" validate:"required,oneof=oidc"`                                  // 'oidc' is supported for now
-	ConnectorSubType string `json:"connector_sub_type" validate:"omitempty,oneof=general google-workspace entraid"` // Optional sub-type
-	Issuer           string `json:"issuer,omitempty" validate:"omitempty,url"`
-	TenantID         string `json:"tenant_id,omitempty" validate:"omitempty,uuid"`
-	ClientID         string `json:"client_id" validate:"required"`
-	ClientSecret     string `json:"client_secret" validate:"required"`
-	ID               uint   `json:"id,omitempty"`   // Optional
-	Name             string `json:"name,omitempty"` // Optional
+	ConnectorID      string                     `json:"connector_id" validate:"required"`
+	ConnectorType    string                     `json:"connector_type" validate:"required,oneof=oidc ldap saml"`                                                  // oidc, ldap or saml
+	ConnectorSubType string                     `json:"connector_sub_type" validate:"omitempty,oneof=general google-workspace entraid active-directory openldap"` // Optional sub-type
+	Issuer           string                     `json:"issuer,omitempty" validate:"omitempty,url"`
+	TenantID         string                     `json:"tenant_id,omitempty" validate:"omitempty,uuid"`
+	ClientID         string                     `json:"client_id" validate:"required_without_all=LDAP SAML"`
+	ClientSecret     string                     `json:"client_secret" validate:"required_without_all=LDAP SAML"`
+	ID               uint                       `json:"id,omitempty"`   // Optional
+	Name             string                     `json:"name,omitempty"` // Optional
+	LDAP             *LDAPConnectorConfig       `json:"ldap,omitempty"` // Required for ldap connectors
+	SAML             *SAMLConnectorConfig       `json:"saml,omitempty"` // Required for saml connectors
+	AttributeMapping *ConnectorAttributeMapping `json:"attribute_mapping,omitempty"`
+}
+
+// LDAPConnectorConfig configures an LDAP directory, e.g. Active Directory or OpenLDAP.
+// Connections use LDAPS unless insecure_no_ssl or start_tls is set.
+type LDAPConnectorConfig struct {
+	Host               string `json:"host" validate:"required" example:"ldap.example.com:636"`                // Host and port of the server
+	InsecureNoSSL      bool   `json:"insecure_no_ssl,omitempty"`                                              // Plain LDAP, credentials are sent in clear text
+	StartTLS           bool   `json:"start_tls,omitempty"`                                                    // Upgrade a plain connection with StartTLS
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`                                         // Do not verify the server certificate
+	RootCA             string `json:"root_ca,omitempty"`                                                      // PEM encoded CA of the server certificate
+	BindDN             string `json:"bind_dn,omitempty" example:"cn=svc-auth,ou=services,dc=example,dc=com"`  // Account searching the directory, anonymous if empty
+	BindPassword       string `json:"bind_password,omitempty"`                                                // Password of the bind account, kept when omitted from an update
+	UserBaseDN         string `json:"user_base_dn" validate:"required" example:"ou=people,dc=example,dc=com"` // Subtree of the users
+	UserFilter         string `json:"user_filter,omitempty" example:"(objectClass=person)"`                   // Defaults to the sub-type's user object class
+	UsernameAttr       string `json:"username_attr,omitempty" example:"uid"`                                  // Attribute matched against the login username
+	UsernamePrompt     string `json:"username_prompt,omitempty" example:"Email Address"`                      // Label of the username field of the login page
+	GroupBaseDN        string `json:"group_base_dn,omitempty" example:"ou=groups,dc=example,dc=com"`          // Subtree of the groups, groups are not searched if empty
+	GroupFilter        string `json:"group_filter,omitempty" example:"(objectClass=groupOfNames)"`            // Defaults to the sub-type's group object class
+	GroupMemberAttr    string `json:"group_member_attr,omitempty" example:"member"`                           // Attribute of the groups listing the DN of their members
+}
+
+// SAMLConnectorConfig configures a SAML 2.0 identity provider
+type SAMLConnectorConfig struct {
+	SSOURL             string `json:"sso_url" validate:"required,url" example:"https://idp.example.com/saml/sso"` // Single sign-on endpoint of the IdP
+	CA                 string `json:"ca" validate:"required"`                                                     // PEM encoded certificate signing the IdP responses
+	EntityIssuer       string `json:"entity_issuer,omitempty"`                                                    // Issuer of the authentication requests, the SP entity ID
+	SSOIssuer          string `json:"sso_issuer,omitempty"`                                                       // Expected issuer of the responses, the IdP entity ID
+	NameIDPolicyFormat string `json:"name_id_policy_format,omitempty" example:"persistent"`
+	GroupsDelimiter    string `json:"groups_delimiter,omitempty" example:","`          // For IdPs sending all groups in one value
+	MetadataURL        string `json:"metadata_url,omitempty" validate:"omitempty,url"` // Only used by the connection test, checked against the configuration
+}
+
+// ConnectorAttributeMapping names the directory or assertion attributes holding the user details,
+// unset attributes use the defaults of the connector sub-type
+type ConnectorAttributeMapping struct {
+	Email             string `json:"email,omitempty" example:"mail"`
+	Name              string `json:"name,omitempty" example:"displayName"`
+	Groups            string `json:"groups,omitempty" example:"cn"`              // LDAP: name attribute of the groups. SAML: attribute listing the groups
+	PreferredUsername string `json:"preferred_username,omitempty" example:"uid"` // LDAP only
+	ID                string `json:"id,omitempty" example:"DN"`                  // LDAP only, unique ID of the users, DN by default
+}
+
+// TestConnectorRequest checks an LDAP or SAML configuration before it is saved. With a username the
+// LDAP test looks the user up, and with its password also checks it can sign in.
+type TestConnectorRequest struct {
+	ConnectorType    string                     `json:"connector_type" validate:"required,oneof=ldap saml"`
+	ConnectorSubType string                     `json:"connector_sub_type" validate:"omitempty,oneof=general active-directory openldap"`
+	LDAP             *LDAPConnectorConfig       `json:"ldap,omitempty"`
+	SAML             *SAMLConnectorConfig       `json:"saml,omitempty"`
+	AttributeMapping *ConnectorAttributeMapping `json:"attribute_mapping,omitempty"`
+	Username         string                     `json:"username,omitempty"` // LDAP only
+	Password         string                     `json:"password,omitempty"` // LDAP only
+}
+
+// TestConnectorStep is the outcome of one check of a connection test
+type TestConnectorStep struct {
+	Name    string `json:"name" example:"bind"`
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// TestConnectorUser is the user found by a connection test, with its mapped attributes
+type TestConnectorUser struct {
+	ID                string   `json:"id"`
+	Email             string   `json:"email"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username,omitempty"`
+	Groups            []string `json:"groups"`
+}
 
+type TestConnectorResponse struct {
+	Success bool                `json:"success"` // True if every step succeeded
+	Steps   []TestConnectorStep `json:"steps"`
+	User    *TestConnectorUser  `json:"user,omitempty"`
 }
 
 type OIDCConfig struct {
@@ -49,6 +131,8 @@ type GetConnectorsResponse struct {
 	Issuer      string `json:"issuer,omitempty"`
 	ClientID    string `json:"client_id,omitempty"`
 	TenantID    string `json:"tenant_id,omitempty"`
+	Host        string `json:"host,omitempty"`    // LDAP server
+	SSOURL      string `json:"sso_url,omitempty"` // SAML single sign-on endpoint
 	UserCount   uint   `json:"user_count"`
 	CreatedAt   any    `json:"created_at"`
 	LastUpdate  any    `json:"last_update"`
@@ -62,3 +146,18 @@ type GetSupportedConnectorTypeResponse struct {
 	ConnectorType string              `json:"connector_type"`
 	SubTypes      []ConnectorSubTypes `json:"sub_types"`
 }
+
+// ConnectorRoleRule grants a role to users whose claim matches the value, a case-insensitive glob
+type ConnectorRoleRule struct {
+	Claim string   `json:"claim" validate:"required,oneof=groups email email_domain preferred_username" enums:"groups,email,email_domain,preferred_username" example:"groups"`
+	Value string   `json:"value" validate:"required" example:"platform-admins"`
+	Role  api.Role `json:"role" validate:"required,oneof=admin editor viewer" enums:"admin,editor,viewer" example:"admin"`
+}
+
+// ConnectorRoleMapping defines how users of a connector get their role. When a connector has rules or a
+// default role, the role of its users is re-evaluated from their token claims, the highest matching role wins.
+type ConnectorRoleMapping struct {
+	JITProvisioning bool                `json:"jit_provisioning"`                                                                                  // Create unknown users on their first login
+	DefaultRole     api.Role            `json:"default_role,omitempty" validate:"omitempty,oneof=admin editor viewer" enums:"admin,editor,viewer"` // Role when no rule matches, empty denies access
+	Rules           []ConnectorRoleRule `json:"rules" validate:"dive"`
+}
@@ -0,0 +1,42 @@
+package api
+
+import "time"
+
+type RoleBindingSubjectType string
+
+const (
+	RoleBindingSubjectUser   RoleBindingSubjectType = "user"
+	RoleBindingSubjectAPIKey RoleBindingSubjectType = "api_key"
+	// Bindings of a service account apply to its keys without bindings of their own
+	RoleBindingSubjectServiceAccount RoleBindingSubjectType = "service_account"
+)
+
+// CreateRoleBindingRequest limits a subject to the union of the given integrations, integration groups and
+// label selectors. Subjects without bindings have access to every integration, API keys without bindings
+// inherit the bindings of their creator, or of their service account.
+type CreateRoleBindingRequest struct {
+	SubjectType       RoleBindingSubjectType `json:"subject_type" enums:"user,api_key,service_account" example:"user"`         // Kind of the subject
+	SubjectID         string                 `json:"subject_id" example:"auth|123456789"`                                      // External ID of the user, or ID of the API key or service account
+	IntegrationIDs    []string               `json:"integration_ids,omitempty" example:"8e0f8e7a-1b1c-4e6f-b7e4-9c6af9d2b1c8"` // Integrations the subject can access
+	IntegrationGroups []string               `json:"integration_groups,omitempty" example:"active"`                            // Integration groups the subject can access
+	LabelSelectors    []map[string]string    `json:"label_selectors,omitempty"`                                                // Integrations having all labels of any selector
+	Description       string                 `json:"description,omitempty"`
+}
+
+type RoleBindingResponse struct {
+	ID                uint                   `json:"id" example:"1"`
+	SubjectType       RoleBindingSubjectType `json:"subject_type" enums:"user,api_key,service_account" example:"user"`
+	SubjectID         string                 `json:"subject_id" example:"auth|123456789"`
+	IntegrationIDs    []string               `json:"integration_ids"`
+	IntegrationGroups []string               `json:"integration_groups"`
+	LabelSelectors    []map[string]string    `json:"label_selectors"`
+	Description       string                 `json:"description"`
+	CreatedBy         string                 `json:"created_by"`
+	CreatedAt         time.Time              `json:"created_at"`
+}
+
+// IntegrationScopeResponse is the resolved scope of a subject
+type IntegrationScopeResponse struct {
+	Restricted     bool     `json:"restricted"`      // False if the subject can access every integration
+	IntegrationIDs []string `json:"integration_ids"` // Integrations the subject can access when restricted
+}
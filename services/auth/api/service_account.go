@@ -0,0 +1,57 @@
+package api
+
+import (
+	"time"
+
+	"github.com/opengovern/og-util/pkg/api"
+)
+
+type CreateServiceAccountRequest struct {
+	Name        string   `json:"name" validate:"required" example:"ci-pipeline"`                        // Unique name of the account
+	Description string   `json:"description,omitempty"`                                                 // What the account is used for
+	Role        api.Role `json:"role" validate:"required" enums:"admin,editor,viewer" example:"viewer"` // Role of requests made with its keys
+	OwnerTeam   string   `json:"owner_team" validate:"required" example:"platform"`                     // Team responsible for the account
+}
+
+// UpdateServiceAccountRequest changes the fields that are set
+type UpdateServiceAccountRequest struct {
+	Description *string   `json:"description,omitempty"`
+	Role        *api.Role `json:"role,omitempty" enums:"admin,editor,viewer" example:"viewer"`
+	OwnerTeam   *string   `json:"owner_team,omitempty" example:"platform"`
+	IsActive    *bool     `json:"is_active,omitempty" example:"true"` // Inactive accounts reject all their keys
+}
+
+// ServiceAccountUsage aggregates the usage of the keys of a service account
+type ServiceAccountUsage struct {
+	RequestCount int64      `json:"request_count"`          // Requests authorized with any of its keys
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"` // Last time one of its keys passed an auth check
+	LastUsedIP   string     `json:"last_used_ip,omitempty" example:"10.0.0.1"`
+	ActiveKeys   int        `json:"active_keys" example:"1"` // Keys that are active, unrevoked and unexpired
+}
+
+type ServiceAccountResponse struct {
+	ID          uint                `json:"id" example:"1"`
+	Subject     string              `json:"subject" example:"service-account|1"` // User ID the platform sees for requests of the account
+	Name        string              `json:"name" example:"ci-pipeline"`
+	Description string              `json:"description"`
+	Role        api.Role            `json:"role" enums:"admin,editor,viewer" example:"viewer"`
+	OwnerTeam   string              `json:"owner_team" example:"platform"`
+	IsActive    bool                `json:"is_active" example:"true"`
+	CreatedBy   string              `json:"created_by"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+	Usage       ServiceAccountUsage `json:"usage"`
+	Keys        []APIKeyResponse    `json:"keys,omitempty"` // Only returned when getting a single account
+}
+
+type CreateServiceAccountKeyRequest struct {
+	Name          string        `json:"name"`                                   // Name of the key
+	ExpiresInDays int           `json:"expires_in_days,omitempty" example:"90"` // Lifetime of the key, defaults to the configured default
+	Scopes        *APIKeyScopes `json:"scopes,omitempty"`                       // Optional restrictions of the key
+}
+
+// RotateServiceAccountKeyRequest creates a new key, the previously active key keeps working for the grace period
+type RotateServiceAccountKeyRequest struct {
+	CreateServiceAccountKeyRequest
+	GracePeriodHours *int `json:"grace_period_hours,omitempty" example:"24"` // Overlap of the old and new keys, defaults to 24
+}
@@ -0,0 +1,16 @@
+package api
+
+import "time"
+
+type RevokeSessionsRequest struct {
+	Reason string `json:"reason,omitempty" example:"lost laptop"` // Why the sessions are revoked, kept with the revocation
+}
+
+// SessionRevocationResponse tells which sessions of a user are revoked, tokens issued before
+// RevokedBefore are rejected until they expire
+type SessionRevocationResponse struct {
+	Subject       string    `json:"subject" example:"local|user@example.com"` // External ID of the user
+	RevokedBefore time.Time `json:"revoked_before" example:"2023-03-31T09:36:09Z"`
+	RevokedBy     string    `json:"revoked_by"`
+	Reason        string    `json:"reason"`
+}
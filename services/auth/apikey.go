@@ -0,0 +1,346 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	envoyauth "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	envoytype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/golang-jwt/jwt"
+	"github.com/opengovern/opensecurity/services/auth/api"
+	"github.com/opengovern/opensecurity/services/auth/authcache"
+	"github.com/opengovern/opensecurity/services/auth/db"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"gorm.io/gorm"
+)
+
+// apiKeyUsageInterval throttles how often the last usage of a key is written to the database.
+const apiKeyUsageInterval = time.Minute
+
+// hashAPIKeyToken returns the hash API keys are stored and looked up by.
+func hashAPIKeyToken(token string) string {
+	hash := sha512.Sum512([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// signAPIKeyToken signs the platform token of a new API key, and returns it with the masked form
+// shown in listings and the hash the key record is looked up by.
+func signAPIKeyToken(privateKey *rsa.PrivateKey, claims *userClaim) (token, maskedKey, keyHash string, err error) {
+	token, err = jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(privateKey)
+	if err != nil {
+		return "", "", "", err
+	}
+	maskedKey = "invalid"
+	if len(token) > 20 { // Basic check to avoid panic on very short tokens
+		maskedKey = fmt.Sprintf("%s...%s", token[:10], token[len(token)-10:])
+	}
+	return token, maskedKey, hashAPIKeyToken(token), nil
+}
+
+// cachedAPIKeyFromDB converts an API key record to its cached form.
+func cachedAPIKeyFromDB(key db.ApiKey) *authcache.CachedAPIKey {
+	var serviceAccountID uint
+	if key.ServiceAccountID != nil {
+		serviceAccountID = *key.ServiceAccountID
+	}
+	return &authcache.CachedAPIKey{
+		ID:                  key.ID,
+		Role:                string(key.Role),
+		ServiceAccountID:    serviceAccountID,
+		CreatorUserID:       key.CreatorUserID,
+		IsActive:            key.IsActive,
+		ExpiresAt:           key.ExpiresAt,
+		RevokedAt:           key.RevokedAt,
+		LastUsedAt:          key.LastUsedAt,
+		AllowedServices:     key.AllowedServices,
+		AllowedPathPrefixes: key.AllowedPathPrefixes,
+		AllowedMethods:      key.AllowedMethods,
+	}
+}
+
+// apiKeyScopesFromDB maps the scope columns of a key to the API representation.
+func apiKeyScopesFromDB(key db.ApiKey) api.APIKeyScopes {
+	return api.APIKeyScopes{
+		Services:     key.AllowedServices,
+		PathPrefixes: key.AllowedPathPrefixes,
+		Methods:      key.AllowedMethods,
+	}
+}
+
+// apiKeyToAPI maps an API key record to its API representation.
+func apiKeyToAPI(key db.ApiKey) api.APIKeyResponse {
+	return api.APIKeyResponse{
+		ID:            key.ID,
+		CreatedAt:     key.CreatedAt,
+		UpdatedAt:     key.UpdatedAt,
+		Name:          key.Name,
+		RoleName:      key.Role,
+		CreatorUserID: key.CreatorUserID,
+		Active:        key.IsActive,
+		MaskedKey:     key.MaskedKey,
+		ExpiresAt:     key.ExpiresAt,
+		RevokedAt:     key.RevokedAt,
+		RevokedBy:     key.RevokedBy,
+		LastUsedAt:    key.LastUsedAt,
+		LastUsedIP:    key.LastUsedIP,
+		UsageCount:    key.UsageCount,
+		Scopes:        apiKeyScopesFromDB(key),
+	}
+}
+
+// getAPIKeyState loads the state of an API key from the auth cache, falling back to the database.
+// It returns nil without error when the token does not belong to any key.
+func (s *Server) getAPIKeyState(ctx context.Context, keyHash string) (*authcache.CachedAPIKey, error) {
+	cached, err := s.authCache.GetAPIKey(ctx, keyHash)
+	if err == nil {
+		return cached, nil
+	}
+	if !errors.Is(err, authcache.ErrAPIKeyNotFound) {
+		s.logger.Error("API key cache error, falling back to DB", zap.Error(err))
+	}
+
+	key, err := s.db.GetApiKeyByHash(keyHash)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	state := cachedAPIKeyFromDB(*key)
+	if err := s.authCache.AddAPIKeyToCache(ctx, keyHash, state); err != nil {
+		s.logger.Error("Failed to populate API key cache after DB lookup", zap.Uint("id", key.ID), zap.Error(err))
+	}
+	return state, nil
+}
+
+// authorizeAPIKey checks the key a platform token belongs to is usable for the request.
+// On denial it returns the response to send back, otherwise the state of the key.
+func (s *Server) authorizeAPIKey(ctx context.Context, req *envoyauth.CheckRequest, keyHash string,
+	unAuth *envoyauth.CheckResponse, logFields []zap.Field) (*authcache.CachedAPIKey, *envoyauth.CheckResponse) {
+	key, err := s.getAPIKeyState(ctx, keyHash)
+	if err != nil {
+		s.logger.Error("Failed to load API key state", append(logFields, zap.Error(err))...)
+		return nil, unAuth
+	}
+	if key == nil {
+		// Deleted keys have no record anymore
+		s.logger.Warn("Access denied: API key not found", logFields...)
+		return nil, unAuth
+	}
+
+	logFields = append(logFields, zap.Uint("apiKeyId", key.ID))
+	now := time.Now()
+	switch {
+	case key.RevokedAt != nil:
+		s.logger.Warn("Access denied: API key is revoked", logFields...)
+		return nil, unAuth
+	case !key.IsActive:
+		s.logger.Warn("Access denied: API key is inactive", logFields...)
+		return nil, unAuth
+	case key.ExpiresAt != nil && now.After(*key.ExpiresAt):
+		s.logger.Warn("Access denied: API key is expired", logFields...)
+		return nil, unAuth
+	}
+
+	httpRequest := req.GetAttributes().GetRequest().GetHttp()
+	if reason := apiKeyScopeViolation(key, httpRequest.GetPath(), httpRequest.GetMethod()); reason != "" {
+		s.logger.Warn("Access denied: request is outside of the API key scopes", append(logFields, zap.String("reason", reason))...)
+		return nil, &envoyauth.CheckResponse{
+			Status: &status.Status{Code: int32(rpc.PERMISSION_DENIED), Message: reason},
+			HttpResponse: &envoyauth.CheckResponse_DeniedResponse{
+				DeniedResponse: &envoyauth.DeniedHttpResponse{
+					Status: &envoytype.HttpStatus{Code: http.StatusForbidden},
+					Body:   http.StatusText(http.StatusForbidden),
+				},
+			},
+		}
+	}
+
+	s.countAPIKeyUsage(key.ID)
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyUsageInterval {
+		go s.recordAPIKeyUsage(keyHash, key, now, requestClientIP(req, s.trustedProxies))
+	}
+	return key, nil
+}
+
+// countAPIKeyUsage counts a request authorized with a key, APIKeyUsageLoop adds the counts to the key records.
+func (s *Server) countAPIKeyUsage(id uint) {
+	counter, _ := s.apiKeyUsage.LoadOrStore(id, new(atomic.Int64))
+	counter.(*atomic.Int64).Add(1)
+}
+
+// APIKeyUsageLoop periodically adds the requests counted by this replica to the usage count of the keys.
+func (s *Server) APIKeyUsageLoop() {
+	s.logger.Info("Starting APIKeyUsageLoop...")
+	ticker := time.NewTicker(apiKeyUsageInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.apiKeyUsage.Range(func(id, counter any) bool {
+			count := counter.(*atomic.Int64).Swap(0)
+			if count == 0 {
+				return true
+			}
+			if err := s.db.AddApiKeyUsage(id.(uint), count); err != nil {
+				counter.(*atomic.Int64).Add(count) // Retried on the next tick, the error is logged by the db layer
+			}
+			return true
+		})
+	}
+}
+
+// recordAPIKeyUsage stores the last usage of a key and refreshes its cached state.
+func (s *Server) recordAPIKeyUsage(keyHash string, key *authcache.CachedAPIKey, usedAt time.Time, ip string) {
+	if err := s.db.UpdateApiKeyLastUsed(key.ID, usedAt, ip); err != nil {
+		return // Logged by the db layer
+	}
+	updated := *key
+	updated.LastUsedAt = &usedAt
+	if err := s.authCache.AddAPIKeyToCache(context.Background(), keyHash, &updated); err != nil {
+		s.logger.Warn("Failed to refresh API key cache after usage update", zap.Uint("id", key.ID), zap.Error(err))
+	}
+}
+
+// apiKeyScopeViolation returns why a request is not allowed by the key scopes, or empty if it is.
+// The service of a request is the first segment of its path, e.g. compliance for /compliance/api/v1/...
+func apiKeyScopeViolation(key *authcache.CachedAPIKey, path, method string) string {
+	if len(key.AllowedServices) > 0 {
+		service := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]
+		if !containsFold(key.AllowedServices, service) {
+			return "service " + service + " is not allowed for this API key"
+		}
+	}
+	if len(key.AllowedPathPrefixes) > 0 {
+		allowed := false
+		for _, prefix := range key.AllowedPathPrefixes {
+			if strings.HasPrefix(path, prefix) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return "path " + path + " is not allowed for this API key"
+		}
+	}
+	if len(key.AllowedMethods) > 0 && !containsFold(key.AllowedMethods, method) {
+		return "method " + method + " is not allowed for this API key"
+	}
+	return ""
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestClientIP returns the original client address of a check request. It is the peer address Envoy saw,
+// unless that peer is a trusted proxy: the x-forwarded-for addresses are then read from the closest one and the
+// first address not belonging to a trusted proxy is the client. Headers set by anyone else can not be told
+// from forged ones and are ignored.
+func requestClientIP(req *envoyauth.CheckRequest, trustedProxies []*net.IPNet) string {
+	source := req.GetAttributes().GetSource().GetAddress().GetSocketAddress().GetAddress()
+	if !trustedProxy(source, trustedProxies) {
+		return source
+	}
+	forwarded := strings.Split(req.GetAttributes().GetRequest().GetHttp().GetHeaders()["x-forwarded-for"], ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		address := strings.TrimSpace(forwarded[i])
+		if address == "" {
+			continue
+		}
+		if !trustedProxy(address, trustedProxies) {
+			return address
+		}
+		source = address
+	}
+	return source
+}
+
+// trustedProxy reports whether an address belongs to one of the trusted proxy networks.
+func trustedProxy(address string, trustedProxies []*net.IPNet) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies parses a comma separated list of CIDRs or addresses.
+func parseTrustedProxies(value string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy address %q", item)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy network %q: %w", item, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// normalizeAPIKeyScopes cleans the requested scopes before they are stored.
+func normalizeAPIKeyScopes(scopes *api.APIKeyScopes) (services, prefixes, methods []string, err error) {
+	if scopes == nil {
+		return nil, nil, nil, nil
+	}
+	for _, service := range scopes.Services {
+		service = strings.Trim(strings.TrimSpace(service), "/")
+		if service == "" || strings.Contains(service, "/") {
+			return nil, nil, nil, errors.New("invalid service scope: " + service)
+		}
+		services = append(services, strings.ToLower(service))
+	}
+	for _, prefix := range scopes.PathPrefixes {
+		prefix = strings.TrimSpace(prefix)
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, nil, nil, errors.New("path prefix scopes must start with /: " + prefix)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	for _, method := range scopes.Methods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		switch method {
+		case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+			methods = append(methods, method)
+		default:
+			return nil, nil, nil, errors.New("invalid method scope: " + method)
+		}
+	}
+	return services, prefixes, methods, nil
+}
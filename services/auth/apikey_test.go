@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"testing"
+
+	envoycore "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoyauth "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func checkRequestFrom(source, forwardedFor string) *envoyauth.CheckRequest {
+	headers := map[string]string{}
+	if forwardedFor != "" {
+		headers["x-forwarded-for"] = forwardedFor
+	}
+	return &envoyauth.CheckRequest{
+		Attributes: &envoyauth.AttributeContext{
+			Source: &envoyauth.AttributeContext_Peer{
+				Address: &envoycore.Address{Address: &envoycore.Address_SocketAddress{
+					SocketAddress: &envoycore.SocketAddress{Address: source},
+				}},
+			},
+			Request: &envoyauth.AttributeContext_Request{
+				Http: &envoyauth.AttributeContext_HttpRequest{Headers: headers},
+			},
+		},
+	}
+}
+
+func TestRequestClientIP(t *testing.T) {
+	proxies, err := parseTrustedProxies("10.0.0.0/8, 192.168.1.10")
+	require.NoError(t, err)
+
+	// Forwarded addresses from untrusted peers are ignored
+	assert.Equal(t, "203.0.113.7", requestClientIP(checkRequestFrom("203.0.113.7", "1.2.3.4"), proxies))
+	assert.Equal(t, "203.0.113.7", requestClientIP(checkRequestFrom("203.0.113.7", "1.2.3.4"), nil))
+
+	// Trusted proxies are skipped from the closest one, a forged leftmost address is not reached
+	assert.Equal(t, "198.51.100.2", requestClientIP(checkRequestFrom("10.1.2.3", "1.2.3.4, 198.51.100.2, 192.168.1.10"), proxies))
+
+	// A trusted peer without the header is the client
+	assert.Equal(t, "10.1.2.3", requestClientIP(checkRequestFrom("10.1.2.3", ""), proxies))
+
+	// Only trusted proxies in the chain, the farthest one is the client
+	assert.Equal(t, "192.168.1.10", requestClientIP(checkRequestFrom("10.1.2.3", "192.168.1.10"), proxies))
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := parseTrustedProxies("")
+	require.NoError(t, err)
+	assert.Empty(t, proxies)
+
+	_, err = parseTrustedProxies("10.0.0.0/33")
+	assert.Error(t, err)
+	_, err = parseTrustedProxies("proxy.local")
+	assert.Error(t, err)
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opengovern/opensecurity/services/auth/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// auditChain builds a chain the way AppendAuditEvent does, keyed from the event at index keyedFrom on.
+func auditChain(key []byte, keyedFrom, n int) []db.AuditEvent {
+	prevHash := db.AuditGenesisHash()
+	events := make([]db.AuditEvent, n)
+	for i := range events {
+		events[i] = db.AuditEvent{
+			ID:        uint64(i + 1),
+			Timestamp: time.Date(2026, 1, 1, 0, 0, i, 0, time.UTC),
+			EventType: db.AuditEventTypeAPICall,
+			ActorID:   "user-1",
+			Method:    "POST",
+			Path:      "/core/api/v1/query/run",
+			Decision:  db.AuditDecisionAllowed,
+			PrevHash:  prevHash,
+		}
+		var eventKey []byte
+		if i >= keyedFrom {
+			events[i].Keyed = true
+			eventKey = key
+		}
+		events[i].Hash = events[i].ComputeHash(prevHash, eventKey)
+		prevHash = events[i].Hash
+	}
+	return events
+}
+
+func checkAuditChain(key []byte, events []db.AuditEvent) (uint64, string, int64) {
+	verifier := auditChainVerifier{key: key, prevHash: db.AuditGenesisHash()}
+	for _, event := range events {
+		if reason := verifier.check(event); reason != "" {
+			return event.ID, reason, verifier.unkeyed
+		}
+	}
+	return 0, "", verifier.unkeyed
+}
+
+func TestAuditChainValid(t *testing.T) {
+	key := []byte("audit-key")
+
+	brokenAt, reason, unkeyed := checkAuditChain(key, auditChain(key, 2, 5))
+	assert.Zero(t, brokenAt, reason)
+	assert.Equal(t, int64(2), unkeyed, "events from before the key was configured are counted")
+
+	brokenAt, reason, unkeyed = checkAuditChain(nil, auditChain(nil, 5, 5))
+	assert.Zero(t, brokenAt, reason)
+	assert.Equal(t, int64(5), unkeyed)
+}
+
+func TestAuditChainTampering(t *testing.T) {
+	key := []byte("audit-key")
+
+	events := auditChain(key, 0, 5)
+	events[2].Decision = db.AuditDecisionDenied
+	brokenAt, _, _ := checkAuditChain(key, events)
+	assert.Equal(t, uint64(3), brokenAt, "a modified event does not match its hash")
+
+	events = auditChain(key, 0, 5)
+	events = append(events[:2], events[3:]...)
+	brokenAt, _, _ = checkAuditChain(key, events)
+	assert.Equal(t, uint64(4), brokenAt, "the event after a removed one does not link to it")
+
+	// Without the key, the rewritten hashes do not match
+	events = auditChain(key, 0, 5)
+	forged := auditChain([]byte("other-key"), 0, 5)
+	brokenAt, _, _ = checkAuditChain(key, forged)
+	assert.Equal(t, uint64(1), brokenAt)
+
+	// Nor can the tail be rewritten unkeyed
+	events = append(events[:3], auditChain(nil, 5, 5)[3:]...)
+	for i := 3; i < len(events); i++ {
+		events[i].PrevHash = events[i-1].Hash
+		events[i].Hash = events[i].ComputeHash(events[i].PrevHash, nil)
+	}
+	brokenAt, _, _ = checkAuditChain(key, events)
+	assert.Equal(t, uint64(4), brokenAt)
+
+	// Keyed events can not be checked without the key
+	brokenAt, _, _ = checkAuditChain(nil, auditChain(key, 0, 1))
+	assert.Equal(t, uint64(1), brokenAt)
+}
+
+func TestAuditSpillDrain(t *testing.T) {
+	spill := newAuditSpill(filepath.Join(t.TempDir(), "spill.ndjson"))
+	events := auditChain(nil, 3, 3)
+	for _, event := range events {
+		require.NoError(t, spill.write(event))
+	}
+
+	// Events from the failed one on stay spilled
+	var stored []db.AuditEvent
+	n, err := spill.drain(func(event db.AuditEvent) error {
+		if len(stored) == 1 {
+			return errors.New("database unavailable")
+		}
+		stored = append(stored, event)
+		return nil
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, n)
+
+	n, err = spill.drain(func(event db.AuditEvent) error {
+		stored = append(stored, event)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	require.Len(t, stored, 3)
+	for i, event := range stored {
+		assert.Equal(t, events[i].Timestamp, event.Timestamp.UTC())
+		assert.Equal(t, events[i].ActorID, event.ActorID)
+	}
+
+	_, err = os.Stat(spill.path)
+	assert.True(t, os.IsNotExist(err))
+	n, err = spill.drain(func(db.AuditEvent) error { return nil })
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestAuditPathAndTargets(t *testing.T) {
+	path, targets := auditPathAndTargets("/integration/api/v1/integrations/0b7a3f4e-5c4d-4a8e-9f1a-2b3c4d5e6f70/healthcheck?token=secret&integration_ids=a,b&a")
+	assert.Equal(t, "/integration/api/v1/integrations/0b7a3f4e-5c4d-4a8e-9f1a-2b3c4d5e6f70/healthcheck", path)
+	assert.Equal(t, []string{"0b7a3f4e-5c4d-4a8e-9f1a-2b3c4d5e6f70", "a", "b"}, targets)
+
+	_, targets = auditPathAndTargets("/auth/api/v1/keys/42?id=42")
+	assert.Equal(t, []string{"42"}, targets)
+}
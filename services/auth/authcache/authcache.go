@@ -1,5 +1,6 @@
-// Package authcache provides an embedded in-process cache for user authorization info,
-// backed by in-memory go-cache and instrumented with Prometheus metrics.
+// Package authcache provides a cache for user authorization info, backed by in-memory
+// go-cache by default or by NATS KV buckets shared between replicas, and instrumented
+// with Prometheus metrics.
 package authcache
 
 import (
@@ -7,6 +8,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -32,6 +34,33 @@ const UserEmailKeyPrefix = "user:email:"
 // externalIDKeyPrefix is the prefix for cache keys mapping external ID to email.
 const externalIDKeyPrefix = "extid:email:" // <-- New prefix for the ID map cache
 
+// apiKeyHashKeyPrefix is the prefix for cache keys storing API key state by token hash.
+const apiKeyHashKeyPrefix = "apikey:hash:"
+
+// DefaultAPIKeyCacheTTL bounds how long a revocation made on another replica can take to be seen.
+const DefaultAPIKeyCacheTTL = 1 * time.Minute
+
+// integrationScopeKeyPrefix is the prefix for cache keys storing the resolved integration scope of a subject.
+const integrationScopeKeyPrefix = "scope:"
+
+// DefaultIntegrationScopeCacheTTL bounds how long label and group membership changes take to apply to scopes.
+const DefaultIntegrationScopeCacheTTL = 1 * time.Minute
+
+// connectorRoleMappingKeyPrefix is the prefix for cache keys storing the role mapping of a connector.
+const connectorRoleMappingKeyPrefix = "connector:rolemapping:"
+
+// serviceAccountKeyPrefix is the prefix for cache keys storing service account state by ID.
+const serviceAccountKeyPrefix = "serviceaccount:id:"
+
+// sessionRevocationKeyPrefix is the prefix for cache keys storing the session revocation of a subject.
+const sessionRevocationKeyPrefix = "session:revocation:"
+
+// DefaultSessionRevocationCacheTTL bounds how long revoked sessions can keep working on other replicas.
+const DefaultSessionRevocationCacheTTL = 1 * time.Minute
+
+// DefaultConnectorRoleMappingCacheTTL bounds how long rule changes take to apply on other replicas.
+const DefaultConnectorRoleMappingCacheTTL = 1 * time.Minute
+
 // --- Errors ---
 
 // ErrUserInfoNotFound indicates that a user's info was not present in cache,
@@ -41,6 +70,21 @@ var ErrUserInfoNotFound = errors.New("authcache: user info not found")
 // ErrEmailNotFound indicates that an email mapping was not found for an external ID.
 var ErrEmailNotFound = errors.New("authcache: email not found for external ID") // <-- New error
 
+// ErrAPIKeyNotFound indicates that an API key's state was not present in cache.
+var ErrAPIKeyNotFound = errors.New("authcache: api key not found")
+
+// ErrIntegrationScopeNotFound indicates that the scope of a subject was not present in cache.
+var ErrIntegrationScopeNotFound = errors.New("authcache: integration scope not found")
+
+// ErrServiceAccountNotFound indicates that a service account's state was not present in cache.
+var ErrServiceAccountNotFound = errors.New("authcache: service account not found")
+
+// ErrSessionRevocationNotFound indicates that the session revocation of a subject was not present in cache.
+var ErrSessionRevocationNotFound = errors.New("authcache: session revocation not found")
+
+// ErrConnectorRoleMappingNotFound indicates that the role mapping of a connector was not present in cache.
+var ErrConnectorRoleMappingNotFound = errors.New("authcache: connector role mapping not found")
+
 // --- Prometheus metrics ---
 
 var (
@@ -90,6 +134,55 @@ type CachedUserInfo struct {
 	// CreatedAt  time.Time `json:"created_at"`
 }
 
+// CachedAPIKey holds the state of an API key needed to authorize a request made with it.
+type CachedAPIKey struct {
+	ID                  uint       `json:"id"`                    // Internal DB ID
+	Role                string     `json:"role"`                  // Role granted by the key
+	ServiceAccountID    uint       `json:"service_account_id"`    // Owning service account, 0 for keys of users
+	CreatorUserID       string     `json:"creator_user_id"`       // External ID of the key creator
+	IsActive            bool       `json:"is_active"`             // Activity state of the key
+	ExpiresAt           *time.Time `json:"expires_at"`            // Expiry of the key
+	RevokedAt           *time.Time `json:"revoked_at"`            // Revocation time, nil if not revoked
+	LastUsedAt          *time.Time `json:"last_used_at"`          // Last recorded usage
+	AllowedServices     []string   `json:"allowed_services"`      // Service scope
+	AllowedPathPrefixes []string   `json:"allowed_path_prefixes"` // Route prefix scope
+	AllowedMethods      []string   `json:"allowed_methods"`       // HTTP method scope
+}
+
+// CachedServiceAccount holds the state of a service account needed to authorize requests made with its keys.
+type CachedServiceAccount struct {
+	ID        uint   `json:"id"`         // Internal DB ID
+	Name      string `json:"name"`       // Unique name of the account
+	Role      string `json:"role"`       // Role granted to requests made with its keys
+	OwnerTeam string `json:"owner_team"` // Team responsible for the account
+	IsActive  bool   `json:"is_active"`  // Disabled accounts reject all their keys
+}
+
+// CachedSessionRevocation holds when the sessions of a subject were last revoked.
+type CachedSessionRevocation struct {
+	RevokedBefore int64 `json:"revoked_before_us"` // Unix microseconds, tokens issued before are rejected. 0 if never revoked
+}
+
+// CachedIntegrationScope holds the integrations a subject's role bindings resolve to.
+type CachedIntegrationScope struct {
+	HasBindings    bool     `json:"has_bindings"`    // False if the subject has no role bindings
+	IntegrationIDs []string `json:"integration_ids"` // Resolved integrations of the bindings
+}
+
+// CachedRoleRule maps a value of an identity provider claim to a role.
+type CachedRoleRule struct {
+	Claim string `json:"claim"` // groups, email, email_domain or preferred_username
+	Value string `json:"value"` // Case-insensitive glob matched against the claim
+	Role  string `json:"role"`  // Role granted when the rule matches
+}
+
+// CachedConnectorRoleMapping holds how users signing in through a connector get their role.
+type CachedConnectorRoleMapping struct {
+	JITProvisioning bool             `json:"jit_provisioning"` // Create unknown users on their first login
+	DefaultRole     string           `json:"default_role"`     // Role when no rule matches, empty denies access
+	Rules           []CachedRoleRule `json:"rules"`
+}
+
 // --- CacheClient abstraction ---
 
 // CacheClient defines the methods required to interact with an underlying cache.
@@ -167,31 +260,77 @@ func (c *inMemoryClient) Close() error {
 type AuthCacheService struct {
 	userInfoCache  CacheClient   // Cache for user:email:<email> -> CachedUserInfo JSON string
 	idToEmailCache CacheClient   // Cache for extid:email:<extid> -> email string
+	apiKeyCache    CacheClient   // Cache for apikey:hash:<hash> -> CachedAPIKey JSON string
+	scopeCache     CacheClient   // Cache for scope:<subject type>:<subject id> -> CachedIntegrationScope JSON string
+	connectorCache CacheClient   // Cache for connector:rolemapping:<connector id> -> CachedConnectorRoleMapping JSON string
 	logger         *zap.Logger   // structured logger
 	ttl            time.Duration // default entry TTL used by AddUserToCache
 }
 
+// ClientFactory creates the cache client backing one of the caches of the service.
+// name identifies the cache, defaultTTL is the TTL its entries are usually stored with.
+type ClientFactory func(name string, defaultTTL time.Duration) (CacheClient, error)
+
+// InMemoryClientFactory creates go-cache clients, entries are local to the replica.
+func InMemoryClientFactory(_ string, defaultTTL time.Duration) (CacheClient, error) {
+	cleanupInterval := DefaultCleanupInterval
+	if defaultTTL > DefaultCacheTTL {
+		cleanupInterval = DefaultCleanupInterval * 2
+	}
+	return NewInMemoryClient(defaultTTL, cleanupInterval), nil
+}
+
 // NewAuthCacheService creates and initializes the cache service.
-// It uses two in-memory go-cache instances under the hood.
+// It uses in-memory go-cache instances under the hood.
 func NewAuthCacheService(logger *zap.Logger) (*AuthCacheService, error) {
+	return NewAuthCacheServiceWithFactory(logger, "go-cache", InMemoryClientFactory)
+}
+
+// NewAuthCacheServiceWithFactory creates the cache service on the clients of a backend,
+// a shared backend keeps the caches of all replicas consistent.
+func NewAuthCacheServiceWithFactory(logger *zap.Logger, backend string, factory ClientFactory) (*AuthCacheService, error) {
 	if logger == nil {
 		return nil, errors.New("logger cannot be nil")
 	}
 
-	// Initialize the main user info cache
-	userInfoClient := NewInMemoryClient(DefaultCacheTTL, DefaultCleanupInterval)
-	// Initialize the ID-to-Email mapping cache (could potentially have a different TTL/cleanup)
-	idToEmailClient := NewInMemoryClient(DefaultCacheTTL*2, DefaultCleanupInterval*2) // Example: longer TTL for mapping
+	caches := []struct {
+		name string
+		ttl  time.Duration
+	}{
+		{"userinfo", DefaultCacheTTL},
+		{"idmap", DefaultCacheTTL * 2}, // Longer TTL for the ID-to-Email mapping
+		{"apikey", DefaultAPIKeyCacheTTL},
+		{"scope", DefaultIntegrationScopeCacheTTL},
+		{"connector", DefaultConnectorRoleMappingCacheTTL},
+	}
+	clients := make([]CacheClient, 0, len(caches))
+	for _, cache := range caches {
+		client, err := factory(cache.name, cache.ttl)
+		if err != nil {
+			for _, created := range clients {
+				_ = created.Close()
+			}
+			return nil, fmt.Errorf("failed to create %s cache client: %w", cache.name, err)
+		}
+		clients = append(clients, client)
+	}
 
 	namedLogger := logger.Named("authcache")
-	namedLogger.Info("AuthCacheService initialized (using go-cache)",
+	namedLogger.Info("AuthCacheService initialized",
+		zap.String("backend", backend),
 		zap.Duration("user_info_ttl", DefaultCacheTTL),
-		zap.Duration("id_map_ttl", DefaultCacheTTL*2), // Log TTL for the new cache
+		zap.Duration("id_map_ttl", DefaultCacheTTL*2),
+		zap.Duration("api_key_ttl", DefaultAPIKeyCacheTTL),
+		zap.Duration("integration_scope_ttl", DefaultIntegrationScopeCacheTTL),
+		zap.Duration("connector_role_mapping_ttl", DefaultConnectorRoleMappingCacheTTL),
 	)
 
 	return &AuthCacheService{
-		userInfoCache:  userInfoClient,
-		idToEmailCache: idToEmailClient, // Assign the second cache client
+		userInfoCache:  clients[0],
+		idToEmailCache: clients[1],
+		apiKeyCache:    clients[2],
+		scopeCache:     clients[3],
+		connectorCache: clients[4],
 		logger:         namedLogger,
 		ttl:            DefaultCacheTTL, // Store the default TTL for AddUserToCache
 	}, nil
@@ -403,6 +542,314 @@ func (s *AuthCacheService) SetExternalIDToEmail(ctx context.Context, externalID,
 	return nil
 }
 
+// --- API key state ---
+
+// formatAPIKeyHashKey builds the cache key for API key state based on the token hash.
+func formatAPIKeyHashKey(keyHash string) string {
+	return apiKeyHashKeyPrefix + keyHash
+}
+
+// GetAPIKey fetches the state of an API key from the cache using the hash of its token.
+// Returns ErrAPIKeyNotFound if no valid entry exists.
+func (s *AuthCacheService) GetAPIKey(ctx context.Context, keyHash string) (*CachedAPIKey, error) {
+	if keyHash == "" {
+		return nil, errors.New("key hash cannot be empty")
+	}
+	key := formatAPIKeyHashKey(keyHash)
+
+	rawValue, err := s.apiKeyCache.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrUserInfoNotFound) { // Adapter returns ErrUserInfoNotFound on miss
+			return nil, ErrAPIKeyNotFound
+		}
+		metricErrors.Inc()
+		s.logger.Error("API key cache GET error", zap.Error(err))
+		return nil, fmt.Errorf("api key cache GET failed: %w", err)
+	}
+
+	var info CachedAPIKey
+	if err := json.Unmarshal([]byte(rawValue), &info); err != nil {
+		metricErrors.Inc()
+		s.logger.Error("API key cache data unmarshal error", zap.Error(err))
+		_ = s.apiKeyCache.Del(ctx, key) // Delete corrupted entry
+		return nil, ErrAPIKeyNotFound
+	}
+	return &info, nil
+}
+
+// AddAPIKeyToCache stores the state of an API key by the hash of its token.
+func (s *AuthCacheService) AddAPIKeyToCache(ctx context.Context, keyHash string, info *CachedAPIKey) error {
+	if keyHash == "" {
+		return errors.New("key hash cannot be empty for cache add")
+	}
+	if info == nil {
+		return errors.New("cannot cache nil api key")
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		metricErrors.Inc()
+		return fmt.Errorf("api key cache marshal failed: %w", err)
+	}
+	if err := s.apiKeyCache.Set(ctx, formatAPIKeyHashKey(keyHash), string(data), DefaultAPIKeyCacheTTL); err != nil {
+		metricErrors.Inc()
+		s.logger.Error("API key cache SET error", zap.Uint("id", info.ID), zap.Error(err))
+		return fmt.Errorf("api key cache SET failed: %w", err)
+	}
+	return nil
+}
+
+// RemoveAPIKeyFromCache deletes the state of an API key, the next check reloads it from the database.
+func (s *AuthCacheService) RemoveAPIKeyFromCache(ctx context.Context, keyHash string) error {
+	if keyHash == "" {
+		return errors.New("key hash cannot be empty for cache remove")
+	}
+	if err := s.apiKeyCache.Del(ctx, formatAPIKeyHashKey(keyHash)); err != nil {
+		metricErrors.Inc()
+		s.logger.Error("API key cache DEL error (unexpected)", zap.Error(err))
+	}
+	return nil // Best-effort, like RemoveUserFromCache
+}
+
+// --- Integration scopes ---
+
+// formatIntegrationScopeKey builds the cache key for the scope of a subject.
+func formatIntegrationScopeKey(subjectType, subjectID string) string {
+	return integrationScopeKeyPrefix + subjectType + ":" + subjectID
+}
+
+// GetIntegrationScope fetches the resolved integration scope of a subject from the cache.
+// Returns ErrIntegrationScopeNotFound if no valid entry exists.
+func (s *AuthCacheService) GetIntegrationScope(ctx context.Context, subjectType, subjectID string) (*CachedIntegrationScope, error) {
+	key := formatIntegrationScopeKey(subjectType, subjectID)
+	rawValue, err := s.scopeCache.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrUserInfoNotFound) { // Adapter returns ErrUserInfoNotFound on miss
+			return nil, ErrIntegrationScopeNotFound
+		}
+		metricErrors.Inc()
+		s.logger.Error("Integration scope cache GET error", zap.String("key", key), zap.Error(err))
+		return nil, fmt.Errorf("integration scope cache GET failed for %q: %w", key, err)
+	}
+
+	var scope CachedIntegrationScope
+	if err := json.Unmarshal([]byte(rawValue), &scope); err != nil {
+		metricErrors.Inc()
+		s.logger.Error("Integration scope cache data unmarshal error", zap.String("key", key), zap.Error(err))
+		_ = s.scopeCache.Del(ctx, key)
+		return nil, ErrIntegrationScopeNotFound
+	}
+	return &scope, nil
+}
+
+// SetIntegrationScope stores the resolved integration scope of a subject.
+func (s *AuthCacheService) SetIntegrationScope(ctx context.Context, subjectType, subjectID string, scope *CachedIntegrationScope) error {
+	if subjectID == "" || scope == nil {
+		return errors.New("subject and scope cannot be empty for cache add")
+	}
+	key := formatIntegrationScopeKey(subjectType, subjectID)
+	data, err := json.Marshal(scope)
+	if err != nil {
+		metricErrors.Inc()
+		return fmt.Errorf("integration scope cache marshal failed for %q: %w", key, err)
+	}
+	if err := s.scopeCache.Set(ctx, key, string(data), DefaultIntegrationScopeCacheTTL); err != nil {
+		metricErrors.Inc()
+		s.logger.Error("Integration scope cache SET error", zap.String("key", key), zap.Error(err))
+		return fmt.Errorf("integration scope cache SET failed for %q: %w", key, err)
+	}
+	return nil
+}
+
+// RemoveIntegrationScope deletes the cached scope of a subject after its role bindings changed.
+func (s *AuthCacheService) RemoveIntegrationScope(ctx context.Context, subjectType, subjectID string) error {
+	key := formatIntegrationScopeKey(subjectType, subjectID)
+	if err := s.scopeCache.Del(ctx, key); err != nil {
+		metricErrors.Inc()
+		s.logger.Error("Integration scope cache DEL error (unexpected)", zap.String("key", key), zap.Error(err))
+	}
+	return nil // Best-effort, like RemoveUserFromCache
+}
+
+// --- Service accounts ---
+
+// formatServiceAccountKey builds the cache key for the state of a service account.
+func formatServiceAccountKey(id uint) string {
+	return serviceAccountKeyPrefix + strconv.FormatUint(uint64(id), 10)
+}
+
+// GetServiceAccount fetches the state of a service account from the cache.
+// Returns ErrServiceAccountNotFound if no valid entry exists.
+func (s *AuthCacheService) GetServiceAccount(ctx context.Context, id uint) (*CachedServiceAccount, error) {
+	key := formatServiceAccountKey(id)
+	rawValue, err := s.apiKeyCache.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrUserInfoNotFound) { // Adapter returns ErrUserInfoNotFound on miss
+			return nil, ErrServiceAccountNotFound
+		}
+		metricErrors.Inc()
+		s.logger.Error("Service account cache GET error", zap.String("key", key), zap.Error(err))
+		return nil, fmt.Errorf("service account cache GET failed for %q: %w", key, err)
+	}
+
+	var account CachedServiceAccount
+	if err := json.Unmarshal([]byte(rawValue), &account); err != nil {
+		metricErrors.Inc()
+		s.logger.Error("Service account cache data unmarshal error", zap.String("key", key), zap.Error(err))
+		_ = s.apiKeyCache.Del(ctx, key)
+		return nil, ErrServiceAccountNotFound
+	}
+	return &account, nil
+}
+
+// SetServiceAccount stores the state of a service account, it shares the TTL of API key states.
+func (s *AuthCacheService) SetServiceAccount(ctx context.Context, account *CachedServiceAccount) error {
+	if account == nil || account.ID == 0 {
+		return errors.New("service account cannot be empty for cache add")
+	}
+	key := formatServiceAccountKey(account.ID)
+	data, err := json.Marshal(account)
+	if err != nil {
+		metricErrors.Inc()
+		return fmt.Errorf("service account cache marshal failed for %q: %w", key, err)
+	}
+	if err := s.apiKeyCache.Set(ctx, key, string(data), DefaultAPIKeyCacheTTL); err != nil {
+		metricErrors.Inc()
+		s.logger.Error("Service account cache SET error", zap.String("key", key), zap.Error(err))
+		return fmt.Errorf("service account cache SET failed for %q: %w", key, err)
+	}
+	return nil
+}
+
+// RemoveServiceAccount deletes the cached state of a service account after it changed.
+func (s *AuthCacheService) RemoveServiceAccount(ctx context.Context, id uint) error {
+	key := formatServiceAccountKey(id)
+	if err := s.apiKeyCache.Del(ctx, key); err != nil {
+		metricErrors.Inc()
+		s.logger.Error("Service account cache DEL error (unexpected)", zap.String("key", key), zap.Error(err))
+	}
+	return nil // Best-effort, like RemoveUserFromCache
+}
+
+// --- Session revocations ---
+
+// formatSessionRevocationKey builds the cache key for the session revocation of a subject.
+func formatSessionRevocationKey(subject string) string {
+	return sessionRevocationKeyPrefix + subject
+}
+
+// GetSessionRevocation fetches the session revocation of a subject from the cache.
+// Returns ErrSessionRevocationNotFound if no valid entry exists.
+func (s *AuthCacheService) GetSessionRevocation(ctx context.Context, subject string) (*CachedSessionRevocation, error) {
+	key := formatSessionRevocationKey(subject)
+	rawValue, err := s.userInfoCache.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrUserInfoNotFound) { // Adapter returns ErrUserInfoNotFound on miss
+			return nil, ErrSessionRevocationNotFound
+		}
+		metricErrors.Inc()
+		s.logger.Error("Session revocation cache GET error", zap.String("key", key), zap.Error(err))
+		return nil, fmt.Errorf("session revocation cache GET failed for %q: %w", key, err)
+	}
+
+	var revocation CachedSessionRevocation
+	if err := json.Unmarshal([]byte(rawValue), &revocation); err != nil {
+		metricErrors.Inc()
+		s.logger.Error("Session revocation cache data unmarshal error", zap.String("key", key), zap.Error(err))
+		_ = s.userInfoCache.Del(ctx, key)
+		return nil, ErrSessionRevocationNotFound
+	}
+	return &revocation, nil
+}
+
+// SetSessionRevocation stores the session revocation of a subject, subjects never revoked are stored too.
+func (s *AuthCacheService) SetSessionRevocation(ctx context.Context, subject string, revocation *CachedSessionRevocation) error {
+	if subject == "" || revocation == nil {
+		return errors.New("subject and revocation cannot be empty for cache add")
+	}
+	key := formatSessionRevocationKey(subject)
+	data, err := json.Marshal(revocation)
+	if err != nil {
+		metricErrors.Inc()
+		return fmt.Errorf("session revocation cache marshal failed for %q: %w", key, err)
+	}
+	if err := s.userInfoCache.Set(ctx, key, string(data), DefaultSessionRevocationCacheTTL); err != nil {
+		metricErrors.Inc()
+		s.logger.Error("Session revocation cache SET error", zap.String("key", key), zap.Error(err))
+		return fmt.Errorf("session revocation cache SET failed for %q: %w", key, err)
+	}
+	return nil
+}
+
+// RemoveSessionRevocation deletes the cached session revocation of a subject after its sessions were revoked.
+func (s *AuthCacheService) RemoveSessionRevocation(ctx context.Context, subject string) error {
+	key := formatSessionRevocationKey(subject)
+	if err := s.userInfoCache.Del(ctx, key); err != nil {
+		metricErrors.Inc()
+		s.logger.Error("Session revocation cache DEL error (unexpected)", zap.String("key", key), zap.Error(err))
+	}
+	return nil // Best-effort, like RemoveUserFromCache
+}
+
+// --- Connector role mappings ---
+
+// formatConnectorRoleMappingKey builds the cache key for the role mapping of a connector.
+func formatConnectorRoleMappingKey(connectorID string) string {
+	return connectorRoleMappingKeyPrefix + connectorID
+}
+
+// GetConnectorRoleMapping fetches the role mapping of a connector from the cache.
+// Returns ErrConnectorRoleMappingNotFound if no valid entry exists.
+func (s *AuthCacheService) GetConnectorRoleMapping(ctx context.Context, connectorID string) (*CachedConnectorRoleMapping, error) {
+	key := formatConnectorRoleMappingKey(connectorID)
+	rawValue, err := s.connectorCache.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrUserInfoNotFound) { // Adapter returns ErrUserInfoNotFound on miss
+			return nil, ErrConnectorRoleMappingNotFound
+		}
+		metricErrors.Inc()
+		s.logger.Error("Connector role mapping cache GET error", zap.String("key", key), zap.Error(err))
+		return nil, fmt.Errorf("connector role mapping cache GET failed for %q: %w", key, err)
+	}
+
+	var mapping CachedConnectorRoleMapping
+	if err := json.Unmarshal([]byte(rawValue), &mapping); err != nil {
+		metricErrors.Inc()
+		s.logger.Error("Connector role mapping cache data unmarshal error", zap.String("key", key), zap.Error(err))
+		_ = s.connectorCache.Del(ctx, key)
+		return nil, ErrConnectorRoleMappingNotFound
+	}
+	return &mapping, nil
+}
+
+// SetConnectorRoleMapping stores the role mapping of a connector.
+func (s *AuthCacheService) SetConnectorRoleMapping(ctx context.Context, connectorID string, mapping *CachedConnectorRoleMapping) error {
+	if connectorID == "" || mapping == nil {
+		return errors.New("connector and mapping cannot be empty for cache add")
+	}
+	key := formatConnectorRoleMappingKey(connectorID)
+	data, err := json.Marshal(mapping)
+	if err != nil {
+		metricErrors.Inc()
+		return fmt.Errorf("connector role mapping cache marshal failed for %q: %w", key, err)
+	}
+	if err := s.connectorCache.Set(ctx, key, string(data), DefaultConnectorRoleMappingCacheTTL); err != nil {
+		metricErrors.Inc()
+		s.logger.Error("Connector role mapping cache SET error", zap.String("key", key), zap.Error(err))
+		return fmt.Errorf("connector role mapping cache SET failed for %q: %w", key, err)
+	}
+	return nil
+}
+
+// RemoveConnectorRoleMapping deletes the cached role mapping of a connector after it changed.
+func (s *AuthCacheService) RemoveConnectorRoleMapping(ctx context.Context, connectorID string) error {
+	key := formatConnectorRoleMappingKey(connectorID)
+	if err := s.connectorCache.Del(ctx, key); err != nil {
+		metricErrors.Inc()
+		s.logger.Error("Connector role mapping cache DEL error (unexpected)", zap.String("key", key), zap.Error(err))
+	}
+	return nil // Best-effort, like RemoveUserFromCache
+}
+
 // Close shuts down the cache service and underlying client.
 func (s *AuthCacheService) Close() error {
 	s.logger.Info("Shutting down AuthCacheService...")
@@ -416,6 +863,18 @@ func (s *AuthCacheService) Close() error {
 		s.logger.Error("ID->Email cache client close error", zap.Error(err))
 		errs = append(errs, fmt.Sprintf("id map cache: %v", err))
 	}
+	if err := s.apiKeyCache.Close(); err != nil {
+		s.logger.Error("API key cache client close error", zap.Error(err))
+		errs = append(errs, fmt.Sprintf("api key cache: %v", err))
+	}
+	if err := s.scopeCache.Close(); err != nil {
+		s.logger.Error("Integration scope cache client close error", zap.Error(err))
+		errs = append(errs, fmt.Sprintf("integration scope cache: %v", err))
+	}
+	if err := s.connectorCache.Close(); err != nil {
+		s.logger.Error("Connector role mapping cache client close error", zap.Error(err))
+		errs = append(errs, fmt.Sprintf("connector role mapping cache: %v", err))
+	}
 
 	if len(errs) > 0 {
 		return fmt.Errorf("cache client close failed: %s", strings.Join(errs, "; "))
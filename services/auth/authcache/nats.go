@@ -0,0 +1,108 @@
+package authcache
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// --- NATS KV adapter ---
+
+// natsKVEntry wraps a cached value with its expiry, the bucket TTL only bounds the longest one.
+type natsKVEntry struct {
+	ExpiresAt int64  `json:"e"` // Unix nanoseconds
+	Value     string `json:"v"`
+}
+
+// natsKVClient stores the entries of one cache in a NATS JetStream key-value bucket
+// shared by all replicas, so a Del on any replica invalidates the entry everywhere.
+type natsKVClient struct {
+	kv         jetstream.KeyValue
+	defaultTTL time.Duration
+}
+
+// NewNATSKVClientFactory returns a ClientFactory creating one in-memory KV bucket per cache,
+// named "<bucketPrefix>-<cache name>".
+func NewNATSKVClientFactory(nc *nats.Conn, bucketPrefix string) (ClientFactory, error) {
+	if nc == nil {
+		return nil, errors.New("nats connection cannot be nil")
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jetstream context: %w", err)
+	}
+	return func(name string, defaultTTL time.Duration) (CacheClient, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		kv, err := js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{
+			Bucket:      bucketPrefix + "-" + name,
+			Description: "opensecurity auth service " + name + " cache",
+			History:     1,
+			TTL:         2 * defaultTTL, // Upper bound, entries also expire on read
+			Storage:     jetstream.MemoryStorage,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create kv bucket %s-%s: %w", bucketPrefix, name, err)
+		}
+		return &natsKVClient{kv: kv, defaultTTL: defaultTTL}, nil
+	}, nil
+}
+
+// natsKVKey encodes a cache key, KV keys are limited to a restricted character set.
+func natsKVKey(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// Get retrieves a string value from the bucket.
+// Returns ErrUserInfoNotFound if the key doesn't exist or its entry expired.
+func (c *natsKVClient) Get(ctx context.Context, key string) (string, error) {
+	kvEntry, err := c.kv.Get(ctx, natsKVKey(key))
+	if err != nil {
+		if errors.Is(err, jetstream.ErrKeyNotFound) {
+			return "", ErrUserInfoNotFound
+		}
+		return "", err
+	}
+	var entry natsKVEntry
+	if err := json.Unmarshal(kvEntry.Value(), &entry); err != nil {
+		_ = c.kv.Delete(ctx, natsKVKey(key))
+		return "", fmt.Errorf("invalid entry found in cache for key %s: %w", key, err)
+	}
+	if time.Now().UnixNano() >= entry.ExpiresAt {
+		return "", ErrUserInfoNotFound
+	}
+	return entry.Value, nil
+}
+
+// Set stores a string value in the bucket with a specific TTL.
+func (c *natsKVClient) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = c.defaultTTL
+	}
+	data, err := json.Marshal(natsKVEntry{ExpiresAt: time.Now().Add(ttl).UnixNano(), Value: value})
+	if err != nil {
+		return err
+	}
+	_, err = c.kv.Put(ctx, natsKVKey(key), data)
+	return err
+}
+
+// Del removes a key from the bucket for all replicas.
+func (c *natsKVClient) Del(ctx context.Context, key string) error {
+	err := c.kv.Delete(ctx, natsKVKey(key))
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Close is a no-op, the NATS connection is owned and drained by the caller.
+func (c *natsKVClient) Close() error {
+	return nil
+}
@@ -0,0 +1,102 @@
+package authcache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runNATSServer starts an embedded JetStream server for the test
+func runNATSServer(t *testing.T) string {
+	s, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	require.NoError(t, err)
+	go s.Start()
+	t.Cleanup(s.Shutdown)
+	require.True(t, s.ReadyForConnections(10*time.Second), "nats server is not ready")
+	return s.ClientURL()
+}
+
+// natsReplica returns the client of a cache as created by one replica of the service
+func natsReplica(t *testing.T, url string, defaultTTL time.Duration) CacheClient {
+	nc, err := nats.Connect(url)
+	require.NoError(t, err)
+	t.Cleanup(nc.Close)
+	factory, err := NewNATSKVClientFactory(nc, "auth-test")
+	require.NoError(t, err)
+	client, err := factory("userinfo", defaultTTL)
+	require.NoError(t, err)
+	return client
+}
+
+func TestNATSKVClient(t *testing.T) {
+	ctx := context.Background()
+	client := natsReplica(t, runNATSServer(t), time.Hour)
+
+	// keys are not limited to the KV key character set
+	key := "user:jane.doe@example.com/external id"
+	_, err := client.Get(ctx, key)
+	assert.ErrorIs(t, err, ErrUserInfoNotFound)
+
+	require.NoError(t, client.Set(ctx, key, `{"role":"admin"}`, 0))
+	value, err := client.Get(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, `{"role":"admin"}`, value)
+
+	require.NoError(t, client.Set(ctx, key, `{"role":"viewer"}`, time.Minute))
+	value, err = client.Get(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, `{"role":"viewer"}`, value)
+
+	require.NoError(t, client.Del(ctx, key))
+	_, err = client.Get(ctx, key)
+	assert.ErrorIs(t, err, ErrUserInfoNotFound)
+	assert.NoError(t, client.Del(ctx, key), "deleting a missing key is not an error")
+}
+
+func TestNATSKVClientEntryExpiry(t *testing.T) {
+	ctx := context.Background()
+	client := natsReplica(t, runNATSServer(t), time.Hour)
+
+	// the entry expires long before the bucket TTL removes it
+	require.NoError(t, client.Set(ctx, "short", "value", 100*time.Millisecond))
+	require.NoError(t, client.Set(ctx, "long", "value", 0))
+	value, err := client.Get(ctx, "short")
+	require.NoError(t, err)
+	assert.Equal(t, "value", value)
+
+	time.Sleep(200 * time.Millisecond)
+	_, err = client.Get(ctx, "short")
+	assert.ErrorIs(t, err, ErrUserInfoNotFound)
+	value, err = client.Get(ctx, "long")
+	require.NoError(t, err)
+	assert.Equal(t, "value", value)
+}
+
+func TestNATSKVClientSharedAcrossReplicas(t *testing.T) {
+	ctx := context.Background()
+	url := runNATSServer(t)
+	first := natsReplica(t, url, time.Hour)
+	second := natsReplica(t, url, time.Hour)
+
+	require.NoError(t, first.Set(ctx, "user", "cached", 0))
+	value, err := second.Get(ctx, "user")
+	require.NoError(t, err)
+	assert.Equal(t, "cached", value)
+
+	// a Del on one replica invalidates the entry read by the other one
+	require.NoError(t, second.Del(ctx, "user"))
+	_, err = first.Get(ctx, "user")
+	assert.ErrorIs(t, err, ErrUserInfoNotFound)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
@@ -11,12 +12,14 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	dexApi "github.com/dexidp/dex/api/v2"
+	"github.com/nats-io/nats.go"
 	"github.com/spf13/cobra"
 	"gorm.io/gorm"
 
@@ -25,6 +28,8 @@ import (
 	"github.com/opengovern/og-util/pkg/postgres"
 	"github.com/opengovern/opensecurity/services/auth/authcache"
 	"github.com/opengovern/opensecurity/services/auth/db"
+	"github.com/opengovern/opensecurity/services/auth/ratelimit"
+	integrationClient "github.com/opengovern/opensecurity/services/integration/client"
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
@@ -39,6 +44,13 @@ const (
 	authCacheDefaultTTL    = 10 * time.Minute     // <-- Default TTL for user auth cache
 )
 
+// API key defaults, overridable through environment variables
+const (
+	defaultAPIKeyMaxPerUser = 5   // Active API keys a user can own when API_KEY_MAX_PER_USER is not set
+	defaultAPIKeyTTLDays    = 90  // Lifetime of a new API key when the request does not specify one
+	defaultAPIKeyMaxTTLDays = 365 // Longest lifetime a new API key can be created with
+)
+
 // --- End Constants ---
 
 var (
@@ -56,6 +68,23 @@ var (
 	platformKeyEnabledStr        = os.Getenv("PLATFORM_KEY_ENABLED")
 	platformPublicKeyStr         = os.Getenv("PLATFORM_PUBLIC_KEY")
 	platformPrivateKeyStr        = os.Getenv("PLATFORM_PRIVATE_KEY")
+	apiKeyMaxPerUserStr          = os.Getenv("API_KEY_MAX_PER_USER")
+	apiKeyDefaultTTLDaysStr      = os.Getenv("API_KEY_DEFAULT_TTL_DAYS")
+	apiKeyMaxTTLDaysStr          = os.Getenv("API_KEY_MAX_TTL_DAYS")
+	integrationBaseURL           = os.Getenv("INTEGRATION_BASE_URL")
+	auditSyslogNetwork           = os.Getenv("AUDIT_SYSLOG_NETWORK")
+	auditSyslogAddress           = os.Getenv("AUDIT_SYSLOG_ADDRESS")
+	auditHMACKey                 = os.Getenv("AUDIT_HMAC_KEY")
+	auditSpillPath               = os.Getenv("AUDIT_SPILL_PATH")
+	scimBearerToken              = os.Getenv("SCIM_BEARER_TOKEN")
+	scimConnectorID              = os.Getenv("SCIM_CONNECTOR_ID")
+	scimGroupRoleMapping         = os.Getenv("SCIM_GROUP_ROLE_MAPPING")
+	authCacheBackend             = os.Getenv("AUTH_CACHE_BACKEND")
+	natsURL                      = os.Getenv("NATS_URL")
+	authCacheNATSBucketPrefix    = os.Getenv("AUTH_CACHE_NATS_BUCKET_PREFIX")
+	rateLimitConfigJSON          = os.Getenv("RATE_LIMIT_CONFIG")
+	rateLimitStore               = os.Getenv("RATE_LIMIT_STORE")
+	trustedProxyCIDRs            = os.Getenv("TRUSTED_PROXY_CIDRS")
 )
 
 type ServerConfig struct {
@@ -301,7 +330,35 @@ func start(ctx context.Context, logger *zap.Logger) error {
 
 	// --- Initialize Auth Cache ---
 	logger.Info("Initializing Auth Cache service...")
-	authCacheSvc, err := authcache.NewAuthCacheService(logger) // Uses DefaultTTL from authcache pkg
+	var authCacheSvc *authcache.AuthCacheService
+	var nc *nats.Conn // Set with the nats backend, also used by the rate limit store
+	switch authCacheBackend {
+	case "", "memory":
+		authCacheSvc, err = authcache.NewAuthCacheService(logger) // Uses DefaultTTL from authcache pkg
+	case "nats":
+		// Shared between replicas, invalidations made by one replica apply to all of them
+		if natsURL == "" {
+			return errors.New("NATS_URL is required for the nats auth cache backend")
+		}
+		var natsErr error
+		nc, natsErr = nats.Connect(natsURL, nats.Name("auth-service-cache"))
+		if natsErr != nil {
+			logger.Error("Failed to connect to NATS for the auth cache", zap.String("url", natsURL), zap.Error(natsErr))
+			return fmt.Errorf("failed to connect to NATS: %w", natsErr)
+		}
+		defer nc.Drain()
+		bucketPrefix := authCacheNATSBucketPrefix
+		if bucketPrefix == "" {
+			bucketPrefix = "auth-cache"
+		}
+		factory, factoryErr := authcache.NewNATSKVClientFactory(nc, bucketPrefix)
+		if factoryErr != nil {
+			return fmt.Errorf("failed to initialize auth cache: %w", factoryErr)
+		}
+		authCacheSvc, err = authcache.NewAuthCacheServiceWithFactory(logger, "nats-kv", factory)
+	default:
+		return fmt.Errorf("invalid AUTH_CACHE_BACKEND %q, expected memory or nats", authCacheBackend)
+	}
 	if err != nil {
 		logger.Error("Failed to initialize Auth Cache service", zap.Error(err))
 		return fmt.Errorf("failed to initialize auth cache: %w", err)
@@ -317,6 +374,108 @@ func start(ctx context.Context, logger *zap.Logger) error {
 	logger.Info("Auth Cache service initialized successfully.")
 	// --- End Initialize Auth Cache ---
 
+	// --- Rate Limiting ---
+	// Limits are only applied with a configuration. Buckets are shared by the replicas through NATS, a memory store
+	// has to be asked for explicitly since each replica would then let through its own share of the limit.
+	var rateLimiter *ratelimit.Limiter
+	if rateLimitConfigJSON == "" {
+		logger.Info("RATE_LIMIT_CONFIG is not set, rate limiting is disabled")
+	} else {
+		rateLimitConfig, err := ratelimit.ParseConfig(rateLimitConfigJSON)
+		if err != nil {
+			logger.Error("Invalid RATE_LIMIT_CONFIG", zap.Error(err))
+			return err
+		}
+		storeName := rateLimitStore
+		if storeName == "" {
+			storeName = "nats"
+		}
+		var store ratelimit.Store
+		switch storeName {
+		case "memory":
+			logger.Warn("Rate limit buckets are kept in memory, each replica applies the limits on its own")
+			store = ratelimit.NewMemoryStore()
+		case "nats":
+			rateLimitNC := nc
+			if rateLimitNC == nil {
+				if natsURL == "" {
+					return errors.New("NATS_URL is required for the shared rate limit store, set RATE_LIMIT_STORE=memory for a single replica")
+				}
+				rateLimitNC, err = nats.Connect(natsURL, nats.Name("auth-service-ratelimit"))
+				if err != nil {
+					logger.Error("Failed to connect to NATS for rate limiting", zap.String("url", natsURL), zap.Error(err))
+					return fmt.Errorf("failed to connect to NATS: %w", err)
+				}
+				defer rateLimitNC.Drain()
+			}
+			bucketPrefix := authCacheNATSBucketPrefix
+			if bucketPrefix == "" {
+				bucketPrefix = "auth-cache"
+			}
+			if store, err = ratelimit.NewNATSKVStore(rateLimitNC, bucketPrefix); err != nil {
+				return fmt.Errorf("failed to initialize rate limit store: %w", err)
+			}
+		default:
+			return fmt.Errorf("invalid RATE_LIMIT_STORE %q, expected nats or memory", rateLimitStore)
+		}
+		if rateLimiter, err = ratelimit.NewLimiter(rateLimitConfig, store); err != nil {
+			return fmt.Errorf("failed to initialize rate limiter: %w", err)
+		}
+		logger.Info("Rate limiting enabled", zap.Int("limits", len(rateLimitConfig.Limits)), zap.String("store", storeName))
+	}
+	// --- End Rate Limiting ---
+
+	// --- SCIM Provisioning ---
+	// SCIM endpoints are only served with a dedicated token and the connector provisioned users sign in with
+	var scimTokenHash []byte
+	scimGroupRoles, err := parseScimGroupRoles(scimGroupRoleMapping)
+	if err != nil {
+		logger.Error("Invalid SCIM_GROUP_ROLE_MAPPING", zap.Error(err))
+		return err
+	}
+	switch {
+	case scimBearerToken == "":
+		logger.Info("SCIM_BEARER_TOKEN is not set, SCIM provisioning is disabled")
+	case scimConnectorID == "" || scimConnectorID == "local":
+		logger.Error("SCIM_CONNECTOR_ID should be set to the connector of the identity provider, SCIM provisioning is disabled")
+	default:
+		hash := sha256.Sum256([]byte(scimBearerToken))
+		scimTokenHash = hash[:]
+		logger.Info("SCIM provisioning enabled", zap.String("connectorId", scimConnectorID), zap.Int("mappedGroups", len(scimGroupRoles)))
+	}
+
+	// --- API Key Limits ---
+	// Client addresses of API key usage and audit events come from the peer address, or from x-forwarded-for
+	// when the peer is one of these proxies
+	trustedProxies, err := parseTrustedProxies(trustedProxyCIDRs)
+	if err != nil {
+		logger.Error("Invalid TRUSTED_PROXY_CIDRS", zap.Error(err))
+		return err
+	}
+
+	apiKeyMaxPerUser := positiveIntFromEnv(logger, "API_KEY_MAX_PER_USER", apiKeyMaxPerUserStr, defaultAPIKeyMaxPerUser)
+	apiKeyDefaultTTLDays := positiveIntFromEnv(logger, "API_KEY_DEFAULT_TTL_DAYS", apiKeyDefaultTTLDaysStr, defaultAPIKeyTTLDays)
+	apiKeyMaxTTLDays := positiveIntFromEnv(logger, "API_KEY_MAX_TTL_DAYS", apiKeyMaxTTLDaysStr, defaultAPIKeyMaxTTLDays)
+	if apiKeyDefaultTTLDays > apiKeyMaxTTLDays {
+		logger.Warn("API_KEY_DEFAULT_TTL_DAYS is larger than API_KEY_MAX_TTL_DAYS, using the maximum",
+			zap.Int("default", apiKeyDefaultTTLDays), zap.Int("max", apiKeyMaxTTLDays))
+		apiKeyDefaultTTLDays = apiKeyMaxTTLDays
+	}
+	logger.Info("API key limits configured",
+		zap.Int("maxPerUser", apiKeyMaxPerUser),
+		zap.Int("defaultTTLDays", apiKeyDefaultTTLDays),
+		zap.Int("maxTTLDays", apiKeyMaxTTLDays))
+
+	// Keys created before expiry was mandatory get the default lifetime from now on
+	legacyExpiry := time.Now().AddDate(0, 0, apiKeyDefaultTTLDays)
+	if n, err := adb.SetMissingApiKeyExpiry(legacyExpiry); err != nil {
+		logger.Error("Failed to set expiry of API keys without one", zap.Error(err))
+		return fmt.Errorf("failed to set expiry of legacy api keys: %w", err)
+	} else if n > 0 {
+		logger.Warn("API keys without expiry were given one", zap.Int64("count", n), zap.Time("expiresAt", legacyExpiry))
+	}
+	// --- End API Key Limits ---
+
 	// --- Platform Key Handling ---
 	logger.Info("Setting up platform keys...")
 	var platformKeyEnabled bool
@@ -534,6 +693,14 @@ func start(ctx context.Context, logger *zap.Logger) error {
 		logger.Error("Database is not ready, cannot initialize server")
 		return errors.New("cannot initialize server, database not ready")
 	}
+	// Integration client resolves role bindings on integration groups and labels
+	var itClient integrationClient.IntegrationServiceClient
+	if integrationBaseURL != "" {
+		itClient = integrationClient.NewIntegrationServiceClient(integrationBaseURL)
+	} else {
+		logger.Warn("INTEGRATION_BASE_URL is not set, role bindings on integration groups or labels will deny access")
+	}
+
 	authServer := &Server{
 		host:              platformHost,
 		platformPublicKey: platformPublicKey,
@@ -541,12 +708,38 @@ func start(ctx context.Context, logger *zap.Logger) error {
 		dexClient:         dexClient,
 		logger:            logger.Named("authServer"),
 		db:                adb,
-		authCache:         authCacheSvc,            // Inject AuthCacheService
+		authCache:         authCacheSvc, // Inject AuthCacheService
+		integrationClient: itClient,
 		updateLogin:       make(chan User, 100000), // TODO: Remove if loop is removed
+		auditEvents:       make(chan db.AuditEvent, auditQueueSize),
+		rateLimiter:       rateLimiter,
+		trustedProxies:    trustedProxies,
+	}
+	// The key keeps someone with write access to the database from rewriting the chain, without it
+	// the chain only detects edits that do not recompute the hashes
+	if auditHMACKey != "" {
+		authServer.auditKey = []byte(auditHMACKey)
+	} else {
+		logger.Warn("AUDIT_HMAC_KEY is not set, audit events are hashed without a key")
+	}
+	if auditSpillPath == "" {
+		auditSpillPath = filepath.Join(os.TempDir(), "auth-audit-spill.ndjson")
+	}
+	authServer.auditSpill = newAuditSpill(auditSpillPath)
+	// Audit events are always stored in the database, forwarding to syslog is optional
+	if auditSyslogAddress != "" {
+		network := auditSyslogNetwork
+		if network == "" {
+			network = "udp"
+		}
+		authServer.auditSyslog = newAuditSyslogForwarder(network, auditSyslogAddress)
+		logger.Info("Forwarding audit events to syslog", zap.String("network", network), zap.String("address", auditSyslogAddress))
 	}
 
 	// TODO: Remove this goroutine call if UpdateLastLoginLoop is removed
 	go authServer.UpdateLastLoginLoop()
+	go authServer.AuditLoop()
+	go authServer.APIKeyUsageLoop()
 	logger.Info("Application server initialized.")
 	// --- End Server Initialization ---
 
@@ -560,6 +753,12 @@ func start(ctx context.Context, logger *zap.Logger) error {
 			db:                 adb,
 			authCache:          authCacheSvc, // Inject AuthCacheService
 			authServer:         authServer,
+			apiKeyMaxPerUser:   int64(apiKeyMaxPerUser),
+			apiKeyDefaultTTL:   time.Duration(apiKeyDefaultTTLDays) * 24 * time.Hour,
+			apiKeyMaxTTL:       time.Duration(apiKeyMaxTTLDays) * 24 * time.Hour,
+			scimTokenHash:      scimTokenHash,
+			scimConnectorID:    scimConnectorID,
+			scimGroupRoles:     scimGroupRoles,
 		}
 		httpErr := httpserver.RegisterAndStart(ctx, logger.Named("httpServer"), httpServerAddress, &routes)
 		if httpErr != nil && !errors.Is(httpErr, http.ErrServerClosed) {
@@ -583,6 +782,20 @@ func start(ctx context.Context, logger *zap.Logger) error {
 	return nil
 }
 
+// positiveIntFromEnv parses a positive integer env var, falling back to def when unset or invalid.
+func positiveIntFromEnv(logger *zap.Logger, name, value string, def int) int {
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		logger.Warn("Invalid value for environment variable, using default",
+			zap.String("envVar", name), zap.String("value", value), zap.Int("default", def))
+		return def
+	}
+	return n
+}
+
 /*
 func ensureDexClients(ctx context.Context, logger *zap.Logger, dexClient dexApi.DexClient) error {
 	publicUris := strings.Split(dexPublicClientRedirectUris, ",")
@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	api2 "github.com/opengovern/og-util/pkg/api"
+	"github.com/opengovern/opensecurity/services/auth/api"
+	"github.com/opengovern/opensecurity/services/auth/authcache"
+	"github.com/opengovern/opensecurity/services/auth/db"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// --- Role evaluation ---
+
+// connectorRoleMapping loads the role mapping of a connector from the auth cache, falling back to the database.
+// Unknown connectors get an empty mapping, their users keep the role stored for them.
+func (s *Server) connectorRoleMapping(ctx context.Context, connectorID string) (*authcache.CachedConnectorRoleMapping, error) {
+	mapping, err := s.authCache.GetConnectorRoleMapping(ctx, connectorID)
+	if err == nil {
+		return mapping, nil
+	}
+	if !errors.Is(err, authcache.ErrConnectorRoleMappingNotFound) {
+		s.logger.Error("Connector role mapping cache error, falling back to DB", zap.Error(err))
+	}
+
+	mapping = &authcache.CachedConnectorRoleMapping{}
+	connector, err := s.db.GetConnectorByConnectorID(connectorID)
+	if err != nil {
+		return nil, err
+	}
+	if connector != nil {
+		rules, err := s.db.ListConnectorRoleRules(connectorID)
+		if err != nil {
+			return nil, err
+		}
+		mapping.JITProvisioning = connector.JITProvisioning
+		mapping.DefaultRole = string(connector.DefaultRole)
+		for _, rule := range rules {
+			mapping.Rules = append(mapping.Rules, authcache.CachedRoleRule{
+				Claim: rule.Claim,
+				Value: rule.Value,
+				Role:  string(rule.Role),
+			})
+		}
+	}
+
+	if err := s.authCache.SetConnectorRoleMapping(ctx, connectorID, mapping); err != nil {
+		s.logger.Error("Failed to populate connector role mapping cache", zap.String("connectorId", connectorID), zap.Error(err))
+	}
+	return mapping, nil
+}
+
+// roleMappingManaged reports whether roles of the connector users come from their claims.
+func roleMappingManaged(mapping *authcache.CachedConnectorRoleMapping) bool {
+	return len(mapping.Rules) > 0 || mapping.DefaultRole != ""
+}
+
+// emailRule reports whether a rule matches the email address of the user.
+func emailRule(rule authcache.CachedRoleRule) bool {
+	return rule.Claim == db.RoleRuleClaimEmail || rule.Claim == db.RoleRuleClaimEmailDomain
+}
+
+// hasEmailRules reports whether a role mapping has rules on the email address of the user.
+func hasEmailRules(mapping *authcache.CachedConnectorRoleMapping) bool {
+	for _, rule := range mapping.Rules {
+		if emailRule(rule) {
+			return true
+		}
+	}
+	return false
+}
+
+// claimValues returns the values of a claim a rule can match.
+// Email addresses the identity provider did not verify match no rule.
+func claimValues(claim *userClaim, name string) []string {
+	switch name {
+	case db.RoleRuleClaimGroups:
+		return claim.groups
+	case db.RoleRuleClaimEmail:
+		if claim.EmailVerified {
+			return []string{claim.Email}
+		}
+	case db.RoleRuleClaimEmailDomain:
+		if _, domain, ok := strings.Cut(claim.Email, "@"); ok && claim.EmailVerified {
+			return []string{domain}
+		}
+	case db.RoleRuleClaimPreferredUsername:
+		return []string{claim.preferredUsername}
+	}
+	return nil
+}
+
+// evaluateRoleMapping returns the highest role of the rules matching the claims,
+// the default role of the connector when none does.
+func evaluateRoleMapping(mapping *authcache.CachedConnectorRoleMapping, claim *userClaim) api2.Role {
+	var role api2.Role
+	for _, rule := range mapping.Rules {
+		ruleRole := api2.Role(rule.Role)
+		if roleRank[ruleRole] <= roleRank[role] {
+			continue
+		}
+		pattern := strings.ToLower(rule.Value)
+		for _, value := range claimValues(claim, rule.Claim) {
+			if matched, _ := path.Match(pattern, strings.ToLower(value)); matched {
+				role = ruleRole
+				break
+			}
+		}
+	}
+	if role == "" {
+		role = api2.Role(mapping.DefaultRole)
+	}
+	return role
+}
+
+// syncConnectorRole applies the role mapping of the token's connector: the stored role of the user follows
+// its claims, and unknown users are created on their first login when the connector allows it.
+// It returns a reason when the user must be denied access.
+func (s *Server) syncConnectorRole(ctx context.Context, claim *userClaim, logFields []zap.Field) (string, error) {
+	if claim.connectorID == "" {
+		return "", nil
+	}
+	mapping, err := s.connectorRoleMapping(ctx, claim.connectorID)
+	if err != nil {
+		return "", err
+	}
+	if !roleMappingManaged(mapping) {
+		return "", nil
+	}
+	// Anyone can claim an unverified address, the email rules can not tell the role of the user then
+	// and it keeps the one stored for it
+	if !claim.EmailVerified && hasEmailRules(mapping) {
+		s.logger.Debug("Email of the user is not verified, keeping its role", logFields...)
+		return "", nil
+	}
+	role := evaluateRoleMapping(mapping, claim)
+
+	// Fast path, the cached user already has the role its claims grant
+	if cached, err := s.authCache.GetUser(ctx, claim.Email); err == nil && cached.Role == string(role) {
+		return "", nil
+	}
+
+	user, err := s.db.GetUserByEmail(claim.Email)
+	if err != nil {
+		return "", err
+	}
+	if user == nil {
+		// Accounts are only created for addresses the identity provider verified
+		if !mapping.JITProvisioning || role == "" || !claim.EmailVerified {
+			return "", nil // Denied as an unknown user
+		}
+		return "", s.provisionConnectorUser(claim, role, logFields)
+	}
+	// Users of other connectors and the primary admin keep the role stored for them
+	if user.ConnectorId != claim.connectorID || user.ID == 1 {
+		return "", nil
+	}
+	if role == "" {
+		return "no role mapping rule of the connector matches the user claims", nil
+	}
+	if user.Role == role {
+		return "", nil
+	}
+
+	if err := s.db.Orm.Model(&db.User{}).Where("id = ?", user.ID).Update("role", role).Error; err != nil {
+		return "", err
+	}
+	s.logger.Info("Updated user role from identity provider claims",
+		append(logFields, zap.String("from", string(user.Role)), zap.String("to", string(role)))...)
+	if err := s.authCache.RemoveUserFromCache(ctx, user.Email); err != nil {
+		s.logger.Error("Failed to invalidate user cache after role mapping", append(logFields, zap.Error(err))...)
+	}
+	return "", nil
+}
+
+// provisionConnectorUser creates a user on its first login through a connector with JIT provisioning.
+func (s *Server) provisionConnectorUser(claim *userClaim, role api2.Role, logFields []zap.Field) error {
+	fullName := claim.name
+	if fullName == "" {
+		fullName = claim.Email
+	}
+	user := db.User{
+		Email:                 claim.Email,
+		EmailVerified:         claim.EmailVerified,
+		FullName:              fullName,
+		Role:                  role,
+		ConnectorId:           claim.connectorID,
+		ExternalId:            fmt.Sprintf("%s|%s", claim.connectorID, claim.Email),
+		Username:              claim.Email,
+		RequirePasswordChange: false, // Signs in through its identity provider
+		IsActive:              true,
+	}
+	if err := s.db.CreateUser(&user); err != nil {
+		return err
+	}
+	s.logger.Info("Provisioned user on first login", append(logFields, zap.Uint("id", user.ID), zap.String("role", string(role)))...)
+	return nil
+}
+
+// --- HTTP handlers ---
+
+// GetConnectorRoleMapping returns the role rules and provisioning settings of a connector.
+func (r *httpRoutes) GetConnectorRoleMapping(ctx echo.Context) error {
+	connectorID := ctx.Param("id")
+	connector, err := r.db.GetConnectorByConnectorID(connectorID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve connector")
+	}
+	if connector == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Connector not found")
+	}
+	rules, err := r.db.ListConnectorRoleRules(connectorID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve role rules")
+	}
+
+	resp := api.ConnectorRoleMapping{
+		JITProvisioning: connector.JITProvisioning,
+		DefaultRole:     connector.DefaultRole,
+		Rules:           make([]api.ConnectorRoleRule, 0, len(rules)),
+	}
+	for _, rule := range rules {
+		resp.Rules = append(resp.Rules, api.ConnectorRoleRule{Claim: rule.Claim, Value: rule.Value, Role: rule.Role})
+	}
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+// UpdateConnectorRoleMapping replaces the role rules and provisioning settings of a connector.
+// Roles of its users are re-evaluated on their next request.
+func (r *httpRoutes) UpdateConnectorRoleMapping(ctx echo.Context) error {
+	connectorID := ctx.Param("id")
+	if connectorID == "local" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Local users have no identity provider claims to map")
+	}
+	var req api.ConnectorRoleMapping
+	if err := bindValidate(ctx, &req); err != nil {
+		return err
+	}
+	if req.JITProvisioning && len(req.Rules) == 0 && req.DefaultRole == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "JIT provisioning needs rules or a default role to assign a role")
+	}
+
+	rules := make([]db.ConnectorRoleRule, 0, len(req.Rules))
+	for _, rule := range req.Rules {
+		value := strings.TrimSpace(rule.Value)
+		if _, err := path.Match(value, ""); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid rule value pattern: "+rule.Value)
+		}
+		rules = append(rules, db.ConnectorRoleRule{Claim: rule.Claim, Value: value, Role: rule.Role})
+	}
+
+	if err := r.db.ReplaceConnectorRoleMapping(connectorID, req.JITProvisioning, req.DefaultRole, rules); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Connector not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save role mapping")
+	}
+	_ = r.authCache.RemoveConnectorRoleMapping(ctx.Request().Context(), connectorID)
+
+	return r.GetConnectorRoleMapping(ctx)
+}
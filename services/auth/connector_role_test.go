@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"testing"
+
+	api2 "github.com/opengovern/og-util/pkg/api"
+	"github.com/opengovern/opensecurity/services/auth/authcache"
+	"github.com/opengovern/opensecurity/services/auth/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluateRoleMapping(t *testing.T) {
+	mapping := &authcache.CachedConnectorRoleMapping{
+		DefaultRole: string(api2.ViewerRole),
+		Rules: []authcache.CachedRoleRule{
+			{Claim: db.RoleRuleClaimGroups, Value: "platform-editors", Role: string(api2.EditorRole)},
+			{Claim: db.RoleRuleClaimEmailDomain, Value: "example.com", Role: string(api2.AdminRole)},
+		},
+	}
+
+	claim := &userClaim{Email: "jane@example.com", EmailVerified: true, groups: []string{"platform-editors"}}
+	assert.Equal(t, api2.AdminRole, evaluateRoleMapping(mapping, claim), "the highest matching rule applies")
+
+	claim = &userClaim{Email: "jane@example.com", groups: []string{"platform-editors"}}
+	assert.Equal(t, api2.EditorRole, evaluateRoleMapping(mapping, claim), "unverified addresses match no email rule")
+
+	claim = &userClaim{Email: "jane@other.com", EmailVerified: true}
+	assert.Equal(t, api2.ViewerRole, evaluateRoleMapping(mapping, claim), "the default role applies when no rule matches")
+}
+
+func TestHasEmailRules(t *testing.T) {
+	assert.False(t, hasEmailRules(&authcache.CachedConnectorRoleMapping{
+		Rules: []authcache.CachedRoleRule{{Claim: db.RoleRuleClaimGroups, Value: "admins", Role: string(api2.AdminRole)}},
+	}))
+	assert.True(t, hasEmailRules(&authcache.CachedConnectorRoleMapping{
+		Rules: []authcache.CachedRoleRule{{Claim: db.RoleRuleClaimEmail, Value: "*@example.com", Role: string(api2.AdminRole)}},
+	}))
+}
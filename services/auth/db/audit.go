@@ -0,0 +1,170 @@
+package db
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	AuditEventTypeAPICall    = "api_call"
+	AuditEventTypeAuthDenied = "auth_denied"
+	AuditEventTypeSCIM       = "scim_provisioning"
+
+	// Decisions of the auth check, the response status of the service handling an allowed request is not known
+	AuditDecisionAllowed = "allowed"
+	AuditDecisionDenied  = "denied"
+)
+
+// auditChainLockID serializes appends to the audit chain across replicas.
+const auditChainLockID = 4302
+
+// auditGenesisHash is the previous hash of the first event of the chain.
+var auditGenesisHash = strings.Repeat("0", 64)
+
+// ComputeHash returns the hash of the event chained to the given previous hash. Keyed events are hashed with
+// HMAC-SHA256 under the audit key, which is kept out of the database so rows can not be rewritten along with
+// their hashes by someone holding only database access.
+func (e AuditEvent) ComputeHash(prevHash string, key []byte) string {
+	fields := []string{
+		prevHash,
+		e.Timestamp.UTC().Format(time.RFC3339Nano),
+		e.EventType,
+		e.ActorID,
+		e.ActorEmail,
+		e.ActorRole,
+		e.APIKeyID,
+		e.Method,
+		e.Path,
+		strings.Join(e.TargetIDs, ","),
+		e.Decision,
+		e.Reason,
+		e.RequestID,
+		e.SourceIP,
+	}
+	if !e.Keyed {
+		digest := sha256.Sum256([]byte(strings.Join(fields, "\x1f")))
+		return hex.EncodeToString(digest[:])
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.Join(fields, "\x1f")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// AuditEventFilter narrows down audit event queries, empty fields do not filter.
+type AuditEventFilter struct {
+	ActorID    string
+	EventType  string
+	Decision   string
+	Method     string
+	PathPrefix string
+	TargetID   string
+	RequestID  string
+	From       *time.Time
+	To         *time.Time
+}
+
+// --- Audit Methods ---
+
+// AppendAuditEvent links the event to the last event of the chain and stores it, keyed with the audit key if set.
+func (db Database) AppendAuditEvent(event *AuditEvent, key []byte) error {
+	// Postgres keeps microseconds, the hash must be computed over the stored value
+	event.Timestamp = event.Timestamp.UTC().Truncate(time.Microsecond)
+	event.Keyed = len(key) > 0
+
+	err := db.Orm.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLockID).Error; err != nil {
+			return err
+		}
+		var last AuditEvent
+		prevHash := auditGenesisHash
+		err := tx.Model(&AuditEvent{}).Order("id desc").Limit(1).Take(&last).Error
+		if err == nil {
+			prevHash = last.Hash
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		event.PrevHash = prevHash
+		event.Hash = event.ComputeHash(prevHash, key)
+		return tx.Create(event).Error
+	})
+	if err != nil {
+		db.Logger.Error("Failed to append audit event", zap.String("eventType", event.EventType), zap.String("path", event.Path), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (db Database) auditEventsQuery(filter AuditEventFilter) *gorm.DB {
+	tx := db.Orm.Model(&AuditEvent{})
+	if filter.ActorID != "" {
+		tx = tx.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.EventType != "" {
+		tx = tx.Where("event_type = ?", filter.EventType)
+	}
+	if filter.Decision != "" {
+		tx = tx.Where("decision = ?", filter.Decision)
+	}
+	if filter.Method != "" {
+		tx = tx.Where("method = ?", strings.ToUpper(filter.Method))
+	}
+	if filter.PathPrefix != "" {
+		tx = tx.Where("path LIKE ?", strings.NewReplacer("%", "\\%", "_", "\\_").Replace(filter.PathPrefix)+"%")
+	}
+	if filter.TargetID != "" {
+		tx = tx.Where("? = ANY(target_ids)", filter.TargetID)
+	}
+	if filter.RequestID != "" {
+		tx = tx.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.From != nil {
+		tx = tx.Where("timestamp >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		tx = tx.Where("timestamp <= ?", *filter.To)
+	}
+	return tx
+}
+
+// ListAuditEvents returns the newest matching events with an ID lower than beforeID, if set.
+func (db Database) ListAuditEvents(filter AuditEventFilter, beforeID uint64, limit int) ([]AuditEvent, error) {
+	var events []AuditEvent
+	tx := db.auditEventsQuery(filter)
+	if beforeID > 0 {
+		tx = tx.Where("id < ?", beforeID)
+	}
+	tx = tx.Order("id desc").Limit(limit).Find(&events)
+	if tx.Error != nil {
+		db.Logger.Error("Failed to list audit events", zap.Error(tx.Error))
+		return nil, tx.Error
+	}
+	return events, nil
+}
+
+// ListAuditEventsAfter returns matching events in chain order starting after the given ID.
+func (db Database) ListAuditEventsAfter(filter AuditEventFilter, afterID uint64, limit int) ([]AuditEvent, error) {
+	var events []AuditEvent
+	tx := db.auditEventsQuery(filter).
+		Where("id > ?", afterID).
+		Order("id asc").
+		Limit(limit).
+		Find(&events)
+	if tx.Error != nil {
+		db.Logger.Error("Failed to list audit events", zap.Uint64("afterId", afterID), zap.Error(tx.Error))
+		return nil, tx.Error
+	}
+	return events, nil
+}
+
+// AuditGenesisHash is the previous hash expected on the first event of the chain.
+func AuditGenesisHash() string {
+	return auditGenesisHash
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/opengovern/og-util/pkg/api"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Claims connector role rules can match on
+const (
+	RoleRuleClaimGroups            = "groups"
+	RoleRuleClaimEmail             = "email"
+	RoleRuleClaimEmailDomain       = "email_domain"
+	RoleRuleClaimPreferredUsername = "preferred_username"
+)
+
+// --- Connector Role Rule Methods ---
+
+// ListConnectorRoleRules returns the role rules of a connector.
+func (db Database) ListConnectorRoleRules(connectorID string) ([]ConnectorRoleRule, error) {
+	var rules []ConnectorRoleRule
+	tx := db.Orm.Model(&ConnectorRoleRule{}).
+		Where("connector_id = ?", connectorID).
+		Order("id").
+		Find(&rules)
+	if tx.Error != nil {
+		db.Logger.Error("Failed to list connector role rules", zap.String("connectorId", connectorID), zap.Error(tx.Error))
+		return nil, tx.Error
+	}
+	return rules, nil
+}
+
+// ReplaceConnectorRoleMapping sets the provisioning settings of a connector and replaces its role rules.
+func (db Database) ReplaceConnectorRoleMapping(connectorID string, jitProvisioning bool, defaultRole api.Role, rules []ConnectorRoleRule) error {
+	err := db.Orm.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Connector{}).
+			Where("connector_id = ?", connectorID).
+			Updates(map[string]interface{}{
+				"jit_provisioning": jitProvisioning,
+				"default_role":     defaultRole,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Unscoped().Where("connector_id = ?", connectorID).Delete(&ConnectorRoleRule{}).Error; err != nil {
+			return err
+		}
+		for i := range rules {
+			rules[i].ConnectorID = connectorID
+		}
+		if len(rules) > 0 {
+			return tx.Create(&rules).Error
+		}
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			db.Logger.Error("Failed to replace connector role mapping", zap.String("connectorId", connectorID), zap.Error(err))
+		}
+		return err
+	}
+	db.Logger.Info("Replaced connector role mapping", zap.String("connectorId", connectorID), zap.Int("rules", len(rules)))
+	return nil
+}
+
+// DeleteConnectorRoleRules removes the role rules of a deleted connector.
+func (db Database) DeleteConnectorRoleRules(connectorID string) error {
+	if err := db.Orm.Where("connector_id = ?", connectorID).Delete(&ConnectorRoleRule{}).Error; err != nil {
+		db.Logger.Error("Failed to delete connector role rules", zap.String("connectorId", connectorID), zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -47,10 +47,12 @@ func (db Database) AddConfiguration(c *Configuration) error {
 
 // --- API Key Methods ---
 
+// ListApiKeys lists the keys of users, keys of service accounts are listed with their account.
 func (db Database) ListApiKeys() ([]ApiKey, error) {
 	var s []ApiKey
 	// Order and find all keys
 	tx := db.Orm.Model(&ApiKey{}).
+		Where("service_account_id IS NULL").
 		Order("created_at desc").
 		Find(&s)
 	if tx.Error != nil {
@@ -135,9 +137,11 @@ func (db Database) DeleteAPIKey(id uint64) error {
 
 func (db Database) CountApiKeysForUser(userID string) (int64, error) {
 	var count int64
-	// Combine WHERE clauses, use boolean true for is_active
+	// Only keys that can still be used count towards the limit
 	tx := db.Orm.Model(&ApiKey{}).
 		Where("creator_user_id = ? AND is_active = ?", userID, true).
+		Where("revoked_at IS NULL").
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
 		Count(&count)
 	if tx.Error != nil {
 		db.Logger.Error("Failed to count active API keys for user", zap.String("userId", userID), zap.Error(tx.Error))
@@ -146,6 +150,96 @@ func (db Database) CountApiKeysForUser(userID string) (int64, error) {
 	return count, nil
 }
 
+func (db Database) GetApiKey(id uint64) (*ApiKey, error) {
+	var key ApiKey
+	tx := db.Orm.Model(&ApiKey{}).
+		Where("id = ?", id).
+		First(&key)
+	if tx.Error != nil {
+		if !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			db.Logger.Error("Failed to get API key", zap.Uint64("id", id), zap.Error(tx.Error))
+		}
+		return nil, tx.Error
+	}
+	return &key, nil
+}
+
+// GetApiKeyByHash finds the key record of a token by the sha512 hash of the token.
+func (db Database) GetApiKeyByHash(keyHash string) (*ApiKey, error) {
+	var key ApiKey
+	tx := db.Orm.Model(&ApiKey{}).
+		Where("key_hash = ?", keyHash).
+		First(&key)
+	if tx.Error != nil {
+		if !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			db.Logger.Error("Failed to get API key by hash", zap.Error(tx.Error))
+		}
+		return nil, tx.Error
+	}
+	return &key, nil
+}
+
+// RevokeAPIKey permanently revokes a key, revoked keys cannot be re-activated.
+func (db Database) RevokeAPIKey(id uint64, revokedBy string) error {
+	now := time.Now()
+	tx := db.Orm.Model(&ApiKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"is_active":  false,
+			"revoked_at": now,
+			"revoked_by": revokedBy,
+		})
+	if tx.Error != nil {
+		db.Logger.Error("Failed to revoke API key", zap.Uint64("id", id), zap.Error(tx.Error))
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		db.Logger.Warn("API key not found or already revoked", zap.Uint64("id", id))
+		return gorm.ErrRecordNotFound
+	}
+	db.Logger.Info("Revoked API key", zap.Uint64("id", id), zap.String("revokedBy", revokedBy))
+	return nil
+}
+
+func (db Database) UpdateApiKeyLastUsed(id uint, usedAt time.Time, ip string) error {
+	// UpdateColumns so updated_at keeps reflecting changes made by admins
+	tx := db.Orm.Model(&ApiKey{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{
+			"last_used_at": usedAt,
+			"last_used_ip": ip,
+		})
+	if tx.Error != nil {
+		db.Logger.Error("Failed to update API key last usage", zap.Uint("id", id), zap.Error(tx.Error))
+		return tx.Error
+	}
+	return nil
+}
+
+// AddApiKeyUsage adds requests authorized with a key to its usage count.
+func (db Database) AddApiKeyUsage(id uint, count int64) error {
+	tx := db.Orm.Model(&ApiKey{}).
+		Where("id = ?", id).
+		UpdateColumn("usage_count", gorm.Expr("usage_count + ?", count))
+	if tx.Error != nil {
+		db.Logger.Error("Failed to update API key usage count", zap.Uint("id", id), zap.Error(tx.Error))
+		return tx.Error
+	}
+	return nil
+}
+
+// SetMissingApiKeyExpiry gives keys created before expiry was mandatory an expiry date.
+func (db Database) SetMissingApiKeyExpiry(expiresAt time.Time) (int64, error) {
+	tx := db.Orm.Model(&ApiKey{}).
+		Where("expires_at IS NULL").
+		UpdateColumn("expires_at", expiresAt)
+	if tx.Error != nil {
+		db.Logger.Error("Failed to set expiry of legacy API keys", zap.Error(tx.Error))
+		return 0, tx.Error
+	}
+	return tx.RowsAffected, nil
+}
+
 // --- User Methods ---
 
 func (db Database) CreateUser(user *User) error {
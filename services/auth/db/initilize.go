@@ -22,6 +22,13 @@ func (db Database) Initialize() error {
 		&User{},
 		&Configuration{},
 		&Connector{},
+		&RoleBinding{},
+		&AuditEvent{},
+		&ScimGroup{},
+		&ScimGroupMember{},
+		&ConnectorRoleRule{},
+		&ServiceAccount{},
+		&SessionRevocation{},
 	}
 
 	db.Logger.Info("Running AutoMigrate...")
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"github.com/lib/pq"
 	"github.com/opengovern/og-util/pkg/api"
 	"gorm.io/gorm"
 	"time"
@@ -18,8 +19,93 @@ type ApiKey struct {
 	Role          api.Role
 	CreatorUserID string
 	IsActive      bool
-	KeyHash       string
+	KeyHash       string `gorm:"index"`
 	MaskedKey     string
+	ExpiresAt     *time.Time
+	RevokedAt     *time.Time
+	RevokedBy     string
+	LastUsedAt    *time.Time
+	LastUsedIP    string
+	UsageCount    int64 `gorm:"default:0;not null"` // Requests authorized with the key, flushed periodically
+	// Service account owning the key, nil for keys created by users for themselves
+	ServiceAccountID *uint `gorm:"index"`
+	// Scopes, an empty list means the key is not restricted on that dimension
+	AllowedServices     pq.StringArray `gorm:"type:text[]"`
+	AllowedPathPrefixes pq.StringArray `gorm:"type:text[]"`
+	AllowedMethods      pq.StringArray `gorm:"type:text[]"`
+}
+
+// SessionRevocation ends the sessions of a user, its OIDC tokens issued before RevokedBefore
+// are rejected even though they did not expire yet
+type SessionRevocation struct {
+	Subject       string    `gorm:"primaryKey"` // External ID of the user
+	RevokedBefore time.Time `gorm:"not null"`
+	RevokedBy     string
+	Reason        string
+	UpdatedAt     time.Time
+}
+
+// ServiceAccount is a non-human identity owned by a team, requests made with its API keys
+// act as the account with its own role and role bindings
+type ServiceAccount struct {
+	gorm.Model
+	Name        string `gorm:"not null;uniqueIndex"`
+	Description string
+	Role        api.Role `gorm:"not null"`
+	OwnerTeam   string   `gorm:"not null;index"`
+	IsActive    bool     `gorm:"default:true;not null"`
+	CreatedBy   string
+}
+
+// RoleBinding limits a user or an API key to a set of integrations,
+// subjects without any binding are not limited
+type RoleBinding struct {
+	gorm.Model
+	SubjectType       string              `gorm:"not null;index:idx_role_binding_subject"`
+	SubjectID         string              `gorm:"not null;index:idx_role_binding_subject"`
+	IntegrationIDs    pq.StringArray      `gorm:"type:text[]"`
+	IntegrationGroups pq.StringArray      `gorm:"type:text[]"`
+	LabelSelectors    []map[string]string `gorm:"serializer:json;type:jsonb"` // Each selector matches integrations having all its labels
+	Description       string
+	CreatedBy         string
+}
+
+// ScimGroup is a group provisioned by the identity provider over SCIM,
+// its display name maps to the platform role granted to its members
+type ScimGroup struct {
+	ID          string `gorm:"primaryKey"`
+	DisplayName string `gorm:"not null;uniqueIndex"`
+	ExternalID  string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// ScimGroupMember links a user to a SCIM group
+type ScimGroupMember struct {
+	GroupID string `gorm:"primaryKey"`
+	UserID  uint   `gorm:"primaryKey;index"`
+}
+
+// AuditEvent is an entry of the hash-chained audit log, every event stores the hash of the previous one
+// so a modified or removed row breaks the chain
+type AuditEvent struct {
+	ID         uint64    `gorm:"primaryKey"`
+	Timestamp  time.Time `gorm:"not null;index"`
+	EventType  string    `gorm:"not null;index"`
+	ActorID    string    `gorm:"index"`
+	ActorEmail string
+	ActorRole  string
+	APIKeyID   string
+	Method     string
+	Path       string
+	TargetIDs  pq.StringArray `gorm:"type:text[]"`
+	Decision   string         `gorm:"not null"` // Decision of the auth check
+	Reason     string
+	RequestID  string `gorm:"index"`
+	SourceIP   string
+	PrevHash   string `gorm:"not null"`
+	Hash       string `gorm:"not null;uniqueIndex"`
+	Keyed      bool   `gorm:"not null;default:false"` // Hash is an HMAC under the audit key
 }
 
 type Connector struct {
@@ -29,6 +115,18 @@ type Connector struct {
 	ConnectorType    string
 	ConnectorSubType string
 	LastUpdate       time.Time
+	// Role mapping of users signing in through the connector, see ConnectorRoleRule
+	JITProvisioning bool     `gorm:"default:false;not null"` // Create unknown users on their first login
+	DefaultRole     api.Role // Role when no rule matches, empty denies access to users of a connector with rules
+}
+
+// ConnectorRoleRule grants a role to users of a connector whose identity provider claim matches a value
+type ConnectorRoleRule struct {
+	gorm.Model
+	ConnectorID string   `gorm:"not null;index"`
+	Claim       string   `gorm:"not null"` // groups, email, email_domain or preferred_username
+	Value       string   `gorm:"not null"` // Case-insensitive glob, e.g. platform-admins or *@example.com
+	Role        api.Role `gorm:"not null"`
 }
 
 type User struct {
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"errors"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	RoleBindingSubjectUser   = "user"
+	RoleBindingSubjectAPIKey = "api_key"
+	// Bindings of a service account apply to its keys without bindings of their own
+	RoleBindingSubjectServiceAccount = "service_account"
+)
+
+// --- Role Binding Methods ---
+
+func (db Database) CreateRoleBinding(binding *RoleBinding) error {
+	tx := db.Orm.Create(binding)
+	if tx.Error != nil {
+		db.Logger.Error("Failed to create role binding",
+			zap.String("subjectType", binding.SubjectType), zap.String("subjectId", binding.SubjectID), zap.Error(tx.Error))
+		return tx.Error
+	}
+	db.Logger.Info("Created role binding", zap.Uint("id", binding.ID),
+		zap.String("subjectType", binding.SubjectType), zap.String("subjectId", binding.SubjectID))
+	return nil
+}
+
+// ListRoleBindings lists the bindings, optionally only the ones of a subject.
+func (db Database) ListRoleBindings(subjectType, subjectID string) ([]RoleBinding, error) {
+	var bindings []RoleBinding
+	tx := db.Orm.Model(&RoleBinding{})
+	if subjectType != "" {
+		tx = tx.Where("subject_type = ?", subjectType)
+	}
+	if subjectID != "" {
+		tx = tx.Where("subject_id = ?", subjectID)
+	}
+	tx = tx.Order("id").Find(&bindings)
+	if tx.Error != nil {
+		db.Logger.Error("Failed to list role bindings", zap.String("subjectType", subjectType), zap.String("subjectId", subjectID), zap.Error(tx.Error))
+		return nil, tx.Error
+	}
+	return bindings, nil
+}
+
+func (db Database) GetRoleBinding(id uint64) (*RoleBinding, error) {
+	var binding RoleBinding
+	tx := db.Orm.Model(&RoleBinding{}).
+		Where("id = ?", id).
+		First(&binding)
+	if tx.Error != nil {
+		if !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			db.Logger.Error("Failed to get role binding", zap.Uint64("id", id), zap.Error(tx.Error))
+		}
+		return nil, tx.Error
+	}
+	return &binding, nil
+}
+
+func (db Database) DeleteRoleBinding(id uint64) error {
+	tx := db.Orm.Model(&RoleBinding{}).
+		Where("id = ?", id).
+		Delete(&RoleBinding{})
+	if tx.Error != nil {
+		db.Logger.Error("Failed to delete role binding", zap.Uint64("id", id), zap.Error(tx.Error))
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	db.Logger.Info("Deleted role binding", zap.Uint64("id", id))
+	return nil
+}
+
+// DeleteRoleBindingsOfSubject removes the bindings of a deleted user or API key.
+func (db Database) DeleteRoleBindingsOfSubject(subjectType, subjectID string) error {
+	tx := db.Orm.Model(&RoleBinding{}).
+		Where("subject_type = ? AND subject_id = ?", subjectType, subjectID).
+		Delete(&RoleBinding{})
+	if tx.Error != nil {
+		db.Logger.Error("Failed to delete role bindings of subject", zap.String("subjectType", subjectType), zap.String("subjectId", subjectID), zap.Error(tx.Error))
+		return tx.Error
+	}
+	return nil
+}
+
+// RenameRoleBindingSubject moves the bindings of a subject whose ID changed.
+func (db Database) RenameRoleBindingSubject(subjectType, oldSubjectID, newSubjectID string) error {
+	tx := db.Orm.Model(&RoleBinding{}).
+		Where("subject_type = ? AND subject_id = ?", subjectType, oldSubjectID).
+		Update("subject_id", newSubjectID)
+	if tx.Error != nil {
+		db.Logger.Error("Failed to rename role binding subject", zap.String("subjectType", subjectType),
+			zap.String("oldSubjectId", oldSubjectID), zap.String("newSubjectId", newSubjectID), zap.Error(tx.Error))
+		return tx.Error
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package db
+
+import (
+	"errors"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// --- SCIM Methods ---
+
+// ListUsersPaged returns a page of users ordered by ID and the total number of matching users.
+// field is one of email or external_id, an empty field does not filter.
+func (db Database) ListUsersPaged(field, value string, offset, limit int) ([]User, int64, error) {
+	var users []User
+	var total int64
+	tx := db.Orm.Model(&User{})
+	switch field {
+	case "":
+	case "email", "external_id":
+		tx = tx.Where(field+" = ?", value)
+	default:
+		return nil, 0, errors.New("unsupported user filter field: " + field)
+	}
+	if err := tx.Count(&total).Error; err != nil {
+		db.Logger.Error("Failed to count users", zap.Error(err))
+		return nil, 0, err
+	}
+	if err := tx.Order("id").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		db.Logger.Error("Failed to list users", zap.Error(err))
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// ExistingUserIDs returns the IDs among the given ones that belong to a user.
+func (db Database) ExistingUserIDs(ids []uint) ([]uint, error) {
+	var existing []uint
+	if len(ids) == 0 {
+		return existing, nil
+	}
+	tx := db.Orm.Model(&User{}).Where("id IN ?", ids).Pluck("id", &existing)
+	if tx.Error != nil {
+		db.Logger.Error("Failed to check user IDs", zap.Error(tx.Error))
+		return nil, tx.Error
+	}
+	return existing, nil
+}
+
+// CreateScimGroup stores a group with its initial members.
+func (db Database) CreateScimGroup(group *ScimGroup, memberIDs []uint) error {
+	err := db.Orm.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(group).Error; err != nil {
+			return err
+		}
+		return addScimGroupMembers(tx, group.ID, memberIDs)
+	})
+	if err != nil {
+		db.Logger.Error("Failed to create SCIM group", zap.String("displayName", group.DisplayName), zap.Error(err))
+		return err
+	}
+	db.Logger.Info("Created SCIM group", zap.String("id", group.ID), zap.String("displayName", group.DisplayName))
+	return nil
+}
+
+func (db Database) GetScimGroup(id string) (*ScimGroup, error) {
+	var group ScimGroup
+	tx := db.Orm.Where("id = ?", id).First(&group)
+	if tx.Error != nil {
+		if !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			db.Logger.Error("Failed to get SCIM group", zap.String("id", id), zap.Error(tx.Error))
+		}
+		return nil, tx.Error
+	}
+	return &group, nil
+}
+
+// ListScimGroups returns a page of groups ordered by display name, optionally filtered by display name.
+func (db Database) ListScimGroups(displayName string, offset, limit int) ([]ScimGroup, int64, error) {
+	var groups []ScimGroup
+	var total int64
+	tx := db.Orm.Model(&ScimGroup{})
+	if displayName != "" {
+		tx = tx.Where("display_name = ?", displayName)
+	}
+	if err := tx.Count(&total).Error; err != nil {
+		db.Logger.Error("Failed to count SCIM groups", zap.Error(err))
+		return nil, 0, err
+	}
+	if err := tx.Order("display_name").Offset(offset).Limit(limit).Find(&groups).Error; err != nil {
+		db.Logger.Error("Failed to list SCIM groups", zap.Error(err))
+		return nil, 0, err
+	}
+	return groups, total, nil
+}
+
+// UpdateScimGroup updates the display name and external ID of a group.
+func (db Database) UpdateScimGroup(group *ScimGroup) error {
+	tx := db.Orm.Model(&ScimGroup{}).
+		Where("id = ?", group.ID).
+		Updates(map[string]interface{}{
+			"display_name": group.DisplayName,
+			"external_id":  group.ExternalID,
+		})
+	if tx.Error != nil {
+		db.Logger.Error("Failed to update SCIM group", zap.String("id", group.ID), zap.Error(tx.Error))
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteScimGroup removes a group and its memberships.
+func (db Database) DeleteScimGroup(id string) error {
+	err := db.Orm.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", id).Delete(&ScimGroupMember{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("id = ?", id).Delete(&ScimGroup{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			db.Logger.Error("Failed to delete SCIM group", zap.String("id", id), zap.Error(err))
+		}
+		return err
+	}
+	db.Logger.Info("Deleted SCIM group", zap.String("id", id))
+	return nil
+}
+
+// ListScimGroupMemberIDs returns the member user IDs of each of the given groups.
+func (db Database) ListScimGroupMemberIDs(groupIDs []string) (map[string][]uint, error) {
+	members := make(map[string][]uint)
+	if len(groupIDs) == 0 {
+		return members, nil
+	}
+	var rows []ScimGroupMember
+	tx := db.Orm.Where("group_id IN ?", groupIDs).Order("user_id").Find(&rows)
+	if tx.Error != nil {
+		db.Logger.Error("Failed to list SCIM group members", zap.Error(tx.Error))
+		return nil, tx.Error
+	}
+	for _, row := range rows {
+		members[row.GroupID] = append(members[row.GroupID], row.UserID)
+	}
+	return members, nil
+}
+
+// ListScimGroupsOfUser returns the groups a user is a member of.
+func (db Database) ListScimGroupsOfUser(userID uint) ([]ScimGroup, error) {
+	var groups []ScimGroup
+	tx := db.Orm.Model(&ScimGroup{}).
+		Joins("JOIN scim_group_members ON scim_group_members.group_id = scim_groups.id").
+		Where("scim_group_members.user_id = ?", userID).
+		Order("scim_groups.display_name").
+		Find(&groups)
+	if tx.Error != nil {
+		db.Logger.Error("Failed to list SCIM groups of user", zap.Uint("userId", userID), zap.Error(tx.Error))
+		return nil, tx.Error
+	}
+	return groups, nil
+}
+
+func (db Database) AddScimGroupMembers(groupID string, userIDs []uint) error {
+	if err := addScimGroupMembers(db.Orm, groupID, userIDs); err != nil {
+		db.Logger.Error("Failed to add SCIM group members", zap.String("groupId", groupID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func addScimGroupMembers(tx *gorm.DB, groupID string, userIDs []uint) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	rows := make([]ScimGroupMember, 0, len(userIDs))
+	for _, id := range userIDs {
+		rows = append(rows, ScimGroupMember{GroupID: groupID, UserID: id})
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+}
+
+// RemoveScimGroupMembers removes the given members from a group, all of them if userIDs is nil.
+func (db Database) RemoveScimGroupMembers(groupID string, userIDs []uint) error {
+	tx := db.Orm.Where("group_id = ?", groupID)
+	if userIDs != nil {
+		if len(userIDs) == 0 {
+			return nil
+		}
+		tx = tx.Where("user_id IN ?", userIDs)
+	}
+	if err := tx.Delete(&ScimGroupMember{}).Error; err != nil {
+		db.Logger.Error("Failed to remove SCIM group members", zap.String("groupId", groupID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// RemoveUserFromScimGroups drops the memberships of a deleted user.
+func (db Database) RemoveUserFromScimGroups(userID uint) error {
+	if err := db.Orm.Where("user_id = ?", userID).Delete(&ScimGroupMember{}).Error; err != nil {
+		db.Logger.Error("Failed to remove user from SCIM groups", zap.Uint("userId", userID), zap.Error(err))
+		return err
+	}
+	return nil
+}
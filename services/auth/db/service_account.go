@@ -0,0 +1,194 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// pgUniqueViolation is the Postgres error code of a unique constraint violation
+const pgUniqueViolation = "23505"
+
+var (
+	// ErrServiceAccountExists is returned when a service account with the same name exists
+	ErrServiceAccountExists = errors.New("service account already exists")
+	// ErrServiceAccountKeyLimit is returned when a service account already has the maximum of usable keys
+	ErrServiceAccountKeyLimit = errors.New("service account has the maximum of active keys")
+)
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation
+}
+
+// --- Service Account Methods ---
+
+func (db Database) CreateServiceAccount(account *ServiceAccount) error {
+	tx := db.Orm.Create(account)
+	if tx.Error != nil {
+		if isUniqueViolation(tx.Error) {
+			return ErrServiceAccountExists
+		}
+		db.Logger.Error("Failed to create service account", zap.String("name", account.Name), zap.Error(tx.Error))
+		return tx.Error
+	}
+	db.Logger.Info("Created service account", zap.Uint("id", account.ID), zap.String("name", account.Name),
+		zap.String("ownerTeam", account.OwnerTeam))
+	return nil
+}
+
+// ListServiceAccounts lists the service accounts, optionally only the ones owned by a team.
+func (db Database) ListServiceAccounts(ownerTeam string) ([]ServiceAccount, error) {
+	var accounts []ServiceAccount
+	tx := db.Orm.Model(&ServiceAccount{})
+	if ownerTeam != "" {
+		tx = tx.Where("owner_team = ?", ownerTeam)
+	}
+	tx = tx.Order("name").Find(&accounts)
+	if tx.Error != nil {
+		db.Logger.Error("Failed to list service accounts", zap.String("ownerTeam", ownerTeam), zap.Error(tx.Error))
+		return nil, tx.Error
+	}
+	return accounts, nil
+}
+
+func (db Database) GetServiceAccount(id uint64) (*ServiceAccount, error) {
+	var account ServiceAccount
+	tx := db.Orm.Model(&ServiceAccount{}).
+		Where("id = ?", id).
+		First(&account)
+	if tx.Error != nil {
+		if !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			db.Logger.Error("Failed to get service account", zap.Uint64("id", id), zap.Error(tx.Error))
+		}
+		return nil, tx.Error
+	}
+	return &account, nil
+}
+
+// UpdateServiceAccount applies the given column updates to a service account,
+// a new role is also stored on its keys so their listing shows the role they act with.
+func (db Database) UpdateServiceAccount(id uint64, updates map[string]interface{}) error {
+	err := db.Orm.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&ServiceAccount{}).Where("id = ?", id).Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if role, ok := updates["role"]; ok {
+			return tx.Model(&ApiKey{}).Where("service_account_id = ?", id).Update("role", role).Error
+		}
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			db.Logger.Error("Failed to update service account", zap.Uint64("id", id), zap.Error(err))
+		}
+		return err
+	}
+	db.Logger.Info("Updated service account", zap.Uint64("id", id))
+	return nil
+}
+
+// DeleteServiceAccount removes a service account and its API keys.
+// The account row is removed for good so its name can be reused.
+func (db Database) DeleteServiceAccount(id uint64) error {
+	err := db.Orm.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("service_account_id = ?", id).Delete(&ApiKey{}).Error; err != nil {
+			return err
+		}
+		res := tx.Unscoped().Where("id = ?", id).Delete(&ServiceAccount{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			db.Logger.Error("Failed to delete service account", zap.Uint64("id", id), zap.Error(err))
+		}
+		return err
+	}
+	db.Logger.Info("Deleted service account", zap.Uint64("id", id))
+	return nil
+}
+
+// ListServiceAccountKeys lists the API keys of a service account, newest first.
+func (db Database) ListServiceAccountKeys(id uint) ([]ApiKey, error) {
+	var keys []ApiKey
+	tx := db.Orm.Model(&ApiKey{}).
+		Where("service_account_id = ?", id).
+		Order("created_at desc").
+		Find(&keys)
+	if tx.Error != nil {
+		db.Logger.Error("Failed to list service account keys", zap.Uint("serviceAccountId", id), zap.Error(tx.Error))
+		return nil, tx.Error
+	}
+	return keys, nil
+}
+
+// AddServiceAccountKey stores a key of a service account unless the account already has maxActiveKeys usable keys,
+// in which case ErrServiceAccountKeyLimit is returned. If expireActiveAt is set, the usable keys expire at that time
+// at the latest, used to end the overlap of a rotation, and they are returned.
+// The account row is locked for the transaction so concurrent creations and rotations are counted one after another.
+func (db Database) AddServiceAccountKey(key *ApiKey, maxActiveKeys int, expireActiveAt *time.Time) ([]ApiKey, error) {
+	if key.ServiceAccountID == nil {
+		return nil, errors.New("key has no service account")
+	}
+	accountID := *key.ServiceAccountID
+
+	var activeKeys []ApiKey
+	err := db.Orm.Transaction(func(tx *gorm.DB) error {
+		var account ServiceAccount
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", accountID).First(&account).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		err := tx.Model(&ApiKey{}).
+			Where("service_account_id = ?", accountID).
+			Where("is_active = ? AND revoked_at IS NULL", true).
+			Where("expires_at IS NULL OR expires_at > ?", now).
+			Find(&activeKeys).Error
+		if err != nil {
+			return err
+		}
+		if len(activeKeys) >= maxActiveKeys {
+			return ErrServiceAccountKeyLimit
+		}
+
+		if err := tx.Create(key).Error; err != nil {
+			return err
+		}
+		if expireActiveAt == nil || len(activeKeys) == 0 {
+			return nil
+		}
+		ids := make([]uint, 0, len(activeKeys))
+		for _, activeKey := range activeKeys {
+			ids = append(ids, activeKey.ID)
+		}
+		return tx.Model(&ApiKey{}).
+			Where("id IN ?", ids).
+			Where("expires_at IS NULL OR expires_at > ?", *expireActiveAt).
+			Update("expires_at", *expireActiveAt).Error
+	})
+	if err != nil {
+		if !errors.Is(err, ErrServiceAccountKeyLimit) && !errors.Is(err, gorm.ErrRecordNotFound) {
+			db.Logger.Error("Failed to add service account key", zap.Uint("serviceAccountId", accountID), zap.Error(err))
+		}
+		return nil, err
+	}
+	db.Logger.Info("Added service account key", zap.Uint("serviceAccountId", accountID), zap.Uint("id", key.ID))
+	if expireActiveAt == nil {
+		return nil, nil
+	}
+	return activeKeys, nil
+}
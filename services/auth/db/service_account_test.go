@@ -0,0 +1,20 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsUniqueViolation(t *testing.T) {
+	uniqueErr := &pgconn.PgError{Code: "23505", ConstraintName: "idx_service_accounts_name"}
+	assert.True(t, isUniqueViolation(uniqueErr))
+	assert.True(t, isUniqueViolation(fmt.Errorf("create: %w", uniqueErr)))
+
+	assert.False(t, isUniqueViolation(&pgconn.PgError{Code: "23503"}), "foreign key violations are not duplicates")
+	assert.False(t, isUniqueViolation(errors.New(`ERROR: duplicate key value violates unique constraint (SQLSTATE 23505)`)))
+	assert.False(t, isUniqueViolation(nil))
+}
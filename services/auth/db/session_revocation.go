@@ -0,0 +1,55 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// --- Session Revocation Methods ---
+
+// RevokeSessions revokes the sessions of a subject issued before the given time.
+// A revocation never moves back in time, the latest one wins.
+func (db Database) RevokeSessions(subject string, before time.Time, revokedBy, reason string) (*SessionRevocation, error) {
+	revocation := SessionRevocation{
+		Subject:       subject,
+		RevokedBefore: before,
+		RevokedBy:     revokedBy,
+		Reason:        reason,
+	}
+	tx := db.Orm.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "subject"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"revoked_before": gorm.Expr("GREATEST(session_revocations.revoked_before, excluded.revoked_before)"),
+			"revoked_by":     gorm.Expr("excluded.revoked_by"),
+			"reason":         gorm.Expr("excluded.reason"),
+			"updated_at":     gorm.Expr("excluded.updated_at"),
+		}),
+	}).Create(&revocation)
+	if tx.Error != nil {
+		db.Logger.Error("Failed to revoke sessions", zap.String("subject", subject), zap.Error(tx.Error))
+		return nil, tx.Error
+	}
+	db.Logger.Info("Revoked sessions", zap.String("subject", subject), zap.Time("before", before),
+		zap.String("revokedBy", revokedBy), zap.String("reason", reason))
+	return db.GetSessionRevocation(subject)
+}
+
+// GetSessionRevocation returns the revocation of a subject, nil if its sessions were never revoked.
+func (db Database) GetSessionRevocation(subject string) (*SessionRevocation, error) {
+	var revocation SessionRevocation
+	tx := db.Orm.Model(&SessionRevocation{}).
+		Where("subject = ?", subject).
+		First(&revocation)
+	if tx.Error != nil {
+		if errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		db.Logger.Error("Failed to get session revocation", zap.String("subject", subject), zap.Error(tx.Error))
+		return nil, tx.Error
+	}
+	return &revocation, nil
+}
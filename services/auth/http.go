@@ -3,10 +3,8 @@ package auth
 import (
 	"context"
 	"crypto/rsa"
-	"crypto/sha512"
 	"database/sql"
 	_ "embed" // Keep if needed for email templates later
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -18,6 +16,7 @@ import (
 	"time"
 
 	dexApi "github.com/dexidp/dex/api/v2"
+	envoycore "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoyauth "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
 	api2 "github.com/opengovern/og-util/pkg/api"
 	"github.com/opengovern/og-util/pkg/httpserver"
@@ -28,8 +27,6 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"google.golang.org/grpc/codes"
 
-	"github.com/golang-jwt/jwt"
-
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -42,6 +39,12 @@ type httpRoutes struct {
 	db                 db.Database
 	authCache          *authcache.AuthCacheService // Injected cache service
 	authServer         *Server                     // Injected main server logic
+	apiKeyMaxPerUser   int64                       // Active API keys a user can own
+	apiKeyDefaultTTL   time.Duration               // Lifetime of keys created without expires_in_days
+	apiKeyMaxTTL       time.Duration               // Longest lifetime a key can be created with
+	scimTokenHash      []byte                      // sha256 of the SCIM bearer token, SCIM is disabled when empty
+	scimConnectorID    string                      // Connector users provisioned over SCIM sign in with
+	scimGroupRoles     map[string]api2.Role        // Roles granted by SCIM groups, keyed by lowercase display name
 }
 
 // Register defines and registers all HTTP routes for the auth service.
@@ -61,12 +64,39 @@ func (r *httpRoutes) Register(e *echo.Echo) {
 	v1.GET("/user/password/check", httpserver.AuthorizeHandler(r.CheckUserPasswordChangeRequired, api2.ViewerRole))
 	v1.POST("/user/password/reset", httpserver.AuthorizeHandler(r.ResetUserPassword, api2.ViewerRole))
 	v1.DELETE("/user/:id", httpserver.AuthorizeHandler(r.DeleteUser, api2.AdminRole))
+	v1.POST("/user/:id/sessions/revoke", httpserver.AuthorizeHandler(r.RevokeUserSessions, api2.AdminRole))
+	v1.GET("/user/:id/sessions/revocation", httpserver.AuthorizeHandler(r.GetUserSessionRevocation, api2.AdminRole))
+	v1.POST("/me/sessions/revoke", httpserver.AuthorizeHandler(r.SignOutEverywhere, api2.ViewerRole)) // Sign out everywhere
 
 	// API Key Management Endpoints
 	v1.POST("/keys", httpserver.AuthorizeHandler(r.CreateAPIKey, api2.AdminRole))
 	v1.GET("/keys", httpserver.AuthorizeHandler(r.ListAPIKeys, api2.AdminRole))
 	v1.DELETE("/key/:id", httpserver.AuthorizeHandler(r.DeleteAPIKey, api2.AdminRole))
 	v1.PUT("/key/:id", httpserver.AuthorizeHandler(r.EditAPIKey, api2.AdminRole))
+	v1.POST("/key/:id/revoke", httpserver.AuthorizeHandler(r.RevokeAPIKey, api2.AdminRole))
+
+	// Service Accounts, their keys are revoked and deleted through the key endpoints
+	v1.GET("/service-accounts", httpserver.AuthorizeHandler(r.ListServiceAccounts, api2.AdminRole))
+	v1.POST("/service-accounts", httpserver.AuthorizeHandler(r.CreateServiceAccount, api2.AdminRole))
+	v1.GET("/service-account/:id", httpserver.AuthorizeHandler(r.GetServiceAccount, api2.AdminRole))
+	v1.PUT("/service-account/:id", httpserver.AuthorizeHandler(r.UpdateServiceAccount, api2.AdminRole))
+	v1.DELETE("/service-account/:id", httpserver.AuthorizeHandler(r.DeleteServiceAccount, api2.AdminRole))
+	v1.POST("/service-account/:id/keys", httpserver.AuthorizeHandler(r.CreateServiceAccountKey, api2.AdminRole))
+	v1.POST("/service-account/:id/keys/rotate", httpserver.AuthorizeHandler(r.RotateServiceAccountKey, api2.AdminRole))
+
+	// Role Binding Endpoints (integration scoped access)
+	v1.GET("/role-bindings", httpserver.AuthorizeHandler(r.ListRoleBindings, api2.AdminRole))
+	v1.POST("/role-bindings", httpserver.AuthorizeHandler(r.CreateRoleBinding, api2.AdminRole))
+	v1.DELETE("/role-binding/:id", httpserver.AuthorizeHandler(r.DeleteRoleBinding, api2.AdminRole))
+	v1.GET("/role-bindings/scope", httpserver.AuthorizeHandler(r.GetIntegrationScope, api2.AdminRole))
+
+	// Audit log
+	v1.GET("/audit/events", httpserver.AuthorizeHandler(r.ListAuditEvents, api2.AdminRole))
+	v1.GET("/audit/events/export", httpserver.AuthorizeHandler(r.ExportAuditEvents, api2.AdminRole))
+	v1.GET("/audit/verify", httpserver.AuthorizeHandler(r.VerifyAuditLog, api2.AdminRole))
+
+	// SCIM 2.0 provisioning, authenticated by its own bearer token
+	r.registerSCIM(e)
 
 	// Connector Management Endpoints
 	v1.GET("/connectors", httpserver.AuthorizeHandler(r.GetConnectors, api2.AdminRole))
@@ -75,7 +105,10 @@ func (r *httpRoutes) Register(e *echo.Echo) {
 	v1.POST("/connector", httpserver.AuthorizeHandler(r.CreateConnector, api2.AdminRole))
 	v1.POST("/connector/auth0", httpserver.AuthorizeHandler(r.CreateAuth0Connector, api2.AdminRole)) // Specific endpoint for Auth0
 	v1.PUT("/connector", httpserver.AuthorizeHandler(r.UpdateConnector, api2.AdminRole))
+	v1.POST("/connector/test", httpserver.AuthorizeHandler(r.TestConnectorConnection, api2.AdminRole))
 	v1.DELETE("/connector/:id", httpserver.AuthorizeHandler(r.DeleteConnector, api2.AdminRole)) // Assuming delete by ConnectorID (string)
+	v1.GET("/connector/:id/role-mapping", httpserver.AuthorizeHandler(r.GetConnectorRoleMapping, api2.AdminRole))
+	v1.PUT("/connector/:id/role-mapping", httpserver.AuthorizeHandler(r.UpdateConnectorRoleMapping, api2.AdminRole))
 }
 
 const healthCheckDBTimeout = 2 * time.Second
@@ -173,9 +206,18 @@ func (r *httpRoutes) Check(ctx echo.Context) error {
 		// Decide how to handle parse error - maybe deny? For now, use path from request URL
 		checkRequest.Attributes.Request.Http.Path = ctx.Request().URL.Path
 	} else {
-		checkRequest.Attributes.Request.Http.Path = originalUri.Path
+		// Keep the query like Envoy does, the audit log reads target IDs from it
+		checkRequest.Attributes.Request.Http.Path = originalUri.RequestURI()
 	}
 	checkRequest.Attributes.Request.Http.Method = originalMethod
+	// Client address, used when no forwarding headers are present
+	checkRequest.Attributes.Source = &envoyauth.AttributeContext_Peer{
+		Address: &envoycore.Address{
+			Address: &envoycore.Address_SocketAddress{
+				SocketAddress: &envoycore.SocketAddress{Address: ctx.RealIP()},
+			},
+		},
+	}
 
 	// Call the core Check logic (likely involving cache/DB lookups)
 	res, err := r.authServer.Check(ctx.Request().Context(), &checkRequest)
@@ -195,6 +237,10 @@ func (r *httpRoutes) Check(ctx echo.Context) error {
 			zap.String("message", res.Status.Message),
 			zap.String("path", checkRequest.Attributes.Request.Http.Path),
 			zap.String("method", checkRequest.Attributes.Request.Http.Method))
+		if res.Status.Code == int32(codes.PermissionDenied) {
+			// Authenticated, but e.g. outside of the API key scopes
+			return echo.NewHTTPError(http.StatusForbidden, "Forbidden")
+		}
 		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized") // Simple unauthorized
 	}
 
@@ -364,11 +410,21 @@ func (r *httpRoutes) CreateAPIKey(ctx echo.Context) error {
 		r.logger.Error("Failed to count API keys for user", zap.String("externalId", externalUserID), zap.Error(err))
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check key limits")
 	}
-	// TODO: Make key limit configurable
-	if currentKeyCount >= 5 {
-		r.logger.Warn("API key limit reached for user", zap.String("externalId", externalUserID), zap.Int64("limit", 5))
-		return echo.NewHTTPError(http.StatusNotAcceptable, "Maximum number of API keys (5) reached for user")
+	if currentKeyCount >= r.apiKeyMaxPerUser {
+		r.logger.Warn("API key limit reached for user", zap.String("externalId", externalUserID), zap.Int64("limit", r.apiKeyMaxPerUser))
+		return echo.NewHTTPError(http.StatusNotAcceptable, fmt.Sprintf("Maximum number of API keys (%d) reached for user", r.apiKeyMaxPerUser))
+	}
+
+	ttl, err := r.apiKeyTTL(req.ExpiresInDays)
+	if err != nil {
+		return err
+	}
+	allowedServices, allowedPathPrefixes, allowedMethods, err := normalizeAPIKeyScopes(req.Scopes)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
+	issuedAt := time.Now()
+	expiresAt := issuedAt.Add(ttl)
 
 	// Prepare claims for the new API key JWT
 	// Note: This JWT represents the API key itself, not the user's session
@@ -376,8 +432,8 @@ func (r *httpRoutes) CreateAPIKey(ctx echo.Context) error {
 		Role:           req.Role,          // Role assigned to the key
 		Email:          creatorUser.Email, // Email of the user *creating* the key
 		ExternalUserID: externalUserID,    // External ID of the user *creating* the key
-		// Add IssuedAt, maybe Expiry if keys should expire?
-		// StandardClaims: jwt.StandardClaims{ IssuedAt: time.Now().Unix() },
+		IssuedAt:       issuedAt.Unix(),
+		ExpiresAt:      expiresAt.Unix(),
 	}
 
 	// Ensure platform private key is available for signing
@@ -386,25 +442,13 @@ func (r *httpRoutes) CreateAPIKey(ctx echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "API key generation is disabled")
 	}
 
-	// Sign the token
-	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, &apiKeyClaims).SignedString(r.platformPrivateKey)
+	// Sign the token, and generate masked key and hash for storage
+	token, maskedKey, keyHash, err := signAPIKeyToken(r.platformPrivateKey, &apiKeyClaims)
 	if err != nil {
 		r.logger.Error("Failed to sign API key token", zap.Error(err))
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate API key token")
 	}
 
-	// Generate masked key and hash for storage
-	maskedKey := "invalid"
-	if len(token) > 20 { // Basic check to avoid panic on very short tokens
-		maskedKey = fmt.Sprintf("%s...%s", token[:10], token[len(token)-10:])
-	}
-	hash := sha512.New()
-	if _, err = hash.Write([]byte(token)); err != nil { // Check hash write error
-		r.logger.Error("Failed to hash API key token", zap.Error(err))
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to secure API key")
-	}
-	keyHash := hex.EncodeToString(hash.Sum(nil))
-
 	// Create DB record for the key
 	apiKeyRecord := db.ApiKey{
 		Name:          req.Name,
@@ -413,6 +457,11 @@ func (r *httpRoutes) CreateAPIKey(ctx echo.Context) error {
 		IsActive:      true,
 		MaskedKey:     maskedKey,
 		KeyHash:       keyHash, // Store the hash of the full token
+		ExpiresAt:     &expiresAt,
+		// Scopes are checked against every request made with the key
+		AllowedServices:     allowedServices,
+		AllowedPathPrefixes: allowedPathPrefixes,
+		AllowedMethods:      allowedMethods,
 	}
 
 	// Add to database
@@ -429,10 +478,27 @@ func (r *httpRoutes) CreateAPIKey(ctx echo.Context) error {
 		Active:    apiKeyRecord.IsActive,
 		CreatedAt: apiKeyRecord.CreatedAt,
 		RoleName:  apiKeyRecord.Role,
+		ExpiresAt: expiresAt,
+		Scopes:    apiKeyScopesFromDB(apiKeyRecord),
 		Token:     token, // Return the actual JWT token
 	})
 }
 
+// apiKeyTTL returns the lifetime of a new key, every key expires and the lifetime is bounded by the configured maximum.
+func (r *httpRoutes) apiKeyTTL(expiresInDays int) (time.Duration, error) {
+	ttl := r.apiKeyDefaultTTL
+	if expiresInDays < 0 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "expires_in_days must be positive")
+	} else if expiresInDays > 0 {
+		ttl = time.Duration(expiresInDays) * 24 * time.Hour
+	}
+	if ttl > r.apiKeyMaxTTL {
+		return 0, echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("expires_in_days cannot be more than %d", int(r.apiKeyMaxTTL/(24*time.Hour))))
+	}
+	return ttl, nil
+}
+
 // DeleteAPIKey deletes an API key by its database ID.
 func (r *httpRoutes) DeleteAPIKey(ctx echo.Context) error {
 	idStr := ctx.Param("id")
@@ -442,6 +508,15 @@ func (r *httpRoutes) DeleteAPIKey(ctx echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid API key ID format")
 	}
 
+	// Load the key first so its cached state can be dropped
+	key, err := r.db.GetApiKey(apiKeyID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "API key not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete API key")
+	}
+
 	// Perform deletion
 	err = r.db.DeleteAPIKey(apiKeyID)
 	if err != nil {
@@ -453,6 +528,12 @@ func (r *httpRoutes) DeleteAPIKey(ctx echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete API key")
 	}
 
+	_ = r.authCache.RemoveAPIKeyFromCache(ctx.Request().Context(), key.KeyHash)
+	// A key ID is never reused, its bindings are dropped with it
+	if err := r.db.DeleteRoleBindingsOfSubject(db.RoleBindingSubjectAPIKey, idStr); err == nil {
+		_ = r.authCache.RemoveIntegrationScope(ctx.Request().Context(), db.RoleBindingSubjectAPIKey, idStr)
+	}
+
 	r.logger.Info("Deleted API key", zap.Uint64("id", apiKeyID))
 	return ctx.NoContent(http.StatusAccepted) // 202 Accepted or 204 No Content are appropriate
 }
@@ -466,13 +547,29 @@ func (r *httpRoutes) EditAPIKey(ctx echo.Context) error {
 	}
 
 	// Validate ID format (UpdateAPIKey expects string, but let's ensure it's reasonable)
-	if _, err := strconv.ParseUint(idStr, 10, 64); err != nil {
+	apiKeyID, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
 		r.logger.Warn("Invalid API key ID format for edit", zap.String("id", idStr), zap.Error(err))
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid API key ID format")
 	}
 
+	key, err := r.db.GetApiKey(apiKeyID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "API key not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update API key")
+	}
+	// Revocation is permanent, a revoked key has to be replaced by a new one
+	if key.RevokedAt != nil && req.IsActive {
+		return echo.NewHTTPError(http.StatusConflict, "Revoked API keys cannot be re-activated")
+	}
+	if key.ServiceAccountID != nil && req.Role != key.Role {
+		return echo.NewHTTPError(http.StatusBadRequest, "Keys of a service account have the role of their account")
+	}
+
 	// Perform update
-	err := r.db.UpdateAPIKey(idStr, req.IsActive, req.Role)
+	err = r.db.UpdateAPIKey(idStr, req.IsActive, req.Role)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			r.logger.Info("API key not found for edit", zap.String("id", idStr))
@@ -482,10 +579,44 @@ func (r *httpRoutes) EditAPIKey(ctx echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update API key")
 	}
 
+	// Drop the cached state so the change applies to the next request made with the key
+	_ = r.authCache.RemoveAPIKeyFromCache(ctx.Request().Context(), key.KeyHash)
+
 	r.logger.Info("Edited API key", zap.String("id", idStr), zap.Bool("isActive", req.IsActive), zap.String("role", string(req.Role)))
 	return ctx.NoContent(http.StatusAccepted) // 202 Accepted or 200 OK / 204 No Content
 }
 
+// RevokeAPIKey permanently revokes an API key by its database ID.
+func (r *httpRoutes) RevokeAPIKey(ctx echo.Context) error {
+	idStr := ctx.Param("id")
+	apiKeyID, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		r.logger.Warn("Invalid API key ID format for revocation", zap.String("id", idStr), zap.Error(err))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid API key ID format")
+	}
+
+	key, err := r.db.GetApiKey(apiKeyID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "API key not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke API key")
+	}
+
+	err = r.db.RevokeAPIKey(apiKeyID, httpserver.GetUserID(ctx))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusConflict, "API key is already revoked")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke API key")
+	}
+	// Drop the cached state so the key is rejected by the next check
+	_ = r.authCache.RemoveAPIKeyFromCache(ctx.Request().Context(), key.KeyHash)
+
+	r.logger.Info("Revoked API key", zap.Uint64("id", apiKeyID), zap.String("revokedBy", httpserver.GetUserID(ctx)))
+	return ctx.NoContent(http.StatusAccepted)
+}
+
 // ListAPIKeys lists API keys for the currently authenticated user.
 func (r *httpRoutes) ListAPIKeys(ctx echo.Context) error {
 	//externalUserID := httpserver.GetUserID(ctx)
@@ -503,16 +634,7 @@ func (r *httpRoutes) ListAPIKeys(ctx echo.Context) error {
 	// Map to response
 	resp := make([]api.APIKeyResponse, 0, len(keys))
 	for _, key := range keys {
-		resp = append(resp, api.APIKeyResponse{
-			ID:            key.ID,
-			CreatedAt:     key.CreatedAt,
-			UpdatedAt:     key.UpdatedAt, // Include UpdatedAt if needed
-			Name:          key.Name,
-			RoleName:      key.Role,
-			CreatorUserID: key.CreatorUserID,
-			Active:        key.IsActive,
-			MaskedKey:     key.MaskedKey,
-		})
+		resp = append(resp, apiKeyToAPI(key))
 	}
 
 	return ctx.JSON(http.StatusOK, resp)
@@ -688,6 +810,8 @@ func (r *httpRoutes) UpdateUser(ctx echo.Context) error {
 
 	// Track if any changes were made that require cache invalidation
 	cacheNeedsInvalidation := false
+	// Password, role and deactivation changes end the current sessions of the user
+	var revocationReasons []string
 
 	// Update password if provided and connector is local
 	if req.Password != nil && *req.Password != "" {
@@ -735,6 +859,7 @@ func (r *httpRoutes) UpdateUser(ctx echo.Context) error {
 				// Log but don't necessarily fail the whole request
 			}
 		}
+		revocationReasons = append(revocationReasons, sessionRevocationPasswordChanged)
 	}
 
 	// Update other user fields
@@ -744,10 +869,14 @@ func (r *httpRoutes) UpdateUser(ctx echo.Context) error {
 		// TODO: Add validation for allowed roles
 		updateData["role"] = *req.Role
 		cacheNeedsInvalidation = true // Role change invalidates cache
+		revocationReasons = append(revocationReasons, sessionRevocationRoleChanged)
 	}
 	if user.IsActive != req.IsActive {
 		updateData["is_active"] = req.IsActive
 		cacheNeedsInvalidation = true // Active status change invalidates cache
+		if !req.IsActive {
+			revocationReasons = append(revocationReasons, sessionRevocationDeactivated)
+		}
 	}
 	if req.UserName != "" && user.Username != req.UserName {
 		updateData["username"] = req.UserName
@@ -793,6 +922,16 @@ func (r *httpRoutes) UpdateUser(ctx echo.Context) error {
 			r.logger.Error("Failed to invalidate user cache after update", zap.String("email", email), zap.Error(err))
 		}
 	}
+	if len(revocationReasons) > 0 {
+		subject := user.ExternalId
+		if newExternalId, ok := updateData["external_id"].(string); ok {
+			subject = newExternalId
+		}
+		_, err = r.authServer.revokeSessions(ctx.Request().Context(), subject, httpserver.GetUserID(ctx), strings.Join(revocationReasons, ", "))
+		if err != nil {
+			r.logger.Error("Failed to revoke sessions after user update", zap.Uint("id", user.ID), zap.Error(err))
+		}
+	}
 
 	return ctx.NoContent(http.StatusOK) // 200 OK or 204 No Content
 }
@@ -879,6 +1018,18 @@ func (r *httpRoutes) DoDeleteUser(idStr string) error {
 		r.logger.Error("Failed to invalidate user cache after deletion", zap.String("email", userEmail), zap.Error(err))
 	}
 
+	_ = r.db.RemoveUserFromScimGroups(user.ID)
+
+	// Tokens of the deleted user must not work again for a user re-created with the same external ID
+	if _, err = r.authServer.revokeSessions(cacheCtx, user.ExternalId, "", sessionRevocationDeleted); err != nil {
+		r.logger.Error("Failed to revoke sessions of deleted user", zap.Uint("id", user.ID), zap.Error(err))
+	}
+
+	// Drop the role bindings so a user re-created with the same external ID starts without them
+	if err = r.db.DeleteRoleBindingsOfSubject(db.RoleBindingSubjectUser, user.ExternalId); err == nil {
+		_ = r.authCache.RemoveIntegrationScope(cacheCtx, db.RoleBindingSubjectUser, user.ExternalId)
+	}
+
 	return nil // Indicate success to the calling handler
 }
 
@@ -998,6 +1149,11 @@ func (r *httpRoutes) ResetUserPassword(ctx echo.Context) error {
 		r.logger.Error("Failed to invalidate user cache after password reset", zap.String("email", user.Email), zap.Error(err))
 	}
 
+	// Sessions opened with the old password end, including the one making this request
+	if _, err := r.authServer.revokeSessions(ctx.Request().Context(), user.ExternalId, externalUserID, sessionRevocationPasswordChanged); err != nil {
+		r.logger.Error("Failed to revoke sessions after password reset", zap.Uint("id", user.ID), zap.Error(err))
+	}
+
 	r.logger.Info("User successfully reset password", zap.String("email", user.Email), zap.Uint("id", user.ID))
 	return ctx.NoContent(http.StatusAccepted)
 }
@@ -1071,6 +1227,18 @@ func (r *httpRoutes) GetConnectors(ctx echo.Context) error {
 				// Don't fail the request, just omit the OIDC details
 			}
 		}
+		switch strings.ToLower(dexConnector.Type) {
+		case "ldap":
+			var ldapConfig utils.LDAPConfig
+			if err := json.Unmarshal(dexConnector.Config, &ldapConfig); err == nil {
+				info.Host = ldapConfig.Host
+			}
+		case "saml":
+			var samlConfig utils.SAMLConfig
+			if err := json.Unmarshal(dexConnector.Config, &samlConfig); err == nil {
+				info.SSOURL = samlConfig.SSOURL
+			}
+		}
 
 		resp = append(resp, info)
 	}
@@ -1128,13 +1296,14 @@ func (r *httpRoutes) CreateConnector(ctx echo.Context) error {
 
 	// Handle subtype validation and default assignment
 	if connectorSubTypeLower == "" {
-		if connectorTypeLower == "oidc" { // Default OIDC subtype if not provided
+		switch connectorTypeLower {
+		case "oidc", "saml": // Default OIDC and SAML subtype if not provided
 			connectorSubTypeLower = "general"
-			req.ConnectorSubType = "general" // Update request struct for consistency
-			r.logger.Info("No connector_sub_type specified for OIDC, defaulting to 'general'")
-		} else {
-			// If other types require subtypes, add checks here
+		case "ldap":
+			connectorSubTypeLower = "openldap"
 		}
+		req.ConnectorSubType = connectorSubTypeLower // Update request struct for consistency
+		r.logger.Info("No connector_sub_type specified, using the default", zap.String("type", connectorTypeLower), zap.String("subtype", connectorSubTypeLower))
 	}
 
 	if !utils.IsSupportedSubType(connectorTypeLower, connectorSubTypeLower) {
@@ -1157,19 +1326,10 @@ func (r *httpRoutes) CreateConnector(ctx echo.Context) error {
 		r.logger.Info("Assigning default connector Name", zap.String("name", req.Name))
 	}
 
-	// Validate required fields based on subtype (could also be in creator)
-	switch connectorSubTypeLower {
-	case "general":
-		if strings.TrimSpace(req.Issuer) == "" {
-			return echo.NewHTTPError(http.StatusBadRequest, "issuer is required for 'general' OIDC connector")
-		}
-	case "entraid":
-		if strings.TrimSpace(req.TenantID) == "" {
-			return echo.NewHTTPError(http.StatusBadRequest, "tenant_id is required for 'entraid' OIDC connector")
-		}
-	case "google-workspace":
-		// Requires ClientID and ClientSecret (validated by struct tags)
-		break
+	// Validate required fields based on type and subtype (could also be in creator)
+	if err := validateConnectorFields(connectorTypeLower, connectorSubTypeLower, req.Issuer, req.TenantID,
+		req.ClientID, req.ClientSecret, req.LDAP != nil, req.SAML != nil); err != nil {
+		return err
 	}
 
 	// Prepare request for Dex utils function
@@ -1182,6 +1342,9 @@ func (r *httpRoutes) CreateConnector(ctx echo.Context) error {
 		ClientSecret:     req.ClientSecret,
 		ID:               req.ID,
 		Name:             req.Name,
+		LDAP:             req.LDAP,
+		SAML:             req.SAML,
+		AttributeMapping: req.AttributeMapping,
 	}
 
 	// Create the Dex gRPC request structure
@@ -1364,18 +1527,28 @@ func (r *httpRoutes) UpdateConnector(ctx echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err)
 	}
 
-	// Validate required fields based on subtype (similar to create)
-	switch connectorSubTypeLower {
-	case "general":
-		if strings.TrimSpace(req.Issuer) == "" {
-			return echo.NewHTTPError(http.StatusBadRequest, "issuer is required for 'general' OIDC connector update")
+	// Validate required fields based on type and subtype (similar to create)
+	if err := validateConnectorFields(connectorTypeLower, connectorSubTypeLower, req.Issuer, req.TenantID,
+		req.ClientID, req.ClientSecret, req.LDAP != nil, req.SAML != nil); err != nil {
+		return err
+	}
+
+	// An omitted bind password keeps the current one
+	if connectorTypeLower == "ldap" && req.LDAP != nil && req.LDAP.BindPassword == "" {
+		connectors, err := r.authServer.dexClient.ListConnectors(ctx.Request().Context(), &dexApi.ListConnectorReq{})
+		if err != nil {
+			r.logger.Error("Failed to list connectors from Dex", zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve the current connector")
+		}
+		var current *dexApi.Connector
+		for _, connector := range connectors.Connectors {
+			if connector.Id == req.ConnectorID {
+				current = connector
+			}
 		}
-	case "entraid":
-		if strings.TrimSpace(req.TenantID) == "" {
-			return echo.NewHTTPError(http.StatusBadRequest, "tenant_id is required for 'entraid' OIDC connector update")
+		if err = utils.KeepLDAPBindPassword(req.LDAP, current); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		}
-	case "google-workspace":
-		break // ClientID/Secret validated by struct tags
 	}
 
 	// Prepare request for Dex utils update function
@@ -1388,10 +1561,17 @@ func (r *httpRoutes) UpdateConnector(ctx echo.Context) error {
 		ClientSecret:     req.ClientSecret,
 		ID:               req.ConnectorID, // Pass Dex's ID to the util function
 		Name:             req.Name,        // Pass Name if updating it is supported/desired
+		LDAP:             req.LDAP,
+		SAML:             req.SAML,
+		AttributeMapping: req.AttributeMapping,
 	}
 
 	// Create the Dex gRPC update request structure
-	dexGrpcReq, err := utils.UpdateOIDCConnector(dexUtilRequest)
+	updater := utils.GetConnectorUpdater(connectorTypeLower)
+	if updater == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Connector type '%s' is not supported", req.ConnectorType))
+	}
+	dexGrpcReq, err := updater(dexUtilRequest)
 	if err != nil {
 		r.logger.Error("Failed to prepare Dex connector update request structure", zap.Error(err))
 		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Failed to prepare connector update config: %v", err))
@@ -1500,6 +1680,10 @@ func (r *httpRoutes) DeleteConnector(ctx echo.Context) error {
 		}
 	}
 
+	if err = r.db.DeleteConnectorRoleRules(connectorID); err == nil {
+		_ = r.authCache.RemoveConnectorRoleMapping(ctx.Request().Context(), connectorID)
+	}
+
 	// Invalidate any cache entries related to users from this connector? Complex.
 	// For now, rely on user cache TTL or invalidation during user-specific updates.
 
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+
+	envoycore "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoyauth "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	envoytype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/opengovern/og-util/pkg/api"
+	"github.com/opengovern/opensecurity/services/auth/authcache"
+	"github.com/opengovern/opensecurity/services/auth/ratelimit"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/status"
+)
+
+// rateLimitCheck counts the request against the rate limit of its caller and route class.
+// It returns a 429 response when the caller is over its limit, nil when the request can go on.
+func (s *Server) rateLimitCheck(ctx context.Context, req *envoyauth.CheckRequest, externalID string, role api.Role,
+	apiKey *authcache.CachedAPIKey, logFields []zap.Field) *envoyauth.CheckResponse {
+	if s.rateLimiter == nil {
+		return nil
+	}
+	identity := ratelimit.Identity{UserID: externalID, Role: role}
+	if apiKey != nil {
+		identity.APIKeyID = apiKey.ID
+	}
+	httpRequest := req.GetAttributes().GetRequest().GetHttp()
+	decision, err := s.rateLimiter.Allow(ctx, identity, httpRequest.GetMethod(), httpRequest.GetPath())
+	if err != nil {
+		// Fail open, an unavailable store must not take the API down
+		s.logger.Error("Rate limit check failed, letting the request through", append(logFields, zap.Error(err))...)
+	}
+	if decision.Allowed {
+		return nil
+	}
+
+	retryAfter := strconv.Itoa(int(math.Ceil(decision.RetryAfter.Seconds())))
+	s.logger.Warn("Access denied: rate limit exceeded",
+		append(logFields, zap.String("routeClass", decision.RouteClass), zap.Duration("retryAfter", decision.RetryAfter))...)
+	return &envoyauth.CheckResponse{
+		Status: &status.Status{Code: int32(rpc.RESOURCE_EXHAUSTED), Message: "rate limit exceeded"},
+		HttpResponse: &envoyauth.CheckResponse_DeniedResponse{
+			DeniedResponse: &envoyauth.DeniedHttpResponse{
+				Status: &envoytype.HttpStatus{Code: http.StatusTooManyRequests},
+				Headers: []*envoycore.HeaderValueOption{
+					{Header: &envoycore.HeaderValue{Key: "Retry-After", Value: retryAfter}},
+					{Header: &envoycore.HeaderValue{Key: "X-RateLimit-Limit", Value: strconv.FormatFloat(decision.Limit.RequestsPerMinute, 'f', -1, 64) + ";w=60"}},
+				},
+				Body: http.StatusText(http.StatusTooManyRequests),
+			},
+		},
+	}
+}
@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// natsTakeAttempts bounds the retries of a take losing the race against another replica.
+const natsTakeAttempts = 5
+
+// natsKVStore keeps the buckets in a NATS JetStream key-value bucket shared by all replicas.
+// Updates are compare-and-set on the entry revision, so concurrent takes never share a token.
+type natsKVStore struct {
+	kv jetstream.KeyValue
+}
+
+// NewNATSKVStore returns a Store shared by all replicas, kept in the in-memory KV bucket "<bucketPrefix>-ratelimit".
+func NewNATSKVStore(nc *nats.Conn, bucketPrefix string) (Store, error) {
+	if nc == nil {
+		return nil, errors.New("nats connection cannot be nil")
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jetstream context: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	kv, err := js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{
+		Bucket:      bucketPrefix + "-ratelimit",
+		Description: "opensecurity auth service rate limit buckets",
+		History:     1,
+		TTL:         maxRefillDuration, // Idle buckets are full again by then
+		Storage:     jetstream.MemoryStorage,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kv bucket %s-ratelimit: %w", bucketPrefix, err)
+	}
+	return &natsKVStore{kv: kv}, nil
+}
+
+func (s *natsKVStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (bool, time.Duration, error) {
+	kvKey := base64.RawURLEncoding.EncodeToString([]byte(key))
+	for attempt := 0; attempt < natsTakeAttempts; attempt++ {
+		var bucket bucketState
+		var revision uint64
+		entry, err := s.kv.Get(ctx, kvKey)
+		switch {
+		case err == nil:
+			revision = entry.Revision()
+			if err := json.Unmarshal(entry.Value(), &bucket); err != nil {
+				bucket = bucketState{} // Start over with a full bucket
+			}
+		case !errors.Is(err, jetstream.ErrKeyNotFound):
+			return true, 0, err
+		}
+
+		allowed, retryAfter := bucket.take(limit, now)
+		if !allowed {
+			return false, retryAfter, nil
+		}
+		data, err := json.Marshal(bucket)
+		if err != nil {
+			return true, 0, err
+		}
+		if revision == 0 {
+			_, err = s.kv.Create(ctx, kvKey, data)
+		} else {
+			_, err = s.kv.Update(ctx, kvKey, data, revision)
+		}
+		if err == nil {
+			return true, 0, nil
+		}
+		if !errors.Is(err, jetstream.ErrKeyExists) {
+			return true, 0, err
+		}
+		// Another replica took a token in between, retry with its state
+	}
+	return true, 0, fmt.Errorf("rate limit bucket %s kept changing during %d attempts", key, natsTakeAttempts)
+}
@@ -0,0 +1,308 @@
+// Package ratelimit limits the requests of each identity going through the auth service ext-authz check.
+//
+// Requests are sorted into route classes by method and path, and each identity (user or API key) gets one
+// token bucket per route class. The limit of a bucket comes from the most specific configured rule: an API key
+// rule before a user rule, before a role rule, before a rule for everyone. Buckets live in a Store, which can
+// be shared by all replicas of the service.
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opengovern/og-util/pkg/api"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// AllRouteClasses matches every route class in a limit rule.
+const AllRouteClasses = "*"
+
+// DefaultRouteClass holds the requests matching no configured route class.
+const DefaultRouteClass = "default"
+
+// maxRefillDuration bounds the time an empty bucket takes to refill, idle buckets are dropped after it.
+const maxRefillDuration = time.Hour
+
+// DefaultRouteClasses are the expensive endpoints limited separately from the rest of the API,
+// configured route classes with the same name replace them. Paths are the ones the proxy checks,
+// prefixed with the name of the service.
+var DefaultRouteClasses = map[string][]string{
+	"query": {
+		"POST /core/api/v1/query/run",
+		"POST /core/api/v3/query/run",
+	},
+	"compliance": {
+		"POST /compliance/api/v1/compliance_result",
+		"POST /compliance/api/v1/compliance_result/*",
+	},
+}
+
+// --- Prometheus metrics ---
+
+var (
+	metricThrottled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "auth",
+		Subsystem: "ratelimit",
+		Name:      "throttled_requests_total",
+		Help:      "Total number of requests denied because the identity exceeded its rate limit.",
+	}, []string{"route_class", "role", "identity_type"})
+	metricErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "auth",
+		Subsystem: "ratelimit",
+		Name:      "errors_total",
+		Help:      "Total number of rate limit store errors, the requests are let through.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(metricThrottled, metricErrors)
+}
+
+// --- Configuration ---
+
+// Limit is a token bucket refilled at RequestsPerMinute and holding at most Burst requests.
+type Limit struct {
+	RequestsPerMinute float64 `json:"requests_per_minute"`
+	Burst             int     `json:"burst,omitempty"`     // Defaults to RequestsPerMinute
+	Unlimited         bool    `json:"unlimited,omitempty"` // Exempts the matching identities
+}
+
+// LimitRule applies a limit to the requests of a route class, optionally only to the ones of a role,
+// a user (external ID) or an API key. User rules apply to the sessions of the user, not to its API keys.
+type LimitRule struct {
+	RouteClass string   `json:"route_class"` // Route class name or "*"
+	Role       api.Role `json:"role,omitempty"`
+	UserID     string   `json:"user_id,omitempty"`
+	APIKeyID   uint     `json:"api_key_id,omitempty"`
+	Limit
+}
+
+// specificity orders the rules matching a request, the highest one applies.
+func (r LimitRule) specificity() int {
+	level := 0
+	switch {
+	case r.APIKeyID != 0:
+		level = 3
+	case r.UserID != "":
+		level = 2
+	case r.Role != "":
+		level = 1
+	}
+	if r.RouteClass != AllRouteClasses {
+		return level*2 + 1
+	}
+	return level * 2
+}
+
+// Config lists the route classes and the limit rules. Routes are "<METHOD> <path pattern>" or "<path pattern>"
+// for any method, patterns use path.Match syntax against the request path without its query.
+type Config struct {
+	RouteClasses map[string][]string `json:"route_classes,omitempty"`
+	Limits       []LimitRule         `json:"limits"`
+}
+
+// route is a parsed route class pattern
+type route struct {
+	class   string
+	method  string
+	pattern string
+}
+
+// ParseConfig parses and validates a JSON rate limit configuration.
+func ParseConfig(value string) (*Config, error) {
+	var config Config
+	if err := json.Unmarshal([]byte(value), &config); err != nil {
+		return nil, fmt.Errorf("invalid rate limit config: %w", err)
+	}
+	classes := map[string]bool{DefaultRouteClass: true}
+	for class := range DefaultRouteClasses {
+		classes[class] = true
+	}
+	for class, routes := range config.RouteClasses {
+		if class == "" || class == AllRouteClasses || class == DefaultRouteClass {
+			return nil, fmt.Errorf("invalid route class name %q", class)
+		}
+		for _, r := range routes {
+			if _, err := parseRoute(class, r); err != nil {
+				return nil, err
+			}
+		}
+		classes[class] = true
+	}
+	for i, rule := range config.Limits {
+		if !classes[rule.RouteClass] && rule.RouteClass != AllRouteClasses {
+			return nil, fmt.Errorf("limit %d: unknown route class %q", i, rule.RouteClass)
+		}
+		if rule.Role != "" && api.GetRole(string(rule.Role)) == "" {
+			return nil, fmt.Errorf("limit %d: invalid role %q", i, rule.Role)
+		}
+		if (rule.Role != "" && rule.UserID != "") || (rule.APIKeyID != 0 && (rule.Role != "" || rule.UserID != "")) {
+			return nil, fmt.Errorf("limit %d: only one of role, user_id and api_key_id can be set", i)
+		}
+		if rule.Unlimited {
+			continue
+		}
+		if rule.RequestsPerMinute <= 0 || rule.Burst < 0 {
+			return nil, fmt.Errorf("limit %d: requests_per_minute must be positive", i)
+		}
+		if rule.Burst == 0 {
+			config.Limits[i].Burst = max(1, int(rule.RequestsPerMinute))
+		}
+		if refill := time.Duration(float64(config.Limits[i].Burst) / rule.RequestsPerMinute * float64(time.Minute)); refill > maxRefillDuration {
+			return nil, fmt.Errorf("limit %d: a burst of %d takes longer than %s to refill", i, config.Limits[i].Burst, maxRefillDuration)
+		}
+	}
+	return &config, nil
+}
+
+func parseRoute(class, value string) (route, error) {
+	r := route{class: class}
+	method, pattern, ok := strings.Cut(strings.TrimSpace(value), " ")
+	if ok {
+		r.method = strings.ToUpper(method)
+		r.pattern = strings.TrimSpace(pattern)
+	} else {
+		r.pattern = method
+	}
+	if !strings.HasPrefix(r.pattern, "/") {
+		return r, fmt.Errorf("route class %s: invalid route %q, expected [METHOD] /path", class, value)
+	}
+	if _, err := path.Match(r.pattern, ""); err != nil {
+		return r, fmt.Errorf("route class %s: invalid path pattern %q: %w", class, r.pattern, err)
+	}
+	return r, nil
+}
+
+// --- Limiter ---
+
+// Identity is the caller a request is counted against.
+type Identity struct {
+	UserID   string
+	APIKeyID uint // Requests made with an API key are counted against the key
+	Role     api.Role
+}
+
+func (i Identity) typeLabel() string {
+	if i.APIKeyID != 0 {
+		return "api_key"
+	}
+	return "user"
+}
+
+func (i Identity) bucketKey(class string) string {
+	if i.APIKeyID != 0 {
+		return class + ":apikey:" + strconv.FormatUint(uint64(i.APIKeyID), 10)
+	}
+	return class + ":user:" + i.UserID
+}
+
+// Decision is the outcome of a rate limit check.
+type Decision struct {
+	Allowed    bool
+	RouteClass string
+	Limit      Limit         // Limit that applied, zero when the request is not limited
+	RetryAfter time.Duration // Time until the next request is allowed when denied
+}
+
+// Limiter applies a Config to requests, keeping its buckets in a Store.
+type Limiter struct {
+	routes []route
+	limits []LimitRule
+	store  Store
+}
+
+func NewLimiter(config *Config, store Store) (*Limiter, error) {
+	if store == nil {
+		return nil, errors.New("rate limit store cannot be nil")
+	}
+	classes := make(map[string][]string, len(DefaultRouteClasses)+len(config.RouteClasses))
+	for class, routes := range DefaultRouteClasses {
+		classes[class] = routes
+	}
+	for class, routes := range config.RouteClasses {
+		classes[class] = routes
+	}
+	// Routes are matched in the order of their class names, a request matching several classes gets the first one
+	names := make([]string, 0, len(classes))
+	for class := range classes {
+		names = append(names, class)
+	}
+	sort.Strings(names)
+	limiter := &Limiter{limits: config.Limits, store: store}
+	for _, class := range names {
+		for _, value := range classes[class] {
+			r, err := parseRoute(class, value)
+			if err != nil {
+				return nil, err
+			}
+			limiter.routes = append(limiter.routes, r)
+		}
+	}
+	return limiter, nil
+}
+
+// RouteClass returns the route class of a request, DefaultRouteClass when none matches.
+func (l *Limiter) RouteClass(method, requestPath string) string {
+	requestPath, _, _ = strings.Cut(requestPath, "?")
+	method = strings.ToUpper(method)
+	for _, r := range l.routes {
+		if r.method != "" && r.method != method {
+			continue
+		}
+		if matched, _ := path.Match(r.pattern, requestPath); matched {
+			return r.class
+		}
+	}
+	return DefaultRouteClass
+}
+
+// limitFor returns the most specific limit rule matching the identity and route class.
+func (l *Limiter) limitFor(identity Identity, class string) (LimitRule, bool) {
+	var best LimitRule
+	found := false
+	for _, rule := range l.limits {
+		if rule.RouteClass != class && rule.RouteClass != AllRouteClasses {
+			continue
+		}
+		if (rule.APIKeyID != 0 && rule.APIKeyID != identity.APIKeyID) ||
+			(rule.UserID != "" && (identity.APIKeyID != 0 || rule.UserID != identity.UserID)) ||
+			(rule.Role != "" && rule.Role != identity.Role) {
+			continue
+		}
+		if !found || rule.specificity() > best.specificity() {
+			best, found = rule, true
+		}
+	}
+	return best, found
+}
+
+// Allow takes a token from the bucket of the identity for the route class of the request.
+// Store errors let the request through, they are returned for logging.
+func (l *Limiter) Allow(ctx context.Context, identity Identity, method, requestPath string) (Decision, error) {
+	class := l.RouteClass(method, requestPath)
+	decision := Decision{Allowed: true, RouteClass: class}
+	rule, ok := l.limitFor(identity, class)
+	if !ok || rule.Unlimited {
+		return decision, nil
+	}
+	decision.Limit = rule.Limit
+
+	allowed, retryAfter, err := l.store.Take(ctx, identity.bucketKey(class), rule.Limit, time.Now())
+	if err != nil {
+		metricErrors.Inc()
+		return decision, err
+	}
+	if !allowed {
+		decision.Allowed = false
+		decision.RetryAfter = retryAfter
+		metricThrottled.WithLabelValues(class, string(identity.Role), identity.typeLabel()).Inc()
+	}
+	return decision, nil
+}
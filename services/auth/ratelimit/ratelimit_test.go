@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opengovern/og-util/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketTake(t *testing.T) {
+	limit := Limit{RequestsPerMinute: 60, Burst: 2}
+	now := time.Unix(1_700_000_000, 0)
+	var bucket bucketState
+
+	allowed, _ := bucket.take(limit, now)
+	assert.True(t, allowed)
+	allowed, _ = bucket.take(limit, now)
+	assert.True(t, allowed)
+	allowed, retryAfter := bucket.take(limit, now)
+	assert.False(t, allowed, "the burst is used up")
+	assert.Equal(t, time.Second, retryAfter)
+
+	// One token per second comes back, up to the burst
+	allowed, _ = bucket.take(limit, now.Add(time.Second))
+	assert.True(t, allowed)
+	allowed, _ = bucket.take(limit, now.Add(time.Second))
+	assert.False(t, allowed)
+	bucket.take(limit, now.Add(time.Hour))
+	assert.Equal(t, float64(limit.Burst-1), bucket.Tokens)
+
+	// A clock going backwards does not add tokens
+	allowed, _ = bucket.take(limit, now)
+	assert.True(t, allowed)
+	allowed, _ = bucket.take(limit, now)
+	assert.False(t, allowed)
+}
+
+func TestMemoryStoreKeys(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{RequestsPerMinute: 1, Burst: 1}
+	now := time.Now()
+
+	allowed, _, err := store.Take(context.Background(), "a", limit, now)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	allowed, _, _ = store.Take(context.Background(), "a", limit, now)
+	assert.False(t, allowed)
+	allowed, _, _ = store.Take(context.Background(), "b", limit, now)
+	assert.True(t, allowed, "buckets are per key")
+}
+
+func TestRouteClass(t *testing.T) {
+	limiter, err := NewLimiter(&Config{}, NewMemoryStore())
+	require.NoError(t, err)
+
+	assert.Equal(t, "query", limiter.RouteClass("POST", "/core/api/v1/query/run"))
+	assert.Equal(t, "query", limiter.RouteClass("post", "/core/api/v3/query/run?cache=false"))
+	assert.Equal(t, DefaultRouteClass, limiter.RouteClass("GET", "/core/api/v3/query/run"))
+	assert.Equal(t, "compliance", limiter.RouteClass("POST", "/compliance/api/v1/compliance_result"))
+	assert.Equal(t, "compliance", limiter.RouteClass("POST", "/compliance/api/v1/compliance_result/filters"))
+	assert.Equal(t, DefaultRouteClass, limiter.RouteClass("GET", "/integration/api/v1/integrations"))
+}
+
+func TestLimiterRuleSpecificity(t *testing.T) {
+	config, err := ParseConfig(`{"limits": [
+		{"route_class": "*", "requests_per_minute": 100},
+		{"route_class": "query", "role": "viewer", "requests_per_minute": 1},
+		{"route_class": "query", "user_id": "admin-user", "unlimited": true},
+		{"route_class": "*", "api_key_id": 7, "requests_per_minute": 2}
+	]}`)
+	require.NoError(t, err)
+	limiter, err := NewLimiter(config, NewMemoryStore())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	viewer := Identity{UserID: "viewer-user", Role: api.ViewerRole}
+	decision, err := limiter.Allow(ctx, viewer, "POST", "/core/api/v3/query/run")
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	decision, _ = limiter.Allow(ctx, viewer, "POST", "/core/api/v3/query/run")
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, "query", decision.RouteClass)
+	assert.Positive(t, decision.RetryAfter)
+
+	// Other route classes have their own bucket
+	decision, _ = limiter.Allow(ctx, viewer, "GET", "/core/api/v1/queries")
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, float64(100), decision.Limit.RequestsPerMinute)
+
+	admin := Identity{UserID: "admin-user", Role: api.AdminRole}
+	for i := 0; i < 5; i++ {
+		decision, _ = limiter.Allow(ctx, admin, "POST", "/core/api/v3/query/run")
+		assert.True(t, decision.Allowed)
+	}
+
+	// A key of the unlimited user is limited by its own rule, not the user's
+	key := Identity{UserID: "admin-user", APIKeyID: 7, Role: api.AdminRole}
+	for i := 0; i < 2; i++ {
+		decision, _ = limiter.Allow(ctx, key, "POST", "/core/api/v3/query/run")
+		assert.True(t, decision.Allowed)
+	}
+	decision, _ = limiter.Allow(ctx, key, "POST", "/core/api/v3/query/run")
+	assert.False(t, decision.Allowed)
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	for _, value := range []string{
+		`{"limits": [{"route_class": "unknown", "requests_per_minute": 1}]}`,
+		`{"limits": [{"route_class": "*", "requests_per_minute": 0}]}`,
+		`{"limits": [{"route_class": "*", "role": "viewer", "user_id": "u", "requests_per_minute": 1}]}`,
+		`{"limits": [{"route_class": "*", "requests_per_minute": 1, "burst": 100}]}`,
+		`{"route_classes": {"bad": ["query/run"]}, "limits": []}`,
+	} {
+		_, err := ParseConfig(value)
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Store keeps the token buckets.
+type Store interface {
+	// Take removes a token from the bucket at key if one is left. When none is, it returns
+	// the time until the bucket holds one again.
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (bool, time.Duration, error)
+}
+
+// bucketState is a token bucket, refilled lazily when a token is taken.
+type bucketState struct {
+	Tokens    float64 `json:"t"`
+	UpdatedAt int64   `json:"u"` // Unix nanoseconds, zero for a new full bucket
+}
+
+// take refills the bucket for the time elapsed since its last update and removes a token if one is left.
+// A denied take leaves the state unchanged, it does not need to be stored.
+func (b *bucketState) take(limit Limit, now time.Time) (bool, time.Duration) {
+	capacity := float64(limit.Burst)
+	perSecond := limit.RequestsPerMinute / 60
+	tokens := capacity
+	if b.UpdatedAt != 0 {
+		elapsed := now.Sub(time.Unix(0, b.UpdatedAt)).Seconds()
+		tokens = math.Min(capacity, b.Tokens+math.Max(0, elapsed)*perSecond)
+	}
+	if tokens < 1 {
+		return false, time.Duration((1 - tokens) / perSecond * float64(time.Second))
+	}
+	b.Tokens = tokens - 1
+	b.UpdatedAt = now.UnixNano()
+	return true, 0
+}
+
+// --- In-memory store ---
+
+// memoryStore keeps the buckets of a single replica.
+type memoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucketState
+	lastSweep time.Time
+}
+
+// NewMemoryStore returns a Store local to the process, each replica then limits its own share of the requests.
+func NewMemoryStore() Store {
+	return &memoryStore{buckets: make(map[string]*bucketState)}
+}
+
+func (s *memoryStore) Take(_ context.Context, key string, limit Limit, now time.Time) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Buckets idle for longer than any refill are full again, forgetting them changes nothing
+	if now.Sub(s.lastSweep) > maxRefillDuration {
+		for k, b := range s.buckets {
+			if now.Sub(time.Unix(0, b.UpdatedAt)) > maxRefillDuration {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &bucketState{}
+	}
+	allowed, retryAfter := bucket.take(limit, now)
+	if allowed && !ok {
+		s.buckets[key] = bucket
+	}
+	return allowed, retryAfter, nil
+}
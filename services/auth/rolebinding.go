@@ -0,0 +1,294 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	api2 "github.com/opengovern/og-util/pkg/api"
+	"github.com/opengovern/og-util/pkg/httpclient"
+	"github.com/opengovern/og-util/pkg/httpserver"
+	"github.com/opengovern/opensecurity/services/auth/api"
+	"github.com/opengovern/opensecurity/services/auth/authcache"
+	"github.com/opengovern/opensecurity/services/auth/db"
+	integrationApi "github.com/opengovern/opensecurity/services/integration/api/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// noIntegrationsScope is propagated for subjects whose bindings currently match no integration.
+// It is not a valid integration ID, so services filtering by the scope return nothing.
+const noIntegrationsScope = "none"
+
+// --- Scope resolution ---
+
+// integrationScopeHeader returns the value of the connections scope header for a request,
+// empty if the caller can access every integration.
+// Requests made with an API key use the key's bindings, or if the key has none the bindings of
+// its service account, or of its creator for keys of users.
+func (s *Server) integrationScopeHeader(ctx context.Context, userExternalID string, apiKey *authcache.CachedAPIKey) (string, error) {
+	var scope *authcache.CachedIntegrationScope
+	var err error
+	if apiKey != nil {
+		scope, err = s.subjectIntegrationScope(ctx, db.RoleBindingSubjectAPIKey, strconv.FormatUint(uint64(apiKey.ID), 10))
+		if err != nil {
+			return "", err
+		}
+		if !scope.HasBindings {
+			if apiKey.ServiceAccountID != 0 {
+				scope, err = s.subjectIntegrationScope(ctx, db.RoleBindingSubjectServiceAccount, strconv.FormatUint(uint64(apiKey.ServiceAccountID), 10))
+			} else {
+				scope, err = s.subjectIntegrationScope(ctx, db.RoleBindingSubjectUser, apiKey.CreatorUserID)
+			}
+		}
+	} else {
+		scope, err = s.subjectIntegrationScope(ctx, db.RoleBindingSubjectUser, userExternalID)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if !scope.HasBindings {
+		return "", nil
+	}
+	if len(scope.IntegrationIDs) == 0 {
+		return noIntegrationsScope, nil
+	}
+	return strings.Join(scope.IntegrationIDs, ","), nil
+}
+
+// subjectIntegrationScope loads the scope of a subject from the auth cache, resolving its bindings on a miss.
+func (s *Server) subjectIntegrationScope(ctx context.Context, subjectType, subjectID string) (*authcache.CachedIntegrationScope, error) {
+	scope, err := s.authCache.GetIntegrationScope(ctx, subjectType, subjectID)
+	if err == nil {
+		return scope, nil
+	}
+	if !errors.Is(err, authcache.ErrIntegrationScopeNotFound) {
+		s.logger.Error("Integration scope cache error, resolving bindings", zap.Error(err))
+	}
+
+	bindings, err := s.db.ListRoleBindings(subjectType, subjectID)
+	if err != nil {
+		return nil, err
+	}
+	scope = &authcache.CachedIntegrationScope{HasBindings: len(bindings) > 0}
+	if scope.HasBindings {
+		scope.IntegrationIDs, err = s.resolveRoleBindings(ctx, bindings)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.authCache.SetIntegrationScope(ctx, subjectType, subjectID, scope); err != nil {
+		s.logger.Error("Failed to populate integration scope cache", zap.String("subjectType", subjectType), zap.String("subjectId", subjectID), zap.Error(err))
+	}
+	return scope, nil
+}
+
+// resolveRoleBindings returns the union of the integrations matched by the bindings.
+func (s *Server) resolveRoleBindings(ctx context.Context, bindings []db.RoleBinding) ([]string, error) {
+	ids := make(map[string]bool)
+	clientCtx := &httpclient.Context{Ctx: ctx, UserRole: api2.AdminRole}
+	for _, binding := range bindings {
+		for _, id := range binding.IntegrationIDs {
+			ids[id] = true
+		}
+		if len(binding.IntegrationGroups) == 0 && len(binding.LabelSelectors) == 0 {
+			continue
+		}
+		if s.integrationClient == nil {
+			return nil, fmt.Errorf("role binding %d needs the integration service, INTEGRATION_BASE_URL is not set", binding.ID)
+		}
+		for _, groupName := range binding.IntegrationGroups {
+			group, err := s.integrationClient.GetIntegrationGroup(clientCtx, groupName)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve integration group %s: %w", groupName, err)
+			}
+			for _, id := range group.IntegrationIds {
+				ids[id] = true
+			}
+		}
+		for _, selector := range binding.LabelSelectors {
+			if len(selector) == 0 {
+				continue // An empty selector would match every integration
+			}
+			res, err := s.integrationClient.ListIntegrationsByFilters(clientCtx, integrationApi.ListIntegrationsRequest{Labels: selector})
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve label selector of role binding %d: %w", binding.ID, err)
+			}
+			for _, integration := range res.Integrations {
+				ids[integration.IntegrationID] = true
+			}
+		}
+	}
+
+	result := make([]string, 0, len(ids))
+	for id := range ids {
+		result = append(result, id)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// --- HTTP handlers ---
+
+func roleBindingToAPI(binding db.RoleBinding) api.RoleBindingResponse {
+	return api.RoleBindingResponse{
+		ID:                binding.ID,
+		SubjectType:       api.RoleBindingSubjectType(binding.SubjectType),
+		SubjectID:         binding.SubjectID,
+		IntegrationIDs:    binding.IntegrationIDs,
+		IntegrationGroups: binding.IntegrationGroups,
+		LabelSelectors:    binding.LabelSelectors,
+		Description:       binding.Description,
+		CreatedBy:         binding.CreatedBy,
+		CreatedAt:         binding.CreatedAt,
+	}
+}
+
+// ListRoleBindings lists role bindings, optionally filtered by subject_type and subject_id query params.
+func (r *httpRoutes) ListRoleBindings(ctx echo.Context) error {
+	bindings, err := r.db.ListRoleBindings(ctx.QueryParam("subject_type"), ctx.QueryParam("subject_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list role bindings")
+	}
+
+	resp := make([]api.RoleBindingResponse, 0, len(bindings))
+	for _, binding := range bindings {
+		resp = append(resp, roleBindingToAPI(binding))
+	}
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+// CreateRoleBinding limits a user, API key or service account to a set of integrations.
+func (r *httpRoutes) CreateRoleBinding(ctx echo.Context) error {
+	var req api.CreateRoleBindingRequest
+	if err := bindValidate(ctx, &req); err != nil {
+		return err
+	}
+
+	req.SubjectID = strings.TrimSpace(req.SubjectID)
+	if req.SubjectID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "subject_id is required")
+	}
+	switch req.SubjectType {
+	case api.RoleBindingSubjectUser:
+		user, err := r.db.GetUserByExternalID(req.SubjectID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve user data")
+		}
+		if user == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "User not found")
+		}
+	case api.RoleBindingSubjectAPIKey:
+		keyID, err := strconv.ParseUint(req.SubjectID, 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid API key ID format")
+		}
+		if _, err := r.db.GetApiKey(keyID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.NewHTTPError(http.StatusNotFound, "API key not found")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve API key")
+		}
+	case api.RoleBindingSubjectServiceAccount:
+		accountID, err := strconv.ParseUint(req.SubjectID, 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid service account ID format")
+		}
+		if _, err := r.db.GetServiceAccount(accountID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.NewHTTPError(http.StatusNotFound, "Service account not found")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve service account")
+		}
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "subject_type should be user, api_key or service_account")
+	}
+
+	for _, id := range req.IntegrationIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid integration id: "+id)
+		}
+	}
+	var selectors []map[string]string
+	for _, selector := range req.LabelSelectors {
+		if len(selector) > 0 {
+			selectors = append(selectors, selector)
+		}
+	}
+	if len(req.IntegrationIDs) == 0 && len(req.IntegrationGroups) == 0 && len(selectors) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "at least one of integration_ids, integration_groups or label_selectors is required")
+	}
+
+	binding := db.RoleBinding{
+		SubjectType:       string(req.SubjectType),
+		SubjectID:         req.SubjectID,
+		IntegrationIDs:    req.IntegrationIDs,
+		IntegrationGroups: req.IntegrationGroups,
+		LabelSelectors:    selectors,
+		Description:       req.Description,
+		CreatedBy:         httpserver.GetUserID(ctx),
+	}
+	if err := r.db.CreateRoleBinding(&binding); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create role binding")
+	}
+	_ = r.authCache.RemoveIntegrationScope(ctx.Request().Context(), binding.SubjectType, binding.SubjectID)
+
+	return ctx.JSON(http.StatusCreated, roleBindingToAPI(binding))
+}
+
+// DeleteRoleBinding removes a role binding, a subject left without bindings can access every integration.
+func (r *httpRoutes) DeleteRoleBinding(ctx echo.Context) error {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid role binding ID format")
+	}
+
+	binding, err := r.db.GetRoleBinding(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Role binding not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete role binding")
+	}
+	if err := r.db.DeleteRoleBinding(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Role binding not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete role binding")
+	}
+	_ = r.authCache.RemoveIntegrationScope(ctx.Request().Context(), binding.SubjectType, binding.SubjectID)
+
+	return ctx.NoContent(http.StatusAccepted)
+}
+
+// GetIntegrationScope returns the integrations a subject's bindings currently resolve to.
+func (r *httpRoutes) GetIntegrationScope(ctx echo.Context) error {
+	subjectType := ctx.QueryParam("subject_type")
+	subjectID := ctx.QueryParam("subject_id")
+	switch subjectType {
+	case db.RoleBindingSubjectUser, db.RoleBindingSubjectAPIKey, db.RoleBindingSubjectServiceAccount:
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "subject_type should be user, api_key or service_account")
+	}
+	if subjectID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "subject_id is required")
+	}
+
+	scope, err := r.authServer.subjectIntegrationScope(ctx.Request().Context(), subjectType, subjectID)
+	if err != nil {
+		r.logger.Error("Failed to resolve integration scope", zap.String("subjectType", subjectType), zap.String("subjectId", subjectID), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to resolve integration scope")
+	}
+	return ctx.JSON(http.StatusOK, api.IntegrationScopeResponse{
+		Restricted:     scope.HasBindings,
+		IntegrationIDs: scope.IntegrationIDs,
+	})
+}
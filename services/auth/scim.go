@@ -0,0 +1,1122 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	api2 "github.com/opengovern/og-util/pkg/api"
+	"github.com/opengovern/opensecurity/services/auth/api"
+	"github.com/opengovern/opensecurity/services/auth/db"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	scimContentType     = "application/scim+json"
+	scimBasePath        = "/scim/v2"
+	defaultScimPageSize = 100
+	maxScimPageSize     = 1000
+)
+
+// scimFilterRegex matches the only filter form identity providers use for lookups: <attribute> eq "<value>"
+var scimFilterRegex = regexp.MustCompile(`^\s*([A-Za-z.]+)\s+(?i:eq)\s+"((?:[^"\\]|\\.)*)"\s*$`)
+
+// scimMemberFilterRegex matches the members[value eq "<id>"] path of group patches
+var scimMemberFilterRegex = regexp.MustCompile(`^members\[\s*value\s+(?i:eq)\s+"([^"]*)"\s*\]$`)
+
+// roleRank orders roles so a user in several groups gets the highest of their roles
+var roleRank = map[api2.Role]int{
+	api2.ViewerRole: 1,
+	api2.EditorRole: 2,
+	api2.AdminRole:  3,
+}
+
+// parseScimGroupRoles parses a comma separated list of <group display name>=<role>.
+func parseScimGroupRoles(value string) (map[string]api2.Role, error) {
+	roles := make(map[string]api2.Role)
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, roleName, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		role := api2.GetRole(roleName)
+		if !ok || name == "" || role == "" {
+			return nil, fmt.Errorf("invalid SCIM group role mapping %q, expected <group>=<admin|editor|viewer>", entry)
+		}
+		roles[strings.ToLower(name)] = role
+	}
+	return roles, nil
+}
+
+// scimGroupRole returns the role granted to members of a group, empty if the group grants none.
+// Only groups listed in SCIM_GROUP_ROLE_MAPPING grant a role, whatever their name.
+func (r *httpRoutes) scimGroupRole(displayName string) api2.Role {
+	return r.scimGroupRoles[strings.ToLower(strings.TrimSpace(displayName))]
+}
+
+// registerSCIM registers the SCIM 2.0 endpoints, they are only served when a SCIM token is configured.
+func (r *httpRoutes) registerSCIM(e *echo.Echo) {
+	if len(r.scimTokenHash) == 0 {
+		return
+	}
+	scim := e.Group(scimBasePath, r.scimAuthenticate)
+
+	scim.GET("/ServiceProviderConfig", r.ScimServiceProviderConfig)
+	scim.GET("/ResourceTypes", r.ScimResourceTypes)
+
+	scim.GET("/Users", r.ScimListUsers)
+	scim.POST("/Users", r.ScimCreateUser)
+	scim.GET("/Users/:id", r.ScimGetUser)
+	scim.PUT("/Users/:id", r.ScimReplaceUser)
+	scim.PATCH("/Users/:id", r.ScimPatchUser)
+	scim.DELETE("/Users/:id", r.ScimDeleteUser)
+
+	scim.GET("/Groups", r.ScimListGroups)
+	scim.POST("/Groups", r.ScimCreateGroup)
+	scim.GET("/Groups/:id", r.ScimGetGroup)
+	scim.PUT("/Groups/:id", r.ScimReplaceGroup)
+	scim.PATCH("/Groups/:id", r.ScimPatchGroup)
+	scim.DELETE("/Groups/:id", r.ScimDeleteGroup)
+}
+
+// scimAuthenticate checks the dedicated SCIM bearer token and records provisioning changes in the audit log.
+func (r *httpRoutes) scimAuthenticate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		req := ctx.Request()
+		event := db.AuditEvent{
+			ActorID:   "scim",
+			Method:    req.Method,
+			RequestID: req.Header.Get(echo.HeaderXRequestID),
+			SourceIP:  ctx.RealIP(),
+		}
+		event.Path, event.TargetIDs = auditPathAndTargets(req.URL.RequestURI())
+
+		token, ok := strings.CutPrefix(req.Header.Get(echo.HeaderAuthorization), "Bearer ")
+		hash := sha256.Sum256([]byte(strings.TrimSpace(token)))
+		if !ok || subtle.ConstantTimeCompare(hash[:], r.scimTokenHash) != 1 {
+			r.logger.Warn("SCIM request with an invalid token", zap.String("path", req.URL.Path), zap.String("ip", ctx.RealIP()))
+			event.Timestamp = time.Now()
+			event.EventType = db.AuditEventTypeAuthDenied
+			event.Decision = db.AuditDecisionDenied
+			event.Reason = "invalid SCIM token"
+			r.authServer.queueAuditEvent(event)
+			return writeScimError(ctx, scimError(http.StatusUnauthorized, "", "Invalid SCIM bearer token"))
+		}
+
+		if err := next(ctx); err != nil {
+			return writeScimError(ctx, err)
+		}
+		if isMutatingMethod(req.Method) && ctx.Response().Status < http.StatusBadRequest {
+			event.Timestamp = time.Now()
+			event.EventType = db.AuditEventTypeSCIM
+			event.Decision = db.AuditDecisionAllowed
+			r.authServer.queueAuditEvent(event)
+		}
+		return nil
+	}
+}
+
+// --- Helpers ---
+
+func scimJSON(ctx echo.Context, status int, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ctx.Blob(status, scimContentType, body)
+}
+
+// scimHTTPError is an error returned by SCIM handlers, rendered in the SCIM error format by scimAuthenticate.
+type scimHTTPError struct {
+	status   int
+	scimType string
+	detail   string
+}
+
+func (e *scimHTTPError) Error() string {
+	return fmt.Sprintf("scim error %d: %s", e.status, e.detail)
+}
+
+func scimError(status int, scimType, detail string) error {
+	return &scimHTTPError{status: status, scimType: scimType, detail: detail}
+}
+
+// writeScimError renders an error returned by a SCIM handler, including the echo errors of the shared user handlers.
+func writeScimError(ctx echo.Context, err error) error {
+	var scimErr *scimHTTPError
+	var httpErr *echo.HTTPError
+	switch {
+	case errors.As(err, &scimErr):
+	case errors.As(err, &httpErr):
+		scimErr = &scimHTTPError{status: httpErr.Code, detail: fmt.Sprint(httpErr.Message)}
+		if httpErr.Code == http.StatusConflict {
+			scimErr.scimType = "uniqueness"
+		}
+	default:
+		return err
+	}
+	return scimJSON(ctx, scimErr.status, api.ScimError{
+		Schemas:  []string{api.ScimSchemaError},
+		Status:   strconv.Itoa(scimErr.status),
+		ScimType: scimErr.scimType,
+		Detail:   scimErr.detail,
+	})
+}
+
+// decodeScim decodes a SCIM body, echo's binder does not accept the application/scim+json content type.
+func decodeScim(ctx echo.Context, v interface{}) error {
+	if err := json.NewDecoder(ctx.Request().Body).Decode(v); err != nil {
+		return scimError(http.StatusBadRequest, "invalidSyntax", "Invalid request body: "+err.Error())
+	}
+	return nil
+}
+
+// scimPagination reads the 1-based startIndex and count query params.
+func scimPagination(ctx echo.Context) (startIndex, count int, err error) {
+	startIndex, count = 1, defaultScimPageSize
+	if value := ctx.QueryParam("startIndex"); value != "" {
+		startIndex, err = strconv.Atoi(value)
+		if err != nil {
+			return 0, 0, scimError(http.StatusBadRequest, "invalidValue", "startIndex should be a number")
+		}
+		if startIndex < 1 {
+			startIndex = 1
+		}
+	}
+	if value := ctx.QueryParam("count"); value != "" {
+		count, err = strconv.Atoi(value)
+		if err != nil {
+			return 0, 0, scimError(http.StatusBadRequest, "invalidValue", "count should be a number")
+		}
+		if count < 0 {
+			count = 0
+		}
+		if count > maxScimPageSize {
+			count = maxScimPageSize
+		}
+	}
+	return startIndex, count, nil
+}
+
+// scimFilter parses the filter query param, it returns an empty attribute when there is no filter.
+func scimFilter(ctx echo.Context) (attribute, value string, err error) {
+	filter := ctx.QueryParam("filter")
+	if filter == "" {
+		return "", "", nil
+	}
+	match := scimFilterRegex.FindStringSubmatch(filter)
+	if match == nil {
+		return "", "", scimError(http.StatusBadRequest, "invalidFilter", "Only filters of the form <attribute> eq \"<value>\" are supported")
+	}
+	value, err = strconv.Unquote(`"` + match[2] + `"`)
+	if err != nil {
+		return "", "", scimError(http.StatusBadRequest, "invalidFilter", "Invalid filter value")
+	}
+	return strings.ToLower(match[1]), value, nil
+}
+
+func scimLocation(ctx echo.Context, resource, id string) string {
+	return fmt.Sprintf("%s://%s%s/%s/%s", ctx.Scheme(), ctx.Request().Host, scimBasePath, resource, id)
+}
+
+func scimListResponse(resources []interface{}, total int64, startIndex int) api.ScimListResponse {
+	return api.ScimListResponse{
+		Schemas:      []string{api.ScimSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// --- Discovery ---
+
+func (r *httpRoutes) ScimServiceProviderConfig(ctx echo.Context) error {
+	supported := func(v bool) map[string]bool { return map[string]bool{"supported": v} }
+	return scimJSON(ctx, http.StatusOK, map[string]interface{}{
+		"schemas":        []string{api.ScimSchemaServiceProviderConfig},
+		"patch":          supported(true),
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": maxScimPageSize},
+		"changePassword": supported(false),
+		"sort":           supported(false),
+		"etag":           supported(false),
+		"authenticationSchemes": []map[string]interface{}{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer Token",
+			"description": "Dedicated SCIM bearer token",
+			"primary":     true,
+		}},
+	})
+}
+
+func (r *httpRoutes) ScimResourceTypes(ctx echo.Context) error {
+	resources := []interface{}{
+		map[string]interface{}{
+			"schemas":  []string{api.ScimSchemaResourceType},
+			"id":       "User",
+			"name":     "User",
+			"endpoint": "/Users",
+			"schema":   api.ScimSchemaUser,
+		},
+		map[string]interface{}{
+			"schemas":  []string{api.ScimSchemaResourceType},
+			"id":       "Group",
+			"name":     "Group",
+			"endpoint": "/Groups",
+			"schema":   api.ScimSchemaGroup,
+		},
+	}
+	return scimJSON(ctx, http.StatusOK, scimListResponse(resources, int64(len(resources)), 1))
+}
+
+// --- Users ---
+
+func (r *httpRoutes) scimUserFromDB(ctx echo.Context, user db.User, groups []db.ScimGroup) api.ScimUser {
+	id := strconv.FormatUint(uint64(user.ID), 10)
+	active := user.IsActive
+	resp := api.ScimUser{
+		Schemas:     []string{api.ScimSchemaUser},
+		ID:          id,
+		UserName:    user.Email,
+		Name:        &api.ScimName{Formatted: user.FullName},
+		DisplayName: user.FullName,
+		Emails:      []api.ScimEmail{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta: &api.ScimMeta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Location:     scimLocation(ctx, "Users", id),
+		},
+	}
+	for _, group := range groups {
+		resp.Groups = append(resp.Groups, api.ScimReference{
+			Value:   group.ID,
+			Ref:     scimLocation(ctx, "Groups", group.ID),
+			Display: group.DisplayName,
+		})
+	}
+	return resp
+}
+
+// scimUserEmail returns the email of a SCIM user, its userName or else its primary email.
+func scimUserEmail(user api.ScimUser) (string, error) {
+	candidates := []string{user.UserName}
+	for _, email := range user.Emails {
+		if email.Primary {
+			candidates = append(candidates, email.Value)
+		}
+	}
+	if len(user.Emails) > 0 {
+		candidates = append(candidates, user.Emails[0].Value)
+	}
+	for _, candidate := range candidates {
+		email := strings.ToLower(strings.TrimSpace(candidate))
+		if email == "" {
+			continue
+		}
+		if _, err := mail.ParseAddress(email); err == nil {
+			return email, nil
+		}
+	}
+	return "", errors.New("userName or a primary email should be a valid email address")
+}
+
+// scimUserFullName returns the name to store for a SCIM user, empty if it has none.
+func scimUserFullName(user api.ScimUser) string {
+	if user.Name != nil {
+		if user.Name.Formatted != "" {
+			return user.Name.Formatted
+		}
+		if name := strings.TrimSpace(user.Name.GivenName + " " + user.Name.FamilyName); name != "" {
+			return name
+		}
+	}
+	return user.DisplayName
+}
+
+// getScimUser loads the user of the id path param.
+func (r *httpRoutes) getScimUser(ctx echo.Context) (*db.User, error) {
+	id := ctx.Param("id")
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return nil, scimError(http.StatusNotFound, "", "User not found")
+	}
+	user, err := r.db.GetUser(id)
+	if err != nil {
+		return nil, scimError(http.StatusInternalServerError, "", "Failed to retrieve user")
+	}
+	if user == nil {
+		return nil, scimError(http.StatusNotFound, "", "User not found")
+	}
+	return user, nil
+}
+
+func (r *httpRoutes) respondScimUser(ctx echo.Context, status int, user db.User) error {
+	groups, err := r.db.ListScimGroupsOfUser(user.ID)
+	if err != nil {
+		return scimError(http.StatusInternalServerError, "", "Failed to retrieve user groups")
+	}
+	resp := r.scimUserFromDB(ctx, user, groups)
+	if status == http.StatusCreated {
+		ctx.Response().Header().Set(echo.HeaderLocation, resp.Meta.Location)
+	}
+	return scimJSON(ctx, status, resp)
+}
+
+// ScimListUsers lists users, filtering on userName, emails.value or id.
+func (r *httpRoutes) ScimListUsers(ctx echo.Context) error {
+	startIndex, count, err := scimPagination(ctx)
+	if err != nil {
+		return err
+	}
+	attribute, value, err := scimFilter(ctx)
+	if err != nil {
+		return err
+	}
+
+	var users []db.User
+	var total int64
+	switch attribute {
+	case "":
+		users, total, err = r.db.ListUsersPaged("", "", startIndex-1, count)
+	case "username", "emails.value", "emails":
+		users, total, err = r.db.ListUsersPaged("email", strings.ToLower(strings.TrimSpace(value)), startIndex-1, count)
+	case "id":
+		var user *db.User
+		if _, parseErr := strconv.ParseUint(value, 10, 64); parseErr == nil {
+			user, err = r.db.GetUser(value)
+		}
+		if user != nil && startIndex == 1 && count > 0 {
+			users, total = []db.User{*user}, 1
+		} else if user != nil {
+			total = 1
+		}
+	default:
+		return scimError(http.StatusBadRequest, "invalidFilter", "Unsupported filter attribute: "+attribute)
+	}
+	if err != nil {
+		return scimError(http.StatusInternalServerError, "", "Failed to list users")
+	}
+
+	resources := make([]interface{}, 0, len(users))
+	for _, user := range users {
+		groups, err := r.db.ListScimGroupsOfUser(user.ID)
+		if err != nil {
+			return scimError(http.StatusInternalServerError, "", "Failed to retrieve user groups")
+		}
+		resources = append(resources, r.scimUserFromDB(ctx, user, groups))
+	}
+	return scimJSON(ctx, http.StatusOK, scimListResponse(resources, total, startIndex))
+}
+
+func (r *httpRoutes) ScimGetUser(ctx echo.Context) error {
+	user, err := r.getScimUser(ctx)
+	if err != nil {
+		return err
+	}
+	return r.respondScimUser(ctx, http.StatusOK, *user)
+}
+
+// ScimCreateUser provisions a user signing in through the SCIM connector, with the viewer role until
+// it is added to a group mapped to a role.
+func (r *httpRoutes) ScimCreateUser(ctx echo.Context) error {
+	var req api.ScimUser
+	if err := decodeScim(ctx, &req); err != nil {
+		return err
+	}
+	email, err := scimUserEmail(req)
+	if err != nil {
+		return scimError(http.StatusBadRequest, "invalidValue", err.Error())
+	}
+
+	existing, err := r.db.GetUserByEmail(email)
+	if err != nil {
+		return scimError(http.StatusInternalServerError, "", "Failed to check user existence")
+	}
+	if existing != nil {
+		return scimError(http.StatusConflict, "uniqueness", "A user with this userName already exists")
+	}
+
+	fullName := scimUserFullName(req)
+	if fullName == "" {
+		fullName = email
+	}
+	user := db.User{
+		Email:                 email,
+		EmailVerified:         true, // Verified by the identity provider
+		Username:              email,
+		FullName:              fullName,
+		Role:                  api2.ViewerRole,
+		ConnectorId:           r.scimConnectorID,
+		ExternalId:            fmt.Sprintf("%s|%s", r.scimConnectorID, email),
+		RequirePasswordChange: false, // Users of the SCIM connector have no local password
+		IsActive:              req.Active == nil || *req.Active,
+	}
+	if err := r.db.CreateUser(&user); err != nil {
+		return scimError(http.StatusInternalServerError, "", "Failed to save user data")
+	}
+	r.logger.Info("Provisioned user over SCIM", zap.String("email", email), zap.Uint("id", user.ID))
+
+	return r.respondScimUser(ctx, http.StatusCreated, user)
+}
+
+// applyScimUser updates a user from its SCIM representation.
+// Deactivated users are removed from the auth cache so their next request is denied.
+func (r *httpRoutes) applyScimUser(ctx echo.Context, user *db.User, req api.ScimUser) error {
+	email, err := scimUserEmail(req)
+	if err != nil {
+		return scimError(http.StatusBadRequest, "invalidValue", err.Error())
+	}
+
+	updateData := make(map[string]interface{})
+	newExternalID := user.ExternalId
+	if email != user.Email {
+		other, err := r.db.GetUserByEmail(email)
+		if err != nil {
+			return scimError(http.StatusInternalServerError, "", "Failed to check user existence")
+		}
+		if other != nil {
+			return scimError(http.StatusConflict, "uniqueness", "A user with this userName already exists")
+		}
+		updateData["email"] = email
+		updateData["username"] = email
+		if user.ConnectorId != "local" {
+			newExternalID = fmt.Sprintf("%s|%s", user.ConnectorId, email)
+			updateData["external_id"] = newExternalID
+		}
+	}
+	if fullName := scimUserFullName(req); fullName != "" && fullName != user.FullName {
+		updateData["full_name"] = fullName
+	}
+	if req.Active != nil && *req.Active != user.IsActive {
+		if !*req.Active && user.ID == 1 {
+			return scimError(http.StatusBadRequest, "mutability", "Cannot deactivate the primary admin user")
+		}
+		updateData["is_active"] = *req.Active
+	}
+	if len(updateData) == 0 {
+		return nil
+	}
+
+	if err := r.db.Orm.Model(&db.User{}).Where("id = ?", user.ID).Updates(updateData).Error; err != nil {
+		r.logger.Error("Failed to update user over SCIM", zap.Uint("id", user.ID), zap.Error(err))
+		return scimError(http.StatusInternalServerError, "", "Failed to update user data")
+	}
+	r.logger.Info("Updated user over SCIM", zap.Uint("id", user.ID), zap.Any("changes", updateData))
+
+	if err := r.authCache.RemoveUserFromCache(ctx.Request().Context(), user.Email); err != nil {
+		r.logger.Error("Failed to invalidate user cache after SCIM update", zap.String("email", user.Email), zap.Error(err))
+	}
+	if active, ok := updateData["is_active"].(bool); ok && !active {
+		if _, err := r.authServer.revokeSessions(ctx.Request().Context(), newExternalID, "scim", sessionRevocationDeactivated); err != nil {
+			r.logger.Error("Failed to revoke sessions of user deactivated over SCIM", zap.Uint("id", user.ID), zap.Error(err))
+		}
+	}
+	if newExternalID != user.ExternalId {
+		if err := r.db.RenameRoleBindingSubject(db.RoleBindingSubjectUser, user.ExternalId, newExternalID); err == nil {
+			_ = r.authCache.RemoveIntegrationScope(ctx.Request().Context(), db.RoleBindingSubjectUser, user.ExternalId)
+		}
+	}
+
+	updated, err := r.db.GetUser(strconv.FormatUint(uint64(user.ID), 10))
+	if err != nil || updated == nil {
+		return scimError(http.StatusInternalServerError, "", "Failed to retrieve user")
+	}
+	*user = *updated
+	return nil
+}
+
+// ScimReplaceUser replaces the attributes of a user, deactivating it when active is false.
+func (r *httpRoutes) ScimReplaceUser(ctx echo.Context) error {
+	user, err := r.getScimUser(ctx)
+	if err != nil {
+		return err
+	}
+	var req api.ScimUser
+	if err := decodeScim(ctx, &req); err != nil {
+		return err
+	}
+	if req.Active == nil {
+		active := true
+		req.Active = &active
+	}
+	if err := r.applyScimUser(ctx, user, req); err != nil {
+		return err
+	}
+	return r.respondScimUser(ctx, http.StatusOK, *user)
+}
+
+// ScimPatchUser applies SCIM patch operations, mostly used by identity providers to toggle active.
+func (r *httpRoutes) ScimPatchUser(ctx echo.Context) error {
+	user, err := r.getScimUser(ctx)
+	if err != nil {
+		return err
+	}
+	var req api.ScimPatchRequest
+	if err := decodeScim(ctx, &req); err != nil {
+		return err
+	}
+
+	// Patches are applied on the JSON representation of the user, then saved like a replace
+	current := r.scimUserFromDB(ctx, *user, nil)
+	current.Meta = nil
+	raw, err := json.Marshal(current)
+	if err != nil {
+		return scimError(http.StatusInternalServerError, "", "Failed to patch user")
+	}
+	attributes := make(map[string]interface{})
+	if err := json.Unmarshal(raw, &attributes); err != nil {
+		return scimError(http.StatusInternalServerError, "", "Failed to patch user")
+	}
+
+	for _, op := range req.Operations {
+		if err := applyScimUserPatch(attributes, op); err != nil {
+			return scimError(http.StatusBadRequest, "invalidValue", err.Error())
+		}
+	}
+	// Some identity providers send booleans as strings
+	if active, ok := attributes["active"].(string); ok {
+		parsed, err := strconv.ParseBool(strings.ToLower(active))
+		if err != nil {
+			return scimError(http.StatusBadRequest, "invalidValue", "active should be a boolean")
+		}
+		attributes["active"] = parsed
+	}
+
+	raw, err = json.Marshal(attributes)
+	if err != nil {
+		return scimError(http.StatusInternalServerError, "", "Failed to patch user")
+	}
+	var patched api.ScimUser
+	if err := json.Unmarshal(raw, &patched); err != nil {
+		return scimError(http.StatusBadRequest, "invalidValue", "Invalid patched user: "+err.Error())
+	}
+	if err := r.applyScimUser(ctx, user, patched); err != nil {
+		return err
+	}
+	return r.respondScimUser(ctx, http.StatusOK, *user)
+}
+
+// applyScimUserPatch applies a patch operation on the attributes of a user.
+func applyScimUserPatch(attributes map[string]interface{}, op api.ScimPatchOperation) error {
+	var value interface{}
+	if len(op.Value) > 0 {
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", op.Path, err)
+		}
+	}
+	path := strings.TrimPrefix(op.Path, api.ScimSchemaUser+":")
+
+	switch strings.ToLower(op.Op) {
+	case "add", "replace":
+		if path == "" {
+			values, ok := value.(map[string]interface{})
+			if !ok {
+				return errors.New("operations without path need an object value")
+			}
+			for key, v := range values {
+				setScimAttribute(attributes, strings.TrimPrefix(key, api.ScimSchemaUser+":"), v)
+			}
+			return nil
+		}
+		setScimAttribute(attributes, path, value)
+		return nil
+	case "remove":
+		if path == "" {
+			return errors.New("remove operations need a path")
+		}
+		name, sub, _ := strings.Cut(path, ".")
+		if sub == "" {
+			delete(attributes, name)
+		} else if nested, ok := attributes[name].(map[string]interface{}); ok {
+			delete(nested, sub)
+		}
+		return nil
+	default:
+		return errors.New("unsupported patch operation: " + op.Op)
+	}
+}
+
+// setScimAttribute sets a top level or sub attribute, paths filtering emails set the primary email.
+func setScimAttribute(attributes map[string]interface{}, path string, value interface{}) {
+	if strings.HasPrefix(strings.ToLower(path), "emails") {
+		if email, ok := value.(string); ok {
+			attributes["emails"] = []interface{}{map[string]interface{}{"value": email, "type": "work", "primary": true}}
+		} else {
+			attributes["emails"] = value
+		}
+		return
+	}
+	name, sub, _ := strings.Cut(path, ".")
+	if sub == "" {
+		attributes[name] = value
+		return
+	}
+	nested, ok := attributes[name].(map[string]interface{})
+	if !ok {
+		nested = make(map[string]interface{})
+		attributes[name] = nested
+	}
+	nested[sub] = value
+}
+
+// ScimDeleteUser deletes a user and its group memberships.
+func (r *httpRoutes) ScimDeleteUser(ctx echo.Context) error {
+	user, err := r.getScimUser(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.DoDeleteUser(strconv.FormatUint(uint64(user.ID), 10)); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// --- Groups ---
+
+func (r *httpRoutes) scimGroupFromDB(ctx echo.Context, group db.ScimGroup, memberIDs []uint) api.ScimGroup {
+	resp := api.ScimGroup{
+		Schemas:     []string{api.ScimSchemaGroup},
+		ID:          group.ID,
+		ExternalID:  group.ExternalID,
+		DisplayName: group.DisplayName,
+		Meta: &api.ScimMeta{
+			ResourceType: "Group",
+			Created:      group.CreatedAt,
+			LastModified: group.UpdatedAt,
+			Location:     scimLocation(ctx, "Groups", group.ID),
+		},
+	}
+	for _, id := range memberIDs {
+		value := strconv.FormatUint(uint64(id), 10)
+		resp.Members = append(resp.Members, api.ScimReference{Value: value, Ref: scimLocation(ctx, "Users", value)})
+	}
+	return resp
+}
+
+func (r *httpRoutes) respondScimGroup(ctx echo.Context, status int, group db.ScimGroup) error {
+	members, err := r.db.ListScimGroupMemberIDs([]string{group.ID})
+	if err != nil {
+		return scimError(http.StatusInternalServerError, "", "Failed to retrieve group members")
+	}
+	resp := r.scimGroupFromDB(ctx, group, members[group.ID])
+	if status == http.StatusCreated {
+		ctx.Response().Header().Set(echo.HeaderLocation, resp.Meta.Location)
+	}
+	return scimJSON(ctx, status, resp)
+}
+
+// getScimGroup loads the group of the id path param.
+func (r *httpRoutes) getScimGroup(ctx echo.Context) (*db.ScimGroup, error) {
+	group, err := r.db.GetScimGroup(ctx.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, scimError(http.StatusNotFound, "", "Group not found")
+		}
+		return nil, scimError(http.StatusInternalServerError, "", "Failed to retrieve group")
+	}
+	return group, nil
+}
+
+// scimMemberIDs parses member references, all of them must be existing users.
+func (r *httpRoutes) scimMemberIDs(refs []api.ScimReference) ([]uint, error) {
+	ids := make([]uint, 0, len(refs))
+	for _, ref := range refs {
+		id, err := strconv.ParseUint(ref.Value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid member %q", ref.Value)
+		}
+		ids = append(ids, uint(id))
+	}
+	existing, err := r.db.ExistingUserIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		found[id] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return nil, fmt.Errorf("member %d is not a user", id)
+		}
+	}
+	return ids, nil
+}
+
+// syncScimUserRoles gives each user the highest role of its groups, or viewer when its groups grant none.
+// Only users of the SCIM connector are managed, local users, users of other connectors and the primary
+// admin user keep their role.
+func (r *httpRoutes) syncScimUserRoles(ctx echo.Context, userIDs []uint) {
+	seen := make(map[uint]bool)
+	for _, id := range userIDs {
+		if seen[id] || id == 1 {
+			continue
+		}
+		seen[id] = true
+
+		user, err := r.db.GetUser(strconv.FormatUint(uint64(id), 10))
+		if err != nil || user == nil || user.ConnectorId != r.scimConnectorID {
+			continue
+		}
+		groups, err := r.db.ListScimGroupsOfUser(id)
+		if err != nil {
+			continue // Logged by the db layer
+		}
+		role := api2.ViewerRole
+		for _, group := range groups {
+			if groupRole := r.scimGroupRole(group.DisplayName); roleRank[groupRole] > roleRank[role] {
+				role = groupRole
+			}
+		}
+		if role == user.Role {
+			continue
+		}
+
+		if err := r.db.Orm.Model(&db.User{}).Where("id = ?", id).Update("role", role).Error; err != nil {
+			r.logger.Error("Failed to update user role from SCIM groups", zap.Uint("id", id), zap.Error(err))
+			continue
+		}
+		r.logger.Info("Updated user role from SCIM groups", zap.Uint("id", id),
+			zap.String("from", string(user.Role)), zap.String("to", string(role)))
+		if err := r.authCache.RemoveUserFromCache(ctx.Request().Context(), user.Email); err != nil {
+			r.logger.Error("Failed to invalidate user cache after SCIM role change", zap.String("email", user.Email), zap.Error(err))
+		}
+		if _, err := r.authServer.revokeSessions(ctx.Request().Context(), user.ExternalId, "scim", sessionRevocationRoleChanged); err != nil {
+			r.logger.Error("Failed to revoke sessions after SCIM role change", zap.Uint("id", id), zap.Error(err))
+		}
+	}
+}
+
+// ScimListGroups lists groups, filtering on displayName or id.
+func (r *httpRoutes) ScimListGroups(ctx echo.Context) error {
+	startIndex, count, err := scimPagination(ctx)
+	if err != nil {
+		return err
+	}
+	attribute, value, err := scimFilter(ctx)
+	if err != nil {
+		return err
+	}
+
+	var groups []db.ScimGroup
+	var total int64
+	switch attribute {
+	case "":
+		groups, total, err = r.db.ListScimGroups("", startIndex-1, count)
+	case "displayname":
+		groups, total, err = r.db.ListScimGroups(value, startIndex-1, count)
+	case "id":
+		var group *db.ScimGroup
+		group, err = r.db.GetScimGroup(value)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		} else if err == nil {
+			total = 1
+			if startIndex == 1 && count > 0 {
+				groups = []db.ScimGroup{*group}
+			}
+		}
+	default:
+		return scimError(http.StatusBadRequest, "invalidFilter", "Unsupported filter attribute: "+attribute)
+	}
+	if err != nil {
+		return scimError(http.StatusInternalServerError, "", "Failed to list groups")
+	}
+
+	groupIDs := make([]string, 0, len(groups))
+	for _, group := range groups {
+		groupIDs = append(groupIDs, group.ID)
+	}
+	// Identity providers can skip members when they only look groups up
+	var members map[string][]uint
+	if !strings.Contains(ctx.QueryParam("excludedAttributes"), "members") {
+		members, err = r.db.ListScimGroupMemberIDs(groupIDs)
+		if err != nil {
+			return scimError(http.StatusInternalServerError, "", "Failed to retrieve group members")
+		}
+	}
+
+	resources := make([]interface{}, 0, len(groups))
+	for _, group := range groups {
+		resources = append(resources, r.scimGroupFromDB(ctx, group, members[group.ID]))
+	}
+	return scimJSON(ctx, http.StatusOK, scimListResponse(resources, total, startIndex))
+}
+
+func (r *httpRoutes) ScimGetGroup(ctx echo.Context) error {
+	group, err := r.getScimGroup(ctx)
+	if err != nil {
+		return err
+	}
+	return r.respondScimGroup(ctx, http.StatusOK, *group)
+}
+
+// ScimCreateGroup creates a group, its members get the role the group maps to.
+func (r *httpRoutes) ScimCreateGroup(ctx echo.Context) error {
+	var req api.ScimGroup
+	if err := decodeScim(ctx, &req); err != nil {
+		return err
+	}
+	req.DisplayName = strings.TrimSpace(req.DisplayName)
+	if req.DisplayName == "" {
+		return scimError(http.StatusBadRequest, "invalidValue", "displayName is required")
+	}
+	if _, total, err := r.db.ListScimGroups(req.DisplayName, 0, 1); err != nil {
+		return scimError(http.StatusInternalServerError, "", "Failed to check group existence")
+	} else if total > 0 {
+		return scimError(http.StatusConflict, "uniqueness", "A group with this displayName already exists")
+	}
+	memberIDs, err := r.scimMemberIDs(req.Members)
+	if err != nil {
+		return scimError(http.StatusBadRequest, "invalidValue", err.Error())
+	}
+
+	group := db.ScimGroup{
+		ID:          uuid.New().String(),
+		DisplayName: req.DisplayName,
+		ExternalID:  req.ExternalID,
+	}
+	if err := r.db.CreateScimGroup(&group, memberIDs); err != nil {
+		return scimError(http.StatusInternalServerError, "", "Failed to save group")
+	}
+	if role := r.scimGroupRole(group.DisplayName); role == "" {
+		r.logger.Warn("SCIM group does not map to any role", zap.String("displayName", group.DisplayName))
+	}
+	r.syncScimUserRoles(ctx, memberIDs)
+
+	return r.respondScimGroup(ctx, http.StatusCreated, group)
+}
+
+// ScimReplaceGroup replaces the name and the members of a group.
+func (r *httpRoutes) ScimReplaceGroup(ctx echo.Context) error {
+	group, err := r.getScimGroup(ctx)
+	if err != nil {
+		return err
+	}
+	var req api.ScimGroup
+	if err := decodeScim(ctx, &req); err != nil {
+		return err
+	}
+	memberIDs, err := r.scimMemberIDs(req.Members)
+	if err != nil {
+		return scimError(http.StatusBadRequest, "invalidValue", err.Error())
+	}
+
+	update := scimGroupUpdate{displayName: &req.DisplayName, externalID: &req.ExternalID, replaceMembers: true, add: memberIDs}
+	if err := r.updateScimGroup(ctx, group, update); err != nil {
+		return err
+	}
+	return r.respondScimGroup(ctx, http.StatusOK, *group)
+}
+
+// scimGroupUpdate collects the changes of a replace or patch request on a group.
+type scimGroupUpdate struct {
+	displayName    *string
+	externalID     *string
+	replaceMembers bool   // Remove every current member before adding
+	add            []uint // Members to add
+	remove         []uint // Members to remove
+}
+
+// updateScimGroup saves the changes to a group and resyncs the roles of the users they affect.
+func (r *httpRoutes) updateScimGroup(ctx echo.Context, group *db.ScimGroup, update scimGroupUpdate) error {
+	members, err := r.db.ListScimGroupMemberIDs([]string{group.ID})
+	if err != nil {
+		return scimError(http.StatusInternalServerError, "", "Failed to retrieve group members")
+	}
+	affected := append([]uint{}, update.add...)
+	affected = append(affected, update.remove...)
+
+	if update.displayName != nil || update.externalID != nil {
+		renamed := *group
+		if update.displayName != nil {
+			renamed.DisplayName = strings.TrimSpace(*update.displayName)
+			if renamed.DisplayName == "" {
+				return scimError(http.StatusBadRequest, "invalidValue", "displayName is required")
+			}
+		}
+		if update.externalID != nil {
+			renamed.ExternalID = *update.externalID
+		}
+		if renamed.DisplayName != group.DisplayName {
+			if _, total, err := r.db.ListScimGroups(renamed.DisplayName, 0, 1); err != nil {
+				return scimError(http.StatusInternalServerError, "", "Failed to check group existence")
+			} else if total > 0 {
+				return scimError(http.StatusConflict, "uniqueness", "A group with this displayName already exists")
+			}
+			// The role of the group may change with its name
+			affected = append(affected, members[group.ID]...)
+		}
+		if err := r.db.UpdateScimGroup(&renamed); err != nil {
+			return scimError(http.StatusInternalServerError, "", "Failed to update group")
+		}
+		*group = renamed
+	}
+
+	if update.replaceMembers {
+		affected = append(affected, members[group.ID]...)
+		if err := r.db.RemoveScimGroupMembers(group.ID, nil); err != nil {
+			return scimError(http.StatusInternalServerError, "", "Failed to update group members")
+		}
+	} else if err := r.db.RemoveScimGroupMembers(group.ID, update.remove); err != nil {
+		return scimError(http.StatusInternalServerError, "", "Failed to update group members")
+	}
+	if err := r.db.AddScimGroupMembers(group.ID, update.add); err != nil {
+		return scimError(http.StatusInternalServerError, "", "Failed to update group members")
+	}
+
+	r.syncScimUserRoles(ctx, affected)
+	return nil
+}
+
+// ScimPatchGroup applies SCIM patch operations, used by identity providers to add and remove members.
+func (r *httpRoutes) ScimPatchGroup(ctx echo.Context) error {
+	group, err := r.getScimGroup(ctx)
+	if err != nil {
+		return err
+	}
+	var req api.ScimPatchRequest
+	if err := decodeScim(ctx, &req); err != nil {
+		return err
+	}
+
+	var update scimGroupUpdate
+	for _, op := range req.Operations {
+		if err := r.collectScimGroupPatch(&update, op); err != nil {
+			return scimError(http.StatusBadRequest, "invalidValue", err.Error())
+		}
+	}
+	if err := r.updateScimGroup(ctx, group, update); err != nil {
+		return err
+	}
+	return r.respondScimGroup(ctx, http.StatusOK, *group)
+}
+
+// collectScimGroupPatch adds the changes of a patch operation to the group update.
+func (r *httpRoutes) collectScimGroupPatch(update *scimGroupUpdate, op api.ScimPatchOperation) error {
+	path := strings.TrimPrefix(op.Path, api.ScimSchemaGroup+":")
+	opName := strings.ToLower(op.Op)
+
+	// members[value eq "<id>"] only makes sense for removals
+	if match := scimMemberFilterRegex.FindStringSubmatch(path); match != nil {
+		if opName != "remove" {
+			return errors.New("unsupported patch operation on " + path)
+		}
+		ids, err := r.scimMemberIDs([]api.ScimReference{{Value: match[1]}})
+		if err != nil {
+			return err
+		}
+		update.remove = append(update.remove, ids...)
+		return nil
+	}
+
+	if path == "" {
+		if opName != "add" && opName != "replace" {
+			return errors.New("operations without path need to be add or replace")
+		}
+		var values struct {
+			DisplayName *string              `json:"displayName"`
+			ExternalID  *string              `json:"externalId"`
+			Members     *[]api.ScimReference `json:"members"`
+		}
+		if err := json.Unmarshal(op.Value, &values); err != nil {
+			return fmt.Errorf("operations without path need an object value: %w", err)
+		}
+		if values.DisplayName != nil {
+			update.displayName = values.DisplayName
+		}
+		if values.ExternalID != nil {
+			update.externalID = values.ExternalID
+		}
+		if values.Members != nil {
+			return r.collectScimMembers(update, opName, *values.Members)
+		}
+		return nil
+	}
+
+	switch strings.ToLower(path) {
+	case "displayname":
+		var name string
+		if err := json.Unmarshal(op.Value, &name); err != nil || opName == "remove" {
+			return errors.New("displayName should be replaced by a string")
+		}
+		update.displayName = &name
+	case "externalid":
+		var externalID string
+		if opName != "remove" {
+			if err := json.Unmarshal(op.Value, &externalID); err != nil {
+				return errors.New("externalId should be a string")
+			}
+		}
+		update.externalID = &externalID
+	case "members":
+		var refs []api.ScimReference
+		if len(op.Value) > 0 {
+			if err := json.Unmarshal(op.Value, &refs); err != nil {
+				return fmt.Errorf("members should be a list of references: %w", err)
+			}
+		}
+		if opName == "remove" && len(refs) == 0 {
+			update.replaceMembers = true
+			update.add = nil
+			return nil
+		}
+		return r.collectScimMembers(update, opName, refs)
+	default:
+		return errors.New("unsupported patch path: " + op.Path)
+	}
+	return nil
+}
+
+func (r *httpRoutes) collectScimMembers(update *scimGroupUpdate, opName string, refs []api.ScimReference) error {
+	ids, err := r.scimMemberIDs(refs)
+	if err != nil {
+		return err
+	}
+	switch opName {
+	case "add":
+		update.add = append(update.add, ids...)
+	case "replace":
+		update.replaceMembers = true
+		update.add = ids
+	case "remove":
+		update.remove = append(update.remove, ids...)
+	default:
+		return errors.New("unsupported patch operation: " + opName)
+	}
+	return nil
+}
+
+// ScimDeleteGroup deletes a group, its former members lose the role it granted.
+func (r *httpRoutes) ScimDeleteGroup(ctx echo.Context) error {
+	group, err := r.getScimGroup(ctx)
+	if err != nil {
+		return err
+	}
+	members, err := r.db.ListScimGroupMemberIDs([]string{group.ID})
+	if err != nil {
+		return scimError(http.StatusInternalServerError, "", "Failed to retrieve group members")
+	}
+	if err := r.db.DeleteScimGroup(group.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return scimError(http.StatusNotFound, "", "Group not found")
+		}
+		return scimError(http.StatusInternalServerError, "", "Failed to delete group")
+	}
+	r.syncScimUserRoles(ctx, members[group.ID])
+	return ctx.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	api2 "github.com/opengovern/og-util/pkg/api"
+	"github.com/opengovern/opensecurity/services/auth/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scimFilterContext(filter string) echo.Context {
+	req := httptest.NewRequest(http.MethodGet, "/scim/v2/Users?filter="+url.QueryEscape(filter), nil)
+	return echo.New().NewContext(req, httptest.NewRecorder())
+}
+
+func TestScimFilter(t *testing.T) {
+	attribute, value, err := scimFilter(scimFilterContext(`userName eq "jane@example.com"`))
+	require.NoError(t, err)
+	assert.Equal(t, "username", attribute)
+	assert.Equal(t, "jane@example.com", value)
+
+	attribute, value, err = scimFilter(scimFilterContext(`displayName EQ "R&D \"core\""`))
+	require.NoError(t, err)
+	assert.Equal(t, "displayname", attribute)
+	assert.Equal(t, `R&D "core"`, value)
+
+	attribute, _, err = scimFilter(scimFilterContext(""))
+	require.NoError(t, err)
+	assert.Empty(t, attribute)
+
+	for _, filter := range []string{`userName co "jane"`, `userName eq jane`, `userName eq "a" or userName eq "b"`} {
+		_, _, err = scimFilter(scimFilterContext(filter))
+		assert.Error(t, err, filter)
+	}
+}
+
+func TestApplyScimUserPatch(t *testing.T) {
+	attributes := map[string]interface{}{
+		"userName": "jane@example.com",
+		"active":   true,
+		"name":     map[string]interface{}{"givenName": "Jane", "familyName": "Doe"},
+	}
+
+	require.NoError(t, applyScimUserPatch(attributes, api.ScimPatchOperation{Op: "Replace", Path: "active", Value: json.RawMessage(`false`)}))
+	assert.Equal(t, false, attributes["active"])
+
+	require.NoError(t, applyScimUserPatch(attributes, api.ScimPatchOperation{Op: "replace", Path: "name.givenName", Value: json.RawMessage(`"Janet"`)}))
+	assert.Equal(t, "Janet", attributes["name"].(map[string]interface{})["givenName"])
+
+	require.NoError(t, applyScimUserPatch(attributes, api.ScimPatchOperation{Op: "replace", Path: `emails[type eq "work"].value`, Value: json.RawMessage(`"janet@example.com"`)}))
+	assert.Equal(t, []interface{}{map[string]interface{}{"value": "janet@example.com", "type": "work", "primary": true}}, attributes["emails"])
+
+	require.NoError(t, applyScimUserPatch(attributes, api.ScimPatchOperation{Op: "add", Value: json.RawMessage(`{"` + api.ScimSchemaUser + `:displayName": "Janet Doe"}`)}))
+	assert.Equal(t, "Janet Doe", attributes["displayName"])
+
+	require.NoError(t, applyScimUserPatch(attributes, api.ScimPatchOperation{Op: "remove", Path: "name.familyName"}))
+	assert.NotContains(t, attributes["name"], "familyName")
+
+	assert.Error(t, applyScimUserPatch(attributes, api.ScimPatchOperation{Op: "remove"}))
+	assert.Error(t, applyScimUserPatch(attributes, api.ScimPatchOperation{Op: "add", Value: json.RawMessage(`"value"`)}))
+	assert.Error(t, applyScimUserPatch(attributes, api.ScimPatchOperation{Op: "move", Path: "active"}))
+}
+
+func TestCollectScimGroupPatch(t *testing.T) {
+	r := &httpRoutes{}
+
+	var update scimGroupUpdate
+	require.NoError(t, r.collectScimGroupPatch(&update, api.ScimPatchOperation{Op: "replace", Path: "displayName", Value: json.RawMessage(`"Platform Admins"`)}))
+	require.NoError(t, r.collectScimGroupPatch(&update, api.ScimPatchOperation{Op: "remove", Path: "externalId"}))
+	require.NoError(t, r.collectScimGroupPatch(&update, api.ScimPatchOperation{Op: "remove", Path: "members"}))
+	require.NotNil(t, update.displayName)
+	assert.Equal(t, "Platform Admins", *update.displayName)
+	require.NotNil(t, update.externalID)
+	assert.Empty(t, *update.externalID)
+	assert.True(t, update.replaceMembers)
+
+	assert.Error(t, r.collectScimGroupPatch(&update, api.ScimPatchOperation{Op: "add", Path: `members[value eq "2"]`}))
+	assert.Error(t, r.collectScimGroupPatch(&update, api.ScimPatchOperation{Op: "remove", Path: "displayName"}))
+	assert.Error(t, r.collectScimGroupPatch(&update, api.ScimPatchOperation{Op: "replace", Path: "owner", Value: json.RawMessage(`"x"`)}))
+}
+
+func TestScimGroupRole(t *testing.T) {
+	roles, err := parseScimGroupRoles("Platform Admins=admin, engineers = editor")
+	require.NoError(t, err)
+	r := &httpRoutes{scimGroupRoles: roles}
+
+	assert.Equal(t, api2.AdminRole, r.scimGroupRole("platform admins"))
+	assert.Equal(t, api2.EditorRole, r.scimGroupRole("Engineers"))
+	assert.Empty(t, r.scimGroupRole("admin"), "groups named after a role grant nothing unless mapped")
+
+	_, err = parseScimGroupRoles("admins=owner")
+	assert.Error(t, err)
+	_, err = parseScimGroupRoles("admins")
+	assert.Error(t, err)
+}
@@ -5,8 +5,11 @@ import (
 	"crypto/rsa"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	dexApi "github.com/dexidp/dex/api/v2"
@@ -22,7 +25,9 @@ import (
 	"github.com/opengovern/og-util/pkg/httpserver"
 	"github.com/opengovern/opensecurity/services/auth/authcache" // Import authcache
 	"github.com/opengovern/opensecurity/services/auth/db"
+	"github.com/opengovern/opensecurity/services/auth/ratelimit"
 	"github.com/opengovern/opensecurity/services/auth/utils"
+	integrationClient "github.com/opengovern/opensecurity/services/integration/client"
 	"go.uber.org/zap"
 	"google.golang.org/genproto/googleapis/rpc/status"
 	// "google.golang.org/grpc/credentials" // Keep if newServerCredentials is defined here
@@ -46,18 +51,27 @@ type Server struct {
 	dexClient           dexApi.DexClient
 	logger              *zap.Logger
 	db                  db.Database
-	authCache           *authcache.AuthCacheService // Injected cache service instance
-	updateLoginUserList []User                      // State for UpdateLastLoginLoop - consider alternatives if scaling
-	updateLogin         chan User                   // Channel for UpdateLastLoginLoop
+	authCache           *authcache.AuthCacheService                // Injected cache service instance
+	integrationClient   integrationClient.IntegrationServiceClient // Resolves integration groups and labels of role bindings
+	updateLoginUserList []User                                     // State for UpdateLastLoginLoop - consider alternatives if scaling
+	updateLogin         chan User                                  // Channel for UpdateLastLoginLoop
+	auditEvents         chan db.AuditEvent                         // Events waiting to be written by AuditLoop
+	auditSyslog         *auditSyslogForwarder                      // Optional forwarding of audit events to syslog
+	apiKeyUsage         sync.Map                                   // Key ID to *atomic.Int64 of requests not yet counted in the DB
+	rateLimiter         *ratelimit.Limiter                         // Throttles callers over their rate limit, nil when disabled
+	trustedProxies      []*net.IPNet                               // Proxies whose x-forwarded-for header tells the client address
+	auditKey            []byte                                     // HMAC key of the audit chain, kept out of the database
+	auditSpill          *auditSpill                                // Audit events waiting on disk to be stored
 }
 
 // DexClaims represents the expected claims structure within a Dex ID token.
 type DexClaims struct {
-	Email           string                 `json:"email"`
-	EmailVerified   bool                   `json:"email_verified"`
-	Groups          []string               `json:"groups"`           // Optional groups claim
-	Name            string                 `json:"name"`             // Optional name claim
-	FederatedClaims map[string]interface{} `json:"federated_claims"` // Optional federated claims
+	Email             string                 `json:"email"`
+	EmailVerified     bool                   `json:"email_verified"`
+	Groups            []string               `json:"groups"`             // Optional groups claim
+	Name              string                 `json:"name"`               // Optional name claim
+	PreferredUsername string                 `json:"preferred_username"` // Optional username claim
+	FederatedClaims   map[string]interface{} `json:"federated_claims"`   // Optional federated claims
 	jwt.StandardClaims
 }
 
@@ -69,6 +83,14 @@ type userClaim struct {
 	UserLastLogin  *time.Time // User's last login time (from DB/Cache)
 	ExternalUserID string     `json:"sub"` // Subject claim (External ID)
 	EmailVerified  bool       // From Dex token if available
+	IssuedAt       int64      `json:"iat,omitempty"` // Issue time, checked against session revocations for Dex tokens
+	ExpiresAt      int64      `json:"exp,omitempty"` // Expiry of platform API key tokens
+	apiKeyHash     string     // sha512 of the token when it was signed by the platform key
+	// Identity provider claims of Dex tokens, used by the role mapping of their connector
+	connectorID       string
+	groups            []string
+	name              string
+	preferredUsername string
 }
 
 // Valid implements jwt.Claims interface (basic validation).
@@ -76,6 +98,9 @@ func (u userClaim) Valid() error {
 	if u.ExternalUserID == "" || u.Email == "" {
 		return errors.New("user claim missing external ID or email")
 	}
+	if u.ExpiresAt != 0 && time.Now().Unix() > u.ExpiresAt {
+		return errors.New("token is expired")
+	}
 	return nil
 }
 
@@ -162,7 +187,13 @@ func (s *Server) UpdateLastLogin(claim *userClaim) {
 
 // Check performs the authorization check for Envoy.
 // It verifies the token, checks the cache, falls back to the database, and populates the cache.
-func (s *Server) Check(ctx context.Context, req *envoyauth.CheckRequest) (*envoyauth.CheckResponse, error) {
+func (s *Server) Check(ctx context.Context, req *envoyauth.CheckRequest) (resp *envoyauth.CheckResponse, err error) {
+	// Every decision on a mutating request, and every denial, ends up in the audit log
+	var actor auditActor
+	defer func() {
+		s.auditCheck(req, actor, resp)
+	}()
+
 	// Standard Unauthorized response structure
 	unAuth := &envoyauth.CheckResponse{
 		Status: &status.Status{Code: int32(rpc.UNAUTHENTICATED)},
@@ -190,6 +221,7 @@ func (s *Server) Check(ctx context.Context, req *envoyauth.CheckRequest) (*envoy
 			zap.String("reqId", httpRequest.Id),
 			zap.String("path", httpRequest.Path),
 			zap.Error(err))
+		actor.reason = "invalid token"
 		return unAuth, nil // Return UNAUTHENTICATED
 	}
 
@@ -197,8 +229,11 @@ func (s *Server) Check(ctx context.Context, req *envoyauth.CheckRequest) (*envoy
 	verifiedClaim.Email = strings.ToLower(strings.TrimSpace(verifiedClaim.Email))
 	if verifiedClaim.Email == "" {
 		s.logger.Warn("Token verified but email claim is missing or empty", zap.String("externalId", verifiedClaim.ExternalUserID))
+		actor.reason = "missing email claim"
 		return unAuth, nil // Return UNAUTHENTICATED
 	}
+	actor.id = verifiedClaim.ExternalUserID
+	actor.email = verifiedClaim.Email
 	logFields := []zap.Field{
 		zap.String("email", verifiedClaim.Email),
 		zap.String("externalId", verifiedClaim.ExternalUserID),
@@ -207,20 +242,72 @@ func (s *Server) Check(ctx context.Context, req *envoyauth.CheckRequest) (*envoy
 	}
 	s.logger.Debug("Token verified successfully", logFields...)
 
+	// API keys must still be active, unexpired, unrevoked and used within their scopes
+	var apiKey *authcache.CachedAPIKey
+	var serviceAccount *authcache.CachedServiceAccount
+	if verifiedClaim.apiKeyHash != "" {
+		var denied *envoyauth.CheckResponse
+		apiKey, denied = s.authorizeAPIKey(ctx, req, verifiedClaim.apiKeyHash, unAuth, logFields)
+		if denied != nil {
+			actor.reason = "API key rejected"
+			return denied, nil
+		}
+		actor.apiKeyID = strconv.FormatUint(uint64(apiKey.ID), 10)
+		logFields = append(logFields, zap.Uint("apiKeyId", apiKey.ID))
+		// Keys of service accounts act as their account, not as a user
+		if apiKey.ServiceAccountID != 0 {
+			serviceAccount, err = s.serviceAccountState(ctx, apiKey.ServiceAccountID)
+			if err != nil {
+				s.logger.Error("Access denied: failed to load service account", append(logFields, zap.Error(err))...)
+				actor.reason = "service account unresolved"
+				return unAuth, nil
+			}
+			if serviceAccount == nil || !serviceAccount.IsActive {
+				s.logger.Warn("Access denied: service account is deleted or inactive", logFields...)
+				actor.reason = "service account is inactive"
+				return unAuth, nil
+			}
+			logFields = append(logFields, zap.Uint("serviceAccountId", serviceAccount.ID))
+		}
+	} else {
+		// Users of connectors with role rules get their role, and possibly their account, from their claims
+		reason, err := s.syncConnectorRole(ctx, verifiedClaim, logFields)
+		if err != nil {
+			s.logger.Error("Access denied: failed to evaluate connector role mapping", append(logFields, zap.Error(err))...)
+			actor.reason = "connector role mapping unresolved"
+			return unAuth, nil
+		}
+		if reason != "" {
+			s.logger.Warn("Access denied: "+reason, logFields...)
+			actor.reason = reason
+			return unAuth, nil
+		}
+	}
+
 	// --- Authorization Check with Cache ---
 	// var userInfo *utils.User // No longer needed here, use specific types
 	var userRole api.Role     // Final resolved role
 	var userExternalId string // Final resolved external ID
 
 	// 2. Check Cache
-	cachedInfo, cacheErr := s.authCache.GetUser(ctx, verifiedClaim.Email)
+	var cachedInfo *authcache.CachedUserInfo
+	var cacheErr error
+	if serviceAccount == nil {
+		cachedInfo, cacheErr = s.authCache.GetUser(ctx, verifiedClaim.Email)
+	}
+
+	if serviceAccount != nil {
+		// Service accounts are not users, their own record holds the role
+		userRole = api.Role(serviceAccount.Role)
+		userExternalId = serviceAccountSubject(serviceAccount.ID)
 
-	if cacheErr == nil {
+	} else if cacheErr == nil {
 		// Cache Hit!
 		s.logger.Debug("Auth cache hit", logFields...)
 		// Check if the cached user is active
 		if !cachedInfo.IsActive {
 			s.logger.Warn("Access denied: User found in cache but is inactive", logFields...)
+			actor.reason = "user is inactive"
 			return unAuth, nil // Return UNAUTHENTICATED for inactive user
 		}
 		// Use cached data
@@ -239,6 +326,7 @@ func (s *Server) Check(ctx context.Context, req *envoyauth.CheckRequest) (*envoy
 			// Handle specific errors from GetUserByEmail (already logs internally)
 			// e.g., "user disabled", "user not found"
 			s.logger.Warn("Access denied: User lookup failed or user invalid", append(logFields, zap.Error(dbErr))...)
+			actor.reason = "user not found or inactive"
 			return unAuth, nil // Return UNAUTHENTICATED
 		}
 		if dbUser == nil { // Defensive check
@@ -273,6 +361,7 @@ func (s *Server) Check(ctx context.Context, req *envoyauth.CheckRequest) (*envoy
 		dbUser, dbErr := utils.GetUserByEmail(verifiedClaim.Email, s.db)
 		if dbErr != nil {
 			s.logger.Warn("Access denied: User lookup failed or user invalid (after cache error)", append(logFields, zap.Error(dbErr))...)
+			actor.reason = "user not found or inactive"
 			return unAuth, nil
 		}
 		if dbUser == nil {
@@ -287,6 +376,34 @@ func (s *Server) Check(ctx context.Context, req *envoyauth.CheckRequest) (*envoy
 
 	// --- Authorization Decision ---
 	// At this point, we have a valid, active user (either from cache or DB)
+	if apiKey != nil && serviceAccount == nil {
+		// Requests made with an API key get the role of the key, capped by the current role of its creator
+		// so a demoted user keeps no more access through keys created before
+		if keyRole := api.Role(apiKey.Role); roleRank[keyRole] < roleRank[userRole] {
+			userRole = keyRole
+		}
+	}
+	actor.id = userExternalId
+	actor.role = string(userRole)
+	// OIDC tokens issued before the sessions of the user were revoked are rejected until they expire
+	if apiKey == nil {
+		revokedBefore, err := s.sessionsRevokedBefore(ctx, userExternalId)
+		if err != nil {
+			s.logger.Error("Access denied: failed to load session revocation", append(logFields, zap.Error(err))...)
+			actor.reason = "session revocation unresolved"
+			return unAuth, nil
+		}
+		if sessionRevoked(verifiedClaim.IssuedAt, revokedBefore) {
+			s.logger.Warn("Access denied: session is revoked", append(logFields, zap.Int64("issuedAt", verifiedClaim.IssuedAt))...)
+			actor.reason = "session revoked"
+			return unAuth, nil
+		}
+	}
+	// Callers over their rate limit are throttled before anything more is resolved for them
+	if throttled := s.rateLimitCheck(ctx, req, userExternalId, userRole, apiKey, logFields); throttled != nil {
+		actor.reason = "rate limit exceeded"
+		return throttled, nil
+	}
 	// Add more complex authorization logic here if needed (e.g., checking roles against path/method)
 	s.logger.Info("Authorization check successful", logFields...)
 
@@ -297,6 +414,15 @@ func (s *Server) Check(ctx context.Context, req *envoyauth.CheckRequest) (*envoy
 		return unAuth, fmt.Errorf("internal check error: missing user details") // Should not happen
 	}
 
+	// Role bindings limit the integrations the caller can see, services filter by this scope
+	integrationScope, err := s.integrationScopeHeader(ctx, userExternalId, apiKey)
+	if err != nil {
+		// Fail closed, an unresolved scope must not grant access to every integration
+		s.logger.Error("Access denied: failed to resolve integration scope", append(logFields, zap.Error(err))...)
+		actor.reason = "integration scope unresolved"
+		return unAuth, nil
+	}
+
 	return &envoyauth.CheckResponse{
 		Status: &status.Status{Code: int32(rpc.OK)},
 		HttpResponse: &envoyauth.CheckResponse_OkResponse{
@@ -304,8 +430,8 @@ func (s *Server) Check(ctx context.Context, req *envoyauth.CheckRequest) (*envoy
 				Headers: []*envoycore.HeaderValueOption{
 					{Header: &envoycore.HeaderValue{Key: httpserver.XPlatformUserIDHeader, Value: userExternalId}},
 					{Header: &envoycore.HeaderValue{Key: httpserver.XPlatformUserRoleHeader, Value: string(userRole)}},
-					// XPlatformUserConnectionsScope seems redundant if it's the same as ExternalId? Adjust if needed.
-					{Header: &envoycore.HeaderValue{Key: httpserver.XPlatformUserConnectionsScope, Value: userExternalId}},
+					// Comma separated integration IDs, empty if the caller is not limited to some integrations
+					{Header: &envoycore.HeaderValue{Key: httpserver.XPlatformUserConnectionsScope, Value: integrationScope}},
 				},
 			},
 		},
@@ -336,10 +462,16 @@ func (s *Server) Verify(ctx context.Context, authToken string) (*userClaim, erro
 		s.logger.Debug("Dex OIDC verification successful", zap.String("subject", claims.Subject), zap.String("email", claims.Email))
 		// Construct userClaim from Dex claims
 		// Role is NOT set here, will be determined later in Check()
+		connectorID, _ := claims.FederatedClaims["connector_id"].(string)
 		return &userClaim{
-			Email:          claims.Email,
-			EmailVerified:  claims.EmailVerified,
-			ExternalUserID: claims.Subject, // Standard OIDC subject claim maps to ExternalUserID
+			Email:             claims.Email,
+			EmailVerified:     claims.EmailVerified,
+			ExternalUserID:    claims.Subject, // Standard OIDC subject claim maps to ExternalUserID
+			IssuedAt:          idToken.IssuedAt.Unix(),
+			connectorID:       connectorID,
+			groups:            claims.Groups,
+			name:              claims.Name,
+			preferredUsername: claims.PreferredUsername,
 		}, nil
 	} else {
 		// Log Dex verification error, but don't return yet, try platform key
@@ -367,6 +499,8 @@ func (s *Server) Verify(ctx context.Context, authToken string) (*userClaim, erro
 	if parseErr == nil && parsedToken.Valid {
 		// Platform key verification successful
 		s.logger.Debug("Platform key verification successful", zap.String("subject", platformClaims.ExternalUserID), zap.String("email", platformClaims.Email), zap.String("role", string(platformClaims.Role)))
+		// Platform tokens are API keys, their state is checked against the key record in Check()
+		platformClaims.apiKeyHash = hashAPIKeyToken(token)
 		// Role *is* expected in platform tokens, return the full claim
 		return &platformClaims, nil
 	} else {
@@ -0,0 +1,420 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/opengovern/og-util/pkg/httpserver"
+	"github.com/opengovern/opensecurity/services/auth/api"
+	"github.com/opengovern/opensecurity/services/auth/authcache"
+	"github.com/opengovern/opensecurity/services/auth/db"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// serviceAccountMaxActiveKeys allows the old and new key to overlap during a rotation.
+	serviceAccountMaxActiveKeys = 2
+	// serviceAccountDefaultGracePeriod is how long the previous key keeps working after a rotation.
+	serviceAccountDefaultGracePeriod = 24 * time.Hour
+	serviceAccountMaxGracePeriod     = 7 * 24 * time.Hour
+)
+
+// serviceAccountSubject is the user ID services see for requests made with the keys of a service account.
+func serviceAccountSubject(id uint) string {
+	return fmt.Sprintf("service-account|%d", id)
+}
+
+// serviceAccountEmail is the email claim of the keys of a service account, it never matches a user.
+func serviceAccountEmail(name string) string {
+	return name + "@service-accounts.local"
+}
+
+func cachedServiceAccountFromDB(account db.ServiceAccount) *authcache.CachedServiceAccount {
+	return &authcache.CachedServiceAccount{
+		ID:        account.ID,
+		Name:      account.Name,
+		Role:      string(account.Role),
+		OwnerTeam: account.OwnerTeam,
+		IsActive:  account.IsActive,
+	}
+}
+
+// serviceAccountState loads the state of a service account from the auth cache, falling back to the database.
+// It returns nil without error when the account does not exist anymore.
+func (s *Server) serviceAccountState(ctx context.Context, id uint) (*authcache.CachedServiceAccount, error) {
+	cached, err := s.authCache.GetServiceAccount(ctx, id)
+	if err == nil {
+		return cached, nil
+	}
+	if !errors.Is(err, authcache.ErrServiceAccountNotFound) {
+		s.logger.Error("Service account cache error, falling back to DB", zap.Error(err))
+	}
+
+	account, err := s.db.GetServiceAccount(uint64(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	state := cachedServiceAccountFromDB(*account)
+	if err := s.authCache.SetServiceAccount(ctx, state); err != nil {
+		s.logger.Error("Failed to populate service account cache after DB lookup", zap.Uint("id", id), zap.Error(err))
+	}
+	return state, nil
+}
+
+// apiKeyUsable reports whether a key can still authorize requests.
+func apiKeyUsable(key db.ApiKey, now time.Time) bool {
+	return key.IsActive && key.RevokedAt == nil && (key.ExpiresAt == nil || key.ExpiresAt.After(now))
+}
+
+// serviceAccountToAPI maps a service account and its keys to the API representation, with the usage of the keys.
+func serviceAccountToAPI(account db.ServiceAccount, keys []db.ApiKey) api.ServiceAccountResponse {
+	resp := api.ServiceAccountResponse{
+		ID:          account.ID,
+		Subject:     serviceAccountSubject(account.ID),
+		Name:        account.Name,
+		Description: account.Description,
+		Role:        account.Role,
+		OwnerTeam:   account.OwnerTeam,
+		IsActive:    account.IsActive,
+		CreatedBy:   account.CreatedBy,
+		CreatedAt:   account.CreatedAt,
+		UpdatedAt:   account.UpdatedAt,
+	}
+	now := time.Now()
+	for _, key := range keys {
+		resp.Usage.RequestCount += key.UsageCount
+		if key.LastUsedAt != nil && (resp.Usage.LastUsedAt == nil || key.LastUsedAt.After(*resp.Usage.LastUsedAt)) {
+			resp.Usage.LastUsedAt = key.LastUsedAt
+			resp.Usage.LastUsedIP = key.LastUsedIP
+		}
+		if apiKeyUsable(key, now) {
+			resp.Usage.ActiveKeys++
+		}
+	}
+	return resp
+}
+
+// serviceAccountParam loads the service account of the id path param.
+func (r *httpRoutes) serviceAccountParam(ctx echo.Context) (*db.ServiceAccount, error) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid service account ID format")
+	}
+	account, err := r.db.GetServiceAccount(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "Service account not found")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve service account")
+	}
+	return account, nil
+}
+
+// --- HTTP handlers ---
+
+// ListServiceAccounts lists service accounts with the usage of their keys, optionally filtered by the owner_team query param.
+func (r *httpRoutes) ListServiceAccounts(ctx echo.Context) error {
+	accounts, err := r.db.ListServiceAccounts(ctx.QueryParam("owner_team"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list service accounts")
+	}
+
+	resp := make([]api.ServiceAccountResponse, 0, len(accounts))
+	for _, account := range accounts {
+		keys, err := r.db.ListServiceAccountKeys(account.ID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list service account keys")
+		}
+		resp = append(resp, serviceAccountToAPI(account, keys))
+	}
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+// CreateServiceAccount creates a service account, keys are created for it separately.
+func (r *httpRoutes) CreateServiceAccount(ctx echo.Context) error {
+	var req api.CreateServiceAccountRequest
+	if err := bindValidate(ctx, &req); err != nil {
+		return err
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.OwnerTeam = strings.TrimSpace(req.OwnerTeam)
+	if req.Name == "" || req.OwnerTeam == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "name and owner_team are required")
+	}
+	if strings.ContainsAny(req.Name, "@ \t") {
+		return echo.NewHTTPError(http.StatusBadRequest, "name cannot contain spaces or @")
+	}
+	if roleRank[req.Role] == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "role should be admin, editor or viewer")
+	}
+
+	account := db.ServiceAccount{
+		Name:        req.Name,
+		Description: req.Description,
+		Role:        req.Role,
+		OwnerTeam:   req.OwnerTeam,
+		IsActive:    true,
+		CreatedBy:   httpserver.GetUserID(ctx),
+	}
+	if err := r.db.CreateServiceAccount(&account); err != nil {
+		if errors.Is(err, db.ErrServiceAccountExists) {
+			return echo.NewHTTPError(http.StatusConflict, "A service account with this name already exists")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create service account")
+	}
+	return ctx.JSON(http.StatusCreated, serviceAccountToAPI(account, nil))
+}
+
+// GetServiceAccount returns a service account with its keys and their usage.
+func (r *httpRoutes) GetServiceAccount(ctx echo.Context) error {
+	account, err := r.serviceAccountParam(ctx)
+	if err != nil {
+		return err
+	}
+	keys, err := r.db.ListServiceAccountKeys(account.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list service account keys")
+	}
+
+	resp := serviceAccountToAPI(*account, keys)
+	resp.Keys = make([]api.APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		resp.Keys = append(resp.Keys, apiKeyToAPI(key))
+	}
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+// UpdateServiceAccount changes the description, role, owner team or activity of a service account.
+// Requests made with its keys see the change on their next check.
+func (r *httpRoutes) UpdateServiceAccount(ctx echo.Context) error {
+	account, err := r.serviceAccountParam(ctx)
+	if err != nil {
+		return err
+	}
+	var req api.UpdateServiceAccountRequest
+	if err := bindValidate(ctx, &req); err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Role != nil {
+		if roleRank[*req.Role] == 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "role should be admin, editor or viewer")
+		}
+		updates["role"] = *req.Role
+	}
+	if req.OwnerTeam != nil {
+		ownerTeam := strings.TrimSpace(*req.OwnerTeam)
+		if ownerTeam == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "owner_team cannot be empty")
+		}
+		updates["owner_team"] = ownerTeam
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+	if len(updates) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Nothing to update")
+	}
+
+	if err := r.db.UpdateServiceAccount(uint64(account.ID), updates); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Service account not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update service account")
+	}
+	_ = r.authCache.RemoveServiceAccount(ctx.Request().Context(), account.ID)
+
+	return r.GetServiceAccount(ctx)
+}
+
+// DeleteServiceAccount deletes a service account with its keys and role bindings.
+func (r *httpRoutes) DeleteServiceAccount(ctx echo.Context) error {
+	account, err := r.serviceAccountParam(ctx)
+	if err != nil {
+		return err
+	}
+	keys, err := r.db.ListServiceAccountKeys(account.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete service account")
+	}
+
+	if err := r.db.DeleteServiceAccount(uint64(account.ID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Service account not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete service account")
+	}
+
+	reqCtx := ctx.Request().Context()
+	_ = r.authCache.RemoveServiceAccount(reqCtx, account.ID)
+	for _, key := range keys {
+		keyID := strconv.FormatUint(uint64(key.ID), 10)
+		_ = r.authCache.RemoveAPIKeyFromCache(reqCtx, key.KeyHash)
+		if err := r.db.DeleteRoleBindingsOfSubject(db.RoleBindingSubjectAPIKey, keyID); err == nil {
+			_ = r.authCache.RemoveIntegrationScope(reqCtx, db.RoleBindingSubjectAPIKey, keyID)
+		}
+	}
+	accountID := strconv.FormatUint(uint64(account.ID), 10)
+	if err := r.db.DeleteRoleBindingsOfSubject(db.RoleBindingSubjectServiceAccount, accountID); err == nil {
+		_ = r.authCache.RemoveIntegrationScope(reqCtx, db.RoleBindingSubjectServiceAccount, accountID)
+	}
+
+	return ctx.NoContent(http.StatusAccepted)
+}
+
+// CreateServiceAccountKey creates a key for a service account, an account has at most two active keys.
+func (r *httpRoutes) CreateServiceAccountKey(ctx echo.Context) error {
+	account, err := r.serviceAccountParam(ctx)
+	if err != nil {
+		return err
+	}
+	var req api.CreateServiceAccountKeyRequest
+	if err := bindValidate(ctx, &req); err != nil {
+		return err
+	}
+
+	resp, _, err := r.issueServiceAccountKey(ctx, account, req, nil)
+	if errors.Is(err, db.ErrServiceAccountKeyLimit) {
+		return echo.NewHTTPError(http.StatusConflict,
+			fmt.Sprintf("A service account has at most %d active keys, revoke one first", serviceAccountMaxActiveKeys))
+	}
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusCreated, resp)
+}
+
+// RotateServiceAccountKey creates a new key for a service account and makes its active key expire
+// at the end of the grace period, so clients can switch to the new key without downtime.
+func (r *httpRoutes) RotateServiceAccountKey(ctx echo.Context) error {
+	account, err := r.serviceAccountParam(ctx)
+	if err != nil {
+		return err
+	}
+	var req api.RotateServiceAccountKeyRequest
+	if err := bindValidate(ctx, &req); err != nil {
+		return err
+	}
+	gracePeriod := serviceAccountDefaultGracePeriod
+	if req.GracePeriodHours != nil {
+		if *req.GracePeriodHours < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "grace_period_hours must be positive")
+		}
+		gracePeriod = time.Duration(*req.GracePeriodHours) * time.Hour
+	}
+	if gracePeriod > serviceAccountMaxGracePeriod {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("grace_period_hours cannot be more than %d", int(serviceAccountMaxGracePeriod/time.Hour)))
+	}
+
+	// The previous key overlaps with the new one until the end of the grace period
+	expireActiveAt := time.Now().Add(gracePeriod)
+	resp, expiringKeys, err := r.issueServiceAccountKey(ctx, account, req.CreateServiceAccountKeyRequest, &expireActiveAt)
+	if errors.Is(err, db.ErrServiceAccountKeyLimit) {
+		return echo.NewHTTPError(http.StatusConflict, "A rotation is already in progress, revoke the old key first")
+	}
+	if err != nil {
+		return err
+	}
+
+	ids := make([]uint, 0, len(expiringKeys))
+	for _, key := range expiringKeys {
+		ids = append(ids, key.ID)
+		_ = r.authCache.RemoveAPIKeyFromCache(ctx.Request().Context(), key.KeyHash)
+	}
+	r.logger.Info("Rotated service account key", zap.Uint("serviceAccountId", account.ID),
+		zap.Uint("newKeyId", resp.ID), zap.Uints("expiringKeyIds", ids), zap.Duration("gracePeriod", gracePeriod))
+
+	return ctx.JSON(http.StatusCreated, resp)
+}
+
+// issueServiceAccountKey signs and stores a new key of a service account. It returns db.ErrServiceAccountKeyLimit
+// if the account already has its maximum of active keys. If expireActiveAt is set, the active keys of the account
+// expire at that time at the latest and are returned.
+func (r *httpRoutes) issueServiceAccountKey(ctx echo.Context, account *db.ServiceAccount, req api.CreateServiceAccountKeyRequest,
+	expireActiveAt *time.Time) (*api.CreateAPIKeyResponse, []db.ApiKey, error) {
+	if r.platformPrivateKey == nil {
+		r.logger.Error("Platform private key is not configured, cannot create API key")
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "API key generation is disabled")
+	}
+	ttl, err := r.apiKeyTTL(req.ExpiresInDays)
+	if err != nil {
+		return nil, nil, err
+	}
+	allowedServices, allowedPathPrefixes, allowedMethods, err := normalizeAPIKeyScopes(req.Scopes)
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	name := req.Name
+	if name == "" {
+		name = account.Name
+	}
+	issuedAt := time.Now()
+	expiresAt := issuedAt.Add(ttl)
+
+	// The key identifies the account, not the admin creating it
+	subject := serviceAccountSubject(account.ID)
+	token, maskedKey, keyHash, err := signAPIKeyToken(r.platformPrivateKey, &userClaim{
+		Role:           account.Role,
+		Email:          serviceAccountEmail(account.Name),
+		ExternalUserID: subject,
+		IssuedAt:       issuedAt.Unix(),
+		ExpiresAt:      expiresAt.Unix(),
+	})
+	if err != nil {
+		r.logger.Error("Failed to sign API key token", zap.Error(err))
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate API key token")
+	}
+
+	accountID := account.ID
+	key := db.ApiKey{
+		Name:                name,
+		Role:                account.Role,
+		CreatorUserID:       subject,
+		IsActive:            true,
+		MaskedKey:           maskedKey,
+		KeyHash:             keyHash,
+		ExpiresAt:           &expiresAt,
+		ServiceAccountID:    &accountID,
+		AllowedServices:     allowedServices,
+		AllowedPathPrefixes: allowedPathPrefixes,
+		AllowedMethods:      allowedMethods,
+	}
+	expiringKeys, err := r.db.AddServiceAccountKey(&key, serviceAccountMaxActiveKeys, expireActiveAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrServiceAccountKeyLimit):
+			return nil, nil, err
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, nil, echo.NewHTTPError(http.StatusNotFound, "Service account not found")
+		}
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to save API key")
+	}
+	r.logger.Info("Created service account key", zap.Uint("id", key.ID), zap.Uint("serviceAccountId", account.ID),
+		zap.String("createdBy", httpserver.GetUserID(ctx)))
+
+	return &api.CreateAPIKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Active:    key.IsActive,
+		CreatedAt: key.CreatedAt,
+		RoleName:  key.Role,
+		ExpiresAt: expiresAt,
+		Scopes:    apiKeyScopesFromDB(key),
+		Token:     token,
+	}, expiringKeys, nil
+}
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/opengovern/og-util/pkg/httpserver"
+	"github.com/opengovern/opensecurity/services/auth/api"
+	"github.com/opengovern/opensecurity/services/auth/authcache"
+	"github.com/opengovern/opensecurity/services/auth/db"
+	"go.uber.org/zap"
+)
+
+// Reasons of automatic session revocations
+const (
+	sessionRevocationPasswordChanged = "password changed"
+	sessionRevocationRoleChanged     = "role changed"
+	sessionRevocationDeactivated     = "user deactivated"
+	sessionRevocationDeleted         = "user deleted"
+	sessionRevocationSignOut         = "signed out everywhere"
+)
+
+// sessionsRevokedBefore returns until when the sessions of a subject are revoked, in unix microseconds,
+// from the auth cache falling back to the database. It returns 0 if they were never revoked.
+func (s *Server) sessionsRevokedBefore(ctx context.Context, subject string) (int64, error) {
+	cached, err := s.authCache.GetSessionRevocation(ctx, subject)
+	if err == nil {
+		return cached.RevokedBefore, nil
+	}
+	if !errors.Is(err, authcache.ErrSessionRevocationNotFound) {
+		s.logger.Error("Session revocation cache error, falling back to DB", zap.Error(err))
+	}
+
+	revocation, err := s.db.GetSessionRevocation(subject)
+	if err != nil {
+		return 0, err
+	}
+	cached = &authcache.CachedSessionRevocation{}
+	if revocation != nil {
+		cached.RevokedBefore = revocation.RevokedBefore.UnixMicro() // As precise as postgres keeps it
+	}
+	if err := s.authCache.SetSessionRevocation(ctx, subject, cached); err != nil {
+		s.logger.Error("Failed to populate session revocation cache", zap.String("subject", subject), zap.Error(err))
+	}
+	return cached.RevokedBefore, nil
+}
+
+// sessionRevoked reports whether a token issued at iat, in unix seconds, was issued before the revocation.
+// The issue time is truncated to the second, so a token of the second the sessions were revoked in can not be
+// told apart and is rejected, unless the revocation fell exactly on that second.
+func sessionRevoked(iat, revokedBefore int64) bool {
+	return revokedBefore != 0 && iat*int64(time.Second/time.Microsecond) < revokedBefore
+}
+
+// revokeSessions rejects the OIDC tokens of a subject issued until now.
+func (s *Server) revokeSessions(ctx context.Context, subject, revokedBy, reason string) (*db.SessionRevocation, error) {
+	revocation, err := s.db.RevokeSessions(subject, time.Now(), revokedBy, reason)
+	if err != nil {
+		return nil, err
+	}
+	_ = s.authCache.RemoveSessionRevocation(ctx, subject)
+	return revocation, nil
+}
+
+func sessionRevocationToAPI(revocation *db.SessionRevocation) api.SessionRevocationResponse {
+	return api.SessionRevocationResponse{
+		Subject:       revocation.Subject,
+		RevokedBefore: revocation.RevokedBefore,
+		RevokedBy:     revocation.RevokedBy,
+		Reason:        revocation.Reason,
+	}
+}
+
+// --- HTTP handlers ---
+
+// sessionUserParam loads the user of the id path param.
+func (r *httpRoutes) sessionUserParam(ctx echo.Context) (*db.User, error) {
+	if _, err := strconv.ParseUint(ctx.Param("id"), 10, 64); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID format")
+	}
+	user, err := r.db.GetUser(ctx.Param("id"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve user")
+	}
+	if user == nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+	return user, nil
+}
+
+// RevokeUserSessions signs a user out of every session, its tokens issued until now are rejected.
+func (r *httpRoutes) RevokeUserSessions(ctx echo.Context) error {
+	user, err := r.sessionUserParam(ctx)
+	if err != nil {
+		return err
+	}
+	var req api.RevokeSessionsRequest
+	if err := bindValidate(ctx, &req); err != nil {
+		return err
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		reason = "revoked by an admin"
+	}
+
+	revocation, err := r.authServer.revokeSessions(ctx.Request().Context(), user.ExternalId, httpserver.GetUserID(ctx), reason)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke sessions")
+	}
+	return ctx.JSON(http.StatusOK, sessionRevocationToAPI(revocation))
+}
+
+// GetUserSessionRevocation returns the last session revocation of a user.
+func (r *httpRoutes) GetUserSessionRevocation(ctx echo.Context) error {
+	user, err := r.sessionUserParam(ctx)
+	if err != nil {
+		return err
+	}
+	revocation, err := r.db.GetSessionRevocation(user.ExternalId)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve session revocation")
+	}
+	if revocation == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Sessions of the user were never revoked")
+	}
+	return ctx.JSON(http.StatusOK, sessionRevocationToAPI(revocation))
+}
+
+// SignOutEverywhere revokes every session of the calling user, including the one making the request.
+func (r *httpRoutes) SignOutEverywhere(ctx echo.Context) error {
+	subject := httpserver.GetUserID(ctx)
+	if subject == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Cannot identify authenticated user")
+	}
+	user, err := r.db.GetUserByExternalID(subject)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve user")
+	}
+	if user == nil {
+		// Service accounts have no sessions, their keys are revoked instead
+		return echo.NewHTTPError(http.StatusBadRequest, "Only users have sessions to sign out of")
+	}
+
+	revocation, err := r.authServer.revokeSessions(ctx.Request().Context(), subject, subject, sessionRevocationSignOut)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke sessions")
+	}
+	return ctx.JSON(http.StatusOK, sessionRevocationToAPI(revocation))
+}
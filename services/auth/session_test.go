@@ -0,0 +1,21 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionRevoked(t *testing.T) {
+	revokedAt := time.Date(2026, 3, 1, 10, 0, 5, 400_000_000, time.UTC)
+	revokedBefore := revokedAt.UnixMicro()
+
+	assert.False(t, sessionRevoked(revokedAt.Unix(), 0), "never revoked")
+	assert.True(t, sessionRevoked(revokedAt.Unix()-1, revokedBefore))
+	assert.True(t, sessionRevoked(revokedAt.Unix(), revokedBefore), "the second of the revocation can not be ordered")
+	assert.False(t, sessionRevoked(revokedAt.Unix()+1, revokedBefore), "a sign in right after the revocation is kept")
+
+	onTheSecond := revokedAt.Truncate(time.Second)
+	assert.False(t, sessionRevoked(onTheSecond.Unix(), onTheSecond.UnixMicro()))
+}
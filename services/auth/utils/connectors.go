@@ -2,7 +2,10 @@ package utils
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,20 +13,24 @@ import (
 	"strings"
 
 	dexapi "github.com/dexidp/dex/api/v2"
+	"github.com/opengovern/opensecurity/services/auth/api"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
 
 type CreateConnectorRequest struct {
-	ConnectorType    string `json:"connector_type" validate:"required,oneof=oidc"`                                  // 'oidc' is supported for now
-	ConnectorSubType string `json:"connector_sub_type" validate:"omitempty,oneof=general google-workspace entraid"` // Optional sub-type
-	Issuer           string `json:"issuer,omitempty" validate:"omitempty,url"`
-	TenantID         string `json:"tenant_id,omitempty" validate:"omitempty,uuid"`
-	ClientID         string `json:"client_id" validate:"required"`
-	ClientSecret     string `json:"client_secret" validate:"required"`
-	ID               string `json:"id,omitempty"`   // Optional
-	Name             string `json:"name,omitempty"` // Optional
+	ConnectorType    string                         `json:"connector_type" validate:"required,oneof=oidc ldap saml"`
+	ConnectorSubType string                         `json:"connector_sub_type" validate:"omitempty,oneof=general google-workspace entraid active-directory openldap"` // Optional sub-type
+	Issuer           string                         `json:"issuer,omitempty" validate:"omitempty,url"`
+	TenantID         string                         `json:"tenant_id,omitempty" validate:"omitempty,uuid"`
+	ClientID         string                         `json:"client_id" validate:"required_without_all=LDAP SAML"`
+	ClientSecret     string                         `json:"client_secret" validate:"required_without_all=LDAP SAML"`
+	ID               string                         `json:"id,omitempty"`   // Optional
+	Name             string                         `json:"name,omitempty"` // Optional
+	LDAP             *api.LDAPConnectorConfig       `json:"ldap,omitempty"`
+	SAML             *api.SAMLConnectorConfig       `json:"saml,omitempty"`
+	AttributeMapping *api.ConnectorAttributeMapping `json:"attribute_mapping,omitempty"`
 }
 type CreateAuth0ConnectorRequest struct {
 	Issuer       string `json:"issuer,omitempty" validate:"omitempty,url"`
@@ -32,15 +39,18 @@ type CreateAuth0ConnectorRequest struct {
 	Domain       string `json:"domain" validate:"required"`
 }
 type UpdateConnectorRequest struct {
-	ConnectorID      string `json:"connector_id" validate:"required"`
-	ConnectorType    string `json:"connector_type" validate:"required,oneof=oidc"`                                  // 'oidc' is supported for now
-	ConnectorSubType string `json:"connector_sub_type" validate:"omitempty,oneof=general google-workspace entraid"` // Optional sub-type
-	Issuer           string `json:"issuer,omitempty" validate:"omitempty,url"`
-	TenantID         string `json:"tenant_id,omitempty" validate:"omitempty,uuid"`
-	ClientID         string `json:"client_id" validate:"required"`
-	ClientSecret     string `json:"client_secret" validate:"required"`
-	ID               string `json:"id,omitempty"`   // Optional
-	Name             string `json:"name,omitempty"` // Optional
+	ConnectorID      string                         `json:"connector_id" validate:"required"`
+	ConnectorType    string                         `json:"connector_type" validate:"required,oneof=oidc ldap saml"`
+	ConnectorSubType string                         `json:"connector_sub_type" validate:"omitempty,oneof=general google-workspace entraid active-directory openldap"` // Optional sub-type
+	Issuer           string                         `json:"issuer,omitempty" validate:"omitempty,url"`
+	TenantID         string                         `json:"tenant_id,omitempty" validate:"omitempty,uuid"`
+	ClientID         string                         `json:"client_id" validate:"required_without_all=LDAP SAML"`
+	ClientSecret     string                         `json:"client_secret" validate:"required_without_all=LDAP SAML"`
+	ID               string                         `json:"id,omitempty"`   // Optional
+	Name             string                         `json:"name,omitempty"` // Optional
+	LDAP             *api.LDAPConnectorConfig       `json:"ldap,omitempty"`
+	SAML             *api.SAMLConnectorConfig       `json:"saml,omitempty"`
+	AttributeMapping *api.ConnectorAttributeMapping `json:"attribute_mapping,omitempty"`
 }
 
 type OIDCConfig struct {
@@ -56,17 +66,27 @@ type OIDCConfig struct {
 }
 
 type ConnectorCreator func(params CreateConnectorRequest) (*dexapi.CreateConnectorReq, error)
+type ConnectorUpdater func(params UpdateConnectorRequest) (*dexapi.UpdateConnectorReq, error)
 
 var connectorCreators = map[string]ConnectorCreator{
 	"oidc": CreateOIDCConnector,
-	// Future connector types can be added here, e.g., "saml": (*DexClient).CreateSAMLConnector
+	"ldap": CreateLDAPConnector,
+	"saml": CreateSAMLConnector,
+}
+var connectorUpdaters = map[string]ConnectorUpdater{
+	"oidc": UpdateOIDCConnector,
+	"ldap": UpdateLDAPConnector,
+	"saml": UpdateSAMLConnector,
 }
 var SupportedConnectors = map[string][]string{
 	"oidc": {"general", "google-workspace", "entraid"},
-	// Add more connector types and their sub-types here as needed.
+	"ldap": {"active-directory", "openldap"},
+	"saml": {"general"},
 }
 var SupportedConnectorsNames = map[string][]string{
 	"oidc": {"General OIDC", "Google Workspaces", "AzureAD/EntraID"},
+	"ldap": {"Active Directory", "OpenLDAP"},
+	"saml": {"SAML 2.0"},
 }
 
 func CreateOIDCConnector(params CreateConnectorRequest) (*dexapi.CreateConnectorReq, error) {
@@ -217,6 +237,31 @@ func fetchEntraIDIssuer(tenantID string) (string, error) {
 	return config.Issuer, nil
 }
 
+// parseCertificates parses the PEM encoded certificates of a CA bundle.
+func parseCertificates(data string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM encoded certificate found")
+	}
+	return certs, nil
+}
+
 func UpdateOIDCConnector(params UpdateConnectorRequest) (*dexapi.UpdateConnectorReq, error) {
 	var newOIDCConfig OIDCConfig
 
@@ -256,6 +301,7 @@ func UpdateOIDCConnector(params UpdateConnectorRequest) (*dexapi.UpdateConnector
 
 	req := &dexapi.UpdateConnectorReq{
 		Id:        params.ID,
+		NewType:   "oidc",
 		NewConfig: configBytes,
 	}
 
@@ -278,6 +324,9 @@ func IsSupportedSubType(connectorType, subType string) bool {
 func GetConnectorCreator(connectorType string) ConnectorCreator {
 	return connectorCreators[connectorType]
 }
+func GetConnectorUpdater(connectorType string) ConnectorUpdater {
+	return connectorUpdaters[connectorType]
+}
 func GetSupportedConnectors(connectorType string) []string {
 	return SupportedConnectors[connectorType]
 }
@@ -0,0 +1,417 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	dexapi "github.com/dexidp/dex/api/v2"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/opengovern/opensecurity/services/auth/api"
+)
+
+const ldapTestTimeout = 10 * time.Second
+
+// LDAPUserMatcher matches users to the groups listing them
+type LDAPUserMatcher struct {
+	UserAttr  string `json:"userAttr"`
+	GroupAttr string `json:"groupAttr"`
+}
+
+type LDAPUserSearch struct {
+	BaseDN                string `json:"baseDN"`
+	Filter                string `json:"filter,omitempty"`
+	Username              string `json:"username"`
+	Scope                 string `json:"scope,omitempty"`
+	IDAttr                string `json:"idAttr"`
+	EmailAttr             string `json:"emailAttr"`
+	NameAttr              string `json:"nameAttr,omitempty"`
+	PreferredUsernameAttr string `json:"preferredUsernameAttr,omitempty"`
+}
+
+type LDAPGroupSearch struct {
+	BaseDN       string            `json:"baseDN"`
+	Filter       string            `json:"filter,omitempty"`
+	Scope        string            `json:"scope,omitempty"`
+	UserMatchers []LDAPUserMatcher `json:"userMatchers"`
+	NameAttr     string            `json:"nameAttr"`
+}
+
+// LDAPConfig is the configuration of the Dex ldap connector
+type LDAPConfig struct {
+	Host               string           `json:"host"`
+	InsecureNoSSL      bool             `json:"insecureNoSSL"`
+	InsecureSkipVerify bool             `json:"insecureSkipVerify"`
+	StartTLS           bool             `json:"startTLS"`
+	RootCAData         []byte           `json:"rootCAData,omitempty"`
+	BindDN             string           `json:"bindDN,omitempty"`
+	BindPW             string           `json:"bindPW,omitempty"`
+	UsernamePrompt     string           `json:"usernamePrompt,omitempty"`
+	UserSearch         LDAPUserSearch   `json:"userSearch"`
+	GroupSearch        *LDAPGroupSearch `json:"groupSearch,omitempty"`
+}
+
+// ldapDefaults holds the schema of a directory sub-type
+type ldapDefaults struct {
+	usernameAttr, userFilter                  string
+	idAttr, emailAttr, nameAttr, prefUserAttr string
+	groupFilter, groupMemberAttr, groupName   string
+	usernamePrompt                            string
+}
+
+var ldapSubTypeDefaults = map[string]ldapDefaults{
+	"active-directory": {
+		usernameAttr: "sAMAccountName", userFilter: "(objectClass=person)",
+		idAttr: "DN", emailAttr: "mail", nameAttr: "displayName", prefUserAttr: "sAMAccountName",
+		groupFilter: "(objectClass=group)", groupMemberAttr: "member", groupName: "cn",
+		usernamePrompt: "Username",
+	},
+	"openldap": {
+		usernameAttr: "uid", userFilter: "(objectClass=person)",
+		idAttr: "DN", emailAttr: "mail", nameAttr: "cn", prefUserAttr: "uid",
+		groupFilter: "(objectClass=groupOfNames)", groupMemberAttr: "member", groupName: "cn",
+		usernamePrompt: "Username",
+	},
+}
+
+func orDefault(value, def string) string {
+	if value = strings.TrimSpace(value); value != "" {
+		return value
+	}
+	return def
+}
+
+// BuildLDAPConfig validates an LDAP connector configuration and fills the attributes
+// left empty with the defaults of its sub-type.
+func BuildLDAPConfig(subType string, cfg *api.LDAPConnectorConfig, mapping *api.ConnectorAttributeMapping) (*LDAPConfig, error) {
+	if cfg == nil {
+		return nil, errors.New("ldap configuration is required for ldap connectors")
+	}
+	defaults, ok := ldapSubTypeDefaults[subType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported connector_sub_type: %s", subType)
+	}
+	if mapping == nil {
+		mapping = &api.ConnectorAttributeMapping{}
+	}
+	if cfg.InsecureNoSSL && cfg.StartTLS {
+		return nil, errors.New("insecure_no_ssl and start_tls cannot be used together")
+	}
+
+	host := strings.TrimSpace(cfg.Host)
+	if host == "" {
+		return nil, errors.New("ldap host is required")
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		// Same default ports as Dex, StartTLS upgrades a plain connection
+		if cfg.InsecureNoSSL || cfg.StartTLS {
+			host = net.JoinHostPort(host, "389")
+		} else {
+			host = net.JoinHostPort(host, "636")
+		}
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			return nil, fmt.Errorf("invalid ldap host %s: %w", cfg.Host, err)
+		}
+	}
+
+	config := &LDAPConfig{
+		Host:               host,
+		InsecureNoSSL:      cfg.InsecureNoSSL,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		StartTLS:           cfg.StartTLS,
+		BindDN:             strings.TrimSpace(cfg.BindDN),
+		BindPW:             cfg.BindPassword,
+		UsernamePrompt:     orDefault(cfg.UsernamePrompt, defaults.usernamePrompt),
+		UserSearch: LDAPUserSearch{
+			BaseDN:                strings.TrimSpace(cfg.UserBaseDN),
+			Filter:                orDefault(cfg.UserFilter, defaults.userFilter),
+			Username:              orDefault(cfg.UsernameAttr, defaults.usernameAttr),
+			Scope:                 "sub",
+			IDAttr:                orDefault(mapping.ID, defaults.idAttr),
+			EmailAttr:             orDefault(mapping.Email, defaults.emailAttr),
+			NameAttr:              orDefault(mapping.Name, defaults.nameAttr),
+			PreferredUsernameAttr: orDefault(mapping.PreferredUsername, defaults.prefUserAttr),
+		},
+	}
+	if strings.TrimSpace(cfg.RootCA) != "" {
+		if _, err := parseCertificates(cfg.RootCA); err != nil {
+			return nil, fmt.Errorf("invalid root_ca: %w", err)
+		}
+		config.RootCAData = []byte(cfg.RootCA)
+	}
+	if config.BindDN == "" && config.BindPW != "" {
+		return nil, errors.New("bind_password requires a bind_dn")
+	}
+	if config.BindDN != "" {
+		if _, err := ldap.ParseDN(config.BindDN); err != nil {
+			return nil, fmt.Errorf("invalid bind_dn: %w", err)
+		}
+	}
+	if _, err := ldap.ParseDN(config.UserSearch.BaseDN); err != nil || config.UserSearch.BaseDN == "" {
+		return nil, fmt.Errorf("invalid user_base_dn: %s", cfg.UserBaseDN)
+	}
+	if _, err := ldap.CompileFilter(config.UserSearch.Filter); err != nil {
+		return nil, fmt.Errorf("invalid user_filter: %w", err)
+	}
+
+	if groupBaseDN := strings.TrimSpace(cfg.GroupBaseDN); groupBaseDN != "" {
+		if _, err := ldap.ParseDN(groupBaseDN); err != nil {
+			return nil, fmt.Errorf("invalid group_base_dn: %w", err)
+		}
+		config.GroupSearch = &LDAPGroupSearch{
+			BaseDN: groupBaseDN,
+			Filter: orDefault(cfg.GroupFilter, defaults.groupFilter),
+			Scope:  "sub",
+			UserMatchers: []LDAPUserMatcher{{
+				UserAttr:  "DN",
+				GroupAttr: orDefault(cfg.GroupMemberAttr, defaults.groupMemberAttr),
+			}},
+			NameAttr: orDefault(mapping.Groups, defaults.groupName),
+		}
+		if _, err := ldap.CompileFilter(config.GroupSearch.Filter); err != nil {
+			return nil, fmt.Errorf("invalid group_filter: %w", err)
+		}
+	}
+	return config, nil
+}
+
+func CreateLDAPConnector(params CreateConnectorRequest) (*dexapi.CreateConnectorReq, error) {
+	config, err := BuildLDAPConfig(params.ConnectorSubType, params.LDAP, params.AttributeMapping)
+	if err != nil {
+		return nil, err
+	}
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal LDAP config: %w", err)
+	}
+	return &dexapi.CreateConnectorReq{
+		Connector: &dexapi.Connector{
+			Id:     params.ID,
+			Type:   "ldap",
+			Name:   params.Name,
+			Config: configBytes,
+		},
+	}, nil
+}
+
+func UpdateLDAPConnector(params UpdateConnectorRequest) (*dexapi.UpdateConnectorReq, error) {
+	config, err := BuildLDAPConfig(params.ConnectorSubType, params.LDAP, params.AttributeMapping)
+	if err != nil {
+		return nil, err
+	}
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal new LDAP config: %w", err)
+	}
+	return &dexapi.UpdateConnectorReq{
+		Id:        params.ID,
+		NewType:   "ldap",
+		NewConfig: configBytes,
+	}, nil
+}
+
+// KeepLDAPBindPassword fills the bind password left out of an update with the one of the current Dex
+// configuration, so the password does not have to be sent again. It is only kept for the same bind DN.
+func KeepLDAPBindPassword(cfg *api.LDAPConnectorConfig, current *dexapi.Connector) error {
+	if cfg == nil || cfg.BindPassword != "" || strings.TrimSpace(cfg.BindDN) == "" {
+		return nil
+	}
+	if current == nil || current.Type != "ldap" {
+		return errors.New("bind_password is required")
+	}
+	var currentConfig LDAPConfig
+	if err := json.Unmarshal(current.Config, &currentConfig); err != nil {
+		return fmt.Errorf("failed to read the current ldap config: %w", err)
+	}
+	if !strings.EqualFold(currentConfig.BindDN, strings.TrimSpace(cfg.BindDN)) {
+		return errors.New("bind_password is required when bind_dn changes")
+	}
+	cfg.BindPassword = currentConfig.BindPW
+	return nil
+}
+
+// --- Connection test ---
+
+// dialLDAP connects to the server the way the Dex connector does.
+func dialLDAP(config *LDAPConfig) (*ldap.Conn, error) {
+	host, _, _ := net.SplitHostPort(config.Host)
+	tlsConfig := &tls.Config{ServerName: host, InsecureSkipVerify: config.InsecureSkipVerify}
+	if len(config.RootCAData) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(config.RootCAData) {
+			return nil, errors.New("no certificate found in root_ca")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	dialer := ldap.DialWithDialer(&net.Dialer{Timeout: ldapTestTimeout})
+
+	var conn *ldap.Conn
+	var err error
+	switch {
+	case config.InsecureNoSSL:
+		conn, err = ldap.DialURL("ldap://"+config.Host, dialer)
+	case config.StartTLS:
+		conn, err = ldap.DialURL("ldap://"+config.Host, dialer)
+		if err == nil {
+			if err = conn.StartTLS(tlsConfig); err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("start tls: %w", err)
+			}
+		}
+	default:
+		conn, err = ldap.DialURL("ldaps://"+config.Host, dialer, ldap.DialWithTLSConfig(tlsConfig))
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(ldapTestTimeout)
+	return conn, nil
+}
+
+// ldapEntryAttr returns an attribute of an entry, "DN" being the entry DN.
+func ldapEntryAttr(entry *ldap.Entry, attr string) string {
+	if attr == "" {
+		return ""
+	}
+	if strings.EqualFold(attr, "DN") {
+		return entry.DN
+	}
+	return entry.GetAttributeValue(attr)
+}
+
+// TestLDAPConnection connects and binds to the directory, then searches for the given user and its groups.
+// With a password it also checks the user can sign in. It stops at the first failed step.
+func TestLDAPConnection(config *LDAPConfig, username, password string) (resp api.TestConnectorResponse) {
+	resp.Steps = []api.TestConnectorStep{}
+	step := func(name string, err error, message string) bool {
+		s := api.TestConnectorStep{Name: name, Success: err == nil, Message: message}
+		if err != nil {
+			s.Message = err.Error()
+		}
+		resp.Steps = append(resp.Steps, s)
+		return err == nil
+	}
+	defer func() {
+		resp.Success = true
+		for _, s := range resp.Steps {
+			resp.Success = resp.Success && s.Success
+		}
+	}()
+
+	conn, err := dialLDAP(config)
+	if !step("connect", err, "Connected to "+config.Host) {
+		return resp
+	}
+	defer conn.Close()
+
+	bind := func() error {
+		if config.BindDN == "" {
+			return nil
+		}
+		return conn.Bind(config.BindDN, config.BindPW)
+	}
+	bindMessage := "Bound as " + config.BindDN
+	if config.BindDN == "" {
+		bindMessage = "Anonymous search, no bind_dn configured"
+	}
+	if !step("bind", bind(), bindMessage) {
+		return resp
+	}
+
+	userSearch := config.UserSearch
+	if username == "" {
+		// Without a user, only check the base DN and filter can be searched
+		_, err := conn.Search(ldap.NewSearchRequest(userSearch.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+			1, int(ldapTestTimeout.Seconds()), false, userSearch.Filter, []string{"dn"}, nil))
+		if err != nil && ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+			err = nil
+		}
+		step("user_search", err, "Searched users under "+userSearch.BaseDN)
+		return resp
+	}
+
+	filter := fmt.Sprintf("(%s=%s)", userSearch.Username, ldap.EscapeFilter(username))
+	if userSearch.Filter != "" {
+		filter = fmt.Sprintf("(&%s%s)", userSearch.Filter, filter)
+	}
+	attrs := []string{userSearch.IDAttr, userSearch.EmailAttr, userSearch.NameAttr, userSearch.PreferredUsernameAttr}
+	if config.GroupSearch != nil {
+		for _, matcher := range config.GroupSearch.UserMatchers {
+			attrs = append(attrs, matcher.UserAttr)
+		}
+	}
+	result, err := conn.Search(ldap.NewSearchRequest(userSearch.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(ldapTestTimeout.Seconds()), false, filter, attrs, nil))
+	if err == nil {
+		switch len(result.Entries) {
+		case 0:
+			err = fmt.Errorf("no user matches %s", filter)
+		case 1:
+		default:
+			err = fmt.Errorf("several users match %s", filter)
+		}
+	}
+	if !step("user_search", err, "Found user") {
+		return resp
+	}
+	entry := result.Entries[0]
+	user := &api.TestConnectorUser{
+		ID:                ldapEntryAttr(entry, userSearch.IDAttr),
+		Email:             ldapEntryAttr(entry, userSearch.EmailAttr),
+		Name:              ldapEntryAttr(entry, userSearch.NameAttr),
+		PreferredUsername: ldapEntryAttr(entry, userSearch.PreferredUsernameAttr),
+		Groups:            []string{},
+	}
+	resp.User = user
+	resp.Steps[len(resp.Steps)-1].Message = "Found user " + entry.DN
+	var mappingErr error
+	if user.Email == "" {
+		mappingErr = fmt.Errorf("user has no %s attribute, the email is required to sign in", userSearch.EmailAttr)
+	}
+	step("attribute_mapping", mappingErr, "Mapped user attributes")
+
+	if password != "" {
+		err := conn.Bind(entry.DN, password)
+		if !step("user_bind", err, "User credentials accepted") {
+			return resp
+		}
+		// Groups are searched with the service account, as Dex does
+		if !step("rebind", bind(), bindMessage) {
+			return resp
+		}
+	}
+
+	if config.GroupSearch == nil {
+		return resp
+	}
+	groupSearch := config.GroupSearch
+	var groupErr error
+	for _, matcher := range groupSearch.UserMatchers {
+		value := ldapEntryAttr(entry, matcher.UserAttr)
+		if value == "" {
+			continue
+		}
+		filter := fmt.Sprintf("(%s=%s)", matcher.GroupAttr, ldap.EscapeFilter(value))
+		if groupSearch.Filter != "" {
+			filter = fmt.Sprintf("(&%s%s)", groupSearch.Filter, filter)
+		}
+		result, err := conn.Search(ldap.NewSearchRequest(groupSearch.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+			0, int(ldapTestTimeout.Seconds()), false, filter, []string{groupSearch.NameAttr}, nil))
+		if err != nil {
+			groupErr = err
+			break
+		}
+		for _, group := range result.Entries {
+			if name := ldapEntryAttr(group, groupSearch.NameAttr); name != "" {
+				user.Groups = append(user.Groups, name)
+			}
+		}
+	}
+	step("group_search", groupErr, fmt.Sprintf("Found %d groups", len(user.Groups)))
+	return resp
+}
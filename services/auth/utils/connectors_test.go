@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	dexapi "github.com/dexidp/dex/api/v2"
+	"github.com/opengovern/opensecurity/services/auth/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCertificatePEM(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestBuildLDAPConfig(t *testing.T) {
+	cfg := &api.LDAPConnectorConfig{
+		Host:        "ldap.example.com",
+		BindDN:      "cn=svc,dc=example,dc=com",
+		UserBaseDN:  "ou=people,dc=example,dc=com",
+		GroupBaseDN: "ou=groups,dc=example,dc=com",
+	}
+	config, err := BuildLDAPConfig("active-directory", cfg, &api.ConnectorAttributeMapping{Email: "userPrincipalName"})
+	require.NoError(t, err)
+	assert.Equal(t, "ldap.example.com:636", config.Host)
+	assert.Equal(t, "sAMAccountName", config.UserSearch.Username)
+	assert.Equal(t, "userPrincipalName", config.UserSearch.EmailAttr)
+	assert.Equal(t, "(objectClass=person)", config.UserSearch.Filter)
+	require.NotNil(t, config.GroupSearch)
+	assert.Equal(t, "(objectClass=group)", config.GroupSearch.Filter)
+	assert.Equal(t, "member", config.GroupSearch.UserMatchers[0].GroupAttr)
+
+	cfg.StartTLS = true
+	config, err = BuildLDAPConfig("openldap", cfg, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "ldap.example.com:389", config.Host)
+	assert.Equal(t, "uid", config.UserSearch.Username)
+	assert.Equal(t, "(objectClass=groupOfNames)", config.GroupSearch.Filter)
+
+	for name, invalid := range map[string]api.LDAPConnectorConfig{
+		"tls modes":   {Host: "ldap", UserBaseDN: "dc=example", InsecureNoSSL: true, StartTLS: true},
+		"base dn":     {Host: "ldap", UserBaseDN: "not a dn"},
+		"user filter": {Host: "ldap", UserBaseDN: "dc=example", UserFilter: "(uid="},
+		"password":    {Host: "ldap", UserBaseDN: "dc=example", BindPassword: "secret"},
+		"root ca":     {Host: "ldap", UserBaseDN: "dc=example", RootCA: "not a certificate"},
+	} {
+		_, err := BuildLDAPConfig("openldap", &invalid, nil)
+		assert.Error(t, err, name)
+	}
+	_, err = BuildLDAPConfig("entraid", cfg, nil)
+	assert.Error(t, err)
+}
+
+func TestBuildSAMLConfig(t *testing.T) {
+	t.Setenv("DEX_CALLBACK_URL", "https://platform.example.com/dex/callback,https://other/callback")
+	cfg := &api.SAMLConnectorConfig{SSOURL: " https://idp.example.com/sso ", CA: testCertificatePEM(t)}
+
+	config, err := BuildSAMLConfig("general", cfg, &api.ConnectorAttributeMapping{Groups: "memberOf"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://idp.example.com/sso", config.SSOURL)
+	assert.Equal(t, "https://platform.example.com/dex/callback", config.RedirectURI)
+	assert.Equal(t, "email", config.EmailAttr)
+	assert.Equal(t, "name", config.UsernameAttr)
+	assert.Equal(t, "memberOf", config.GroupsAttr)
+
+	_, err = BuildSAMLConfig("general", cfg, &api.ConnectorAttributeMapping{ID: "uid"})
+	assert.Error(t, err, "id mappings are ldap only")
+	_, err = BuildSAMLConfig("general", &api.SAMLConnectorConfig{SSOURL: "https://idp", CA: "invalid"}, nil)
+	assert.Error(t, err)
+	_, err = BuildSAMLConfig("openldap", cfg, nil)
+	assert.Error(t, err)
+}
+
+func TestUpdateConnectorSetsType(t *testing.T) {
+	req, err := UpdateLDAPConnector(UpdateConnectorRequest{
+		ID:               "ldap-1",
+		ConnectorSubType: "openldap",
+		LDAP:             &api.LDAPConnectorConfig{Host: "ldap:389", UserBaseDN: "dc=example", InsecureNoSSL: true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ldap", req.NewType)
+}
+
+func TestKeepLDAPBindPassword(t *testing.T) {
+	currentConfig, err := json.Marshal(LDAPConfig{BindDN: "cn=svc,dc=example,dc=com", BindPW: "secret"})
+	require.NoError(t, err)
+	current := &dexapi.Connector{Id: "ldap-1", Type: "ldap", Config: currentConfig}
+
+	cfg := &api.LDAPConnectorConfig{BindDN: "CN=svc,dc=example,dc=com"}
+	require.NoError(t, KeepLDAPBindPassword(cfg, current))
+	assert.Equal(t, "secret", cfg.BindPassword)
+
+	cfg = &api.LDAPConnectorConfig{BindDN: "cn=svc,dc=example,dc=com", BindPassword: "new"}
+	require.NoError(t, KeepLDAPBindPassword(cfg, current))
+	assert.Equal(t, "new", cfg.BindPassword)
+
+	assert.Error(t, KeepLDAPBindPassword(&api.LDAPConnectorConfig{BindDN: "cn=other,dc=example,dc=com"}, current))
+	assert.Error(t, KeepLDAPBindPassword(&api.LDAPConnectorConfig{BindDN: "cn=svc,dc=example,dc=com"}, nil))
+	assert.NoError(t, KeepLDAPBindPassword(&api.LDAPConnectorConfig{}, nil), "anonymous binds have no password")
+}
+
+func TestLDAPConnectionStopsAtFailedStep(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	config := &LDAPConfig{Host: address, InsecureNoSSL: true, BindDN: "cn=svc,dc=example,dc=com", BindPW: "secret"}
+	resp := TestLDAPConnection(config, "jane", "")
+	assert.False(t, resp.Success)
+	require.Len(t, resp.Steps, 2)
+	assert.True(t, resp.Steps[0].Success)
+	assert.Equal(t, "bind", resp.Steps[1].Name)
+	assert.False(t, resp.Steps[1].Success)
+
+	listener.Close()
+	resp = TestLDAPConnection(config, "", "")
+	assert.False(t, resp.Success)
+	require.Len(t, resp.Steps, 1)
+	assert.Equal(t, "connect", resp.Steps[0].Name)
+}
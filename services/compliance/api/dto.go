@@ -45,6 +45,11 @@ type BenchmarkAssignedEntities struct {
 	Integrations []BenchmarkAssignedIntegration `json:"integrations"`
 }
 
+type IntegrationAssignedFrameworks struct {
+	IntegrationID string   `json:"integrationID" example:"8e0f8e7a-1b1c-4e6f-b7e4-9c6af9d2b1c8"`
+	FrameworkIDs  []string `json:"frameworkIDs"`
+}
+
 type TopFieldRecord struct {
 	Integration  *integrationapi.Integration
 	ResourceType *coreClient.ResourceType
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
@@ -13,6 +14,8 @@ import (
 
 type ComplianceServiceClient interface {
 	ListAssignmentsByBenchmark(ctx *httpclient.Context, benchmarkID string) (*compliance.BenchmarkAssignedEntities, error)
+	ListAssignmentsByIntegration(ctx *httpclient.Context) ([]compliance.IntegrationAssignedFrameworks, error)
+	AddFrameworkAssignments(ctx *httpclient.Context, frameworkID string, integrationIDs []string) error
 	GetBenchmark(ctx *httpclient.Context, benchmarkID string) (*compliance.Benchmark, error)
 	GetBenchmarkSummary(ctx *httpclient.Context, benchmarkID string, connectionId []string, timeAt *time.Time) (*compliance.BenchmarkEvaluationSummary, error)
 	GetBenchmarkControls(ctx *httpclient.Context, benchmarkID string, connectionId []string, timeAt *time.Time) (*compliance.BenchmarkControlSummary, error)
@@ -47,6 +50,35 @@ func (s *complianceClient) ListAssignmentsByBenchmark(ctx *httpclient.Context, b
 	return &response, nil
 }
 
+func (s *complianceClient) ListAssignmentsByIntegration(ctx *httpclient.Context) ([]compliance.IntegrationAssignedFrameworks, error) {
+	url := fmt.Sprintf("%s/api/v1/assignments/integrations", s.baseURL)
+
+	var response []compliance.IntegrationAssignedFrameworks
+	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, ctx.ToHeaders(), nil, &response); err != nil {
+		if 400 <= statusCode && statusCode < 500 {
+			return nil, echo.NewHTTPError(statusCode, err.Error())
+		}
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *complianceClient) AddFrameworkAssignments(ctx *httpclient.Context, frameworkID string, integrationIDs []string) error {
+	url := fmt.Sprintf("%s/api/v1/frameworks/%s/assignments", s.baseURL, frameworkID)
+
+	payload, err := json.Marshal(compliance.AddAssignmentsRequest{Integrations: integrationIDs})
+	if err != nil {
+		return err
+	}
+	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodPut, url, ctx.ToHeaders(), payload, nil); err != nil {
+		if 400 <= statusCode && statusCode < 500 {
+			return echo.NewHTTPError(statusCode, err.Error())
+		}
+		return err
+	}
+	return nil
+}
+
 func (s *complianceClient) GetBenchmark(ctx *httpclient.Context, benchmarkID string) (*compliance.Benchmark, error) {
 	url := fmt.Sprintf("%s/api/v1/benchmarks/%s", s.baseURL, benchmarkID)
 
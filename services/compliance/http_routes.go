@@ -77,6 +77,7 @@ func (h *HttpHandler) Register(e *echo.Echo) {
 
 	assignments := v1.Group("/assignments")
 	assignments.GET("/benchmark/:benchmark_id", httpserver2.AuthorizeHandler(h.ListAssignmentsByBenchmark, authApi.ViewerRole))
+	assignments.GET("/integrations", httpserver2.AuthorizeHandler(h.ListAssignmentsByIntegration, authApi.ViewerRole))
 
 	complianceResults := v1.Group("/compliance_result")
 	complianceResults.POST("", httpserver2.AuthorizeHandler(h.GetComplianceResults, authApi.ViewerRole))
@@ -142,9 +143,12 @@ func bindValidate(ctx echo.Context, i any) error {
 	return nil
 }
 
-func (h *HttpHandler) getIntegrationIdFilterFromInputs(ctx context.Context, integrationIds []string, integrationGroup []string) ([]string, error) {
+// getIntegrationIdFilterFromInputs resolves the integration filter of a request, limited to the integrations
+// the caller is scoped to
+func (h *HttpHandler) getIntegrationIdFilterFromInputs(echoCtx echo.Context, integrationIds []string, integrationGroup []string) ([]string, error) {
+	ctx := echoCtx.Request().Context()
 	if len(integrationIds) == 0 && len(integrationGroup) == 0 {
-		return nil, nil
+		return httpserver2.ResolveConnectionIDs(echoCtx, nil)
 	}
 
 	if len(integrationIds) > 0 && len(integrationGroup) > 0 {
@@ -152,7 +156,7 @@ func (h *HttpHandler) getIntegrationIdFilterFromInputs(ctx context.Context, inte
 	}
 
 	if len(integrationIds) > 0 {
-		return integrationIds, nil
+		return httpserver2.ResolveConnectionIDs(echoCtx, integrationIds)
 	}
 
 	check := make(map[string]bool)
@@ -177,17 +181,13 @@ func (h *HttpHandler) getIntegrationIdFilterFromInputs(ctx context.Context, inte
 	}
 	integrationIds = integrationIDSChecked
 
-	return integrationIds, nil
+	return httpserver2.ResolveConnectionIDs(echoCtx, integrationIds)
 }
 
 func (h *HttpHandler) getIntegrationIdFilterFromParams(echoCtx echo.Context) ([]string, error) {
 	integrationIds := httpserver2.QueryArrayParam(echoCtx, IntegrationIDParam)
-	//integrationIds, err := httpserver2.ResolveIntegrationIDs(echoCtx, integrationIds)
-	//if err != nil {
-	//	return nil, err
-	//}
 	integrationGroup := httpserver2.QueryArrayParam(echoCtx, IntegrationGroupParam)
-	return h.getIntegrationIdFilterFromInputs(echoCtx.Request().Context(), integrationIds, integrationGroup)
+	return h.getIntegrationIdFilterFromInputs(echoCtx, integrationIds, integrationGroup)
 }
 
 var tracer = otel.Tracer("new_compliance")
@@ -212,7 +212,7 @@ func (h *HttpHandler) GetComplianceResults(echoCtx echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	req.Filters.IntegrationID, err = h.getIntegrationIdFilterFromInputs(echoCtx.Request().Context(), req.Filters.IntegrationID, req.Filters.IntegrationGroup)
+	req.Filters.IntegrationID, err = h.getIntegrationIdFilterFromInputs(echoCtx, req.Filters.IntegrationID, req.Filters.IntegrationGroup)
 	if err != nil {
 		return err
 	}
@@ -414,6 +414,9 @@ func (h *HttpHandler) GetComplianceResultEventsByComplianceResultID(echoCtx echo
 		ComplianceResultDriftEvents: make([]api.ComplianceResultDriftEvent, 0, len(findingEvents)),
 	}
 	for _, findingEvent := range findingEvents {
+		if httpserver2.CheckAccessToConnectionID(echoCtx, findingEvent.IntegrationID) != nil {
+			continue
+		}
 		response.ComplianceResultDriftEvents = append(response.ComplianceResultDriftEvents, api.GetAPIComplianceResultDriftEventFromESComplianceResultDriftEvent(findingEvent))
 	}
 
@@ -590,6 +593,10 @@ func (h *HttpHandler) GetSingleComplianceResultByComplianceResultID(echoCtx echo
 	if finding == nil {
 		return echo.NewHTTPError(http.StatusNotFound, "finding not found")
 	}
+	// Findings of integrations outside of the caller scope are reported as missing
+	if httpserver2.CheckAccessToConnectionID(echoCtx, finding.IntegrationID) != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "finding not found")
+	}
 
 	apiFinding := api.GetAPIComplianceResultFromESComplianceResult(*finding)
 
@@ -659,16 +666,11 @@ func (h *HttpHandler) GetComplianceResultFilterValues(echoCtx echo.Context) erro
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	req.IntegrationID, err = h.getIntegrationIdFilterFromInputs(echoCtx.Request().Context(), req.IntegrationID, req.IntegrationGroup)
+	req.IntegrationID, err = h.getIntegrationIdFilterFromInputs(echoCtx, req.IntegrationID, req.IntegrationGroup)
 	if err != nil {
 		return err
 	}
 
-	//req.IntegrationID, err = httpserver2.ResolveIntegrationIDs(echoCtx, req.IntegrationID)
-	//if err != nil {
-	//	return err
-	//}
-
 	if len(req.ComplianceStatus) == 0 {
 		req.ComplianceStatus = []api.ComplianceStatus{api.ComplianceStatusFailed}
 	}
@@ -1579,10 +1581,10 @@ func (h *HttpHandler) ListResourceFindings(echoCtx echo.Context) error {
 		req.Filters.IntegrationID = integrationIDs
 	}
 
-	//req.Filters.IntegrationID, err = httpserver2.ResolveIntegrationIDs(echoCtx, req.Filters.IntegrationID)
-	//if err != nil {
-	//	return err
-	//}
+	req.Filters.IntegrationID, err = httpserver2.ResolveConnectionIDs(echoCtx, req.Filters.IntegrationID)
+	if err != nil {
+		return err
+	}
 
 	if len(req.AfterSortKey) != 0 {
 		expectedLen := len(req.Sort) + 1
@@ -2732,16 +2734,12 @@ func (h *HttpHandler) GetControlSummary(echoCtx echo.Context) error {
 
 	controlID := echoCtx.Param("controlId")
 	integrationIds := httpserver2.QueryArrayParam(echoCtx, IntegrationIDParam)
-	//integrationIds, err := httpserver2.ResolveIntegrationIDs(echoCtx, integrationIds)
-	//if err != nil {
-	//	return err
-	//}
 	integrationGroup := httpserver2.QueryArrayParam(echoCtx, IntegrationGroupParam)
 
 	if len(integrationIds) == 0 && len(integrationGroup) == 0 {
 		integrationGroup = []string{"active"}
 	}
-	integrationIDs, err := h.getIntegrationIdFilterFromInputs(echoCtx.Request().Context(), integrationIds, integrationGroup)
+	integrationIDs, err := h.getIntegrationIdFilterFromInputs(echoCtx, integrationIds, integrationGroup)
 	if err != nil {
 		return err
 	}
@@ -2969,6 +2967,47 @@ func (h *HttpHandler) ListAssignmentsByBenchmark(echoCtx echo.Context) error {
 	return echoCtx.JSON(http.StatusOK, resp)
 }
 
+// ListAssignmentsByIntegration godoc
+//
+//	@Summary		Get explicit framework assignments of all integrations
+//	@Description	Retrieving the frameworks explicitly assigned to each integration
+//	@Security		BearerToken
+//	@Tags			benchmarks_assignment
+//	@Produce		json
+//	@Success		200	{object}	[]api.IntegrationAssignedFrameworks
+//	@Router			/compliance/api/v1/assignments/integrations [get]
+func (h *HttpHandler) ListAssignmentsByIntegration(echoCtx echo.Context) error {
+	ctx := echoCtx.Request().Context()
+
+	dbAssignments, err := h.db.ListBenchmarkAssignments(ctx)
+	if err != nil {
+		h.logger.Error("failed to list assignments", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list assignments")
+	}
+
+	frameworks := make(map[string][]string)
+	var integrationIDs []string
+	for _, assignment := range dbAssignments {
+		if assignment.IntegrationID == nil {
+			continue
+		}
+		if _, ok := frameworks[*assignment.IntegrationID]; !ok {
+			integrationIDs = append(integrationIDs, *assignment.IntegrationID)
+		}
+		frameworks[*assignment.IntegrationID] = append(frameworks[*assignment.IntegrationID], assignment.BenchmarkId)
+	}
+
+	result := make([]api.IntegrationAssignedFrameworks, 0, len(integrationIDs))
+	for _, integrationID := range integrationIDs {
+		result = append(result, api.IntegrationAssignedFrameworks{
+			IntegrationID: integrationID,
+			FrameworkIDs:  frameworks[integrationID],
+		})
+	}
+
+	return echoCtx.JSON(http.StatusOK, result)
+}
+
 // ListBenchmarksFiltered godoc
 //
 //	@Summary	List benchmarks filtered by integrations and other filters
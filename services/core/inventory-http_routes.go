@@ -487,6 +487,9 @@ func (h *HttpHandler) RunQuery(ctx echo.Context) error {
 				runQueryCache.Result.Bytes != nil {
 				var resp api.RunQueryResponse
 				err = json.Unmarshal(runQueryCache.Result.Bytes, &resp)
+				if err := restrictQueryResultToScope(ctx, &resp); err != nil {
+					return err
+				}
 
 				if req.ResultType != nil && strings.ToLower(*req.ResultType) == "csv" {
 					csvData, err := resp.ToCSV()
@@ -557,6 +560,10 @@ func (h *HttpHandler) RunQuery(ctx echo.Context) error {
 			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 		}
 	}
+	// The cache keeps the full result, it is restricted per caller
+	if err := restrictQueryResultToScope(ctx, resp); err != nil {
+		return err
+	}
 
 	if req.ResultType != nil && strings.ToLower(*req.ResultType) == "csv" {
 		csvData, err := resp.ToCSV()
@@ -1353,6 +1360,9 @@ func (h *HttpHandler) RunQueryByID(ctx echo.Context) error {
 		attribute.String("query title ", resp.Title),
 	))
 	span.End()
+	if err := restrictQueryResultToScope(ctx, resp); err != nil {
+		return err
+	}
 	select {
 	case <-newCtx.Done():
 		job, err := h.schedulerClient.RunQuery(&httpclient.Context{UserRole: api2.AdminRole}, req.ID)
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/opengovern/og-util/pkg/httpserver"
+	"github.com/opengovern/opensecurity/services/core/api"
+)
+
+// integrationIDColumn is the column every cloudql table exposes the integration of a row in
+const integrationIDColumn = "platform_integration_id"
+
+// restrictQueryResultToScope drops the rows of integrations outside of the caller's role bindings.
+// Results of scoped callers must include the integration column, otherwise they can not be attributed
+// to an integration and are refused.
+func restrictQueryResultToScope(ctx echo.Context, resp *api.RunQueryResponse) error {
+	if resp == nil || ctx.Request().Header.Get(httpserver.XPlatformUserConnectionsScope) == "" {
+		return nil
+	}
+
+	colIdx := -1
+	for idx, header := range resp.Headers {
+		if strings.ToLower(header) == integrationIDColumn {
+			colIdx = idx
+			break
+		}
+	}
+	if colIdx < 0 {
+		return echo.NewHTTPError(http.StatusForbidden,
+			"your access is limited to some integrations, the query must select "+integrationIDColumn)
+	}
+
+	result := make([][]any, 0, len(resp.Result))
+	for _, row := range resp.Result {
+		if len(row) <= colIdx {
+			continue
+		}
+		integrationID, ok := row[colIdx].(string)
+		if !ok || httpserver.CheckAccessToConnectionID(ctx, integrationID) != nil {
+			continue
+		}
+		result = append(result, row)
+	}
+	resp.Result = result
+	return nil
+}
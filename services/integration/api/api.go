@@ -8,12 +8,16 @@ import (
 	"github.com/opengovern/og-util/pkg/ticker"
 	"github.com/opengovern/og-util/pkg/vault"
 	"github.com/opengovern/opensecurity/pkg/utils"
+	complianceClient "github.com/opengovern/opensecurity/services/compliance/client"
 	coreClient "github.com/opengovern/opensecurity/services/core/client"
 	"github.com/opengovern/opensecurity/services/integration/api/credentials"
 	integration_type2 "github.com/opengovern/opensecurity/services/integration/api/integration-types"
 	"github.com/opengovern/opensecurity/services/integration/api/integrations"
+	integrationConfig "github.com/opengovern/opensecurity/services/integration/config"
 	"github.com/opengovern/opensecurity/services/integration/db"
 	integration_type "github.com/opengovern/opensecurity/services/integration/integration-type"
+	"github.com/opengovern/opensecurity/services/integration/secrets"
+	utils2 "github.com/opengovern/opensecurity/services/integration/utils"
 	"go.uber.org/zap"
 	"golang.org/x/net/context"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -32,9 +36,15 @@ type API struct {
 	masterSecretKey string
 	vault           vault.VaultSourceConfig
 
-	steampipeOption *steampipe.Option
+	steampipeOption   *steampipe.Option
+	healthCheckConfig integrationConfig.HealthCheckConfig
+	credentialsConfig integrationConfig.CredentialsConfig
+	orgSyncConfig     integrationConfig.OrgSyncConfig
 
-	coreClient coreClient.CoreServiceClient
+	coreClient       coreClient.CoreServiceClient
+	complianceClient complianceClient.ComplianceServiceClient
+	secretResolver   *secrets.Resolver
+	pluginVerifier   *utils2.PluginVerifier
 }
 
 func New(
@@ -46,25 +56,38 @@ func New(
 	typeManager *integration_type.IntegrationTypeManager,
 	elastic opengovernance.Client,
 	coreClient coreClient.CoreServiceClient,
+	complianceClient complianceClient.ComplianceServiceClient,
 	elasticConfig config.ElasticSearch,
+	healthCheckConfig integrationConfig.HealthCheckConfig,
+	credentialsConfig integrationConfig.CredentialsConfig,
+	orgSyncConfig integrationConfig.OrgSyncConfig,
+	secretResolver *secrets.Resolver,
+	pluginVerifier *utils2.PluginVerifier,
 ) *API {
 	return &API{
-		logger:          logger.Named("api"),
-		database:        db,
-		vault:           vault,
-		steampipeOption: steampipeOption,
-		kubeClient:      kubeClient,
-		typeManager:     typeManager,
-		elastic:         elastic,
-		elasticConfig:   elasticConfig,
-		coreClient:      coreClient,
+		logger:           logger.Named("api"),
+		database:         db,
+		vault:            vault,
+		steampipeOption:  steampipeOption,
+		kubeClient:       kubeClient,
+		typeManager:      typeManager,
+		elastic:          elastic,
+		elasticConfig:    elasticConfig,
+		coreClient:       coreClient,
+		complianceClient: complianceClient,
+
+		healthCheckConfig: healthCheckConfig,
+		credentialsConfig: credentialsConfig,
+		orgSyncConfig:     orgSyncConfig,
+		secretResolver:    secretResolver,
+		pluginVerifier:    pluginVerifier,
 	}
 }
 
 func (api *API) Register(e *echo.Echo) {
-	integrationsApi := integrations.New(api.vault, api.database, api.logger, api.steampipeOption, api.kubeClient, api.typeManager)
-	cred := credentials.New(api.vault, api.database, api.logger)
-	integrationType := integration_type2.New(api.typeManager, api.database, api.logger, api.elastic, api.coreClient, api.elasticConfig)
+	cred := credentials.New(api.vault, api.database, api.logger, api.typeManager, api.credentialsConfig, api.secretResolver)
+	integrationsApi := integrations.New(api.vault, api.database, api.logger, api.steampipeOption, api.kubeClient, api.typeManager, api.healthCheckConfig, api.orgSyncConfig, api.complianceClient, api.secretResolver, api.coreClient, cred)
+	integrationType := integration_type2.New(api.typeManager, api.database, api.logger, api.elastic, api.coreClient, api.elasticConfig, api.pluginVerifier)
 
 	integrationsApi.Register(e.Group("/api/v1/integrations"))
 	cred.Register(e.Group("/api/v1/credentials"))
@@ -73,6 +96,15 @@ func (api *API) Register(e *echo.Echo) {
 	utils.EnsureRunGoroutine(func() {
 		api.CheckPluginInstallTimeout(context.Background())
 	})
+	utils.EnsureRunGoroutine(func() {
+		integrationsApi.IntegrationGroupMembershipScheduler(context.Background())
+	})
+	utils.EnsureRunGoroutine(func() {
+		integrationsApi.IntegrationHealthCheckScheduler(context.Background())
+	})
+	utils.EnsureRunGoroutine(func() {
+		integrationsApi.OrgSyncScheduler(context.Background())
+	})
 }
 
 func (api *API) CheckPluginInstallTimeout(ctx context.Context) {
@@ -1,42 +1,72 @@
 package credentials
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/labstack/echo/v4"
 	"github.com/opengovern/og-util/pkg/api"
 	"github.com/opengovern/og-util/pkg/httpserver"
+	"github.com/opengovern/og-util/pkg/integration"
 	"github.com/opengovern/og-util/pkg/vault"
 	"github.com/opengovern/opensecurity/services/integration/api/models"
+	"github.com/opengovern/opensecurity/services/integration/config"
 	"github.com/opengovern/opensecurity/services/integration/db"
+	"github.com/opengovern/opensecurity/services/integration/entities"
+	integration_type "github.com/opengovern/opensecurity/services/integration/integration-type"
+	models2 "github.com/opengovern/opensecurity/services/integration/models"
+	"github.com/opengovern/opensecurity/services/integration/secrets"
 	"go.uber.org/zap"
 	ioutil "io/ioutil"
 	"net/http"
+	"strconv"
 	strings "strings"
+	"time"
 )
 
+const defaultExpiryWarningDays = 14
+
 type API struct {
-	vault    vault.VaultSourceConfig
-	logger   *zap.Logger
-	database db.Database
+	vault        vault.VaultSourceConfig
+	logger       *zap.Logger
+	database     db.Database
+	typesManager *integration_type.IntegrationTypeManager
+	secrets      *secrets.Resolver
+
+	expiryWarningDays int
 }
 
 func New(
 	vault vault.VaultSourceConfig,
 	database db.Database,
 	logger *zap.Logger,
+	typesManager *integration_type.IntegrationTypeManager,
+	credentialsConfig config.CredentialsConfig,
+	secretResolver *secrets.Resolver,
 ) API {
+	expiryWarningDays := credentialsConfig.ExpiryWarningDays
+	if expiryWarningDays <= 0 {
+		expiryWarningDays = defaultExpiryWarningDays
+	}
 	return API{
-		vault:    vault,
-		database: database,
-		logger:   logger.Named("credentials"),
+		vault:        vault,
+		database:     database,
+		logger:       logger.Named("credentials"),
+		typesManager: typesManager,
+		secrets:      secretResolver,
+
+		expiryWarningDays: expiryWarningDays,
 	}
 }
 
 func (h API) Register(g *echo.Group) {
 	g.GET("", httpserver.AuthorizeHandler(h.List, api.ViewerRole))
 	g.POST("/list", httpserver.AuthorizeHandler(h.CredentialsFilteredList, api.ViewerRole))
+	g.GET("/expiring", httpserver.AuthorizeHandler(h.ListExpiringCredentials, api.ViewerRole))
 	g.DELETE("/:credentialId", httpserver.AuthorizeHandler(h.Delete, api.EditorRole))
 	g.GET("/:credentialId", httpserver.AuthorizeHandler(h.Get, api.ViewerRole))
-	g.PUT("/:credentialId", httpserver.AuthorizeHandler(h.UpdateCredential, api.ViewerRole))
+	g.GET("/:credentialId/resolutions", httpserver.AuthorizeHandler(h.ListSecretResolutions, api.AdminRole))
+	g.PUT("/:credentialId", httpserver.AuthorizeHandler(h.UpdateCredential, api.EditorRole))
 }
 
 // Delete godoc
@@ -51,6 +81,9 @@ func (h API) Register(g *echo.Group) {
 //	@Router			/integration/api/v1/credentials/{credentialId} [delete]
 func (h API) Delete(c echo.Context) error {
 	credentialId := c.Param("credentialId")
+	if err := h.checkCredentialInScope(c, credentialId); err != nil {
+		return err
+	}
 
 	err := h.database.DeleteCredential(credentialId)
 	if err != nil {
@@ -76,10 +109,14 @@ func (h API) List(c echo.Context) error {
 		h.logger.Error("failed to list credentials", zap.Error(err))
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list credential")
 	}
+	credentials, err = h.filterInScope(c, credentials)
+	if err != nil {
+		return err
+	}
 
 	var items []models.Credential
 	for _, credential := range credentials {
-		item, err := credential.ToApi(true)
+		item, err := h.toApiCredential(credential, true)
 		if err != nil {
 			h.logger.Error("failed to convert credentials to API model", zap.Error(err))
 			return echo.NewHTTPError(http.StatusInternalServerError, "failed to convert credentials to API model")
@@ -95,17 +132,22 @@ func (h API) List(c echo.Context) error {
 
 // UpdateCredential godoc
 //
-//	@Summary		List credentials
-//	@Description	List credentials
+//	@Summary		Update credential
+//	@Description	Update credential description and expiry or rotate its secret. A new secret is validated against
+//	@Description	all the integrations using the credential and only replaces the current one if all health checks pass.
 //	@Security		BearerToken
 //	@Tags			credentials
 //	@Produce		json
 //	@Param			credentialId	path		string							true	"credentialId"
 //	@Param			request			body		models.UpdateCredentialRequest	true	"Request"
-//	@Success		200				{object}	models.ListCredentialsResponse
+//	@Success		200				{object}	models.CredentialRotationResult
+//	@Failure		400				{object}	models.CredentialRotationResult
 //	@Router			/integration/api/v1/credentials/{credentialId} [put]
 func (h API) UpdateCredential(c echo.Context) error {
 	credentialId := c.Param("credentialId")
+	if err := h.checkCredentialInScope(c, credentialId); err != nil {
+		return err
+	}
 
 	var req models.UpdateCredentialRequest
 
@@ -153,21 +195,86 @@ func (h API) UpdateCredential(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusNotFound, "credential not found")
 	}
 
-	mapData, err := h.vault.Decrypt(c.Request().Context(), credential.Secret)
+	// nothing is written until the new secret, if any, passed the health checks
+	var update db.CredentialUpdate
+	if req.Description != credential.Description {
+		update.Description = &req.Description
+	}
+	if req.ExpiresAt != nil {
+		update.Expiry = &db.CredentialExpiry{ExpiresAt: req.ExpiresAt, Source: models2.CredentialExpirySourceManual}
+	}
+
+	if credential.IsSecretReference() {
+		if len(req.Credentials) > 0 {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				fmt.Sprintf("credential secret is kept in %s, rotate it there or point the credential to another secret", credential.SecretBackend))
+		}
+		if req.SecretReference == nil || *req.SecretReference == credential.SecretReference {
+			if err = h.database.UpdateCredentialFields(credentialId, update); err != nil {
+				h.logger.Error("failed to update credential", zap.Error(err))
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to update credential")
+			}
+			// the secret may have been rotated in its backend, the next use reads it again
+			h.secrets.Invalidate(credentialId)
+			return c.NoContent(http.StatusOK)
+		}
+		return h.updateSecretReference(c, credential, *req.SecretReference, update)
+	}
+	if req.SecretReference != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "credential secret is stored in the platform and has no reference")
+	}
+	if len(req.Credentials) == 0 {
+		if err = h.database.UpdateCredentialFields(credentialId, update); err != nil {
+			h.logger.Error("failed to update credential", zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update credential")
+		}
+		return c.NoContent(http.StatusOK)
+	}
+
+	result, err := h.RotateSecret(c.Request().Context(), credential, req.Credentials, update)
+	if err != nil {
+		return err
+	}
+	if !result.Rotated {
+		return c.JSON(http.StatusBadRequest, result)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// RotateSecret merges the changes into the platform stored secret of the credential and, once the new secret passed
+// the health check of every integration using the credential, stores it along with the other changes of the update.
+// The result is not rotated when a health check failed.
+func (h API) RotateSecret(ctx context.Context, credential *models2.Credential, changes map[string]any, update db.CredentialUpdate) (*models.CredentialRotationResult, error) {
+	credentialId := credential.ID.String()
+	mapData, err := h.vault.Decrypt(ctx, credential.Secret)
 	if err != nil {
 		h.logger.Error("failed to decrypt secret", zap.Error(err))
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to decrypt config")
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to decrypt config")
 	}
-	for k, v := range req.Credentials {
+	for k, v := range changes {
 		mapData[k] = v
 	}
-	secret, err := h.vault.Encrypt(c.Request().Context(), mapData)
+
+	// the new secret should work for every integration using the credential before it replaces the current one
+	result, err := h.validateCredentialSecret(credential, mapData)
+	if err != nil {
+		return nil, err
+	}
+	for _, check := range result.Integrations {
+		if !check.Healthy {
+			h.logger.Info("credential rotation rejected", zap.String("credentialId", credentialId),
+				zap.String("integrationId", check.IntegrationID), zap.String("reason", check.Reason))
+			return result, nil
+		}
+	}
+
+	secret, err := h.vault.Encrypt(ctx, mapData)
 	if err != nil {
 		h.logger.Error("failed to encrypt secret", zap.Error(err))
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to encrypt config")
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to encrypt config")
 	}
 	masked := make(map[string]any)
-		for key, value := range req.Credentials {
+	for key, value := range changes {
 		strValue, ok := value.(string) // Ensure the value is a string
 		if !ok {
 			// If it's not a string, just skip masking
@@ -181,14 +288,196 @@ func (h API) UpdateCredential(c echo.Context) error {
 		} else {
 			masked[key] = "*****" + strValue
 		}
-			}
-	err = h.database.UpdateCredential(credentialId, secret,masked,req.Description)
+	}
+	rotatedAt := time.Now()
+	update.Secret = &secret
+	update.MaskedSecret = masked
+	update.RotatedAt = &rotatedAt
+	// the expiry of the previous secret does not apply to the new one
+	if update.Expiry == nil {
+		update.Expiry = parsedExpiry(mapData)
+	}
+	if err = h.database.UpdateCredentialFields(credentialId, update); err != nil {
+		h.logger.Error("failed to update credential", zap.Error(err))
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to update credential")
+	}
+
+	result.Rotated = true
+	return result, nil
+}
+
+// updateSecretReference points the credential to another secret in its backend once the secret passes the health
+// check of every integration using the credential
+func (h API) updateSecretReference(c echo.Context, credential *models2.Credential, reference string, update db.CredentialUpdate) error {
+	credentialId := credential.ID.String()
+	mapData, err := h.secrets.ResolveReference(c.Request().Context(), credential.ID, credential.SecretBackend, reference,
+		models2.CredentialSecretPurposeValidation)
+	if err != nil {
+		h.logger.Error("failed to resolve secret reference", zap.String("credentialId", credentialId), zap.Error(err))
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	result, err := h.validateCredentialSecret(credential, mapData)
 	if err != nil {
+		return err
+	}
+	for _, check := range result.Integrations {
+		if !check.Healthy {
+			h.logger.Info("credential secret reference update rejected", zap.String("credentialId", credentialId),
+				zap.String("integrationId", check.IntegrationID), zap.String("reason", check.Reason))
+			return c.JSON(http.StatusBadRequest, result)
+		}
+	}
+
+	rotatedAt := time.Now()
+	update.SecretReference = &reference
+	update.RotatedAt = &rotatedAt
+	if update.Expiry == nil {
+		update.Expiry = parsedExpiry(mapData)
+	}
+	if err = h.database.UpdateCredentialFields(credentialId, update); err != nil {
 		h.logger.Error("failed to update credential", zap.Error(err))
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update credential")
 	}
+	h.secrets.Invalidate(credentialId)
 
-	return c.NoContent(http.StatusOK)
+	result.Rotated = true
+	return c.JSON(http.StatusOK, result)
+}
+
+// parsedExpiry is the expiry read from a new secret, it clears the previous one when the secret does not tell
+func parsedExpiry(secret map[string]any) *db.CredentialExpiry {
+	expiresAt := entities.ParseCredentialExpiry(secret)
+	if expiresAt == nil {
+		return &db.CredentialExpiry{}
+	}
+	return &db.CredentialExpiry{ExpiresAt: expiresAt, Source: models2.CredentialExpirySourceAuto}
+}
+
+// validateCredentialSecret runs the health check of every integration using the credential with the given secret
+func (h API) validateCredentialSecret(credential *models2.Credential, secret map[string]any) (*models.CredentialRotationResult, error) {
+	result := models.CredentialRotationResult{
+		CredentialID: credential.ID.String(),
+		Integrations: []models.CredentialIntegrationCheck{},
+	}
+
+	integrations, err := h.database.ListIntegrationsByCredentialID(credential.ID.String())
+	if err != nil {
+		h.logger.Error("failed to list credential integrations", zap.Error(err))
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to list credential integrations")
+	}
+	var activeIntegrations []models2.Integration
+	for _, i := range integrations {
+		if i.State == integration.IntegrationStateArchived || i.State == integration.IntegrationStateSample {
+			continue
+		}
+		activeIntegrations = append(activeIntegrations, i)
+	}
+	if len(activeIntegrations) == 0 {
+		return &result, nil
+	}
+
+	integrationType, ok := h.typesManager.GetIntegrationTypeMap()[credential.IntegrationType]
+	if !ok || integrationType == nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("integration type %s is not loaded, the new secret can not be validated", credential.IntegrationType))
+	}
+	jsonData, err := json.Marshal(secret)
+	if err != nil {
+		h.logger.Error("failed to marshal json data", zap.Error(err))
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "failed to marshal json data")
+	}
+
+	for _, i := range activeIntegrations {
+		integrationApi, err := i.ToApi()
+		if err != nil {
+			h.logger.Error("failed to create integration api", zap.Error(err))
+			return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to create integration api")
+		}
+		check := models.CredentialIntegrationCheck{
+			IntegrationID: integrationApi.IntegrationID,
+			Name:          integrationApi.Name,
+			ProviderID:    integrationApi.ProviderID,
+		}
+		healthy, err := integrationType.HealthCheck(jsonData, integrationApi.ProviderID, integrationApi.Labels, integrationApi.Annotations)
+		switch {
+		case err != nil:
+			check.Reason = err.Error()
+		case !healthy:
+			check.Reason = "health check failed"
+		default:
+			check.Healthy = true
+		}
+		result.Integrations = append(result.Integrations, check)
+	}
+
+	return &result, nil
+}
+
+// ListExpiringCredentials godoc
+//
+//	@Summary		List expiring credentials
+//	@Description	List the credentials which are expired or expire within the given number of days
+//	@Security		BearerToken
+//	@Tags			credentials
+//	@Produce		json
+//	@Param			days	query		int	false	"days until expiry, defaults to the configured warning days"
+//	@Success		200		{object}	models.ListCredentialsResponse
+//	@Router			/integration/api/v1/credentials/expiring [get]
+func (h API) ListExpiringCredentials(c echo.Context) error {
+	days := h.expiryWarningDays
+	if daysStr := c.QueryParam("days"); daysStr != "" {
+		var err error
+		days, err = strconv.Atoi(daysStr)
+		if err != nil || days < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid days")
+		}
+	}
+
+	credentials, err := h.database.ListCredentialsExpiringBefore(time.Now().AddDate(0, 0, days))
+	if err != nil {
+		h.logger.Error("failed to list credentials", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list credential")
+	}
+	credentials, err = h.filterInScope(c, credentials)
+	if err != nil {
+		return err
+	}
+
+	items := make([]models.Credential, 0, len(credentials))
+	for _, credential := range credentials {
+		item, err := h.toApiCredential(credential, false)
+		if err != nil {
+			h.logger.Error("failed to convert credentials to API model", zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to convert credentials to API model")
+		}
+		items = append(items, *item)
+	}
+
+	return c.JSON(http.StatusOK, models.ListCredentialsResponse{
+		Credentials: items,
+		TotalCount:  len(items),
+	})
+}
+
+// toApiCredential converts the credential to the API model along with its expiry status
+func (h API) toApiCredential(credential models2.Credential, returnSecret bool) (*models.Credential, error) {
+	item, err := credential.ToApi(returnSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case item.ExpiresAt == nil:
+		item.ExpiryStatus = models.CredentialExpiryStatusUnknown
+	case item.ExpiresAt.Before(time.Now()):
+		item.ExpiryStatus = models.CredentialExpiryStatusExpired
+	case item.ExpiresAt.Before(time.Now().AddDate(0, 0, h.expiryWarningDays)):
+		item.ExpiryStatus = models.CredentialExpiryStatusExpiring
+	default:
+		item.ExpiryStatus = models.CredentialExpiryStatusOK
+	}
+	return item, nil
 }
 
 // CredentialsFilteredList godoc
@@ -213,10 +502,14 @@ func (h API) CredentialsFilteredList(c echo.Context) error {
 		h.logger.Error("failed to list credentials", zap.Error(err))
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list credential")
 	}
+	credentials, err = h.filterInScope(c, credentials)
+	if err != nil {
+		return err
+	}
 
 	var items []models.Credential
 	for _, credential := range credentials {
-		item, err := credential.ToApi(false)
+		item, err := h.toApiCredential(credential, false)
 		if err != nil {
 			h.logger.Error("failed to convert credentials to API model", zap.Error(err))
 			return echo.NewHTTPError(http.StatusInternalServerError, "failed to convert credentials to API model")
@@ -239,9 +532,14 @@ func (h API) CredentialsFilteredList(c echo.Context) error {
 //	@Produce		json
 //	@Success		200
 //	@Param			credentialId	path	string	true	"credentialId"
+//	@Param			purpose			query	string	false	"resolve a secret kept in an external backend for this use, admin only"	Enums(discovery,health_check,describe,task,validation)
 //	@Router			/integration/api/v1/credentials/{credentialId} [get]
 func (h API) Get(c echo.Context) error {
 	credentialId := c.Param("credentialId")
+	purpose := models2.CredentialSecretPurpose(c.QueryParam("purpose"))
+	if err := h.checkCredentialInScope(c, credentialId); err != nil {
+		return err
+	}
 
 	credential, err := h.database.GetCredential(credentialId)
 	if err != nil {
@@ -249,10 +547,73 @@ func (h API) Get(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get credential")
 	}
 
-	item, err := credential.ToApi(true)
+	item, err := h.toApiCredential(*credential, true)
 	if err != nil {
 		h.logger.Error("failed to convert credentials to API model", zap.Error(err))
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to convert integration to API model")
 	}
+
+	// describers and tasks get the referenced secret encrypted the same way as stored secrets
+	if credential.IsSecretReference() && purpose != "" {
+		if err = httpserver.RequireMinRole(c, api.AdminRole); err != nil {
+			return err
+		}
+		mapData, err := h.secrets.Resolve(c.Request().Context(), credential, purpose)
+		if err != nil {
+			h.logger.Error("failed to resolve secret", zap.String("credentialId", credentialId), zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to resolve credential secret")
+		}
+		item.Secret, err = h.vault.Encrypt(c.Request().Context(), mapData)
+		if err != nil {
+			h.logger.Error("failed to encrypt secret", zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to encrypt config")
+		}
+	}
 	return c.JSON(http.StatusOK, item)
 }
+
+// ListSecretResolutions godoc
+//
+//	@Summary		List credential secret resolutions
+//	@Description	List the audit of the latest resolutions of a credential secret kept in an external backend
+//	@Security		BearerToken
+//	@Tags			credentials
+//	@Produce		json
+//	@Param			credentialId	path		string	true	"credentialId"
+//	@Param			limit			query		int		false	"number of resolutions, defaults to 100"
+//	@Success		200				{object}	[]models.CredentialSecretResolution
+//	@Router			/integration/api/v1/credentials/{credentialId}/resolutions [get]
+func (h API) ListSecretResolutions(c echo.Context) error {
+	credentialId := c.Param("credentialId")
+	if err := h.checkCredentialInScope(c, credentialId); err != nil {
+		return err
+	}
+	limit := 100
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid limit")
+		}
+	}
+
+	resolutions, err := h.database.ListCredentialSecretResolutions(credentialId, limit)
+	if err != nil {
+		h.logger.Error("failed to list secret resolutions", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list secret resolutions")
+	}
+
+	items := make([]models.CredentialSecretResolution, 0, len(resolutions))
+	for _, r := range resolutions {
+		items = append(items, models.CredentialSecretResolution{
+			CredentialID:    r.CredentialID.String(),
+			SecretBackend:   string(r.SecretBackend),
+			SecretReference: r.SecretReference,
+			Purpose:         string(r.Purpose),
+			Success:         r.Success,
+			Error:           r.Error,
+			CreatedAt:       r.CreatedAt,
+		})
+	}
+	return c.JSON(http.StatusOK, items)
+}
@@ -0,0 +1,187 @@
+package credentials
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/opengovern/og-util/pkg/integration"
+	"github.com/opengovern/og-util/pkg/integration/interfaces"
+	"github.com/opengovern/opensecurity/services/integration/db"
+	integration_type "github.com/opengovern/opensecurity/services/integration/integration-type"
+	models2 "github.com/opengovern/opensecurity/services/integration/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testIntegrationType integration.Type = "test_account"
+
+// plainVault keeps secrets as plain JSON
+type plainVault struct{}
+
+func (plainVault) Encrypt(_ context.Context, data map[string]any) (string, error) {
+	b, err := json.Marshal(data)
+	return string(b), err
+}
+
+func (plainVault) Decrypt(_ context.Context, cypherText string) (map[string]any, error) {
+	var data map[string]any
+	err := json.Unmarshal([]byte(cypherText), &data)
+	return data, err
+}
+
+// healthCheckType passes the health check of the accounts accepting the secret's token
+type healthCheckType struct {
+	interfaces.IntegrationType
+	accepted map[string]string
+	checked  []string
+}
+
+func (t *healthCheckType) HealthCheck(jsonData []byte, providerId string, _ map[string]string, _ map[string]string) (bool, error) {
+	t.checked = append(t.checked, providerId)
+	var secret map[string]any
+	if err := json.Unmarshal(jsonData, &secret); err != nil {
+		return false, err
+	}
+	token, ok := t.accepted[providerId]
+	if !ok {
+		return false, errors.New("account not reachable")
+	}
+	return secret["token"] == token, nil
+}
+
+// jsonArg matches a JSON query argument equal to the expected value
+type jsonArg struct {
+	expected string
+}
+
+func (a jsonArg) Match(v driver.Value) bool {
+	var data []byte
+	switch value := v.(type) {
+	case string:
+		data = []byte(value)
+	case []byte:
+		data = value
+	default:
+		return false
+	}
+	var actual, expected any
+	if json.Unmarshal(data, &actual) != nil || json.Unmarshal([]byte(a.expected), &expected) != nil {
+		return false
+	}
+	return assert.ObjectsAreEqual(expected, actual)
+}
+
+func rotationAPI(t *testing.T, integrationType *healthCheckType) (API, sqlmock.Sqlmock) {
+	h, mock := mockAPI(t)
+	h.vault = plainVault{}
+	h.typesManager = &integration_type.IntegrationTypeManager{
+		IntegrationTypes: map[integration.Type]interfaces.IntegrationType{testIntegrationType: integrationType},
+	}
+	return h, mock
+}
+
+func testCredential(secret string) *models2.Credential {
+	return &models2.Credential{ID: uuid.New(), IntegrationType: testIntegrationType, Secret: secret}
+}
+
+func expectIntegrationStates(mock sqlmock.Sqlmock, credentialID string, states map[string]integration.IntegrationState) {
+	rows := sqlmock.NewRows([]string{"integration_id", "provider_id", "credential_id", "state"})
+	for providerID, state := range states {
+		rows.AddRow(uuid.NewString(), providerID, credentialID, state)
+	}
+	mock.ExpectQuery(`SELECT \* FROM "integrations" WHERE credential_id = \$1`).
+		WithArgs(credentialID).
+		WillReturnRows(rows)
+}
+
+func TestValidateCredentialSecret(t *testing.T) {
+	integrationType := &healthCheckType{accepted: map[string]string{"healthy": "new", "stale": "old"}}
+	h, mock := rotationAPI(t, integrationType)
+	credential := testCredential("")
+	expectIntegrationStates(mock, credential.ID.String(), map[string]integration.IntegrationState{
+		"healthy":     integration.IntegrationStateActive,
+		"stale":       integration.IntegrationStateInactive,
+		"unreachable": integration.IntegrationStateActive,
+		"archived":    integration.IntegrationStateArchived,
+		"sample":      integration.IntegrationStateSample,
+	})
+
+	result, err := h.validateCredentialSecret(credential, map[string]any{"token": "new"})
+	require.NoError(t, err)
+	assert.False(t, result.Rotated)
+	assert.ElementsMatch(t, []string{"healthy", "stale", "unreachable"}, integrationType.checked,
+		"archived and sample integrations are not checked")
+
+	reasons := make(map[string]string)
+	for _, check := range result.Integrations {
+		assert.Equal(t, check.Reason == "", check.Healthy)
+		reasons[check.ProviderID] = check.Reason
+	}
+	assert.Equal(t, map[string]string{
+		"healthy":     "",
+		"stale":       "health check failed",
+		"unreachable": "account not reachable",
+	}, reasons)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestValidateCredentialSecretWithoutActiveIntegrations(t *testing.T) {
+	integrationType := &healthCheckType{}
+	h, mock := rotationAPI(t, integrationType)
+	h.typesManager.IntegrationTypes = nil
+	credential := testCredential("")
+	expectIntegrationStates(mock, credential.ID.String(), map[string]integration.IntegrationState{
+		"archived": integration.IntegrationStateArchived,
+	})
+
+	// nothing to check, so the integration type does not have to be loaded
+	result, err := h.validateCredentialSecret(credential, map[string]any{"token": "new"})
+	require.NoError(t, err)
+	assert.Empty(t, result.Integrations)
+	assert.Empty(t, integrationType.checked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRotateSecretRejected(t *testing.T) {
+	h, mock := rotationAPI(t, &healthCheckType{accepted: map[string]string{"account": "old"}})
+	credential := testCredential(`{"token":"old","region":"eu-west-1"}`)
+	expectIntegrationStates(mock, credential.ID.String(), map[string]integration.IntegrationState{
+		"account": integration.IntegrationStateActive,
+	})
+
+	description := "rotated"
+	result, err := h.RotateSecret(context.Background(), credential, map[string]any{"token": "new"},
+		db.CredentialUpdate{Description: &description})
+	require.NoError(t, err)
+	assert.False(t, result.Rotated)
+	require.Len(t, result.Integrations, 1)
+	assert.False(t, result.Integrations[0].Healthy)
+	// the credential is left untouched, description included
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRotateSecret(t *testing.T) {
+	h, mock := rotationAPI(t, &healthCheckType{accepted: map[string]string{"account": "new-token"}})
+	credential := testCredential(`{"token":"old","region":"eu-west-1"}`)
+	expectIntegrationStates(mock, credential.ID.String(), map[string]integration.IntegrationState{
+		"account": integration.IntegrationStateActive,
+	})
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "credentials" SET "description"=\$1,"expires_at"=\$2,"expiry_source"=\$3,"masked_secret"=\$4,"rotated_at"=\$5,"secret"=\$6,"updated_at"=\$7 WHERE id = \$8`).
+		WithArgs("rotated", nil, "", jsonArg{`{"token":"*****token"}`}, sqlmock.AnyArg(),
+			jsonArg{`{"token":"new-token","region":"eu-west-1"}`}, sqlmock.AnyArg(), credential.ID.String()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	description := "rotated"
+	result, err := h.RotateSecret(context.Background(), credential, map[string]any{"token": "new-token"},
+		db.CredentialUpdate{Description: &description})
+	require.NoError(t, err)
+	assert.True(t, result.Rotated)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
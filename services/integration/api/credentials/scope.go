@@ -0,0 +1,72 @@
+package credentials
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/opengovern/og-util/pkg/httpserver"
+	models2 "github.com/opengovern/opensecurity/services/integration/models"
+	"go.uber.org/zap"
+)
+
+// scoped reports whether the caller's role bindings limit it to some integrations
+func scoped(c echo.Context) bool {
+	return c.Request().Header.Get(httpserver.XPlatformUserConnectionsScope) != ""
+}
+
+// allInScope reports whether every integration is in the caller's scope. Scoped callers have no access to an
+// empty set, as a credential without integrations may reach integrations outside of their scope once discovered.
+func allInScope(c echo.Context, integrations []models2.Integration) bool {
+	if !scoped(c) {
+		return true
+	}
+	if len(integrations) == 0 {
+		return false
+	}
+	for _, i := range integrations {
+		if httpserver.CheckAccessToConnectionID(c, i.IntegrationID.String()) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// checkCredentialInScope returns a not found error for credentials used by integrations outside of the caller's scope
+func (h API) checkCredentialInScope(c echo.Context, credentialID string) error {
+	if !scoped(c) {
+		return nil
+	}
+	integrations, err := h.database.ListIntegrationsByCredentialID(credentialID)
+	if err != nil {
+		h.logger.Error("failed to list credential integrations", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list credential integrations")
+	}
+	if !allInScope(c, integrations) {
+		return echo.NewHTTPError(http.StatusNotFound, "credential not found")
+	}
+	return nil
+}
+
+// filterInScope drops the credentials used by integrations outside of the caller's scope
+func (h API) filterInScope(c echo.Context, credentials []models2.Credential) ([]models2.Credential, error) {
+	if !scoped(c) {
+		return credentials, nil
+	}
+	integrations, err := h.database.ListIntegration(nil)
+	if err != nil {
+		h.logger.Error("failed to list integrations", zap.Error(err))
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to list integrations")
+	}
+	credentialIntegrations := make(map[string][]models2.Integration)
+	for _, i := range integrations {
+		credentialIntegrations[i.CredentialID.String()] = append(credentialIntegrations[i.CredentialID.String()], i)
+	}
+
+	var items []models2.Credential
+	for _, credential := range credentials {
+		if allInScope(c, credentialIntegrations[credential.ID.String()]) {
+			items = append(items, credential)
+		}
+	}
+	return items, nil
+}
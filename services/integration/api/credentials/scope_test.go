@@ -0,0 +1,136 @@
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/opengovern/og-util/pkg/httpserver"
+	"github.com/opengovern/opensecurity/services/integration/api/models"
+	"github.com/opengovern/opensecurity/services/integration/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func mockAPI(t *testing.T) (API, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	orm, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+	return API{logger: zap.NewNop(), database: db.NewDatabase(orm, orm), expiryWarningDays: defaultExpiryWarningDays}, mock
+}
+
+func scopedRequest(method, target, body, integrationIDs string) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if integrationIDs != "" {
+		req.Header.Set(httpserver.XPlatformUserConnectionsScope, integrationIDs)
+	}
+	rec := httptest.NewRecorder()
+	return echo.New().NewContext(req, rec), rec
+}
+
+func expectCredentialIntegrations(mock sqlmock.Sqlmock, credentialID string, integrationIDs ...string) {
+	rows := sqlmock.NewRows([]string{"integration_id", "credential_id"})
+	for _, id := range integrationIDs {
+		rows.AddRow(id, credentialID)
+	}
+	mock.ExpectQuery(`SELECT \* FROM "integrations" WHERE credential_id = \$1`).
+		WithArgs(credentialID).
+		WillReturnRows(rows)
+}
+
+func httpErrorCode(t *testing.T, err error) int {
+	var httpErr *echo.HTTPError
+	require.True(t, errors.As(err, &httpErr), "expected an HTTP error, got %v", err)
+	return httpErr.Code
+}
+
+func TestUpdateCredentialOutOfScope(t *testing.T) {
+	h, mock := mockAPI(t)
+	credentialID := uuid.NewString()
+	inScope, shared := uuid.NewString(), uuid.NewString()
+	expectCredentialIntegrations(mock, credentialID, inScope, shared)
+
+	c, _ := scopedRequest(http.MethodPut, "/", `{"credentials":{"token":"rotated"}}`, inScope)
+	c.SetParamNames("credentialId")
+	c.SetParamValues(credentialID)
+
+	err := h.UpdateCredential(c)
+	assert.Equal(t, http.StatusNotFound, httpErrorCode(t, err))
+	// the credential is neither read nor rotated
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteCredentialScope(t *testing.T) {
+	h, mock := mockAPI(t)
+	credentialID := uuid.NewString()
+	integrationID := uuid.NewString()
+
+	// scoped callers have no access to credentials without integrations
+	expectCredentialIntegrations(mock, credentialID)
+	c, _ := scopedRequest(http.MethodDelete, "/", "", integrationID)
+	c.SetParamNames("credentialId")
+	c.SetParamValues(credentialID)
+	assert.Equal(t, http.StatusNotFound, httpErrorCode(t, h.Delete(c)))
+
+	expectCredentialIntegrations(mock, credentialID, integrationID)
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "credentials" WHERE id = \$1`).
+		WithArgs(credentialID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	c, rec := scopedRequest(http.MethodDelete, "/", "", integrationID)
+	c.SetParamNames("credentialId")
+	c.SetParamValues(credentialID)
+	require.NoError(t, h.Delete(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListCredentialsScope(t *testing.T) {
+	h, mock := mockAPI(t)
+	ownCredential, sharedCredential, unusedCredential := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	a, b := uuid.NewString(), uuid.NewString()
+
+	listCredentials := func() {
+		mock.ExpectQuery(`SELECT \* FROM "credentials"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).
+				AddRow(ownCredential).
+				AddRow(sharedCredential).
+				AddRow(unusedCredential))
+	}
+	listCredentials()
+	mock.ExpectQuery(`SELECT \* FROM "integrations"`).
+		WillReturnRows(sqlmock.NewRows([]string{"integration_id", "credential_id"}).
+			AddRow(a, ownCredential).
+			AddRow(a, sharedCredential).
+			AddRow(b, sharedCredential))
+
+	c, rec := scopedRequest(http.MethodGet, "/", "", a)
+	require.NoError(t, h.List(c))
+	var response models.ListCredentialsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Len(t, response.Credentials, 1)
+	assert.Equal(t, ownCredential, response.Credentials[0].ID)
+
+	// callers without a scope see every credential
+	listCredentials()
+	c, rec = scopedRequest(http.MethodGet, "/", "", "")
+	require.NoError(t, h.List(c))
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Len(t, response.Credentials, 3)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,6 +2,7 @@ package integration_types
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/opengovern/og-util/pkg/config"
 	"github.com/opengovern/og-util/pkg/httpclient"
@@ -27,6 +28,7 @@ import (
 	"sync"
 
 	"github.com/goccy/go-yaml"
+	"github.com/google/uuid"
 	"github.com/hashicorp/go-getter"
 	plugin2 "github.com/hashicorp/go-plugin"
 	"github.com/labstack/echo/v4"
@@ -52,16 +54,19 @@ type API struct {
 	elastic       opengovernance.Client
 	coreClient    coreClient.CoreServiceClient
 	elasticConfig config.ElasticSearch
+
+	pluginVerifier *utils2.PluginVerifier
 }
 
-func New(typeManager *integration_type.IntegrationTypeManager, database db.Database, logger *zap.Logger, elastic opengovernance.Client, coreClient coreClient.CoreServiceClient, elasticConfig config.ElasticSearch) *API {
+func New(typeManager *integration_type.IntegrationTypeManager, database db.Database, logger *zap.Logger, elastic opengovernance.Client, coreClient coreClient.CoreServiceClient, elasticConfig config.ElasticSearch, pluginVerifier *utils2.PluginVerifier) *API {
 	return &API{
-		logger:        logger.Named("integration_types"),
-		typeManager:   typeManager,
-		database:      database,
-		elastic:       elastic,
-		coreClient:    coreClient,
-		elasticConfig: elasticConfig,
+		logger:         logger.Named("integration_types"),
+		typeManager:    typeManager,
+		database:       database,
+		elastic:        elastic,
+		coreClient:     coreClient,
+		elasticConfig:  elasticConfig,
+		pluginVerifier: pluginVerifier,
 	}
 }
 
@@ -78,6 +83,9 @@ func (a *API) Register(e *echo.Group) {
 	plugin.GET("/:id/manifest", httpserver.AuthorizeHandler(a.GetManifest, api.ViewerRole))
 	plugin.POST("/load/id/:id", httpserver.AuthorizeHandler(a.LoadPluginWithID, api.EditorRole))
 	plugin.POST("/load/url/:http_url", httpserver.AuthorizeHandler(a.LoadPluginWithURL, api.EditorRole))
+	plugin.POST("/upload", httpserver.AuthorizeHandler(a.UploadPlugin, api.EditorRole))
+	plugin.GET("/compatibility", httpserver.AuthorizeHandler(a.GetPluginCompatibility, api.ViewerRole))
+	plugin.GET("/upgrade-check", httpserver.AuthorizeHandler(a.CheckPlatformUpgrade, api.ViewerRole))
 	plugin.DELETE("/uninstall/id/:id", httpserver.AuthorizeHandler(a.UninstallPlugin, api.EditorRole))
 	plugin.POST("/:id/enable", httpserver.AuthorizeHandler(a.EnablePlugin, api.EditorRole))
 	plugin.POST("/:id/disable", httpserver.AuthorizeHandler(a.DisablePlugin, api.EditorRole))
@@ -89,6 +97,7 @@ func (a *API) Register(e *echo.Group) {
 	plugin.GET("/tables", httpserver.AuthorizeHandler(a.GetPluginsTables, api.ViewerRole))
 	plugin.PUT("/:id/demo/load", httpserver.AuthorizeHandler(a.LoadPluginDemoData, api.EditorRole))
 	plugin.PUT("/:id/demo/remove", httpserver.AuthorizeHandler(a.RemovePluginDemoData, api.EditorRole))
+	plugin.POST("/:id/demo/export", httpserver.AuthorizeHandler(a.ExportPluginDemoData, api.AdminRole))
 }
 
 // List godoc
@@ -484,6 +493,17 @@ func (a *API) LoadPluginWithURL(c echo.Context) error {
 
 			a.logger.Info("done reading files", zap.String("url", url), zap.String("url", url), zap.String("integrationType", m.IntegrationType.String()), zap.Int("integrationPluginSize", len(integrationPlugin)), zap.Int("cloudqlPluginSize", len(cloudqlPlugin)))
 
+			if err = a.checkPlatformCompatibility(c.Request().Context(), m.SupportedPlatformVersion); err != nil {
+				a.logger.Error("plugin is not compatible with the platform", zap.Error(err), zap.String("url", url))
+				return platformCompatibilityHTTPError(err)
+			}
+
+			verification, err := a.pluginVerifier.Verify(m, integrationPlugin, cloudqlPlugin)
+			if err != nil {
+				a.logger.Error("plugin verification failed", zap.Error(err), zap.String("url", url))
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+
 			plugin = &models2.IntegrationPlugin{
 				PluginID:        m.IntegrationType.String(),
 				IntegrationType: m.IntegrationType,
@@ -492,7 +512,10 @@ func (a *API) LoadPluginWithURL(c echo.Context) error {
 				DemoDataURL:     m.DemoDataURL,
 				InstallState:    models2.IntegrationTypeInstallStateInstalling,
 				URL:             url,
+
+				SupportedPlatformVersion: m.SupportedPlatformVersion,
 			}
+			verification.Apply(plugin)
 			err = a.database.CreatePlugin(plugin)
 			if err != nil {
 				a.logger.Error("failed to create plugin", zap.Error(err), zap.String("id", m.IntegrationType.String()))
@@ -637,6 +660,21 @@ func (a *API) EnablePlugin(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusNotFound, "plugin not found")
 	}
 
+	if err = a.checkPlatformCompatibility(c.Request().Context(), plugin.SupportedPlatformVersion); err != nil {
+		// the plugin is left as it is when the platform version is unknown
+		if errors.Is(err, errPlatformVersionUnavailable) {
+			return platformCompatibilityHTTPError(err)
+		}
+		if plugin.OperationalStatus != models2.IntegrationPluginOperationalStatusDisabled {
+			if updateErr := plugin.AddOperationalStatusUpdate(models2.IntegrationPluginOperationalStatusDisabled, err.Error()); updateErr != nil {
+				a.logger.Error("failed to add operational status update", zap.Error(updateErr), zap.String("id", plugin.PluginID))
+			} else if updateErr = a.database.UpdatePlugin(plugin); updateErr != nil {
+				a.logger.Error("failed to update plugin", zap.Error(updateErr), zap.String("id", plugin.PluginID))
+			}
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
 	plugin.OperationalStatus = models2.IntegrationPluginOperationalStatusEnabled
 
 	err = a.database.UpdatePlugin(plugin)
@@ -719,7 +757,7 @@ func (a *API) ListPlugins(c echo.Context) error {
 
 	var items []models.IntegrationPlugin
 	for _, plugin := range plugins {
-		integrations, err := a.database.ListIntegrationsByFilters(nil, []string{plugin.IntegrationType.String()}, nil, nil)
+		integrations, err := a.database.ListIntegrationsByFilters(nil, []string{plugin.IntegrationType.String()}, nil, nil, nil)
 		if err != nil {
 			a.logger.Error("failed to list integrations", zap.Error(err))
 			return echo.NewHTTPError(http.StatusInternalServerError, "failed to list integrations")
@@ -894,6 +932,14 @@ func (a *API) GetPlugin(c echo.Context) error {
 		DescriberURL:             plugin.DescriberURL,
 		DiscoveryType:            string(plugin.DiscoveryType),
 		Name:                     plugin.Name,
+		Verification: &models.IntegrationPluginVerification{
+			Status:                  string(plugin.VerificationStatus),
+			Signer:                  plugin.VerificationSigner,
+			Message:                 plugin.VerificationMessage,
+			VerifiedAt:              plugin.VerifiedAt,
+			IntegrationPluginSHA256: plugin.IntegrationPluginSHA256,
+			CloudQLPluginSHA256:     plugin.CloudQLPluginSHA256,
+		},
 	})
 }
 
@@ -1076,20 +1122,25 @@ func (a *API) InstallOrUpdatePlugin(ctx context.Context, plugin *models2.Integra
 		return err
 	}
 
+	return a.installPluginPackage(ctx, plugin, baseDir+"/integration_type")
+}
+
+// installPluginPackage installs the plugin package extracted in dir, either downloaded from the plugin URL or uploaded
+func (a *API) installPluginPackage(ctx context.Context, plugin *models2.IntegrationPlugin, dir string) error {
 	// read integration-plugin file
-	integrationPlugin, err := os.ReadFile(baseDir + "/integration_type/integration-plugin")
+	integrationPlugin, err := os.ReadFile(filepath.Join(dir, "integration-plugin"))
 	if err != nil {
 		a.logger.Error("failed to open integration-plugin file", zap.Error(err), zap.String("id", plugin.PluginID))
 		return err
 	}
-	cloudqlPlugin, err := os.ReadFile(baseDir + "/integration_type/cloudql-plugin")
+	cloudqlPlugin, err := os.ReadFile(filepath.Join(dir, "cloudql-plugin"))
 	if err != nil {
 		a.logger.Error("failed to open cloudql-plugin file", zap.Error(err), zap.String("id", plugin.PluginID))
 		return err
 	}
 
 	//// read manifest file
-	manifestFile, err := os.ReadFile(baseDir + "/integration_type/manifest.yaml")
+	manifestFile, err := os.ReadFile(filepath.Join(dir, "manifest.yaml"))
 	if err != nil {
 		a.logger.Error("failed to open manifest file", zap.Error(err))
 		return err
@@ -1099,16 +1150,33 @@ func (a *API) InstallOrUpdatePlugin(ctx context.Context, plugin *models2.Integra
 	var m models2.Manifest
 	// decode yaml
 	if err = yaml.Unmarshal(manifestFile, &m); err != nil {
-		a.logger.Error("failed to decode manifest", zap.Error(err), zap.String("url", url))
+		a.logger.Error("failed to decode manifest", zap.Error(err), zap.String("url", plugin.URL))
+		return err
+	}
+
+	if plugin.IntegrationType != "" && m.IntegrationType != plugin.IntegrationType {
+		return fmt.Errorf("package is for integration type %s, not %s", m.IntegrationType, plugin.IntegrationType)
+	}
+
+	if err = a.checkPlatformCompatibility(ctx, m.SupportedPlatformVersion); err != nil {
+		a.logger.Error("plugin is not compatible with the platform", zap.Error(err), zap.String("id", plugin.PluginID))
+		return err
+	}
+	plugin.SupportedPlatformVersion = m.SupportedPlatformVersion
+
+	verification, err := a.pluginVerifier.Verify(m, integrationPlugin, cloudqlPlugin)
+	verification.Apply(plugin)
+	if err != nil {
+		a.logger.Error("plugin verification failed", zap.Error(err), zap.String("id", plugin.PluginID))
 		return err
 	}
 
 	// Opensearch templates
 	a.logger.Info("checking for index-templates", zap.String("id", plugin.PluginID))
 	var files []string
-	if stats, err := os.Stat(filepath.Join(baseDir, "integration_type", "index-templates")); err == nil && stats.IsDir() {
+	if stats, err := os.Stat(filepath.Join(dir, "index-templates")); err == nil && stats.IsDir() {
 		a.logger.Info("found index-templates directory", zap.String("id", plugin.PluginID))
-		err = filepath.Walk(filepath.Join(baseDir, "integration_type", "index-templates"), func(path string, info fs.FileInfo, err error) error {
+		err = filepath.Walk(filepath.Join(dir, "index-templates"), func(path string, info fs.FileInfo, err error) error {
 			if strings.HasSuffix(info.Name(), ".json") {
 				files = append(files, path)
 			}
@@ -1141,7 +1209,7 @@ func (a *API) InstallOrUpdatePlugin(ctx context.Context, plugin *models2.Integra
 		}
 	}
 
-	a.logger.Info("done reading files", zap.String("id", plugin.PluginID), zap.String("url", url), zap.String("integrationType", plugin.IntegrationType.String()), zap.Int("integrationPluginSize", len(integrationPlugin)), zap.Int("cloudqlPluginSize", len(cloudqlPlugin)))
+	a.logger.Info("done reading files", zap.String("id", plugin.PluginID), zap.String("url", plugin.URL), zap.String("integrationType", plugin.IntegrationType.String()), zap.Int("integrationPluginSize", len(integrationPlugin)), zap.Int("cloudqlPluginSize", len(cloudqlPlugin)))
 
 	plugin.DescriberURL = m.DescriberURL
 	plugin.DescriberTag = m.DescriberTag
@@ -1179,6 +1247,11 @@ func (a *API) LoadPlugin(ctx context.Context, plugin *models2.IntegrationPlugin,
 	}
 	pluginName := plugin.IntegrationType.String()
 
+	if err := a.pluginVerifier.VerifyInstalled(*plugin, *pluginBinary); err != nil {
+		a.logger.Error("plugin binary verification failed", zap.Error(err), zap.String("plugin", pluginName))
+		return err
+	}
+
 	if v, ok := a.typeManager.Clients[plugin.IntegrationType]; ok {
 		v.Kill()
 		delete(a.typeManager.Clients, plugin.IntegrationType)
@@ -1441,6 +1514,99 @@ func (a *API) RemovePluginDemoData(c echo.Context) error {
 	return c.NoContent(http.StatusOK)
 }
 
+// ExportPluginDemoData godoc
+//
+// @Summary			Export demo data for plugin
+// @Description		Export the resources and metadata of the plugin integrations as an encrypted demo dataset which can be loaded as the plugin demo data
+// @Security		BearerToken
+// @Tags			integration_types
+// @Produce			octet-stream
+// @Param			id		path	string								true	"plugin id"
+// @Param			request	body	models.ExportPluginDemoDataRequest	false	"Request"
+// @Success			200
+// @Router			/integration/api/v1/plugin/{id}/demo/export [post]
+func (a *API) ExportPluginDemoData(c echo.Context) error {
+	id := c.Param("id")
+
+	var req models.ExportPluginDemoDataRequest
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(&req); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+		}
+	}
+	for _, integrationID := range req.IntegrationIDs {
+		if _, err := uuid.Parse(integrationID); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid integration id %s", integrationID))
+		}
+	}
+
+	plugin, err := a.database.GetPluginByID(id)
+	if err != nil {
+		a.logger.Error("failed to get plugin", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get plugin")
+	}
+	if plugin == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "plugin not found")
+	}
+
+	openSSLPassword := env.GetString("OPENSSL_PASSWORD", "")
+	if openSSLPassword == "" {
+		return echo.NewHTTPError(http.StatusInternalServerError, "demo data encryption password is not configured")
+	}
+
+	integrationType, ok := a.typeManager.GetIntegrationTypeMap()[plugin.IntegrationType]
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "plugin is not loaded")
+	}
+
+	integrations, err := a.database.ListIntegrationsByFilters(req.IntegrationIDs, []string{plugin.IntegrationType.String()}, nil, nil, nil)
+	if err != nil {
+		a.logger.Error("failed to list integrations", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list integrations")
+	}
+	var selected []models2.Integration
+	for _, i := range integrations {
+		if i.State == integration.IntegrationStateSample {
+			continue
+		}
+		selected = append(selected, i)
+	}
+	if len(selected) == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "no integrations to export")
+	}
+	if len(req.IntegrationIDs) > 0 && len(selected) != len(req.IntegrationIDs) {
+		return echo.NewHTTPError(http.StatusBadRequest, "some integrations were not found for the plugin")
+	}
+
+	resourceTypeConfigs, err := integrationType.GetResourceTypesByLabels(nil)
+	if err != nil {
+		a.logger.Error("failed to get resource types", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get resource types")
+	}
+	resourceTypes := make([]string, 0, len(resourceTypeConfigs))
+	for _, rt := range resourceTypeConfigs {
+		resourceTypes = append(resourceTypes, rt.Name)
+	}
+
+	exportConfig := demo_import.ExportConfig{
+		OpenSSLPassword:   openSSLPassword,
+		ElasticsearchUser: a.elasticConfig.Username,
+		ElasticsearchPass: a.elasticConfig.Password,
+		ElasticsearchAddr: a.elasticConfig.Address,
+		Anonymize:         req.Anonymize,
+	}
+	workDir, archivePath, err := demo_import.ExportDemoData(exportConfig, a.logger, selected, resourceTypes)
+	if workDir != "" {
+		defer os.RemoveAll(workDir)
+	}
+	if err != nil {
+		a.logger.Error("failed to export demo data", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to export demo data")
+	}
+
+	return c.Attachment(archivePath, fmt.Sprintf("%s_demo_data.tar.gz.enc", plugin.PluginID))
+}
+
 // AddPluginSpec godoc
 //
 //	@Summary	Load Task
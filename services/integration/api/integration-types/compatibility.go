@@ -0,0 +1,135 @@
+package integration_types
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/opengovern/opensecurity/services/integration/api/models"
+	models2 "github.com/opengovern/opensecurity/services/integration/models"
+	utils2 "github.com/opengovern/opensecurity/services/integration/utils"
+	"go.uber.org/zap"
+)
+
+// errPlatformVersionUnavailable is returned by checkPlatformCompatibility when the platform version can not be read,
+// the compatibility of the plugin is unknown then
+var errPlatformVersionUnavailable = errors.New("failed to get platform version to check plugin compatibility")
+
+// checkPlatformCompatibility returns an error if the running platform version does not satisfy the supported versions
+func (a *API) checkPlatformCompatibility(ctx context.Context, supportedPlatformVersion string) error {
+	platformVersion, err := utils2.GetPlatformVersion(ctx, a.coreClient)
+	if err != nil {
+		a.logger.Error("failed to get platform version", zap.Error(err))
+		return errPlatformVersionUnavailable
+	}
+	if compatible, reason := utils2.CheckPlatformVersion(platformVersion, supportedPlatformVersion); !compatible {
+		return fmt.Errorf("%s", reason)
+	}
+	return nil
+}
+
+// platformCompatibilityHTTPError responds to a failed checkPlatformCompatibility
+func platformCompatibilityHTTPError(err error) error {
+	if errors.Is(err, errPlatformVersionUnavailable) {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, err.Error())
+	}
+	return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+}
+
+// GetPluginCompatibility godoc
+//
+// @Summary			Plugin compatibility matrix
+// @Description		Check the supported platform versions of every plugin against the running platform version and optionally a target version
+// @Security		BearerToken
+// @Tags			integration_types
+// @Produce			json
+// @Param			target_version	query		string	false	"platform version to check the plugins against as well"
+// @Success			200				{object}	models.PluginCompatibilityResponse
+// @Router			/integration/api/v1/plugin/compatibility [get]
+func (a *API) GetPluginCompatibility(c echo.Context) error {
+	response, err := a.pluginCompatibility(c.Request().Context(), c.QueryParam("target_version"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// CheckPlatformUpgrade godoc
+//
+// @Summary			Pre-upgrade plugin check
+// @Description		List the installed plugins which are compatible with the running platform version but not with the target version
+// @Security		BearerToken
+// @Tags			integration_types
+// @Produce			json
+// @Param			target_version	query		string	true	"platform version to upgrade to"
+// @Success			200				{object}	models.PlatformUpgradeCheckResponse
+// @Router			/integration/api/v1/plugin/upgrade-check [get]
+func (a *API) CheckPlatformUpgrade(c echo.Context) error {
+	targetVersion := c.QueryParam("target_version")
+	if targetVersion == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "target_version is required")
+	}
+
+	compatibility, err := a.pluginCompatibility(c.Request().Context(), targetVersion)
+	if err != nil {
+		return err
+	}
+
+	response := models.PlatformUpgradeCheckResponse{
+		PlatformVersion:       compatibility.PlatformVersion,
+		TargetPlatformVersion: targetVersion,
+		Incompatible:          []models.PluginCompatibility{},
+	}
+	for _, p := range compatibility.Plugins {
+		if p.InstallState != string(models2.IntegrationTypeInstallStateInstalled) {
+			continue
+		}
+		if p.Compatible && p.TargetCompatible != nil && !*p.TargetCompatible {
+			response.Incompatible = append(response.Incompatible, p)
+		}
+	}
+	response.Safe = len(response.Incompatible) == 0
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (a *API) pluginCompatibility(ctx context.Context, targetVersion string) (*models.PluginCompatibilityResponse, error) {
+	platformVersion, err := utils2.GetPlatformVersion(ctx, a.coreClient)
+	if err != nil {
+		a.logger.Error("failed to get platform version", zap.Error(err))
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to get platform version")
+	}
+
+	plugins, err := a.database.ListPlugins()
+	if err != nil {
+		a.logger.Error("failed to list plugins", zap.Error(err))
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to list plugins")
+	}
+
+	response := models.PluginCompatibilityResponse{
+		PlatformVersion:       platformVersion,
+		TargetPlatformVersion: targetVersion,
+		Plugins:               []models.PluginCompatibility{},
+	}
+	for _, plugin := range plugins {
+		item := models.PluginCompatibility{
+			PluginID:                 plugin.PluginID,
+			IntegrationType:          plugin.IntegrationType.String(),
+			Version:                  plugin.Version,
+			SupportedPlatformVersion: plugin.SupportedPlatformVersion,
+			InstallState:             string(plugin.InstallState),
+			OperationalStatus:        string(plugin.OperationalStatus),
+		}
+		item.Compatible, item.Reason = utils2.CheckPlatformVersion(platformVersion, plugin.SupportedPlatformVersion)
+		if targetVersion != "" {
+			targetCompatible, targetReason := utils2.CheckPlatformVersion(targetVersion, plugin.SupportedPlatformVersion)
+			item.TargetCompatible = &targetCompatible
+			item.TargetReason = targetReason
+		}
+		response.Plugins = append(response.Plugins, item)
+	}
+
+	return &response, nil
+}
@@ -0,0 +1,45 @@
+package integration_types
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/opengovern/og-util/pkg/httpclient"
+	"github.com/opengovern/opensecurity/services/core/api"
+	coreClient "github.com/opengovern/opensecurity/services/core/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type aboutCoreClient struct {
+	coreClient.CoreServiceClient
+	about *api.About
+	err   error
+}
+
+func (c aboutCoreClient) GetAbout(*httpclient.Context) (*api.About, error) {
+	return c.about, c.err
+}
+
+func TestCheckPlatformCompatibility(t *testing.T) {
+	a := &API{logger: zap.NewNop(), coreClient: aboutCoreClient{about: &api.About{AppVersion: "v2.4.0-rc.1"}}}
+	assert.NoError(t, a.checkPlatformCompatibility(context.Background(), "v2.X.X"))
+
+	err := a.checkPlatformCompatibility(context.Background(), ">= 3.0.0")
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, errPlatformVersionUnavailable)
+	var httpErr *echo.HTTPError
+	require.True(t, errors.As(platformCompatibilityHTTPError(err), &httpErr))
+	assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+
+	// A failing core service does not make the plugin incompatible
+	a.coreClient = aboutCoreClient{err: errors.New("connection refused")}
+	err = a.checkPlatformCompatibility(context.Background(), ">= 3.0.0")
+	assert.ErrorIs(t, err, errPlatformVersionUnavailable)
+	require.True(t, errors.As(platformCompatibilityHTTPError(err), &httpErr))
+	assert.Equal(t, http.StatusServiceUnavailable, httpErr.Code)
+}
@@ -0,0 +1,310 @@
+package integration_types
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-getter"
+	"github.com/labstack/echo/v4"
+	models2 "github.com/opengovern/opensecurity/services/integration/models"
+	"go.uber.org/zap"
+)
+
+const (
+	uploadedPluginsDir = "/integration-types/uploads"
+
+	// maxPluginPackageSize bounds the uploaded package, maxPluginPackageExtractedSize the total size of all its
+	// extracted files and maxPluginPackageFiles their number
+	maxPluginPackageSize          = 1 << 30
+	maxPluginPackageExtractedSize = 2 << 30
+	maxPluginPackageFiles         = 10000
+)
+
+var pluginArchiveExtensions = []string{".tar.gz", ".tgz", ".tar", ".zip"}
+
+// UploadPlugin godoc
+//
+// @Summary			Upload plugin package
+// @Description		Install or update a plugin from an uploaded package, for clusters without network access.
+// @Description		The package is the same archive LoadPluginWithURL downloads, with the manifest, integration-plugin and cloudql-plugin files.
+// @Security		BearerToken
+// @Tags			integration_types
+// @Accept			multipart/form-data,application/gzip,application/zip,application/x-tar
+// @Produce			json
+// @Param			file		formData	file	false	"plugin package, the request body is used if not set"
+// @Param			filename	query		string	false	"package file name for streamed uploads, used to detect the archive format"
+// @Success			200
+// @Router			/integration/api/v1/plugin/upload [post]
+func (a *API) UploadPlugin(c echo.Context) error {
+	installingPlugins, err := a.database.ListInstallingPlugins()
+	if err != nil {
+		a.logger.Error("failed to list installing plugins", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list installing plugins")
+	}
+	if len(installingPlugins) > 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "other plugin install is in progress")
+	}
+
+	err = a.CheckEnoughMemory()
+	if err != nil {
+		a.logger.Error("checking enough memory failed", zap.Error(err))
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, maxPluginPackageSize)
+	var body io.Reader
+	filename := c.QueryParam("filename")
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("plugin package should not be larger than %d bytes", maxBytesErr.Limit))
+			}
+			return echo.NewHTTPError(http.StatusBadRequest, "plugin package file is required")
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to open uploaded file")
+		}
+		defer file.Close()
+		body = file
+		filename = fileHeader.Filename
+	} else {
+		body = c.Request().Body
+	}
+	extension := pluginArchiveExtension(filename, c.Request().Header.Get(echo.HeaderContentType))
+	if extension == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("plugin package should be one of %s", strings.Join(pluginArchiveExtensions, ", ")))
+	}
+
+	uploadID := uuid.New().String()
+	if err = os.MkdirAll(uploadedPluginsDir, os.ModePerm); err != nil {
+		a.logger.Error("failed to create uploads directory", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create uploads directory")
+	}
+	archivePath := filepath.Join(uploadedPluginsDir, uploadID+extension)
+	packageDir := filepath.Join(uploadedPluginsDir, uploadID)
+	cleanup := func() {
+		_ = os.Remove(archivePath)
+		_ = os.RemoveAll(packageDir)
+	}
+
+	if err = storePluginPackage(archivePath, body); err != nil {
+		cleanup()
+		a.logger.Error("failed to store plugin package", zap.Error(err))
+		return err
+	}
+
+	if err = extractPluginPackage(archivePath, packageDir, maxPluginPackageFiles, maxPluginPackageExtractedSize); err != nil {
+		cleanup()
+		a.logger.Error("failed to extract plugin package", zap.Error(err))
+		return err
+	}
+
+	m, err := a.validatePluginPackage(c.Request().Context(), packageDir)
+	if err != nil {
+		cleanup()
+		return platformCompatibilityHTTPError(err)
+	}
+
+	plugin, err := a.database.GetPluginByID(m.IntegrationType.String())
+	if err != nil {
+		cleanup()
+		a.logger.Error("failed to get plugin", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get plugin")
+	}
+	// previous is the plugin as it was before this upload, it is restored if the install fails
+	var previous *models2.IntegrationPlugin
+	if plugin == nil {
+		plugin = &models2.IntegrationPlugin{
+			PluginID:                 m.IntegrationType.String(),
+			IntegrationType:          m.IntegrationType,
+			DescriberURL:             m.DescriberURL,
+			DescriberTag:             m.DescriberTag,
+			DemoDataURL:              m.DemoDataURL,
+			SupportedPlatformVersion: m.SupportedPlatformVersion,
+			InstallState:             models2.IntegrationTypeInstallStateInstalling,
+		}
+		if err = a.database.CreatePlugin(plugin); err != nil {
+			cleanup()
+			a.logger.Error("failed to create plugin", zap.Error(err), zap.String("id", plugin.PluginID))
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create plugin")
+		}
+	} else {
+		current := *plugin
+		previous = &current
+		plugin.InstallState = models2.IntegrationTypeInstallStateInstalling
+		if err = a.database.UpdatePlugin(plugin); err != nil {
+			cleanup()
+			a.logger.Error("failed to update plugin", zap.Error(err), zap.String("id", plugin.PluginID))
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update plugin")
+		}
+	}
+
+	go func() {
+		defer cleanup()
+		if err := a.installUploadedPlugin(context.Background(), plugin, previous, packageDir); err != nil {
+			a.logger.Error("failed to install uploaded plugin", zap.Error(err), zap.String("id", plugin.PluginID))
+		}
+	}()
+
+	return c.NoContent(http.StatusOK)
+}
+
+// installUploadedPlugin installs the extracted package. A plugin which existed before the upload is restored to
+// its previous state and binary if the install fails, a new one is marked as failed.
+func (a *API) installUploadedPlugin(ctx context.Context, plugin, previous *models2.IntegrationPlugin, dir string) (err error) {
+	var previousBinary *models2.IntegrationPluginBinary
+	defer func() {
+		if err != nil && previous != nil {
+			a.restorePlugin(ctx, previous, previousBinary)
+			return
+		}
+		if err != nil {
+			plugin.InstallState = models2.IntegrationTypeInstallStateNotInstalled
+			plugin.OperationalStatus = models2.IntegrationPluginOperationalStatusFailed
+		} else {
+			plugin.InstallState = models2.IntegrationTypeInstallStateInstalled
+			plugin.OperationalStatus = models2.IntegrationPluginOperationalStatusEnabled
+		}
+		if err := a.database.UpdatePlugin(plugin); err != nil {
+			a.logger.Error("failed to update plugin", zap.Error(err), zap.String("id", plugin.PluginID))
+		}
+	}()
+
+	if previous != nil && previous.InstallState == models2.IntegrationTypeInstallStateInstalled {
+		if previousBinary, err = a.database.GetPluginBinaryByID(previous.PluginID); err != nil {
+			a.logger.Error("failed to get plugin binary", zap.Error(err), zap.String("id", previous.PluginID))
+			return err
+		}
+	}
+
+	return a.installPluginPackage(ctx, plugin, dir)
+}
+
+// restorePlugin puts back a plugin after a failed upload, reloading its previous binary if the upload replaced it
+func (a *API) restorePlugin(ctx context.Context, previous *models2.IntegrationPlugin, previousBinary *models2.IntegrationPluginBinary) {
+	if previousBinary != nil && previousBinary.PluginID != "" {
+		binary, err := a.database.GetPluginBinaryByID(previous.PluginID)
+		if err != nil {
+			a.logger.Error("failed to get plugin binary", zap.Error(err), zap.String("id", previous.PluginID))
+		} else if binary == nil || !bytes.Equal(binary.IntegrationPlugin, previousBinary.IntegrationPlugin) ||
+			!bytes.Equal(binary.CloudQlPlugin, previousBinary.CloudQlPlugin) {
+			if err = a.database.CreatePluginBinary(previousBinary); err != nil {
+				a.logger.Error("failed to restore plugin binary", zap.Error(err), zap.String("id", previous.PluginID))
+			} else if err = a.LoadPlugin(ctx, previous, previousBinary); err != nil {
+				a.logger.Error("failed to reload previous plugin", zap.Error(err), zap.String("id", previous.PluginID))
+			}
+		}
+	}
+
+	if err := a.database.RestorePlugin(previous); err != nil {
+		a.logger.Error("failed to restore plugin", zap.Error(err), zap.String("id", previous.PluginID))
+	}
+}
+
+// validatePluginPackage checks the package has the plugin files and its manifest supports the platform version
+func (a *API) validatePluginPackage(ctx context.Context, dir string) (*models2.Manifest, error) {
+	for _, name := range []string{"integration-plugin", "cloudql-plugin"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return nil, fmt.Errorf("plugin package has no %s file", name)
+		}
+	}
+	manifestFile, err := os.ReadFile(filepath.Join(dir, "manifest.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("plugin package has no manifest.yaml file")
+	}
+	var m models2.Manifest
+	if err = yaml.Unmarshal(manifestFile, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	if m.IntegrationType == "" {
+		return nil, fmt.Errorf("manifest has no IntegrationType")
+	}
+	if m.DescriberURL == "" || m.DescriberTag == "" {
+		return nil, fmt.Errorf("manifest has no DescriberURL or DescriberTag")
+	}
+
+	if err = a.checkPlatformCompatibility(ctx, m.SupportedPlatformVersion); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// extractPluginPackage extracts the package archive to dir. go-getter sums the declared sizes of the entries,
+// the size of the extracted files is checked again as a declared size can be smaller than the content.
+func extractPluginPackage(archivePath, dir string, maxFiles int, maxSize int64) error {
+	extractor := getter.Client{
+		Src:           archivePath,
+		Dst:           dir,
+		Mode:          getter.ClientModeDir,
+		Decompressors: getter.LimitedDecompressors(maxFiles, maxSize),
+	}
+	if err := extractor.Get(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "failed to extract plugin package")
+	}
+
+	var size int64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to read extracted plugin package")
+	}
+	if size > maxSize {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("extracted plugin package should not be larger than %d bytes", maxSize))
+	}
+	return nil
+}
+
+// storePluginPackage writes the uploaded package to path, the body is expected to be limited to maxPluginPackageSize
+func storePluginPackage(path string, body io.Reader) error {
+	archive, err := os.Create(path)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to store plugin package")
+	}
+	_, err = io.Copy(archive, body)
+	archive.Close()
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("plugin package should not be larger than %d bytes", maxBytesErr.Limit))
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, "failed to read plugin package")
+	}
+	return nil
+}
+
+func pluginArchiveExtension(filename, contentType string) string {
+	filename = strings.ToLower(filename)
+	for _, extension := range pluginArchiveExtensions {
+		if strings.HasSuffix(filename, extension) {
+			return extension
+		}
+	}
+	switch {
+	case strings.HasPrefix(contentType, "application/gzip"), strings.HasPrefix(contentType, "application/x-gzip"):
+		return ".tar.gz"
+	case strings.HasPrefix(contentType, "application/zip"):
+		return ".zip"
+	case strings.HasPrefix(contentType, "application/x-tar"):
+		return ".tar"
+	}
+	return ""
+}
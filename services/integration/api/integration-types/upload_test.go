@@ -0,0 +1,124 @@
+package integration_types
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/opengovern/opensecurity/services/integration/db"
+	models2 "github.com/opengovern/opensecurity/services/integration/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestStorePluginPackage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "package.tar.gz")
+	pkg := bytes.Repeat([]byte("a"), 1024)
+
+	body := http.MaxBytesReader(httptest.NewRecorder(), io.NopCloser(bytes.NewReader(pkg)), 1024)
+	require.NoError(t, storePluginPackage(path, body))
+	stored, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, pkg, stored)
+
+	body = http.MaxBytesReader(httptest.NewRecorder(), io.NopCloser(bytes.NewReader(append(pkg, 'b'))), 1024)
+	err = storePluginPackage(path, body)
+	var httpErr *echo.HTTPError
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, httpErr.Code)
+}
+
+func TestPluginArchiveExtension(t *testing.T) {
+	assert.Equal(t, ".tar.gz", pluginArchiveExtension("aws-v1.2.0.TAR.GZ", ""))
+	assert.Equal(t, ".tgz", pluginArchiveExtension("aws.tgz", "application/octet-stream"))
+	assert.Equal(t, ".zip", pluginArchiveExtension("", "application/zip"))
+	assert.Equal(t, ".tar.gz", pluginArchiveExtension("", "application/x-gzip"))
+	assert.Empty(t, pluginArchiveExtension("aws.rar", "application/octet-stream"))
+}
+
+func writeTar(t *testing.T, path string, files map[string][]byte) {
+	var buf bytes.Buffer
+	writer := tar.NewWriter(&buf)
+	for name, content := range files {
+		require.NoError(t, writer.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}))
+		_, err := writer.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+}
+
+func TestExtractPluginPackageTotalSize(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "package.tar")
+	writeTar(t, archive, map[string][]byte{
+		"integration-plugin": bytes.Repeat([]byte("a"), 600),
+		"cloudql-plugin":     bytes.Repeat([]byte("b"), 600),
+	})
+
+	require.NoError(t, extractPluginPackage(archive, filepath.Join(t.TempDir(), "package"), 10, 2000))
+
+	// every file is under the limit, both together are not
+	dir := filepath.Join(t.TempDir(), "package")
+	err := extractPluginPackage(archive, dir, 10, 1000)
+	var httpErr *echo.HTTPError
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+
+	err = extractPluginPackage(archive, filepath.Join(t.TempDir(), "package"), 1, 2000)
+	assert.Error(t, err, "too many files")
+}
+
+func TestInstallUploadedPluginRestoresPreviousState(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	orm, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+	a := &API{logger: zap.NewNop(), database: db.NewDatabase(orm, orm)}
+
+	previous := &models2.IntegrationPlugin{
+		PluginID:          "aws",
+		InstallState:      models2.IntegrationTypeInstallStateInstalled,
+		OperationalStatus: models2.IntegrationPluginOperationalStatusEnabled,
+		DescriberTag:      "v1.0.0",
+		// as read from the database
+		OperationalStatusUpdates: pgtype.JSONB{Status: pgtype.Null},
+		Tags:                     pgtype.JSONB{Status: pgtype.Null},
+	}
+	plugin := *previous
+	plugin.InstallState = models2.IntegrationTypeInstallStateInstalling
+
+	binaryRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"plugin_id", "integration_plugin", "cloud_ql_plugin"}).
+			AddRow("aws", []byte("integration v1"), []byte("cloudql v1"))
+	}
+	mock.ExpectQuery(`SELECT \* FROM "integration_plugin_binaries" WHERE plugin_id = \$1`).
+		WithArgs("aws").
+		WillReturnRows(binaryRows())
+	// the failed install did not get to replace the binary, so the running plugin is left as it is
+	mock.ExpectQuery(`SELECT \* FROM "integration_plugin_binaries" WHERE plugin_id = \$1`).
+		WithArgs("aws").
+		WillReturnRows(binaryRows())
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "integration_plugins" SET .*"install_state"=\$\d+,"operational_status"=\$\d+.* WHERE plugin_id = \$\d+`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// the package has no plugin files, so the install fails
+	err = a.installUploadedPlugin(context.Background(), &plugin, previous, t.TempDir())
+	require.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -24,11 +24,17 @@ import (
 	"github.com/opengovern/og-util/pkg/steampipe"
 	"github.com/opengovern/og-util/pkg/vault"
 	"github.com/opengovern/opensecurity/pkg/utils"
+	complianceClient "github.com/opengovern/opensecurity/services/compliance/client"
+	coreClient "github.com/opengovern/opensecurity/services/core/client"
+	"github.com/opengovern/opensecurity/services/integration/api/credentials"
 	"github.com/opengovern/opensecurity/services/integration/api/models"
+	"github.com/opengovern/opensecurity/services/integration/config"
 	"github.com/opengovern/opensecurity/services/integration/db"
 	"github.com/opengovern/opensecurity/services/integration/entities"
 	integration_type "github.com/opengovern/opensecurity/services/integration/integration-type"
 	models2 "github.com/opengovern/opensecurity/services/integration/models"
+	"github.com/opengovern/opensecurity/services/integration/secrets"
+	utils2 "github.com/opengovern/opensecurity/services/integration/utils"
 	"go.uber.org/zap"
 	"golang.org/x/net/context"
 	appsv1 "k8s.io/api/apps/v1"
@@ -43,6 +49,16 @@ type API struct {
 	database     db.Database
 	kubeClient   client.Client
 	typesManager *integration_type.IntegrationTypeManager
+	secrets      *secrets.Resolver
+	credentials  credentials.API
+
+	healthCheckConfig config.HealthCheckConfig
+	orgSyncConfig     config.OrgSyncConfig
+	complianceClient  complianceClient.ComplianceServiceClient
+	coreClient        coreClient.CoreServiceClient
+
+	orgSyncLock    sync.Mutex
+	orgSyncRunning map[uuid.UUID]bool
 
 	steampipeOption *steampipe.Option
 	steampipeLock   sync.Mutex
@@ -63,6 +79,12 @@ func New(
 	steampipeOption *steampipe.Option,
 	kubeClien client.Client,
 	typesManager *integration_type.IntegrationTypeManager,
+	healthCheckConfig config.HealthCheckConfig,
+	orgSyncConfig config.OrgSyncConfig,
+	complianceClient complianceClient.ComplianceServiceClient,
+	secretResolver *secrets.Resolver,
+	coreClient coreClient.CoreServiceClient,
+	credentialsApi credentials.API,
 ) API {
 	return API{
 		vault:           vault,
@@ -72,6 +94,14 @@ func New(
 		steampipeLock:   sync.Mutex{},
 		kubeClient:      kubeClien,
 		typesManager:    typesManager,
+		secrets:         secretResolver,
+		credentials:     credentialsApi,
+
+		healthCheckConfig: fillHealthCheckConfigDefaults(healthCheckConfig),
+		orgSyncConfig:     fillOrgSyncConfigDefaults(orgSyncConfig),
+		orgSyncRunning:    make(map[uuid.UUID]bool),
+		complianceClient:  complianceClient,
+		coreClient:        coreClient,
 	}
 }
 
@@ -80,12 +110,26 @@ func (h *API) Register(g *echo.Group) {
 	g.POST("/list", httpserver.AuthorizeHandler(h.ListByFilters, api.ViewerRole))
 	g.POST("/discover", httpserver.AuthorizeHandler(h.DiscoverIntegrations, api.EditorRole))
 	g.POST("/add", httpserver.AuthorizeHandler(h.AddIntegrations, api.EditorRole))
+	g.POST("/bulk/import", httpserver.AuthorizeHandler(h.BulkImportIntegrations, api.EditorRole))
+	g.GET("/bulk/export", httpserver.AuthorizeHandler(h.ExportIntegrations, api.ViewerRole))
 	g.PUT("/:IntegrationID/healthcheck", httpserver.AuthorizeHandler(h.IntegrationHealthcheck, api.EditorRole))
+	g.GET("/org-sync", httpserver.AuthorizeHandler(h.ListOrgSyncs, api.ViewerRole))
+	g.GET("/org-sync/:credentialId", httpserver.AuthorizeHandler(h.GetOrgSync, api.ViewerRole))
+	g.PUT("/org-sync/:credentialId", httpserver.AuthorizeHandler(h.UpdateOrgSync, api.EditorRole))
+	g.DELETE("/org-sync/:credentialId", httpserver.AuthorizeHandler(h.DeleteOrgSync, api.EditorRole))
+	g.POST("/org-sync/:credentialId/run", httpserver.AuthorizeHandler(h.RunOrgSync, api.EditorRole))
+	g.GET("/org-sync/:credentialId/reports", httpserver.AuthorizeHandler(h.ListOrgSyncReports, api.ViewerRole))
+	g.GET("/:IntegrationID/health", httpserver.AuthorizeHandler(h.GetIntegrationHealth, api.ViewerRole))
 	g.DELETE("/:IntegrationID", httpserver.AuthorizeHandler(h.Delete, api.EditorRole))
 	g.GET("/:IntegrationID", httpserver.AuthorizeHandler(h.Get, api.ViewerRole))
 	g.POST("/:IntegrationID", httpserver.AuthorizeHandler(h.Update, api.EditorRole))
 	g.GET("/integration-groups", httpserver.AuthorizeHandler(h.ListIntegrationGroups, api.ViewerRole))
+	g.POST("/integration-groups", httpserver.AuthorizeHandler(h.CreateIntegrationGroup, api.EditorRole))
+	g.POST("/integration-groups/preview", httpserver.AuthorizeHandler(h.PreviewIntegrationGroup, api.EditorRole))
 	g.GET("/integration-groups/:integrationGroupName", httpserver.AuthorizeHandler(h.GetIntegrationGroup, api.ViewerRole))
+	g.PUT("/integration-groups/:integrationGroupName", httpserver.AuthorizeHandler(h.UpdateIntegrationGroup, api.EditorRole))
+	g.DELETE("/integration-groups/:integrationGroupName", httpserver.AuthorizeHandler(h.DeleteIntegrationGroup, api.EditorRole))
+	g.GET("/integration-groups/:integrationGroupName/history", httpserver.AuthorizeHandler(h.ListIntegrationGroupHistory, api.ViewerRole))
 	g.PUT("/sample/purge", httpserver.AuthorizeHandler(h.PurgeSampleData, api.EditorRole))
 	g.PUT("/:integration_id/resource", httpserver.AuthorizeHandler(h.SetResourceTypesForIntegration, api.EditorRole))
 
@@ -194,10 +238,10 @@ func (h *API) DiscoverIntegrations(c echo.Context) error {
 		}
 		integrationType = credential.IntegrationType
 
-		mapData, err := h.vault.Decrypt(c.Request().Context(), credential.Secret)
+		mapData, err := h.secrets.Resolve(c.Request().Context(), credential, models2.CredentialSecretPurposeDiscovery)
 		if err != nil {
-			h.logger.Error("failed to decrypt secret", zap.Error(err))
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to decrypt config")
+			h.logger.Error("failed to resolve secret", zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to resolve credential secret")
 		}
 
 		if _, ok := h.typesManager.GetIntegrationTypeMap()[req.IntegrationType]; !ok {
@@ -209,6 +253,50 @@ func (h *API) DiscoverIntegrations(c echo.Context) error {
 			h.logger.Error("failed to marshal json data", zap.Error(err))
 			return echo.NewHTTPError(http.StatusInternalServerError, "failed to marshal json data")
 		}
+	} else if req.SecretBackend != "" {
+		integrationType = req.IntegrationType
+		secretBackend := models2.CredentialSecretBackend(req.SecretBackend)
+		if !h.secrets.Supports(secretBackend) {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("secret backend %s is not configured", req.SecretBackend))
+		}
+		// the id is set before the credential is created so the read of the reference is audited under it
+		credentialID := uuid.New()
+		mapData, err := h.secrets.ResolveReference(c.Request().Context(), credentialID, secretBackend, req.SecretReference,
+			models2.CredentialSecretPurposeValidation)
+		if err != nil {
+			h.logger.Error("failed to resolve secret reference", zap.Error(err))
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		jsonData, err = json.Marshal(mapData)
+		if err != nil {
+			h.logger.Error("failed to marshal json data", zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to marshal json data")
+		}
+
+		metadataJsonb := pgtype.JSONB{}
+		if err = metadataJsonb.Set([]byte("{}")); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to set metadata")
+		}
+		// the secret never leaves its backend, only the reference is shown
+		maskedSecretJsonb := pgtype.JSONB{}
+		if err = maskedSecretJsonb.Set([]byte("{}")); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to set masked secret")
+		}
+		err = h.database.CreateCredential(&models2.Credential{
+			ID:              credentialID,
+			IntegrationType: req.IntegrationType,
+			CredentialType:  req.CredentialType,
+			Description:     req.Description,
+			MaskedSecret:    maskedSecretJsonb,
+			Metadata:        metadataJsonb,
+			SecretBackend:   secretBackend,
+			SecretReference: req.SecretReference,
+		})
+		if err != nil {
+			h.logger.Error("failed to create credential", zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create credential")
+		}
+		credentialIDStr = credentialID.String()
 	} else {
 		integrationType = req.IntegrationType
 		jsonData, err = json.Marshal(req.Credentials)
@@ -226,22 +314,7 @@ func (h *API) DiscoverIntegrations(c echo.Context) error {
 			h.logger.Error("failed to encrypt secret", zap.Error(err))
 			return echo.NewHTTPError(http.StatusInternalServerError, "failed to encrypt config")
 		}
-		masked := make(map[string]any)
-		for key, value := range req.Credentials {
-			strValue, ok := value.(string) // Ensure the value is a string
-			if !ok {
-				// If it's not a string, just skip masking
-				masked[key] = "not available"
-				continue
-			}
-
-			// Get the last 5 characters, or the full string if it's shorter
-			if len(strValue) > 5 {
-				masked[key] = "*****" + strValue[len(strValue)-5:]
-			} else {
-				masked[key] = "*****" + strValue
-			}
-		}
+		masked := maskCredentialSecret(req.Credentials)
 		// convert to jsonb
 		maskedSecreyJsonData, err := json.Marshal(masked)
 		maskedSecretJsonb := pgtype.JSONB{}
@@ -315,12 +388,46 @@ func (h *API) DiscoverIntegrations(c echo.Context) error {
 		integrationsAPI = append(integrationsAPI, *integrationAPI)
 	}
 
+	if req.CredentialID == nil {
+		var annotations []map[string]string
+		for _, i := range integrationsAPI {
+			annotations = append(annotations, i.Annotations)
+		}
+		if expiresAt := entities.ParseCredentialExpiry(req.Credentials, annotations...); expiresAt != nil {
+			err = h.database.SetCredentialExpiry(credentialIDStr, expiresAt, models2.CredentialExpirySourceAuto)
+			if err != nil {
+				h.logger.Error("failed to set credential expiry", zap.Error(err))
+			}
+		}
+	}
+
 	return c.JSON(http.StatusOK, models.DiscoverIntegrationResponse{
 		CredentialID: credentialIDStr,
 		Integrations: integrationsAPI,
 	})
 }
 
+// maskCredentialSecret keeps the last 5 characters of every string value of the secret
+func maskCredentialSecret(credentials map[string]any) map[string]any {
+	masked := make(map[string]any)
+	for key, value := range credentials {
+		strValue, ok := value.(string) // Ensure the value is a string
+		if !ok {
+			// If it's not a string, just skip masking
+			masked[key] = "not available"
+			continue
+		}
+
+		// Get the last 5 characters, or the full string if it's shorter
+		if len(strValue) > 5 {
+			masked[key] = "*****" + strValue[len(strValue)-5:]
+		} else {
+			masked[key] = "*****" + strValue
+		}
+	}
+	return masked
+}
+
 // AddIntegrations godoc
 //
 //	@Summary		Add integrations
@@ -342,16 +449,19 @@ func (h *API) AddIntegrations(c echo.Context) error {
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid credential id")
 	}
+	if err = h.checkCredentialInScope(c, credentialID); err != nil {
+		return err
+	}
 	credential, err := h.database.GetCredential(req.CredentialID)
 	if err != nil {
 		h.logger.Error("failed to get credential", zap.Error(err))
 		return echo.NewHTTPError(http.StatusNotFound, "credential not found")
 	}
 
-	mapData, err := h.vault.Decrypt(c.Request().Context(), credential.Secret)
+	mapData, err := h.secrets.Resolve(c.Request().Context(), credential, models2.CredentialSecretPurposeDiscovery)
 	if err != nil {
-		h.logger.Error("failed to decrypt secret", zap.Error(err))
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to decrypt config")
+		h.logger.Error("failed to resolve secret", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to resolve credential secret")
 	}
 
 	if _, ok := h.typesManager.GetIntegrationTypeMap()[req.IntegrationType]; !ok {
@@ -457,8 +567,8 @@ func (h *API) AddIntegrations(c echo.Context) error {
 
 // IntegrationHealthcheck godoc
 //
-//	@Summary		Add integrations
-//	@Description	Add integrations by given credential ID and integration IDs
+//	@Summary		Check integration health
+//	@Description	Run the integration health check now and record it in the health history
 //	@Security		BearerToken
 //	@Tags			integrations
 //	@Produce		json
@@ -469,6 +579,9 @@ func (h *API) IntegrationHealthcheck(c echo.Context) error {
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
 	}
+	if !inScope(c, IntegrationID.String()) {
+		return echo.NewHTTPError(http.StatusNotFound, "integration not found")
+	}
 
 	integ, err := h.database.GetIntegration(IntegrationID)
 	if err != nil {
@@ -476,90 +589,21 @@ func (h *API) IntegrationHealthcheck(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get integration")
 	}
 
-	defer func() {
-		if err != nil && integ != nil && integ.State != integration.IntegrationStateArchived {
-			h.logger.Error("healthcheck failed", zap.Error(err))
-			healthcheckTime := time.Now()
-			integ.LastCheck = &healthcheckTime
-			integ.State = integration.IntegrationStateInactive
-			_, err = integ.AddAnnotations("platform/integration/health-reason", err.Error())
-			if err != nil {
-				h.logger.Error("failed to add annotations", zap.Error(err))
-			}
-			err = h.database.UpdateIntegration(integ)
-			if err != nil {
-				h.logger.Error("failed to update integration", zap.Error(err), zap.Any("integration", *integ))
-			}
-		}
-	}()
-
-	credential, err := h.database.GetCredential(integ.CredentialID.String())
-	if err != nil {
-		h.logger.Error("failed to get credential", zap.Error(err))
-		return echo.NewHTTPError(http.StatusNotFound, "credential not found")
-	}
-	if credential == nil {
-		h.logger.Error("credential not found", zap.Any("credentialId", integ.CredentialID))
-		return echo.NewHTTPError(http.StatusNotFound, "credential not found")
-	}
-
-	mapData, err := h.vault.Decrypt(c.Request().Context(), credential.Secret)
+	check, err := h.checkIntegrationHealth(c.Request().Context(), integ, models2.IntegrationHealthCheckTriggerManual)
 	if err != nil {
-		h.logger.Error("failed to decrypt secret", zap.Error(err))
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to decrypt config")
+		h.logger.Error("failed to check integration health", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to check integration health")
 	}
-
-	if _, ok := h.typesManager.GetIntegrationTypeMap()[integ.IntegrationType]; !ok {
-		return echo.NewHTTPError(http.StatusBadRequest, "invalid integration type")
-	}
-
-	jsonData, err := json.Marshal(mapData)
-	if err != nil {
-		h.logger.Error("failed to marshal json data", zap.Error(err))
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to marshal json data")
+	if !check.Healthy {
+		h.logger.Info("healthcheck failed", zap.String("integrationId", IntegrationID.String()), zap.String("reason", check.Reason))
 	}
 
-	integrationType := h.typesManager.GetIntegrationTypeMap()[integ.IntegrationType]
-
-	if integrationType == nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "failed to marshal json data")
-	}
 	integrationApi, err := integ.ToApi()
 	if err != nil {
 		h.logger.Error("failed to create integration api", zap.Error(err))
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create integration api")
 	}
 
-	healthy, err := integrationType.HealthCheck(jsonData, integrationApi.ProviderID, integrationApi.Labels, integrationApi.Annotations)
-	if err != nil || !healthy {
-		h.logger.Error("healthcheck failed", zap.Error(err))
-		if integ.State != integration.IntegrationStateArchived {
-			integ.State = integration.IntegrationStateInactive
-		}
-		_, err = integ.AddAnnotations("platform/integration/health-reason", err.Error())
-		if err != nil {
-			h.logger.Error("failed to add annotations", zap.Error(err))
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to add annotations")
-		}
-	} else {
-		if integ.State != integration.IntegrationStateArchived {
-			integ.State = integration.IntegrationStateActive
-		}
-	}
-	healthcheckTime := time.Now()
-	integ.LastCheck = &healthcheckTime
-	err = h.database.UpdateIntegration(integ)
-	if err != nil {
-		h.logger.Error("failed to update integration", zap.Error(err), zap.Any("integration", *integ))
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update integration")
-	}
-
-	integrationApi, err = integ.ToApi()
-	if err != nil {
-		h.logger.Error("failed to create integration api", zap.Error(err))
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create integration api")
-	}
-
 	return c.JSON(http.StatusOK, *integrationApi)
 }
 
@@ -578,6 +622,9 @@ func (h *API) Delete(c echo.Context) error {
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
 	}
+	if !inScope(c, IntegrationID.String()) {
+		return echo.NewHTTPError(http.StatusNotFound, "integration not found")
+	}
 
 	err = h.database.DeleteIntegration(IntegrationID)
 	if err != nil {
@@ -621,6 +668,7 @@ func (h *API) List(c echo.Context) error {
 		}
 		items = append(items, *item)
 	}
+	items = filterInScope(c, items)
 
 	return c.JSON(http.StatusOK, models.ListIntegrationsResponse{
 		Integrations: items,
@@ -644,7 +692,7 @@ func (h *API) ListByFilters(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
 	}
 
-	integrations, err := h.database.ListIntegrationsByFilters(req.IntegrationID, req.IntegrationType, req.NameRegex, req.ProviderIDRegex)
+	integrations, err := h.database.ListIntegrationsByFilters(req.IntegrationID, req.IntegrationType, req.NameRegex, req.ProviderIDRegex, req.Labels)
 	if err != nil {
 		h.logger.Error("failed to list credentials", zap.Error(err))
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list credential")
@@ -659,6 +707,7 @@ func (h *API) ListByFilters(c echo.Context) error {
 		}
 		items = append(items, *item)
 	}
+	items = filterInScope(c, items)
 
 	totalCount := len(items)
 	sort.Slice(items, func(i, j int) bool {
@@ -718,7 +767,7 @@ func (h *API) ListIntegrationGroups(c echo.Context) error {
 			return echo.NewHTTPError(http.StatusInternalServerError, "failed to convert integration group to API model")
 		}
 		if populateIntegrations {
-			integrations, err := h.database.ListIntegrationsByFilters(integrationGroupApi.IntegrationIds, nil, nil, nil)
+			integrations, err := h.database.ListIntegrationsByFilters(integrationGroupApi.IntegrationIds, nil, nil, nil, nil)
 			if err != nil {
 				h.logger.Error("failed to list integrations", zap.Error(err))
 				return echo.NewHTTPError(http.StatusInternalServerError, "failed to list integrations")
@@ -732,7 +781,7 @@ func (h *API) ListIntegrationGroups(c echo.Context) error {
 				}
 				apiIntegrations = append(apiIntegrations, *apiIntegration)
 			}
-			integrationGroupApi.Integrations = apiIntegrations
+			integrationGroupApi.Integrations = filterInScope(c, apiIntegrations)
 		}
 		items = append(items, *integrationGroupApi)
 	}
@@ -781,7 +830,7 @@ func (h *API) GetIntegrationGroup(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to convert integration group to API model")
 	}
 	if populateIntegrations {
-		integrations, err := h.database.ListIntegrationsByFilters(integrationGroupApi.IntegrationIds, nil, nil, nil)
+		integrations, err := h.database.ListIntegrationsByFilters(integrationGroupApi.IntegrationIds, nil, nil, nil, nil)
 		if err != nil {
 			h.logger.Error("failed to list integrations", zap.Error(err))
 			return echo.NewHTTPError(http.StatusInternalServerError, "failed to list integrations")
@@ -795,7 +844,7 @@ func (h *API) GetIntegrationGroup(c echo.Context) error {
 			}
 			apiIntegrations = append(apiIntegrations, *apiIntegration)
 		}
-		integrationGroupApi.Integrations = apiIntegrations
+		integrationGroupApi.Integrations = filterInScope(c, apiIntegrations)
 	}
 
 	return c.JSON(http.StatusOK, integrationGroupApi)
@@ -816,6 +865,9 @@ func (h *API) Get(c echo.Context) error {
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
 	}
+	if !inScope(c, IntegrationID.String()) {
+		return echo.NewHTTPError(http.StatusNotFound, "integration not found")
+	}
 
 	integration, err := h.database.GetIntegration(IntegrationID)
 	if err != nil {
@@ -847,6 +899,9 @@ func (h *API) Update(c echo.Context) error {
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
 	}
+	if !inScope(c, IntegrationID.String()) {
+		return echo.NewHTTPError(http.StatusNotFound, "integration not found")
+	}
 
 	var req models.UpdateRequest
 
@@ -865,43 +920,29 @@ func (h *API) Update(c echo.Context) error {
 		h.logger.Error("failed to get credential", zap.Error(err))
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get credential")
 	}
-
-	credentials, err := h.vault.Decrypt(c.Request().Context(), credential.Secret)
-	if err != nil {
-		h.logger.Error("failed to decrypt secret", zap.Error(err))
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to decrypt config")
-	}
-
-	for k, v := range req.Credentials {
-		credentials[k] = v
-	}
-
-	secret, err := h.vault.Encrypt(c.Request().Context(), credentials)
-	if err != nil {
-		h.logger.Error("failed to encrypt secret", zap.Error(err))
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to encrypt config")
+	// the credential can be shared with integrations outside of the caller's scope
+	if err = h.checkCredentialInScope(c, credential.ID); err != nil {
+		return err
 	}
-	masked := make(map[string]any)
-	for key, value := range req.Credentials {
-		strValue, ok := value.(string) // Ensure the value is a string
-		if !ok {
-			// If it's not a string, just skip masking
-			masked[key] = "not available"
-			continue
-		}
-
-		// Get the last 5 characters, or the full string if it's shorter
-		if len(strValue) > 5 {
-			masked[key] = "*****" + strValue[len(strValue)-5:]
-		} else {
-			masked[key] = "*****" + strValue
+	// without a new secret only the description changes, the secret, its expiry and rotation time are kept
+	if len(req.Credentials) == 0 {
+		if err = h.database.UpdateCredentialFields(credential.ID.String(), db.CredentialUpdate{Description: &req.Description}); err != nil {
+			h.logger.Error("failed to update credential", zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update credential")
 		}
+		return c.NoContent(http.StatusOK)
+	}
+	if credential.IsSecretReference() {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("credential secret is kept in %s, update it there", credential.SecretBackend))
 	}
 
-	err = h.database.UpdateCredential(integration.CredentialID.String(), secret, masked, req.Description)
+	result, err := h.credentials.RotateSecret(c.Request().Context(), credential, req.Credentials,
+		db.CredentialUpdate{Description: &req.Description})
 	if err != nil {
-		h.logger.Error("failed to update credential", zap.Error(err))
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update credential")
+		return err
+	}
+	if !result.Rotated {
+		return c.JSON(http.StatusBadRequest, result)
 	}
 
 	return c.NoContent(http.StatusOK)
@@ -946,7 +987,7 @@ func (h *API) ListIntegrationTypes(c echo.Context) error {
 
 	var items []models.ListIntegrationTypesItem
 	for _, plugin := range plugins {
-		integrations, err := h.database.ListIntegrationsByFilters(nil, []string{plugin.IntegrationType.String()}, nil, nil)
+		integrations, err := h.database.ListIntegrationsByFilters(nil, []string{plugin.IntegrationType.String()}, nil, nil, nil)
 		if err != nil {
 			h.logger.Error("failed to list integrations", zap.Error(err))
 			return echo.NewHTTPError(http.StatusInternalServerError, "failed to list integrations")
@@ -1306,6 +1347,15 @@ func (h *API) UpgradeIntegrationType(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "the integration type is not enabled")
 	}
 
+	platformVersion, err := utils2.GetPlatformVersion(ctx, h.coreClient)
+	if err != nil {
+		h.logger.Error("failed to get platform version", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get platform version")
+	}
+	if compatible, reason := utils2.CheckPlatformVersion(platformVersion, plugin.SupportedPlatformVersion); !compatible {
+		return echo.NewHTTPError(http.StatusBadRequest, reason)
+	}
+
 	var integrationTypes []integration.Type
 	integrationTypes = append(integrationTypes, integration.Type(integrationTypeName))
 
@@ -1777,6 +1827,9 @@ func (a *API) SetResourceTypesForIntegration(c echo.Context) error {
 		a.logger.Error("failed to parse integration id", zap.Error(err))
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to parse integration id")
 	}
+	if !inScope(c, integrationID.String()) {
+		return echo.NewHTTPError(http.StatusNotFound, "integration not found")
+	}
 
 	req := new(models.SetResourceTypesForIntegration)
 	if err = c.Bind(req); err != nil {
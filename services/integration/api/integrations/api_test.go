@@ -0,0 +1,35 @@
+package integrations
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateDescriptionOnly(t *testing.T) {
+	h, mock := mockAPI(t)
+	integrationID, credentialID := uuid.NewString(), uuid.NewString()
+	mock.ExpectQuery(`SELECT \* FROM "integrations" WHERE integration_id = \$1`).
+		WithArgs(integrationID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"integration_id", "credential_id"}).AddRow(integrationID, credentialID))
+	mock.ExpectQuery(`SELECT \* FROM "credentials" WHERE id = \$1`).
+		WithArgs(credentialID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "secret", "masked_secret"}).AddRow(credentialID, "encrypted", `{"token":"*****token"}`))
+	// neither the secret nor its expiry and rotation time are touched
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "credentials" SET "description"=\$1,"updated_at"=\$2 WHERE id = \$3`).
+		WithArgs("production account", sqlmock.AnyArg(), credentialID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	c, rec := scopedRequest(http.MethodPost, "/", `{"description":"production account"}`, "")
+	c.SetParamNames("IntegrationID")
+	c.SetParamValues(integrationID)
+	require.NoError(t, h.Update(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
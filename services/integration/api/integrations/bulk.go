@@ -0,0 +1,846 @@
+package integrations
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/opengovern/og-util/pkg/api"
+	"github.com/opengovern/og-util/pkg/httpclient"
+	"github.com/opengovern/og-util/pkg/integration"
+	"github.com/opengovern/opensecurity/services/integration/api/models"
+	"github.com/opengovern/opensecurity/services/integration/entities"
+	models2 "github.com/opengovern/opensecurity/services/integration/models"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+const (
+	manifestFormatYAML = "yaml"
+	manifestFormatCSV  = "csv"
+
+	// platformAnnotationPrefix annotations are managed by the platform and are not exported
+	platformAnnotationPrefix = "platform/integration/"
+)
+
+var manifestCSVHeader = []string{"integration_type", "provider_id", "name", "credential_id", "labels", "annotations", "frameworks"}
+
+// BulkImportIntegrations godoc
+//
+//	@Summary		Bulk import integrations
+//	@Description	Create or update integrations from a YAML or CSV manifest. Every row references an existing credential
+//	@Description	by credential_id or a credential of the YAML manifest credentials by credential_ref, the integration is
+//	@Description	discovered with it and health checked. Manifest credentials are created once a row using them is valid.
+//	@Description	With dry_run nothing is saved and the per-credential and per-row validation and health check results are returned.
+//	@Description	Callers limited to some integrations can only update those and use the credentials all of whose integrations they can access.
+//	@Security		BearerToken
+//	@Tags			integrations
+//	@Accept			application/yaml,text/csv,multipart/form-data
+//	@Produce		json
+//	@Param			dry_run	query		bool	false	"only validate the manifest"
+//	@Param			format	query		string	false	"manifest format, yaml or csv. detected from the content type or file name by default"
+//	@Success		200		{object}	models.BulkImportResponse
+//	@Router			/integration/api/v1/integrations/bulk/import [post]
+func (h *API) BulkImportIntegrations(c echo.Context) error {
+	dryRun := false
+	if dryRunStr := c.QueryParam("dry_run"); dryRunStr != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(dryRunStr)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "dry_run is not a valid boolean")
+		}
+	}
+
+	data, format, err := readManifest(c)
+	if err != nil {
+		return err
+	}
+	manifest, err := parseIntegrationManifest(data, format)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid manifest: %v", err))
+	}
+
+	// new credentials are not used by any integration yet, so they are out of every scope
+	if scoped(c) && len(manifest.Credentials) > 0 {
+		return echo.NewHTTPError(http.StatusForbidden, "manifest credentials require access to all integrations")
+	}
+
+	existingIntegrations, err := h.database.ListIntegration(nil)
+	if err != nil {
+		h.logger.Error("failed to list integrations", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list integrations")
+	}
+	existing := make(map[string]models2.Integration)
+	for _, i := range existingIntegrations {
+		existing[manifestKey(i.IntegrationType.String(), i.ProviderID)] = i
+	}
+
+	importer := bulkImporter{
+		api:                 h,
+		c:                   c,
+		ctx:                 c.Request().Context(),
+		dryRun:              dryRun,
+		existing:            existing,
+		seen:                make(map[string]int),
+		discovered:          make(map[string]*discoveredCredential),
+		manifestCredentials: make(map[string]*discoveredCredential),
+		touchedCredentials:  make(map[string]bool),
+	}
+	response := models.BulkImportResponse{DryRun: dryRun}
+	// results are filled in place while the rows are imported, so the slice is never grown afterwards
+	response.Credentials = make([]models.BulkImportCredentialResult, len(manifest.Credentials))
+	for idx, entry := range manifest.Credentials {
+		importer.prepareCredential(entry, &response.Credentials[idx])
+	}
+
+	frameworkIntegrations := make(map[string][]string)
+	frameworkRows := make(map[string][]int)
+	for idx, entry := range manifest.Integrations {
+		result := importer.importEntry(idx+1, entry)
+		if result.Valid {
+			for _, framework := range entry.Frameworks {
+				frameworkIntegrations[framework] = append(frameworkIntegrations[framework], result.IntegrationID)
+				frameworkRows[framework] = append(frameworkRows[framework], len(response.Rows))
+			}
+		}
+		response.Rows = append(response.Rows, result)
+	}
+
+	h.applyManifestFrameworks(c.Request().Context(), dryRun, frameworkIntegrations, frameworkRows, response.Rows)
+	if !dryRun {
+		for credentialID := range importer.touchedCredentials {
+			integrations, err := h.database.ListIntegrationsByCredentialID(credentialID)
+			if err != nil {
+				h.logger.Error("failed to list credential integrations", zap.String("credentialId", credentialID), zap.Error(err))
+				continue
+			}
+			if err = h.database.UpdateCredentialIntegrationCount(credentialID, len(integrations)); err != nil {
+				h.logger.Error("failed to update credential integration count", zap.String("credentialId", credentialID), zap.Error(err))
+			}
+		}
+	}
+
+	for _, credential := range response.Credentials {
+		if credential.Created {
+			response.CredentialsCreated++
+		}
+	}
+	for _, row := range response.Rows {
+		switch {
+		case !row.Valid:
+			response.Failed++
+		case row.Action == models.BulkImportActionCreate:
+			response.Created++
+		case row.Action == models.BulkImportActionUpdate:
+			response.Updated++
+		}
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// ExportIntegrations godoc
+//
+//	@Summary		Export integrations
+//	@Description	Export the integrations as a YAML or CSV manifest which can be imported again. Secrets are not exported.
+//	@Security		BearerToken
+//	@Tags			integrations
+//	@Produce		application/yaml,text/csv
+//	@Param			format				query	string		false	"yaml (default) or csv"
+//	@Param			integration_type	query	[]string	false	"integration type filter"
+//	@Success		200
+//	@Router			/integration/api/v1/integrations/bulk/export [get]
+func (h *API) ExportIntegrations(c echo.Context) error {
+	format := strings.ToLower(c.QueryParam("format"))
+	if format == "" {
+		format = manifestFormatYAML
+	}
+	if format != manifestFormatYAML && format != manifestFormatCSV {
+		return echo.NewHTTPError(http.StatusBadRequest, "format should be yaml or csv")
+	}
+
+	var integrationTypes []integration.Type
+	for _, t := range c.QueryParams()["integration_type"] {
+		integrationTypes = append(integrationTypes, integration.Type(t))
+	}
+	integrations, err := h.database.ListIntegration(integrationTypes)
+	if err != nil {
+		h.logger.Error("failed to list integrations", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list integrations")
+	}
+
+	clientCtx := &httpclient.Context{UserRole: api.AdminRole, Ctx: c.Request().Context()}
+	assignments, err := h.complianceClient.ListAssignmentsByIntegration(clientCtx)
+	if err != nil {
+		h.logger.Error("failed to list framework assignments", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list framework assignments")
+	}
+	frameworks := make(map[string][]string)
+	for _, assignment := range assignments {
+		frameworks[assignment.IntegrationID] = assignment.FrameworkIDs
+	}
+
+	manifest := models.IntegrationManifest{Integrations: []models.IntegrationManifestEntry{}}
+	for _, i := range integrations {
+		if i.State == integration.IntegrationStateSample || !inScope(c, i.IntegrationID.String()) {
+			continue
+		}
+		integrationApi, err := i.ToApi()
+		if err != nil {
+			h.logger.Error("failed to create integration api", zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create integration api")
+		}
+		annotations := make(map[string]string)
+		for k, v := range integrationApi.Annotations {
+			if strings.HasPrefix(k, platformAnnotationPrefix) {
+				continue
+			}
+			annotations[k] = v
+		}
+		integrationFrameworks := frameworks[integrationApi.IntegrationID]
+		sort.Strings(integrationFrameworks)
+		manifest.Integrations = append(manifest.Integrations, models.IntegrationManifestEntry{
+			IntegrationType: integrationApi.IntegrationType.String(),
+			ProviderID:      integrationApi.ProviderID,
+			Name:            integrationApi.Name,
+			CredentialID:    integrationApi.CredentialID,
+			Labels:          integrationApi.Labels,
+			Annotations:     annotations,
+			Frameworks:      integrationFrameworks,
+		})
+	}
+	sort.Slice(manifest.Integrations, func(i, j int) bool {
+		a, b := manifest.Integrations[i], manifest.Integrations[j]
+		if a.IntegrationType != b.IntegrationType {
+			return a.IntegrationType < b.IntegrationType
+		}
+		return a.ProviderID < b.ProviderID
+	})
+
+	var data []byte
+	var contentType string
+	if format == manifestFormatCSV {
+		data, err = writeManifestCSV(manifest.Integrations)
+		contentType = "text/csv"
+	} else {
+		data, err = yaml.Marshal(manifest)
+		contentType = "application/yaml"
+	}
+	if err != nil {
+		h.logger.Error("failed to write manifest", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to write manifest")
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=integrations.%s", format))
+	return c.Blob(http.StatusOK, contentType, data)
+}
+
+type discoveredCredential struct {
+	credential   *models2.Credential
+	jsonData     []byte
+	integrations map[string]integration.Integration
+	err          error
+
+	// manifest is set for the manifest credentials, which are only saved once a row uses them
+	manifest *models.BulkImportCredentialResult
+	// secret is the inline secret of a manifest credential, encrypted when the credential is saved
+	secret map[string]any
+}
+
+type bulkImporter struct {
+	api      *API
+	c        echo.Context
+	ctx      context.Context
+	dryRun   bool
+	existing map[string]models2.Integration
+	// seen maps the manifest key of every row to its row number to reject duplicates
+	seen       map[string]int
+	discovered map[string]*discoveredCredential
+	// manifestCredentials maps the ref of the manifest credentials to their discovery
+	manifestCredentials map[string]*discoveredCredential
+	// touchedCredentials are the credentials whose integrations were saved or moved to another credential
+	touchedCredentials map[string]bool
+}
+
+// applyManifestFrameworks assigns the frameworks of the valid rows to their integrations, or with dryRun only checks
+// the frameworks exist. Failures are reported on the rows of the framework.
+func (h *API) applyManifestFrameworks(ctx context.Context, dryRun bool, frameworkIntegrations map[string][]string,
+	frameworkRows map[string][]int, rows []models.BulkImportRowResult) {
+	clientCtx := &httpclient.Context{UserRole: api.AdminRole, Ctx: ctx}
+	for framework, integrationIDs := range frameworkIntegrations {
+		var rowErr string
+		if dryRun {
+			if _, err := h.complianceClient.GetBenchmark(clientCtx, framework); err != nil {
+				var httpErr *echo.HTTPError
+				if errors.As(err, &httpErr) && httpErr.Code == http.StatusNotFound {
+					rowErr = fmt.Sprintf("framework %s not found", framework)
+				} else {
+					h.logger.Error("failed to get framework", zap.String("framework", framework), zap.Error(err))
+					rowErr = fmt.Sprintf("failed to check framework %s: %v", framework, err)
+				}
+			}
+		} else if err := h.complianceClient.AddFrameworkAssignments(clientCtx, framework, integrationIDs); err != nil {
+			h.logger.Error("failed to assign framework", zap.String("framework", framework), zap.Error(err))
+			rowErr = fmt.Sprintf("failed to assign framework %s: %v", framework, err)
+		}
+		if rowErr == "" {
+			continue
+		}
+		for _, row := range frameworkRows[framework] {
+			rows[row].Errors = append(rows[row].Errors, rowErr)
+		}
+	}
+}
+
+// prepareCredential validates a manifest credential and discovers its integrations. Nothing is saved,
+// the credential is created by saveCredential once the first row using it is valid.
+func (b *bulkImporter) prepareCredential(entry models.CredentialManifestEntry, result *models.BulkImportCredentialResult) {
+	result.Ref = entry.Ref
+	result.IntegrationType = entry.IntegrationType
+	discovered := &discoveredCredential{integrations: make(map[string]integration.Integration), manifest: result}
+	fail := func(format string, args ...any) {
+		discovered.err = fmt.Errorf(format, args...)
+		result.Errors = append(result.Errors, discovered.err.Error())
+	}
+
+	if entry.Ref == "" {
+		fail("ref is required")
+		return
+	}
+	if _, ok := b.manifestCredentials[entry.Ref]; ok {
+		fail("duplicate credential ref %s", entry.Ref)
+		return
+	}
+	b.manifestCredentials[entry.Ref] = discovered
+
+	integrationType := integration.Type(entry.IntegrationType)
+	integrationTypeImpl, ok := b.api.typesManager.GetIntegrationTypeMap()[integrationType]
+	if !ok || integrationTypeImpl == nil {
+		fail("integration type %s is not loaded", entry.IntegrationType)
+		return
+	}
+
+	credential := &models2.Credential{
+		ID:              uuid.New(),
+		IntegrationType: integrationType,
+		CredentialType:  entry.CredentialType,
+		Description:     entry.Description,
+	}
+	var mapData map[string]any
+	switch {
+	case entry.SecretBackend != "" && len(entry.Credentials) > 0:
+		fail("only one of credentials or secret_backend should be set")
+		return
+	case entry.SecretBackend != "":
+		secretBackend := models2.CredentialSecretBackend(entry.SecretBackend)
+		if !b.api.secrets.Supports(secretBackend) {
+			fail("secret backend %s is not configured", entry.SecretBackend)
+			return
+		}
+		var err error
+		mapData, err = b.api.secrets.ResolveReference(b.ctx, credential.ID, secretBackend, entry.SecretReference,
+			models2.CredentialSecretPurposeValidation)
+		if err != nil {
+			fail("%v", err)
+			return
+		}
+		credential.SecretBackend = secretBackend
+		credential.SecretReference = entry.SecretReference
+		// the secret never leaves its backend, only the reference is shown
+		if err = credential.MaskedSecret.Set([]byte("{}")); err != nil {
+			fail("failed to set masked secret")
+			return
+		}
+	case len(entry.Credentials) > 0:
+		mapData = entry.Credentials
+		discovered.secret = entry.Credentials
+		maskedJson, err := json.Marshal(maskCredentialSecret(entry.Credentials))
+		if err != nil {
+			fail("failed to mask credentials")
+			return
+		}
+		if err = credential.MaskedSecret.Set(maskedJson); err != nil {
+			fail("failed to set masked secret")
+			return
+		}
+	default:
+		fail("credentials or secret_backend is required")
+		return
+	}
+	if err := credential.Metadata.Set([]byte("{}")); err != nil {
+		fail("failed to set metadata")
+		return
+	}
+	discovered.credential = credential
+
+	var err error
+	discovered.jsonData, err = json.Marshal(mapData)
+	if err != nil {
+		fail("failed to marshal credentials")
+		return
+	}
+	integrations, err := integrationTypeImpl.DiscoverIntegrations(discovered.jsonData)
+	if err != nil {
+		fail("failed to discover integrations: %v", err)
+		return
+	}
+	for _, i := range integrations {
+		discovered.integrations[i.ProviderID] = i
+	}
+	result.Valid = true
+}
+
+// saveCredential creates a manifest credential the first time a row using it is saved
+func (b *bulkImporter) saveCredential(discovered *discoveredCredential) error {
+	if discovered.manifest == nil || discovered.manifest.Created {
+		return nil
+	}
+
+	credential := discovered.credential
+	if credential.SecretBackend == models2.CredentialSecretBackendPlatform {
+		secret, err := b.api.vault.Encrypt(b.ctx, discovered.secret)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt credentials: %w", err)
+		}
+		credential.Secret = secret
+	}
+	if err := b.api.database.CreateCredential(credential); err != nil {
+		return err
+	}
+	discovered.manifest.Created = true
+	discovered.manifest.CredentialID = credential.ID.String()
+
+	if discovered.secret != nil {
+		var annotations []map[string]string
+		for _, in := range discovered.integrations {
+			i := models2.Integration{Integration: in}
+			if integrationApi, err := i.ToApi(); err == nil {
+				annotations = append(annotations, integrationApi.Annotations)
+			}
+		}
+		if expiresAt := entities.ParseCredentialExpiry(discovered.secret, annotations...); expiresAt != nil {
+			err := b.api.database.SetCredentialExpiry(credential.ID.String(), expiresAt, models2.CredentialExpirySourceAuto)
+			if err != nil {
+				b.api.logger.Error("failed to set credential expiry", zap.Error(err))
+			}
+		}
+	}
+	return nil
+}
+
+func (b *bulkImporter) importEntry(row int, entry models.IntegrationManifestEntry) models.BulkImportRowResult {
+	result := models.BulkImportRowResult{
+		Row:             row,
+		IntegrationType: entry.IntegrationType,
+		ProviderID:      entry.ProviderID,
+		Action:          models.BulkImportActionSkip,
+	}
+	fail := func(format string, args ...any) models.BulkImportRowResult {
+		result.Errors = append(result.Errors, fmt.Sprintf(format, args...))
+		return result
+	}
+
+	if entry.IntegrationType == "" {
+		return fail("integration_type is required")
+	}
+	if entry.ProviderID == "" {
+		return fail("provider_id is required")
+	}
+	credentialName := entry.CredentialID
+	switch {
+	case entry.CredentialID != "" && entry.CredentialRef != "":
+		return fail("only one of credential_id or credential_ref should be set")
+	case entry.CredentialRef != "":
+		credentialName = entry.CredentialRef
+		if _, ok := b.manifestCredentials[entry.CredentialRef]; !ok {
+			return fail("credential_ref %s is not a manifest credential", entry.CredentialRef)
+		}
+	default:
+		if _, err := uuid.Parse(entry.CredentialID); err != nil {
+			return fail("credential_id should be a valid credential id")
+		}
+	}
+	key := manifestKey(entry.IntegrationType, entry.ProviderID)
+	if previous, ok := b.seen[key]; ok {
+		return fail("duplicate of row %d", previous)
+	}
+	b.seen[key] = row
+
+	integrationType := integration.Type(entry.IntegrationType)
+	integrationTypeImpl, ok := b.api.typesManager.GetIntegrationTypeMap()[integrationType]
+	if !ok || integrationTypeImpl == nil {
+		return fail("integration type %s is not loaded", entry.IntegrationType)
+	}
+	plugin, err := b.api.database.GetPluginByID(entry.IntegrationType)
+	if err != nil || plugin == nil {
+		return fail("integration type %s is not installed", entry.IntegrationType)
+	}
+	if plugin.OperationalStatus != models2.IntegrationPluginOperationalStatusEnabled ||
+		plugin.InstallState == models2.IntegrationTypeInstallStateNotInstalled {
+		return fail("integration type %s is not enabled", entry.IntegrationType)
+	}
+
+	var discovered *discoveredCredential
+	if entry.CredentialRef != "" {
+		discovered = b.manifestCredentials[entry.CredentialRef]
+	} else {
+		discovered = b.discover(entry.CredentialID)
+	}
+	if discovered.err != nil {
+		if discovered.manifest != nil {
+			return fail("credential %s is invalid", credentialName)
+		}
+		return fail("%v", discovered.err)
+	}
+	if discovered.credential.IntegrationType != integrationType {
+		return fail("credential %s belongs to integration type %s", credentialName, discovered.credential.IntegrationType)
+	}
+	discoveredIntegration, ok := discovered.integrations[entry.ProviderID]
+	if !ok {
+		return fail("provider_id %s was not discovered with credential %s", entry.ProviderID, credentialName)
+	}
+
+	i := models2.Integration{Integration: discoveredIntegration}
+	result.Action = models.BulkImportActionCreate
+	if current, ok := b.existing[key]; ok {
+		if !inScope(b.c, current.IntegrationID.String()) {
+			return fail("integration %s is outside of your integration scope", entry.ProviderID)
+		}
+		i = current
+		result.Action = models.BulkImportActionUpdate
+		result.IntegrationID = current.IntegrationID.String()
+	}
+	previousCredentialID := i.CredentialID
+	i.IntegrationType = integrationType
+	i.CredentialID = discovered.credential.ID
+	if entry.Name != "" {
+		i.Name = entry.Name
+	}
+	if err = applyManifestMetadata(&i, entry, result.Action == models.BulkImportActionUpdate); err != nil {
+		return fail("invalid labels or annotations: %v", err)
+	}
+	result.Valid = true
+
+	integrationApi, err := i.ToApi()
+	if err != nil {
+		return fail("failed to read integration: %v", err)
+	}
+	healthy, err := integrationTypeImpl.HealthCheck(discovered.jsonData, i.ProviderID, integrationApi.Labels, integrationApi.Annotations)
+	result.Healthy = err == nil && healthy
+	if !result.Healthy {
+		reason := "health check failed"
+		if err != nil {
+			reason = err.Error()
+		}
+		result.Errors = append(result.Errors, fmt.Sprintf("health check: %s", reason))
+	}
+	if b.dryRun {
+		return result
+	}
+
+	if i.State != integration.IntegrationStateArchived {
+		if result.Healthy {
+			i.State = integration.IntegrationStateActive
+		} else {
+			i.State = integration.IntegrationStateInactive
+		}
+	}
+	if err = b.saveCredential(discovered); err != nil {
+		b.api.logger.Error("failed to create credential", zap.String("ref", entry.CredentialRef), zap.Error(err))
+		result.Valid = false
+		return fail("failed to create credential %s", credentialName)
+	}
+	checkedAt := time.Now()
+	i.LastCheck = &checkedAt
+	if result.Action == models.BulkImportActionCreate {
+		err = b.api.database.CreateIntegration(&i)
+	} else {
+		err = b.api.database.UpdateIntegration(&i)
+	}
+	if err != nil {
+		b.api.logger.Error("failed to save integration", zap.String("providerId", i.ProviderID), zap.Error(err))
+		result.Valid = false
+		return fail("failed to save integration")
+	}
+	result.IntegrationID = i.IntegrationID.String()
+	b.existing[key] = i
+	b.touchedCredentials[i.CredentialID.String()] = true
+	// an integration moved to another credential changes the integration count of both
+	if result.Action == models.BulkImportActionUpdate && previousCredentialID != i.CredentialID {
+		b.touchedCredentials[previousCredentialID.String()] = true
+	}
+
+	return result
+}
+
+// discover decrypts the credential and discovers its integrations once per credential
+func (b *bulkImporter) discover(credentialID string) *discoveredCredential {
+	if discovered, ok := b.discovered[credentialID]; ok {
+		return discovered
+	}
+	discovered := &discoveredCredential{integrations: make(map[string]integration.Integration)}
+	b.discovered[credentialID] = discovered
+
+	credential, err := b.api.database.GetCredential(credentialID)
+	if err != nil || credential == nil {
+		discovered.err = fmt.Errorf("credential %s not found", credentialID)
+		return discovered
+	}
+	ok, err := b.api.credentialInScope(b.c, credentialID)
+	if err != nil {
+		b.api.logger.Error("failed to list credential integrations", zap.Error(err))
+		discovered.err = fmt.Errorf("failed to list integrations of credential %s", credentialID)
+		return discovered
+	}
+	if !ok {
+		discovered.err = fmt.Errorf("credential %s not found", credentialID)
+		return discovered
+	}
+	discovered.credential = credential
+
+	mapData, err := b.api.secrets.Resolve(b.ctx, credential, models2.CredentialSecretPurposeDiscovery)
+	if err != nil {
+		b.api.logger.Error("failed to resolve secret", zap.Error(err))
+		discovered.err = fmt.Errorf("failed to resolve secret of credential %s", credentialID)
+		return discovered
+	}
+	discovered.jsonData, err = json.Marshal(mapData)
+	if err != nil {
+		discovered.err = fmt.Errorf("failed to marshal credential %s", credentialID)
+		return discovered
+	}
+
+	integrationType, ok := b.api.typesManager.GetIntegrationTypeMap()[credential.IntegrationType]
+	if !ok || integrationType == nil {
+		discovered.err = fmt.Errorf("integration type %s is not loaded", credential.IntegrationType)
+		return discovered
+	}
+	integrations, err := integrationType.DiscoverIntegrations(discovered.jsonData)
+	if err != nil {
+		discovered.err = fmt.Errorf("failed to discover integrations with credential %s: %v", credentialID, err)
+		return discovered
+	}
+	for _, i := range integrations {
+		discovered.integrations[i.ProviderID] = i
+	}
+
+	return discovered
+}
+
+// applyManifestMetadata sets the labels and annotations of the manifest on the integration. Labels of an existing
+// integration are replaced by the manifest while annotations are merged to keep the platform managed ones.
+func applyManifestMetadata(i *models2.Integration, entry models.IntegrationManifestEntry, replaceLabels bool) error {
+	labels := make(map[string]string)
+	if !replaceLabels && i.Labels.Status == pgtype.Present {
+		if err := json.Unmarshal(i.Labels.Bytes, &labels); err != nil {
+			return err
+		}
+	}
+	for k, v := range entry.Labels {
+		labels[k] = v
+	}
+	annotations := make(map[string]string)
+	if i.Annotations.Status == pgtype.Present {
+		if err := json.Unmarshal(i.Annotations.Bytes, &annotations); err != nil {
+			return err
+		}
+	}
+	for k, v := range entry.Annotations {
+		annotations[k] = v
+	}
+
+	labelsJson, err := json.Marshal(labels)
+	if err != nil {
+		return err
+	}
+	if err = i.Labels.Set(labelsJson); err != nil {
+		return err
+	}
+	annotationsJson, err := json.Marshal(annotations)
+	if err != nil {
+		return err
+	}
+	return i.Annotations.Set(annotationsJson)
+}
+
+func manifestKey(integrationType, providerID string) string {
+	return integrationType + "/" + providerID
+}
+
+// readManifest reads the manifest from the request body or the uploaded file and detects its format
+func readManifest(c echo.Context) ([]byte, string, error) {
+	format := strings.ToLower(c.QueryParam("format"))
+	contentType := c.Request().Header.Get(echo.HeaderContentType)
+
+	var data []byte
+	if strings.HasPrefix(contentType, echo.MIMEMultipartForm) {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return nil, "", echo.NewHTTPError(http.StatusBadRequest, "manifest file is required")
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, "", echo.NewHTTPError(http.StatusInternalServerError, "Failed to open uploaded file")
+		}
+		defer file.Close()
+		data, err = io.ReadAll(file)
+		if err != nil {
+			return nil, "", echo.NewHTTPError(http.StatusInternalServerError, "Failed to read uploaded file")
+		}
+		if format == "" && strings.EqualFold(filepath.Ext(fileHeader.Filename), ".csv") {
+			format = manifestFormatCSV
+		}
+	} else {
+		var err error
+		data, err = io.ReadAll(c.Request().Body)
+		if err != nil {
+			return nil, "", echo.NewHTTPError(http.StatusBadRequest, "failed to read manifest")
+		}
+		if format == "" && strings.HasPrefix(contentType, "text/csv") {
+			format = manifestFormatCSV
+		}
+	}
+	if format == "" {
+		format = manifestFormatYAML
+	}
+	if format != manifestFormatYAML && format != manifestFormatCSV {
+		return nil, "", echo.NewHTTPError(http.StatusBadRequest, "format should be yaml or csv")
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, "", echo.NewHTTPError(http.StatusBadRequest, "manifest is empty")
+	}
+
+	return data, format, nil
+}
+
+// parseIntegrationManifest parses a YAML or CSV manifest. Credentials can only be defined in YAML manifests,
+// the CSV rows reference existing credentials.
+func parseIntegrationManifest(data []byte, format string) (models.IntegrationManifest, error) {
+	var manifest models.IntegrationManifest
+	if format == manifestFormatYAML {
+		err := yaml.Unmarshal(data, &manifest)
+		return manifest, err
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return manifest, err
+	}
+	if len(records) == 0 {
+		return manifest, fmt.Errorf("missing csv header")
+	}
+	columns := make(map[string]int)
+	for idx, column := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(column))] = idx
+	}
+	for _, required := range []string{"integration_type", "provider_id", "credential_id"} {
+		if _, ok := columns[required]; !ok {
+			return manifest, fmt.Errorf("missing csv column %s", required)
+		}
+	}
+	get := func(record []string, column string) string {
+		idx, ok := columns[column]
+		if !ok || idx >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[idx])
+	}
+
+	for _, record := range records[1:] {
+		labels, err := parseCSVMap(get(record, "labels"))
+		if err != nil {
+			return manifest, fmt.Errorf("invalid labels %q: %w", get(record, "labels"), err)
+		}
+		annotations, err := parseCSVMap(get(record, "annotations"))
+		if err != nil {
+			return manifest, fmt.Errorf("invalid annotations %q: %w", get(record, "annotations"), err)
+		}
+		var frameworks []string
+		for _, framework := range strings.Split(get(record, "frameworks"), ";") {
+			if framework = strings.TrimSpace(framework); framework != "" {
+				frameworks = append(frameworks, framework)
+			}
+		}
+		manifest.Integrations = append(manifest.Integrations, models.IntegrationManifestEntry{
+			IntegrationType: get(record, "integration_type"),
+			ProviderID:      get(record, "provider_id"),
+			Name:            get(record, "name"),
+			CredentialID:    get(record, "credential_id"),
+			Labels:          labels,
+			Annotations:     annotations,
+			Frameworks:      frameworks,
+		})
+	}
+	return manifest, nil
+}
+
+// parseCSVMap parses key=value pairs separated by semicolons
+func parseCSVMap(value string) (map[string]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	result := make(map[string]string)
+	for _, pair := range strings.Split(value, ";") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected key=value, got %q", pair)
+		}
+		result[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return result, nil
+}
+
+func writeManifestCSV(entries []models.IntegrationManifestEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(manifestCSVHeader); err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		record := []string{
+			entry.IntegrationType,
+			entry.ProviderID,
+			entry.Name,
+			entry.CredentialID,
+			formatCSVMap(entry.Labels),
+			formatCSVMap(entry.Annotations),
+			strings.Join(entry.Frameworks, ";"),
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+func formatCSVMap(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+m[k])
+	}
+	return strings.Join(pairs, ";")
+}
@@ -0,0 +1,215 @@
+package integrations
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/opengovern/og-util/pkg/httpclient"
+	"github.com/opengovern/og-util/pkg/integration"
+	"github.com/opengovern/og-util/pkg/integration/interfaces"
+	"github.com/opengovern/opensecurity/services/compliance/api"
+	complianceClient "github.com/opengovern/opensecurity/services/compliance/client"
+	"github.com/opengovern/opensecurity/services/integration/api/models"
+	integration_type "github.com/opengovern/opensecurity/services/integration/integration-type"
+	models2 "github.com/opengovern/opensecurity/services/integration/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIntegrationManifestYAML(t *testing.T) {
+	manifest, err := parseIntegrationManifest([]byte(`
+credentials:
+  - ref: prod-org
+    integration_type: aws_cloud_account
+    credential_type: aws_single_account
+    secret_backend: kubernetes
+    secret_reference: security/aws-prod
+  - ref: github
+    integration_type: github_account
+    credentials:
+      token: ghp_secret
+integrations:
+  - integration_type: aws_cloud_account
+    provider_id: "123456789012"
+    credential_ref: prod-org
+    labels:
+      env: prod
+    frameworks: [cis-aws]
+  - integration_type: github_account
+    provider_id: opengovern
+    credential_id: 0b7a3f4e-5c4d-4a8e-9f1a-2b3c4d5e6f70
+`), manifestFormatYAML)
+	require.NoError(t, err)
+
+	require.Len(t, manifest.Credentials, 2)
+	assert.Equal(t, "kubernetes", manifest.Credentials[0].SecretBackend)
+	assert.Equal(t, "security/aws-prod", manifest.Credentials[0].SecretReference)
+	assert.Equal(t, map[string]any{"token": "ghp_secret"}, manifest.Credentials[1].Credentials)
+
+	require.Len(t, manifest.Integrations, 2)
+	assert.Equal(t, "prod-org", manifest.Integrations[0].CredentialRef)
+	assert.Equal(t, map[string]string{"env": "prod"}, manifest.Integrations[0].Labels)
+	assert.Equal(t, []string{"cis-aws"}, manifest.Integrations[0].Frameworks)
+	assert.Equal(t, "0b7a3f4e-5c4d-4a8e-9f1a-2b3c4d5e6f70", manifest.Integrations[1].CredentialID)
+}
+
+func TestParseIntegrationManifestCSV(t *testing.T) {
+	manifest, err := parseIntegrationManifest([]byte(
+		"integration_type,provider_id,credential_id,labels,frameworks\n"+
+			"aws_cloud_account,123456789012,0b7a3f4e-5c4d-4a8e-9f1a-2b3c4d5e6f70,env=prod;team=sec,cis-aws;soc2\n"), manifestFormatCSV)
+	require.NoError(t, err)
+	assert.Empty(t, manifest.Credentials)
+	require.Len(t, manifest.Integrations, 1)
+	assert.Equal(t, map[string]string{"env": "prod", "team": "sec"}, manifest.Integrations[0].Labels)
+	assert.Equal(t, []string{"cis-aws", "soc2"}, manifest.Integrations[0].Frameworks)
+
+	_, err = parseIntegrationManifest([]byte("integration_type,provider_id\naws_cloud_account,1\n"), manifestFormatCSV)
+	assert.ErrorContains(t, err, "credential_id")
+}
+
+func TestManifestCSVRoundTrip(t *testing.T) {
+	entries := []models.IntegrationManifestEntry{{
+		IntegrationType: "aws_cloud_account",
+		ProviderID:      "123456789012",
+		Name:            "prod",
+		CredentialID:    "0b7a3f4e-5c4d-4a8e-9f1a-2b3c4d5e6f70",
+		Labels:          map[string]string{"team": "sec", "env": "prod"},
+		Frameworks:      []string{"cis-aws", "soc2"},
+	}}
+	data, err := writeManifestCSV(entries)
+	require.NoError(t, err)
+
+	manifest, err := parseIntegrationManifest(data, manifestFormatCSV)
+	require.NoError(t, err)
+	assert.Equal(t, entries, manifest.Integrations)
+}
+
+func TestBulkImportCredentialReferences(t *testing.T) {
+	importer := bulkImporter{
+		seen:                make(map[string]int),
+		discovered:          make(map[string]*discoveredCredential),
+		manifestCredentials: make(map[string]*discoveredCredential),
+		touchedCredentials:  make(map[string]bool),
+	}
+
+	var missingRef, first, duplicate models.BulkImportCredentialResult
+	importer.prepareCredential(models.CredentialManifestEntry{IntegrationType: "aws_cloud_account"}, &missingRef)
+	assert.False(t, missingRef.Valid)
+	assert.Equal(t, []string{"ref is required"}, missingRef.Errors)
+
+	importer.manifestCredentials["prod-org"] = &discoveredCredential{manifest: &first}
+	importer.prepareCredential(models.CredentialManifestEntry{Ref: "prod-org"}, &duplicate)
+	assert.False(t, duplicate.Valid)
+	assert.Equal(t, []string{"duplicate credential ref prod-org"}, duplicate.Errors)
+
+	result := importer.importEntry(1, models.IntegrationManifestEntry{
+		IntegrationType: "aws_cloud_account",
+		ProviderID:      "123456789012",
+		CredentialID:    "0b7a3f4e-5c4d-4a8e-9f1a-2b3c4d5e6f70",
+		CredentialRef:   "prod-org",
+	})
+	assert.False(t, result.Valid)
+	assert.Equal(t, []string{"only one of credential_id or credential_ref should be set"}, result.Errors)
+
+	result = importer.importEntry(2, models.IntegrationManifestEntry{
+		IntegrationType: "aws_cloud_account",
+		ProviderID:      "123456789012",
+		CredentialRef:   "staging-org",
+	})
+	assert.False(t, result.Valid)
+	assert.Equal(t, []string{"credential_ref staging-org is not a manifest credential"}, result.Errors)
+}
+
+type frameworksComplianceClient struct {
+	complianceClient.ComplianceServiceClient
+	frameworks map[string]bool
+	assigned   map[string][]string
+}
+
+func (c *frameworksComplianceClient) GetBenchmark(_ *httpclient.Context, benchmarkID string) (*api.Benchmark, error) {
+	if !c.frameworks[benchmarkID] {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "benchmark not found")
+	}
+	return &api.Benchmark{ID: benchmarkID}, nil
+}
+
+func (c *frameworksComplianceClient) AddFrameworkAssignments(_ *httpclient.Context, frameworkID string, integrationIDs []string) error {
+	c.assigned[frameworkID] = append(c.assigned[frameworkID], integrationIDs...)
+	return nil
+}
+
+func TestApplyManifestFrameworksDryRun(t *testing.T) {
+	client := &frameworksComplianceClient{frameworks: map[string]bool{"cis-aws": true}, assigned: make(map[string][]string)}
+	h, _ := mockAPI(t)
+	h.complianceClient = client
+
+	rows := make([]models.BulkImportRowResult, 2)
+	frameworkIntegrations := map[string][]string{"cis-aws": {"integration-a"}, "cis-typo": {"integration-a", "integration-b"}}
+	frameworkRows := map[string][]int{"cis-aws": {0}, "cis-typo": {0, 1}}
+	h.applyManifestFrameworks(context.Background(), true, frameworkIntegrations, frameworkRows, rows)
+
+	assert.Equal(t, []string{"framework cis-typo not found"}, rows[0].Errors)
+	assert.Equal(t, []string{"framework cis-typo not found"}, rows[1].Errors)
+	assert.Empty(t, client.assigned, "nothing is assigned in a dry run")
+}
+
+// healthyType discovers and passes the health check of every account
+type healthyType struct {
+	interfaces.IntegrationType
+}
+
+func (healthyType) HealthCheck([]byte, string, map[string]string, map[string]string) (bool, error) {
+	return true, nil
+}
+
+func TestBulkImportMovedIntegrationRecountsBothCredentials(t *testing.T) {
+	h, mock := mockAPI(t)
+	h.typesManager = &integration_type.IntegrationTypeManager{
+		IntegrationTypes: map[integration.Type]interfaces.IntegrationType{"aws_cloud_account": healthyType{}},
+	}
+	oldCredentialID, newCredentialID := uuid.New(), uuid.New()
+	current := models2.Integration{Integration: integration.Integration{
+		IntegrationID:   uuid.New(),
+		ProviderID:      "123456789012",
+		IntegrationType: "aws_cloud_account",
+		CredentialID:    oldCredentialID,
+		State:           integration.IntegrationStateActive,
+	}}
+
+	importer := bulkImporter{
+		api:      h,
+		c:        scopedContext(""),
+		ctx:      context.Background(),
+		existing: map[string]models2.Integration{manifestKey("aws_cloud_account", "123456789012"): current},
+		seen:     make(map[string]int),
+		discovered: map[string]*discoveredCredential{newCredentialID.String(): {
+			credential:   &models2.Credential{ID: newCredentialID, IntegrationType: "aws_cloud_account"},
+			integrations: map[string]integration.Integration{"123456789012": current.Integration},
+		}},
+		manifestCredentials: make(map[string]*discoveredCredential),
+		touchedCredentials:  make(map[string]bool),
+	}
+
+	mock.ExpectQuery(`SELECT \* FROM "integration_plugins" WHERE plugin_id = \$1`).
+		WithArgs("aws_cloud_account").
+		WillReturnRows(sqlmock.NewRows([]string{"plugin_id", "install_state", "operational_status"}).
+			AddRow("aws_cloud_account", models2.IntegrationTypeInstallStateInstalled, models2.IntegrationPluginOperationalStatusEnabled))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "integrations" SET .*"credential_id"=\$\d+`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	result := importer.importEntry(1, models.IntegrationManifestEntry{
+		IntegrationType: "aws_cloud_account",
+		ProviderID:      "123456789012",
+		CredentialID:    newCredentialID.String(),
+	})
+	require.True(t, result.Valid, result.Errors)
+	assert.Equal(t, models.BulkImportActionUpdate, result.Action)
+	assert.Equal(t, map[string]bool{oldCredentialID.String(): true, newCredentialID.String(): true}, importer.touchedCredentials)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
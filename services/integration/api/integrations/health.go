@@ -0,0 +1,344 @@
+package integrations
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/opengovern/og-util/pkg/integration"
+	"github.com/opengovern/og-util/pkg/ticker"
+	"github.com/opengovern/opensecurity/pkg/utils"
+	"github.com/opengovern/opensecurity/services/integration/api/models"
+	"github.com/opengovern/opensecurity/services/integration/config"
+	"github.com/opengovern/opensecurity/services/integration/db"
+	models2 "github.com/opengovern/opensecurity/services/integration/models"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultHealthCheckIntervalMinutes      = 60
+	defaultHealthCheckFailureThreshold     = 3
+	defaultHealthCheckFlappingThreshold    = 4
+	defaultHealthCheckFlappingWindowHours  = 24
+	defaultHealthCheckHistoryRetentionDays = 30
+
+	healthReasonAnnotation = "platform/integration/health-reason"
+)
+
+func fillHealthCheckConfigDefaults(cfg config.HealthCheckConfig) config.HealthCheckConfig {
+	if cfg.IntervalMinutes <= 0 {
+		cfg.IntervalMinutes = defaultHealthCheckIntervalMinutes
+	}
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaultHealthCheckFailureThreshold
+	}
+	if cfg.FlappingThreshold <= 0 {
+		cfg.FlappingThreshold = defaultHealthCheckFlappingThreshold
+	}
+	if cfg.FlappingWindowHours <= 0 {
+		cfg.FlappingWindowHours = defaultHealthCheckFlappingWindowHours
+	}
+	if cfg.HistoryRetentionDays <= 0 {
+		cfg.HistoryRetentionDays = defaultHealthCheckHistoryRetentionDays
+	}
+	return cfg
+}
+
+// healthCheckInterval returns the health check cadence of an integration type
+func (h *API) healthCheckInterval(integrationType integration.Type) time.Duration {
+	if minutes, ok := h.healthCheckConfig.IntegrationTypeIntervalMinutes[integrationType.String()]; ok && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return time.Duration(h.healthCheckConfig.IntervalMinutes) * time.Minute
+}
+
+// checkIntegrationHealth runs the integration type health check, stores the result in the health history and updates
+// the integration state. The integration is only marked inactive after FailureThreshold consecutive failures.
+func (h *API) checkIntegrationHealth(ctx context.Context, integ *models2.Integration, trigger models2.IntegrationHealthCheckTrigger) (*models2.IntegrationHealthCheck, error) {
+	start := time.Now()
+	healthy, reason := h.runIntegrationHealthCheck(ctx, integ)
+	check := models2.IntegrationHealthCheck{
+		IntegrationID:   integ.IntegrationID,
+		IntegrationType: integ.IntegrationType.String(),
+		CheckedAt:       start,
+		LatencyMs:       time.Since(start).Milliseconds(),
+		Healthy:         healthy,
+		Reason:          reason,
+		Trigger:         trigger,
+	}
+	if err := h.database.CreateIntegrationHealthCheck(&check); err != nil {
+		return nil, fmt.Errorf("failed to store health check: %w", err)
+	}
+
+	state := integ.State
+	if healthy {
+		if state != integration.IntegrationStateArchived {
+			state = integration.IntegrationStateActive
+		}
+	} else if state != integration.IntegrationStateArchived {
+		recentChecks, err := h.database.ListIntegrationHealthChecks(integ.IntegrationID, nil, nil, h.healthCheckConfig.FailureThreshold)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list health checks: %w", err)
+		}
+		if consecutiveFailures(recentChecks) >= h.healthCheckConfig.FailureThreshold {
+			state = integration.IntegrationStateInactive
+		}
+	}
+	// Only the health columns are written, and only if no one changed the state (e.g. archived it) during the check
+	updated, err := h.database.UpdateIntegrationHealth(integ.IntegrationID, integ.State, state, check.CheckedAt, healthReasonAnnotation, reason)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update integration: %w", err)
+	}
+	if !updated {
+		h.logger.Info("integration state changed during the health check, keeping it", zap.String("integrationId", integ.IntegrationID.String()))
+		return &check, nil
+	}
+
+	integ.State = state
+	integ.LastCheck = &check.CheckedAt
+	if healthy {
+		err = integ.RemoveAnnotation(healthReasonAnnotation)
+	} else {
+		_, err = integ.AddAnnotations(healthReasonAnnotation, reason)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update annotations: %w", err)
+	}
+
+	return &check, nil
+}
+
+// runIntegrationHealthCheck returns whether the integration is healthy and the reason if it is not
+func (h *API) runIntegrationHealthCheck(ctx context.Context, integ *models2.Integration) (bool, string) {
+	credential, err := h.database.GetCredential(integ.CredentialID.String())
+	if err != nil || credential == nil {
+		return false, "credential not found"
+	}
+
+	mapData, err := h.secrets.Resolve(ctx, credential, models2.CredentialSecretPurposeHealthCheck)
+	if err != nil {
+		h.logger.Error("failed to resolve secret", zap.Error(err))
+		return false, "failed to resolve credential secret"
+	}
+	jsonData, err := json.Marshal(mapData)
+	if err != nil {
+		return false, "failed to marshal credential"
+	}
+
+	integrationType, ok := h.typesManager.GetIntegrationTypeMap()[integ.IntegrationType]
+	if !ok || integrationType == nil {
+		return false, fmt.Sprintf("integration type %s is not loaded", integ.IntegrationType)
+	}
+	integrationApi, err := integ.ToApi()
+	if err != nil {
+		return false, "failed to read integration"
+	}
+
+	healthy, err := integrationType.HealthCheck(jsonData, integrationApi.ProviderID, integrationApi.Labels, integrationApi.Annotations)
+	if err != nil {
+		return false, err.Error()
+	}
+	if !healthy {
+		return false, "health check failed"
+	}
+	return true, ""
+}
+
+// IntegrationHealthCheckScheduler runs the health checks of the integrations which are due based on
+// their integration type cadence and removes expired health history. A single replica runs them.
+func (h *API) IntegrationHealthCheckScheduler(ctx context.Context) {
+	t := ticker.NewTicker(time.Minute, time.Second*10)
+	defer t.Stop()
+	lock := h.database.NewRunnerLock(db.HealthCheckRunnerLockID)
+	defer lock.Release()
+	for {
+		if leader, err := lock.TryAcquire(ctx); err != nil {
+			h.logger.Warn("failed to acquire health check runner lock", zap.Error(err))
+		} else if leader {
+			h.runScheduledHealthChecks(ctx)
+		}
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (h *API) runScheduledHealthChecks(ctx context.Context) {
+	integrations, err := h.database.ListIntegration(nil)
+	if err != nil {
+		h.logger.Warn("failed to list integrations", zap.Error(err))
+		return
+	}
+
+	now := time.Now()
+	for _, integ := range integrations {
+		integ := integ
+		if integ.State == integration.IntegrationStateArchived || integ.State == integration.IntegrationStateSample {
+			continue
+		}
+		if integ.LastCheck != nil && now.Sub(*integ.LastCheck) < h.healthCheckInterval(integ.IntegrationType) {
+			continue
+		}
+		check, err := h.checkIntegrationHealth(ctx, &integ, models2.IntegrationHealthCheckTriggerScheduled)
+		if err != nil {
+			h.logger.Warn("failed to check integration health", zap.String("integrationId", integ.IntegrationID.String()), zap.Error(err))
+			continue
+		}
+		if !check.Healthy {
+			h.logger.Info("integration health check failed", zap.String("integrationId", integ.IntegrationID.String()),
+				zap.String("reason", check.Reason), zap.String("state", string(integ.State)))
+		}
+	}
+
+	retention := time.Duration(h.healthCheckConfig.HistoryRetentionDays) * 24 * time.Hour
+	if err = h.database.DeleteIntegrationHealthChecksBefore(now.Add(-retention)); err != nil {
+		h.logger.Warn("failed to remove expired health checks", zap.Error(err))
+	}
+}
+
+// GetIntegrationHealth godoc
+//
+//	@Summary		Get integration health timeline
+//	@Description	Get the health check history of an integration with an aggregate summary and flapping indicator
+//	@Security		BearerToken
+//	@Tags			integrations
+//	@Produce		json
+//	@Param			IntegrationID	path		string	true	"integration id"
+//	@Param			start_time		query		int		false	"checks after, unix seconds. default: flapping window"
+//	@Param			end_time		query		int		false	"checks before, unix seconds"
+//	@Param			limit			query		int		false	"maximum number of checks"
+//	@Success		200				{object}	models.IntegrationHealthTimelineResponse
+//	@Router			/integration/api/v1/integrations/{IntegrationID}/health [get]
+func (h *API) GetIntegrationHealth(c echo.Context) error {
+	integrationID, err := uuid.Parse(c.Param("IntegrationID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+	if !inScope(c, integrationID.String()) {
+		return echo.NewHTTPError(http.StatusNotFound, "integration not found")
+	}
+
+	integ, err := h.database.GetIntegration(integrationID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "integration not found")
+		}
+		h.logger.Error("failed to get integration", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get integration")
+	}
+
+	now := time.Now()
+	flappingWindowStart := now.Add(-time.Duration(h.healthCheckConfig.FlappingWindowHours) * time.Hour)
+	from, to := &flappingWindowStart, (*time.Time)(nil)
+	if startTimeStr := c.QueryParam("start_time"); startTimeStr != "" {
+		startTime, err := strconv.ParseInt(startTimeStr, 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid start_time")
+		}
+		from = utils.GetPointer(time.Unix(startTime, 0))
+	}
+	if endTimeStr := c.QueryParam("end_time"); endTimeStr != "" {
+		endTime, err := strconv.ParseInt(endTimeStr, 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid end_time")
+		}
+		to = utils.GetPointer(time.Unix(endTime, 0))
+	}
+	limit := 0
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid limit")
+		}
+	}
+
+	checks, err := h.database.ListIntegrationHealthChecks(integrationID, from, to, limit)
+	if err != nil {
+		h.logger.Error("failed to list health checks", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list health checks")
+	}
+	flappingChecks, err := h.database.ListIntegrationHealthChecks(integrationID, &flappingWindowStart, nil, 0)
+	if err != nil {
+		h.logger.Error("failed to list health checks", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list health checks")
+	}
+
+	summary := summarizeHealthChecks(checks)
+	summary.Flapping = healthTransitions(flappingChecks) >= h.healthCheckConfig.FlappingThreshold
+
+	items := make([]models.IntegrationHealthCheck, 0, len(checks))
+	for _, check := range checks {
+		items = append(items, models.IntegrationHealthCheck{
+			CheckedAt: check.CheckedAt,
+			LatencyMs: check.LatencyMs,
+			Healthy:   check.Healthy,
+			Reason:    check.Reason,
+			Trigger:   string(check.Trigger),
+		})
+	}
+
+	return c.JSON(http.StatusOK, models.IntegrationHealthTimelineResponse{
+		IntegrationID: integ.IntegrationID.String(),
+		State:         models.IntegrationState(integ.State),
+		LastCheck:     integ.LastCheck,
+		Summary:       summary,
+		Checks:        items,
+	})
+}
+
+// summarizeHealthChecks aggregates health checks ordered newest first
+func summarizeHealthChecks(checks []models2.IntegrationHealthCheck) models.IntegrationHealthSummary {
+	summary := models.IntegrationHealthSummary{
+		TotalChecks:         len(checks),
+		ConsecutiveFailures: consecutiveFailures(checks),
+		Transitions:         healthTransitions(checks),
+	}
+	if len(checks) == 0 {
+		return summary
+	}
+
+	var totalLatency int64
+	for _, check := range checks {
+		if !check.Healthy {
+			summary.FailedChecks++
+		}
+		totalLatency += check.LatencyMs
+	}
+	summary.AverageLatencyMs = totalLatency / int64(len(checks))
+	summary.UptimePercent = float64(len(checks)-summary.FailedChecks) * 100 / float64(len(checks))
+
+	return summary
+}
+
+// consecutiveFailures counts the failed checks since the last healthy one, checks are ordered newest first
+func consecutiveFailures(checks []models2.IntegrationHealthCheck) int {
+	count := 0
+	for _, check := range checks {
+		if check.Healthy {
+			break
+		}
+		count++
+	}
+	return count
+}
+
+// healthTransitions counts how many times the checks switched between healthy and unhealthy
+func healthTransitions(checks []models2.IntegrationHealthCheck) int {
+	transitions := 0
+	for i := 1; i < len(checks); i++ {
+		if checks[i].Healthy != checks[i-1].Healthy {
+			transitions++
+		}
+	}
+	return transitions
+}
@@ -0,0 +1,146 @@
+package integrations
+
+import (
+	"testing"
+	"time"
+
+	"github.com/opengovern/opensecurity/services/integration/api/models"
+	models2 "github.com/opengovern/opensecurity/services/integration/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// healthChecks builds checks ordered newest first from a pattern, H for a healthy and F for a failed check,
+// the latencies are the given ones or 100ms
+func healthChecks(pattern string, latencies ...int64) []models2.IntegrationHealthCheck {
+	now := time.Now()
+	checks := make([]models2.IntegrationHealthCheck, 0, len(pattern))
+	for idx, r := range pattern {
+		latency := int64(100)
+		if idx < len(latencies) {
+			latency = latencies[idx]
+		}
+		checks = append(checks, models2.IntegrationHealthCheck{
+			CheckedAt: now.Add(-time.Duration(idx) * time.Hour),
+			LatencyMs: latency,
+			Healthy:   r == 'H',
+		})
+	}
+	return checks
+}
+
+func TestConsecutiveFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		checks    string
+		threshold int
+		failures  int
+		inactive  bool
+	}{
+		{name: "no checks", checks: "", threshold: 3, failures: 0},
+		{name: "healthy", checks: "HHH", threshold: 3, failures: 0},
+		{name: "below threshold", checks: "FFHFFF", threshold: 3, failures: 2},
+		{name: "at threshold", checks: "FFFH", threshold: 3, failures: 3, inactive: true},
+		{name: "above threshold", checks: "FFFFF", threshold: 3, failures: 5, inactive: true},
+		{name: "recovered", checks: "HFFFF", threshold: 3, failures: 0},
+		{name: "single failure threshold", checks: "FH", threshold: 1, failures: 1, inactive: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failures := consecutiveFailures(healthChecks(tt.checks))
+			assert.Equal(t, tt.failures, failures)
+			assert.Equal(t, tt.inactive, failures >= tt.threshold)
+		})
+	}
+}
+
+func TestHealthTransitions(t *testing.T) {
+	tests := []struct {
+		name        string
+		checks      string
+		transitions int
+	}{
+		{name: "no checks", checks: "", transitions: 0},
+		{name: "single check", checks: "F", transitions: 0},
+		{name: "stable", checks: "HHHH", transitions: 0},
+		{name: "single outage", checks: "HFFH", transitions: 2},
+		{name: "flapping", checks: "HFHFH", transitions: 4},
+		{name: "still failing", checks: "FFHH", transitions: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.transitions, healthTransitions(healthChecks(tt.checks)))
+		})
+	}
+}
+
+func TestSummarizeHealthChecks(t *testing.T) {
+	tests := []struct {
+		name      string
+		checks    []models2.IntegrationHealthCheck
+		summary   models.IntegrationHealthSummary
+		threshold int
+		flapping  bool
+	}{
+		{
+			name:      "empty window",
+			checks:    healthChecks(""),
+			summary:   models.IntegrationHealthSummary{},
+			threshold: 4,
+		},
+		{
+			name:   "healthy window",
+			checks: healthChecks("HHHH", 100, 200, 300, 400),
+			summary: models.IntegrationHealthSummary{
+				TotalChecks:      4,
+				AverageLatencyMs: 250,
+				UptimePercent:    100,
+			},
+			threshold: 4,
+		},
+		{
+			name:   "failing window",
+			checks: healthChecks("FFFH", 1000, 1000, 1000, 200),
+			summary: models.IntegrationHealthSummary{
+				TotalChecks:         4,
+				FailedChecks:        3,
+				ConsecutiveFailures: 3,
+				Transitions:         1,
+				AverageLatencyMs:    800,
+				UptimePercent:       25,
+			},
+			threshold: 4,
+		},
+		{
+			name:   "flapping window",
+			checks: healthChecks("HFHFH"),
+			summary: models.IntegrationHealthSummary{
+				TotalChecks:      5,
+				FailedChecks:     2,
+				Transitions:      4,
+				AverageLatencyMs: 100,
+				UptimePercent:    60,
+			},
+			threshold: 4,
+			flapping:  true,
+		},
+		{
+			name:   "window limited to the latest checks",
+			checks: healthChecks("HFHFH")[:2],
+			summary: models.IntegrationHealthSummary{
+				TotalChecks:      2,
+				FailedChecks:     1,
+				Transitions:      1,
+				AverageLatencyMs: 100,
+				UptimePercent:    50,
+			},
+			threshold: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := summarizeHealthChecks(tt.checks)
+			assert.Equal(t, tt.summary, summary)
+			assert.Equal(t, tt.flapping, healthTransitions(tt.checks) >= tt.threshold)
+		})
+	}
+}
@@ -0,0 +1,379 @@
+package integrations
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/opengovern/og-util/pkg/httpserver"
+	"github.com/opengovern/og-util/pkg/ticker"
+	"github.com/opengovern/opensecurity/services/integration/api/models"
+	"github.com/opengovern/opensecurity/services/integration/db"
+	"github.com/opengovern/opensecurity/services/integration/entities"
+	models2 "github.com/opengovern/opensecurity/services/integration/models"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+)
+
+const integrationGroupMembershipSyncInterval = 15 * time.Minute
+
+// CreateIntegrationGroup godoc
+//
+//	@Summary		Create integration group
+//	@Description	Create an integration group. The query runs in CloudQL and should return a platform_integration_id column.
+//	@Security		BearerToken
+//	@Tags			integrations
+//	@Produce		json
+//	@Param			request	body		models.CreateIntegrationGroupRequest	true	"Request"
+//	@Success		201		{object}	models.IntegrationGroup
+//	@Router			/integration/api/v1/integrations/integration-groups [post]
+func (h *API) CreateIntegrationGroup(c echo.Context) error {
+	var req models.CreateIntegrationGroupRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || strings.TrimSpace(req.Query) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "name and query are required")
+	}
+
+	existing, err := h.database.GetIntegrationGroup(req.Name)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		h.logger.Error("failed to get integration group", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get integration group")
+	}
+	if existing != nil {
+		return echo.NewHTTPError(http.StatusConflict, fmt.Sprintf("integration group %s already exists", req.Name))
+	}
+
+	integrationIds, err := h.evaluateIntegrationGroupQuery(c.Request().Context(), req.Query)
+	if err != nil {
+		return err
+	}
+
+	integrationGroup := models2.IntegrationGroup{
+		Name:        req.Name,
+		Query:       req.Query,
+		Description: req.Description,
+		Source:      models2.IntegrationGroupSourceUser,
+		CreatedBy:   httpserver.GetUserID(c),
+	}
+	if err = h.database.CreateIntegrationGroup(&integrationGroup); err != nil {
+		h.logger.Error("failed to create integration group", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create integration group")
+	}
+	if _, err = h.database.SyncIntegrationGroupMembers(integrationGroup.Name, integrationIds); err != nil {
+		h.logger.Error("failed to sync integration group members", zap.String("group", integrationGroup.Name), zap.Error(err))
+		// the group is only kept with its members
+		if err = h.database.DeleteIntegrationGroup(integrationGroup.Name); err != nil {
+			h.logger.Error("failed to delete integration group", zap.String("group", integrationGroup.Name), zap.Error(err))
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to sync integration group members")
+	}
+
+	integrationGroupApi, err := entities.NewIntegrationGroup(c.Request().Context(), nil, integrationGroup)
+	if err != nil {
+		h.logger.Error("failed to convert integration group to API model", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to convert integration group to API model")
+	}
+	integrationGroupApi.IntegrationIds = integrationIds
+
+	return c.JSON(http.StatusCreated, integrationGroupApi)
+}
+
+// UpdateIntegrationGroup godoc
+//
+//	@Summary		Update integration group
+//	@Description	Update the query or description of a user defined integration group
+//	@Security		BearerToken
+//	@Tags			integrations
+//	@Produce		json
+//	@Param			integrationGroupName	path		string									true	"integrationGroupName"
+//	@Param			request					body		models.UpdateIntegrationGroupRequest	true	"Request"
+//	@Success		200						{object}	models.IntegrationGroup
+//	@Router			/integration/api/v1/integrations/integration-groups/{integrationGroupName} [put]
+func (h *API) UpdateIntegrationGroup(c echo.Context) error {
+	integrationGroupName := c.Param("integrationGroupName")
+
+	var req models.UpdateIntegrationGroupRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+
+	integrationGroup, err := h.getUserIntegrationGroup(integrationGroupName)
+	if err != nil {
+		return err
+	}
+
+	if req.Description != nil {
+		integrationGroup.Description = *req.Description
+	}
+	var integrationIds []string
+	if req.Query != nil {
+		if strings.TrimSpace(*req.Query) == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "query can not be empty")
+		}
+		integrationGroup.Query = *req.Query
+	}
+	integrationIds, err = h.evaluateIntegrationGroupQuery(c.Request().Context(), integrationGroup.Query)
+	if err != nil {
+		return err
+	}
+
+	if err = h.database.UpdateIntegrationGroup(integrationGroup.Name, integrationGroup.Query, integrationGroup.Description); err != nil {
+		h.logger.Error("failed to update integration group", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update integration group")
+	}
+	if _, err = h.database.SyncIntegrationGroupMembers(integrationGroup.Name, integrationIds); err != nil {
+		h.logger.Error("failed to sync integration group members", zap.String("group", integrationGroup.Name), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to sync integration group members")
+	}
+
+	integrationGroupApi, err := entities.NewIntegrationGroup(c.Request().Context(), nil, *integrationGroup)
+	if err != nil {
+		h.logger.Error("failed to convert integration group to API model", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to convert integration group to API model")
+	}
+	integrationGroupApi.IntegrationIds = integrationIds
+
+	return c.JSON(http.StatusOK, integrationGroupApi)
+}
+
+// DeleteIntegrationGroup godoc
+//
+//	@Summary		Delete integration group
+//	@Description	Delete a user defined integration group
+//	@Security		BearerToken
+//	@Tags			integrations
+//	@Produce		json
+//	@Param			integrationGroupName	path	string	true	"integrationGroupName"
+//	@Success		200
+//	@Router			/integration/api/v1/integrations/integration-groups/{integrationGroupName} [delete]
+func (h *API) DeleteIntegrationGroup(c echo.Context) error {
+	integrationGroupName := c.Param("integrationGroupName")
+
+	integrationGroup, err := h.getUserIntegrationGroup(integrationGroupName)
+	if err != nil {
+		return err
+	}
+
+	if err = h.database.DeleteIntegrationGroup(integrationGroup.Name); err != nil {
+		h.logger.Error("failed to delete integration group", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete integration group")
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// PreviewIntegrationGroup godoc
+//
+//	@Summary		Preview integration group members
+//	@Description	Run an integration group query without saving it and return the integrations it selects
+//	@Security		BearerToken
+//	@Tags			integrations
+//	@Produce		json
+//	@Param			request	body		models.PreviewIntegrationGroupRequest	true	"Request"
+//	@Success		200		{object}	models.IntegrationGroup
+//	@Router			/integration/api/v1/integrations/integration-groups/preview [post]
+func (h *API) PreviewIntegrationGroup(c echo.Context) error {
+	var req models.PreviewIntegrationGroupRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "query is required")
+	}
+
+	integrationIds, err := h.evaluateIntegrationGroupQuery(c.Request().Context(), req.Query)
+	if err != nil {
+		return err
+	}
+
+	scopedIntegrationIds := make([]string, 0, len(integrationIds))
+	for _, integrationId := range integrationIds {
+		if inScope(c, integrationId) {
+			scopedIntegrationIds = append(scopedIntegrationIds, integrationId)
+		}
+	}
+	integrationIds = scopedIntegrationIds
+
+	preview := models.IntegrationGroup{
+		Query:          req.Query,
+		IntegrationIds: integrationIds,
+		Integrations:   []models.Integration{},
+	}
+	if len(integrationIds) > 0 {
+		integrations, err := h.database.ListIntegrationsByFilters(integrationIds, nil, nil, nil, nil)
+		if err != nil {
+			h.logger.Error("failed to list integrations", zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to list integrations")
+		}
+		for _, integration := range integrations {
+			apiIntegration, err := integration.ToApi()
+			if err != nil {
+				h.logger.Error("failed to convert integration to API model", zap.Error(err))
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to convert integration to API model")
+			}
+			preview.Integrations = append(preview.Integrations, *apiIntegration)
+		}
+	}
+
+	return c.JSON(http.StatusOK, preview)
+}
+
+// ListIntegrationGroupHistory godoc
+//
+//	@Summary		List integration group membership history
+//	@Description	List when integrations entered or left an integration group, newest first
+//	@Security		BearerToken
+//	@Tags			integrations
+//	@Produce		json
+//	@Param			integrationGroupName	path		string	true	"integrationGroupName"
+//	@Param			integration_id			query		string	false	"only the history of this integration"
+//	@Param			cursor					query		int		false	"cursor"
+//	@Param			per_page				query		int		false	"per page"
+//	@Success		200						{object}	models.ListIntegrationGroupMembershipEventsResponse
+//	@Router			/integration/api/v1/integrations/integration-groups/{integrationGroupName}/history [get]
+func (h *API) ListIntegrationGroupHistory(c echo.Context) error {
+	integrationGroupName := c.Param("integrationGroupName")
+
+	var cursor, perPage int64
+	var err error
+	if cursorStr := c.QueryParam("cursor"); cursorStr != "" {
+		cursor, err = strconv.ParseInt(cursorStr, 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid cursor")
+		}
+	}
+	if perPageStr := c.QueryParam("per_page"); perPageStr != "" {
+		perPage, err = strconv.ParseInt(perPageStr, 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid per_page")
+		}
+	}
+	if cursor < 1 {
+		cursor = 1
+	}
+
+	var integrationIDs []string
+	if integrationID := c.QueryParam("integration_id"); integrationID != "" {
+		integrationIDs = []string{integrationID}
+	}
+	// scoped callers only see the history of the integrations in their scope
+	integrationIDs, err = httpserver.ResolveConnectionIDs(c, integrationIDs)
+	if err != nil {
+		return err
+	}
+
+	events, totalCount, err := h.database.ListIntegrationGroupMembershipEvents(integrationGroupName, integrationIDs,
+		int(perPage), int((cursor-1)*perPage))
+	if err != nil {
+		h.logger.Error("failed to list integration group history", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list integration group history")
+	}
+
+	items := make([]models.IntegrationGroupMembershipEvent, 0, len(events))
+	for _, event := range events {
+		items = append(items, models.IntegrationGroupMembershipEvent{
+			GroupName:     event.GroupName,
+			IntegrationID: event.IntegrationID,
+			Action:        string(event.Action),
+			CreatedAt:     event.CreatedAt,
+		})
+	}
+
+	return c.JSON(http.StatusOK, models.ListIntegrationGroupMembershipEventsResponse{
+		Events:     items,
+		TotalCount: totalCount,
+	})
+}
+
+// IntegrationGroupMembershipScheduler periodically evaluates all integration groups and records membership changes.
+// A single replica evaluates them.
+func (h *API) IntegrationGroupMembershipScheduler(ctx context.Context) {
+	t := ticker.NewTicker(integrationGroupMembershipSyncInterval, time.Second*10)
+	defer t.Stop()
+	lock := h.database.NewRunnerLock(db.IntegrationGroupMembershipRunnerLockID)
+	defer lock.Release()
+	for {
+		if leader, err := lock.TryAcquire(ctx); err != nil {
+			h.logger.Warn("failed to acquire integration group membership runner lock", zap.Error(err))
+		} else if leader {
+			h.syncIntegrationGroupsMembership(ctx)
+		}
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (h *API) syncIntegrationGroupsMembership(ctx context.Context) {
+	integrationGroups, err := h.database.ListIntegrationGroups()
+	if err != nil {
+		h.logger.Warn("failed to list integration groups", zap.Error(err))
+		return
+	}
+	steampipeConn, err := h.getSteampipeConn()
+	if err != nil {
+		h.logger.Warn("failed to get steampipe connection", zap.Error(err))
+		return
+	}
+
+	for _, integrationGroup := range integrationGroups {
+		if integrationGroup.Query == "" {
+			continue
+		}
+		integrationIds, err := entities.QueryIntegrationGroupMembers(ctx, steampipeConn, integrationGroup.Query)
+		if err != nil {
+			h.logger.Warn("failed to evaluate integration group", zap.String("group", integrationGroup.Name), zap.Error(err))
+			continue
+		}
+		events, err := h.database.SyncIntegrationGroupMembers(integrationGroup.Name, integrationIds)
+		if err != nil {
+			h.logger.Warn("failed to sync integration group members", zap.String("group", integrationGroup.Name), zap.Error(err))
+			continue
+		}
+		if len(events) > 0 {
+			h.logger.Info("integration group membership changed", zap.String("group", integrationGroup.Name), zap.Int("changes", len(events)))
+		}
+	}
+}
+
+// evaluateIntegrationGroupQuery validates an integration group query by running it in CloudQL
+func (h *API) evaluateIntegrationGroupQuery(ctx context.Context, query string) ([]string, error) {
+	steampipeConn, err := h.getSteampipeConn()
+	if err != nil {
+		h.logger.Error("failed to get steampipe connection", zap.Error(err))
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to get steampipe connection")
+	}
+
+	integrationIds, err := entities.QueryIntegrationGroupMembers(ctx, steampipeConn, query)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid integration group query: %v", err))
+	}
+	return integrationIds, nil
+}
+
+// getUserIntegrationGroup returns an integration group which can be changed through the API
+func (h *API) getUserIntegrationGroup(name string) (*models2.IntegrationGroup, error) {
+	integrationGroup, err := h.database.GetIntegrationGroup(name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "integration group not found")
+		}
+		h.logger.Error("failed to get integration group", zap.Error(err))
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to get integration group")
+	}
+	if integrationGroup.Source != models2.IntegrationGroupSourceUser {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "integration group is managed by platform content and can not be changed")
+	}
+	return integrationGroup, nil
+}
@@ -0,0 +1,776 @@
+package integrations
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/opengovern/og-util/pkg/api"
+	"github.com/opengovern/og-util/pkg/httpclient"
+	"github.com/opengovern/og-util/pkg/httpserver"
+	"github.com/opengovern/og-util/pkg/integration"
+	"github.com/opengovern/og-util/pkg/ticker"
+	"github.com/opengovern/opensecurity/services/integration/api/models"
+	"github.com/opengovern/opensecurity/services/integration/config"
+	"github.com/opengovern/opensecurity/services/integration/db"
+	models2 "github.com/opengovern/opensecurity/services/integration/models"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+const (
+	defaultOrgSyncIntervalMinutes     = 24 * 60
+	defaultOrgSyncReportRetentionDays = 90
+	defaultOrgSyncReportsLimit        = 20
+
+	// orgSyncArchivedAnnotation marks integrations archived by the org sync, only those are restored when they are discovered again
+	orgSyncArchivedAnnotation = "platform/integration/org-sync-archived-at"
+)
+
+var errOrgSyncRunning = errors.New("org sync is already running for the credential")
+
+func fillOrgSyncConfigDefaults(cfg config.OrgSyncConfig) config.OrgSyncConfig {
+	if cfg.IntervalMinutes <= 0 {
+		cfg.IntervalMinutes = defaultOrgSyncIntervalMinutes
+	}
+	if cfg.ReportRetentionDays <= 0 {
+		cfg.ReportRetentionDays = defaultOrgSyncReportRetentionDays
+	}
+	return cfg
+}
+
+func (h *API) orgSyncInterval(orgSync models2.CredentialOrgSync) time.Duration {
+	if orgSync.IntervalMinutes > 0 {
+		return time.Duration(orgSync.IntervalMinutes) * time.Minute
+	}
+	return time.Duration(h.orgSyncConfig.IntervalMinutes) * time.Minute
+}
+
+// OrgSyncScheduler runs the org sync of the credentials which are due and removes expired sync reports.
+// A single replica runs them.
+func (h *API) OrgSyncScheduler(ctx context.Context) {
+	t := ticker.NewTicker(time.Minute, time.Second*10)
+	defer t.Stop()
+	lock := h.database.NewRunnerLock(db.OrgSyncRunnerLockID)
+	defer lock.Release()
+	for {
+		if leader, err := lock.TryAcquire(ctx); err != nil {
+			h.logger.Warn("failed to acquire org sync runner lock", zap.Error(err))
+		} else if leader {
+			h.runScheduledOrgSyncs(ctx)
+		}
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (h *API) runScheduledOrgSyncs(ctx context.Context) {
+	orgSyncs, err := h.database.ListCredentialOrgSyncs(true)
+	if err != nil {
+		h.logger.Warn("failed to list org syncs", zap.Error(err))
+		return
+	}
+
+	now := time.Now()
+	for _, orgSync := range orgSyncs {
+		if orgSync.LastSyncAt != nil && now.Sub(*orgSync.LastSyncAt) < h.orgSyncInterval(orgSync) {
+			continue
+		}
+		report, err := h.syncCredentialOrg(ctx, orgSync, models2.CredentialOrgSyncTriggerScheduled)
+		if err != nil {
+			h.logger.Warn("failed to sync credential org", zap.String("credentialId", orgSync.CredentialID.String()), zap.Error(err))
+			continue
+		}
+		if report.Status == models2.CredentialOrgSyncStatusFailed {
+			h.logger.Info("credential org sync failed", zap.String("credentialId", orgSync.CredentialID.String()), zap.String("error", report.Error))
+		}
+	}
+
+	retention := time.Duration(h.orgSyncConfig.ReportRetentionDays) * 24 * time.Hour
+	if err = h.database.DeleteCredentialOrgSyncReportsBefore(now.Add(-retention)); err != nil {
+		h.logger.Warn("failed to remove expired org sync reports", zap.Error(err))
+	}
+}
+
+// syncCredentialOrg re-runs the discovery of the credential, creates the new accounts, restores the accounts it archived
+// before and archives the accounts which are no longer discovered. The report is stored even if the sync failed.
+func (h *API) syncCredentialOrg(ctx context.Context, orgSync models2.CredentialOrgSync, trigger models2.CredentialOrgSyncTrigger) (*models2.CredentialOrgSyncReport, error) {
+	h.orgSyncLock.Lock()
+	if h.orgSyncRunning[orgSync.CredentialID] {
+		h.orgSyncLock.Unlock()
+		return nil, errOrgSyncRunning
+	}
+	h.orgSyncRunning[orgSync.CredentialID] = true
+	h.orgSyncLock.Unlock()
+	defer func() {
+		h.orgSyncLock.Lock()
+		delete(h.orgSyncRunning, orgSync.CredentialID)
+		h.orgSyncLock.Unlock()
+	}()
+
+	report := models2.CredentialOrgSyncReport{
+		CredentialID: orgSync.CredentialID,
+		Trigger:      trigger,
+		StartedAt:    time.Now(),
+	}
+	var added, restored, archived, failed []models2.CredentialOrgSyncReportIntegration
+
+	syncErr := h.runCredentialOrgSync(ctx, orgSync, &report, &added, &restored, &archived, &failed)
+	report.FinishedAt = time.Now()
+	report.Status = models2.CredentialOrgSyncStatusSucceeded
+	if syncErr != nil {
+		report.Status = models2.CredentialOrgSyncStatusFailed
+		report.Error = syncErr.Error()
+	}
+	for _, field := range []struct {
+		value   *pgtype.JSONB
+		entries []models2.CredentialOrgSyncReportIntegration
+	}{{&report.Added, added}, {&report.Restored, restored}, {&report.Archived, archived}, {&report.Failed, failed}} {
+		if field.entries == nil {
+			field.entries = []models2.CredentialOrgSyncReportIntegration{}
+		}
+		entriesJson, err := json.Marshal(field.entries)
+		if err != nil {
+			return nil, err
+		}
+		if err = field.value.Set(entriesJson); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := h.database.CreateCredentialOrgSyncReport(&report); err != nil {
+		return nil, fmt.Errorf("failed to store org sync report: %w", err)
+	}
+	if err := h.database.UpdateCredentialOrgSyncLastSync(orgSync.CredentialID, report.StartedAt); err != nil {
+		return nil, fmt.Errorf("failed to update org sync: %w", err)
+	}
+
+	return &report, nil
+}
+
+func (h *API) runCredentialOrgSync(ctx context.Context, orgSync models2.CredentialOrgSync, report *models2.CredentialOrgSyncReport,
+	added, restored, archived, failed *[]models2.CredentialOrgSyncReportIntegration) error {
+	credential, err := h.database.GetCredential(orgSync.CredentialID.String())
+	if err != nil || credential == nil {
+		return fmt.Errorf("credential not found")
+	}
+
+	integrationType, ok := h.typesManager.GetIntegrationTypeMap()[credential.IntegrationType]
+	if !ok || integrationType == nil {
+		return fmt.Errorf("integration type %s is not loaded", credential.IntegrationType)
+	}
+	plugin, err := h.database.GetPluginByID(credential.IntegrationType.String())
+	if err != nil || plugin == nil {
+		return fmt.Errorf("integration type %s is not installed", credential.IntegrationType)
+	}
+	if plugin.OperationalStatus != models2.IntegrationPluginOperationalStatusEnabled ||
+		plugin.InstallState == models2.IntegrationTypeInstallStateNotInstalled {
+		return fmt.Errorf("integration type %s is not enabled", credential.IntegrationType)
+	}
+
+	mapData, err := h.secrets.Resolve(ctx, credential, models2.CredentialSecretPurposeDiscovery)
+	if err != nil {
+		h.logger.Error("failed to resolve secret", zap.Error(err))
+		return fmt.Errorf("failed to resolve credential secret")
+	}
+	jsonData, err := json.Marshal(mapData)
+	if err != nil {
+		return fmt.Errorf("failed to marshal credential")
+	}
+
+	discovered, err := integrationType.DiscoverIntegrations(jsonData)
+	if err != nil {
+		return fmt.Errorf("failed to discover integrations: %v", err)
+	}
+	report.Discovered = len(discovered)
+
+	existing, err := h.database.ListIntegration([]integration.Type{credential.IntegrationType})
+	if err != nil {
+		return fmt.Errorf("failed to list integrations: %v", err)
+	}
+	plan, err := planCredentialOrgSync(credential.ID, discovered, existing, orgSync.ArchiveMissing)
+	if err != nil {
+		return err
+	}
+	report.Unchanged = plan.unchanged
+	*failed = append(*failed, plan.failed...)
+
+	defaultLabels := make(map[string]string)
+	for k, v := range h.orgSyncConfig.DefaultLabels {
+		defaultLabels[k] = v
+	}
+	if orgSync.DefaultLabels.Status == pgtype.Present {
+		var labels map[string]string
+		if err = json.Unmarshal(orgSync.DefaultLabels.Bytes, &labels); err != nil {
+			return fmt.Errorf("invalid default labels: %v", err)
+		}
+		for k, v := range labels {
+			defaultLabels[k] = v
+		}
+	}
+
+	for _, current := range plan.restore {
+		entry := orgSyncReportIntegration(current)
+		annotations, err := integrationAnnotations(current)
+		if err == nil {
+			delete(annotations, orgSyncArchivedAnnotation)
+			err = setIntegrationAnnotations(&current, annotations)
+		}
+		if err != nil {
+			entry.Reason = fmt.Sprintf("failed to set annotations: %v", err)
+			*failed = append(*failed, entry)
+			continue
+		}
+		current.State = integration.IntegrationStateActive
+		if err = h.database.UpdateIntegration(&current); err != nil {
+			h.logger.Error("failed to restore integration", zap.String("integrationId", current.IntegrationID.String()), zap.Error(err))
+			entry.Reason = "failed to restore integration"
+			*failed = append(*failed, entry)
+			continue
+		}
+		*restored = append(*restored, entry)
+	}
+
+	var addedIDs []string
+	for _, in := range plan.add {
+		i := models2.Integration{Integration: in}
+		i.IntegrationType = credential.IntegrationType
+		i.CredentialID = credential.ID
+		if err = applyManifestMetadata(&i, models.IntegrationManifestEntry{Labels: defaultLabels}, false); err != nil {
+			entry := orgSyncReportIntegration(i)
+			entry.Reason = fmt.Sprintf("invalid labels or annotations: %v", err)
+			*failed = append(*failed, entry)
+			continue
+		}
+		integrationApi, err := i.ToApi()
+		if err != nil {
+			entry := orgSyncReportIntegration(i)
+			entry.Reason = fmt.Sprintf("failed to read integration: %v", err)
+			*failed = append(*failed, entry)
+			continue
+		}
+		healthy, err := integrationType.HealthCheck(jsonData, i.ProviderID, integrationApi.Labels, integrationApi.Annotations)
+		if err != nil || !healthy {
+			i.State = integration.IntegrationStateInactive
+		} else {
+			i.State = integration.IntegrationStateActive
+		}
+		checkedAt := time.Now()
+		i.LastCheck = &checkedAt
+		if err = h.database.CreateIntegration(&i); err != nil {
+			h.logger.Error("failed to create integration", zap.String("providerId", i.ProviderID), zap.Error(err))
+			entry := orgSyncReportIntegration(i)
+			entry.Reason = "failed to create integration"
+			*failed = append(*failed, entry)
+			continue
+		}
+		*added = append(*added, orgSyncReportIntegration(i))
+		addedIDs = append(addedIDs, i.IntegrationID.String())
+	}
+
+	for _, current := range plan.archive {
+		entry := orgSyncReportIntegration(current)
+		if _, err = current.AddAnnotations(orgSyncArchivedAnnotation, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			entry.Reason = fmt.Sprintf("failed to set annotations: %v", err)
+			*failed = append(*failed, entry)
+			continue
+		}
+		current.State = integration.IntegrationStateArchived
+		if err = h.database.UpdateIntegration(&current); err != nil {
+			h.logger.Error("failed to archive integration", zap.String("integrationId", current.IntegrationID.String()), zap.Error(err))
+			entry.Reason = "failed to archive integration"
+			*failed = append(*failed, entry)
+			continue
+		}
+		entry.Reason = "no longer discovered with the credential"
+		*archived = append(*archived, entry)
+	}
+
+	if len(addedIDs) > 0 {
+		frameworks := make(map[string]bool)
+		for _, framework := range h.orgSyncConfig.DefaultFrameworks {
+			frameworks[framework] = true
+		}
+		for _, framework := range orgSync.FrameworkIDs {
+			frameworks[framework] = true
+		}
+		clientCtx := &httpclient.Context{UserRole: api.AdminRole, Ctx: ctx}
+		for framework := range frameworks {
+			if err = h.complianceClient.AddFrameworkAssignments(clientCtx, framework, addedIDs); err != nil {
+				h.logger.Error("failed to assign framework", zap.String("framework", framework), zap.Error(err))
+				return fmt.Errorf("integrations were added but assigning framework %s failed: %v", framework, err)
+			}
+		}
+	}
+
+	integrations, err := h.database.ListIntegrationsByCredentialID(credential.ID.String())
+	if err != nil {
+		return fmt.Errorf("failed to list credential integrations: %v", err)
+	}
+	if err = h.database.UpdateCredentialIntegrationCount(credential.ID.String(), len(integrations)); err != nil {
+		h.logger.Error("failed to update credential integration count", zap.String("credentialId", credential.ID.String()), zap.Error(err))
+	}
+
+	return nil
+}
+
+// orgSyncPlan is what an org sync changes in the integrations of a credential
+type orgSyncPlan struct {
+	// add are the discovered accounts which are not onboarded yet
+	add []integration.Integration
+	// restore are the integrations archived by an earlier sync which are discovered again
+	restore []models2.Integration
+	// archive are the integrations of the credential which are no longer discovered
+	archive   []models2.Integration
+	failed    []models2.CredentialOrgSyncReportIntegration
+	unchanged int
+}
+
+// planCredentialOrgSync compares the discovered accounts with the existing integrations of the integration type.
+// Accounts onboarded with another credential are left alone and only the integrations archived by the org sync
+// are restored. Nothing is planned for an empty discovery of a credential which has integrations.
+func planCredentialOrgSync(credentialID uuid.UUID, discovered []integration.Integration, existing []models2.Integration,
+	archiveMissing bool) (*orgSyncPlan, error) {
+	existingByProviderID := make(map[string]models2.Integration)
+	var credentialIntegrations []models2.Integration
+	for _, i := range existing {
+		if i.State == integration.IntegrationStateSample {
+			continue
+		}
+		existingByProviderID[i.ProviderID] = i
+		if i.CredentialID == credentialID {
+			credentialIntegrations = append(credentialIntegrations, i)
+		}
+	}
+	// an empty discovery is more likely a permission problem than an organization without accounts
+	if len(discovered) == 0 && len(credentialIntegrations) > 0 {
+		return nil, fmt.Errorf("discovery returned no accounts, nothing is archived")
+	}
+
+	plan := &orgSyncPlan{}
+	discoveredProviderIDs := make(map[string]bool)
+	for _, in := range discovered {
+		discoveredProviderIDs[in.ProviderID] = true
+
+		current, ok := existingByProviderID[in.ProviderID]
+		if !ok {
+			plan.add = append(plan.add, in)
+			continue
+		}
+		if current.CredentialID != credentialID {
+			// the account is onboarded with another credential
+			plan.unchanged++
+			continue
+		}
+		annotations, err := integrationAnnotations(current)
+		if err != nil {
+			entry := orgSyncReportIntegration(current)
+			entry.Reason = fmt.Sprintf("failed to read annotations: %v", err)
+			plan.failed = append(plan.failed, entry)
+			continue
+		}
+		if current.State != integration.IntegrationStateArchived || annotations[orgSyncArchivedAnnotation] == "" {
+			plan.unchanged++
+			continue
+		}
+		plan.restore = append(plan.restore, current)
+	}
+
+	if archiveMissing {
+		for _, current := range credentialIntegrations {
+			if discoveredProviderIDs[current.ProviderID] || current.State == integration.IntegrationStateArchived {
+				continue
+			}
+			plan.archive = append(plan.archive, current)
+		}
+	}
+	return plan, nil
+}
+
+func orgSyncReportIntegration(i models2.Integration) models2.CredentialOrgSyncReportIntegration {
+	return models2.CredentialOrgSyncReportIntegration{
+		IntegrationID: i.IntegrationID.String(),
+		ProviderID:    i.ProviderID,
+		Name:          i.Name,
+	}
+}
+
+func integrationAnnotations(i models2.Integration) (map[string]string, error) {
+	annotations := make(map[string]string)
+	if i.Annotations.Status == pgtype.Present {
+		if err := json.Unmarshal(i.Annotations.Bytes, &annotations); err != nil {
+			return nil, err
+		}
+	}
+	return annotations, nil
+}
+
+func setIntegrationAnnotations(i *models2.Integration, annotations map[string]string) error {
+	annotationsJson, err := json.Marshal(annotations)
+	if err != nil {
+		return err
+	}
+	return i.Annotations.Set(annotationsJson)
+}
+
+// ListOrgSyncs godoc
+//
+//	@Summary		List org syncs
+//	@Description	List the sub-account sync settings of the organization level credentials
+//	@Security		BearerToken
+//	@Tags			integrations
+//	@Produce		json
+//	@Success		200	{object}	[]models.CredentialOrgSync
+//	@Router			/integration/api/v1/integrations/org-sync [get]
+func (h *API) ListOrgSyncs(c echo.Context) error {
+	orgSyncs, err := h.database.ListCredentialOrgSyncs(false)
+	if err != nil {
+		h.logger.Error("failed to list org syncs", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list org syncs")
+	}
+
+	items := make([]models.CredentialOrgSync, 0, len(orgSyncs))
+	for _, orgSync := range orgSyncs {
+		ok, err := h.credentialInScope(c, orgSync.CredentialID.String())
+		if err != nil {
+			h.logger.Error("failed to list credential integrations", zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to list org syncs")
+		}
+		if !ok {
+			continue
+		}
+		item, err := h.orgSyncToApi(orgSync)
+		if err != nil {
+			h.logger.Error("failed to read org sync", zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to read org sync")
+		}
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].CredentialID < items[j].CredentialID
+	})
+
+	return c.JSON(http.StatusOK, items)
+}
+
+// GetOrgSync godoc
+//
+//	@Summary		Get org sync
+//	@Description	Get the sub-account sync settings of a credential with its last report
+//	@Security		BearerToken
+//	@Tags			integrations
+//	@Produce		json
+//	@Param			credentialId	path		string	true	"credential id"
+//	@Success		200				{object}	models.CredentialOrgSync
+//	@Router			/integration/api/v1/integrations/org-sync/{credentialId} [get]
+func (h *API) GetOrgSync(c echo.Context) error {
+	credentialID, err := uuid.Parse(c.Param("credentialId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid credential id")
+	}
+	if err = h.checkCredentialInScope(c, credentialID); err != nil {
+		return err
+	}
+
+	orgSync, err := h.database.GetCredentialOrgSync(credentialID)
+	if err != nil {
+		h.logger.Error("failed to get org sync", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get org sync")
+	}
+	if orgSync == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "org sync is not configured for the credential")
+	}
+
+	item, err := h.orgSyncToApi(*orgSync)
+	if err != nil {
+		h.logger.Error("failed to read org sync", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to read org sync")
+	}
+
+	return c.JSON(http.StatusOK, item)
+}
+
+// UpdateOrgSync godoc
+//
+//	@Summary		Configure org sync
+//	@Description	Enable or configure the periodic sub-account sync of an organization level credential
+//	@Security		BearerToken
+//	@Tags			integrations
+//	@Accept			json
+//	@Produce		json
+//	@Param			credentialId	path		string									true	"credential id"
+//	@Param			request			body		models.UpdateCredentialOrgSyncRequest	true	"Request"
+//	@Success		200				{object}	models.CredentialOrgSync
+//	@Router			/integration/api/v1/integrations/org-sync/{credentialId} [put]
+func (h *API) UpdateOrgSync(c echo.Context) error {
+	credentialID, err := uuid.Parse(c.Param("credentialId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid credential id")
+	}
+	if err = h.checkCredentialInScope(c, credentialID); err != nil {
+		return err
+	}
+
+	var req models.UpdateCredentialOrgSyncRequest
+	if err = c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+	if req.IntervalMinutes < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "interval_minutes should not be negative")
+	}
+
+	credential, err := h.database.GetCredential(credentialID.String())
+	if err != nil || credential == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "credential not found")
+	}
+
+	orgSync := models2.CredentialOrgSync{
+		CredentialID:    credentialID,
+		Enabled:         req.Enabled,
+		IntervalMinutes: req.IntervalMinutes,
+		FrameworkIDs:    req.FrameworkIDs,
+		ArchiveMissing:  true,
+		CreatedBy:       httpserver.GetUserID(c),
+		UpdatedAt:       time.Now(),
+	}
+	if req.ArchiveMissing != nil {
+		orgSync.ArchiveMissing = *req.ArchiveMissing
+	}
+	if req.DefaultLabels == nil {
+		req.DefaultLabels = map[string]string{}
+	}
+	labelsJson, err := json.Marshal(req.DefaultLabels)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid default labels")
+	}
+	if err = orgSync.DefaultLabels.Set(labelsJson); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid default labels")
+	}
+
+	if err = h.database.UpsertCredentialOrgSync(&orgSync); err != nil {
+		h.logger.Error("failed to update org sync", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update org sync")
+	}
+
+	updated, err := h.database.GetCredentialOrgSync(credentialID)
+	if err != nil || updated == nil {
+		h.logger.Error("failed to get org sync", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get org sync")
+	}
+	item, err := h.orgSyncToApi(*updated)
+	if err != nil {
+		h.logger.Error("failed to read org sync", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to read org sync")
+	}
+
+	return c.JSON(http.StatusOK, item)
+}
+
+// DeleteOrgSync godoc
+//
+//	@Summary		Remove org sync
+//	@Description	Stop syncing the sub-accounts of a credential, the sync reports are kept
+//	@Security		BearerToken
+//	@Tags			integrations
+//	@Param			credentialId	path	string	true	"credential id"
+//	@Success		200
+//	@Router			/integration/api/v1/integrations/org-sync/{credentialId} [delete]
+func (h *API) DeleteOrgSync(c echo.Context) error {
+	credentialID, err := uuid.Parse(c.Param("credentialId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid credential id")
+	}
+	if err = h.checkCredentialInScope(c, credentialID); err != nil {
+		return err
+	}
+
+	if err = h.database.DeleteCredentialOrgSync(credentialID); err != nil {
+		h.logger.Error("failed to delete org sync", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete org sync")
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// RunOrgSync godoc
+//
+//	@Summary		Run org sync
+//	@Description	Sync the sub-accounts of a credential now and return the sync report
+//	@Security		BearerToken
+//	@Tags			integrations
+//	@Produce		json
+//	@Param			credentialId	path		string	true	"credential id"
+//	@Success		200				{object}	models.OrgSyncReport
+//	@Router			/integration/api/v1/integrations/org-sync/{credentialId}/run [post]
+func (h *API) RunOrgSync(c echo.Context) error {
+	credentialID, err := uuid.Parse(c.Param("credentialId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid credential id")
+	}
+	if err = h.checkCredentialInScope(c, credentialID); err != nil {
+		return err
+	}
+
+	orgSync, err := h.database.GetCredentialOrgSync(credentialID)
+	if err != nil {
+		h.logger.Error("failed to get org sync", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get org sync")
+	}
+	if orgSync == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "org sync is not configured for the credential")
+	}
+
+	report, err := h.syncCredentialOrg(c.Request().Context(), *orgSync, models2.CredentialOrgSyncTriggerManual)
+	if err != nil {
+		if errors.Is(err, errOrgSyncRunning) {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		h.logger.Error("failed to sync credential org", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to sync credential org")
+	}
+
+	reportApi, err := orgSyncReportToApi(*report)
+	if err != nil {
+		h.logger.Error("failed to read org sync report", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to read org sync report")
+	}
+
+	return c.JSON(http.StatusOK, reportApi)
+}
+
+// ListOrgSyncReports godoc
+//
+//	@Summary		List org sync reports
+//	@Description	List the sub-account sync reports of a credential, newest first
+//	@Security		BearerToken
+//	@Tags			integrations
+//	@Produce		json
+//	@Param			credentialId	path		string	true	"credential id"
+//	@Param			limit			query		int		false	"maximum number of reports"
+//	@Success		200				{object}	models.ListOrgSyncReportsResponse
+//	@Router			/integration/api/v1/integrations/org-sync/{credentialId}/reports [get]
+func (h *API) ListOrgSyncReports(c echo.Context) error {
+	credentialID, err := uuid.Parse(c.Param("credentialId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid credential id")
+	}
+	if err = h.checkCredentialInScope(c, credentialID); err != nil {
+		return err
+	}
+
+	limit := defaultOrgSyncReportsLimit
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid limit")
+		}
+	}
+
+	reports, err := h.database.ListCredentialOrgSyncReports(credentialID, limit)
+	if err != nil {
+		h.logger.Error("failed to list org sync reports", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list org sync reports")
+	}
+
+	response := models.ListOrgSyncReportsResponse{Reports: []models.OrgSyncReport{}}
+	for _, report := range reports {
+		reportApi, err := orgSyncReportToApi(report)
+		if err != nil {
+			h.logger.Error("failed to read org sync report", zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to read org sync report")
+		}
+		response.Reports = append(response.Reports, *reportApi)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *API) orgSyncToApi(orgSync models2.CredentialOrgSync) (*models.CredentialOrgSync, error) {
+	item := models.CredentialOrgSync{
+		CredentialID:    orgSync.CredentialID.String(),
+		Enabled:         orgSync.Enabled,
+		IntervalMinutes: orgSync.IntervalMinutes,
+		DefaultLabels:   map[string]string{},
+		FrameworkIDs:    orgSync.FrameworkIDs,
+		ArchiveMissing:  orgSync.ArchiveMissing,
+		LastSyncAt:      orgSync.LastSyncAt,
+	}
+	if item.FrameworkIDs == nil {
+		item.FrameworkIDs = []string{}
+	}
+	if orgSync.DefaultLabels.Status == pgtype.Present {
+		if err := json.Unmarshal(orgSync.DefaultLabels.Bytes, &item.DefaultLabels); err != nil {
+			return nil, err
+		}
+	}
+	if credential, err := h.database.GetCredential(orgSync.CredentialID.String()); err == nil && credential != nil {
+		item.IntegrationType = credential.IntegrationType.String()
+	}
+	if orgSync.Enabled {
+		next := time.Now()
+		if orgSync.LastSyncAt != nil {
+			next = orgSync.LastSyncAt.Add(h.orgSyncInterval(orgSync))
+		}
+		item.NextSyncAt = &next
+	}
+
+	reports, err := h.database.ListCredentialOrgSyncReports(orgSync.CredentialID, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(reports) > 0 {
+		item.LastReport, err = orgSyncReportToApi(reports[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &item, nil
+}
+
+func orgSyncReportToApi(report models2.CredentialOrgSyncReport) (*models.OrgSyncReport, error) {
+	reportApi := models.OrgSyncReport{
+		ID:           report.ID,
+		CredentialID: report.CredentialID.String(),
+		Trigger:      string(report.Trigger),
+		Status:       string(report.Status),
+		Error:        report.Error,
+		StartedAt:    report.StartedAt,
+		FinishedAt:   report.FinishedAt,
+		Discovered:   report.Discovered,
+		Unchanged:    report.Unchanged,
+	}
+	for _, field := range []struct {
+		value   pgtype.JSONB
+		entries *[]models.OrgSyncReportIntegration
+	}{{report.Added, &reportApi.Added}, {report.Restored, &reportApi.Restored}, {report.Archived, &reportApi.Archived}, {report.Failed, &reportApi.Failed}} {
+		*field.entries = []models.OrgSyncReportIntegration{}
+		if field.value.Status != pgtype.Present {
+			continue
+		}
+		var entries []models2.CredentialOrgSyncReportIntegration
+		if err := json.Unmarshal(field.value.Bytes, &entries); err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			*field.entries = append(*field.entries, models.OrgSyncReportIntegration{
+				IntegrationID: e.IntegrationID,
+				ProviderID:    e.ProviderID,
+				Name:          e.Name,
+				Reason:        e.Reason,
+			})
+		}
+	}
+	return &reportApi, nil
+}
@@ -0,0 +1,100 @@
+package integrations
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/opengovern/og-util/pkg/integration"
+	models2 "github.com/opengovern/opensecurity/services/integration/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func orgSyncIntegration(t *testing.T, credentialID uuid.UUID, providerID string, state integration.IntegrationState, annotations string) models2.Integration {
+	i := models2.Integration{Integration: integration.Integration{
+		IntegrationID: uuid.New(),
+		ProviderID:    providerID,
+		CredentialID:  credentialID,
+		State:         state,
+	}}
+	require.NoError(t, i.Annotations.Set([]byte(annotations)))
+	return i
+}
+
+func providerIDs[T any](items []T, providerID func(T) string) []string {
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, providerID(item))
+	}
+	return ids
+}
+
+func TestPlanCredentialOrgSync(t *testing.T) {
+	credentialID, otherCredentialID := uuid.New(), uuid.New()
+	existing := []models2.Integration{
+		orgSyncIntegration(t, credentialID, "active", integration.IntegrationStateActive, `{}`),
+		orgSyncIntegration(t, credentialID, "missing", integration.IntegrationStateActive, `{}`),
+		orgSyncIntegration(t, credentialID, "sync-archived", integration.IntegrationStateArchived, `{"`+orgSyncArchivedAnnotation+`":"2026-01-01T00:00:00Z"}`),
+		orgSyncIntegration(t, credentialID, "user-archived", integration.IntegrationStateArchived, `{}`),
+		orgSyncIntegration(t, credentialID, "archived-missing", integration.IntegrationStateArchived, `{}`),
+		orgSyncIntegration(t, otherCredentialID, "other-credential", integration.IntegrationStateActive, `{}`),
+		orgSyncIntegration(t, otherCredentialID, "other-credential-missing", integration.IntegrationStateActive, `{}`),
+		orgSyncIntegration(t, credentialID, "sample", integration.IntegrationStateSample, `{}`),
+	}
+	discovered := []integration.Integration{
+		{ProviderID: "active"},
+		{ProviderID: "sync-archived"},
+		{ProviderID: "user-archived"},
+		{ProviderID: "other-credential"},
+		{ProviderID: "sample"},
+		{ProviderID: "new"},
+	}
+
+	plan, err := planCredentialOrgSync(credentialID, discovered, existing, true)
+	require.NoError(t, err)
+	// sample integrations are not onboarded accounts
+	assert.Equal(t, []string{"sample", "new"}, providerIDs(plan.add, func(i integration.Integration) string { return i.ProviderID }))
+	// integrations archived by a user stay archived
+	assert.Equal(t, []string{"sync-archived"}, providerIDs(plan.restore, func(i models2.Integration) string { return i.ProviderID }))
+	// only the integrations of the credential are archived
+	assert.Equal(t, []string{"missing"}, providerIDs(plan.archive, func(i models2.Integration) string { return i.ProviderID }))
+	assert.Equal(t, 3, plan.unchanged)
+	assert.Empty(t, plan.failed)
+
+	plan, err = planCredentialOrgSync(credentialID, discovered, existing, false)
+	require.NoError(t, err)
+	assert.Empty(t, plan.archive, "missing integrations are kept unless archive_missing is set")
+}
+
+func TestPlanCredentialOrgSyncEmptyDiscovery(t *testing.T) {
+	credentialID := uuid.New()
+	existing := []models2.Integration{
+		orgSyncIntegration(t, credentialID, "active", integration.IntegrationStateActive, `{}`),
+	}
+
+	_, err := planCredentialOrgSync(credentialID, nil, existing, true)
+	assert.ErrorContains(t, err, "nothing is archived")
+
+	// a credential without integrations, or with only samples, has nothing to lose
+	plan, err := planCredentialOrgSync(uuid.New(), nil, existing, true)
+	require.NoError(t, err)
+	assert.Empty(t, plan.add)
+	assert.Empty(t, plan.archive)
+
+	sample := []models2.Integration{orgSyncIntegration(t, credentialID, "sample", integration.IntegrationStateSample, `{}`)}
+	_, err = planCredentialOrgSync(credentialID, nil, sample, true)
+	assert.NoError(t, err)
+}
+
+func TestPlanCredentialOrgSyncInvalidAnnotations(t *testing.T) {
+	credentialID := uuid.New()
+	broken := orgSyncIntegration(t, credentialID, "broken", integration.IntegrationStateArchived, `{}`)
+	broken.Annotations.Bytes = []byte(`["not", "a", "map"]`)
+
+	plan, err := planCredentialOrgSync(credentialID, []integration.Integration{{ProviderID: "broken"}}, []models2.Integration{broken}, true)
+	require.NoError(t, err)
+	require.Len(t, plan.failed, 1)
+	assert.Equal(t, broken.IntegrationID.String(), plan.failed[0].IntegrationID)
+	assert.Contains(t, plan.failed[0].Reason, "failed to read annotations")
+	assert.Empty(t, plan.restore)
+}
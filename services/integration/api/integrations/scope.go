@@ -0,0 +1,76 @@
+package integrations
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/opengovern/og-util/pkg/httpserver"
+	"github.com/opengovern/opensecurity/services/integration/api/models"
+	models2 "github.com/opengovern/opensecurity/services/integration/models"
+	"go.uber.org/zap"
+)
+
+// inScope reports whether the caller's role bindings give access to the integration,
+// callers without bindings have access to every integration
+func inScope(c echo.Context, integrationID string) bool {
+	return httpserver.CheckAccessToConnectionID(c, integrationID) == nil
+}
+
+// filterInScope drops the integrations outside of the caller's scope
+func filterInScope(c echo.Context, integrations []models.Integration) []models.Integration {
+	var items []models.Integration
+	for _, i := range integrations {
+		if inScope(c, i.IntegrationID) {
+			items = append(items, i)
+		}
+	}
+	return items
+}
+
+// scoped reports whether the caller's role bindings limit it to some integrations
+func scoped(c echo.Context) bool {
+	return c.Request().Header.Get(httpserver.XPlatformUserConnectionsScope) != ""
+}
+
+// allInScope reports whether every integration is in the caller's scope. Scoped callers have no access to an
+// empty set, as the credential settings it stands for reach integrations yet to be discovered.
+func allInScope(c echo.Context, integrations []models2.Integration) bool {
+	if !scoped(c) {
+		return true
+	}
+	if len(integrations) == 0 {
+		return false
+	}
+	for _, i := range integrations {
+		if !inScope(c, i.IntegrationID.String()) {
+			return false
+		}
+	}
+	return true
+}
+
+// credentialInScope reports whether every integration using the credential is in the caller's scope
+func (h *API) credentialInScope(c echo.Context, credentialID string) (bool, error) {
+	if !scoped(c) {
+		return true, nil
+	}
+	integrations, err := h.database.ListIntegrationsByCredentialID(credentialID)
+	if err != nil {
+		return false, err
+	}
+	return allInScope(c, integrations), nil
+}
+
+// checkCredentialInScope returns a not found error for credentials outside of the caller's scope
+func (h *API) checkCredentialInScope(c echo.Context, credentialID uuid.UUID) error {
+	ok, err := h.credentialInScope(c, credentialID.String())
+	if err != nil {
+		h.logger.Error("failed to list credential integrations", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list credential integrations")
+	}
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "credential not found")
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package integrations
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/opengovern/og-util/pkg/httpserver"
+	"github.com/opengovern/og-util/pkg/integration"
+	"github.com/opengovern/opensecurity/services/integration/api/models"
+	"github.com/opengovern/opensecurity/services/integration/db"
+	models2 "github.com/opengovern/opensecurity/services/integration/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func scopedContext(integrationIDs string) echo.Context {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if integrationIDs != "" {
+		req.Header.Set(httpserver.XPlatformUserConnectionsScope, integrationIDs)
+	}
+	return echo.New().NewContext(req, httptest.NewRecorder())
+}
+
+func TestInScope(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+
+	unscoped := scopedContext("")
+	assert.True(t, inScope(unscoped, a.String()))
+
+	c := scopedContext(a.String())
+	assert.True(t, inScope(c, a.String()))
+	assert.False(t, inScope(c, b.String()))
+}
+
+func TestFilterInScope(t *testing.T) {
+	a, b := uuid.New().String(), uuid.New().String()
+	integrations := []models.Integration{{IntegrationID: a}, {IntegrationID: b}}
+
+	assert.Len(t, filterInScope(scopedContext(""), integrations), 2)
+	filtered := filterInScope(scopedContext(b), integrations)
+	if assert.Len(t, filtered, 1) {
+		assert.Equal(t, b, filtered[0].IntegrationID)
+	}
+}
+
+func TestAllInScope(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	integrations := []models2.Integration{
+		{Integration: integration.Integration{IntegrationID: a}},
+		{Integration: integration.Integration{IntegrationID: b}},
+	}
+
+	assert.True(t, allInScope(scopedContext(""), integrations))
+	assert.True(t, allInScope(scopedContext(""), nil))
+	assert.True(t, allInScope(scopedContext(a.String()+","+b.String()), integrations))
+	assert.False(t, allInScope(scopedContext(a.String()), integrations), "a credential shared with an integration outside of the scope")
+	assert.False(t, allInScope(scopedContext(a.String()), nil), "scoped callers have no access to credentials without integrations")
+}
+
+func mockAPI(t *testing.T) (*API, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	orm, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+	return &API{logger: zap.NewNop(), database: db.NewDatabase(orm, orm)}, mock
+}
+
+func scopedRequest(method, target, body, integrationIDs string) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if integrationIDs != "" {
+		req.Header.Set(httpserver.XPlatformUserConnectionsScope, integrationIDs)
+	}
+	rec := httptest.NewRecorder()
+	return echo.New().NewContext(req, rec), rec
+}
+
+func httpErrorCode(t *testing.T, err error) int {
+	var httpErr *echo.HTTPError
+	require.True(t, errors.As(err, &httpErr), "expected an HTTP error, got %v", err)
+	return httpErr.Code
+}
+
+func TestAddIntegrationsOutOfScope(t *testing.T) {
+	h, mock := mockAPI(t)
+	credentialID := uuid.NewString()
+	a, b := uuid.NewString(), uuid.NewString()
+	mock.ExpectQuery(`SELECT \* FROM "integrations" WHERE credential_id = \$1`).
+		WithArgs(credentialID).
+		WillReturnRows(sqlmock.NewRows([]string{"integration_id", "credential_id"}).
+			AddRow(a, credentialID).
+			AddRow(b, credentialID))
+
+	c, _ := scopedRequest(http.MethodPost, "/", `{"credential_id":"`+credentialID+`","integration_type":"aws_cloud_account","provider_ids":["123456789012"]}`, a)
+	assert.Equal(t, http.StatusNotFound, httpErrorCode(t, h.AddIntegrations(c)))
+	// the secret is not resolved for discovery
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBulkImportManifestCredentialsOutOfScope(t *testing.T) {
+	h, mock := mockAPI(t)
+	c, _ := scopedRequest(http.MethodPost, "/", `
+credentials:
+  - ref: github
+    integration_type: github_account
+    credentials:
+      token: ghp_secret
+integrations:
+  - integration_type: github_account
+    provider_id: opengovern
+    credential_ref: github
+`, uuid.NewString())
+	c.Request().Header.Set(echo.HeaderContentType, "application/yaml")
+
+	assert.Equal(t, http.StatusForbidden, httpErrorCode(t, h.BulkImportIntegrations(c)))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListIntegrationGroupHistoryScope(t *testing.T) {
+	h, mock := mockAPI(t)
+	a, b := uuid.NewString(), uuid.NewString()
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "integration_group_membership_events" WHERE group_name = \$1 AND integration_id IN \(\$2,\$3\)`).
+		WithArgs("production", a, b).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT \* FROM "integration_group_membership_events" WHERE group_name = \$1 AND integration_id IN \(\$2,\$3\)`).
+		WithArgs("production", a, b).
+		WillReturnRows(sqlmock.NewRows([]string{"group_name", "integration_id", "action"}).AddRow("production", a, "added"))
+
+	c, rec := scopedRequest(http.MethodGet, "/", "", a+","+b)
+	c.SetParamNames("integrationGroupName")
+	c.SetParamValues("production")
+	require.NoError(t, h.ListIntegrationGroupHistory(c))
+	var response models.ListIntegrationGroupMembershipEventsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Len(t, response.Events, 1)
+	assert.Equal(t, a, response.Events[0].IntegrationID)
+
+	// the history of an integration outside of the scope is not listed
+	c, _ = scopedRequest(http.MethodGet, "/?integration_id="+uuid.NewString(), "", a)
+	c.SetParamNames("integrationGroupName")
+	c.SetParamValues("production")
+	assert.Equal(t, http.StatusForbidden, httpErrorCode(t, h.ListIntegrationGroupHistory(c)))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
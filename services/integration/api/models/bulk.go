@@ -0,0 +1,72 @@
+package models
+
+// IntegrationManifest is the YAML/CSV representation of integrations used for bulk import and export.
+// Integrations reference an existing credential or one of the manifest credentials, which are created on import.
+// Secrets are never exported.
+type IntegrationManifest struct {
+	Credentials  []CredentialManifestEntry  `json:"credentials,omitempty" yaml:"credentials,omitempty"`
+	Integrations []IntegrationManifestEntry `json:"integrations" yaml:"integrations"`
+}
+
+// CredentialManifestEntry is a credential to create on import. Ref names it within the manifest.
+// The secret is either given inline in Credentials or kept in an external store with SecretBackend and SecretReference.
+type CredentialManifestEntry struct {
+	Ref             string         `json:"ref" yaml:"ref"`
+	IntegrationType string         `json:"integration_type" yaml:"integration_type"`
+	CredentialType  string         `json:"credential_type" yaml:"credential_type"`
+	Description     string         `json:"description,omitempty" yaml:"description,omitempty"`
+	Credentials     map[string]any `json:"credentials,omitempty" yaml:"credentials,omitempty"`
+	SecretBackend   string         `json:"secret_backend,omitempty" yaml:"secret_backend,omitempty" enums:"hashicorp-vault,kubernetes,file"`
+	SecretReference string         `json:"secret_reference,omitempty" yaml:"secret_reference,omitempty"`
+}
+
+type IntegrationManifestEntry struct {
+	IntegrationType string            `json:"integration_type" yaml:"integration_type"`
+	ProviderID      string            `json:"provider_id" yaml:"provider_id"`
+	Name            string            `json:"name,omitempty" yaml:"name,omitempty"`
+	CredentialID    string            `json:"credential_id,omitempty" yaml:"credential_id,omitempty"`
+	CredentialRef   string            `json:"credential_ref,omitempty" yaml:"credential_ref,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+	Frameworks      []string          `json:"frameworks,omitempty" yaml:"frameworks,omitempty"`
+}
+
+type BulkImportAction string
+
+const (
+	BulkImportActionCreate BulkImportAction = "create"
+	BulkImportActionUpdate BulkImportAction = "update"
+	BulkImportActionSkip   BulkImportAction = "skip"
+)
+
+type BulkImportRowResult struct {
+	Row             int              `json:"row"`
+	IntegrationType string           `json:"integration_type"`
+	ProviderID      string           `json:"provider_id"`
+	IntegrationID   string           `json:"integration_id,omitempty"`
+	Action          BulkImportAction `json:"action"`
+	Valid           bool             `json:"valid"`
+	Healthy         bool             `json:"healthy"`
+	Errors          []string         `json:"errors,omitempty"`
+}
+
+// BulkImportCredentialResult is the outcome of a manifest credential. A credential is only created
+// once an integration row using it is valid, CredentialID is empty until then.
+type BulkImportCredentialResult struct {
+	Ref             string   `json:"ref"`
+	IntegrationType string   `json:"integration_type"`
+	CredentialID    string   `json:"credential_id,omitempty"`
+	Created         bool     `json:"created"`
+	Valid           bool     `json:"valid"`
+	Errors          []string `json:"errors,omitempty"`
+}
+
+type BulkImportResponse struct {
+	DryRun             bool                         `json:"dry_run"`
+	Created            int                          `json:"created"`
+	Updated            int                          `json:"updated"`
+	Failed             int                          `json:"failed"`
+	CredentialsCreated int                          `json:"credentials_created"`
+	Credentials        []BulkImportCredentialResult `json:"credentials,omitempty"`
+	Rows               []BulkImportRowResult        `json:"rows"`
+}
@@ -6,18 +6,34 @@ import (
 )
 
 type Credential struct {
-	ID              string            `json:"id"`
-	Secret          string            `json:"secret"`
-	IntegrationType integration.Type  `json:"integration_type"`
-	CredentialType  string            `json:"credential_type"`
-	Metadata        map[string]string `json:"metadata"`
-	IntegrationCount int             `json:"integration_count"`
-	MaskedSecret  map[string]string `json:"masked_secret"`
-	Description     string            `json:"description"`
-	CreatedAt       time.Time         `json:"created_at"`
-	UpdatedAt       time.Time         `json:"updated_at"`
+	ID               string            `json:"id"`
+	Secret           string            `json:"secret"`
+	IntegrationType  integration.Type  `json:"integration_type"`
+	CredentialType   string            `json:"credential_type"`
+	Metadata         map[string]string `json:"metadata"`
+	IntegrationCount int               `json:"integration_count"`
+	MaskedSecret     map[string]string `json:"masked_secret"`
+	Description      string            `json:"description"`
+	CreatedAt        time.Time         `json:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at"`
+	ExpiresAt        *time.Time        `json:"expires_at,omitempty"`
+	ExpirySource     string            `json:"expiry_source,omitempty" enums:"manual,auto"`
+	ExpiryStatus     string            `json:"expiry_status" enums:"OK,EXPIRING,EXPIRED,UNKNOWN"`
+	RotatedAt        *time.Time        `json:"rotated_at,omitempty"`
+	// AgeDays is the number of days since the secret was created or last rotated
+	AgeDays int `json:"age_days"`
+	// SecretBackend is empty for secrets stored in the platform
+	SecretBackend   string `json:"secret_backend,omitempty" enums:"hashicorp-vault,kubernetes,file"`
+	SecretReference string `json:"secret_reference,omitempty"`
 }
 
+const (
+	CredentialExpiryStatusOK       = "OK"
+	CredentialExpiryStatusExpiring = "EXPIRING"
+	CredentialExpiryStatusExpired  = "EXPIRED"
+	CredentialExpiryStatusUnknown  = "UNKNOWN"
+)
+
 type ListCredentialsRequest struct {
 	CredentialID    []string `json:"credential_id"`
 	IntegrationType []string `json:"integration_type"`
@@ -26,9 +42,38 @@ type ListCredentialsRequest struct {
 type UpdateCredentialRequest struct {
 	Credentials map[string]any `json:"credentials"`
 	Description string         `json:"description"`
+	// ExpiresAt sets the expiry of the secret manually, it is parsed from the secret when possible otherwise
+	ExpiresAt *time.Time `json:"expires_at"`
+	// SecretReference points a credential kept in an external backend to another secret
+	SecretReference *string `json:"secret_reference"`
+}
+
+type CredentialIntegrationCheck struct {
+	IntegrationID string `json:"integration_id"`
+	Name          string `json:"name"`
+	ProviderID    string `json:"provider_id"`
+	Healthy       bool   `json:"healthy"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// CredentialRotationResult is the result of validating a new secret against all the integrations using the credential
+type CredentialRotationResult struct {
+	CredentialID string                       `json:"credential_id"`
+	Rotated      bool                         `json:"rotated"`
+	Integrations []CredentialIntegrationCheck `json:"integrations"`
 }
 
 type ListCredentialsResponse struct {
 	Credentials []Credential `json:"credentials"`
 	TotalCount  int          `json:"total_count"`
 }
+
+type CredentialSecretResolution struct {
+	CredentialID    string    `json:"credential_id"`
+	SecretBackend   string    `json:"secret_backend"`
+	SecretReference string    `json:"secret_reference"`
+	Purpose         string    `json:"purpose" enums:"discovery,health_check,describe,task,validation"`
+	Success         bool      `json:"success"`
+	Error           string    `json:"error,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
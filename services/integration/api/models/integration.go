@@ -20,6 +20,9 @@ type DiscoverIntegrationRequest struct {
 	Description     string           `json:"description"`
 	CredentialID    *string          `json:"credential_id"`
 	Credentials     map[string]any   `json:"credentials"`
+	// SecretBackend and SecretReference create a credential whose secret stays in an external store instead of Credentials
+	SecretBackend   string `json:"secret_backend" enums:"hashicorp-vault,kubernetes,file"`
+	SecretReference string `json:"secret_reference"`
 }
 
 type DiscoverIntegrationResponse struct {
@@ -62,8 +65,10 @@ type ListIntegrationsRequest struct {
 	IntegrationType []string `json:"integration_type"`
 	ProviderIDRegex *string  `json:"provider_id_regex"`
 	NameRegex       *string  `json:"integration_name_regex"`
-	Cursor          *int64   `json:"cursor"`
-	PerPage         *int64   `json:"per_page"`
+	// Labels only returns integrations having all the given labels
+	Labels  map[string]string `json:"labels"`
+	Cursor  *int64            `json:"cursor"`
+	PerPage *int64            `json:"per_page"`
 }
 
 type SetResourceTypesForIntegration struct {
@@ -1,8 +1,42 @@
 package models
 
+import "time"
+
 type IntegrationGroup struct {
 	Name           string        `json:"name" example:"UltraSightApplication"`
 	Query          string        `json:"query" example:"SELECT og_id FROM platform_integrations WHERE labels->'application' IS NOT NULL AND labels->'application' @> '\"UltraSight\"'"`
+	Description    string        `json:"description,omitempty"`
+	Source         string        `json:"source,omitempty" enums:"migration,user"`
+	CreatedBy      string        `json:"created_by,omitempty"`
+	CreatedAt      *time.Time    `json:"created_at,omitempty"`
+	UpdatedAt      *time.Time    `json:"updated_at,omitempty"`
 	IntegrationIds []string      `json:"integration_ids,omitempty" example:"[\"1e8ac3bf-c268-4a87-9374-ce04cc40a596\"]"`
 	Integrations   []Integration `json:"integrations,omitempty"`
 }
+
+type CreateIntegrationGroupRequest struct {
+	Name        string `json:"name" validate:"required"`
+	Query       string `json:"query" validate:"required"`
+	Description string `json:"description"`
+}
+
+type UpdateIntegrationGroupRequest struct {
+	Query       *string `json:"query"`
+	Description *string `json:"description"`
+}
+
+type PreviewIntegrationGroupRequest struct {
+	Query string `json:"query" validate:"required"`
+}
+
+type IntegrationGroupMembershipEvent struct {
+	GroupName     string    `json:"group_name"`
+	IntegrationID string    `json:"integration_id"`
+	Action        string    `json:"action" enums:"ADDED,REMOVED"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type ListIntegrationGroupMembershipEventsResponse struct {
+	Events     []IntegrationGroupMembershipEvent `json:"events"`
+	TotalCount int64                             `json:"total_count"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+type IntegrationHealthCheck struct {
+	CheckedAt time.Time `json:"checked_at"`
+	LatencyMs int64     `json:"latency_ms"`
+	Healthy   bool      `json:"healthy"`
+	Reason    string    `json:"reason,omitempty"`
+	Trigger   string    `json:"trigger" enums:"MANUAL,SCHEDULED"`
+}
+
+type IntegrationHealthSummary struct {
+	TotalChecks         int     `json:"total_checks"`
+	FailedChecks        int     `json:"failed_checks"`
+	ConsecutiveFailures int     `json:"consecutive_failures"`
+	Transitions         int     `json:"transitions"`
+	AverageLatencyMs    int64   `json:"average_latency_ms"`
+	UptimePercent       float64 `json:"uptime_percent"`
+	// Flapping is set when the integration switched between healthy and unhealthy
+	// at least flapping_threshold times in the flapping window
+	Flapping bool `json:"flapping"`
+}
+
+type IntegrationHealthTimelineResponse struct {
+	IntegrationID string                   `json:"integration_id"`
+	State         IntegrationState         `json:"state"`
+	LastCheck     *time.Time               `json:"last_check,omitempty"`
+	Summary       IntegrationHealthSummary `json:"summary"`
+	Checks        []IntegrationHealthCheck `json:"checks"`
+}
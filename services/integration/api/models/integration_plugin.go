@@ -29,7 +29,17 @@ type IntegrationPlugin struct {
 	DiscoveryType     string                          `json:"discovery_type"`
 	Count             IntegrationTypeIntegrationCount `json:"count"`
 
-	OperationalStatusUpdates []OperationalStatusUpdate `json:"operational_status_updates"`
+	OperationalStatusUpdates []OperationalStatusUpdate      `json:"operational_status_updates"`
+	Verification             *IntegrationPluginVerification `json:"verification,omitempty"`
+}
+
+type IntegrationPluginVerification struct {
+	Status                  string     `json:"status" enums:"verified,checksum_only,unverified,failed"`
+	Signer                  string     `json:"signer,omitempty"`
+	Message                 string     `json:"message,omitempty"`
+	VerifiedAt              *time.Time `json:"verified_at,omitempty"`
+	IntegrationPluginSHA256 string     `json:"integration_plugin_sha256,omitempty"`
+	CloudQLPluginSHA256     string     `json:"cloudql_plugin_sha256,omitempty"`
 }
 
 type IntegrationPluginListResponse struct {
@@ -41,3 +51,39 @@ type PluginTables struct {
 	PluginID string   `json:"plugin_id"`
 	Tables   []string `json:"tables"`
 }
+
+type PluginCompatibility struct {
+	PluginID                 string `json:"plugin_id"`
+	IntegrationType          string `json:"integration_type"`
+	Version                  string `json:"version"`
+	SupportedPlatformVersion string `json:"supported_platform_version"`
+	InstallState             string `json:"install_state"`
+	OperationalStatus        string `json:"operational_status"`
+	// Compatible is whether the plugin supports the running platform version
+	Compatible bool   `json:"compatible"`
+	Reason     string `json:"reason,omitempty"`
+	// TargetCompatible is whether the plugin supports the target platform version, only set if a target is given
+	TargetCompatible *bool  `json:"target_compatible,omitempty"`
+	TargetReason     string `json:"target_reason,omitempty"`
+}
+
+type PluginCompatibilityResponse struct {
+	PlatformVersion       string                `json:"platform_version"`
+	TargetPlatformVersion string                `json:"target_platform_version,omitempty"`
+	Plugins               []PluginCompatibility `json:"plugins"`
+}
+
+type PlatformUpgradeCheckResponse struct {
+	PlatformVersion       string `json:"platform_version"`
+	TargetPlatformVersion string `json:"target_platform_version"`
+	// Safe is true if no installed plugin becomes incompatible with the upgrade
+	Safe         bool                  `json:"safe"`
+	Incompatible []PluginCompatibility `json:"incompatible"`
+}
+
+type ExportPluginDemoDataRequest struct {
+	// IntegrationIDs to export, all non demo integrations of the plugin are exported if empty
+	IntegrationIDs []string `json:"integration_ids"`
+	// Anonymize replaces account ids, names and integration ids in the exported dataset
+	Anonymize bool `json:"anonymize"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+type CredentialOrgSync struct {
+	CredentialID    string            `json:"credential_id"`
+	IntegrationType string            `json:"integration_type"`
+	Enabled         bool              `json:"enabled"`
+	IntervalMinutes int               `json:"interval_minutes"`
+	DefaultLabels   map[string]string `json:"default_labels"`
+	FrameworkIDs    []string          `json:"framework_ids"`
+	ArchiveMissing  bool              `json:"archive_missing"`
+	LastSyncAt      *time.Time        `json:"last_sync_at,omitempty"`
+	NextSyncAt      *time.Time        `json:"next_sync_at,omitempty"`
+	LastReport      *OrgSyncReport    `json:"last_report,omitempty"`
+}
+
+type UpdateCredentialOrgSyncRequest struct {
+	Enabled bool `json:"enabled"`
+	// IntervalMinutes is the sync interval, the configured default is used if zero
+	IntervalMinutes int               `json:"interval_minutes"`
+	DefaultLabels   map[string]string `json:"default_labels"`
+	FrameworkIDs    []string          `json:"framework_ids"`
+	// ArchiveMissing archives integrations no longer discovered with the credential, defaults to true
+	ArchiveMissing *bool `json:"archive_missing"`
+}
+
+type OrgSyncReportIntegration struct {
+	IntegrationID string `json:"integration_id"`
+	ProviderID    string `json:"provider_id"`
+	Name          string `json:"name"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+type OrgSyncReport struct {
+	ID           uint                       `json:"id"`
+	CredentialID string                     `json:"credential_id"`
+	Trigger      string                     `json:"trigger" enums:"MANUAL,SCHEDULED"`
+	Status       string                     `json:"status" enums:"SUCCEEDED,FAILED"`
+	Error        string                     `json:"error,omitempty"`
+	StartedAt    time.Time                  `json:"started_at"`
+	FinishedAt   time.Time                  `json:"finished_at"`
+	Discovered   int                        `json:"discovered"`
+	Unchanged    int                        `json:"unchanged"`
+	Added        []OrgSyncReportIntegration `json:"added"`
+	Restored     []OrgSyncReportIntegration `json:"restored"`
+	Archived     []OrgSyncReportIntegration `json:"archived"`
+	Failed       []OrgSyncReportIntegration `json:"failed"`
+}
+
+type ListOrgSyncReportsResponse struct {
+	Reports []OrgSyncReport `json:"reports"`
+}
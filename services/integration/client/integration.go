@@ -1,11 +1,13 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/labstack/echo/v4"
 	authApi "github.com/opengovern/og-util/pkg/api"
 	"github.com/opengovern/og-util/pkg/httpclient"
+	"github.com/opengovern/og-util/pkg/httpserver"
 	"github.com/opengovern/og-util/pkg/integration/interfaces"
 	"github.com/opengovern/opensecurity/services/integration/api/models"
 	"net/http"
@@ -22,6 +24,7 @@ type IntegrationServiceClient interface {
 	ListIntegrationsByFilters(ctx *httpclient.Context, req models.ListIntegrationsRequest) (*models.ListIntegrationsResponse, error)
 	IntegrationHealthcheck(ctx *httpclient.Context, integrationID string) (*models.Integration, error)
 	GetCredential(ctx *httpclient.Context, credentialID string) (*models.Credential, error)
+	ResolveCredential(ctx *httpclient.Context, credentialID string, purpose string) (*models.Credential, error)
 	ListCredentials(ctx *httpclient.Context) (*models.ListCredentialsResponse, error)
 	GetIntegrationGroup(ctx *httpclient.Context, integrationGroupName string) (*models.IntegrationGroup, error)
 	ListIntegrationGroups(ctx *httpclient.Context) ([]models.IntegrationGroup, error)
@@ -32,6 +35,32 @@ type IntegrationServiceClient interface {
 	ListPlugins(ctx *httpclient.Context) (*models.IntegrationPluginListResponse, error)
 }
 
+type scopeContextKey struct{}
+
+// WithUserConnectionsScope returns a context carrying the integration scope of the caller, requests made with it
+// forward the scope so the integration service only returns the integrations the caller has access to.
+func WithUserConnectionsScope(ctx *httpclient.Context, scope string) *httpclient.Context {
+	parent := ctx.Ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	return &httpclient.Context{
+		Ctx:      context.WithValue(parent, scopeContextKey{}, scope),
+		UserRole: ctx.UserRole,
+		UserID:   ctx.UserID,
+	}
+}
+
+func requestHeaders(ctx *httpclient.Context) map[string]string {
+	headers := ctx.ToHeaders()
+	if ctx.Ctx != nil {
+		if scope, ok := ctx.Ctx.Value(scopeContextKey{}).(string); ok && scope != "" {
+			headers[httpserver.XPlatformUserConnectionsScope] = scope
+		}
+	}
+	return headers
+}
+
 type integrationClient struct {
 	baseURL string
 }
@@ -44,7 +73,7 @@ func (c *integrationClient) ListIntegrationTypes(ctx *httpclient.Context) ([]str
 	url := fmt.Sprintf("%s/api/v1/integration-types", c.baseURL)
 	var response []string
 
-	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, ctx.ToHeaders(), nil, &response); err != nil {
+	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, requestHeaders(ctx), nil, &response); err != nil {
 		if 400 <= statusCode && statusCode < 500 {
 			return nil, echo.NewHTTPError(statusCode, err.Error())
 		}
@@ -57,7 +86,7 @@ func (c *integrationClient) GetResourceTypeFromTableName(ctx *httpclient.Context
 	url := fmt.Sprintf("%s/api/v1/integration-types/%s/resource-type/table/%s", c.baseURL, integrationType, tableName)
 	var response models.GetResourceTypeFromTableNameResponse
 
-	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, ctx.ToHeaders(), nil, &response); err != nil {
+	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, requestHeaders(ctx), nil, &response); err != nil {
 		if 400 <= statusCode && statusCode < 500 {
 			return "", echo.NewHTTPError(statusCode, err.Error())
 		}
@@ -81,7 +110,7 @@ func (c *integrationClient) GetResourceTypesByLabels(ctx *httpclient.Context, in
 
 	var response models.GetResourceTypesByLabelsResponse
 
-	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodPost, url, ctx.ToHeaders(), payload, &response); err != nil {
+	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodPost, url, requestHeaders(ctx), payload, &response); err != nil {
 		if 400 <= statusCode && statusCode < 500 {
 			return nil, echo.NewHTTPError(statusCode, err.Error())
 		}
@@ -94,7 +123,7 @@ func (c *integrationClient) GetIntegrationTypeTables(ctx *httpclient.Context, in
 	url := fmt.Sprintf("%s/api/v1/integration-types/%s/table", c.baseURL, integrationType)
 	var response models.ListTablesResponse
 
-	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, ctx.ToHeaders(), nil, &response); err != nil {
+	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, requestHeaders(ctx), nil, &response); err != nil {
 		if 400 <= statusCode && statusCode < 500 {
 			return nil, echo.NewHTTPError(statusCode, err.Error())
 		}
@@ -107,7 +136,7 @@ func (c *integrationClient) GetIntegrationConfiguration(ctx *httpclient.Context,
 	url := fmt.Sprintf("%s/api/v1/integration-types/%s/configuration", c.baseURL, integrationType)
 	var response models.IntegrationTypeConfiguration
 
-	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, ctx.ToHeaders(), nil, &response); err != nil {
+	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, requestHeaders(ctx), nil, &response); err != nil {
 		if 400 <= statusCode && statusCode < 500 {
 			return response, echo.NewHTTPError(statusCode, err.Error())
 		}
@@ -120,7 +149,7 @@ func (c *integrationClient) GetIntegration(ctx *httpclient.Context, integrationI
 	url := fmt.Sprintf("%s/api/v1/integrations/%s", c.baseURL, integrationID)
 	var response *models.Integration
 
-	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, ctx.ToHeaders(), nil, &response); err != nil {
+	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, requestHeaders(ctx), nil, &response); err != nil {
 		if 400 <= statusCode && statusCode < 500 {
 			return nil, echo.NewHTTPError(statusCode, err.Error())
 		}
@@ -142,7 +171,7 @@ func (c *integrationClient) ListIntegrations(ctx *httpclient.Context, integratio
 	}
 
 	var response models.ListIntegrationsResponse
-	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, ctx.ToHeaders(), nil, &response); err != nil {
+	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, requestHeaders(ctx), nil, &response); err != nil {
 		if 400 <= statusCode && statusCode < 500 {
 			return nil, echo.NewHTTPError(statusCode, err.Error())
 		}
@@ -161,7 +190,7 @@ func (c *integrationClient) ListIntegrationsByFilters(ctx *httpclient.Context, r
 	}
 
 	var response models.ListIntegrationsResponse
-	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodPost, url, ctx.ToHeaders(), payload, &response); err != nil {
+	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodPost, url, requestHeaders(ctx), payload, &response); err != nil {
 		if 400 <= statusCode && statusCode < 500 {
 			return nil, echo.NewHTTPError(statusCode, err.Error())
 		}
@@ -174,7 +203,21 @@ func (c *integrationClient) GetCredential(ctx *httpclient.Context, credentialID
 	url := fmt.Sprintf("%s/api/v1/credentials/%s", c.baseURL, credentialID)
 	var response *models.Credential
 
-	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, ctx.ToHeaders(), nil, &response); err != nil {
+	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, requestHeaders(ctx), nil, &response); err != nil {
+		if 400 <= statusCode && statusCode < 500 {
+			return nil, echo.NewHTTPError(statusCode, err.Error())
+		}
+		return nil, err
+	}
+	return response, nil
+}
+
+// ResolveCredential returns the credential with its secret encrypted, secrets kept in external backends are resolved for the purpose
+func (c *integrationClient) ResolveCredential(ctx *httpclient.Context, credentialID string, purpose string) (*models.Credential, error) {
+	url := fmt.Sprintf("%s/api/v1/credentials/%s?purpose=%s", c.baseURL, credentialID, purpose)
+	var response *models.Credential
+
+	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, requestHeaders(ctx), nil, &response); err != nil {
 		if 400 <= statusCode && statusCode < 500 {
 			return nil, echo.NewHTTPError(statusCode, err.Error())
 		}
@@ -187,7 +230,7 @@ func (c *integrationClient) ListCredentials(ctx *httpclient.Context) (*models.Li
 	url := fmt.Sprintf("%s/api/v1/credentials", c.baseURL)
 	var response models.ListCredentialsResponse
 
-	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, ctx.ToHeaders(), nil, &response); err != nil {
+	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, requestHeaders(ctx), nil, &response); err != nil {
 		if 400 <= statusCode && statusCode < 500 {
 			return nil, echo.NewHTTPError(statusCode, err.Error())
 		}
@@ -200,7 +243,7 @@ func (c *integrationClient) IntegrationHealthcheck(ctx *httpclient.Context, inte
 	url := fmt.Sprintf("%s/api/v1/integrations/%s/healthcheck", c.baseURL, integrationID)
 	var response *models.Integration
 
-	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodPut, url, ctx.ToHeaders(), nil, &response); err != nil {
+	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodPut, url, requestHeaders(ctx), nil, &response); err != nil {
 		if 400 <= statusCode && statusCode < 500 {
 			return nil, echo.NewHTTPError(statusCode, err.Error())
 		}
@@ -213,7 +256,7 @@ func (c *integrationClient) GetIntegrationGroup(ctx *httpclient.Context, integra
 	url := fmt.Sprintf("%s/api/v1/integrations/integration-groups/%s", c.baseURL, integrationGroupName)
 
 	var integrationGroup models.IntegrationGroup
-	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, ctx.ToHeaders(), nil, &integrationGroup); err != nil {
+	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, requestHeaders(ctx), nil, &integrationGroup); err != nil {
 		if 400 <= statusCode && statusCode < 500 {
 			return nil, echo.NewHTTPError(statusCode, err.Error())
 		}
@@ -227,7 +270,7 @@ func (c *integrationClient) ListIntegrationGroups(ctx *httpclient.Context) ([]mo
 	url := fmt.Sprintf("%s/api/v1/integrations/integration-groups", c.baseURL)
 
 	var integrationGroup []models.IntegrationGroup
-	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, ctx.ToHeaders(), nil, &integrationGroup); err != nil {
+	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, requestHeaders(ctx), nil, &integrationGroup); err != nil {
 		if 400 <= statusCode && statusCode < 500 {
 			return nil, echo.NewHTTPError(statusCode, err.Error())
 		}
@@ -244,7 +287,7 @@ func (c *integrationClient) PurgeSampleData(ctx *httpclient.Context) ([]string,
 		Integrations []string `json:"integrations"`
 	}
 
-	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodPut, url, ctx.ToHeaders(), nil, &resp); err != nil {
+	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodPut, url, requestHeaders(ctx), nil, &resp); err != nil {
 		if 400 <= statusCode && statusCode < 500 {
 			return nil, echo.NewHTTPError(statusCode, err.Error())
 		}
@@ -258,7 +301,7 @@ func (c *integrationClient) GetPluginsTables(ctx *httpclient.Context) ([]models.
 	url := fmt.Sprintf("%s/api/v1/integration-types/plugin/tables", c.baseURL)
 	var response []models.PluginTables
 
-	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, ctx.ToHeaders(), nil, &response); err != nil {
+	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, requestHeaders(ctx), nil, &response); err != nil {
 		if 400 <= statusCode && statusCode < 500 {
 			return nil, echo.NewHTTPError(statusCode, err.Error())
 		}
@@ -271,7 +314,7 @@ func (c *integrationClient) ListIntegrationTypeResourceTypes(ctx *httpclient.Con
 	url := fmt.Sprintf("%s/api/v1/integrations/types/%s/resource_types", c.baseURL, integrationType)
 	var response models.ListIntegrationTypeResourceTypesResponse
 
-	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, ctx.ToHeaders(), nil, &response); err != nil {
+	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, requestHeaders(ctx), nil, &response); err != nil {
 		if 400 <= statusCode && statusCode < 500 {
 			return nil, echo.NewHTTPError(statusCode, err.Error())
 		}
@@ -285,7 +328,7 @@ func (c *integrationClient) GetIntegrationTypeResourceType(ctx *httpclient.Conte
 	url := fmt.Sprintf("%s/api/v1/integrations/types/%s/resource_types/%s", c.baseURL, integrationType, resourceType)
 	var response models.ResourceTypeConfiguration
 
-	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, ctx.ToHeaders(), nil, &response); err != nil {
+	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, requestHeaders(ctx), nil, &response); err != nil {
 		if 400 <= statusCode && statusCode < 500 {
 			return nil, echo.NewHTTPError(statusCode, err.Error())
 		}
@@ -299,7 +342,7 @@ func (c *integrationClient) ListPlugins(ctx *httpclient.Context) (*models.Integr
 	url := fmt.Sprintf("%s/api/v1/integration-types/plugin", c.baseURL)
 	var response models.IntegrationPluginListResponse
 
-	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, ctx.ToHeaders(), nil, &response); err != nil {
+	if statusCode, err := httpclient.DoRequest(ctx.Ctx, http.MethodGet, url, requestHeaders(ctx), nil, &response); err != nil {
 		if 400 <= statusCode && statusCode < 500 {
 			return nil, echo.NewHTTPError(statusCode, err.Error())
 		}
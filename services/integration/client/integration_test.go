@@ -0,0 +1,21 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/opengovern/og-util/pkg/api"
+	"github.com/opengovern/og-util/pkg/httpclient"
+	"github.com/opengovern/og-util/pkg/httpserver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestHeadersForwardScope(t *testing.T) {
+	ctx := &httpclient.Context{UserRole: api.EditorRole, UserID: "user-1"}
+	headers := requestHeaders(ctx)
+	assert.Equal(t, string(api.EditorRole), headers[httpserver.XPlatformUserRoleHeader])
+	assert.NotContains(t, headers, httpserver.XPlatformUserConnectionsScope)
+
+	headers = requestHeaders(WithUserConnectionsScope(ctx, "a,b"))
+	assert.Equal(t, "user-1", headers[httpserver.XPlatformUserIDHeader])
+	assert.Equal(t, "a,b", headers[httpserver.XPlatformUserConnectionsScope])
+}
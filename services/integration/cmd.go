@@ -14,11 +14,14 @@ import (
 	"github.com/opengovern/og-util/pkg/steampipe"
 	"github.com/opengovern/og-util/pkg/vault"
 	"github.com/opengovern/opensecurity/pkg/utils"
+	complianceClient "github.com/opengovern/opensecurity/services/compliance/client"
 	core "github.com/opengovern/opensecurity/services/core/client"
 	"github.com/opengovern/opensecurity/services/integration/api"
 	"github.com/opengovern/opensecurity/services/integration/config"
 	"github.com/opengovern/opensecurity/services/integration/db"
 	integration_type "github.com/opengovern/opensecurity/services/integration/integration-type"
+	"github.com/opengovern/opensecurity/services/integration/secrets"
+	utils2 "github.com/opengovern/opensecurity/services/integration/utils"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 	appsv1 "k8s.io/api/apps/v1"
@@ -111,6 +114,7 @@ func Command() *cobra.Command {
 			}
 
 			coreClient := core.NewCoreServiceClient(cnf.Core.BaseURL)
+			complianceServiceClient := complianceClient.NewComplianceClient(cnf.Compliance.BaseURL)
 
 			_, err = coreClient.VaultConfigured(&httpclient.Context{UserRole: api3.AdminRole})
 			if err != nil && errors.Is(err, core.ErrConfigNotFound) {
@@ -150,7 +154,19 @@ func Command() *cobra.Command {
 				}
 			}
 
-			typeManager := integration_type.NewIntegrationTypeManager(logger, db, integrationTypesDb, kubeClient, clientset, metricsClient, cnf.IntegrationPlugins)
+			pluginVerifier, err := utils2.NewPluginVerifier(cnf.IntegrationPlugins.Verification)
+			if err != nil {
+				logger.Error("failed to create plugin verifier", zap.Error(err))
+				return err
+			}
+
+			typeManager := integration_type.NewIntegrationTypeManager(logger, db, integrationTypesDb, kubeClient, clientset, metricsClient, cnf.IntegrationPlugins, pluginVerifier)
+
+			secretResolver, err := secrets.NewResolver(logger, vaultSc, db, kubeClient, cnf.SecretBackends)
+			if err != nil {
+				logger.Error("failed to create secret resolver", zap.Error(err))
+				return err
+			}
 
 			cmd.SilenceUsage = true
 
@@ -180,7 +196,7 @@ func Command() *cobra.Command {
 				cmd.Context(),
 				logger,
 				cnf.Http.Address,
-				api.New(logger, db, vaultSc, &steampipeOption, kubeClient, typeManager, elastic, coreClient, elasticConfig),
+				api.New(logger, db, vaultSc, &steampipeOption, kubeClient, typeManager, elastic, coreClient, complianceServiceClient, elasticConfig, cnf.HealthCheck, cnf.Credentials, cnf.OrgSync, secretResolver, pluginVerifier),
 			)
 		},
 	}
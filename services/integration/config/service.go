@@ -5,17 +5,83 @@ import (
 	"github.com/opengovern/og-util/pkg/vault"
 )
 
+type PluginVerificationConfig struct {
+	// RequireSignature rejects plugins which are not signed by one of the trusted keys
+	RequireSignature bool `json:"require_signature" koanf:"require_signature"`
+	// TrustedKeys are minisign public keys, cosign PEM public keys or armored GPG public keys
+	TrustedKeys []string `json:"trusted_keys" koanf:"trusted_keys"`
+	// TrustedKeysPath is a directory of trusted key files, like a mounted secret
+	TrustedKeysPath string `json:"trusted_keys_path" koanf:"trusted_keys_path"`
+}
+
 type IntegrationPluginsConfig struct {
-	PingIntervalSeconds  int `json:"ping_interval_seconds" koanf:"ping_interval_seconds"`
-	MaxAutoRebootRetries int `json:"max_auto_reboot_retries" koanf:"max_auto_reboot_retries"`
+	PingIntervalSeconds  int                      `json:"ping_interval_seconds" koanf:"ping_interval_seconds"`
+	MaxAutoRebootRetries int                      `json:"max_auto_reboot_retries" koanf:"max_auto_reboot_retries"`
+	Verification         PluginVerificationConfig `json:"verification" koanf:"verification"`
+	// DescriberRegistry replaces the registry of the describer images, e.g. registry.internal:5000/opengovern
+	DescriberRegistry string `json:"describer_registry" koanf:"describer_registry"`
+	// DescriberImagePullSecrets are the secrets used to pull describer images from the private registry
+	DescriberImagePullSecrets []string `json:"describer_image_pull_secrets" koanf:"describer_image_pull_secrets"`
+}
+
+type HealthCheckConfig struct {
+	// IntervalMinutes is the default cadence of the scheduled health checks
+	IntervalMinutes int `json:"interval_minutes" koanf:"interval_minutes"`
+	// IntegrationTypeIntervalMinutes overrides the cadence per integration type
+	IntegrationTypeIntervalMinutes map[string]int `json:"integration_type_interval_minutes" koanf:"integration_type_interval_minutes"`
+	// FailureThreshold is the number of consecutive failed checks after which an integration is marked inactive
+	FailureThreshold int `json:"failure_threshold" koanf:"failure_threshold"`
+	// FlappingThreshold is the number of healthy/unhealthy transitions in the flapping window to consider an integration flapping
+	FlappingThreshold   int `json:"flapping_threshold" koanf:"flapping_threshold"`
+	FlappingWindowHours int `json:"flapping_window_hours" koanf:"flapping_window_hours"`
+	// HistoryRetentionDays is how long check results are kept
+	HistoryRetentionDays int `json:"history_retention_days" koanf:"history_retention_days"`
+}
+
+type CredentialsConfig struct {
+	// ExpiryWarningDays is how many days before expiry a credential is reported as expiring
+	ExpiryWarningDays int `json:"expiry_warning_days" koanf:"expiry_warning_days"`
+}
+
+type OrgSyncConfig struct {
+	// IntervalMinutes is the default cadence of the org sync of a credential
+	IntervalMinutes int `json:"interval_minutes" koanf:"interval_minutes"`
+	// DefaultLabels are added to every integration created by an org sync
+	DefaultLabels map[string]string `json:"default_labels" koanf:"default_labels"`
+	// DefaultFrameworks are assigned to every integration created by an org sync
+	DefaultFrameworks []string `json:"default_frameworks" koanf:"default_frameworks"`
+	// ReportRetentionDays is how long sync reports are kept
+	ReportRetentionDays int `json:"report_retention_days" koanf:"report_retention_days"`
+}
+
+type HashicorpVaultSecretBackendConfig struct {
+	Address string `json:"address" koanf:"address"`
+	Token   string `json:"token" koanf:"token"`
+	// Namespace is the vault enterprise namespace
+	Namespace string `json:"namespace" koanf:"namespace"`
+}
+
+type SecretBackendsConfig struct {
+	// CacheTTLSeconds is how long a resolved secret reference is reused before it is read again
+	CacheTTLSeconds int                               `json:"cache_ttl_seconds" koanf:"cache_ttl_seconds"`
+	HashicorpVault  HashicorpVaultSecretBackendConfig `json:"hashicorp_vault" koanf:"hashicorp_vault"`
+	// KubernetesNamespace is the only namespace kubernetes secret references can read from, defaults to the current namespace
+	KubernetesNamespace string `json:"kubernetes_namespace" koanf:"kubernetes_namespace"`
+	// FileRootPath enables file secret references, they are paths relative to it and cannot leave it
+	FileRootPath string `json:"file_root_path" koanf:"file_root_path"`
 }
 
 type IntegrationConfig struct {
-	Postgres  koanf.Postgres              `json:"postgres,omitempty" koanf:"postgres"`
-	Steampipe koanf.Postgres              `json:"steampipe,omitempty" koanf:"steampipe"`
-	Http      koanf.HttpServer            `json:"http,omitempty" koanf:"http"`
-	Vault     vault.Config                `json:"vault,omitempty" koanf:"vault"`
-	Core      koanf.OpenGovernanceService `json:"core,omitempty" koanf:"core"`
+	Postgres   koanf.Postgres              `json:"postgres,omitempty" koanf:"postgres"`
+	Steampipe  koanf.Postgres              `json:"steampipe,omitempty" koanf:"steampipe"`
+	Http       koanf.HttpServer            `json:"http,omitempty" koanf:"http"`
+	Vault      vault.Config                `json:"vault,omitempty" koanf:"vault"`
+	Core       koanf.OpenGovernanceService `json:"core,omitempty" koanf:"core"`
+	Compliance koanf.OpenGovernanceService `json:"compliance,omitempty" koanf:"compliance"`
 
 	IntegrationPlugins IntegrationPluginsConfig `json:"integration_plugins,omitempty" koanf:"integration_plugins"`
+	HealthCheck        HealthCheckConfig        `json:"health_check,omitempty" koanf:"health_check"`
+	Credentials        CredentialsConfig        `json:"credentials,omitempty" koanf:"credentials"`
+	SecretBackends     SecretBackendsConfig     `json:"secret_backends,omitempty" koanf:"secret_backends"`
+	OrgSync            OrgSyncConfig            `json:"org_sync,omitempty" koanf:"org_sync"`
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/jackc/pgtype"
 	"github.com/opengovern/opensecurity/services/integration/models"
 	"gorm.io/gorm/clause"
+	"time"
 )
 
 // CreateCredential creates a new credential
@@ -117,3 +118,123 @@ func (db Database) UpdateCredentialIntegrationCount(id string, count int) error
 	return nil
 }
 
+
+// SetCredentialExpiry sets the expiry of a credential, a nil expiresAt clears it
+func (db Database) SetCredentialExpiry(id string, expiresAt *time.Time, source models.CredentialExpirySource) error {
+	tx := db.Orm.
+		Model(&models.Credential{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"expires_at":    expiresAt,
+			"expiry_source": source,
+		})
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+// CredentialUpdate lists the changes made to a credential, nil fields are left unchanged
+type CredentialUpdate struct {
+	Description     *string
+	Secret          *string
+	MaskedSecret    map[string]any
+	SecretReference *string
+	Expiry          *CredentialExpiry
+	RotatedAt       *time.Time
+}
+
+// CredentialExpiry is the expiry set by a credential update, a nil ExpiresAt clears it
+type CredentialExpiry struct {
+	ExpiresAt *time.Time
+	Source    models.CredentialExpirySource
+}
+
+// UpdateCredentialFields applies all the changes of an update to a credential at once
+func (db Database) UpdateCredentialFields(id string, update CredentialUpdate) error {
+	fields := make(map[string]any)
+	if update.Description != nil {
+		fields["description"] = *update.Description
+	}
+	if update.Secret != nil {
+		maskedSecretJsonData, err := json.Marshal(update.MaskedSecret)
+		if err != nil {
+			return err
+		}
+		maskedSecretJsonb := pgtype.JSONB{}
+		if err = maskedSecretJsonb.Set(maskedSecretJsonData); err != nil {
+			return err
+		}
+		fields["secret"] = *update.Secret
+		fields["masked_secret"] = maskedSecretJsonb
+	}
+	if update.SecretReference != nil {
+		fields["secret_reference"] = *update.SecretReference
+	}
+	if update.Expiry != nil {
+		fields["expires_at"] = update.Expiry.ExpiresAt
+		fields["expiry_source"] = update.Expiry.Source
+	}
+	if update.RotatedAt != nil {
+		fields["rotated_at"] = *update.RotatedAt
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	// A single statement, a failing update leaves the credential as it was
+	tx := db.Orm.
+		Model(&models.Credential{}).
+		Where("id = ?", id).
+		Updates(fields)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+// ListCredentialsExpiringBefore lists the credentials with an expiry before the given time, including expired ones
+func (db Database) ListCredentialsExpiringBefore(before time.Time) ([]models.Credential, error) {
+	var credentials []models.Credential
+	tx := db.Orm.
+		Model(&models.Credential{}).
+		Where("expires_at IS NOT NULL").
+		Where("expires_at <= ?", before).
+		Order("expires_at ASC").
+		Find(&credentials)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return credentials, nil
+}
+
+// CreateCredentialSecretResolution records the resolution of a credential secret reference
+func (db Database) CreateCredentialSecretResolution(resolution *models.CredentialSecretResolution) error {
+	tx := db.Orm.
+		Model(&models.CredentialSecretResolution{}).
+		Create(resolution)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+// ListCredentialSecretResolutions list the latest resolutions of a credential secret reference
+func (db Database) ListCredentialSecretResolutions(credentialID string, limit int) ([]models.CredentialSecretResolution, error) {
+	var resolutions []models.CredentialSecretResolution
+	tx := db.Orm.
+		Model(&models.CredentialSecretResolution{}).
+		Where("credential_id = ?", credentialID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&resolutions)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return resolutions, nil
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
@@ -8,6 +9,7 @@ import (
 	"github.com/opengovern/opensecurity/services/integration/models"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"time"
 )
 
 // CreateIntegration creates a new integration
@@ -99,7 +101,7 @@ func (db Database) ListIntegration(types []integration.Type) ([]models.Integrati
 }
 
 // ListIntegrationsByFilters list Integrations by filters
-func (db Database) ListIntegrationsByFilters(IntegrationIDs []string, types []string, NameRegex, providerIDRegex *string) ([]models.Integration, error) {
+func (db Database) ListIntegrationsByFilters(IntegrationIDs []string, types []string, NameRegex, providerIDRegex *string, labels map[string]string) ([]models.Integration, error) {
 	var integrations []models.Integration
 	tx := db.Orm.
 		Model(&models.Integration{})
@@ -116,6 +118,13 @@ func (db Database) ListIntegrationsByFilters(IntegrationIDs []string, types []st
 	if providerIDRegex != nil {
 		tx = tx.Where("provider_id ~* ?", *providerIDRegex)
 	}
+	if len(labels) > 0 {
+		labelsJson, err := json.Marshal(labels)
+		if err != nil {
+			return nil, err
+		}
+		tx = tx.Where("labels @> ?::jsonb", string(labelsJson))
+	}
 
 	tx = tx.Find(&integrations)
 	if tx.Error != nil {
@@ -151,6 +160,30 @@ func (db Database) UpdateIntegration(integration *models.Integration) error {
 	return nil
 }
 
+// UpdateIntegrationHealth stores a health check result in the health columns of the integration, only if its state
+// is still the one the check started from. The reason annotation is set on failure and removed when reason is empty,
+// other annotations are left untouched. It returns false when the state changed in between.
+func (db Database) UpdateIntegrationHealth(integrationID uuid.UUID, fromState, state integration.IntegrationState,
+	lastCheck time.Time, reasonAnnotation, reason string) (bool, error) {
+	annotations := gorm.Expr("COALESCE(annotations, '{}'::jsonb) - ?::text", reasonAnnotation)
+	if reason != "" {
+		annotations = gorm.Expr("COALESCE(annotations, '{}'::jsonb) || jsonb_build_object(?::text, ?::text)", reasonAnnotation, reason)
+	}
+	tx := db.Orm.
+		Model(&models.Integration{}).
+		Where("integration_id = ? AND state = ?", integrationID.String(), fromState).
+		Updates(map[string]any{
+			"state":       state,
+			"last_check":  lastCheck,
+			"annotations": annotations,
+		})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+
+	return tx.RowsAffected > 0, nil
+}
+
 // InactiveIntegrationType inactive integrations for an integration type
 func (db Database) InactiveIntegrationType(it integration.Type) error {
 	tx := db.Orm.
@@ -194,3 +227,67 @@ func (db Database) SetIntegrationResourcetypes(integration *models.IntegrationRe
 	}
 	return nil
 }
+
+// CreateIntegrationHealthCheck stores the result of an integration health check
+func (db Database) CreateIntegrationHealthCheck(check *models.IntegrationHealthCheck) error {
+	tx := db.Orm.
+		Model(&models.IntegrationHealthCheck{}).
+		Create(check)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+// ListIntegrationHealthChecks lists the health checks of an integration in the given time range, newest first
+func (db Database) ListIntegrationHealthChecks(integrationID uuid.UUID, from, to *time.Time, limit int) ([]models.IntegrationHealthCheck, error) {
+	var checks []models.IntegrationHealthCheck
+	tx := db.Orm.
+		Model(&models.IntegrationHealthCheck{}).
+		Where("integration_id = ?", integrationID)
+	if from != nil {
+		tx = tx.Where("checked_at >= ?", *from)
+	}
+	if to != nil {
+		tx = tx.Where("checked_at <= ?", *to)
+	}
+	tx = tx.Order("checked_at DESC")
+	if limit > 0 {
+		tx = tx.Limit(limit)
+	}
+
+	tx = tx.Find(&checks)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return checks, nil
+}
+
+// DeleteIntegrationHealthChecksBefore removes health checks older than the given time
+func (db Database) DeleteIntegrationHealthChecksBefore(before time.Time) error {
+	tx := db.Orm.
+		Where("checked_at < ?", before).
+		Unscoped().
+		Delete(&models.IntegrationHealthCheck{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+// ListIntegrationsByCredentialID lists the integrations using a credential
+func (db Database) ListIntegrationsByCredentialID(credentialID string) ([]models.Integration, error) {
+	var integrations []models.Integration
+	tx := db.Orm.
+		Model(&models.Integration{}).
+		Where("credential_id = ?", credentialID).
+		Find(&integrations)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return integrations, nil
+}
@@ -1,15 +1,38 @@
 package db
 
 import (
+	"fmt"
 	"github.com/opengovern/opensecurity/services/integration/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
-// DeleteIntegrationGroup deletes an Integration Group
-func (db Database) DeleteIntegrationGroup(name string) error {
+// CreateIntegrationGroup creates a new Integration Group
+func (db Database) CreateIntegrationGroup(integrationGroup *models.IntegrationGroup) error {
+	tx := db.Orm.
+		Model(&models.IntegrationGroup{}).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(integrationGroup)
+	if tx.Error != nil {
+		return tx.Error
+	} else if tx.RowsAffected == 0 {
+		return fmt.Errorf("create integration group: group %s already exists", integrationGroup.Name)
+	}
+
+	return nil
+}
+
+// UpdateIntegrationGroup updates the query and description of an Integration Group
+func (db Database) UpdateIntegrationGroup(name, query, description string) error {
 	tx := db.Orm.
+		Model(&models.IntegrationGroup{}).
 		Where("name = ?", name).
-		Unscoped().
-		Delete(&models.IntegrationGroup{})
+		Updates(map[string]any{
+			"query":       query,
+			"description": description,
+			"updated_at":  time.Now(),
+		})
 	if tx.Error != nil {
 		return tx.Error
 	}
@@ -17,6 +40,23 @@ func (db Database) DeleteIntegrationGroup(name string) error {
 	return nil
 }
 
+// DeleteIntegrationGroup deletes an Integration Group along with its current members, the membership history is kept
+func (db Database) DeleteIntegrationGroup(name string) error {
+	return db.Orm.Transaction(func(tx *gorm.DB) error {
+		if err := tx.
+			Where("group_name = ?", name).
+			Unscoped().
+			Delete(&models.IntegrationGroupMember{}).Error; err != nil {
+			return err
+		}
+
+		return tx.
+			Where("name = ?", name).
+			Unscoped().
+			Delete(&models.IntegrationGroup{}).Error
+	})
+}
+
 // ListIntegrationGroups list Integration Groups
 func (db Database) ListIntegrationGroups() ([]models.IntegrationGroup, error) {
 	var integrationGroups []models.IntegrationGroup
@@ -43,3 +83,129 @@ func (db Database) GetIntegrationGroup(name string) (*models.IntegrationGroup, e
 
 	return &integrationGroup, nil
 }
+
+// ListIntegrationGroupMembers lists the last evaluated members of an Integration Group
+func (db Database) ListIntegrationGroupMembers(name string) ([]models.IntegrationGroupMember, error) {
+	var members []models.IntegrationGroupMember
+	tx := db.Orm.
+		Model(&models.IntegrationGroupMember{}).
+		Where("group_name = ?", name).
+		Find(&members)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return members, nil
+}
+
+// SyncIntegrationGroupMembers stores the given integrations as the members of the group and records
+// the integrations which entered or left the group. Returns the recorded membership events.
+func (db Database) SyncIntegrationGroupMembers(name string, integrationIDs []string) ([]models.IntegrationGroupMembershipEvent, error) {
+	var events []models.IntegrationGroupMembershipEvent
+	err := db.Orm.Transaction(func(tx *gorm.DB) error {
+		var members []models.IntegrationGroupMember
+		if err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("group_name = ?", name).
+			Find(&members).Error; err != nil {
+			return err
+		}
+
+		var added []models.IntegrationGroupMember
+		var removed []string
+		added, removed, events = integrationGroupMembershipChanges(name, members, integrationIDs, time.Now())
+
+		if len(added) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(added, 500).Error; err != nil {
+				return err
+			}
+		}
+		if len(removed) > 0 {
+			if err := tx.
+				Where("group_name = ?", name).
+				Where("integration_id IN ?", removed).
+				Unscoped().
+				Delete(&models.IntegrationGroupMember{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(events) > 0 {
+			if err := tx.CreateInBatches(events, 500).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// ListIntegrationGroupMembershipEvents lists the membership history of an Integration Group, newest first.
+// integrationIDs is optional and narrows the history to the given integrations.
+func (db Database) ListIntegrationGroupMembershipEvents(name string, integrationIDs []string, limit, offset int) ([]models.IntegrationGroupMembershipEvent, int64, error) {
+	query := db.Orm.
+		Model(&models.IntegrationGroupMembershipEvent{}).
+		Where("group_name = ?", name)
+	if len(integrationIDs) > 0 {
+		query = query.Where("integration_id IN ?", integrationIDs)
+	}
+
+	var totalCount int64
+	if err := query.Count(&totalCount).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var events []models.IntegrationGroupMembershipEvent
+	query = query.Order("created_at DESC").Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+	if err := query.Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return events, totalCount, nil
+}
+
+// integrationGroupMembershipChanges compares the current members of a group with the evaluated integrations
+// and returns the members to add, the integration ids to remove and the membership events of the changes
+func integrationGroupMembershipChanges(name string, members []models.IntegrationGroupMember, integrationIDs []string,
+	now time.Time) ([]models.IntegrationGroupMember, []string, []models.IntegrationGroupMembershipEvent) {
+	current := make(map[string]bool)
+	for _, member := range members {
+		current[member.IntegrationID] = true
+	}
+	evaluated := make(map[string]bool)
+	for _, id := range integrationIDs {
+		evaluated[id] = true
+	}
+
+	var added []models.IntegrationGroupMember
+	var removed []string
+	var events []models.IntegrationGroupMembershipEvent
+	for id := range evaluated {
+		if current[id] {
+			continue
+		}
+		added = append(added, models.IntegrationGroupMember{GroupName: name, IntegrationID: id, AddedAt: now})
+		events = append(events, models.IntegrationGroupMembershipEvent{
+			GroupName: name, IntegrationID: id, Action: models.IntegrationGroupMembershipActionAdded, CreatedAt: now,
+		})
+	}
+	for id := range current {
+		if evaluated[id] {
+			continue
+		}
+		removed = append(removed, id)
+		events = append(events, models.IntegrationGroupMembershipEvent{
+			GroupName: name, IntegrationID: id, Action: models.IntegrationGroupMembershipActionRemoved, CreatedAt: now,
+		})
+	}
+	return added, removed, events
+}
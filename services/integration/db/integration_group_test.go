@@ -0,0 +1,35 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/opengovern/opensecurity/services/integration/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntegrationGroupMembershipChanges(t *testing.T) {
+	now := time.Now()
+	members := []models.IntegrationGroupMember{
+		{GroupName: "production", IntegrationID: "kept"},
+		{GroupName: "production", IntegrationID: "left"},
+	}
+
+	added, removed, events := integrationGroupMembershipChanges("production", members, []string{"kept", "joined", "joined"}, now)
+	assert.Equal(t, []models.IntegrationGroupMember{{GroupName: "production", IntegrationID: "joined", AddedAt: now}}, added)
+	assert.Equal(t, []string{"left"}, removed)
+	assert.ElementsMatch(t, []models.IntegrationGroupMembershipEvent{
+		{GroupName: "production", IntegrationID: "joined", Action: models.IntegrationGroupMembershipActionAdded, CreatedAt: now},
+		{GroupName: "production", IntegrationID: "left", Action: models.IntegrationGroupMembershipActionRemoved, CreatedAt: now},
+	}, events)
+
+	added, removed, events = integrationGroupMembershipChanges("production", members, []string{"left", "kept"}, now)
+	assert.Empty(t, added)
+	assert.Empty(t, removed)
+	assert.Empty(t, events, "an unchanged membership records no events")
+
+	added, removed, events = integrationGroupMembershipChanges("production", members, nil, now)
+	assert.Empty(t, added)
+	assert.ElementsMatch(t, []string{"kept", "left"}, removed)
+	assert.Len(t, events, 2)
+}
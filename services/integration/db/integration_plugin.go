@@ -141,6 +141,12 @@ func (db Database) CreatePluginBinary(pluginBinary *models.IntegrationPluginBina
 	return nil
 }
 
+// RestorePlugin writes back every field of a plugin, including the zero values UpdatePlugin skips
+func (db Database) RestorePlugin(plugin *models.IntegrationPlugin) error {
+	return db.IntegrationTypeOrm.Model(models.IntegrationPlugin{}).Where("plugin_id = ?", plugin.PluginID).
+		Select("*").Omit("id").Updates(plugin).Error
+}
+
 func (db Database) UpdatePlugin(plugin *models.IntegrationPlugin) error {
 	err := db.IntegrationTypeOrm.Model(models.IntegrationPlugin{}).Where("plugin_id = ?", plugin.PluginID).Updates(plugin).Error
 	if err != nil {
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/opengovern/opensecurity/services/integration/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UpsertCredentialOrgSync creates or replaces the org sync settings of a credential
+func (db Database) UpsertCredentialOrgSync(orgSync *models.CredentialOrgSync) error {
+	tx := db.Orm.
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "credential_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"enabled", "interval_minutes", "default_labels", "framework_ids", "archive_missing", "updated_at"}),
+		}).
+		Create(orgSync)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+// GetCredentialOrgSync returns the org sync settings of a credential, nil if the credential has none
+func (db Database) GetCredentialOrgSync(credentialID uuid.UUID) (*models.CredentialOrgSync, error) {
+	var orgSync models.CredentialOrgSync
+	tx := db.Orm.
+		Model(&models.CredentialOrgSync{}).
+		Where("credential_id = ?", credentialID).
+		First(&orgSync)
+	if tx.Error != nil {
+		if errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, tx.Error
+	}
+
+	return &orgSync, nil
+}
+
+// ListCredentialOrgSyncs lists the org sync settings of the credentials, only the enabled ones if enabledOnly is set
+func (db Database) ListCredentialOrgSyncs(enabledOnly bool) ([]models.CredentialOrgSync, error) {
+	var orgSyncs []models.CredentialOrgSync
+	tx := db.Orm.
+		Model(&models.CredentialOrgSync{})
+	if enabledOnly {
+		tx = tx.Where("enabled = ?", true)
+	}
+	tx = tx.Find(&orgSyncs)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return orgSyncs, nil
+}
+
+// UpdateCredentialOrgSyncLastSync sets the last sync time of a credential org sync
+func (db Database) UpdateCredentialOrgSyncLastSync(credentialID uuid.UUID, lastSyncAt time.Time) error {
+	tx := db.Orm.
+		Model(&models.CredentialOrgSync{}).
+		Where("credential_id = ?", credentialID).
+		Update("last_sync_at", lastSyncAt)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+// DeleteCredentialOrgSync removes the org sync settings of a credential, the sync reports are kept
+func (db Database) DeleteCredentialOrgSync(credentialID uuid.UUID) error {
+	tx := db.Orm.
+		Where("credential_id = ?", credentialID).
+		Unscoped().
+		Delete(&models.CredentialOrgSync{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+// CreateCredentialOrgSyncReport stores the result of an org sync run
+func (db Database) CreateCredentialOrgSyncReport(report *models.CredentialOrgSyncReport) error {
+	tx := db.Orm.
+		Model(&models.CredentialOrgSyncReport{}).
+		Create(report)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+// ListCredentialOrgSyncReports lists the org sync reports of a credential, newest first
+func (db Database) ListCredentialOrgSyncReports(credentialID uuid.UUID, limit int) ([]models.CredentialOrgSyncReport, error) {
+	var reports []models.CredentialOrgSyncReport
+	tx := db.Orm.
+		Model(&models.CredentialOrgSyncReport{}).
+		Where("credential_id = ?", credentialID).
+		Order("started_at DESC")
+	if limit > 0 {
+		tx = tx.Limit(limit)
+	}
+	tx = tx.Find(&reports)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return reports, nil
+}
+
+// DeleteCredentialOrgSyncReportsBefore removes org sync reports older than the given time
+func (db Database) DeleteCredentialOrgSyncReportsBefore(before time.Time) error {
+	tx := db.Orm.
+		Where("started_at < ?", before).
+		Unscoped().
+		Delete(&models.CredentialOrgSyncReport{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
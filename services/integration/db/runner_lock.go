@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+const (
+	// HealthCheckRunnerLockID elects the replica running the scheduled integration health checks.
+	HealthCheckRunnerLockID = 7301
+	// IntegrationGroupMembershipRunnerLockID elects the replica evaluating the integration group memberships.
+	IntegrationGroupMembershipRunnerLockID = 7302
+	// OrgSyncRunnerLockID elects the replica running the scheduled credential org syncs.
+	OrgSyncRunnerLockID = 7303
+)
+
+// RunnerLock is a postgres session advisory lock electing a single replica to run a periodic job.
+// The lock is held on a dedicated connection, it is released when the connection drops.
+type RunnerLock struct {
+	db   Database
+	id   int64
+	conn *sql.Conn
+}
+
+func (db Database) NewRunnerLock(id int64) *RunnerLock {
+	return &RunnerLock{db: db, id: id}
+}
+
+// TryAcquire returns whether this replica holds the lock, taking it if it is free. It is not safe for concurrent use.
+func (l *RunnerLock) TryAcquire(ctx context.Context) (bool, error) {
+	if l.conn != nil {
+		if err := l.conn.PingContext(ctx); err == nil {
+			return true, nil
+		}
+		// The connection dropped, and the lock with it
+		_ = l.conn.Close()
+		l.conn = nil
+	}
+
+	sqlDB, err := l.db.Orm.DB()
+	if err != nil {
+		return false, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	var acquired bool
+	if err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.id).Scan(&acquired); err != nil || !acquired {
+		_ = conn.Close()
+		return false, err
+	}
+	l.conn = conn
+	return true, nil
+}
+
+// Release gives the lock up for another replica.
+func (l *RunnerLock) Release() {
+	if l.conn == nil {
+		return
+	}
+	_, _ = l.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", l.id)
+	_ = l.conn.Close()
+	l.conn = nil
+}
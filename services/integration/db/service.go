@@ -23,7 +23,13 @@ func (db Database) Initialize() error {
 		&models.Integration{},
 		&models.Credential{},
 		&models.IntegrationGroup{},
+		&models.IntegrationGroupMember{},
+		&models.IntegrationGroupMembershipEvent{},
 		&models.IntegrationResourcetypes{},
+		&models.IntegrationHealthCheck{},
+		&models.CredentialSecretResolution{},
+		&models.CredentialOrgSync{},
+		&models.CredentialOrgSyncReport{},
 	)
 	if err != nil {
 		return err
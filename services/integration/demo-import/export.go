@@ -0,0 +1,344 @@
+package demo_import
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
+	"github.com/opengovern/og-util/pkg/es"
+	models2 "github.com/opengovern/opensecurity/services/integration/models"
+	"go.uber.org/zap"
+)
+
+// maxDocumentSize is the largest dumped document line the anonymizer can rewrite
+const maxDocumentSize = 64 * 1024 * 1024
+
+// minReplacedLength is the shortest value rewritten in the dumped documents, shorter values are too likely
+// to match unrelated content of the documents
+const minReplacedLength = 3
+
+type ExportConfig struct {
+	OpenSSLPassword   string
+	ElasticsearchUser string
+	ElasticsearchPass string
+	ElasticsearchAddr string
+	// Anonymize replaces account ids, names and integration ids with stable pseudonyms across metadata and resources
+	Anonymize bool
+}
+
+// ExportDemoData dumps the resources of the given integrations and their metadata into an encrypted archive
+// with the same layout LoadDemoData expects. It returns the directory holding the export and the path of the
+// encrypted archive, the caller is responsible for removing the directory.
+func ExportDemoData(cfg ExportConfig, logger *zap.Logger, integrations []models2.Integration, resourceTypes []string) (string, string, error) {
+	logger.Info("Starting demo data export", zap.Int("integrations", len(integrations)), zap.Bool("anonymize", cfg.Anonymize))
+
+	workDir, err := os.MkdirTemp("", "demo-export-")
+	if err != nil {
+		logger.Error("Failed to create export directory", zap.Error(err))
+		return "", "", fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	archiveFilePath := filepath.Join(workDir, "demo_data.tar.gz")
+	encryptedFilePath := filepath.Join(workDir, "demo_data.tar.gz.enc")
+	outputPathForDump := filepath.Join(workDir, "/demo-data/es-demo/")
+	integrationsJsonFilePath := filepath.Join(workDir, "/demo-data/integrations.json")
+
+	if err = os.MkdirAll(outputPathForDump, os.ModePerm); err != nil {
+		logger.Error("Failed to create dump directory", zap.Error(err))
+		return workDir, "", fmt.Errorf("failed to create dump directory: %w", err)
+	}
+
+	// documents hold the original integration ids, they are collected before anonymizing
+	integrationIDs := make([]string, 0, len(integrations))
+	for _, i := range integrations {
+		integrationIDs = append(integrationIDs, i.IntegrationID.String())
+	}
+
+	var replacements []string
+	if cfg.Anonymize {
+		integrations, replacements, err = anonymizeIntegrations(integrations)
+		if err != nil {
+			logger.Error("Failed to anonymize integrations", zap.Error(err))
+			return workDir, "", fmt.Errorf("failed to anonymize integrations: %w", err)
+		}
+	}
+
+	// --- 1. Write integrations metadata ---
+	exported := make([]Integration, 0, len(integrations))
+	for _, i := range integrations {
+		annotations, err := jsonbToMap(i.Annotations)
+		if err != nil {
+			return workDir, "", fmt.Errorf("failed to read annotations of %s: %w", i.IntegrationID.String(), err)
+		}
+		labels, err := jsonbToMap(i.Labels)
+		if err != nil {
+			return workDir, "", fmt.Errorf("failed to read labels of %s: %w", i.IntegrationID.String(), err)
+		}
+		exported = append(exported, Integration{
+			IntegrationID:   i.IntegrationID.String(),
+			ProviderID:      i.ProviderID,
+			Name:            i.Name,
+			IntegrationType: i.IntegrationType.String(),
+			Annotations:     annotations,
+			Labels:          labels,
+		})
+	}
+	integrationsJson, err := json.MarshalIndent(exported, "", "  ")
+	if err != nil {
+		return workDir, "", fmt.Errorf("failed to marshal integrations: %w", err)
+	}
+	if err = os.WriteFile(integrationsJsonFilePath, integrationsJson, 0600); err != nil {
+		logger.Error("Failed to write integrations file", zap.String("path", integrationsJsonFilePath), zap.Error(err))
+		return workDir, "", fmt.Errorf("failed to write integrations file: %w", err)
+	}
+	logger.Info("Successfully wrote integrations metadata", zap.String("path", integrationsJsonFilePath))
+
+	// --- 2. Dump the resources of the integrations ---
+	if len(resourceTypes) > 0 && len(integrations) > 0 {
+		if err = dumpResources(cfg, logger, outputPathForDump, integrationIDs, resourceTypes); err != nil {
+			return workDir, "", err
+		}
+	}
+
+	// --- 3. Anonymize the dumped documents ---
+	if len(replacements) > 0 {
+		if err = anonymizeDump(outputPathForDump, strings.NewReplacer(replacements...)); err != nil {
+			logger.Error("Failed to anonymize dumped resources", zap.Error(err))
+			return workDir, "", fmt.Errorf("failed to anonymize dumped resources: %w", err)
+		}
+		logger.Info("Successfully anonymized dumped resources")
+	}
+
+	// --- 4. Create the tarball ---
+	err = runCommand(workDir, "tar", logger, "-czf", archiveFilePath, "demo-data")
+	if err != nil {
+		return workDir, "", fmt.Errorf("failed to run tar command: %w", err)
+	}
+
+	// --- 5. Encrypt the tarball ---
+	// not passed through runCommand so the password is not logged
+	cmd := exec.Command("openssl", "enc", "-aes-256-cbc", "-md", "md5", "-salt",
+		"-pass", "pass:"+cfg.OpenSSLPassword,
+		"-base64",
+		"-in", archiveFilePath,
+		"-out", encryptedFilePath,
+	)
+	cmd.Dir = workDir
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err = cmd.Run(); err != nil {
+		logger.Error("openssl command failed", zap.Error(err))
+		return workDir, "", fmt.Errorf("failed to run openssl command: %w", err)
+	}
+	logger.Info("Demo data export completed successfully", zap.String("path", encryptedFilePath))
+
+	return workDir, encryptedFilePath, nil
+}
+
+// dumpResources dumps the resource and inventory summary documents of the integrations using multielasticdump
+func dumpResources(cfg ExportConfig, logger *zap.Logger, outputDir string, integrationIDs []string, resourceTypes []string) error {
+	indices := []string{es.InventorySummaryIndex}
+	for _, rt := range resourceTypes {
+		indices = append(indices, es.ResourceTypeToESIndex(rt))
+	}
+
+	searchBody, err := json.Marshal(map[string]any{
+		"query": map[string]any{
+			"terms": map[string]any{
+				"integration_id": integrationIDs,
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build search body: %w", err)
+	}
+
+	cleanAddress := strings.TrimPrefix(cfg.ElasticsearchAddr, "https://")
+	elasticsearchAddress := fmt.Sprintf("https://%s:%s@%s", cfg.ElasticsearchUser, cfg.ElasticsearchPass, cleanAddress)
+
+	dumpArgs := []string{
+		"--direction=dump",
+		"--input=" + elasticsearchAddress,
+		"--output=" + outputDir,
+		"--match=^(" + strings.Join(indices, "|") + ")$",
+		"--searchBody=" + string(searchBody),
+		fmt.Sprintf("--parallel=%d", Parallelism),
+		fmt.Sprintf("--limit=%d", BatchLimit),
+		"--scrollTime=" + ScrollTime,
+	}
+
+	cmd := exec.Command("multielasticdump", dumpArgs...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "NODE_TLS_REJECT_UNAUTHORIZED=0")
+
+	logger.Info("Executing multielasticdump command",
+		zap.String("inputAddr", "https://****:****@"+cleanAddress),
+		zap.String("outputDir", outputDir),
+		zap.Int("indices", len(indices)),
+		zap.Int("integrations", len(integrationIDs)))
+
+	if err = cmd.Run(); err != nil {
+		logger.Error("multielasticdump command failed", zap.Error(err))
+		return fmt.Errorf("failed to run multielasticdump command: %w", err)
+	}
+	logger.Info("Successfully dumped resources", zap.String("outputDir", outputDir))
+	return nil
+}
+
+// anonymizeIntegrations replaces the identifying fields of the integrations and returns the old, new pairs
+// to rewrite the dumped documents with
+func anonymizeIntegrations(integrations []models2.Integration) ([]models2.Integration, []string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, err
+	}
+
+	var replacements []string
+	result := make([]models2.Integration, 0, len(integrations))
+	for idx, i := range integrations {
+		newID := uuid.NewSHA1(uuid.NameSpaceOID, append(salt, i.IntegrationID[:]...))
+		replacements = append(replacements, i.IntegrationID.String(), newID.String())
+
+		replaced := make(map[string]string)
+		if i.ProviderID != "" {
+			replaced[i.ProviderID] = pseudonymize(i.ProviderID, salt)
+			if len(i.ProviderID) >= minReplacedLength {
+				replacements = append(replacements, i.ProviderID, replaced[i.ProviderID])
+			}
+		}
+		if len(i.Name) >= minReplacedLength && i.Name != i.ProviderID {
+			replaced[i.Name] = fmt.Sprintf("%s-demo-%d", i.IntegrationType.String(), idx+1)
+			replacements = append(replacements, i.Name, replaced[i.Name])
+		}
+
+		i.IntegrationID = newID
+		i.ProviderID = replaced[i.ProviderID]
+		if name, ok := replaced[i.Name]; ok {
+			i.Name = name
+		} else {
+			i.Name = fmt.Sprintf("%s-demo-%d", i.IntegrationType.String(), idx+1)
+		}
+
+		var err error
+		if i.Annotations, err = replaceJsonbValues(i.Annotations, replaced); err != nil {
+			return nil, nil, err
+		}
+		if i.Labels, err = replaceJsonbValues(i.Labels, replaced); err != nil {
+			return nil, nil, err
+		}
+		result = append(result, i)
+	}
+	return result, replacements, nil
+}
+
+// pseudonymize derives a stable replacement keeping the shape of the value, digits stay digits and letters
+// keep their case so account ids remain valid for the integration type
+func pseudonymize(value string, salt []byte) string {
+	digest := sha256.Sum256(append(append([]byte{}, salt...), value...))
+	var sb strings.Builder
+	for idx, r := range value {
+		b := digest[idx%len(digest)] ^ byte(idx/len(digest))
+		switch {
+		case r >= '0' && r <= '9':
+			sb.WriteRune('0' + rune(b%10))
+		case r >= 'a' && r <= 'z':
+			sb.WriteRune('a' + rune(b%26))
+		case r >= 'A' && r <= 'Z':
+			sb.WriteRune('A' + rune(b%26))
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+func replaceJsonbValues(value pgtype.JSONB, replaced map[string]string) (pgtype.JSONB, error) {
+	m, err := jsonbToMap(value)
+	if err != nil {
+		return value, err
+	}
+	for k, v := range m {
+		if r, ok := replaced[v]; ok {
+			m[k] = r
+		}
+	}
+	var jsonb pgtype.JSONB
+	if err = jsonb.Set(m); err != nil {
+		return value, err
+	}
+	return jsonb, nil
+}
+
+func jsonbToMap(value pgtype.JSONB) (map[string]string, error) {
+	m := make(map[string]string)
+	if value.Status != pgtype.Present || len(value.Bytes) == 0 {
+		return m, nil
+	}
+	if err := json.Unmarshal(value.Bytes, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// anonymizeDump rewrites every dumped file line by line with the replacer
+func anonymizeDump(dir string, replacer *strings.Replacer) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if err = rewriteFile(filepath.Join(dir, entry.Name()), replacer); err != nil {
+			return fmt.Errorf("failed to rewrite %s: %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+func rewriteFile(path string, replacer *strings.Replacer) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmpPath := path + ".tmp"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	writer := bufio.NewWriter(out)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 1024*1024), maxDocumentSize)
+	for scanner.Scan() {
+		if _, err = writer.WriteString(replacer.Replace(scanner.Text()) + "\n"); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		out.Close()
+		return err
+	}
+	if err = writer.Flush(); err != nil {
+		out.Close()
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,139 @@
+package demo_import
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
+	"github.com/opengovern/og-util/pkg/integration"
+	models2 "github.com/opengovern/opensecurity/services/integration/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPseudonymize(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	for _, value := range []string{"123456789012", "my-org", "Org_Name-42", "a"} {
+		replaced := pseudonymize(value, salt)
+		assert.Equal(t, replaced, pseudonymize(value, salt), "replacements are stable")
+
+		original, pseudonym := []rune(value), []rune(replaced)
+		require.Len(t, pseudonym, len(original))
+		for idx, r := range original {
+			switch {
+			case unicode.IsDigit(r):
+				assert.True(t, unicode.IsDigit(pseudonym[idx]), "%q: digits stay digits", value)
+			case unicode.IsLower(r):
+				assert.True(t, unicode.IsLower(pseudonym[idx]), "%q: lowercase letters stay lowercase", value)
+			case unicode.IsUpper(r):
+				assert.True(t, unicode.IsUpper(pseudonym[idx]), "%q: uppercase letters stay uppercase", value)
+			default:
+				assert.Equal(t, r, pseudonym[idx], "%q: separators are kept", value)
+			}
+		}
+	}
+
+	// values longer than the digest are not repeated
+	long := "0000000000000000000000000000000000000000000000000000000000000000"
+	replaced := pseudonymize(long, salt)
+	assert.NotEqual(t, replaced[:32], replaced[32:])
+
+	assert.NotEqual(t, pseudonymize("123456789012", salt), pseudonymize("123456789012", []byte("another salt")))
+}
+
+func jsonb(t *testing.T, m map[string]string) pgtype.JSONB {
+	var value pgtype.JSONB
+	require.NoError(t, value.Set(m))
+	return value
+}
+
+func replacementPairs(replacements []string) map[string]string {
+	pairs := make(map[string]string)
+	for idx := 0; idx+1 < len(replacements); idx += 2 {
+		pairs[replacements[idx]] = replacements[idx+1]
+	}
+	return pairs
+}
+
+func TestAnonymizeIntegrations(t *testing.T) {
+	account := models2.Integration{Integration: integration.Integration{
+		IntegrationID:   uuid.New(),
+		ProviderID:      "123456789012",
+		Name:            "production",
+		IntegrationType: "aws_cloud_account",
+		Annotations:     jsonb(t, map[string]string{"account": "123456789012", "team": "security"}),
+		Labels:          jsonb(t, map[string]string{"name": "production"}),
+	}}
+	short := models2.Integration{Integration: integration.Integration{
+		IntegrationID:   uuid.New(),
+		ProviderID:      "42",
+		Name:            "42",
+		IntegrationType: "github_account",
+	}}
+
+	result, replacements, err := anonymizeIntegrations([]models2.Integration{account, short})
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	pairs := replacementPairs(replacements)
+
+	anonymized := result[0]
+	assert.NotEqual(t, account.IntegrationID, anonymized.IntegrationID)
+	assert.Equal(t, anonymized.IntegrationID.String(), pairs[account.IntegrationID.String()])
+	assert.NotEqual(t, account.ProviderID, anonymized.ProviderID)
+	assert.Equal(t, anonymized.ProviderID, pairs[account.ProviderID])
+	assert.Equal(t, "aws_cloud_account-demo-1", anonymized.Name)
+	assert.Equal(t, anonymized.Name, pairs[account.Name])
+
+	annotations, err := jsonbToMap(anonymized.Annotations)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"account": anonymized.ProviderID, "team": "security"}, annotations)
+	labels, err := jsonbToMap(anonymized.Labels)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"name": anonymized.Name}, labels)
+
+	// short values are replaced in the metadata only, the documents keep them
+	anonymized = result[1]
+	assert.NotEqual(t, short.ProviderID, anonymized.ProviderID)
+	assert.Len(t, anonymized.ProviderID, len(short.ProviderID))
+	assert.Equal(t, anonymized.ProviderID, anonymized.Name, "names matching the provider id share its pseudonym")
+	assert.Equal(t, anonymized.IntegrationID.String(), pairs[short.IntegrationID.String()])
+	assert.NotContains(t, pairs, short.ProviderID)
+	assert.Len(t, replacements, 8)
+}
+
+func TestHexEncodedMapRoundTrip(t *testing.T) {
+	exported := []Integration{
+		{
+			IntegrationID:   uuid.NewString(),
+			ProviderID:      "123456789012",
+			Name:            "aws_cloud_account-demo-1",
+			IntegrationType: "aws_cloud_account",
+			Annotations:     HexEncodedMap{"account": "123456789012"},
+			Labels:          HexEncodedMap{"team": "security"},
+		},
+		{
+			IntegrationID:   uuid.NewString(),
+			IntegrationType: "github_account",
+		},
+	}
+	data, err := json.Marshal(exported)
+	require.NoError(t, err)
+
+	var raw []map[string]any
+	require.NoError(t, json.Unmarshal(data, &raw))
+	assert.Equal(t, `\x7b227465616d223a227365637572697479227d`, raw[0]["labels"], "maps are encoded like a postgres bytea dump")
+
+	path := filepath.Join(t.TempDir(), "integrations.json")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	loaded, err := loadIntegrationsFromJSON(path)
+	require.NoError(t, err)
+
+	// missing maps are exported as empty objects
+	exported[1].Annotations = HexEncodedMap{}
+	exported[1].Labels = HexEncodedMap{}
+	assert.Equal(t, exported, loaded)
+}
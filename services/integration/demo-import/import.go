@@ -63,6 +63,19 @@ func (h *HexEncodedMap) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// MarshalJSON encodes the map the same way postgres dumps a bytea column, so exported datasets can be loaded again
+func (h HexEncodedMap) MarshalJSON() ([]byte, error) {
+	m := map[string]string(h)
+	if m == nil {
+		m = map[string]string{}
+	}
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal("\\x" + hex.EncodeToString(raw))
+}
+
 type Integration struct {
 	IntegrationID   string        `json:"integrationId"`
 	ProviderID      string        `json:"providerId"`
@@ -0,0 +1,82 @@
+package entities
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CredentialExpiryAnnotation can be set by integration types on discovered integrations to report when the credential expires
+const CredentialExpiryAnnotation = "platform/credential/expires-at"
+
+// credentialExpiryKeys are the secret fields the expiry of a credential is read from
+var credentialExpiryKeys = []string{"expires_at", "expiry", "expiration", "expiration_date", "client_secret_expires_at", "secret_expires_at"}
+
+// ParseCredentialExpiry returns the earliest expiry found in the secret fields or the annotations of the integrations
+// using the credential, nil if none of them tells
+func ParseCredentialExpiry(secret map[string]any, annotations ...map[string]string) *time.Time {
+	var expiresAt *time.Time
+	earliest := func(t *time.Time) {
+		if t != nil && (expiresAt == nil || t.Before(*expiresAt)) {
+			expiresAt = t
+		}
+	}
+
+	for _, key := range credentialExpiryKeys {
+		if v, ok := secret[key]; ok {
+			earliest(parseExpiryValue(v))
+		}
+	}
+	for _, a := range annotations {
+		if v, ok := a[CredentialExpiryAnnotation]; ok {
+			earliest(parseExpiryValue(v))
+		}
+	}
+
+	return expiresAt
+}
+
+// maxUnixSeconds is the largest number read as an expiry, in year 5138. Larger numbers are most likely milliseconds
+// or nanoseconds, which would otherwise be read as a date far in the future.
+const maxUnixSeconds = 1e11
+
+// parseExpiryValue reads an RFC 3339 date (time) or a number of seconds since the Unix epoch, as the
+// client_secret_expires_at field of OAuth 2.0 client registrations. Zero and negative numbers mean the credential
+// does not expire, numbers out of the range of seconds are ignored.
+func parseExpiryValue(v any) *time.Time {
+	var seconds float64
+	switch value := v.(type) {
+	case string:
+		value = strings.TrimSpace(value)
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, value); err == nil {
+				return &t
+			}
+		}
+		unix, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil
+		}
+		seconds = float64(unix)
+	case float64:
+		seconds = value
+	case int64:
+		seconds = float64(value)
+	case int:
+		seconds = float64(value)
+	case json.Number:
+		number, err := value.Float64()
+		if err != nil {
+			return nil
+		}
+		seconds = number
+	default:
+		return nil
+	}
+	if seconds <= 0 || seconds > maxUnixSeconds {
+		return nil
+	}
+	t := time.Unix(int64(seconds), 0)
+	return &t
+}
@@ -0,0 +1,56 @@
+package entities
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExpiryValue(t *testing.T) {
+	date := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value any
+		want  *time.Time
+	}{
+		{name: "rfc3339", value: "2030-01-02T00:00:00Z", want: &date},
+		{name: "date", value: "2030-01-02", want: &date},
+		{name: "seconds string", value: "1893542400", want: &date},
+		{name: "seconds json number", value: float64(1893542400), want: &date},
+		{name: "seconds int", value: 1893542400, want: &date},
+		{name: "seconds json.Number", value: json.Number("1893542400"), want: &date},
+		{name: "zero never expires", value: float64(0)},
+		{name: "milliseconds", value: float64(1893542400000)},
+		{name: "milliseconds string", value: "1893542400000"},
+		{name: "garbage", value: "next year"},
+		{name: "bool", value: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseExpiryValue(tt.value)
+			if tt.want == nil {
+				assert.Nil(t, got)
+				return
+			}
+			require.NotNil(t, got)
+			assert.True(t, tt.want.Equal(*got), "got %s", got)
+		})
+	}
+}
+
+func TestParseCredentialExpiryEarliest(t *testing.T) {
+	secret := map[string]any{
+		"expires_at":               "2031-01-01",
+		"client_secret_expires_at": float64(1893542400), // 2030-01-02
+	}
+	annotations := map[string]string{CredentialExpiryAnnotation: "2030-06-01T00:00:00Z"}
+
+	got := ParseCredentialExpiry(secret, annotations)
+	require.NotNil(t, got)
+	assert.Equal(t, time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC), got.UTC())
+
+	assert.Nil(t, ParseCredentialExpiry(map[string]any{"client_id": "id"}))
+}
@@ -1,43 +1,84 @@
 package entities
 
 import (
+	"fmt"
 	"github.com/opengovern/og-util/pkg/steampipe"
 	api "github.com/opengovern/opensecurity/services/integration/api/models"
 	"github.com/opengovern/opensecurity/services/integration/models"
 	"golang.org/x/net/context"
+	"strings"
 )
 
+// IntegrationGroupIDColumns are the columns an integration group query can return the integration ids in
+var IntegrationGroupIDColumns = []string{"platform_integration_id", "integration_id"}
+
 func NewIntegrationGroup(ctx context.Context, steampipe *steampipe.Database, cg models.IntegrationGroup) (*api.IntegrationGroup, error) {
 	apiCg := api.IntegrationGroup{
-		Name:  cg.Name,
-		Query: cg.Query,
+		Name:        cg.Name,
+		Query:       cg.Query,
+		Description: cg.Description,
+		Source:      string(cg.Source),
+		CreatedBy:   cg.CreatedBy,
+	}
+	if !cg.CreatedAt.IsZero() {
+		apiCg.CreatedAt = &cg.CreatedAt
+	}
+	if !cg.UpdatedAt.IsZero() {
+		apiCg.UpdatedAt = &cg.UpdatedAt
 	}
 
 	if steampipe == nil || cg.Query == "" {
 		return &apiCg, nil
 	}
 
-	integrationsQueryResult, err := steampipe.QueryAll(ctx, cg.Query)
+	integrationIds, err := QueryIntegrationGroupMembers(ctx, steampipe, cg.Query)
 	if err != nil {
 		return nil, err
 	}
+	apiCg.IntegrationIds = integrationIds
 
-	var integrationIds []string
-	for i, header := range integrationsQueryResult.Headers {
-		if header != "integration_id" {
-			continue
-		}
-		for _, row := range integrationsQueryResult.Data {
-			if len(row) <= i || row[i] == nil {
-				continue
-			}
-			if strRow, ok := row[i].(string); ok {
-				integrationIds = append(integrationIds, strRow)
+	return &apiCg, nil
+}
+
+// QueryIntegrationGroupMembers runs an integration group query in CloudQL and returns the integration ids it selects.
+// The query should return a platform_integration_id (or integration_id) column.
+func QueryIntegrationGroupMembers(ctx context.Context, steampipe *steampipe.Database, query string) ([]string, error) {
+	integrationsQueryResult, err := steampipe.QueryAll(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return integrationGroupMembers(integrationsQueryResult)
+}
+
+// integrationGroupMembers returns the distinct integration ids of an integration group query result
+func integrationGroupMembers(integrationsQueryResult *steampipe.Result) ([]string, error) {
+	idColumn := -1
+	for _, column := range IntegrationGroupIDColumns {
+		for i, header := range integrationsQueryResult.Headers {
+			if strings.ToLower(header) == column {
+				idColumn = i
+				break
 			}
 		}
+		if idColumn >= 0 {
+			break
+		}
+	}
+	if idColumn < 0 {
+		return nil, fmt.Errorf("query should return a %s column", IntegrationGroupIDColumns[0])
 	}
 
-	apiCg.IntegrationIds = integrationIds
+	seen := make(map[string]bool)
+	integrationIds := make([]string, 0, len(integrationsQueryResult.Data))
+	for _, row := range integrationsQueryResult.Data {
+		if len(row) <= idColumn || row[idColumn] == nil {
+			continue
+		}
+		if strRow, ok := row[idColumn].(string); ok && !seen[strRow] {
+			seen[strRow] = true
+			integrationIds = append(integrationIds, strRow)
+		}
+	}
 
-	return &apiCg, nil
+	return integrationIds, nil
 }
@@ -0,0 +1,75 @@
+package entities
+
+import (
+	"testing"
+
+	"github.com/opengovern/og-util/pkg/steampipe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntegrationGroupMembers(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  steampipe.Result
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "platform integration id column",
+			result: steampipe.Result{
+				Headers: []string{"name", "platform_integration_id"},
+				Data:    [][]any{{"prod", "a"}, {"staging", "b"}},
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name: "integration id column",
+			result: steampipe.Result{
+				Headers: []string{"Integration_ID"},
+				Data:    [][]any{{"a"}},
+			},
+			want: []string{"a"},
+		},
+		{
+			name: "platform integration id is preferred",
+			result: steampipe.Result{
+				Headers: []string{"integration_id", "platform_integration_id"},
+				Data:    [][]any{{"provider", "a"}},
+			},
+			want: []string{"a"},
+		},
+		{
+			name: "duplicates, nulls and non string ids are skipped",
+			result: steampipe.Result{
+				Headers: []string{"platform_integration_id"},
+				Data:    [][]any{{"a"}, {nil}, {"a"}, {42}, {}, {"b"}},
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name:   "no rows",
+			result: steampipe.Result{Headers: []string{"platform_integration_id"}},
+			want:   []string{},
+		},
+		{
+			name: "missing id column",
+			result: steampipe.Result{
+				Headers: []string{"name"},
+				Data:    [][]any{{"prod"}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := integrationGroupMembers(&tt.result)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "platform_integration_id")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
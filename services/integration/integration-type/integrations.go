@@ -24,6 +24,7 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"strings"
@@ -34,6 +35,7 @@ import (
 	"github.com/opengovern/og-util/pkg/integration/interfaces"
 
 	"github.com/opengovern/opensecurity/services/integration/models"
+	"github.com/opengovern/opensecurity/services/integration/utils"
 	hczap "github.com/zaffka/zap-to-hclog"
 )
 
@@ -66,7 +68,8 @@ type IntegrationTypeManager struct {
 }
 
 func NewIntegrationTypeManager(logger *zap.Logger, database db.Database, integrationTypeDb *gorm.DB,
-	kubeClient client.Client, kubeClientset *kubernetes.Clientset, metricsClient *metricsv.Clientset, cnf config.IntegrationPluginsConfig) *IntegrationTypeManager {
+	kubeClient client.Client, kubeClientset *kubernetes.Clientset, metricsClient *metricsv.Clientset, cnf config.IntegrationPluginsConfig,
+	pluginVerifier *utils.PluginVerifier) *IntegrationTypeManager {
 	maxRetries := cnf.MaxAutoRebootRetries
 	if maxRetries == 0 {
 		maxRetries = 1
@@ -117,6 +120,10 @@ func NewIntegrationTypeManager(logger *zap.Logger, database db.Database, integra
 			}
 			continue
 		}
+		if err = pluginVerifier.VerifyInstalled(t, pluginBinary); err != nil {
+			logger.Error("plugin binary verification failed", zap.String("plugin_id", t.PluginID), zap.Error(err))
+			continue
+		}
 		// write the plugin to the file system
 		pluginPath := filepath.Join(baseDir, t.IntegrationType.String()+".so")
 		err := os.WriteFile(pluginPath, pluginBinary.IntegrationPlugin, 0755)
@@ -580,6 +587,22 @@ func (a *IntegrationTypeManager) DisableIntegrationTypeHelper(ctx context.Contex
 	return nil
 }
 
+// describerImage returns the describer image of the plugin, moved to the configured private registry if there is one
+func (a *IntegrationTypeManager) describerImage(plugin *models.IntegrationPlugin) string {
+	if a.cnf.DescriberRegistry == "" {
+		return fmt.Sprintf("%s:%s", plugin.DescriberURL, plugin.DescriberTag)
+	}
+	return fmt.Sprintf("%s/%s:%s", strings.TrimSuffix(a.cnf.DescriberRegistry, "/"), path.Base(plugin.DescriberURL), plugin.DescriberTag)
+}
+
+func (a *IntegrationTypeManager) describerImagePullSecrets() []v1.LocalObjectReference {
+	var secrets []v1.LocalObjectReference
+	for _, name := range a.cnf.DescriberImagePullSecrets {
+		secrets = append(secrets, v1.LocalObjectReference{Name: name})
+	}
+	return secrets
+}
+
 func (a *IntegrationTypeManager) EnableIntegrationTypeHelper(ctx context.Context, plugin *models.IntegrationPlugin) error {
 	currentNamespace, ok := os.LookupEnv("CURRENT_NAMESPACE")
 	if !ok {
@@ -632,10 +655,11 @@ func (a *IntegrationTypeManager) EnableIntegrationTypeHelper(ctx context.Context
 	describerDeployment.Spec.Selector.MatchLabels["app"] = cnf.DescriberDeploymentName
 	describerDeployment.Spec.Template.ObjectMeta.Labels["app"] = cnf.DescriberDeploymentName
 	describerDeployment.Spec.Template.Spec.ServiceAccountName = "og-describer"
+	describerDeployment.Spec.Template.Spec.ImagePullSecrets = a.describerImagePullSecrets()
 
 	container := describerDeployment.Spec.Template.Spec.Containers[0]
 	container.Name = cnf.DescriberDeploymentName
-	container.Image = fmt.Sprintf("%s:%s", plugin.DescriberURL, plugin.DescriberTag)
+	container.Image = a.describerImage(plugin)
 	container.Command = []string{cnf.DescriberRunCommand}
 	natsUrl, ok := os.LookupEnv("NATS_URL")
 	if ok {
@@ -713,10 +737,11 @@ func (a *IntegrationTypeManager) EnableIntegrationTypeHelper(ctx context.Context
 	describerDeploymentManuals.Spec.Selector.MatchLabels["app"] = cnf.DescriberDeploymentName + "-manuals"
 	describerDeploymentManuals.Spec.Template.ObjectMeta.Labels["app"] = cnf.DescriberDeploymentName + "-manuals"
 	describerDeploymentManuals.Spec.Template.Spec.ServiceAccountName = "og-describer"
+	describerDeploymentManuals.Spec.Template.Spec.ImagePullSecrets = a.describerImagePullSecrets()
 
 	containerManuals := describerDeploymentManuals.Spec.Template.Spec.Containers[0]
 	containerManuals.Name = cnf.DescriberDeploymentName
-	containerManuals.Image = fmt.Sprintf("%s:%s", plugin.DescriberURL, plugin.DescriberTag)
+	containerManuals.Image = a.describerImage(plugin)
 	containerManuals.Command = []string{cnf.DescriberRunCommand}
 	natsUrl, ok = os.LookupEnv("NATS_URL")
 	if ok {
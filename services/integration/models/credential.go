@@ -11,6 +11,24 @@ import (
 	"time"
 )
 
+type CredentialExpirySource string
+
+const (
+	CredentialExpirySourceManual CredentialExpirySource = "manual"
+	CredentialExpirySourceAuto   CredentialExpirySource = "auto"
+)
+
+// CredentialSecretBackend is where the secret of a credential is stored
+type CredentialSecretBackend string
+
+const (
+	// CredentialSecretBackendPlatform secrets are encrypted with the platform vault and stored in the credential
+	CredentialSecretBackendPlatform       CredentialSecretBackend = ""
+	CredentialSecretBackendHashicorpVault CredentialSecretBackend = "hashicorp-vault"
+	CredentialSecretBackendKubernetes     CredentialSecretBackend = "kubernetes"
+	CredentialSecretBackendFile           CredentialSecretBackend = "file"
+)
+
 type Credential struct {
 	ID              uuid.UUID `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
 	IntegrationType integration.Type
@@ -21,6 +39,15 @@ type Credential struct {
 	MaskedSecret  pgtype.JSONB 
 	Description     string            
 
+	// SecretBackend and SecretReference point to a secret kept outside the platform, Secret is empty for them
+	SecretBackend   CredentialSecretBackend
+	SecretReference string
+
+	// ExpiresAt is when the secret expires, either entered manually or parsed from the secret and discovered integrations
+	ExpiresAt    *time.Time
+	ExpirySource CredentialExpirySource
+	RotatedAt    *time.Time
+
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	DeletedAt sql.NullTime `gorm:"index"`
@@ -50,6 +77,18 @@ func (c *Credential) ToApi(returnSecret bool) (*models.Credential, error) {
 		Description:     c.Description,
 		CreatedAt:       c.CreatedAt,
 		UpdatedAt:       c.UpdatedAt,
+		ExpiresAt:       c.ExpiresAt,
+		ExpirySource:    string(c.ExpirySource),
+		RotatedAt:       c.RotatedAt,
+		SecretBackend:   string(c.SecretBackend),
+		SecretReference: c.SecretReference,
+	}
+	lastRotation := c.CreatedAt
+	if c.RotatedAt != nil {
+		lastRotation = *c.RotatedAt
+	}
+	if !lastRotation.IsZero() {
+		credential.AgeDays = int(time.Since(lastRotation).Hours() / 24)
 	}
 	if returnSecret {
 		credential.Secret = c.Secret
@@ -57,3 +96,30 @@ func (c *Credential) ToApi(returnSecret bool) (*models.Credential, error) {
 
 	return credential, nil
 }
+
+// IsSecretReference returns whether the secret is resolved from an external backend at use time
+func (c *Credential) IsSecretReference() bool {
+	return c.SecretBackend != CredentialSecretBackendPlatform
+}
+
+type CredentialSecretPurpose string
+
+const (
+	CredentialSecretPurposeDiscovery   CredentialSecretPurpose = "discovery"
+	CredentialSecretPurposeHealthCheck CredentialSecretPurpose = "health_check"
+	CredentialSecretPurposeDescribe    CredentialSecretPurpose = "describe"
+	CredentialSecretPurposeTask        CredentialSecretPurpose = "task"
+	CredentialSecretPurposeValidation  CredentialSecretPurpose = "validation"
+)
+
+// CredentialSecretResolution is the audit record of a secret reference being resolved
+type CredentialSecretResolution struct {
+	ID              uint      `gorm:"primaryKey"`
+	CredentialID    uuid.UUID `gorm:"index"`
+	SecretBackend   CredentialSecretBackend
+	SecretReference string
+	Purpose         CredentialSecretPurpose
+	Success         bool
+	Error           string
+	CreatedAt       time.Time `gorm:"index"`
+}
@@ -59,6 +59,26 @@ func (i *Integration) AddAnnotations(key, value string) (*pgtype.JSONB, error) {
 	return &integrationAnnotationsJsonb, nil
 }
 
+func (i *Integration) RemoveAnnotation(key string) error {
+	if i.Annotations.Status != pgtype.Present {
+		return nil
+	}
+	var annotation map[string]string
+	if err := json.Unmarshal(i.Annotations.Bytes, &annotation); err != nil {
+		return err
+	}
+	if _, ok := annotation[key]; !ok {
+		return nil
+	}
+	delete(annotation, key)
+
+	annotationsJsonData, err := json.Marshal(annotation)
+	if err != nil {
+		return err
+	}
+	return i.Annotations.Set(annotationsJsonData)
+}
+
 func (i *Integration) ToApi() (*api.Integration, error) {
 	var labels map[string]string
 	if i.Labels.Status == pgtype.Present {
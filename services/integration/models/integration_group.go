@@ -1,6 +1,45 @@
 package models
 
+import "time"
+
+type IntegrationGroupSource string
+
+const (
+	// IntegrationGroupSourceMigration groups are loaded from platform content and replaced on every migration
+	IntegrationGroupSourceMigration IntegrationGroupSource = "migration"
+	// IntegrationGroupSourceUser groups are managed through the API and kept across migrations
+	IntegrationGroupSourceUser IntegrationGroupSource = "user"
+)
+
 type IntegrationGroup struct {
-	Name  string `gorm:"primaryKey" json:"name"`
-	Query string `json:"query"`
+	Name        string                 `gorm:"primaryKey" json:"name"`
+	Query       string                 `json:"query"`
+	Description string                 `json:"description"`
+	Source      IntegrationGroupSource `gorm:"not null;default:'migration'" json:"source"`
+	CreatedBy   string                 `json:"created_by"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+}
+
+// IntegrationGroupMember is the last evaluated membership of an integration group
+type IntegrationGroupMember struct {
+	GroupName     string `gorm:"primaryKey"`
+	IntegrationID string `gorm:"primaryKey"`
+	AddedAt       time.Time
+}
+
+type IntegrationGroupMembershipAction string
+
+const (
+	IntegrationGroupMembershipActionAdded   IntegrationGroupMembershipAction = "ADDED"
+	IntegrationGroupMembershipActionRemoved IntegrationGroupMembershipAction = "REMOVED"
+)
+
+// IntegrationGroupMembershipEvent records an integration entering or leaving an integration group
+type IntegrationGroupMembershipEvent struct {
+	ID            uint   `gorm:"primaryKey"`
+	GroupName     string `gorm:"index"`
+	IntegrationID string `gorm:"index"`
+	Action        IntegrationGroupMembershipAction
+	CreatedAt     time.Time `gorm:"index"`
 }
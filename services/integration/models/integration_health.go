@@ -0,0 +1,25 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type IntegrationHealthCheckTrigger string
+
+const (
+	IntegrationHealthCheckTriggerManual    IntegrationHealthCheckTrigger = "MANUAL"
+	IntegrationHealthCheckTriggerScheduled IntegrationHealthCheckTrigger = "SCHEDULED"
+)
+
+// IntegrationHealthCheck is the result of a single integration health check
+type IntegrationHealthCheck struct {
+	ID              uint      `gorm:"primaryKey"`
+	IntegrationID   uuid.UUID `gorm:"type:uuid;index:idx_integration_health_checks_integration_checked_at,priority:1"`
+	IntegrationType string
+	CheckedAt       time.Time `gorm:"index:idx_integration_health_checks_integration_checked_at,priority:2"`
+	LatencyMs       int64
+	Healthy         bool
+	Reason          string
+	Trigger         IntegrationHealthCheckTrigger
+}
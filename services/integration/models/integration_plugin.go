@@ -17,8 +17,43 @@ type Manifest struct {
 	SupportedPlatformVersion string           `json:"SupportedPlatformVersion" yaml:"SupportedPlatformVersion"`
 	UpdateDate               string           `json:"UpdateDate" yaml:"UpdateDate"`
 	DiscoveryType            string           `json:"DiscoveryType" yaml:"DiscoveryType"`
+	Checksums                PluginChecksums  `json:"Checksums" yaml:"Checksums"`
+	Signature                PluginSignature  `json:"Signature" yaml:"Signature"`
 }
 
+// PluginChecksums are the hex encoded SHA-256 digests of the plugin binaries
+type PluginChecksums struct {
+	IntegrationPlugin string `json:"integration-plugin" yaml:"integration-plugin"`
+	CloudQLPlugin     string `json:"cloudql-plugin" yaml:"cloudql-plugin"`
+}
+
+// PluginSignature signs the checksums in the sha256sum format, one "<digest>  <file>" line for integration-plugin
+// then cloudql-plugin, followed by the IntegrationType, DescriberURL, DescriberTag and SupportedPlatformVersion of
+// the manifest as "<field>: <value>" lines, see utils.PluginSignaturePayload
+type PluginSignature struct {
+	Type  PluginSignatureType `json:"Type" yaml:"Type"`
+	Value string              `json:"Value" yaml:"Value"`
+}
+
+type PluginSignatureType string
+
+const (
+	PluginSignatureTypeMinisign PluginSignatureType = "minisign"
+	PluginSignatureTypeCosign   PluginSignatureType = "cosign"
+	PluginSignatureTypeGPG      PluginSignatureType = "gpg"
+)
+
+type IntegrationPluginVerificationStatus string
+
+const (
+	// IntegrationPluginVerificationStatusVerified plugins match their checksums which are signed by a trusted key
+	IntegrationPluginVerificationStatusVerified IntegrationPluginVerificationStatus = "verified"
+	// IntegrationPluginVerificationStatusChecksumOnly plugins match their checksums but are not signed
+	IntegrationPluginVerificationStatusChecksumOnly IntegrationPluginVerificationStatus = "checksum_only"
+	IntegrationPluginVerificationStatusUnverified   IntegrationPluginVerificationStatus = "unverified"
+	IntegrationPluginVerificationStatusFailed       IntegrationPluginVerificationStatus = "failed"
+)
+
 type IntegrationPluginInstallState string
 type IntegrationPluginOperationalStatus string
 
@@ -72,6 +107,17 @@ type IntegrationPlugin struct {
 	DiscoveryType            IntegrationPluginDiscoveryType `gorm:"default:classic"`
 	OperationalStatusUpdates pgtype.JSONB                   `gorm:"default:'[]'"`
 	Tags                     pgtype.JSONB
+
+	// IntegrationPluginSHA256 and CloudQLPluginSHA256 are the digests of the installed binaries, checked before every load
+	IntegrationPluginSHA256 string
+	CloudQLPluginSHA256     string
+	VerificationStatus      IntegrationPluginVerificationStatus
+	VerificationSigner      string
+	VerificationMessage     string
+	// SignatureType and Signature are kept to verify the plugin again every time it is loaded
+	SignatureType PluginSignatureType
+	Signature     string
+	VerifiedAt    *time.Time
 }
 
 func (ip IntegrationPlugin) GetStringOperationalStatusUpdates() ([]string, error) {
@@ -94,3 +140,33 @@ type IntegrationPluginBinary struct {
 	IntegrationPlugin []byte `gorm:"type:bytea"`
 	CloudQlPlugin     []byte `gorm:"type:bytea"`
 }
+
+// AddOperationalStatusUpdate changes the operational status and records the reason, keeping the last 20 updates
+func (ip *IntegrationPlugin) AddOperationalStatusUpdate(newStatus IntegrationPluginOperationalStatus, reason string) error {
+	updates, err := ip.GetStringOperationalStatusUpdates()
+	if err != nil {
+		updates = []string{}
+	}
+	updateJson, err := json.Marshal(OperationalStatusUpdate{
+		Time:      time.Now(),
+		OldStatus: ip.OperationalStatus,
+		NewStatus: newStatus,
+		Reason:    reason,
+	})
+	if err != nil {
+		return err
+	}
+	updates = append(updates, string(updateJson))
+	if len(updates) > 20 {
+		updates = updates[len(updates)-20:]
+	}
+	updatesJson, err := json.Marshal(updates)
+	if err != nil {
+		return err
+	}
+	if err = ip.OperationalStatusUpdates.Set(updatesJson); err != nil {
+		return err
+	}
+	ip.OperationalStatus = newStatus
+	return nil
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntegrationAnnotations(t *testing.T) {
+	var integ Integration
+	require.NoError(t, integ.RemoveAnnotation("platform/integration/health-reason"))
+
+	_, err := integ.AddAnnotations("platform/integration/health-reason", "health check failed")
+	require.NoError(t, err)
+	_, err = integ.AddAnnotations("owner", "team-a")
+	require.NoError(t, err)
+
+	require.NoError(t, integ.RemoveAnnotation("platform/integration/health-reason"))
+	integrationApi, err := integ.ToApi()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"owner": "team-a"}, integrationApi.Annotations)
+}
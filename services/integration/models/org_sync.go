@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
+	"github.com/lib/pq"
+)
+
+// CredentialOrgSync is the periodic sub-account sync of an organization level credential
+type CredentialOrgSync struct {
+	CredentialID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Enabled      bool
+	// IntervalMinutes overrides the default sync interval if set
+	IntervalMinutes int
+	// DefaultLabels are added to the integrations created by the sync, on top of the configured defaults
+	DefaultLabels pgtype.JSONB `gorm:"default:'{}'"`
+	// FrameworkIDs are assigned to the integrations created by the sync, on top of the configured defaults
+	FrameworkIDs pq.StringArray `gorm:"type:text[]"`
+	// ArchiveMissing archives the integrations of the credential which are no longer discovered
+	ArchiveMissing bool `gorm:"default:true"`
+	LastSyncAt     *time.Time
+	CreatedBy      string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type CredentialOrgSyncTrigger string
+
+const (
+	CredentialOrgSyncTriggerManual    CredentialOrgSyncTrigger = "MANUAL"
+	CredentialOrgSyncTriggerScheduled CredentialOrgSyncTrigger = "SCHEDULED"
+)
+
+type CredentialOrgSyncStatus string
+
+const (
+	CredentialOrgSyncStatusSucceeded CredentialOrgSyncStatus = "SUCCEEDED"
+	CredentialOrgSyncStatusFailed    CredentialOrgSyncStatus = "FAILED"
+)
+
+// CredentialOrgSyncReportIntegration is an integration changed by an org sync
+type CredentialOrgSyncReportIntegration struct {
+	IntegrationID string `json:"integration_id"`
+	ProviderID    string `json:"provider_id"`
+	Name          string `json:"name"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// CredentialOrgSyncReport is the result of a single org sync run
+type CredentialOrgSyncReport struct {
+	ID           uint      `gorm:"primaryKey"`
+	CredentialID uuid.UUID `gorm:"type:uuid;index:idx_credential_org_sync_reports_credential_started_at,priority:1"`
+	Trigger      CredentialOrgSyncTrigger
+	Status       CredentialOrgSyncStatus
+	Error        string
+	StartedAt    time.Time `gorm:"index:idx_credential_org_sync_reports_credential_started_at,priority:2"`
+	FinishedAt   time.Time
+	Discovered   int
+	Unchanged    int
+	// Added, Restored, Archived and Failed are lists of CredentialOrgSyncReportIntegration
+	Added    pgtype.JSONB `gorm:"default:'[]'"`
+	Restored pgtype.JSONB `gorm:"default:'[]'"`
+	Archived pgtype.JSONB `gorm:"default:'[]'"`
+	Failed   pgtype.JSONB `gorm:"default:'[]'"`
+}
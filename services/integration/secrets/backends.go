@@ -0,0 +1,154 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"golang.org/x/net/context"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// hashicorpVaultBackend reads KV v2 secrets, references are <mount>/<path> and every key of the secret is a credential field
+type hashicorpVaultBackend struct {
+	client *vaultapi.Client
+}
+
+func (b hashicorpVaultBackend) resolve(ctx context.Context, reference string) (map[string]any, error) {
+	mount, path, ok := strings.Cut(strings.Trim(reference, "/"), "/")
+	if !ok || path == "" {
+		return nil, fmt.Errorf("reference should be <mount>/<path>")
+	}
+	secret, err := b.client.KVv2(mount).Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return secret.Data, nil
+}
+
+// kubernetesBackend reads kubernetes secrets of its namespace, references are [namespace/]name[#key] where the
+// namespace, if given, must be the configured one. Without a key every key of the secret is a credential field,
+// with a key its value should be a json object of the credential fields.
+type kubernetesBackend struct {
+	client    client.Client
+	namespace string
+}
+
+func (b kubernetesBackend) resolve(ctx context.Context, reference string) (map[string]any, error) {
+	name, key, _ := strings.Cut(reference, "#")
+	if ns, n, ok := strings.Cut(name, "/"); ok {
+		if ns != b.namespace {
+			return nil, fmt.Errorf("secrets can only be read from namespace %s", b.namespace)
+		}
+		name = n
+	}
+	if name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid secret name")
+	}
+
+	var secret corev1.Secret
+	if err := b.client.Get(ctx, types.NamespacedName{Namespace: b.namespace, Name: name}, &secret); err != nil {
+		return nil, err
+	}
+
+	if key != "" {
+		value, ok := secret.Data[key]
+		if !ok {
+			return nil, fmt.Errorf("key %s not found", key)
+		}
+		return parseSecretObject(value)
+	}
+	result := make(map[string]any)
+	for k, v := range secret.Data {
+		result[k] = string(v)
+	}
+	return result, nil
+}
+
+// fileBackend reads mounted secrets under its root, references are paths relative to the root. A reference to a
+// file should contain a json object of the credential fields while a reference to a directory uses each file in it
+// as a credential field, like kubernetes secret volumes.
+type fileBackend struct {
+	rootPath string // Cleaned absolute path with symlinks resolved
+}
+
+func newFileBackend(rootPath string) (fileBackend, error) {
+	root, err := filepath.Abs(rootPath)
+	if err != nil {
+		return fileBackend{}, err
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return fileBackend{}, err
+	}
+	return fileBackend{rootPath: root}, nil
+}
+
+// within reports whether path is the root or under it
+func (b fileBackend) within(path string) bool {
+	rel, err := filepath.Rel(b.rootPath, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func (b fileBackend) resolve(_ context.Context, reference string) (map[string]any, error) {
+	// Cleaning the reference as an absolute path drops any "..", so the joined path stays under the root
+	path := filepath.Join(b.rootPath, filepath.Clean(string(filepath.Separator)+reference))
+	// Symlinks, like the ones of kubernetes secret volumes, must not lead out of the root either
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+	if !b.within(path) {
+		return nil, fmt.Errorf("path is outside of the secrets root")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return parseSecretObject(content)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]any)
+	for _, entry := range entries {
+		// kubernetes volumes keep the data in hidden timestamped directories linked from the key files
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		entryPath, err := filepath.EvalSymlinks(filepath.Join(path, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if !b.within(entryPath) {
+			return nil, fmt.Errorf("path is outside of the secrets root")
+		}
+		content, err := os.ReadFile(entryPath)
+		if err != nil {
+			return nil, err
+		}
+		result[entry.Name()] = strings.TrimRight(string(content), "\n")
+	}
+	return result, nil
+}
+
+func parseSecretObject(content []byte) (map[string]any, error) {
+	var result map[string]any
+	if err := json.Unmarshal(content, &result); err != nil {
+		return nil, fmt.Errorf("secret should be a json object: %w", err)
+	}
+	return result, nil
+}
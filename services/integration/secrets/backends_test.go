@@ -0,0 +1,90 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestFileBackendStaysUnderRoot(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "aws"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "aws", "creds.json"), []byte(`{"key":"value"}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "outside.json"), []byte(`{"key":"leak"}`), 0o600))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "outside.json"), filepath.Join(root, "link.json")))
+
+	b, err := newFileBackend(root)
+	require.NoError(t, err)
+
+	secret, err := b.resolve(context.Background(), "aws/creds.json")
+	require.NoError(t, err)
+	assert.Equal(t, "value", secret["key"])
+
+	// Absolute references are relative to the root as well
+	secret, err = b.resolve(context.Background(), "/aws/creds.json")
+	require.NoError(t, err)
+	assert.Equal(t, "value", secret["key"])
+
+	_, err = b.resolve(context.Background(), "../outside.json")
+	assert.Error(t, err)
+	_, err = b.resolve(context.Background(), filepath.Join(dir, "outside.json"))
+	assert.Error(t, err)
+	_, err = b.resolve(context.Background(), "link.json")
+	assert.Error(t, err, "symlinks must not lead out of the root")
+}
+
+func TestFileBackendDirectory(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "secret"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "secret", "client_id"), []byte("id\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "secret", ".hidden"), []byte("skip"), 0o600))
+
+	b, err := newFileBackend(root)
+	require.NoError(t, err)
+	secret, err := b.resolve(context.Background(), "secret")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"client_id": "id"}, secret)
+}
+
+func TestKubernetesBackendNamespace(t *testing.T) {
+	kubeClient := fake.NewClientBuilder().WithObjects(
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "platform", Name: "creds"}, Data: map[string][]byte{"key": []byte("value")}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "creds"}, Data: map[string][]byte{"key": []byte("other")}},
+	).Build()
+	b := kubernetesBackend{client: kubeClient, namespace: "platform"}
+
+	secret, err := b.resolve(context.Background(), "creds")
+	require.NoError(t, err)
+	assert.Equal(t, "value", secret["key"])
+
+	secret, err = b.resolve(context.Background(), "platform/creds")
+	require.NoError(t, err)
+	assert.Equal(t, "value", secret["key"])
+
+	_, err = b.resolve(context.Background(), "kube-system/creds")
+	assert.Error(t, err)
+}
+
+func TestResolverCacheEviction(t *testing.T) {
+	r := &Resolver{cache: make(map[string]cacheEntry)}
+	now := time.Now()
+	for i := 0; i < maxCacheEntries; i++ {
+		r.storeLocked(fmt.Sprintf("key-%d", i), cacheEntry{expiresAt: now.Add(time.Duration(i+1) * time.Minute)})
+	}
+	r.storeLocked("new", cacheEntry{expiresAt: now.Add(time.Hour)})
+
+	assert.Len(t, r.cache, maxCacheEntries)
+	assert.Contains(t, r.cache, "new")
+	assert.NotContains(t, r.cache, "key-0", "the entry closest to expiry is evicted")
+	assert.Contains(t, r.cache, "key-1")
+}
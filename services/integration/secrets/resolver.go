@@ -0,0 +1,249 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/opengovern/og-util/pkg/vault"
+	"github.com/opengovern/opensecurity/services/integration/config"
+	"github.com/opengovern/opensecurity/services/integration/db"
+	"github.com/opengovern/opensecurity/services/integration/models"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	defaultCacheTTL = 5 * time.Minute
+	maxCacheEntries = 1024 // Resolved secrets kept at most, the ones closest to expiry are evicted first
+)
+
+// ErrSecretNotResolved is returned to callers in place of the backend error, which can tell whether
+// a path or secret exists. The detailed error is logged and kept in the resolution audit.
+var ErrSecretNotResolved = errors.New("secret reference could not be resolved")
+
+// ErrBackendNotConfigured is returned for references to a backend the service is not configured for
+var ErrBackendNotConfigured = errors.New("secret backend is not configured")
+
+type backend interface {
+	// resolve reads the secret the reference points to
+	resolve(ctx context.Context, reference string) (map[string]any, error)
+}
+
+type cacheEntry struct {
+	secret    map[string]any
+	expiresAt time.Time
+}
+
+// Resolver returns the secret of a credential, either decrypting the stored secret or resolving the reference
+// to an external backend. Resolved references are cached and every read from a backend is audited.
+type Resolver struct {
+	logger   *zap.Logger
+	vault    vault.VaultSourceConfig
+	database db.Database
+	backends map[models.CredentialSecretBackend]backend
+	cacheTTL time.Duration
+
+	cacheLock sync.Mutex
+	cache     map[string]cacheEntry
+}
+
+func NewResolver(
+	logger *zap.Logger,
+	vault vault.VaultSourceConfig,
+	database db.Database,
+	kubeClient client.Client,
+	cfg config.SecretBackendsConfig,
+) (*Resolver, error) {
+	cacheTTL := defaultCacheTTL
+	if cfg.CacheTTLSeconds > 0 {
+		cacheTTL = time.Duration(cfg.CacheTTLSeconds) * time.Second
+	}
+
+	r := &Resolver{
+		logger:   logger.Named("secrets"),
+		vault:    vault,
+		database: database,
+		backends: make(map[models.CredentialSecretBackend]backend),
+		cacheTTL: cacheTTL,
+		cache:    make(map[string]cacheEntry),
+	}
+
+	if cfg.HashicorpVault.Address != "" {
+		vaultConfig := vaultapi.DefaultConfig()
+		vaultConfig.Address = cfg.HashicorpVault.Address
+		vaultClient, err := vaultapi.NewClient(vaultConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create hashicorp vault client: %w", err)
+		}
+		if cfg.HashicorpVault.Token != "" {
+			vaultClient.SetToken(cfg.HashicorpVault.Token)
+		}
+		if cfg.HashicorpVault.Namespace != "" {
+			vaultClient.SetNamespace(cfg.HashicorpVault.Namespace)
+		}
+		r.backends[models.CredentialSecretBackendHashicorpVault] = hashicorpVaultBackend{client: vaultClient}
+	}
+
+	if kubeClient != nil {
+		namespace := cfg.KubernetesNamespace
+		if namespace == "" {
+			namespace = os.Getenv("CURRENT_NAMESPACE")
+		}
+		if namespace != "" {
+			r.backends[models.CredentialSecretBackendKubernetes] = kubernetesBackend{client: kubeClient, namespace: namespace}
+		} else {
+			r.logger.Warn("kubernetes secret backend disabled, no namespace configured")
+		}
+	}
+
+	// Mounted secrets are only readable under an explicitly configured root
+	if cfg.FileRootPath != "" {
+		fb, err := newFileBackend(cfg.FileRootPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret file root path: %w", err)
+		}
+		r.backends[models.CredentialSecretBackendFile] = fb
+	}
+
+	return r, nil
+}
+
+// Supports returns whether secret references to the backend can be resolved
+func (r *Resolver) Supports(secretBackend models.CredentialSecretBackend) bool {
+	_, ok := r.backends[secretBackend]
+	return ok
+}
+
+// Resolve returns the secret of the credential
+func (r *Resolver) Resolve(ctx context.Context, credential *models.Credential, purpose models.CredentialSecretPurpose) (map[string]any, error) {
+	if !credential.IsSecretReference() {
+		return r.vault.Decrypt(ctx, credential.Secret)
+	}
+
+	cacheKey := fmt.Sprintf("%s|%s|%s", credential.ID, credential.SecretBackend, credential.SecretReference)
+	r.cacheLock.Lock()
+	entry, ok := r.cache[cacheKey]
+	r.cacheLock.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		// Cache hits are not audited, the read they reuse already was
+		return copySecret(entry.secret), nil
+	}
+
+	secret, err := r.resolveReference(ctx, credential.SecretBackend, credential.SecretReference)
+	r.audit(credential.ID, credential.SecretBackend, credential.SecretReference, purpose, err)
+	if err != nil {
+		return nil, r.publicError(credential.SecretBackend, credential.SecretReference, err)
+	}
+
+	r.cacheLock.Lock()
+	r.storeLocked(cacheKey, cacheEntry{secret: secret, expiresAt: time.Now().Add(r.cacheTTL)})
+	r.cacheLock.Unlock()
+
+	return copySecret(secret), nil
+}
+
+// ResolveReference reads a secret reference without caching, used to validate references before they are saved
+// to the credential. The read is audited like the ones of Resolve. Failures return ErrSecretNotResolved or
+// ErrBackendNotConfigured, the details are only logged and audited.
+func (r *Resolver) ResolveReference(ctx context.Context, credentialID uuid.UUID, secretBackend models.CredentialSecretBackend, reference string, purpose models.CredentialSecretPurpose) (map[string]any, error) {
+	secret, err := r.resolveReference(ctx, secretBackend, reference)
+	r.audit(credentialID, secretBackend, reference, purpose, err)
+	if err != nil {
+		return nil, r.publicError(secretBackend, reference, err)
+	}
+	return secret, nil
+}
+
+func (r *Resolver) resolveReference(ctx context.Context, secretBackend models.CredentialSecretBackend, reference string) (map[string]any, error) {
+	b, ok := r.backends[secretBackend]
+	if !ok {
+		return nil, ErrBackendNotConfigured
+	}
+	if reference == "" {
+		return nil, fmt.Errorf("secret reference is empty")
+	}
+	secret, err := b.resolve(ctx, reference)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s secret %s: %w", secretBackend, reference, err)
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("%s secret %s is empty", secretBackend, reference)
+	}
+	return secret, nil
+}
+
+// publicError logs the detailed resolution error and returns the one callers may show
+func (r *Resolver) publicError(secretBackend models.CredentialSecretBackend, reference string, err error) error {
+	if errors.Is(err, ErrBackendNotConfigured) {
+		return err
+	}
+	r.logger.Warn("failed to resolve secret reference", zap.String("backend", string(secretBackend)),
+		zap.String("reference", reference), zap.Error(err))
+	return ErrSecretNotResolved
+}
+
+// storeLocked caches an entry, dropping expired entries and then the ones closest to expiry when the cache is full.
+// The caller holds cacheLock.
+func (r *Resolver) storeLocked(key string, entry cacheEntry) {
+	if _, ok := r.cache[key]; !ok && len(r.cache) >= maxCacheEntries {
+		now := time.Now()
+		for k, e := range r.cache {
+			if !now.Before(e.expiresAt) {
+				delete(r.cache, k)
+			}
+		}
+		for len(r.cache) >= maxCacheEntries {
+			var oldestKey string
+			var oldest time.Time
+			for k, e := range r.cache {
+				if oldestKey == "" || e.expiresAt.Before(oldest) {
+					oldestKey, oldest = k, e.expiresAt
+				}
+			}
+			delete(r.cache, oldestKey)
+		}
+	}
+	r.cache[key] = entry
+}
+
+// Invalidate drops the cached secret of the credential so the next use reads it again
+func (r *Resolver) Invalidate(credentialID string) {
+	r.cacheLock.Lock()
+	defer r.cacheLock.Unlock()
+	for key := range r.cache {
+		if strings.HasPrefix(key, credentialID+"|") {
+			delete(r.cache, key)
+		}
+	}
+}
+
+func (r *Resolver) audit(credentialID uuid.UUID, secretBackend models.CredentialSecretBackend, reference string, purpose models.CredentialSecretPurpose, err error) {
+	resolution := models.CredentialSecretResolution{
+		CredentialID:    credentialID,
+		SecretBackend:   secretBackend,
+		SecretReference: reference,
+		Purpose:         purpose,
+		Success:         err == nil,
+	}
+	if err != nil {
+		resolution.Error = err.Error()
+	}
+	if dbErr := r.database.CreateCredentialSecretResolution(&resolution); dbErr != nil {
+		r.logger.Error("failed to audit secret resolution", zap.String("credentialId", credentialID.String()), zap.Error(dbErr))
+	}
+}
+
+func copySecret(secret map[string]any) map[string]any {
+	result := make(map[string]any, len(secret))
+	for k, v := range secret {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,61 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/opengovern/opensecurity/services/integration/db"
+	"github.com/opengovern/opensecurity/services/integration/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestResolveReferenceAudit(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	orm, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "creds.json"), []byte(`{"key":"value"}`), 0o600))
+	fb, err := newFileBackend(root)
+	require.NoError(t, err)
+	r := &Resolver{
+		logger:   zap.NewNop(),
+		database: db.NewDatabase(orm, orm),
+		backends: map[models.CredentialSecretBackend]backend{models.CredentialSecretBackendFile: fb},
+		cache:    make(map[string]cacheEntry),
+	}
+
+	credentialID := uuid.New()
+	expectAudit := func(reference string, success bool) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "credential_secret_resolutions"`).
+			WithArgs(credentialID, models.CredentialSecretBackendFile, reference, models.CredentialSecretPurposeValidation,
+				success, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+	}
+
+	expectAudit("creds.json", true)
+	secret, err := r.ResolveReference(context.Background(), credentialID, models.CredentialSecretBackendFile, "creds.json",
+		models.CredentialSecretPurposeValidation)
+	require.NoError(t, err)
+	assert.Equal(t, "value", secret["key"])
+
+	// failed reads are audited with the detailed error, the caller only gets the public one
+	expectAudit("missing.json", false)
+	_, err = r.ResolveReference(context.Background(), credentialID, models.CredentialSecretBackendFile, "missing.json",
+		models.CredentialSecretPurposeValidation)
+	assert.ErrorIs(t, err, ErrSecretNotResolved)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
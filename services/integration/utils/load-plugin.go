@@ -104,7 +104,7 @@ func ExtractIntegrationBinaries(logger *zap.Logger, plugin platformspec.PluginSp
 		err = downloader.Get()
 		if err != nil {
 			logger.Error("failed to get integration binaries", zap.Error(err), zap.String("url", url))
-			return nil, nil, fmt.Errorf("get integration binaries for url %s: %w", plugin, err)
+			return nil, nil, fmt.Errorf("get integration binaries for url %s: %w", url, err)
 		}
 
 		//// read manifest file
@@ -118,7 +118,7 @@ func ExtractIntegrationBinaries(logger *zap.Logger, plugin platformspec.PluginSp
 		// decode yaml
 		if err := yaml.Unmarshal(manifestFile, &m); err != nil {
 			logger.Error("failed to decode manifest", zap.Error(err), zap.String("url", plugin.Components.PlatformBinary.URI))
-			return nil, nil, fmt.Errorf("decode manifest for url %s: %w", plugin, err)
+			return nil, nil, fmt.Errorf("decode manifest for url %s: %w", plugin.Components.PlatformBinary.URI, err)
 		}
 
 		logger.Info("manifestFile", zap.String("file", string(manifestFile)), zap.Any("manifest", m))
@@ -140,7 +140,7 @@ func ExtractIntegrationBinaries(logger *zap.Logger, plugin platformspec.PluginSp
 			err = downloader.Get()
 			if err != nil {
 				logger.Error("failed to get integration binaries", zap.Error(err), zap.String("url", url))
-				return nil, nil, fmt.Errorf("get integration binaries for url %s: %w", plugin, err)
+				return nil, nil, fmt.Errorf("get integration binaries for url %s: %w", url, err)
 			}
 		} else {
 			url = plugin.Components.CloudQLBinary.URI
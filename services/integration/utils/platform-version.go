@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/opengovern/og-util/pkg/api"
+	"github.com/opengovern/og-util/pkg/httpclient"
+	coreClient "github.com/opengovern/opensecurity/services/core/client"
+	"golang.org/x/net/context"
+)
+
+// GetPlatformVersion returns the version of the running platform
+func GetPlatformVersion(ctx context.Context, client coreClient.CoreServiceClient) (string, error) {
+	about, err := client.GetAbout(&httpclient.Context{UserRole: api.AdminRole, Ctx: ctx})
+	if err != nil {
+		return "", err
+	}
+	return about.AppVersion, nil
+}
+
+// CheckPlatformVersion returns whether the platform version satisfies the supported versions of a plugin and the
+// reason if it does not. Supported versions are semantic version constraints (e.g. ">= 2.1.0, < 3.0.0") or the
+// comma separated patterns of the plugin manifests where X matches any number (e.g. "v2.X.X, v3.0.X").
+// Plugins without supported versions are compatible with every platform version and every plugin is compatible
+// with development builds which have no semantic version. Prereleases and builds are checked as the version they
+// lead to, e.g. 2.1.0-rc.1 satisfies ">= 2.1.0" and not "< 2.1.0".
+func CheckPlatformVersion(platformVersion string, supportedVersions string) (bool, string) {
+	supportedVersions = strings.TrimSpace(supportedVersions)
+	if supportedVersions == "" {
+		return true, ""
+	}
+	version, err := semver.NewVersion(platformVersion)
+	if err != nil {
+		return true, fmt.Sprintf("platform version %q is not a semantic version", platformVersion)
+	}
+	if version.Prerelease() != "" || version.Metadata() != "" {
+		if version, err = semver.NewVersion(fmt.Sprintf("%d.%d.%d", version.Major(), version.Minor(), version.Patch())); err != nil {
+			return true, fmt.Sprintf("platform version %q is not a semantic version", platformVersion)
+		}
+	}
+
+	constraintStr := supportedVersions
+	if !strings.ContainsAny(supportedVersions, "<>=~^!|") {
+		var patterns []string
+		for _, pattern := range strings.Split(supportedVersions, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				patterns = append(patterns, strings.ReplaceAll(pattern, "X", "x"))
+			}
+		}
+		constraintStr = strings.Join(patterns, " || ")
+	}
+	constraint, err := semver.NewConstraint(constraintStr)
+	if err != nil {
+		return false, fmt.Sprintf("invalid supported platform versions %q: %v", supportedVersions, err)
+	}
+	if !constraint.Check(version) {
+		return false, fmt.Sprintf("plugin supports platform versions %s, platform version is %s", supportedVersions, platformVersion)
+	}
+	return true, ""
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPlatformVersion(t *testing.T) {
+	tests := []struct {
+		name              string
+		platformVersion   string
+		supportedVersions string
+		compatible        bool
+	}{
+		{"no supported versions", "v2.4.0", "", true},
+		{"blank supported versions", "v2.4.0", "  ", true},
+		{"development build", "dev", ">= 2.0.0", true},
+
+		{"pattern match", "v2.4.1", "v2.X.X", true},
+		{"pattern without prefix", "2.4.1", "v2.X.X", true},
+		{"pattern mismatch", "v3.0.0", "v2.X.X", false},
+		{"one of the patterns", "v3.0.7", "v2.X.X, v3.0.X", true},
+		{"none of the patterns", "v3.1.0", "v2.X.X, v3.0.X", false},
+
+		{"constraint lower bound", "v2.1.0", ">= 2.1.0, < 3.0.0", true},
+		{"constraint below", "v2.0.9", ">= 2.1.0, < 3.0.0", false},
+		{"constraint upper bound", "v3.0.0", ">= 2.1.0, < 3.0.0", false},
+		{"caret constraint", "v2.9.3", "^2.1", true},
+		{"tilde constraint", "v2.2.0", "~2.1", false},
+		{"or constraint", "v4.0.0", "< 3.0.0 || >= 4.0.0", true},
+
+		{"prerelease of a supported version", "v2.1.0-rc.1", ">= 2.1.0", true},
+		{"prerelease of an unsupported version", "v2.1.0-rc.1", "< 2.1.0", false},
+		{"prerelease matching a pattern", "v2.4.0-beta.2", "v2.X.X", true},
+		{"build metadata", "v2.4.0+abc123", ">= 2.1.0, < 3.0.0", true},
+		{"prerelease and build metadata", "v3.0.0-rc.1+abc123", ">= 2.1.0, < 3.0.0", false},
+
+		{"invalid constraint", "v2.4.0", ">= two", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compatible, reason := CheckPlatformVersion(tt.platformVersion, tt.supportedVersions)
+			assert.Equal(t, tt.compatible, compatible, reason)
+			if !compatible {
+				assert.NotEmpty(t, reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,332 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/opengovern/opensecurity/services/integration/config"
+	"github.com/opengovern/opensecurity/services/integration/models"
+	"golang.org/x/crypto/blake2b"
+)
+
+type minisignKey struct {
+	id  [8]byte
+	key ed25519.PublicKey
+}
+
+type pemKey struct {
+	key any
+	// id is the hex encoded sha256 of the DER public key
+	id string
+}
+
+// PluginVerifier checks plugin binaries against the checksums of their manifest and the checksums against the
+// manifest signature using the configured trusted keys
+type PluginVerifier struct {
+	requireSignature bool
+	minisignKeys     []minisignKey
+	pemKeys          []pemKey
+	gpgKeys          openpgp.EntityList
+}
+
+type PluginVerificationResult struct {
+	Status                  models.IntegrationPluginVerificationStatus
+	Signer                  string
+	Message                 string
+	IntegrationPluginSHA256 string
+	CloudQLPluginSHA256     string
+	Signature               models.PluginSignature
+}
+
+// Apply records the verification result on the plugin
+func (r PluginVerificationResult) Apply(plugin *models.IntegrationPlugin) {
+	now := time.Now()
+	plugin.IntegrationPluginSHA256 = r.IntegrationPluginSHA256
+	plugin.CloudQLPluginSHA256 = r.CloudQLPluginSHA256
+	plugin.VerificationStatus = r.Status
+	plugin.VerificationSigner = r.Signer
+	plugin.VerificationMessage = r.Message
+	plugin.SignatureType = r.Signature.Type
+	plugin.Signature = r.Signature.Value
+	plugin.VerifiedAt = &now
+}
+
+func NewPluginVerifier(cnf config.PluginVerificationConfig) (*PluginVerifier, error) {
+	v := &PluginVerifier{requireSignature: cnf.RequireSignature}
+
+	keys := cnf.TrustedKeys
+	if cnf.TrustedKeysPath != "" {
+		entries, err := os.ReadDir(cnf.TrustedKeysPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trusted keys: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			content, err := os.ReadFile(filepath.Join(cnf.TrustedKeysPath, entry.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read trusted key %s: %w", entry.Name(), err)
+			}
+			keys = append(keys, string(content))
+		}
+	}
+
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		switch {
+		case key == "":
+			continue
+		case strings.HasPrefix(key, "-----BEGIN PGP PUBLIC KEY BLOCK-----"):
+			entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key))
+			if err != nil {
+				return nil, fmt.Errorf("invalid gpg key: %w", err)
+			}
+			v.gpgKeys = append(v.gpgKeys, entities...)
+		case strings.HasPrefix(key, "-----BEGIN"):
+			block, _ := pem.Decode([]byte(key))
+			if block == nil {
+				return nil, fmt.Errorf("invalid pem key")
+			}
+			pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pem key: %w", err)
+			}
+			digest := sha256.Sum256(block.Bytes)
+			v.pemKeys = append(v.pemKeys, pemKey{key: pub, id: hex.EncodeToString(digest[:])})
+		default:
+			k, err := parseMinisignKey(key)
+			if err != nil {
+				return nil, err
+			}
+			v.minisignKeys = append(v.minisignKeys, *k)
+		}
+	}
+
+	return v, nil
+}
+
+// Verify checks the plugin binaries, it returns an error if the plugin should not be loaded
+func (v *PluginVerifier) Verify(m models.Manifest, integrationPlugin, cloudqlPlugin []byte) (PluginVerificationResult, error) {
+	result := PluginVerificationResult{
+		Status:                  models.IntegrationPluginVerificationStatusUnverified,
+		IntegrationPluginSHA256: sha256Hex(integrationPlugin),
+		CloudQLPluginSHA256:     sha256Hex(cloudqlPlugin),
+	}
+	fail := func(format string, args ...any) (PluginVerificationResult, error) {
+		result.Status = models.IntegrationPluginVerificationStatusFailed
+		result.Message = fmt.Sprintf(format, args...)
+		return result, fmt.Errorf("plugin verification failed: %s", result.Message)
+	}
+
+	hasChecksums := m.Checksums.IntegrationPlugin != "" || m.Checksums.CloudQLPlugin != ""
+	if hasChecksums {
+		if !strings.EqualFold(m.Checksums.IntegrationPlugin, result.IntegrationPluginSHA256) {
+			return fail("integration-plugin checksum mismatch")
+		}
+		if !strings.EqualFold(m.Checksums.CloudQLPlugin, result.CloudQLPluginSHA256) {
+			return fail("cloudql-plugin checksum mismatch")
+		}
+		result.Status = models.IntegrationPluginVerificationStatusChecksumOnly
+		result.Message = "checksums match, plugin is not signed"
+	}
+
+	if m.Signature.Value != "" {
+		if !hasChecksums {
+			return fail("plugin is signed without checksums")
+		}
+		signer, err := v.verifySignature(m.Signature, PluginSignaturePayload(m))
+		if err != nil {
+			return fail("%v", err)
+		}
+		result.Status = models.IntegrationPluginVerificationStatusVerified
+		result.Signer = signer
+		result.Signature = m.Signature
+		result.Message = fmt.Sprintf("checksums and manifest match and are signed by trusted %s key", m.Signature.Type)
+	} else if result.Status == models.IntegrationPluginVerificationStatusUnverified {
+		result.Message = "plugin has no checksums or signature"
+	}
+
+	if v.requireSignature && result.Status != models.IntegrationPluginVerificationStatusVerified {
+		return fail("unsigned plugins are not allowed, %s", result.Message)
+	}
+	return result, nil
+}
+
+// PluginSignaturePayload is the content signed by a plugin signature: the checksums of the binaries in the sha256sum
+// format, one "<digest>  <file>" line for integration-plugin then cloudql-plugin, followed by one "<field>: <value>"
+// line for each manifest field deciding what the plugin runs as and where its describer comes from
+func PluginSignaturePayload(m models.Manifest) []byte {
+	var payload strings.Builder
+	fmt.Fprintf(&payload, "%s  integration-plugin\n", strings.ToLower(m.Checksums.IntegrationPlugin))
+	fmt.Fprintf(&payload, "%s  cloudql-plugin\n", strings.ToLower(m.Checksums.CloudQLPlugin))
+	fmt.Fprintf(&payload, "IntegrationType: %s\n", m.IntegrationType)
+	fmt.Fprintf(&payload, "DescriberURL: %s\n", m.DescriberURL)
+	fmt.Fprintf(&payload, "DescriberTag: %s\n", m.DescriberTag)
+	fmt.Fprintf(&payload, "SupportedPlatformVersion: %s\n", m.SupportedPlatformVersion)
+	return []byte(payload.String())
+}
+
+// VerifyInstalled checks an installed plugin before it is loaded: its binaries should not have changed since they
+// were verified and, when signatures are required, the stored signature should still cover them and the plugin
+// settings with one of the trusted keys
+func (v *PluginVerifier) VerifyInstalled(plugin models.IntegrationPlugin, binary models.IntegrationPluginBinary) error {
+	if err := VerifyPluginBinaryDigests(plugin, binary); err != nil {
+		return err
+	}
+	if !v.requireSignature {
+		return nil
+	}
+	if plugin.Signature == "" {
+		return fmt.Errorf("plugin %s is not signed, unsigned plugins are not allowed", plugin.PluginID)
+	}
+	m := models.Manifest{
+		IntegrationType:          plugin.IntegrationType,
+		DescriberURL:             plugin.DescriberURL,
+		DescriberTag:             plugin.DescriberTag,
+		SupportedPlatformVersion: plugin.SupportedPlatformVersion,
+		Checksums: models.PluginChecksums{
+			IntegrationPlugin: plugin.IntegrationPluginSHA256,
+			CloudQLPlugin:     plugin.CloudQLPluginSHA256,
+		},
+		Signature: models.PluginSignature{Type: plugin.SignatureType, Value: plugin.Signature},
+	}
+	_, err := v.Verify(m, binary.IntegrationPlugin, binary.CloudQlPlugin)
+	return err
+}
+
+// VerifyPluginBinaryDigests checks the stored binaries have not changed since they were verified
+func VerifyPluginBinaryDigests(plugin models.IntegrationPlugin, binary models.IntegrationPluginBinary) error {
+	if plugin.IntegrationPluginSHA256 != "" && plugin.IntegrationPluginSHA256 != sha256Hex(binary.IntegrationPlugin) {
+		return fmt.Errorf("integration-plugin binary of %s does not match its verified checksum", plugin.PluginID)
+	}
+	if plugin.CloudQLPluginSHA256 != "" && plugin.CloudQLPluginSHA256 != sha256Hex(binary.CloudQlPlugin) {
+		return fmt.Errorf("cloudql-plugin binary of %s does not match its verified checksum", plugin.PluginID)
+	}
+	return nil
+}
+
+func (v *PluginVerifier) verifySignature(signature models.PluginSignature, payload []byte) (string, error) {
+	switch signature.Type {
+	case models.PluginSignatureTypeMinisign:
+		return v.verifyMinisign(signature.Value, payload)
+	case models.PluginSignatureTypeCosign:
+		return v.verifyCosign(signature.Value, payload)
+	case models.PluginSignatureTypeGPG:
+		if len(v.gpgKeys) == 0 {
+			return "", fmt.Errorf("no trusted gpg keys")
+		}
+		entity, err := openpgp.CheckArmoredDetachedSignature(v.gpgKeys, bytes.NewReader(payload), strings.NewReader(signature.Value), nil)
+		if err != nil {
+			return "", fmt.Errorf("invalid gpg signature: %w", err)
+		}
+		return strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint)), nil
+	default:
+		return "", fmt.Errorf("unsupported signature type %q", signature.Type)
+	}
+}
+
+// verifyMinisign checks a minisign signature file, including the global signature of its trusted comment
+func (v *PluginVerifier) verifyMinisign(value string, payload []byte) (string, error) {
+	lines := strings.Split(strings.TrimSpace(value), "\n")
+	if len(lines) < 4 {
+		return "", fmt.Errorf("invalid minisign signature")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sig) != 74 {
+		return "", fmt.Errorf("invalid minisign signature")
+	}
+	trustedComment, ok := strings.CutPrefix(strings.TrimSpace(lines[2]), "trusted comment: ")
+	if !ok {
+		return "", fmt.Errorf("invalid minisign trusted comment")
+	}
+	globalSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return "", fmt.Errorf("invalid minisign global signature")
+	}
+
+	algorithm, keyID, signature := string(sig[:2]), sig[2:10], sig[10:]
+	message := payload
+	switch algorithm {
+	case "ED":
+		digest := blake2b.Sum512(payload)
+		message = digest[:]
+	case "Ed":
+	default:
+		return "", fmt.Errorf("unsupported minisign algorithm %q", algorithm)
+	}
+
+	for _, k := range v.minisignKeys {
+		if !bytes.Equal(k.id[:], keyID) {
+			continue
+		}
+		if !ed25519.Verify(k.key, message, signature) {
+			return "", fmt.Errorf("invalid minisign signature")
+		}
+		if !ed25519.Verify(k.key, append(append([]byte{}, signature...), trustedComment...), globalSig) {
+			return "", fmt.Errorf("invalid minisign global signature")
+		}
+		return strings.ToUpper(hex.EncodeToString(reverse(k.id[:]))), nil
+	}
+	return "", fmt.Errorf("minisign key %X is not trusted", reverse(keyID))
+}
+
+// verifyCosign checks a base64 signature made with cosign sign-blob
+func (v *PluginVerifier) verifyCosign(value string, payload []byte) (string, error) {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return "", fmt.Errorf("invalid cosign signature")
+	}
+	digest := sha256.Sum256(payload)
+	for _, k := range v.pemKeys {
+		switch key := k.key.(type) {
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(key, digest[:], sig) {
+				return k.id, nil
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(key, payload, sig) {
+				return k.id, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("cosign signature does not match any trusted key")
+}
+
+func parseMinisignKey(key string) (*minisignKey, error) {
+	lines := strings.Split(key, "\n")
+	encoded := strings.TrimSpace(lines[len(lines)-1])
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) != 42 || string(raw[:2]) != "Ed" {
+		return nil, fmt.Errorf("invalid minisign key")
+	}
+	k := minisignKey{key: ed25519.PublicKey(raw[10:])}
+	copy(k.id[:], raw[2:10])
+	return &k, nil
+}
+
+func sha256Hex(data []byte) string {
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:])
+}
+
+// reverse returns the key id in the little endian order minisign prints it
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[i] = b[len(b)-1-i]
+	}
+	return r
+}
@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/opengovern/opensecurity/services/integration/config"
+	"github.com/opengovern/opensecurity/services/integration/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testIntegrationPlugin = []byte("integration plugin binary")
+	testCloudQLPlugin     = []byte("cloudql plugin binary")
+)
+
+func testManifest() models.Manifest {
+	return models.Manifest{
+		IntegrationType:          "aws_cloud_account",
+		DescriberURL:             "ghcr.io/opengovern/og-describer-aws",
+		DescriberTag:             "v1.2.3",
+		SupportedPlatformVersion: ">=2.0.0",
+		Checksums: models.PluginChecksums{
+			IntegrationPlugin: sha256Hex(testIntegrationPlugin),
+			CloudQLPlugin:     sha256Hex(testCloudQLPlugin),
+		},
+	}
+}
+
+// cosignKey returns an ed25519 key pair with the PEM public key cosign prints
+func cosignKey(t *testing.T) (string, ed25519.PrivateKey) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), priv
+}
+
+// minisignKey returns a minisign public key and a function signing with it the way minisign -S does
+func testMinisignKey(t *testing.T) (string, func(payload []byte) string) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keyID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	publicKey := "untrusted comment: minisign public key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), pub...))
+	sign := func(payload []byte) string {
+		signature := ed25519.Sign(priv, payload)
+		trustedComment := "timestamp:1700000000"
+		globalSignature := ed25519.Sign(priv, append(append([]byte{}, signature...), trustedComment...))
+		return strings.Join([]string{
+			"untrusted comment: signature from minisign secret key",
+			base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), signature...)),
+			"trusted comment: " + trustedComment,
+			base64.StdEncoding.EncodeToString(globalSignature),
+		}, "\n")
+	}
+	return publicKey, sign
+}
+
+func TestVerifySignedManifest(t *testing.T) {
+	publicKey, priv := cosignKey(t)
+	verifier, err := NewPluginVerifier(config.PluginVerificationConfig{RequireSignature: true, TrustedKeys: []string{publicKey}})
+	require.NoError(t, err)
+
+	m := testManifest()
+	m.Signature = models.PluginSignature{
+		Type:  models.PluginSignatureTypeCosign,
+		Value: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, PluginSignaturePayload(m))),
+	}
+	result, err := verifier.Verify(m, testIntegrationPlugin, testCloudQLPlugin)
+	require.NoError(t, err)
+	assert.Equal(t, models.IntegrationPluginVerificationStatusVerified, result.Status)
+	assert.Equal(t, m.Signature, result.Signature)
+
+	// The signature covers the manifest, pointing the plugin to another describer breaks it
+	tampered := m
+	tampered.DescriberURL = "ghcr.io/attacker/describer"
+	result, err = verifier.Verify(tampered, testIntegrationPlugin, testCloudQLPlugin)
+	assert.Error(t, err)
+	assert.Equal(t, models.IntegrationPluginVerificationStatusFailed, result.Status)
+
+	tampered = m
+	tampered.SupportedPlatformVersion = "*"
+	_, err = verifier.Verify(tampered, testIntegrationPlugin, testCloudQLPlugin)
+	assert.Error(t, err)
+
+	_, err = verifier.Verify(m, []byte("other binary"), testCloudQLPlugin)
+	assert.ErrorContains(t, err, "checksum mismatch")
+}
+
+func TestVerifyMinisign(t *testing.T) {
+	publicKey, sign := testMinisignKey(t)
+	verifier, err := NewPluginVerifier(config.PluginVerificationConfig{TrustedKeys: []string{publicKey}})
+	require.NoError(t, err)
+
+	m := testManifest()
+	m.Signature = models.PluginSignature{Type: models.PluginSignatureTypeMinisign, Value: sign(PluginSignaturePayload(m))}
+	result, err := verifier.Verify(m, testIntegrationPlugin, testCloudQLPlugin)
+	require.NoError(t, err)
+	assert.Equal(t, models.IntegrationPluginVerificationStatusVerified, result.Status)
+	assert.Equal(t, "0807060504030201", result.Signer)
+
+	// Signing only the checksums is not enough
+	checksums := strings.Join(strings.SplitAfter(string(PluginSignaturePayload(m)), "\n")[:2], "")
+	m.Signature.Value = sign([]byte(checksums))
+	_, err = verifier.Verify(m, testIntegrationPlugin, testCloudQLPlugin)
+	assert.Error(t, err)
+}
+
+func TestVerifyUnsigned(t *testing.T) {
+	verifier, err := NewPluginVerifier(config.PluginVerificationConfig{})
+	require.NoError(t, err)
+	result, err := verifier.Verify(testManifest(), testIntegrationPlugin, testCloudQLPlugin)
+	require.NoError(t, err)
+	assert.Equal(t, models.IntegrationPluginVerificationStatusChecksumOnly, result.Status)
+
+	strict, err := NewPluginVerifier(config.PluginVerificationConfig{RequireSignature: true})
+	require.NoError(t, err)
+	_, err = strict.Verify(testManifest(), testIntegrationPlugin, testCloudQLPlugin)
+	assert.Error(t, err)
+}
+
+func TestVerifyInstalled(t *testing.T) {
+	publicKey, priv := cosignKey(t)
+	verifier, err := NewPluginVerifier(config.PluginVerificationConfig{RequireSignature: true, TrustedKeys: []string{publicKey}})
+	require.NoError(t, err)
+
+	m := testManifest()
+	m.Signature = models.PluginSignature{
+		Type:  models.PluginSignatureTypeCosign,
+		Value: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, PluginSignaturePayload(m))),
+	}
+	result, err := verifier.Verify(m, testIntegrationPlugin, testCloudQLPlugin)
+	require.NoError(t, err)
+	plugin := models.IntegrationPlugin{
+		PluginID:                 string(m.IntegrationType),
+		IntegrationType:          m.IntegrationType,
+		DescriberURL:             m.DescriberURL,
+		DescriberTag:             m.DescriberTag,
+		SupportedPlatformVersion: m.SupportedPlatformVersion,
+	}
+	result.Apply(&plugin)
+	binary := models.IntegrationPluginBinary{IntegrationPlugin: testIntegrationPlugin, CloudQlPlugin: testCloudQLPlugin}
+	assert.NoError(t, verifier.VerifyInstalled(plugin, binary))
+
+	changed := plugin
+	changed.DescriberTag = "latest"
+	assert.Error(t, verifier.VerifyInstalled(changed, binary), "plugin settings changed since they were signed")
+
+	unsigned := plugin
+	unsigned.Signature = ""
+	assert.Error(t, verifier.VerifyInstalled(unsigned, binary))
+
+	binary.CloudQlPlugin = []byte("replaced")
+	assert.Error(t, verifier.VerifyInstalled(plugin, binary))
+}
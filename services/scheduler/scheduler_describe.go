@@ -147,7 +147,7 @@ func (s *Scheduler) RunDescribeResourceJobCycle(ctx context.Context, manuals boo
 			integrationsMap[dc.IntegrationID] = integration
 		}
 
-		credential, err := s.integrationClient.GetCredential(&httpclient.Context{UserRole: apiAuth.AdminRole}, integration.CredentialID)
+		credential, err := s.integrationClient.ResolveCredential(&httpclient.Context{UserRole: apiAuth.AdminRole}, integration.CredentialID, "describe")
 		if err != nil {
 			s.logger.Error("failed to get credential", zap.String("spot", "GetCredentialByUUID"), zap.Error(err), zap.Uint("jobID", dc.ID))
 			DescribeResourceJobsCount.WithLabelValues("failure", "get_credential").Inc()
@@ -1074,6 +1074,9 @@ func removeDuplicates(s []string) []string {
 //	@Router		/schedule/api/v3/jobs/discovery/connections/{connection_id} [post]
 func (h HttpServer) GetDescribeJobsHistory(ctx echo.Context) error {
 	connectionId := ctx.Param("connection_id")
+	if err := httpserver.CheckAccessToConnectionID(ctx, connectionId); err != nil {
+		return err
+	}
 
 	var request api.GetDescribeJobsHistoryRequest
 	if err := ctx.Bind(&request); err != nil {
@@ -1164,6 +1167,9 @@ func (h HttpServer) GetComplianceJobsHistory(ctx echo.Context) error {
 	}
 
 	connectionId := ctx.Param("connection_id")
+	if err := httpserver.CheckAccessToConnectionID(ctx, connectionId); err != nil {
+		return err
+	}
 
 	jobs, err := h.DB.ListComplianceJobsByFilters(nil, []string{connectionId}, request.BenchmarkId, request.JobStatus, &request.StartTime, request.EndTime)
 	if err != nil {
@@ -1982,6 +1988,9 @@ func (h HttpServer) ListDescribeJobs(ctx echo.Context) error {
 	connectionInfo := make(map[string]api.IntegrationInfo)
 	var connectionIDs []string
 	for _, c := range integrations {
+		if httpserver.CheckAccessToConnectionID(ctx, c.IntegrationID) != nil {
+			continue
+		}
 		connectionInfo[c.IntegrationID] = api.IntegrationInfo{
 			IntegrationID:   c.IntegrationID,
 			IntegrationType: string(c.IntegrationType),
@@ -1990,6 +1999,11 @@ func (h HttpServer) ListDescribeJobs(ctx echo.Context) error {
 		}
 		connectionIDs = append(connectionIDs, c.IntegrationID)
 	}
+	// Without integration filters the jobs of every integration in the caller scope are listed
+	connectionIDs, err := httpserver.ResolveConnectionIDs(ctx, connectionIDs)
+	if err != nil {
+		return err
+	}
 
 	var jobsResults []api.GetDescribeJobsHistoryResponse
 	var startTime, endTime *time.Time
@@ -2127,8 +2141,16 @@ func (h HttpServer) ListComplianceJobs(ctx echo.Context) error {
 
 	var connectionIDs []string
 	for _, c := range integrations {
+		if httpserver.CheckAccessToConnectionID(ctx, c.IntegrationID) != nil {
+			continue
+		}
 		connectionIDs = append(connectionIDs, c.IntegrationID)
 	}
+	// Without integration filters the jobs of every integration in the caller scope are listed
+	connectionIDs, err := httpserver.ResolveConnectionIDs(ctx, connectionIDs)
+	if err != nil {
+		return err
+	}
 	var startTime, endTime *time.Time
 	if request.Interval != nil {
 		startTime, endTime, _ = parseTimeInterval(*request.Interval)
@@ -2483,6 +2505,9 @@ func (h HttpServer) GetIntegrationLastDiscoveryJob(ctx echo.Context) error {
 		}
 		integration = connectionsTmp.Integrations[0]
 	}
+	if err := httpserver.CheckAccessToConnectionID(ctx, integration.IntegrationID); err != nil {
+		return err
+	}
 
 	job, err := h.DB.ListDescribeJobs(integration.IntegrationID)
 	if err != nil {
@@ -2541,6 +2566,9 @@ func (h HttpServer) GetDescribeJobsHistoryByIntegration(ctx echo.Context) error
 
 	connectionInfo := make(map[string]api.IntegrationInfo)
 	for _, c := range integrations {
+		if httpserver.CheckAccessToConnectionID(ctx, c.IntegrationID) != nil {
+			continue
+		}
 		connectionInfo[c.IntegrationID] = api.IntegrationInfo{
 			IntegrationID:   c.IntegrationID,
 			IntegrationType: string(c.IntegrationType),
@@ -2660,6 +2688,9 @@ func (h HttpServer) GetComplianceJobsHistoryByIntegration(ctx echo.Context) erro
 		}
 		integrations = connectionsTmp.Integrations[0]
 	}
+	if err := httpserver.CheckAccessToConnectionID(ctx, integrations.IntegrationID); err != nil {
+		return err
+	}
 
 	connectionInfo := make(map[string]api.IntegrationInfo)
 	connectionInfo[integrations.IntegrationID] = api.IntegrationInfo{
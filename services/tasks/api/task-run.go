@@ -5,17 +5,32 @@ import (
 )
 
 type TaskRun struct {
-	ID             uint      `json:"id"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-	TaskID         string    `json:"task_id"`
-	Status         string    `json:"status"`
-	Result         map[string]any    `json:"result"`
-	Params 	   map[string]any    `json:"params"`
-	FailureMessage string    `json:"failure_message"`
+	ID             uint           `json:"id"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	TaskID         string         `json:"task_id"`
+	Status         string         `json:"status"`
+	TriggerType    string         `json:"trigger_type"`
+	TriggeredBy    string         `json:"triggered_by"`
+	IntegrationID  string         `json:"integration_id,omitempty"`
+	Result         map[string]any `json:"result"`
+	Params         map[string]any `json:"params"`
+	FailureMessage string         `json:"failure_message"`
+	ParentRunID    *uint          `json:"parent_run_id,omitempty"`
+	Priority       int            `json:"priority"`
+	DependsOn      []uint         `json:"depends_on,omitempty"`
+	Archived       bool           `json:"archived,omitempty"`
 }
 
 type ListTaskRunsResponse struct {
-	TotalCount int              `json:"total_count"`
+	TotalCount int       `json:"total_count"`
 	Items      []TaskRun `json:"items"`
 }
+
+// TaskRunRetentionPolicy removes finished runs beyond the latest MaxRuns or older than MaxAgeDays,
+// optionally archiving their params and results to the archive database first. Zero values disable the limit.
+type TaskRunRetentionPolicy struct {
+	MaxRuns        int  `json:"max_runs"`
+	MaxAgeDays     int  `json:"max_age_days"`
+	ArchiveResults bool `json:"archive_results"`
+}
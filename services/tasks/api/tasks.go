@@ -1,5 +1,7 @@
 package api
 
+import "time"
+
 type TaskListResponse struct {
 	Items      []TaskResponse `json:"items"`
 	TotalCount int            `json:"total_count"`
@@ -23,6 +25,8 @@ type TaskDetailsResponse struct {
 	EnvVars      map[string]string   `json:"env_vars"`
 	Params       []string            `json:"params"`
 	ScaleConfig  ScaleConfig         `json:"scale_config"`
+	// CredentialsCheck is set if the task can validate its config, which health checks and rotation need
+	CredentialsCheck bool `json:"credentials_check"`
 }
 
 type ScaleConfig struct {
@@ -34,19 +38,81 @@ type ScaleConfig struct {
 
 	PollingInterval int32 `json:"polling_interval" yaml:"polling_interval"`
 	CooldownPeriod  int32 `json:"cooldown_period" yaml:"cooldown_period"`
+
+	RunLimits `yaml:",inline"`
+}
+
+// RunLimits controls how many runs of a task can be queued and in progress and in which order they are published.
+// A zero MaxConcurrentRuns and unset priorities fall back to the defaults.
+type RunLimits struct {
+	MaxConcurrentRuns    int       `json:"max_concurrent_runs" yaml:"max_concurrent_runs"`
+	MaxQueuedRuns        int       `json:"max_queued_runs" yaml:"max_queued_runs"`
+	ManualRunPriority    *int      `json:"manual_run_priority,omitempty" yaml:"manual_run_priority,omitempty"`
+	ScheduledRunPriority *int      `json:"scheduled_run_priority,omitempty" yaml:"scheduled_run_priority,omitempty"`
+	ManualRunQuota       *RunQuota `json:"manual_run_quota,omitempty" yaml:"manual_run_quota,omitempty"`
+}
+
+// RunQuota limits the number of manual runs a single user can trigger for a task in the given period (e.g. 24h)
+type RunQuota struct {
+	MaxRuns int    `json:"max_runs" yaml:"max_runs"`
+	Period  string `json:"period" yaml:"period"`
 }
 
 type RunScheduleObject struct {
-	ID        string         `json:"id"`
-	Params    map[string]any `json:"params"`
-	Frequency float64        `json:"frequency"`
+	ID           string             `json:"id"`
+	Params       map[string]any     `json:"params"`
+	Frequency    float64            `json:"frequency"`
+	Integrations *IntegrationTarget `json:"integrations,omitempty"`
 }
 
 type RunTaskRequest struct {
-	TaskID string         `json:"task_id"`
-	Params map[string]any `json:"params"`
+	TaskID       string              `json:"task_id"`
+	Params       map[string]any      `json:"params"`
+	DependsOn    []TaskRunDependency `json:"depends_on"`
+	Integrations *IntegrationTarget  `json:"integrations"`
+}
+
+// IntegrationTarget selects the integrations a task runs against, one run is created per matched integration
+// with the integration and its credentials passed in the "integration" param.
+type IntegrationTarget struct {
+	IntegrationIDs    []string          `json:"integration_ids,omitempty" yaml:"integration_ids"`
+	IntegrationGroups []string          `json:"integration_groups,omitempty" yaml:"integration_groups"`
+	Labels            map[string]string `json:"labels,omitempty" yaml:"labels"`
+}
+
+func (t IntegrationTarget) IsEmpty() bool {
+	return len(t.IntegrationIDs) == 0 && len(t.IntegrationGroups) == 0 && len(t.Labels) == 0
+}
+
+// TaskRunDependency declares an upstream of a task run. Either RunID of an existing run or TaskID of a task
+// to start a new upstream run of (with Params) should be set.
+// The upstream result is passed to the run as ParamName (defaults to upstream_<run id>). If FanOutKey is set,
+// one run is created per item of the list found under that key in the upstream result.
+type TaskRunDependency struct {
+	RunID     *uint          `json:"run_id"`
+	TaskID    string         `json:"task_id"`
+	Params    map[string]any `json:"params"`
+	ParamName string         `json:"param_name"`
+	FanOutKey string         `json:"fan_out_key"`
 }
 
 type TaskConfigSecret struct {
 	Credentials map[string]any `json:"credentials"`
 }
+
+type TaskConfigHealthCheck struct {
+	RunID          uint      `json:"run_id"`
+	Staged         bool      `json:"staged"`
+	HealthStatus   string    `json:"health_status"`
+	FailureMessage string    `json:"failure_message,omitempty"`
+	CheckedAt      time.Time `json:"checked_at"`
+}
+
+type TaskConfigHealthResponse struct {
+	TaskID             string                  `json:"task_id"`
+	HealthStatus       string                  `json:"health_status"`
+	LastCheckedAt      *time.Time              `json:"last_checked_at"`
+	StagedHealthStatus string                  `json:"staged_health_status,omitempty"`
+	StagedAt           *time.Time              `json:"staged_at,omitempty"`
+	History            []TaskConfigHealthCheck `json:"history"`
+}
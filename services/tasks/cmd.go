@@ -14,6 +14,7 @@ import (
 	"github.com/opengovern/og-util/pkg/vault"
 	"github.com/opengovern/opensecurity/pkg/utils"
 	core "github.com/opengovern/opensecurity/services/core/client"
+	integrationClient "github.com/opengovern/opensecurity/services/integration/client"
 	"github.com/opengovern/opensecurity/services/tasks/config"
 	"github.com/opengovern/opensecurity/services/tasks/db"
 	"github.com/opengovern/opensecurity/services/tasks/scheduler"
@@ -84,6 +85,24 @@ func start(ctx context.Context) error {
 	dbm := db.Database{Orm: orm}
 	fmt.Println("Connected to the postgres database: ", cfg.Postgres.DB)
 
+	if cfg.ArchivePostgres.Host != "" {
+		archivePostgresCfg := postgres.Config{
+			Host:    cfg.ArchivePostgres.Host,
+			Port:    cfg.ArchivePostgres.Port,
+			User:    cfg.ArchivePostgres.Username,
+			Passwd:  cfg.ArchivePostgres.Password,
+			DB:      cfg.ArchivePostgres.DB,
+			SSLMode: cfg.ArchivePostgres.SSLMode,
+		}
+		dbm.ArchiveOrm, err = postgres.NewClient(&archivePostgresCfg, logger)
+		if err != nil {
+			return fmt.Errorf("new archive postgres client: %w", err)
+		}
+		fmt.Println("Connected to the archive postgres database: ", cfg.ArchivePostgres.DB)
+	} else {
+		logger.Warn("archive postgres is not configured, task runs can not be archived by retention policies")
+	}
+
 	// setup postgres connection
 	itPostgresConfig := postgres.Config{
 		Host:    cfg.Postgres.Host,
@@ -117,7 +136,9 @@ func start(ctx context.Context) error {
 		return err
 	}
 
-	mainScheduler, err := scheduler.NewMainScheduler(cfg, logger, dbm, kubeClient, vaultSc, jq)
+	itClient := integrationClient.NewIntegrationServiceClient(cfg.Integration.BaseURL)
+
+	mainScheduler, err := scheduler.NewMainScheduler(cfg, logger, dbm, kubeClient, vaultSc, jq, itClient)
 	if err != nil {
 		return err
 	}
@@ -131,12 +152,26 @@ func start(ctx context.Context) error {
 		mainScheduler.CreateTaskScheduler(ctx)
 	})
 
+	utils.EnsureRunGoroutine(func() {
+		mainScheduler.DependencyResolverScheduler(ctx)
+	})
+
+	utils.EnsureRunGoroutine(func() {
+		mainScheduler.CredentialsHealthCheckScheduler(ctx)
+	})
+
+	utils.EnsureRunGoroutine(func() {
+		mainScheduler.RetentionCleanerScheduler(ctx)
+	})
+
 	return httpserver.RegisterAndStart(ctx, logger, cfg.Http.Address, &httpRoutes{
 		logger: logger,
 		db:     dbm,
 		itDb:   itDbm,
 		jq:     jq,
 		vault:  vaultSc,
+
+		integrationClient: itClient,
 	})
 }
 
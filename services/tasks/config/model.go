@@ -13,6 +13,12 @@ type Config struct {
 	ElasticSearch config.ElasticSearch
 	Vault         vault.Config                `yaml:"vault" koanf:"vault"`
 	Core          koanf.OpenGovernanceService `yaml:"core" koanf:"core"`
+	Integration   koanf.OpenGovernanceService `yaml:"integration" koanf:"integration"`
+
+	// ArchivePostgres is the database expired task runs are archived to, archiving is disabled if its host is empty
+	ArchivePostgres koanf.Postgres `yaml:"archive_postgres" koanf:"archive_postgres"`
 
 	ESSinkEndpoint string `yaml:"essink_endpoint" koanf:"essink_endpoint"`
+
+	CredentialsHealthCheckIntervalMinutes int `yaml:"credentials_health_check_interval_minutes" koanf:"credentials_health_check_interval_minutes"`
 }
@@ -7,10 +7,13 @@ import (
 	"github.com/opengovern/opensecurity/services/tasks/db/models"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"time"
 )
 
 type Database struct {
 	Orm *gorm.DB
+	// ArchiveOrm is the database the retention cleaner archives task runs to, nil if archiving is not configured
+	ArchiveOrm *gorm.DB
 }
 
 func (db Database) Initialize() error {
@@ -19,11 +22,20 @@ func (db Database) Initialize() error {
 		&models.TaskRun{},
 		&models.TaskConfigSecret{},
 		&models.TaskRunSchedule{},
+		&models.TaskRunDependency{},
+		&models.TaskConfigSecretHealthCheck{},
+		&models.TaskRunRetentionPolicy{},
 	)
 	if err != nil {
 		return err
 	}
 
+	if db.ArchiveOrm != nil {
+		if err = db.ArchiveOrm.AutoMigrate(&models.TaskRunArchive{}); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -47,6 +59,18 @@ func (db Database) UpdateTask(id string, task *models.Task) error {
 	return nil
 }
 
+// WithTaskLock runs f in a transaction holding a row lock on the task, so the runs of a task created by concurrent
+// callers are checked against its run limits one at a time
+func (db Database) WithTaskLock(taskID string, f func(tx Database) error) error {
+	return db.Orm.Transaction(func(tx *gorm.DB) error {
+		var task models.Task
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", taskID).First(&task).Error; err != nil {
+			return err
+		}
+		return f(Database{Orm: tx, ArchiveOrm: db.ArchiveOrm})
+	})
+}
+
 // GetTask retrieves a task by Task name
 func (db Database) GetTask(id string) (*models.Task, error) {
 	var task models.Task
@@ -89,12 +113,15 @@ func (db Database) ListTaskRunResult(taskId string) ([]models.TaskRun, error) {
 	return task, nil
 }
 
-// FetchCreatedTaskRunsByTaskID retrieves a list of task runs
-func (db Database) FetchCreatedTaskRunsByTaskID(taskID string) ([]models.TaskRun, error) {
+// FetchCreatedTaskRunsByTaskID retrieves at most limit created task runs, highest priority and oldest first
+func (db Database) FetchCreatedTaskRunsByTaskID(taskID string, limit int) ([]models.TaskRun, error) {
 	var tasks []models.TaskRun
 	tx := db.Orm.Model(&models.TaskRun{}).
 		Where("task_id = ?", taskID).
 		Where("status = ?", models.TaskRunStatusCreated).
+		Order("priority desc").
+		Order("created_at asc").
+		Limit(limit).
 		Find(&tasks)
 	if tx.Error != nil {
 		return nil, tx.Error
@@ -117,6 +144,37 @@ func (db Database) CountInProgressTaskRunsByTaskID(taskID string) (*int64, error
 	return &count, nil
 }
 
+// CountCreatedTaskRunsByTaskID counts the task runs waiting to be published
+func (db Database) CountCreatedTaskRunsByTaskID(taskID string) (int64, error) {
+	var count int64
+	tx := db.Orm.Model(&models.TaskRun{}).
+		Where("task_id = ?", taskID).
+		Where("status = ?", models.TaskRunStatusCreated).
+		Count(&count)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+
+	return count, nil
+}
+
+// CountManualTaskRunsByUser counts the manual task runs triggered by the user since the given time
+func (db Database) CountManualTaskRunsByUser(taskID, userID string, since time.Time) (int64, error) {
+	var count int64
+	tx := db.Orm.Model(&models.TaskRun{}).
+		Where("task_id = ?", taskID).
+		Where("trigger_type = ?", models.TriggerTypeManual).
+		Where("triggered_by = ?", userID).
+		Where("parent_run_id IS NULL").
+		Where("created_at >= ?", since).
+		Count(&count)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+
+	return count, nil
+}
+
 // FetchLastCreatedTaskRunsByTaskID retrieves last task runs
 func (db Database) FetchLastCreatedTaskRunsByTaskID(taskID string) (*models.TaskRun, error) {
 	var task models.TaskRun
@@ -180,6 +238,147 @@ func (db Database) CreateTaskRun(taskRun *models.TaskRun) error {
 	return nil
 }
 
+// TaskRunDependencyInput is a dependency of a new task run. If NewUpstreamRun is set, the upstream run
+// is created alongside the run and Dependency.UpstreamRunID is filled with its ID.
+type TaskRunDependencyInput struct {
+	Dependency     models.TaskRunDependency
+	NewUpstreamRun *models.TaskRun
+}
+
+// CreateTaskRunWithDependencies creates a task run, its new upstream runs and the dependencies between them
+func (db Database) CreateTaskRunWithDependencies(taskRun *models.TaskRun, dependencies []TaskRunDependencyInput) error {
+	return db.Orm.Transaction(func(tx *gorm.DB) error {
+		for _, dependency := range dependencies {
+			if dependency.NewUpstreamRun == nil {
+				continue
+			}
+			if err := tx.Create(dependency.NewUpstreamRun).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(taskRun).Error; err != nil {
+			return err
+		}
+
+		for _, dependency := range dependencies {
+			dep := dependency.Dependency
+			dep.RunID = taskRun.ID
+			if dependency.NewUpstreamRun != nil {
+				dep.UpstreamRunID = dependency.NewUpstreamRun.ID
+			}
+			if err := tx.Create(&dep).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetTaskRunDependencies retrieves the upstream dependencies of a task run
+func (db Database) GetTaskRunDependencies(runID uint) ([]models.TaskRunDependency, error) {
+	var dependencies []models.TaskRunDependency
+	tx := db.Orm.Model(&models.TaskRunDependency{}).
+		Where("run_id = ?", runID).
+		Find(&dependencies)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return dependencies, nil
+}
+
+// GetTaskRunsByIDs retrieves task runs by their ids
+func (db Database) GetTaskRunsByIDs(ids []uint) ([]models.TaskRun, error) {
+	var runs []models.TaskRun
+	tx := db.Orm.Model(&models.TaskRun{}).
+		Where("id IN ?", ids).
+		Find(&runs)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return runs, nil
+}
+
+// FetchTaskRunsByStatus retrieves all task runs with the given status
+func (db Database) FetchTaskRunsByStatus(status models.TaskRunStatus) ([]models.TaskRun, error) {
+	var runs []models.TaskRun
+	tx := db.Orm.Model(&models.TaskRun{}).
+		Where("status = ?", status).
+		Order("created_at asc").
+		Find(&runs)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return runs, nil
+}
+
+// ListChildTaskRuns retrieves the fan-out children of a task run
+func (db Database) ListChildTaskRuns(parentRunID uint) ([]models.TaskRun, error) {
+	var runs []models.TaskRun
+	tx := db.Orm.Model(&models.TaskRun{}).
+		Where("parent_run_id = ?", parentRunID).
+		Order("id asc").
+		Find(&runs)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return runs, nil
+}
+
+// ReleaseWaitingTaskRun sets the resolved params of a waiting task run and hands it over to the publisher
+func (db Database) ReleaseWaitingTaskRun(runID uint, params pgtype.JSONB) error {
+	tx := db.Orm.Model(&models.TaskRun{}).
+		Where("id = ?", runID).
+		Where("status = ?", models.TaskRunStatusWaiting).
+		Updates(models.TaskRun{Status: models.TaskRunStatusCreated, Params: params})
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+// CreateFanOutTaskRun creates a fanned out task run together with its child runs
+func (db Database) CreateFanOutTaskRun(parent *models.TaskRun, children []models.TaskRun) error {
+	return db.Orm.Transaction(func(tx *gorm.DB) error {
+		parent.Status = models.TaskRunStatusFannedOut
+		if err := tx.Create(parent).Error; err != nil {
+			return err
+		}
+		for i := range children {
+			children[i].ParentRunID = &parent.ID
+		}
+		if len(children) > 0 {
+			if err := tx.Create(&children).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FanOutTaskRun creates the child runs of a waiting task run and marks it as fanned out. Nothing is created
+// if the run is no longer waiting, e.g. it was cancelled or fanned out by another scheduler.
+func (db Database) FanOutTaskRun(runID uint, children []models.TaskRun) error {
+	return db.Orm.Transaction(func(tx *gorm.DB) error {
+		update := tx.Model(&models.TaskRun{}).
+			Where("id = ?", runID).
+			Where("status = ?", models.TaskRunStatusWaiting).
+			Update("status", models.TaskRunStatusFannedOut)
+		if update.Error != nil {
+			return update.Error
+		}
+		if update.RowsAffected == 0 || len(children) == 0 {
+			return nil
+		}
+		return tx.Create(&children).Error
+	})
+}
+
 // UpdateTaskRun creates a task result
 func (db Database) UpdateTaskRun(runID uint, status models.TaskRunStatus, result pgtype.JSONB, failureMessage string) error {
 	tx := db.Orm.Where("id = ?", runID).Updates(&models.TaskRun{
@@ -259,3 +458,144 @@ func (db Database) GetTaskConfigSecret(taskId string) (*models.TaskConfigSecret,
 
 	return &configSecret, nil
 }
+
+// ListTaskConfigSecrets retrieves the config secrets of all tasks
+func (db Database) ListTaskConfigSecrets() ([]models.TaskConfigSecret, error) {
+	var configSecrets []models.TaskConfigSecret
+	tx := db.Orm.Model(&models.TaskConfigSecret{}).Find(&configSecrets)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return configSecrets, nil
+}
+
+func (db Database) SetTaskConfigSecretHealthStatus(taskId string, healthStatus models.TaskSecretHealthStatus, checkedAt time.Time) error {
+	tx := db.Orm.Model(&models.TaskConfigSecret{}).Where("task_id = ?", taskId).Updates(map[string]any{
+		"health_status":   healthStatus,
+		"last_checked_at": checkedAt,
+	})
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+func (db Database) SetTaskConfigSecretLastCheckedAt(taskId string, checkedAt time.Time) error {
+	tx := db.Orm.Model(&models.TaskConfigSecret{}).Where("task_id = ?", taskId).Update("last_checked_at", checkedAt)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+// StageTaskConfigSecret stores a rotated secret next to the active one until it is validated
+func (db Database) StageTaskConfigSecret(taskId string, secret string) error {
+	tx := db.Orm.Model(&models.TaskConfigSecret{}).Where("task_id = ?", taskId).Updates(map[string]any{
+		"staged_secret":        secret,
+		"staged_health_status": models.TaskSecretHealthStatusUnknown,
+		"staged_at":            time.Now(),
+		"staged_run_id":        nil,
+	})
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+func (db Database) SetStagedTaskConfigSecretRunID(taskId string, runID uint) error {
+	tx := db.Orm.Model(&models.TaskConfigSecret{}).Where("task_id = ?", taskId).Update("staged_run_id", runID)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+func (db Database) UpdateStagedTaskConfigSecretHealthStatus(taskId string, healthStatus models.TaskSecretHealthStatus) error {
+	tx := db.Orm.Model(&models.TaskConfigSecret{}).Where("task_id = ?", taskId).Update("staged_health_status", healthStatus)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+// PromoteStagedTaskConfigSecret swaps the active secret with the validated staged secret
+func (db Database) PromoteStagedTaskConfigSecret(taskId string) error {
+	tx := db.Orm.Model(&models.TaskConfigSecret{}).
+		Where("task_id = ?", taskId).
+		Where("staged_secret <> ''").
+		Updates(map[string]any{
+			"secret":               gorm.Expr("staged_secret"),
+			"health_status":        models.TaskSecretHealthStatusHealthy,
+			"last_checked_at":      time.Now(),
+			"staged_secret":        "",
+			"staged_health_status": "",
+			"staged_at":            nil,
+			"staged_run_id":        nil,
+		})
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+// DiscardStagedTaskConfigSecret removes the staged secret and keeps the active one
+func (db Database) DiscardStagedTaskConfigSecret(taskId string) error {
+	tx := db.Orm.Model(&models.TaskConfigSecret{}).Where("task_id = ?", taskId).Updates(map[string]any{
+		"staged_secret":        "",
+		"staged_health_status": "",
+		"staged_at":            nil,
+		"staged_run_id":        nil,
+	})
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+func (db Database) CreateTaskConfigSecretHealthCheck(check *models.TaskConfigSecretHealthCheck) error {
+	tx := db.Orm.Create(check)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+// ListTaskConfigSecretHealthChecks retrieves the latest health checks of a task config secret
+func (db Database) ListTaskConfigSecretHealthChecks(taskId string, limit int) ([]models.TaskConfigSecretHealthCheck, error) {
+	var checks []models.TaskConfigSecretHealthCheck
+	tx := db.Orm.Model(&models.TaskConfigSecretHealthCheck{}).
+		Where("task_id = ?", taskId).
+		Order("checked_at desc").
+		Limit(limit).
+		Find(&checks)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return checks, nil
+}
+
+// CountPendingCredentialsCheckRuns counts the credentials check runs of a secret which are not done yet
+func (db Database) CountPendingCredentialsCheckRuns(taskID string, target string) (int64, error) {
+	var count int64
+	tx := db.Orm.Model(&models.TaskRun{}).
+		Where("task_id = ?", taskID).
+		Where("trigger_type = ?", models.TriggerTypeCredentialsCheck).
+		Where("triggered_by = ?", target).
+		Where("status IN ?", []models.TaskRunStatus{models.TaskRunStatusCreated, models.TaskRunStatusQueued, models.TaskRunStatusInProgress}).
+		Count(&count)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+
+	return count, nil
+}
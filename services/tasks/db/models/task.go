@@ -4,6 +4,7 @@ import (
 	"github.com/jackc/pgtype"
 	"github.com/lib/pq"
 	"gorm.io/gorm"
+	"time"
 )
 
 type TaskSecretHealthStatus string
@@ -30,6 +31,8 @@ type Task struct {
 	EnvVars             pgtype.JSONB
 	Params              pq.StringArray `gorm:"type:text[]"`
 	Configs             pq.StringArray `gorm:"type:text[]"`
+	// CredentialsCheck is set for tasks which validate their config secret on runs with the validate_credentials param
+	CredentialsCheck bool `gorm:"not null;default:false"`
 }
 
 type TaskBinary struct {
@@ -39,14 +42,33 @@ type TaskBinary struct {
 }
 
 type TaskConfigSecret struct {
-	TaskID       string `gorm:"primarykey"`
-	Secret       string
-	HealthStatus TaskSecretHealthStatus
+	TaskID        string `gorm:"primarykey"`
+	Secret        string
+	HealthStatus  TaskSecretHealthStatus
+	LastCheckedAt *time.Time
+
+	// StagedSecret is a rotated secret which replaces Secret once a credentials check run validates it
+	StagedSecret       string
+	StagedHealthStatus TaskSecretHealthStatus
+	StagedAt           *time.Time
+	StagedRunID        *uint
+}
+
+// TaskConfigSecretHealthCheck is a single health check result of a task config secret
+type TaskConfigSecretHealthCheck struct {
+	ID             uint   `gorm:"primarykey"`
+	TaskID         string `gorm:"index"`
+	RunID          uint
+	Staged         bool
+	HealthStatus   TaskSecretHealthStatus
+	FailureMessage string
+	CheckedAt      time.Time
 }
 
 type TaskRunSchedule struct {
-	ID        string `gorm:"primarykey"`
-	TaskID    string `gorm:"primarykey"`
-	Params    pgtype.JSONB
-	Frequency float64
+	ID                string `gorm:"primarykey"`
+	TaskID            string `gorm:"primarykey"`
+	Params            pgtype.JSONB
+	Frequency         float64
+	IntegrationTarget pgtype.JSONB
 }
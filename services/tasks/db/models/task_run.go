@@ -3,6 +3,7 @@ package models
 import (
 	"github.com/jackc/pgtype"
 	"gorm.io/gorm"
+	"time"
 )
 
 type TaskRunStatus string
@@ -15,13 +16,38 @@ const (
 	TaskRunStatusFailed     TaskRunStatus = "FAILED"
 	TaskRunStatusTimeout    TaskRunStatus = "TIMEOUT"
 	TaskRunStatusCancelled  TaskRunStatus = "CANCELLED"
+	// TaskRunStatusWaiting is used for runs which are waiting for their upstream runs to finish
+	TaskRunStatusWaiting TaskRunStatus = "WAITING"
+	// TaskRunStatusFannedOut is used for runs which are split into one child run per upstream item
+	TaskRunStatusFannedOut TaskRunStatus = "FANNED_OUT"
 )
 
+var TerminalTaskRunStatuses = []TaskRunStatus{
+	TaskRunStatusFinished, TaskRunStatusFailed, TaskRunStatusTimeout, TaskRunStatusCancelled,
+}
+
+func (s TaskRunStatus) IsTerminal() bool {
+	for _, status := range TerminalTaskRunStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
 type TriggerType string
 
 const (
 	TriggerTypeManual    TriggerType = "MANUAL"
 	TriggerTypeScheduled TriggerType = "SCHEDULED"
+	// TriggerTypeCredentialsCheck is used for runs validating the task config secret,
+	// TriggeredBy is set to the checked secret (CredentialsCheckTargetActive or CredentialsCheckTargetStaged)
+	TriggerTypeCredentialsCheck TriggerType = "CREDENTIALS_CHECK"
+)
+
+const (
+	CredentialsCheckTargetActive = "active"
+	CredentialsCheckTargetStaged = "staged"
 )
 
 type TaskRun struct {
@@ -33,4 +59,44 @@ type TaskRun struct {
 	TriggerType    TriggerType
 	TriggeredBy    string
 	FailureMessage string
+	ParentRunID    *uint  `gorm:"index"`
+	Priority       int    `gorm:"not null;default:0"`
+	IntegrationID  string `gorm:"index"`
+}
+
+// TaskRunDependency declares that RunID can only be published after UpstreamRunID has finished successfully.
+// The upstream result is passed to the run as the ParamName param. If FanOutKey is set, the upstream result
+// should contain a list under that key and one child run is created per item instead.
+type TaskRunDependency struct {
+	RunID         uint `gorm:"primarykey"`
+	UpstreamRunID uint `gorm:"primarykey"`
+	ParamName     string
+	FanOutKey     string
+}
+
+// TaskRunRetentionPolicy limits how many finished runs of a task are kept. Runs beyond MaxRuns or older
+// than MaxAgeDays are removed by the retention cleaner, zero values disable the limit.
+type TaskRunRetentionPolicy struct {
+	TaskID         string `gorm:"primarykey"`
+	MaxRuns        int
+	MaxAgeDays     int
+	ArchiveResults bool
+	UpdatedAt      time.Time
+}
+
+// TaskRunArchive keeps the compressed params and result of a task run removed by the retention cleaner
+type TaskRunArchive struct {
+	RunID          uint   `gorm:"primarykey"`
+	TaskID         string `gorm:"index"`
+	Status         TaskRunStatus
+	TriggerType    TriggerType
+	TriggeredBy    string
+	FailureMessage string
+	ParentRunID    *uint
+	IntegrationID  string `gorm:"index"`
+	Params         []byte `gorm:"type:bytea"`
+	Result         []byte `gorm:"type:bytea"`
+	RunCreatedAt   time.Time
+	RunUpdatedAt   time.Time
+	ArchivedAt     time.Time
 }
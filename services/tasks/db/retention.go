@@ -0,0 +1,326 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/opengovern/opensecurity/services/tasks/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidResultJSONPath is returned when the ResultJSONPath filter is rejected by Postgres
+	ErrInvalidResultJSONPath = errors.New("invalid result jsonpath")
+	// ErrArchiveNotConfigured is returned when task runs should be archived but no archive database is configured
+	ErrArchiveNotConfigured = errors.New("task run archive database is not configured")
+)
+
+type TaskRunFilters struct {
+	Statuses       []models.TaskRunStatus
+	TriggerTypes   []models.TriggerType
+	TriggeredBy    string
+	IntegrationID  string
+	CreatedAfter   *time.Time
+	CreatedBefore  *time.Time
+	ResultJSONPath string
+}
+
+// ListTaskRunResultPaginated retrieves a page of the task runs matching the filters and the total count of matches.
+// Runs of all tasks are listed if taskId is empty. ResultJSONPath is a Postgres SQL/JSON path which should match the run result.
+func (db Database) ListTaskRunResultPaginated(taskId string, filters TaskRunFilters, limit, offset int) ([]models.TaskRun, int64, error) {
+	tx := db.Orm.Model(&models.TaskRun{})
+	if taskId != "" {
+		tx = tx.Where("task_id = ?", taskId)
+	}
+	if len(filters.Statuses) > 0 {
+		tx = tx.Where("status IN ?", filters.Statuses)
+	}
+	if len(filters.TriggerTypes) > 0 {
+		tx = tx.Where("trigger_type IN ?", filters.TriggerTypes)
+	}
+	if filters.TriggeredBy != "" {
+		tx = tx.Where("triggered_by = ?", filters.TriggeredBy)
+	}
+	if filters.IntegrationID != "" {
+		tx = tx.Where("integration_id = ?", filters.IntegrationID)
+	}
+	if filters.CreatedAfter != nil {
+		tx = tx.Where("created_at >= ?", *filters.CreatedAfter)
+	}
+	if filters.CreatedBefore != nil {
+		tx = tx.Where("created_at <= ?", *filters.CreatedBefore)
+	}
+	if filters.ResultJSONPath != "" {
+		tx = tx.Where("jsonb_path_exists(result, ?::jsonpath)", filters.ResultJSONPath)
+	}
+
+	var count int64
+	if err := tx.Count(&count).Error; err != nil {
+		return nil, 0, resultJSONPathError(filters, err)
+	}
+
+	var runs []models.TaskRun
+	tx = tx.Order("id desc")
+	if limit > 0 {
+		tx = tx.Limit(limit).Offset(offset)
+	}
+	if err := tx.Find(&runs).Error; err != nil {
+		return nil, 0, resultJSONPathError(filters, err)
+	}
+
+	return runs, count, nil
+}
+
+// resultJSONPathError wraps the errors Postgres raises for a malformed or failing SQL/JSON path
+// with ErrInvalidResultJSONPath
+func resultJSONPathError(filters TaskRunFilters, err error) error {
+	if filters.ResultJSONPath == "" {
+		return err
+	}
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	// 42601 is a syntax error in the path, class 22 the SQL/JSON data exceptions raised while evaluating it
+	if pgErr.Code == "42601" || strings.HasPrefix(pgErr.Code, "22") {
+		return fmt.Errorf("%w: %s", ErrInvalidResultJSONPath, pgErr.Message)
+	}
+	return err
+}
+
+func (db Database) GetTaskRunRetentionPolicy(taskID string) (*models.TaskRunRetentionPolicy, error) {
+	var policy models.TaskRunRetentionPolicy
+	tx := db.Orm.Model(&models.TaskRunRetentionPolicy{}).Where("task_id = ?", taskID).First(&policy)
+	if tx.Error != nil {
+		if errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, tx.Error
+	}
+
+	return &policy, nil
+}
+
+func (db Database) ListTaskRunRetentionPolicies() ([]models.TaskRunRetentionPolicy, error) {
+	var policies []models.TaskRunRetentionPolicy
+	tx := db.Orm.Model(&models.TaskRunRetentionPolicy{}).Find(&policies)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	return policies, nil
+}
+
+func (db Database) SetTaskRunRetentionPolicy(policy models.TaskRunRetentionPolicy) error {
+	tx := db.Orm.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "task_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"max_runs", "max_age_days", "archive_results", "updated_at"}),
+	}).Create(&policy)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+func (db Database) DeleteTaskRunRetentionPolicy(taskID string) error {
+	tx := db.Orm.Where("task_id = ?", taskID).Delete(&models.TaskRunRetentionPolicy{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	return nil
+}
+
+// FetchExpiredTaskRuns retrieves at most limit finished task runs which are out of the retention policy.
+// Runs still needed by waiting or fanned out runs are never returned.
+func (db Database) FetchExpiredTaskRuns(policy models.TaskRunRetentionPolicy, limit int) ([]models.TaskRun, error) {
+	if policy.MaxRuns <= 0 && policy.MaxAgeDays <= 0 {
+		return nil, nil
+	}
+
+	tx := db.Orm.Model(&models.TaskRun{}).
+		Where("task_id = ?", policy.TaskID).
+		Where("status IN ?", models.TerminalTaskRunStatuses).
+		Where("id NOT IN (?)", db.Orm.Table("task_run_dependencies").
+			Select("task_run_dependencies.upstream_run_id").
+			Joins("JOIN task_runs ON task_runs.id = task_run_dependencies.run_id").
+			Where("task_runs.status = ?", models.TaskRunStatusWaiting)).
+		Where("(parent_run_id IS NULL OR parent_run_id NOT IN (?))", db.Orm.Model(&models.TaskRun{}).
+			Select("id").
+			Where("status = ?", models.TaskRunStatusFannedOut))
+
+	// fan-out children are kept with their parent run
+	const notLatest = "(id NOT IN (@latest) AND (parent_run_id IS NULL OR parent_run_id NOT IN (@latest)))"
+	switch {
+	case policy.MaxRuns > 0 && policy.MaxAgeDays > 0:
+		tx = tx.Where("(created_at < @before OR "+notLatest+")", map[string]any{
+			"before": time.Now().AddDate(0, 0, -policy.MaxAgeDays),
+			"latest": latestTaskRunIDs(db.Orm, policy),
+		})
+	case policy.MaxRuns > 0:
+		tx = tx.Where(notLatest, map[string]any{"latest": latestTaskRunIDs(db.Orm, policy)})
+	default:
+		tx = tx.Where("created_at < ?", time.Now().AddDate(0, 0, -policy.MaxAgeDays))
+	}
+
+	var runs []models.TaskRun
+	if err := tx.Order("id asc").Limit(limit).Find(&runs).Error; err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// latestTaskRunIDs selects the MaxRuns latest finished top level runs of the task. Runs still in progress and
+// fan-out children do not take the place of finished runs.
+func latestTaskRunIDs(orm *gorm.DB, policy models.TaskRunRetentionPolicy) *gorm.DB {
+	return orm.Model(&models.TaskRun{}).
+		Select("id").
+		Where("task_id = ?", policy.TaskID).
+		Where("status IN ?", models.TerminalTaskRunStatuses).
+		Where("parent_run_id IS NULL").
+		Order("created_at desc").
+		Limit(policy.MaxRuns)
+}
+
+// RemoveTaskRuns permanently deletes the task runs, storing their compressed params and results
+// in the archive database first if archive is set
+func (db Database) RemoveTaskRuns(runs []models.TaskRun, archive bool) error {
+	if len(runs) == 0 {
+		return nil
+	}
+	if archive && db.ArchiveOrm == nil {
+		return ErrArchiveNotConfigured
+	}
+
+	var ids []uint
+	var archives []models.TaskRunArchive
+	now := time.Now()
+	for _, run := range runs {
+		ids = append(ids, run.ID)
+		if !archive {
+			continue
+		}
+		params, err := compressJSONB(run.Params)
+		if err != nil {
+			return err
+		}
+		result, err := compressJSONB(run.Result)
+		if err != nil {
+			return err
+		}
+		archives = append(archives, models.TaskRunArchive{
+			RunID:          run.ID,
+			TaskID:         run.TaskID,
+			Status:         run.Status,
+			TriggerType:    run.TriggerType,
+			TriggeredBy:    run.TriggeredBy,
+			FailureMessage: run.FailureMessage,
+			ParentRunID:    run.ParentRunID,
+			IntegrationID:  run.IntegrationID,
+			Params:         params,
+			Result:         result,
+			RunCreatedAt:   run.CreatedAt,
+			RunUpdatedAt:   run.UpdatedAt,
+			ArchivedAt:     now,
+		})
+	}
+
+	// The archive lives in another database, so it is written first. Archiving is idempotent on run_id,
+	// runs whose deletion fails are archived again on the next pass.
+	if len(archives) > 0 {
+		if err := db.ArchiveOrm.Clauses(clause.OnConflict{DoNothing: true}).Create(&archives).Error; err != nil {
+			return fmt.Errorf("failed to archive task runs: %w", err)
+		}
+	}
+
+	return db.Orm.Transaction(func(tx *gorm.DB) error {
+		// dependencies are removed from both sides, none is left pointing to a deleted upstream run
+		if err := tx.Where("run_id IN ? OR upstream_run_id IN ?", ids, ids).Delete(&models.TaskRunDependency{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("id IN ?", ids).Delete(&models.TaskRun{}).Error
+	})
+}
+
+// GetArchivedTaskRun retrieves a task run removed by the retention cleaner from the archive.
+// It returns nil if the run is not archived or no archive database is configured.
+func (db Database) GetArchivedTaskRun(id string) (*models.TaskRun, error) {
+	if db.ArchiveOrm == nil {
+		return nil, nil
+	}
+
+	var archive models.TaskRunArchive
+	tx := db.ArchiveOrm.Model(&models.TaskRunArchive{}).Where("run_id = ?", id).First(&archive)
+	if tx.Error != nil {
+		if errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, tx.Error
+	}
+
+	run := models.TaskRun{
+		TaskID:         archive.TaskID,
+		Status:         archive.Status,
+		TriggerType:    archive.TriggerType,
+		TriggeredBy:    archive.TriggeredBy,
+		FailureMessage: archive.FailureMessage,
+		ParentRunID:    archive.ParentRunID,
+		IntegrationID:  archive.IntegrationID,
+	}
+	run.ID = archive.RunID
+	run.CreatedAt = archive.RunCreatedAt
+	run.UpdatedAt = archive.RunUpdatedAt
+
+	var err error
+	if run.Params, err = decompressJSONB(archive.Params); err != nil {
+		return nil, fmt.Errorf("failed to decompress params: %w", err)
+	}
+	if run.Result, err = decompressJSONB(archive.Result); err != nil {
+		return nil, fmt.Errorf("failed to decompress result: %w", err)
+	}
+
+	return &run, nil
+}
+
+func compressJSONB(jsonb pgtype.JSONB) ([]byte, error) {
+	data := []byte("{}")
+	if jsonb.Status == pgtype.Present && len(jsonb.Bytes) > 0 {
+		data = jsonb.Bytes
+	}
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompressJSONB(data []byte) (pgtype.JSONB, error) {
+	var jsonb pgtype.JSONB
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return jsonb, err
+	}
+	defer reader.Close()
+
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		return jsonb, err
+	}
+	err = jsonb.Set(decompressed)
+	return jsonb, err
+}
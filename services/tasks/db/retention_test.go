@@ -0,0 +1,100 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/opengovern/opensecurity/services/tasks/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func mockDatabase(t *testing.T) (Database, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	orm, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+	return Database{Orm: orm}, mock
+}
+
+func TestResultJSONPathError(t *testing.T) {
+	filters := TaskRunFilters{ResultJSONPath: "$.items[*"}
+
+	syntaxErr := fmt.Errorf("query: %w", &pgconn.PgError{Code: "42601", Message: `syntax error at end of jsonpath input`})
+	assert.ErrorIs(t, resultJSONPathError(filters, syntaxErr), ErrInvalidResultJSONPath)
+
+	evalErr := &pgconn.PgError{Code: "22038", Message: "single boolean result is expected"}
+	assert.ErrorIs(t, resultJSONPathError(filters, evalErr), ErrInvalidResultJSONPath)
+
+	// Other failures are not blamed on the path
+	connErr := &pgconn.PgError{Code: "57P01", Message: "terminating connection due to administrator command"}
+	assert.NotErrorIs(t, resultJSONPathError(filters, connErr), ErrInvalidResultJSONPath)
+	assert.NotErrorIs(t, resultJSONPathError(filters, errors.New("jsonpath")), ErrInvalidResultJSONPath)
+	assert.NotErrorIs(t, resultJSONPathError(TaskRunFilters{}, syntaxErr), ErrInvalidResultJSONPath)
+}
+
+func TestRemoveTaskRunsArchiveNotConfigured(t *testing.T) {
+	err := Database{}.RemoveTaskRuns([]models.TaskRun{{TaskID: "task"}}, true)
+	assert.ErrorIs(t, err, ErrArchiveNotConfigured)
+
+	run, err := Database{}.GetArchivedTaskRun("1")
+	assert.NoError(t, err)
+	assert.Nil(t, run)
+}
+
+func TestCompressJSONB(t *testing.T) {
+	var result pgtype.JSONB
+	require.NoError(t, result.Set([]byte(`{"findings":[1,2,3]}`)))
+
+	data, err := compressJSONB(result)
+	require.NoError(t, err)
+	decompressed, err := decompressJSONB(data)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"findings":[1,2,3]}`, string(decompressed.Bytes))
+
+	data, err = compressJSONB(pgtype.JSONB{Status: pgtype.Null})
+	require.NoError(t, err)
+	decompressed, err = decompressJSONB(data)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{}`, string(decompressed.Bytes))
+}
+
+func TestRemoveTaskRunsDependencies(t *testing.T) {
+	database, mock := mockDatabase(t)
+
+	// the dependencies of downstream runs on the removed runs go with them
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "task_run_dependencies" WHERE run_id IN \(\$1,\$2\) OR upstream_run_id IN \(\$3,\$4\)`).
+		WithArgs(1, 2, 1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`DELETE FROM "task_runs" WHERE id IN \(\$1,\$2\)`).
+		WithArgs(1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	first, second := models.TaskRun{}, models.TaskRun{}
+	first.ID, second.ID = 1, 2
+	require.NoError(t, database.RemoveTaskRuns([]models.TaskRun{first, second}, false))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFetchExpiredTaskRunsKeepsLatestFinishedRuns(t *testing.T) {
+	database, mock := mockDatabase(t)
+
+	// only finished top level runs count toward MaxRuns, and the children of the kept runs are kept with them
+	latest := `SELECT "id" FROM "task_runs" WHERE task_id = \$\d+ AND status IN \(\$\d+,\$\d+,\$\d+,\$\d+\) AND parent_run_id IS NULL AND "task_runs"."deleted_at" IS NULL ORDER BY created_at desc LIMIT \$\d+`
+	mock.ExpectQuery(`AND \(\(id NOT IN \(` + latest + `\) AND \(parent_run_id IS NULL OR parent_run_id NOT IN \(` + latest + `\)\)\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	runs, err := database.FetchExpiredTaskRuns(models.TaskRunRetentionPolicy{TaskID: "task", MaxRuns: 5}, 100)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,23 +3,29 @@ package tasks
 import (
 	"crypto/rsa"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/jackc/pgtype"
 	api2 "github.com/opengovern/og-util/pkg/api"
+	"github.com/opengovern/og-util/pkg/httpclient"
 	"github.com/opengovern/og-util/pkg/httpserver"
 	"github.com/opengovern/og-util/pkg/jq"
 	"github.com/opengovern/og-util/pkg/tasks"
 	"github.com/opengovern/og-util/pkg/vault"
 	"github.com/opengovern/opensecurity/pkg/utils"
+	integrationClient "github.com/opengovern/opensecurity/services/integration/client"
 	"github.com/opengovern/opensecurity/services/tasks/api"
 	"github.com/opengovern/opensecurity/services/tasks/db"
 	"github.com/opengovern/opensecurity/services/tasks/db/models"
 	utils2 "github.com/opengovern/opensecurity/services/tasks/utils"
 	"github.com/opengovern/opensecurity/services/tasks/worker/consts"
+	"gorm.io/gorm"
 	"io"
 	"net/http"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
@@ -33,6 +39,8 @@ type httpRoutes struct {
 	itDb               db.Database
 	jq                 *jq.JobQueue
 	vault              vault.VaultSourceConfig
+
+	integrationClient integrationClient.IntegrationServiceClient
 }
 
 func (r *httpRoutes) Register(e *echo.Echo) {
@@ -51,8 +59,22 @@ func (r *httpRoutes) Register(e *echo.Echo) {
 	v1.PUT("/tasks/run/:id/cancel", httpserver.AuthorizeHandler(r.CancelTaskRun, api2.EditorRole))
 	// List Tasks Result
 	v1.GET("/tasks/:id/runs", httpserver.AuthorizeHandler(r.ListTaskRunResults, api2.ViewerRole))
+	// List task runs targeting an integration
+	v1.GET("/integrations/:integration_id/task-runs", httpserver.AuthorizeHandler(r.ListIntegrationTaskRuns, api2.ViewerRole))
+	// Task run retention policy
+	v1.GET("/tasks/:id/retention", httpserver.AuthorizeHandler(r.GetTaskRunRetentionPolicy, api2.ViewerRole))
+	v1.PUT("/tasks/:id/retention", httpserver.AuthorizeHandler(r.SetTaskRunRetentionPolicy, api2.EditorRole))
+	v1.DELETE("/tasks/:id/retention", httpserver.AuthorizeHandler(r.DeleteTaskRunRetentionPolicy, api2.EditorRole))
 	// Add Task Configurations
 	v1.POST("/tasks/:id/config", httpserver.AuthorizeHandler(r.AddTaskConfig, api2.EditorRole))
+	// Get Task Configurations health history
+	v1.GET("/tasks/:id/config/health", httpserver.AuthorizeHandler(r.GetTaskConfigHealth, api2.ViewerRole))
+	// Validate Task Configurations now
+	v1.POST("/tasks/:id/config/check", httpserver.AuthorizeHandler(r.CheckTaskConfig, api2.EditorRole))
+	// Rotate Task Configurations
+	v1.POST("/tasks/:id/config/rotate", httpserver.AuthorizeHandler(r.RotateTaskConfig, api2.EditorRole))
+	// Discard staged Task Configurations
+	v1.DELETE("/tasks/:id/config/rotate", httpserver.AuthorizeHandler(r.DiscardTaskConfigRotation, api2.EditorRole))
 }
 
 func bindValidate(ctx echo.Context, i interface{}) error {
@@ -165,10 +187,16 @@ func (r *httpRoutes) GetTask(ctx echo.Context) error {
 			r.logger.Error("failed to get task run params", zap.Error(err))
 			return ctx.JSON(http.StatusInternalServerError, "failed to get task run params")
 		}
+		integrationTarget, err := utils2.ParseIntegrationTarget(runSchedule.IntegrationTarget)
+		if err != nil {
+			r.logger.Error("failed to get task run integrations", zap.Error(err))
+			return ctx.JSON(http.StatusInternalServerError, "failed to get task run integrations")
+		}
 		runSchedulesObjects = append(runSchedulesObjects, api.RunScheduleObject{
-			ID:        runSchedule.ID,
-			Params:    params,
-			Frequency: runSchedule.Frequency,
+			ID:           runSchedule.ID,
+			Params:       params,
+			Frequency:    runSchedule.Frequency,
+			Integrations: integrationTarget,
 		})
 	}
 
@@ -196,6 +224,8 @@ func (r *httpRoutes) GetTask(ctx echo.Context) error {
 		EnvVars:      envVars,
 		ScaleConfig:  scaleConfig,
 		Params:       task.Params,
+
+		CredentialsCheck: task.CredentialsCheck,
 	}
 
 	return ctx.JSON(http.StatusOK, taskResponse)
@@ -236,9 +266,19 @@ func (r *httpRoutes) RunTask(ctx echo.Context) error {
 		return ctx.JSON(http.StatusInternalServerError, "failed to find task")
 	}
 
+	runLimits, err := utils2.GetTaskRunLimits(*task)
+	if err != nil {
+		r.logger.Error("failed to get task run limits", zap.String("task", req.TaskID), zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to get task run limits")
+	}
+	userID := httpserver.GetUserID(ctx)
+
 	run := models.TaskRun{
-		TaskID: req.TaskID,
-		Status: models.TaskRunStatusCreated,
+		TaskID:      req.TaskID,
+		Status:      models.TaskRunStatusCreated,
+		TriggerType: models.TriggerTypeManual,
+		TriggeredBy: userID,
+		Priority:    utils2.GetRunPriority(runLimits, models.TriggerTypeManual),
 	}
 	paramsJson, err := json.Marshal(req.Params)
 	if err != nil {
@@ -255,14 +295,171 @@ func (r *httpRoutes) RunTask(ctx echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to set results")
 	}
 
-	if err := r.db.CreateTaskRun(&run); err != nil {
-		r.logger.Error("failed to create task run", zap.Error(err))
-		return ctx.JSON(http.StatusInternalServerError, "failed to create task run")
+	if req.Integrations != nil && !req.Integrations.IsEmpty() {
+		if len(req.DependsOn) > 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "integrations can not be combined with depends_on")
+		}
+		// The integrations are resolved as the caller, so it can only target the integrations in its scope
+		clientCtx := integrationClient.WithUserConnectionsScope(httpclient.FromEchoContext(ctx),
+			ctx.Request().Header.Get(httpserver.XPlatformUserConnectionsScope))
+		integrations, err := utils2.ResolveIntegrationTarget(clientCtx, r.integrationClient, *req.Integrations)
+		if err != nil {
+			r.logger.Error("failed to resolve integrations", zap.Error(err))
+			return ctx.JSON(http.StatusInternalServerError, "failed to resolve integrations")
+		}
+		if len(integrations) == 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "no active integrations matched the given integrations, groups or labels")
+		}
+		err = r.createManualTaskRun(task.ID, userID, runLimits, func(tx db.Database) error {
+			return utils2.CreateIntegrationTaskRuns(tx, &run, integrations)
+		})
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(http.StatusCreated, run)
+	}
+
+	if len(req.DependsOn) == 0 {
+		err = r.createManualTaskRun(task.ID, userID, runLimits, func(tx db.Database) error {
+			return tx.CreateTaskRun(&run)
+		})
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(http.StatusCreated, run)
+	}
+
+	dependencies, err := r.buildTaskRunDependencies(req.DependsOn, run)
+	if err != nil {
+		return err
+	}
+	run.Status = models.TaskRunStatusWaiting
+	err = r.createManualTaskRun(task.ID, userID, runLimits, func(tx db.Database) error {
+		return tx.CreateTaskRunWithDependencies(&run, dependencies)
+	})
+	if err != nil {
+		return err
 	}
 
 	return ctx.JSON(http.StatusCreated, run)
 }
 
+// createManualTaskRun checks the run limits and creates the run in a single transaction holding the task lock,
+// so concurrent requests can not go over the queue size or the manual run quota
+func (r *httpRoutes) createManualTaskRun(taskID, userID string, runLimits api.RunLimits, create func(tx db.Database) error) error {
+	err := r.db.WithTaskLock(taskID, func(tx db.Database) error {
+		if err := r.checkRunLimits(tx, taskID, userID, runLimits); err != nil {
+			return err
+		}
+		if err := create(tx); err != nil {
+			r.logger.Error("failed to create task run", zap.String("task", taskID), zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create task run")
+		}
+		return nil
+	})
+	var httpErr *echo.HTTPError
+	if err != nil && !errors.As(err, &httpErr) {
+		r.logger.Error("failed to lock task", zap.String("task", taskID), zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create task run")
+	}
+	return err
+}
+
+// checkRunLimits rejects a manual run if the task queue is full or the user has used up the manual run quota
+func (r *httpRoutes) checkRunLimits(database db.Database, taskID, userID string, runLimits api.RunLimits) error {
+	if runLimits.MaxQueuedRuns > 0 {
+		queued, err := database.CountCreatedTaskRunsByTaskID(taskID)
+		if err != nil {
+			r.logger.Error("failed to count queued task runs", zap.String("task", taskID), zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to count queued task runs")
+		}
+		if queued >= int64(runLimits.MaxQueuedRuns) {
+			return echo.NewHTTPError(http.StatusTooManyRequests,
+				fmt.Sprintf("task %s already has %d queued runs (max_queued_runs: %d), try again later", taskID, queued, runLimits.MaxQueuedRuns))
+		}
+	}
+
+	if runLimits.ManualRunQuota != nil {
+		period, err := utils2.GetRunQuotaPeriod(*runLimits.ManualRunQuota)
+		if err != nil {
+			r.logger.Error("invalid manual run quota period", zap.String("task", taskID), zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, "invalid manual run quota period")
+		}
+		count, err := database.CountManualTaskRunsByUser(taskID, userID, time.Now().Add(-period))
+		if err != nil {
+			r.logger.Error("failed to count manual task runs", zap.String("task", taskID), zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to count manual task runs")
+		}
+		if count >= int64(runLimits.ManualRunQuota.MaxRuns) {
+			return echo.NewHTTPError(http.StatusTooManyRequests,
+				fmt.Sprintf("manual run quota exceeded for task %s: %d runs per %s", taskID,
+					runLimits.ManualRunQuota.MaxRuns, runLimits.ManualRunQuota.Period))
+		}
+	}
+
+	return nil
+}
+
+func (r *httpRoutes) buildTaskRunDependencies(dependsOn []api.TaskRunDependency, run models.TaskRun) ([]db.TaskRunDependencyInput, error) {
+	var dependencies []db.TaskRunDependencyInput
+	fanOuts := 0
+	for _, dependency := range dependsOn {
+		if (dependency.RunID == nil) == (dependency.TaskID == "") {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "each dependency should have exactly one of run_id or task_id")
+		}
+		if dependency.FanOutKey != "" {
+			fanOuts++
+		}
+		input := db.TaskRunDependencyInput{
+			Dependency: models.TaskRunDependency{
+				ParamName: dependency.ParamName,
+				FanOutKey: dependency.FanOutKey,
+			},
+		}
+
+		if dependency.RunID != nil {
+			upstream, err := r.db.GetTaskRun(strconv.FormatUint(uint64(*dependency.RunID), 10))
+			if err != nil || upstream == nil {
+				r.logger.Error("failed to find upstream task run", zap.Uintp("runId", dependency.RunID), zap.Error(err))
+				return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("upstream run %d not found", *dependency.RunID))
+			}
+			input.Dependency.UpstreamRunID = upstream.ID
+		} else {
+			upstreamTask, err := r.db.GetTask(dependency.TaskID)
+			if err != nil || upstreamTask == nil {
+				r.logger.Error("failed to find upstream task", zap.String("task", dependency.TaskID), zap.Error(err))
+				return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("upstream task %s not found", dependency.TaskID))
+			}
+			upstreamRun := models.TaskRun{
+				TaskID:      upstreamTask.ID,
+				Status:      models.TaskRunStatusCreated,
+				TriggerType: run.TriggerType,
+				TriggeredBy: run.TriggeredBy,
+				Priority:    run.Priority,
+			}
+			paramsJson, err := json.Marshal(dependency.Params)
+			if err != nil {
+				return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid upstream params")
+			}
+			if err = upstreamRun.Params.Set(paramsJson); err != nil {
+				r.logger.Error("failed to set params", zap.Error(err))
+				return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to set params")
+			}
+			if err = upstreamRun.Result.Set([]byte("{}")); err != nil {
+				r.logger.Error("failed to set results", zap.Error(err))
+				return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to set results")
+			}
+			input.NewUpstreamRun = &upstreamRun
+		}
+		dependencies = append(dependencies, input)
+	}
+	if fanOuts > 1 {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "only one dependency can fan out")
+	}
+
+	return dependencies, nil
+}
+
 // GetTaskRunResult godoc
 //
 //	@Summary	Get task run
@@ -274,33 +471,41 @@ func (r *httpRoutes) RunTask(ctx echo.Context) error {
 //	@Router		/tasks/api/v1/tasks/run/:id [get]
 func (r *httpRoutes) GetTaskRunResult(ctx echo.Context) error {
 	id := ctx.Param("id")
+	archived := false
 	task, err := r.db.GetTaskRun(id)
 	if err != nil {
-		r.logger.Error("failed to get task results", zap.Error(err))
-		return ctx.JSON(http.StatusInternalServerError, "failed to get task results")
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			r.logger.Error("failed to get task results", zap.Error(err))
+			return ctx.JSON(http.StatusInternalServerError, "failed to get task results")
+		}
+		task, err = r.db.GetArchivedTaskRun(id)
+		if err != nil {
+			r.logger.Error("failed to get archived task results", zap.Error(err))
+			return ctx.JSON(http.StatusInternalServerError, "failed to get task results")
+		}
+		if task == nil {
+			return ctx.JSON(http.StatusNotFound, "task run not found")
+		}
+		archived = true
 	}
 
-	var params map[string]interface{}
-	err = json.Unmarshal(task.Params.Bytes, &params)
+	taskRun, err := toApiTaskRun(*task)
 	if err != nil {
-		r.logger.Error("failed to unmarshal params", zap.Error(err))
-		return ctx.JSON(http.StatusInternalServerError, "failed to unmarshal params")
+		r.logger.Error("failed to unmarshal task run", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to unmarshal task run")
 	}
-	var result map[string]interface{}
-	err = json.Unmarshal(task.Result.Bytes, &result)
+	taskRun.Archived = archived
+	if archived {
+		return ctx.JSON(http.StatusOK, taskRun)
+	}
+
+	dependencies, err := r.db.GetTaskRunDependencies(task.ID)
 	if err != nil {
-		r.logger.Error("failed to unmarshal result", zap.Error(err))
-		return ctx.JSON(http.StatusInternalServerError, "failed to unmarshal result")
+		r.logger.Error("failed to get task run dependencies", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to get task run dependencies")
 	}
-	taskRun := api.TaskRun{
-		ID:             task.ID,
-		CreatedAt:      task.CreatedAt,
-		UpdatedAt:      task.UpdatedAt,
-		TaskID:         task.TaskID,
-		Status:         string(task.Status),
-		Result:         result,
-		Params:         params,
-		FailureMessage: task.FailureMessage,
+	for _, dependency := range dependencies {
+		taskRun.DependsOn = append(taskRun.DependsOn, dependency.UpstreamRunID)
 	}
 
 	return ctx.JSON(http.StatusOK, taskRun)
@@ -371,8 +576,15 @@ func (r *httpRoutes) CancelTaskRun(ctx echo.Context) error {
 //	@Summary	List task runs
 //	@Security	BearerToken
 //	@Tags		scheduler
-//	@Param		cursor		query	int	false	"cursor"
-//	@Param		per_page	query	int	false	"per page"
+//	@Param		cursor			query	int		false	"cursor"
+//	@Param		per_page		query	int		false	"per page"
+//	@Param		status			query	[]string	false	"run statuses"
+//	@Param		trigger_type	query	[]string	false	"trigger types"
+//	@Param		triggered_by	query	string	false	"user or schedule which triggered the run"
+//	@Param		integration_id	query	string	false	"integration the run targeted"
+//	@Param		start_time		query	int		false	"created after, unix seconds"
+//	@Param		end_time		query	int		false	"created before, unix seconds"
+//	@Param		result_path		query	string	false	"SQL/JSON path the run result should match, e.g. $.vulnerabilities[*] ? (@.severity == \"critical\")"
 //	@Produce	json
 //	@Success	200	{object}	api.ListTaskRunsResponse
 //	@Router		/tasks/api/v1/tasks/:id/runs [get]
@@ -381,6 +593,85 @@ func (r *httpRoutes) ListTaskRunResults(ctx echo.Context) error {
 	if id == "" {
 		return ctx.JSON(http.StatusBadRequest, "task id should be provided")
 	}
+	filters, err := parseTaskRunFilters(ctx)
+	if err != nil {
+		return err
+	}
+	filters.IntegrationID = ctx.QueryParam("integration_id")
+	if filters.IntegrationID != "" && httpserver.CheckAccessToConnectionID(ctx, filters.IntegrationID) != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "integration not found")
+	}
+
+	return r.listTaskRuns(ctx, id, filters)
+}
+
+// ListIntegrationTaskRuns godoc
+//
+//	@Summary	List task runs of an integration
+//	@Security	BearerToken
+//	@Tags		scheduler
+//	@Param		integration_id	path	string	true	"integration id"
+//	@Param		cursor			query	int		false	"cursor"
+//	@Param		per_page		query	int		false	"per page"
+//	@Param		status			query	[]string	false	"run statuses"
+//	@Param		trigger_type	query	[]string	false	"trigger types"
+//	@Param		triggered_by	query	string	false	"user or schedule which triggered the run"
+//	@Param		start_time		query	int		false	"created after, unix seconds"
+//	@Param		end_time		query	int		false	"created before, unix seconds"
+//	@Param		result_path		query	string	false	"SQL/JSON path the run result should match"
+//	@Produce	json
+//	@Success	200	{object}	api.ListTaskRunsResponse
+//	@Router		/tasks/api/v1/integrations/:integration_id/task-runs [get]
+func (r *httpRoutes) ListIntegrationTaskRuns(ctx echo.Context) error {
+	integrationID := ctx.Param("integration_id")
+	if integrationID == "" {
+		return ctx.JSON(http.StatusBadRequest, "integration id should be provided")
+	}
+	// callers limited to some integrations only see the runs of the integrations in their scope
+	if httpserver.CheckAccessToConnectionID(ctx, integrationID) != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "integration not found")
+	}
+	filters, err := parseTaskRunFilters(ctx)
+	if err != nil {
+		return err
+	}
+	filters.IntegrationID = integrationID
+
+	return r.listTaskRuns(ctx, "", filters)
+}
+
+func parseTaskRunFilters(ctx echo.Context) (db.TaskRunFilters, error) {
+	filters := db.TaskRunFilters{
+		TriggeredBy:    ctx.QueryParam("triggered_by"),
+		ResultJSONPath: strings.TrimSpace(ctx.QueryParam("result_path")),
+	}
+	for _, status := range httpserver.QueryArrayParam(ctx, "status") {
+		filters.Statuses = append(filters.Statuses, models.TaskRunStatus(strings.ToUpper(status)))
+	}
+	for _, triggerType := range httpserver.QueryArrayParam(ctx, "trigger_type") {
+		filters.TriggerTypes = append(filters.TriggerTypes, models.TriggerType(strings.ToUpper(triggerType)))
+	}
+	if startTimeStr := ctx.QueryParam("start_time"); startTimeStr != "" {
+		startTimeInt, err := strconv.ParseInt(startTimeStr, 10, 64)
+		if err != nil {
+			return filters, echo.NewHTTPError(http.StatusBadRequest, "invalid start_time")
+		}
+		filters.CreatedAfter = utils.GetPointer(time.Unix(startTimeInt, 0))
+	}
+	if endTimeStr := ctx.QueryParam("end_time"); endTimeStr != "" {
+		endTimeInt, err := strconv.ParseInt(endTimeStr, 10, 64)
+		if err != nil {
+			return filters, echo.NewHTTPError(http.StatusBadRequest, "invalid end_time")
+		}
+		filters.CreatedBefore = utils.GetPointer(time.Unix(endTimeInt, 0))
+	}
+	if filters.ResultJSONPath != "" && !strings.HasPrefix(filters.ResultJSONPath, "$") {
+		return filters, echo.NewHTTPError(http.StatusBadRequest, "result_path should be a SQL/JSON path starting with $")
+	}
+	return filters, nil
+}
+
+func (r *httpRoutes) listTaskRuns(ctx echo.Context, taskID string, filters db.TaskRunFilters) error {
 	var cursor, perPage int64
 	var err error
 	cursorStr := ctx.QueryParam("cursor")
@@ -397,57 +688,157 @@ func (r *httpRoutes) ListTaskRunResults(ctx echo.Context) error {
 			return err
 		}
 	}
+	if cursor < 1 {
+		cursor = 1
+	}
 
-	items, err := r.db.ListTaskRunResult(id)
+	items, totalCount, err := r.db.ListTaskRunResultPaginated(taskID, filters, int(perPage), int((cursor-1)*perPage))
 	if err != nil {
 		r.logger.Error("failed to get task results", zap.Error(err))
+		if errors.Is(err, db.ErrInvalidResultJSONPath) {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid result_path")
+		}
 		return ctx.JSON(http.StatusInternalServerError, "failed to get task results")
 	}
 
-	var taskRunResponses []api.TaskRun
+	taskRunResponses := make([]api.TaskRun, 0, len(items))
 	for _, task := range items {
-		var params map[string]interface{}
-		err := json.Unmarshal(task.Params.Bytes, &params)
+		taskRun, err := toApiTaskRun(task)
 		if err != nil {
-			r.logger.Error("failed to unmarshal params", zap.Error(err))
-			return ctx.JSON(http.StatusInternalServerError, "failed to unmarshal params")
+			r.logger.Error("failed to unmarshal task run", zap.Error(err))
+			return ctx.JSON(http.StatusInternalServerError, "failed to unmarshal task run")
 		}
-		var result map[string]interface{}
-		err = json.Unmarshal(task.Result.Bytes, &result)
-		if err != nil {
-			r.logger.Error("failed to unmarshal result json", zap.Error(err))
-			return ctx.JSON(http.StatusInternalServerError, "failed to unmarshal result")
-		}
-		taskRunResponses = append(taskRunResponses, api.TaskRun{
-			ID:             task.ID,
-			CreatedAt:      task.CreatedAt,
-			UpdatedAt:      task.UpdatedAt,
-			TaskID:         task.TaskID,
-			Status:         string(task.Status),
-			Result:         result,
-			Params:         params,
-			FailureMessage: task.FailureMessage,
-		})
+		taskRunResponses = append(taskRunResponses, taskRun)
 	}
 
-	sort.Slice(taskRunResponses, func(i, j int) bool {
-		return taskRunResponses[i].ID > taskRunResponses[j].ID
+	return ctx.JSON(http.StatusOK, api.ListTaskRunsResponse{
+		TotalCount: int(totalCount),
+		Items:      taskRunResponses,
 	})
-	totalCount := len(taskRunResponses)
-	if perPage != 0 {
-		if cursor == 0 {
-			taskRunResponses = utils.Paginate(1, perPage, taskRunResponses)
-		} else {
-			taskRunResponses = utils.Paginate(cursor, perPage, taskRunResponses)
-		}
+}
+
+func toApiTaskRun(task models.TaskRun) (api.TaskRun, error) {
+	var params map[string]interface{}
+	if err := json.Unmarshal(task.Params.Bytes, &params); err != nil {
+		return api.TaskRun{}, fmt.Errorf("failed to unmarshal params: %w", err)
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(task.Result.Bytes, &result); err != nil {
+		return api.TaskRun{}, fmt.Errorf("failed to unmarshal result: %w", err)
 	}
 
-	return ctx.JSON(http.StatusOK, api.ListTaskRunsResponse{
-		TotalCount: totalCount,
-		Items:      taskRunResponses,
+	return api.TaskRun{
+		ID:             task.ID,
+		CreatedAt:      task.CreatedAt,
+		UpdatedAt:      task.UpdatedAt,
+		TaskID:         task.TaskID,
+		Status:         string(task.Status),
+		TriggerType:    string(task.TriggerType),
+		TriggeredBy:    task.TriggeredBy,
+		IntegrationID:  task.IntegrationID,
+		Result:         result,
+		Params:         params,
+		FailureMessage: task.FailureMessage,
+		ParentRunID:    task.ParentRunID,
+		Priority:       task.Priority,
+	}, nil
+}
+
+// GetTaskRunRetentionPolicy godoc
+//
+//	@Summary	Get task run retention policy
+//	@Security	BearerToken
+//	@Tags		scheduler
+//	@Param		id	path	string	true	"task id"
+//	@Produce	json
+//	@Success	200	{object}	api.TaskRunRetentionPolicy
+//	@Router		/tasks/api/v1/tasks/:id/retention [get]
+func (r *httpRoutes) GetTaskRunRetentionPolicy(ctx echo.Context) error {
+	id := ctx.Param("id")
+	policy, err := r.db.GetTaskRunRetentionPolicy(id)
+	if err != nil {
+		r.logger.Error("failed to get retention policy", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to get retention policy")
+	}
+	if policy == nil {
+		return ctx.JSON(http.StatusNotFound, "retention policy not found")
+	}
+
+	return ctx.JSON(http.StatusOK, api.TaskRunRetentionPolicy{
+		MaxRuns:        policy.MaxRuns,
+		MaxAgeDays:     policy.MaxAgeDays,
+		ArchiveResults: policy.ArchiveResults,
 	})
 }
 
+// SetTaskRunRetentionPolicy godoc
+//
+//	@Summary	Set task run retention policy
+//	@Security	BearerToken
+//	@Tags		scheduler
+//	@Param		id		path	string						true	"task id"
+//	@Param		request	body	api.TaskRunRetentionPolicy	true	"Retention policy"
+//	@Success	200
+//	@Router		/tasks/api/v1/tasks/:id/retention [put]
+func (r *httpRoutes) SetTaskRunRetentionPolicy(ctx echo.Context) error {
+	id := ctx.Param("id")
+	task, err := r.db.GetTask(id)
+	if err != nil {
+		r.logger.Error("failed to get task", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to get task")
+	}
+	if task == nil {
+		return ctx.JSON(http.StatusNotFound, "task not found")
+	}
+
+	var req api.TaskRunRetentionPolicy
+	if err := bindValidate(ctx, &req); err != nil {
+		r.logger.Error("failed to bind retention policy", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, "failed to bind retention policy")
+	}
+	if req.MaxRuns < 0 || req.MaxAgeDays < 0 {
+		return ctx.JSON(http.StatusBadRequest, "max_runs and max_age_days should not be negative")
+	}
+	if req.MaxRuns == 0 && req.MaxAgeDays == 0 {
+		return ctx.JSON(http.StatusBadRequest, "at least one of max_runs or max_age_days should be set")
+	}
+	if req.ArchiveResults && r.db.ArchiveOrm == nil {
+		return ctx.JSON(http.StatusBadRequest, "task run archive database is not configured")
+	}
+
+	err = r.db.SetTaskRunRetentionPolicy(models.TaskRunRetentionPolicy{
+		TaskID:         task.ID,
+		MaxRuns:        req.MaxRuns,
+		MaxAgeDays:     req.MaxAgeDays,
+		ArchiveResults: req.ArchiveResults,
+		UpdatedAt:      time.Now(),
+	})
+	if err != nil {
+		r.logger.Error("failed to set retention policy", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to set retention policy")
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
+
+// DeleteTaskRunRetentionPolicy godoc
+//
+//	@Summary	Delete task run retention policy, runs are kept forever
+//	@Security	BearerToken
+//	@Tags		scheduler
+//	@Param		id	path	string	true	"task id"
+//	@Success	200
+//	@Router		/tasks/api/v1/tasks/:id/retention [delete]
+func (r *httpRoutes) DeleteTaskRunRetentionPolicy(ctx echo.Context) error {
+	id := ctx.Param("id")
+	if err := r.db.DeleteTaskRunRetentionPolicy(id); err != nil {
+		r.logger.Error("failed to delete retention policy", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to delete retention policy")
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
+
 // AddTaskConfig godoc
 //
 //	@Summary	Run a new task
@@ -474,33 +865,239 @@ func (r *httpRoutes) AddTaskConfig(ctx echo.Context) error {
 		return ctx.JSON(http.StatusBadRequest, "failed to bind task")
 	}
 
+	decryptedSecret, err := r.encryptTaskConfig(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	configSecret := models.TaskConfigSecret{
+		TaskID:       task.ID,
+		Secret:       decryptedSecret,
+		HealthStatus: models.TaskSecretHealthStatusUnknown,
+	}
+	err = r.db.SetTaskConfigSecret(configSecret)
+	if err != nil {
+		r.logger.Error("failed to set task config", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to set task config")
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
+
+func (r *httpRoutes) encryptTaskConfig(ctx echo.Context, req api.TaskConfigSecret) (string, error) {
 	jsonData, err := json.Marshal(req.Credentials)
 	if err != nil {
 		r.logger.Error("failed to marshal json data", zap.Error(err))
-		return echo.NewHTTPError(http.StatusBadRequest, "failed to marshal json data")
+		return "", echo.NewHTTPError(http.StatusBadRequest, "failed to marshal json data")
 	}
 	var mapData map[string]any
 	err = json.Unmarshal(jsonData, &mapData)
 	if err != nil {
 		r.logger.Error("failed to unmarshal json data", zap.Error(err))
-		return echo.NewHTTPError(http.StatusBadRequest, "failed to unmarshal json data")
+		return "", echo.NewHTTPError(http.StatusBadRequest, "failed to unmarshal json data")
 	}
 
-	decryptedSecret, err := r.vault.Encrypt(ctx.Request().Context(), mapData)
+	encryptedSecret, err := r.vault.Encrypt(ctx.Request().Context(), mapData)
 	if err != nil {
-		r.logger.Error("failed to decrypt secret", zap.Error(err))
-		return ctx.JSON(http.StatusInternalServerError, "failed to decrypt secret")
+		r.logger.Error("failed to encrypt secret", zap.Error(err))
+		return "", echo.NewHTTPError(http.StatusInternalServerError, "failed to encrypt secret")
 	}
+	return encryptedSecret, nil
+}
 
-	configSecret := models.TaskConfigSecret{
-		TaskID:       task.ID,
-		Secret:       decryptedSecret,
-		HealthStatus: models.TaskSecretHealthStatusUnknown,
+// GetTaskConfigHealth godoc
+//
+//	@Summary	Get task config health status and history
+//	@Security	BearerToken
+//	@Tags		scheduler
+//	@Param		id		path	string	true	"task id"
+//	@Param		limit	query	int		false	"number of history items, default 50"
+//	@Produce	json
+//	@Success	200	{object}	api.TaskConfigHealthResponse
+//	@Router		/tasks/api/v1/tasks/:id/config/health [get]
+func (r *httpRoutes) GetTaskConfigHealth(ctx echo.Context) error {
+	id := ctx.Param("id")
+	limit := 50
+	if limitStr := ctx.QueryParam("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			return ctx.JSON(http.StatusBadRequest, "invalid limit")
+		}
+		limit = l
 	}
-	err = r.db.SetTaskConfigSecret(configSecret)
+
+	configSecret, err := r.db.GetTaskConfigSecret(id)
 	if err != nil {
-		r.logger.Error("failed to set task config", zap.Error(err))
-		return ctx.JSON(http.StatusInternalServerError, "failed to set task config")
+		r.logger.Error("failed to get task config", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to get task config")
+	}
+	if configSecret == nil {
+		return ctx.JSON(http.StatusNotFound, "task config not found")
+	}
+
+	checks, err := r.db.ListTaskConfigSecretHealthChecks(id, limit)
+	if err != nil {
+		r.logger.Error("failed to get task config health history", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to get task config health history")
+	}
+
+	response := api.TaskConfigHealthResponse{
+		TaskID:             configSecret.TaskID,
+		HealthStatus:       string(configSecret.HealthStatus),
+		LastCheckedAt:      configSecret.LastCheckedAt,
+		StagedHealthStatus: string(configSecret.StagedHealthStatus),
+		StagedAt:           configSecret.StagedAt,
+		History:            []api.TaskConfigHealthCheck{},
+	}
+	for _, check := range checks {
+		response.History = append(response.History, api.TaskConfigHealthCheck{
+			RunID:          check.RunID,
+			Staged:         check.Staged,
+			HealthStatus:   string(check.HealthStatus),
+			FailureMessage: check.FailureMessage,
+			CheckedAt:      check.CheckedAt,
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// CheckTaskConfig godoc
+//
+//	@Summary	Run a credentials check for the task config
+//	@Security	BearerToken
+//	@Tags		scheduler
+//	@Param		id	path	string	true	"task id"
+//	@Produce	json
+//	@Success	201	{object}	models.TaskRun
+//	@Router		/tasks/api/v1/tasks/:id/config/check [post]
+func (r *httpRoutes) CheckTaskConfig(ctx echo.Context) error {
+	id := ctx.Param("id")
+	task, err := r.db.GetTask(id)
+	if err != nil {
+		r.logger.Error("failed to get task", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to get task")
+	}
+	if task == nil {
+		return ctx.JSON(http.StatusNotFound, "task not found")
+	}
+	if !task.CredentialsCheck {
+		return ctx.JSON(http.StatusBadRequest, "task does not support credentials checks")
+	}
+	configSecret, err := r.db.GetTaskConfigSecret(id)
+	if err != nil {
+		r.logger.Error("failed to get task config", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to get task config")
+	}
+	if configSecret == nil {
+		return ctx.JSON(http.StatusNotFound, "task config not found")
+	}
+
+	run, err := utils2.NewCredentialsCheckRun(*task, models.CredentialsCheckTargetActive)
+	if err != nil {
+		r.logger.Error("failed to build credentials check run", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to build credentials check run")
+	}
+	if err = r.db.CreateTaskRun(run); err != nil {
+		r.logger.Error("failed to create task run", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to create task run")
+	}
+	if err = r.db.SetTaskConfigSecretLastCheckedAt(task.ID, time.Now()); err != nil {
+		r.logger.Error("failed to update task config", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to update task config")
+	}
+
+	return ctx.JSON(http.StatusCreated, run)
+}
+
+// RotateTaskConfig godoc
+//
+//	@Summary		Rotate the task config
+//	@Description	Stages the new credentials and validates them with a credentials check run.
+//	@Description	The active credentials are kept until the new ones are reported healthy.
+//	@Description	Only tasks with the credentials_check capability can be rotated.
+//	@Security		BearerToken
+//	@Tags			scheduler
+//	@Param			id		path	string					true	"task id"
+//	@Param			request	body	api.TaskConfigSecret	true	"New task config"
+//	@Produce		json
+//	@Success		201	{object}	models.TaskRun
+//	@Router			/tasks/api/v1/tasks/:id/config/rotate [post]
+func (r *httpRoutes) RotateTaskConfig(ctx echo.Context) error {
+	id := ctx.Param("id")
+	task, err := r.db.GetTask(id)
+	if err != nil {
+		r.logger.Error("failed to get task", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to get task")
+	}
+	if task == nil {
+		return ctx.JSON(http.StatusNotFound, "task not found")
+	}
+	if !task.CredentialsCheck {
+		return ctx.JSON(http.StatusBadRequest, "task does not support credentials checks")
+	}
+	configSecret, err := r.db.GetTaskConfigSecret(id)
+	if err != nil {
+		r.logger.Error("failed to get task config", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to get task config")
+	}
+	if configSecret == nil {
+		return ctx.JSON(http.StatusNotFound, "task config not found, use the config endpoint to add it")
+	}
+
+	var req api.TaskConfigSecret
+	if err := bindValidate(ctx, &req); err != nil {
+		r.logger.Error("failed to bind task config", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, "failed to bind task config")
+	}
+	encryptedSecret, err := r.encryptTaskConfig(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	if err = r.db.StageTaskConfigSecret(task.ID, encryptedSecret); err != nil {
+		r.logger.Error("failed to stage task config", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to stage task config")
+	}
+
+	run, err := utils2.NewCredentialsCheckRun(*task, models.CredentialsCheckTargetStaged)
+	if err != nil {
+		r.logger.Error("failed to build credentials check run", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to build credentials check run")
+	}
+	if err = r.db.CreateTaskRun(run); err != nil {
+		r.logger.Error("failed to create task run", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to create task run")
+	}
+	if err = r.db.SetStagedTaskConfigSecretRunID(task.ID, run.ID); err != nil {
+		r.logger.Error("failed to update task config", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to update task config")
+	}
+
+	return ctx.JSON(http.StatusCreated, run)
+}
+
+// DiscardTaskConfigRotation godoc
+//
+//	@Summary	Discard the staged task config
+//	@Security	BearerToken
+//	@Tags		scheduler
+//	@Param		id	path	string	true	"task id"
+//	@Success	200
+//	@Router		/tasks/api/v1/tasks/:id/config/rotate [delete]
+func (r *httpRoutes) DiscardTaskConfigRotation(ctx echo.Context) error {
+	id := ctx.Param("id")
+	task, err := r.db.GetTask(id)
+	if err != nil {
+		r.logger.Error("failed to get task", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to get task")
+	}
+	if task == nil {
+		return ctx.JSON(http.StatusNotFound, "task not found")
+	}
+	if err = r.db.DiscardStagedTaskConfigSecret(id); err != nil {
+		r.logger.Error("failed to discard staged task config", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, "failed to discard staged task config")
 	}
 
 	return ctx.NoContent(http.StatusOK)
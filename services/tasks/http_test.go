@@ -0,0 +1,122 @@
+package tasks
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/opengovern/og-util/pkg/httpserver"
+	"github.com/opengovern/opensecurity/services/tasks/db"
+	"github.com/opengovern/opensecurity/services/tasks/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestListTaskRunsOutOfScope(t *testing.T) {
+	// the runs are never listed, so the routes have no database
+	r := &httpRoutes{logger: zap.NewNop()}
+
+	newContext := func(target string) echo.Context {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set(httpserver.XPlatformUserConnectionsScope, "integration-a,integration-b")
+		return echo.New().NewContext(req, httptest.NewRecorder())
+	}
+
+	c := newContext("/")
+	c.SetParamNames("integration_id")
+	c.SetParamValues("integration-c")
+	var httpErr *echo.HTTPError
+	require.True(t, errors.As(r.ListIntegrationTaskRuns(c), &httpErr))
+	assert.Equal(t, http.StatusNotFound, httpErr.Code)
+
+	c = newContext("/?integration_id=integration-c")
+	c.SetParamNames("id")
+	c.SetParamValues("task")
+	require.True(t, errors.As(r.ListTaskRunResults(c), &httpErr))
+	assert.Equal(t, http.StatusNotFound, httpErr.Code)
+}
+
+func mockRoutes(t *testing.T) (*httpRoutes, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	orm, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+	return &httpRoutes{logger: zap.NewNop(), db: db.Database{Orm: orm}}, mock
+}
+
+func TestDiscardTaskConfigRotationUnknownTask(t *testing.T) {
+	r, mock := mockRoutes(t)
+
+	mock.ExpectQuery(`SELECT \* FROM "tasks" WHERE id = \$1`).
+		WithArgs("unknown", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodDelete, "/", nil), rec)
+	c.SetParamNames("id")
+	c.SetParamValues("unknown")
+	require.NoError(t, r.DiscardTaskConfigRotation(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	// nothing is discarded
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+type noopValidator struct{}
+
+func (noopValidator) Validate(any) error { return nil }
+
+func runTaskRequest() echo.Context {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"task_id":"task","params":{}}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(httpserver.XPlatformUserIDHeader, "user")
+	e := echo.New()
+	e.Validator = noopValidator{}
+	return e.NewContext(req, httptest.NewRecorder())
+}
+
+func TestRunTaskManualRunQuota(t *testing.T) {
+	r, mock := mockRoutes(t)
+	expectTask := func() {
+		mock.ExpectQuery(`SELECT \* FROM "tasks" WHERE id = \$1`).
+			WithArgs("task", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "scale_config"}).
+				AddRow("task", `{"max_queued_runs":5,"manual_run_quota":{"max_runs":2,"period":"24h"}}`))
+	}
+	// the limits are checked and the run created while the task row is locked
+	expectLimits := func(queued, manual int) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT \* FROM "tasks" WHERE id = \$1 .*FOR UPDATE`).
+			WithArgs("task", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("task"))
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "task_runs" WHERE task_id = \$1 AND status = \$2`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(queued))
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "task_runs" WHERE task_id = \$1 AND trigger_type = \$2 AND triggered_by = \$3`).
+			WithArgs("task", models.TriggerTypeManual, "user", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(manual))
+	}
+
+	expectTask()
+	expectLimits(0, 1)
+	mock.ExpectQuery(`INSERT INTO "task_runs"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	require.NoError(t, r.RunTask(runTaskRequest()))
+
+	// the quota is used up, nothing is inserted
+	expectTask()
+	expectLimits(1, 2)
+	mock.ExpectRollback()
+	var httpErr *echo.HTTPError
+	require.True(t, errors.As(r.RunTask(runTaskRequest()), &httpErr))
+	assert.Equal(t, http.StatusTooManyRequests, httpErr.Code)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,10 +3,14 @@ package scheduler
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"github.com/nats-io/nats.go/jetstream"
 	"github.com/opengovern/opensecurity/services/tasks/db/models"
 	"go.uber.org/zap"
 	"golang.org/x/net/context"
+	"gorm.io/gorm"
+	"strconv"
+	"time"
 )
 
 type TaskResponse struct {
@@ -30,40 +34,7 @@ func (s *TaskScheduler) RunTaskResponseConsumer(ctx context.Context) error {
 				return
 			}
 
-			if response.CredentialsHealthStatus != nil {
-				err := s.db.UpdateTaskConfigSecretHealthStatus(s.TaskID, *response.CredentialsHealthStatus)
-				if err != nil {
-					s.logger.Error("Failed to update task config secret health status", zap.Error(err))
-					return
-				}
-			}
-
-			taskRunUpdate := models.TaskRun{
-				Status:         response.Status,
-				FailureMessage: response.FailureMessage,
-			}
-			emptyResult := []byte("")
-			if response.Result == nil || len(response.Result) == 0 || bytes.Equal(response.Result, emptyResult) {
-				response.Result = []byte("{}")
-			}
-			err := taskRunUpdate.Result.Set(response.Result)
-			if err != nil {
-				s.logger.Error("failed to set result", zap.Error(err))
-				return
-			}
-			s.logger.Info("Received task response message",
-				zap.Uint("runId", response.RunID),
-				zap.String("status", string(response.Status)),
-				zap.Int("resultSize", len(response.Result)),
-				zap.String("failureMsg", response.FailureMessage))
-			err = s.db.UpdateTaskRun(response.RunID, taskRunUpdate.Status, taskRunUpdate.Result, taskRunUpdate.FailureMessage)
-			if err != nil {
-				s.logger.Error("Failed to update the status of RunTaskResponse",
-					zap.String("Task", s.TaskID),
-					zap.Uint("RunID", response.RunID),
-					zap.Error(err))
-				return
-			}
+			s.handleTaskResponse(response)
 		}); err != nil {
 		return err
 	}
@@ -71,3 +42,96 @@ func (s *TaskScheduler) RunTaskResponseConsumer(ctx context.Context) error {
 	<-ctx.Done()
 	return nil
 }
+
+// handleTaskResponse records the credentials health checked by the run and writes the run result
+func (s *TaskScheduler) handleTaskResponse(response TaskResponse) {
+	// the message is already acked, so the run result is written even if the secret health is not
+	if err := s.recordCredentialsHealth(response); err != nil {
+		s.logger.Error("Failed to update task config secret health status",
+			zap.Uint("RunID", response.RunID), zap.Error(err))
+	}
+
+	taskRunUpdate := models.TaskRun{
+		Status:         response.Status,
+		FailureMessage: response.FailureMessage,
+	}
+	emptyResult := []byte("")
+	if response.Result == nil || len(response.Result) == 0 || bytes.Equal(response.Result, emptyResult) {
+		response.Result = []byte("{}")
+	}
+	err := taskRunUpdate.Result.Set(response.Result)
+	if err != nil {
+		s.logger.Error("failed to set result", zap.Error(err))
+		return
+	}
+	s.logger.Info("Received task response message",
+		zap.Uint("runId", response.RunID),
+		zap.String("status", string(response.Status)),
+		zap.Int("resultSize", len(response.Result)),
+		zap.String("failureMsg", response.FailureMessage))
+	err = s.db.UpdateTaskRun(response.RunID, taskRunUpdate.Status, taskRunUpdate.Result, taskRunUpdate.FailureMessage)
+	if err != nil {
+		s.logger.Error("Failed to update the status of RunTaskResponse",
+			zap.String("Task", s.TaskID),
+			zap.Uint("RunID", response.RunID),
+			zap.Error(err))
+	}
+}
+
+// recordCredentialsHealth updates the health of the config secret checked by the run and records it in the
+// health history. A healthy staged secret replaces the active one.
+func (s *TaskScheduler) recordCredentialsHealth(response TaskResponse) error {
+	run, err := s.db.GetTaskRun(strconv.FormatUint(uint64(response.RunID), 10))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	isCheck := run != nil && run.TriggerType == models.TriggerTypeCredentialsCheck
+
+	var healthStatus models.TaskSecretHealthStatus
+	switch {
+	case isCheck && !response.Status.IsTerminal():
+		return nil
+	case response.CredentialsHealthStatus != nil:
+		healthStatus = *response.CredentialsHealthStatus
+	case isCheck && response.Status == models.TaskRunStatusFinished:
+		healthStatus = models.TaskSecretHealthStatusHealthy
+	case isCheck:
+		healthStatus = models.TaskSecretHealthStatusUnhealthy
+	default:
+		return nil
+	}
+
+	staged := isCheck && run.TriggeredBy == models.CredentialsCheckTargetStaged
+	if staged {
+		configSecret, err := s.db.GetTaskConfigSecret(s.TaskID)
+		if err != nil {
+			return err
+		}
+		if configSecret == nil || configSecret.StagedRunID == nil || *configSecret.StagedRunID != run.ID {
+			// the staged secret was discarded or rotated again since this run was created
+			return nil
+		}
+		if err = s.db.UpdateStagedTaskConfigSecretHealthStatus(s.TaskID, healthStatus); err != nil {
+			return err
+		}
+		if healthStatus == models.TaskSecretHealthStatusHealthy {
+			if err = s.db.PromoteStagedTaskConfigSecret(s.TaskID); err != nil {
+				return err
+			}
+			s.logger.Info("staged task config secret validated and promoted", zap.String("task", s.TaskID))
+		}
+	} else {
+		if err = s.db.SetTaskConfigSecretHealthStatus(s.TaskID, healthStatus, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	return s.db.CreateTaskConfigSecretHealthCheck(&models.TaskConfigSecretHealthCheck{
+		TaskID:         s.TaskID,
+		RunID:          response.RunID,
+		Staged:         staged,
+		HealthStatus:   healthStatus,
+		FailureMessage: response.FailureMessage,
+		CheckedAt:      time.Now(),
+	})
+}
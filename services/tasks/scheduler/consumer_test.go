@@ -0,0 +1,27 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/opengovern/opensecurity/services/tasks/db/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleTaskResponseWritesResultWhenHealthFails(t *testing.T) {
+	s, mock := mockScheduler(t)
+	task := &TaskScheduler{db: s.db, logger: s.logger, TaskID: "task"}
+
+	mock.ExpectQuery(`SELECT \* FROM "task_runs" WHERE id = \$1`).
+		WillReturnError(errors.New("connection reset"))
+	// the message is acked before it is handled, so losing the result would leave the run in progress forever
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "task_runs" SET .*"status"=\$\d+,"result"=\$\d+ WHERE id = \$\d+`).
+		WithArgs(sqlmock.AnyArg(), models.TaskRunStatusFinished, jsonArg{`{"findings":2}`}, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	task.handleTaskResponse(TaskResponse{RunID: 7, Status: models.TaskRunStatusFinished, Result: []byte(`{"findings":2}`)})
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
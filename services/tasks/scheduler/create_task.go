@@ -6,6 +6,7 @@ import (
 	"github.com/opengovern/og-util/pkg/httpclient"
 	"github.com/opengovern/og-util/pkg/ticker"
 	"github.com/opengovern/opensecurity/services/tasks/db/models"
+	"github.com/opengovern/opensecurity/services/tasks/utils"
 	"go.uber.org/zap"
 	"time"
 )
@@ -39,6 +40,10 @@ func (s *MainScheduler) createTasks(ctx context.Context) error {
 		if err != nil {
 			return err
 		}
+		runLimits, err := utils.GetTaskRunLimits(task)
+		if err != nil {
+			return err
+		}
 		for _, runSchedule := range runSchedules {
 			lastRun, err := s.db.FetchLastTaskRunsByTaskSchedulerID(task.ID, runSchedule.ID)
 			if err != nil {
@@ -50,11 +55,24 @@ func (s *MainScheduler) createTasks(ctx context.Context) error {
 				}
 			}
 
+			if runLimits.MaxQueuedRuns > 0 {
+				queued, err := s.db.CountCreatedTaskRunsByTaskID(task.ID)
+				if err != nil {
+					return err
+				}
+				if queued >= int64(runLimits.MaxQueuedRuns) {
+					s.logger.Info("task run queue is full, skipping scheduled run",
+						zap.String("task", task.ID), zap.String("schedule", runSchedule.ID))
+					continue
+				}
+			}
+
 			newRun := models.TaskRun{
 				TaskID:      task.ID,
 				Status:      models.TaskRunStatusCreated,
 				TriggerType: models.TriggerTypeScheduled,
 				TriggeredBy: runSchedule.ID,
+				Priority:    utils.GetRunPriority(runLimits, models.TriggerTypeScheduled),
 			}
 
 			err = newRun.Result.Set([]byte("{}"))
@@ -63,7 +81,29 @@ func (s *MainScheduler) createTasks(ctx context.Context) error {
 			}
 			newRun.Params = runSchedule.Params
 
-			if err = s.db.CreateTaskRun(&newRun); err != nil {
+			target, err := utils.ParseIntegrationTarget(runSchedule.IntegrationTarget)
+			if err != nil {
+				return err
+			}
+			if target == nil {
+				if err = s.db.CreateTaskRun(&newRun); err != nil {
+					return err
+				}
+				continue
+			}
+
+			integrations, err := utils.ResolveIntegrationTarget(ctx2, s.integrationClient, *target)
+			if err != nil {
+				s.logger.Error("failed to resolve run schedule integrations", zap.String("task", task.ID),
+					zap.String("schedule", runSchedule.ID), zap.Error(err))
+				continue
+			}
+			if len(integrations) == 0 {
+				s.logger.Info("no integrations matched the run schedule, skipping", zap.String("task", task.ID),
+					zap.String("schedule", runSchedule.ID))
+				continue
+			}
+			if err = utils.CreateIntegrationTaskRuns(s.db, &newRun, integrations); err != nil {
 				return err
 			}
 		}
@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"context"
+	"github.com/opengovern/og-util/pkg/ticker"
+	"github.com/opengovern/opensecurity/services/tasks/db/models"
+	"github.com/opengovern/opensecurity/services/tasks/utils"
+	"go.uber.org/zap"
+	"strconv"
+	"time"
+)
+
+const DefaultCredentialsHealthCheckInterval = time.Hour
+
+// CredentialsHealthCheckScheduler periodically creates credentials check runs for the config secrets of the tasks
+// with the credentials_check capability and marks staged secrets unhealthy when their validation run did not finish.
+func (s *MainScheduler) CredentialsHealthCheckScheduler(ctx context.Context) {
+	s.logger.Info("Scheduling credentials health checks on a timer")
+
+	interval := DefaultCredentialsHealthCheckInterval
+	if s.cfg.CredentialsHealthCheckIntervalMinutes > 0 {
+		interval = time.Duration(s.cfg.CredentialsHealthCheckIntervalMinutes) * time.Minute
+	}
+
+	t := ticker.NewTicker(time.Minute, time.Second*10)
+	defer t.Stop()
+
+	for ; ; <-t.C {
+		configSecrets, err := s.db.ListTaskConfigSecrets()
+		if err != nil {
+			s.logger.Error("failed to list task config secrets", zap.Error(err))
+			continue
+		}
+		for _, configSecret := range configSecrets {
+			if err = s.checkTaskCredentials(configSecret, interval); err != nil {
+				s.logger.Error("failed to check task credentials", zap.String("task", configSecret.TaskID), zap.Error(err))
+			}
+		}
+	}
+}
+
+func (s *MainScheduler) checkTaskCredentials(configSecret models.TaskConfigSecret, interval time.Duration) error {
+	task, err := s.db.GetTask(configSecret.TaskID)
+	if err != nil {
+		return err
+	}
+	if task == nil || !task.IsEnabled || !task.CredentialsCheck {
+		return nil
+	}
+
+	if configSecret.StagedSecret != "" && configSecret.StagedRunID != nil &&
+		configSecret.StagedHealthStatus == models.TaskSecretHealthStatusUnknown {
+		s.expireStagedSecretCheck(configSecret)
+	}
+
+	if configSecret.LastCheckedAt != nil && time.Since(*configSecret.LastCheckedAt) < interval {
+		return nil
+	}
+	pending, err := s.db.CountPendingCredentialsCheckRuns(task.ID, models.CredentialsCheckTargetActive)
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return nil
+	}
+
+	run, err := utils.NewCredentialsCheckRun(*task, models.CredentialsCheckTargetActive)
+	if err != nil {
+		return err
+	}
+	if err = s.db.CreateTaskRun(run); err != nil {
+		return err
+	}
+	if err = s.db.SetTaskConfigSecretLastCheckedAt(task.ID, time.Now()); err != nil {
+		return err
+	}
+	s.logger.Info("credentials check run created", zap.String("task", task.ID), zap.Uint("runId", run.ID))
+	return nil
+}
+
+// expireStagedSecretCheck marks a staged secret unhealthy if its validation run ended without a response
+func (s *MainScheduler) expireStagedSecretCheck(configSecret models.TaskConfigSecret) {
+	run, err := s.db.GetTaskRun(strconv.FormatUint(uint64(*configSecret.StagedRunID), 10))
+	if err != nil {
+		s.logger.Error("failed to get staged secret check run", zap.String("task", configSecret.TaskID), zap.Error(err))
+		return
+	}
+	if run.Status != models.TaskRunStatusTimeout && run.Status != models.TaskRunStatusCancelled {
+		return
+	}
+
+	if err = s.db.UpdateStagedTaskConfigSecretHealthStatus(configSecret.TaskID, models.TaskSecretHealthStatusUnhealthy); err != nil {
+		s.logger.Error("failed to update staged secret health status", zap.String("task", configSecret.TaskID), zap.Error(err))
+		return
+	}
+	err = s.db.CreateTaskConfigSecretHealthCheck(&models.TaskConfigSecretHealthCheck{
+		TaskID:         configSecret.TaskID,
+		RunID:          run.ID,
+		Staged:         true,
+		HealthStatus:   models.TaskSecretHealthStatusUnhealthy,
+		FailureMessage: "credentials check run ended with status " + string(run.Status),
+		CheckedAt:      time.Now(),
+	})
+	if err != nil {
+		s.logger.Error("failed to record staged secret health check", zap.String("task", configSecret.TaskID), zap.Error(err))
+	}
+}
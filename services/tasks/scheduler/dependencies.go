@@ -0,0 +1,261 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgtype"
+	"github.com/opengovern/og-util/pkg/ticker"
+	"github.com/opengovern/opensecurity/services/tasks/db/models"
+	"go.uber.org/zap"
+	"time"
+)
+
+// FanOutRunResult is the result of a single child run inside the aggregated result of a fanned out run
+type FanOutRunResult struct {
+	RunID          uint                 `json:"run_id"`
+	Status         models.TaskRunStatus `json:"status"`
+	Result         any                  `json:"result"`
+	FailureMessage string               `json:"failure_message,omitempty"`
+}
+
+type FanOutAggregatedResult struct {
+	TotalCount    int               `json:"total_count"`
+	FinishedCount int               `json:"finished_count"`
+	FailedCount   int               `json:"failed_count"`
+	Runs          []FanOutRunResult `json:"runs"`
+}
+
+// DependencyResolverScheduler releases waiting task runs once their upstream runs are finished
+// and aggregates the results of fanned out runs once all their children are done.
+func (s *MainScheduler) DependencyResolverScheduler(ctx context.Context) {
+	s.logger.Info("Scheduling dependency resolver on a timer")
+
+	t := ticker.NewTicker(time.Second*15, time.Second*10)
+	defer t.Stop()
+
+	for ; ; <-t.C {
+		if err := s.resolveWaitingTaskRuns(); err != nil {
+			s.logger.Error("failed to resolve waiting task runs", zap.Error(err))
+		}
+		if err := s.aggregateFannedOutTaskRuns(); err != nil {
+			s.logger.Error("failed to aggregate fanned out task runs", zap.Error(err))
+		}
+	}
+}
+
+func (s *MainScheduler) resolveWaitingTaskRuns() error {
+	runs, err := s.db.FetchTaskRunsByStatus(models.TaskRunStatusWaiting)
+	if err != nil {
+		return err
+	}
+
+	for _, run := range runs {
+		if err := s.resolveWaitingTaskRun(run); err != nil {
+			s.logger.Error("failed to resolve task run dependencies", zap.Uint("runId", run.ID), zap.Error(err))
+			continue
+		}
+	}
+	return nil
+}
+
+func (s *MainScheduler) resolveWaitingTaskRun(run models.TaskRun) error {
+	dependencies, err := s.db.GetTaskRunDependencies(run.ID)
+	if err != nil {
+		return err
+	}
+
+	var upstreamIDs []uint
+	for _, dependency := range dependencies {
+		upstreamIDs = append(upstreamIDs, dependency.UpstreamRunID)
+	}
+	upstreamRuns := make(map[uint]models.TaskRun)
+	if len(upstreamIDs) > 0 {
+		runs, err := s.db.GetTaskRunsByIDs(upstreamIDs)
+		if err != nil {
+			return err
+		}
+		for _, r := range runs {
+			upstreamRuns[r.ID] = r
+		}
+	}
+
+	for _, dependency := range dependencies {
+		upstream, ok := upstreamRuns[dependency.UpstreamRunID]
+		if !ok {
+			return s.failTaskRun(run.ID, fmt.Sprintf("upstream run %d not found", dependency.UpstreamRunID))
+		}
+		switch upstream.Status {
+		case models.TaskRunStatusFinished:
+		case models.TaskRunStatusFailed, models.TaskRunStatusTimeout, models.TaskRunStatusCancelled:
+			return s.failTaskRun(run.ID, fmt.Sprintf("upstream run %d ended with status %s", upstream.ID, upstream.Status))
+		default:
+			// upstream is still running
+			return nil
+		}
+	}
+
+	params, _ := JSONBToMap(run.Params)
+	if params == nil {
+		params = make(map[string]any)
+	}
+
+	var fanOutDependency *models.TaskRunDependency
+	var fanOutItems []any
+	for _, dependency := range dependencies {
+		upstream := upstreamRuns[dependency.UpstreamRunID]
+		var result any
+		if upstream.Result.Status == pgtype.Present && len(upstream.Result.Bytes) > 0 {
+			if err := json.Unmarshal(upstream.Result.Bytes, &result); err != nil {
+				return s.failTaskRun(run.ID, fmt.Sprintf("failed to read result of upstream run %d", upstream.ID))
+			}
+		}
+
+		if dependency.FanOutKey != "" {
+			resultMap, _ := result.(map[string]any)
+			items, ok := resultMap[dependency.FanOutKey].([]any)
+			if !ok {
+				return s.failTaskRun(run.ID, fmt.Sprintf("result of upstream run %d has no list under %s", upstream.ID, dependency.FanOutKey))
+			}
+			dependency := dependency
+			fanOutDependency = &dependency
+			fanOutItems = items
+			continue
+		}
+		params[dependencyParamName(dependency)] = result
+	}
+
+	if fanOutDependency == nil {
+		paramsJsonb, err := mapToJSONB(params)
+		if err != nil {
+			return err
+		}
+		s.logger.Info("task run dependencies finished, releasing run", zap.Uint("runId", run.ID))
+		return s.db.ReleaseWaitingTaskRun(run.ID, paramsJsonb)
+	}
+
+	paramName := dependencyParamName(*fanOutDependency)
+	var children []models.TaskRun
+	for _, item := range fanOutItems {
+		childParams := make(map[string]any)
+		for k, v := range params {
+			childParams[k] = v
+		}
+		childParams[paramName] = item
+
+		child := models.TaskRun{
+			TaskID:      run.TaskID,
+			Status:      models.TaskRunStatusCreated,
+			TriggerType: run.TriggerType,
+			TriggeredBy: run.TriggeredBy,
+			ParentRunID: &run.ID,
+			Priority:    run.Priority,
+		}
+		child.Params, err = mapToJSONB(childParams)
+		if err != nil {
+			return err
+		}
+		if err = child.Result.Set([]byte("{}")); err != nil {
+			return err
+		}
+		children = append(children, child)
+	}
+
+	s.logger.Info("fanning out task run", zap.Uint("runId", run.ID), zap.Int("children", len(children)))
+	return s.db.FanOutTaskRun(run.ID, children)
+}
+
+func (s *MainScheduler) aggregateFannedOutTaskRuns() error {
+	runs, err := s.db.FetchTaskRunsByStatus(models.TaskRunStatusFannedOut)
+	if err != nil {
+		return err
+	}
+
+	for _, run := range runs {
+		children, err := s.db.ListChildTaskRuns(run.ID)
+		if err != nil {
+			s.logger.Error("failed to list child task runs", zap.Uint("runId", run.ID), zap.Error(err))
+			continue
+		}
+
+		aggregated := FanOutAggregatedResult{
+			TotalCount: len(children),
+			Runs:       []FanOutRunResult{},
+		}
+		done := true
+		for _, child := range children {
+			if !child.Status.IsTerminal() {
+				done = false
+				break
+			}
+			var result any
+			if child.Result.Status == pgtype.Present && len(child.Result.Bytes) > 0 {
+				_ = json.Unmarshal(child.Result.Bytes, &result)
+			}
+			if child.Status == models.TaskRunStatusFinished {
+				aggregated.FinishedCount++
+			} else {
+				aggregated.FailedCount++
+			}
+			aggregated.Runs = append(aggregated.Runs, FanOutRunResult{
+				RunID:          child.ID,
+				Status:         child.Status,
+				Result:         result,
+				FailureMessage: child.FailureMessage,
+			})
+		}
+		if !done {
+			continue
+		}
+
+		resultJson, err := json.Marshal(aggregated)
+		if err != nil {
+			s.logger.Error("failed to marshal aggregated result", zap.Uint("runId", run.ID), zap.Error(err))
+			continue
+		}
+		var result pgtype.JSONB
+		if err = result.Set(resultJson); err != nil {
+			s.logger.Error("failed to set aggregated result", zap.Uint("runId", run.ID), zap.Error(err))
+			continue
+		}
+
+		status := models.TaskRunStatusFinished
+		failureMessage := ""
+		if aggregated.FailedCount > 0 {
+			status = models.TaskRunStatusFailed
+			failureMessage = fmt.Sprintf("%d of %d fan-out runs did not finish successfully", aggregated.FailedCount, aggregated.TotalCount)
+		}
+		s.logger.Info("fan-out task run completed", zap.Uint("runId", run.ID), zap.String("status", string(status)))
+		if err = s.db.UpdateTaskRun(run.ID, status, result, failureMessage); err != nil {
+			s.logger.Error("failed to update fanned out task run", zap.Uint("runId", run.ID), zap.Error(err))
+			continue
+		}
+	}
+	return nil
+}
+
+func (s *MainScheduler) failTaskRun(runID uint, failureMessage string) error {
+	s.logger.Info("failing task run", zap.Uint("runId", runID), zap.String("reason", failureMessage))
+	result := pgtype.JSONB{}
+	_ = result.Set([]byte("{}"))
+	return s.db.UpdateTaskRun(runID, models.TaskRunStatusFailed, result, failureMessage)
+}
+
+func dependencyParamName(dependency models.TaskRunDependency) string {
+	if dependency.ParamName != "" {
+		return dependency.ParamName
+	}
+	return fmt.Sprintf("upstream_%d", dependency.UpstreamRunID)
+}
+
+func mapToJSONB(m map[string]any) (pgtype.JSONB, error) {
+	var jsonb pgtype.JSONB
+	data, err := json.Marshal(m)
+	if err != nil {
+		return jsonb, err
+	}
+	if err = jsonb.Set(data); err != nil {
+		return jsonb, err
+	}
+	return jsonb, nil
+}
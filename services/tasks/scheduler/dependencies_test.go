@@ -0,0 +1,180 @@
+package scheduler
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/opengovern/opensecurity/services/tasks/db"
+	"github.com/opengovern/opensecurity/services/tasks/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func mockScheduler(t *testing.T) (*MainScheduler, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	orm, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+	return &MainScheduler{db: db.Database{Orm: orm}, logger: zap.NewNop()}, mock
+}
+
+var taskRunColumns = []string{"id", "task_id", "status", "params", "result", "failure_message", "parent_run_id", "created_at"}
+
+func expectDependencies(mock sqlmock.Sqlmock, runID uint, dependencies ...models.TaskRunDependency) {
+	rows := sqlmock.NewRows([]string{"run_id", "upstream_run_id", "param_name", "fan_out_key"})
+	for _, d := range dependencies {
+		rows.AddRow(d.RunID, d.UpstreamRunID, d.ParamName, d.FanOutKey)
+	}
+	mock.ExpectQuery(`SELECT \* FROM "task_run_dependencies" WHERE run_id = \$1`).
+		WithArgs(runID).
+		WillReturnRows(rows)
+}
+
+func expectUpstreamRun(mock sqlmock.Sqlmock, id uint, status models.TaskRunStatus, result string) {
+	mock.ExpectQuery(`SELECT \* FROM "task_runs" WHERE id IN \(\$1\)`).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(taskRunColumns).
+			AddRow(id, "upstream", status, "{}", result, "", nil, time.Now()))
+}
+
+// jsonArg matches a JSON query argument equal to the expected value
+type jsonArg struct {
+	expected string
+}
+
+func (a jsonArg) Match(v driver.Value) bool {
+	var data []byte
+	switch value := v.(type) {
+	case string:
+		data = []byte(value)
+	case []byte:
+		data = value
+	default:
+		return false
+	}
+	var actual, expected any
+	if json.Unmarshal(data, &actual) != nil || json.Unmarshal([]byte(a.expected), &expected) != nil {
+		return false
+	}
+	return assert.ObjectsAreEqual(expected, actual)
+}
+
+func waitingRun(id uint, params string) models.TaskRun {
+	run := models.TaskRun{TaskID: "downstream", Status: models.TaskRunStatusWaiting, TriggerType: models.TriggerTypeManual}
+	run.ID = id
+	_ = run.Params.Set([]byte(params))
+	return run
+}
+
+func TestResolveWaitingTaskRunReleasesRun(t *testing.T) {
+	s, mock := mockScheduler(t)
+	expectDependencies(mock, 2, models.TaskRunDependency{RunID: 2, UpstreamRunID: 1, ParamName: "assets"})
+	expectUpstreamRun(mock, 1, models.TaskRunStatusFinished, `{"count":2}`)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "task_runs" SET "updated_at"=\$1,"params"=\$2,"status"=\$3 WHERE id = \$4 AND status = \$5`).
+		WithArgs(sqlmock.AnyArg(), jsonArg{`{"region":"us-east-1","assets":{"count":2}}`}, models.TaskRunStatusCreated, 2, models.TaskRunStatusWaiting).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, s.resolveWaitingTaskRun(waitingRun(2, `{"region":"us-east-1"}`)))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResolveWaitingTaskRunWaitsForUpstream(t *testing.T) {
+	s, mock := mockScheduler(t)
+	expectDependencies(mock, 2, models.TaskRunDependency{RunID: 2, UpstreamRunID: 1})
+	expectUpstreamRun(mock, 1, models.TaskRunStatusInProgress, `{}`)
+
+	require.NoError(t, s.resolveWaitingTaskRun(waitingRun(2, `{}`)))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResolveWaitingTaskRunFailsWithUpstream(t *testing.T) {
+	s, mock := mockScheduler(t)
+	expectDependencies(mock, 2, models.TaskRunDependency{RunID: 2, UpstreamRunID: 1})
+	expectUpstreamRun(mock, 1, models.TaskRunStatusTimeout, `{}`)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "task_runs" SET .*"status"=\$\d+,.*"failure_message"=\$\d+.* WHERE id = \$\d+`).
+		WithArgs(sqlmock.AnyArg(), models.TaskRunStatusFailed, sqlmock.AnyArg(), "upstream run 1 ended with status TIMEOUT", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, s.resolveWaitingTaskRun(waitingRun(2, `{}`)))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResolveWaitingTaskRunFansOut(t *testing.T) {
+	s, mock := mockScheduler(t)
+	expectDependencies(mock, 2, models.TaskRunDependency{RunID: 2, UpstreamRunID: 1, ParamName: "repository", FanOutKey: "repositories"})
+	expectUpstreamRun(mock, 1, models.TaskRunStatusFinished, `{"repositories":["api","web"]}`)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "task_runs" SET "status"=\$1,"updated_at"=\$2 WHERE id = \$3 AND status = \$4`).
+		WithArgs(models.TaskRunStatusFannedOut, sqlmock.AnyArg(), 2, models.TaskRunStatusWaiting).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "task_runs"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(4))
+	mock.ExpectCommit()
+
+	require.NoError(t, s.resolveWaitingTaskRun(waitingRun(2, `{}`)))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFanOutTaskRunNoLongerWaiting(t *testing.T) {
+	s, mock := mockScheduler(t)
+
+	// another scheduler fanned the run out first, so no duplicate children are created
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "task_runs" SET "status"=\$1,"updated_at"=\$2 WHERE id = \$3 AND status = \$4`).
+		WithArgs(models.TaskRunStatusFannedOut, sqlmock.AnyArg(), 2, models.TaskRunStatusWaiting).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	parentID := uint(2)
+	require.NoError(t, s.db.FanOutTaskRun(parentID, []models.TaskRun{{TaskID: "downstream", ParentRunID: &parentID}}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAggregateFannedOutTaskRuns(t *testing.T) {
+	s, mock := mockScheduler(t)
+	mock.ExpectQuery(`SELECT \* FROM "task_runs" WHERE status = \$1`).
+		WithArgs(models.TaskRunStatusFannedOut).
+		WillReturnRows(sqlmock.NewRows(taskRunColumns).
+			AddRow(2, "downstream", models.TaskRunStatusFannedOut, "{}", "{}", "", nil, time.Now()).
+			AddRow(5, "downstream", models.TaskRunStatusFannedOut, "{}", "{}", "", nil, time.Now()))
+
+	mock.ExpectQuery(`SELECT \* FROM "task_runs" WHERE parent_run_id = \$1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(taskRunColumns).
+			AddRow(3, "downstream", models.TaskRunStatusFinished, "{}", `{"findings":1}`, "", 2, time.Now()).
+			AddRow(4, "downstream", models.TaskRunStatusFailed, "{}", "{}", "timed out", 2, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "task_runs" SET .*"status"=\$\d+,"result"=\$\d+,"failure_message"=\$\d+ WHERE id = \$\d+`).
+		WithArgs(sqlmock.AnyArg(), models.TaskRunStatusFailed, jsonArg{`{
+			"total_count": 2, "finished_count": 1, "failed_count": 1,
+			"runs": [
+				{"run_id": 3, "status": "FINISHED", "result": {"findings": 1}},
+				{"run_id": 4, "status": "FAILED", "result": {}, "failure_message": "timed out"}
+			]}`}, "1 of 2 fan-out runs did not finish successfully", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// the second run still has a child in progress and is left fanned out
+	mock.ExpectQuery(`SELECT \* FROM "task_runs" WHERE parent_run_id = \$1`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(taskRunColumns).
+			AddRow(6, "downstream", models.TaskRunStatusFinished, "{}", "{}", "", 5, time.Now()).
+			AddRow(7, "downstream", models.TaskRunStatusInProgress, "{}", "{}", "", 5, time.Now()))
+
+	require.NoError(t, s.aggregateFannedOutTaskRuns())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
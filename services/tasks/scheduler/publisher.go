@@ -9,15 +9,12 @@ import (
 	"github.com/opengovern/og-util/pkg/httpclient"
 	"github.com/opengovern/og-util/pkg/tasks"
 	"github.com/opengovern/opensecurity/services/tasks/db/models"
+	"github.com/opengovern/opensecurity/services/tasks/utils"
 	"go.uber.org/zap"
 	"golang.org/x/net/context"
 	"net/http"
 )
 
-const (
-	MaximumInProgressTaskRuns = 10
-)
-
 func (s *TaskScheduler) runPublisher(ctx context.Context) error {
 	ctx2 := &httpclient.Context{UserRole: api.AdminRole}
 	ctx2.Ctx = ctx
@@ -32,17 +29,33 @@ func (s *TaskScheduler) runPublisher(ctx context.Context) error {
 		return err
 	}
 
+	task, err := s.db.GetTask(s.TaskID)
+	if err != nil || task == nil {
+		s.logger.Error("failed to get task", zap.String("task_id", s.TaskID), zap.Error(err))
+		return fmt.Errorf("failed to get task %s", s.TaskID)
+	}
+	runLimits, err := utils.GetTaskRunLimits(*task)
+	if err != nil {
+		s.logger.Error("failed to get task run limits", zap.String("task_id", s.TaskID), zap.Error(err))
+		return err
+	}
+
 	inProgressCount, err := s.db.CountInProgressTaskRunsByTaskID(s.TaskID)
 	if err != nil {
 		s.logger.Error("failed to count in-progress task runs", zap.Error(err))
 		return err
 	}
-	if inProgressCount != nil && *inProgressCount > MaximumInProgressTaskRuns {
-		s.logger.Info("in progress tasks reached the maximum number of task runs")
+	available := runLimits.MaxConcurrentRuns
+	if inProgressCount != nil {
+		available -= int(*inProgressCount)
+	}
+	if available <= 0 {
+		s.logger.Info("in progress tasks reached the maximum number of task runs",
+			zap.String("task_id", s.TaskID), zap.Int("max_concurrent_runs", runLimits.MaxConcurrentRuns))
 		return nil
 	}
 
-	runs, err := s.db.FetchCreatedTaskRunsByTaskID(s.TaskID)
+	runs, err := s.db.FetchCreatedTaskRunsByTaskID(s.TaskID, available)
 	if err != nil {
 		s.logger.Error("failed to get task runs", zap.Error(err))
 		return err
@@ -66,7 +79,17 @@ func (s *TaskScheduler) runPublisher(ctx context.Context) error {
 			return err
 		}
 		if configSecrets != nil {
-			mapData, err := s.vault.Decrypt(ctx, configSecrets.Secret)
+			secret := configSecrets.Secret
+			if run.TriggerType == models.TriggerTypeCredentialsCheck && run.TriggeredBy == models.CredentialsCheckTargetStaged {
+				secret = configSecrets.StagedSecret
+			}
+			if secret == "" {
+				result := pgtype.JSONB{}
+				_ = result.Set([]byte("{}"))
+				_ = s.db.UpdateTaskRun(run.ID, models.TaskRunStatusFailed, result, "no staged secret to validate")
+				continue
+			}
+			mapData, err := s.vault.Decrypt(ctx, secret)
 			if err != nil {
 				s.logger.Error("failed to decrypt secret", zap.Error(err))
 				return echo.NewHTTPError(http.StatusInternalServerError, "failed to decrypt config")
@@ -75,6 +98,17 @@ func (s *TaskScheduler) runPublisher(ctx context.Context) error {
 				params[k] = v
 			}
 		}
+		if run.IntegrationID != "" {
+			integrationParam, err := s.getIntegrationParam(ctx2, run.IntegrationID)
+			if err != nil {
+				result := pgtype.JSONB{}
+				_ = result.Set([]byte("{}"))
+				_ = s.db.UpdateTaskRun(run.ID, models.TaskRunStatusFailed, result, fmt.Sprintf("failed to get integration %s: %v", run.IntegrationID, err))
+				s.logger.Error("failed to get integration", zap.Error(err), zap.Uint("runId", run.ID), zap.String("integrationId", run.IntegrationID))
+				continue
+			}
+			params[utils.IntegrationParam] = integrationParam
+		}
 		req := tasks.TaskRequest{
 			EsDeliverEndpoint:         s.cfg.ESSinkEndpoint,
 			IngestionPipelineEndpoint: s.cfg.ElasticSearch.IngestionEndpoint,
@@ -130,6 +164,40 @@ func (s *TaskScheduler) runPublisher(ctx context.Context) error {
 	return nil
 }
 
+// getIntegrationParam returns the integration a run targets along with its decrypted credentials
+func (s *TaskScheduler) getIntegrationParam(ctx *httpclient.Context, integrationID string) (map[string]any, error) {
+	integration, err := s.integrationClient.GetIntegration(ctx, integrationID)
+	if err != nil {
+		return nil, err
+	}
+	if integration == nil {
+		return nil, fmt.Errorf("integration not found")
+	}
+
+	var credentials map[string]any
+	if integration.CredentialID != "" {
+		credential, err := s.integrationClient.ResolveCredential(ctx, integration.CredentialID, "task")
+		if err != nil {
+			return nil, err
+		}
+		if credential != nil && credential.Secret != "" {
+			credentials, err = s.vault.Decrypt(ctx.Ctx, credential.Secret)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt credential: %w", err)
+			}
+		}
+	}
+
+	return map[string]any{
+		"integration_id":   integration.IntegrationID,
+		"provider_id":      integration.ProviderID,
+		"name":             integration.Name,
+		"integration_type": integration.IntegrationType,
+		"labels":           integration.Labels,
+		"credentials":      credentials,
+	}, nil
+}
+
 func JSONBToMap(jsonb pgtype.JSONB) (map[string]any, error) {
 	if jsonb.Status != pgtype.Present {
 		return nil, fmt.Errorf("JSONB data is not present")
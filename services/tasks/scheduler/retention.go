@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"context"
+	"github.com/opengovern/og-util/pkg/ticker"
+	"go.uber.org/zap"
+	"time"
+)
+
+const retentionCleanerBatchSize = 500
+
+// RetentionCleanerScheduler removes the task runs which are out of their task retention policy
+func (s *MainScheduler) RetentionCleanerScheduler(ctx context.Context) {
+	s.logger.Info("Scheduling task run retention cleaner on a timer")
+
+	t := ticker.NewTicker(time.Hour, time.Second*10)
+	defer t.Stop()
+
+	for ; ; <-t.C {
+		if err := s.cleanExpiredTaskRuns(); err != nil {
+			s.logger.Error("failed to clean expired task runs", zap.Error(err))
+			continue
+		}
+	}
+}
+
+func (s *MainScheduler) cleanExpiredTaskRuns() error {
+	policies, err := s.db.ListTaskRunRetentionPolicies()
+	if err != nil {
+		return err
+	}
+
+	for _, policy := range policies {
+		removed := 0
+		for {
+			runs, err := s.db.FetchExpiredTaskRuns(policy, retentionCleanerBatchSize)
+			if err != nil {
+				s.logger.Error("failed to fetch expired task runs", zap.String("task", policy.TaskID), zap.Error(err))
+				break
+			}
+			if len(runs) == 0 {
+				break
+			}
+			if err = s.db.RemoveTaskRuns(runs, policy.ArchiveResults); err != nil {
+				s.logger.Error("failed to remove expired task runs", zap.String("task", policy.TaskID), zap.Error(err))
+				break
+			}
+			removed += len(runs)
+			if len(runs) < retentionCleanerBatchSize {
+				break
+			}
+		}
+		if removed > 0 {
+			s.logger.Info("expired task runs removed", zap.String("task", policy.TaskID),
+				zap.Int("count", removed), zap.Bool("archived", policy.ArchiveResults))
+		}
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/nats-io/nats.go/jetstream"
 	"github.com/opengovern/og-util/pkg/jq"
 	"github.com/opengovern/og-util/pkg/vault"
+	integrationClient "github.com/opengovern/opensecurity/services/integration/client"
 	"github.com/opengovern/opensecurity/services/tasks/config"
 	"github.com/opengovern/opensecurity/services/tasks/db"
 	"github.com/opengovern/opensecurity/services/tasks/worker"
@@ -18,10 +19,11 @@ import (
 )
 
 type MainScheduler struct {
-	jq         *jq.JobQueue
-	db         db.Database
-	kubeClient client.Client
-	logger     *zap.Logger
+	jq                *jq.JobQueue
+	db                db.Database
+	kubeClient        client.Client
+	integrationClient integrationClient.IntegrationServiceClient
+	logger            *zap.Logger
 
 	cfg   config.Config
 	vault vault.VaultSourceConfig
@@ -31,14 +33,16 @@ type MainScheduler struct {
 
 var RunningTasks = make(map[string]bool)
 
-func NewMainScheduler(cfg config.Config, logger *zap.Logger, db db.Database, kubeClient client.Client, vault vault.VaultSourceConfig, jq *jq.JobQueue) (*MainScheduler, error) {
+func NewMainScheduler(cfg config.Config, logger *zap.Logger, db db.Database, kubeClient client.Client, vault vault.VaultSourceConfig, jq *jq.JobQueue,
+	integrationClient integrationClient.IntegrationServiceClient) (*MainScheduler, error) {
 	return &MainScheduler{
-		jq:         jq,
-		db:         db,
-		kubeClient: kubeClient,
-		logger:     logger,
-		cfg:        cfg,
-		vault:      vault,
+		jq:                jq,
+		db:                db,
+		kubeClient:        kubeClient,
+		integrationClient: integrationClient,
+		logger:            logger,
+		cfg:               cfg,
+		vault:             vault,
 	}, nil
 }
 
@@ -88,6 +92,7 @@ func (s *MainScheduler) Start(ctx context.Context) error {
 			s.logger,
 			s.db,
 			s.jq,
+			s.integrationClient,
 			s.cfg,
 			s.vault,
 			task.ID,
@@ -5,6 +5,7 @@ import (
 	"github.com/opengovern/og-util/pkg/ticker"
 	"github.com/opengovern/og-util/pkg/vault"
 	"github.com/opengovern/opensecurity/pkg/utils"
+	integrationClient "github.com/opengovern/opensecurity/services/integration/client"
 	"github.com/opengovern/opensecurity/services/tasks/config"
 	"github.com/opengovern/opensecurity/services/tasks/db"
 	"github.com/opengovern/opensecurity/services/tasks/db/models"
@@ -25,6 +26,7 @@ type TaskScheduler struct {
 	runSetupNatsStreams func(context.Context) error
 	jq                  *jq.JobQueue
 	db                  db.Database
+	integrationClient   integrationClient.IntegrationServiceClient
 	logger              *zap.Logger
 
 	cfg config.Config
@@ -41,6 +43,7 @@ func NewTaskScheduler(
 	logger *zap.Logger,
 	db db.Database,
 	jq *jq.JobQueue,
+	integrationClient integrationClient.IntegrationServiceClient,
 
 	cfg config.Config,
 	vault vault.VaultSourceConfig,
@@ -52,6 +55,7 @@ func NewTaskScheduler(
 		logger:              logger,
 		db:                  db,
 		jq:                  jq,
+		integrationClient:   integrationClient,
 
 		cfg:   cfg,
 		vault: vault,
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"encoding/json"
+	"github.com/opengovern/opensecurity/services/tasks/db/models"
+)
+
+// ValidateCredentialsParam is set on credentials check runs, tasks should only validate their
+// config secret and report credentials_health_status instead of doing the actual work
+const ValidateCredentialsParam = "validate_credentials"
+
+// NewCredentialsCheckRun builds a lightweight run validating the active or staged config secret of a task
+func NewCredentialsCheckRun(task models.Task, target string) (*models.TaskRun, error) {
+	runLimits, err := GetTaskRunLimits(task)
+	if err != nil {
+		return nil, err
+	}
+
+	run := models.TaskRun{
+		TaskID:      task.ID,
+		Status:      models.TaskRunStatusCreated,
+		TriggerType: models.TriggerTypeCredentialsCheck,
+		TriggeredBy: target,
+		Priority:    GetRunPriority(runLimits, models.TriggerTypeManual),
+	}
+	paramsJson, err := json.Marshal(map[string]any{ValidateCredentialsParam: true})
+	if err != nil {
+		return nil, err
+	}
+	if err = run.Params.Set(paramsJson); err != nil {
+		return nil, err
+	}
+	if err = run.Result.Set([]byte("{}")); err != nil {
+		return nil, err
+	}
+
+	return &run, nil
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgtype"
+	"github.com/opengovern/og-util/pkg/httpclient"
+	integrationApi "github.com/opengovern/opensecurity/services/integration/api/models"
+	integrationClient "github.com/opengovern/opensecurity/services/integration/client"
+	"github.com/opengovern/opensecurity/services/tasks/api"
+	"github.com/opengovern/opensecurity/services/tasks/db"
+	"github.com/opengovern/opensecurity/services/tasks/db/models"
+	"sort"
+)
+
+// IntegrationParam is the param the targeted integration and its credentials are passed to the task in
+const IntegrationParam = "integration"
+
+// ResolveIntegrationTarget returns the integrations matching any of the target ids, groups or labels.
+// Archived and inactive integrations are skipped.
+func ResolveIntegrationTarget(ctx *httpclient.Context, client integrationClient.IntegrationServiceClient, target api.IntegrationTarget) ([]integrationApi.Integration, error) {
+	integrationIDs := make(map[string]bool)
+	for _, id := range target.IntegrationIDs {
+		integrationIDs[id] = true
+	}
+	for _, groupName := range target.IntegrationGroups {
+		group, err := client.GetIntegrationGroup(ctx, groupName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get integration group %s: %w", groupName, err)
+		}
+		for _, id := range group.IntegrationIds {
+			integrationIDs[id] = true
+		}
+	}
+
+	matched := make(map[string]integrationApi.Integration)
+	if len(integrationIDs) > 0 {
+		var ids []string
+		for id := range integrationIDs {
+			ids = append(ids, id)
+		}
+		resp, err := client.ListIntegrationsByFilters(ctx, integrationApi.ListIntegrationsRequest{IntegrationID: ids})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list integrations: %w", err)
+		}
+		for _, integration := range resp.Integrations {
+			matched[integration.IntegrationID] = integration
+		}
+	}
+	if len(target.Labels) > 0 {
+		resp, err := client.ListIntegrationsByFilters(ctx, integrationApi.ListIntegrationsRequest{Labels: target.Labels})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list integrations: %w", err)
+		}
+		for _, integration := range resp.Integrations {
+			matched[integration.IntegrationID] = integration
+		}
+	}
+
+	var integrations []integrationApi.Integration
+	for _, integration := range matched {
+		if integration.State == integrationApi.IntegrationStateArchived || integration.State == integrationApi.IntegrationStateInactive {
+			continue
+		}
+		integrations = append(integrations, integration)
+	}
+	sort.Slice(integrations, func(i, j int) bool {
+		return integrations[i].IntegrationID < integrations[j].IntegrationID
+	})
+
+	return integrations, nil
+}
+
+// CreateIntegrationTaskRuns creates one run per integration based on the given run. A single integration is set
+// on the run itself, multiple integrations fan out the run into one child run per integration.
+func CreateIntegrationTaskRuns(database db.Database, run *models.TaskRun, integrations []integrationApi.Integration) error {
+	if len(integrations) == 0 {
+		return fmt.Errorf("no active integrations matched")
+	}
+	if len(integrations) == 1 {
+		run.IntegrationID = integrations[0].IntegrationID
+		return database.CreateTaskRun(run)
+	}
+
+	var children []models.TaskRun
+	for _, integration := range integrations {
+		child := models.TaskRun{
+			TaskID:        run.TaskID,
+			Params:        run.Params,
+			Status:        models.TaskRunStatusCreated,
+			TriggerType:   run.TriggerType,
+			TriggeredBy:   run.TriggeredBy,
+			Priority:      run.Priority,
+			IntegrationID: integration.IntegrationID,
+		}
+		if err := child.Result.Set([]byte("{}")); err != nil {
+			return err
+		}
+		children = append(children, child)
+	}
+
+	return database.CreateFanOutTaskRun(run, children)
+}
+
+// ParseIntegrationTarget reads the integration target of a run schedule, nil is returned if it has none
+func ParseIntegrationTarget(jsonb pgtype.JSONB) (*api.IntegrationTarget, error) {
+	if jsonb.Status != pgtype.Present || len(jsonb.Bytes) == 0 {
+		return nil, nil
+	}
+
+	var target *api.IntegrationTarget
+	if err := json.Unmarshal(jsonb.Bytes, &target); err != nil {
+		return nil, err
+	}
+	if target == nil || target.IsEmpty() {
+		return nil, nil
+	}
+	return target, nil
+}
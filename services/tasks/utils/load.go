@@ -7,10 +7,12 @@ import (
 	"github.com/hashicorp/go-getter"
 	"github.com/jackc/pgtype"
 	"github.com/opengovern/og-util/pkg/platformspec"
+	"github.com/opengovern/opensecurity/services/tasks/api"
 	"github.com/opengovern/opensecurity/services/tasks/db/models"
 	"github.com/opengovern/opensecurity/services/tasks/worker/consts"
 	"github.com/xhit/go-str2duration/v2"
 	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"os"
@@ -46,7 +48,11 @@ func ValidateAndLoadTask(orm *gorm.DB, itOrm *gorm.DB, logger *zap.Logger, data
 		if spec == nil {
 			return errors.New("nil plugin specification")
 		}
-		err = LoadTask(orm, itOrm, logger, *spec)
+		extensions, err := parseTaskSpecExtensions(data)
+		if err != nil {
+			return err
+		}
+		err = LoadTask(orm, itOrm, logger, *spec, *extensions)
 		if err != nil {
 			return err
 		}
@@ -56,7 +62,46 @@ func ValidateAndLoadTask(orm *gorm.DB, itOrm *gorm.DB, logger *zap.Logger, data
 	return nil
 }
 
-func LoadTask(orm *gorm.DB, itOrm *gorm.DB, logger *zap.Logger, task platformspec.TaskSpecification) error {
+// TaskSpecExtensions are the task settings which are not part of the platform task specification
+type TaskSpecExtensions struct {
+	RunLimits            api.RunLimits
+	ScheduleIntegrations map[string]api.IntegrationTarget
+	CredentialsCheck     bool
+}
+
+// parseTaskSpecExtensions reads the settings which are not part of the platform specification from the raw task specification
+func parseTaskSpecExtensions(data []byte) (*TaskSpecExtensions, error) {
+	var rawSpec struct {
+		ScaleConfig api.RunLimits `yaml:"scale_config"`
+		RunSchedule []struct {
+			ID           string                 `yaml:"id"`
+			Integrations *api.IntegrationTarget `yaml:"integrations"`
+		} `yaml:"run_schedule"`
+		Capabilities struct {
+			CredentialsCheck bool `yaml:"credentials_check"`
+		} `yaml:"capabilities"`
+	}
+	if err := yaml.Unmarshal(data, &rawSpec); err != nil {
+		return nil, err
+	}
+	if err := validateRunLimits(rawSpec.ScaleConfig); err != nil {
+		return nil, err
+	}
+
+	extensions := TaskSpecExtensions{
+		RunLimits:            rawSpec.ScaleConfig,
+		ScheduleIntegrations: make(map[string]api.IntegrationTarget),
+		CredentialsCheck:     rawSpec.Capabilities.CredentialsCheck,
+	}
+	for _, runSchedule := range rawSpec.RunSchedule {
+		if runSchedule.Integrations != nil && !runSchedule.Integrations.IsEmpty() {
+			extensions.ScheduleIntegrations[runSchedule.ID] = *runSchedule.Integrations
+		}
+	}
+	return &extensions, nil
+}
+
+func LoadTask(orm *gorm.DB, itOrm *gorm.DB, logger *zap.Logger, task platformspec.TaskSpecification, extensions TaskSpecExtensions) error {
 	if strings.ToLower(task.Type) != "task" {
 		return nil
 	}
@@ -74,7 +119,16 @@ func LoadTask(orm *gorm.DB, itOrm *gorm.DB, logger *zap.Logger, task platformspe
 		return err
 	}
 
-	scaleJsonData, err := json.Marshal(task.ScaleConfig)
+	scaleJsonData, err := json.Marshal(api.ScaleConfig{
+		Stream:          task.ScaleConfig.Stream,
+		Consumer:        task.ScaleConfig.Consumer,
+		LagThreshold:    task.ScaleConfig.LagThreshold,
+		MinReplica:      int32(task.ScaleConfig.MinReplica),
+		MaxReplica:      int32(task.ScaleConfig.MaxReplica),
+		PollingInterval: int32(task.ScaleConfig.PollingInterval),
+		CooldownPeriod:  int32(task.ScaleConfig.CooldownPeriod),
+		RunLimits:       extensions.RunLimits,
+	})
 	if err != nil {
 		return err
 	}
@@ -130,6 +184,7 @@ func LoadTask(orm *gorm.DB, itOrm *gorm.DB, logger *zap.Logger, task platformspe
 		EnvVars:             envVarsJsonb,
 		Params:              task.Params,
 		Configs:             configs,
+		CredentialsCheck:    extensions.CredentialsCheck,
 	}).Error; err != nil {
 		return err
 	}
@@ -160,11 +215,25 @@ func LoadTask(orm *gorm.DB, itOrm *gorm.DB, logger *zap.Logger, task platformspe
 			return err
 		}
 
+		var integrationTargetJsonb pgtype.JSONB
+		if target, ok := extensions.ScheduleIntegrations[runSchedule.ID]; ok {
+			targetJsonData, err := json.Marshal(target)
+			if err != nil {
+				return err
+			}
+			if err = integrationTargetJsonb.Set(targetJsonData); err != nil {
+				return err
+			}
+		} else {
+			integrationTargetJsonb.Status = pgtype.Null
+		}
+
 		if err = orm.Create(&models.TaskRunSchedule{
-			ID:        runSchedule.ID,
-			TaskID:    task.ID,
-			Params:    paramsJsonb,
-			Frequency: frequencyFloat,
+			ID:                runSchedule.ID,
+			TaskID:            task.ID,
+			Params:            paramsJsonb,
+			Frequency:         frequencyFloat,
+			IntegrationTarget: integrationTargetJsonb,
 		}).Error; err != nil {
 			return err
 		}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTaskSpecExtensionsCapabilities(t *testing.T) {
+	extensions, err := parseTaskSpecExtensions([]byte(`
+id: aws-inventory
+capabilities:
+  credentials_check: true
+run_schedule:
+  - id: daily
+    integrations:
+      integration_ids: [a]
+`))
+	require.NoError(t, err)
+	assert.True(t, extensions.CredentialsCheck)
+	assert.Equal(t, []string{"a"}, extensions.ScheduleIntegrations["daily"].IntegrationIDs)
+
+	// Health checks and rotation are opt-in
+	extensions, err = parseTaskSpecExtensions([]byte("id: aws-inventory\n"))
+	require.NoError(t, err)
+	assert.False(t, extensions.CredentialsCheck)
+}
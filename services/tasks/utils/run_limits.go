@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgtype"
+	"github.com/opengovern/opensecurity/services/tasks/api"
+	"github.com/opengovern/opensecurity/services/tasks/db/models"
+	"github.com/xhit/go-str2duration/v2"
+	"time"
+)
+
+const (
+	DefaultMaxConcurrentRuns    = 10
+	DefaultManualRunPriority    = 10
+	DefaultScheduledRunPriority = 0
+)
+
+// GetTaskRunLimits returns the run limits stored in the task scale config with the defaults filled in
+func GetTaskRunLimits(task models.Task) (api.RunLimits, error) {
+	var scaleConfig api.ScaleConfig
+	if task.ScaleConfig.Status == pgtype.Present {
+		if err := json.Unmarshal(task.ScaleConfig.Bytes, &scaleConfig); err != nil {
+			return api.RunLimits{}, err
+		}
+	}
+
+	limits := scaleConfig.RunLimits
+	fillRunLimitsDefaults(&limits)
+	return limits, nil
+}
+
+// GetRunPriority returns the priority of a run with the given trigger type, higher priorities are published first
+func GetRunPriority(limits api.RunLimits, triggerType models.TriggerType) int {
+	fillRunLimitsDefaults(&limits)
+	if triggerType == models.TriggerTypeScheduled {
+		return *limits.ScheduledRunPriority
+	}
+	return *limits.ManualRunPriority
+}
+
+// GetRunQuotaPeriod parses the period of a manual run quota
+func GetRunQuotaPeriod(quota api.RunQuota) (time.Duration, error) {
+	return str2duration.ParseDuration(quota.Period)
+}
+
+func fillRunLimitsDefaults(limits *api.RunLimits) {
+	if limits.MaxConcurrentRuns == 0 {
+		limits.MaxConcurrentRuns = DefaultMaxConcurrentRuns
+	}
+	// priorities are pointers, so an explicit 0 is kept
+	if limits.ManualRunPriority == nil {
+		priority := DefaultManualRunPriority
+		limits.ManualRunPriority = &priority
+	}
+	if limits.ScheduledRunPriority == nil {
+		priority := DefaultScheduledRunPriority
+		limits.ScheduledRunPriority = &priority
+	}
+}
+
+func validateRunLimits(limits api.RunLimits) error {
+	if limits.MaxConcurrentRuns < 0 {
+		return errors.New("max_concurrent_runs should not be negative")
+	}
+	if limits.MaxQueuedRuns < 0 {
+		return errors.New("max_queued_runs should not be negative")
+	}
+	if limits.ManualRunQuota != nil {
+		if limits.ManualRunQuota.MaxRuns <= 0 {
+			return errors.New("manual_run_quota.max_runs should be positive")
+		}
+		period, err := GetRunQuotaPeriod(*limits.ManualRunQuota)
+		if err != nil {
+			return fmt.Errorf("invalid manual_run_quota.period: %w", err)
+		}
+		if period <= 0 {
+			return errors.New("manual_run_quota.period should be positive")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/jackc/pgtype"
+	"github.com/opengovern/opensecurity/services/tasks/api"
+	"github.com/opengovern/opensecurity/services/tasks/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func taskWithScaleConfig(t *testing.T, scaleConfig string) models.Task {
+	var task models.Task
+	require.NoError(t, task.ScaleConfig.Set([]byte(scaleConfig)))
+	return task
+}
+
+func TestGetTaskRunLimitsDefaults(t *testing.T) {
+	limits, err := GetTaskRunLimits(models.Task{ScaleConfig: pgtype.JSONB{Status: pgtype.Null}})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultMaxConcurrentRuns, limits.MaxConcurrentRuns)
+	assert.Equal(t, DefaultManualRunPriority, GetRunPriority(limits, models.TriggerTypeManual))
+	assert.Equal(t, DefaultScheduledRunPriority, GetRunPriority(limits, models.TriggerTypeScheduled))
+
+	// explicit zero priorities publish manual and scheduled runs in creation order
+	limits, err = GetTaskRunLimits(taskWithScaleConfig(t, `{"manual_run_priority":0,"scheduled_run_priority":0}`))
+	require.NoError(t, err)
+	assert.Equal(t, 0, GetRunPriority(limits, models.TriggerTypeManual))
+	assert.Equal(t, 0, GetRunPriority(limits, models.TriggerTypeScheduled))
+
+	limits, err = GetTaskRunLimits(taskWithScaleConfig(t, `{"max_concurrent_runs":2,"scheduled_run_priority":20}`))
+	require.NoError(t, err)
+	assert.Equal(t, 2, limits.MaxConcurrentRuns)
+	assert.Equal(t, DefaultManualRunPriority, GetRunPriority(limits, models.TriggerTypeManual))
+	assert.Equal(t, 20, GetRunPriority(limits, models.TriggerTypeScheduled))
+}
+
+func TestParseTaskSpecExtensionsRunLimits(t *testing.T) {
+	extensions, err := parseTaskSpecExtensions([]byte(`
+id: aws-inventory
+scale_config:
+  max_queued_runs: 5
+  manual_run_priority: 0
+  manual_run_quota:
+    max_runs: 3
+    period: 24h
+`))
+	require.NoError(t, err)
+	limits := extensions.RunLimits
+	assert.Equal(t, 5, limits.MaxQueuedRuns)
+	require.NotNil(t, limits.ManualRunPriority)
+	assert.Equal(t, 0, *limits.ManualRunPriority)
+	assert.Nil(t, limits.ScheduledRunPriority)
+	assert.Equal(t, &api.RunQuota{MaxRuns: 3, Period: "24h"}, limits.ManualRunQuota)
+}
+
+func TestValidateRunLimits(t *testing.T) {
+	assert.NoError(t, validateRunLimits(api.RunLimits{}))
+	assert.NoError(t, validateRunLimits(api.RunLimits{ManualRunQuota: &api.RunQuota{MaxRuns: 1, Period: "1d"}}))
+
+	for name, limits := range map[string]api.RunLimits{
+		"negative concurrency":  {MaxConcurrentRuns: -1},
+		"negative queue":        {MaxQueuedRuns: -1},
+		"quota without runs":    {ManualRunQuota: &api.RunQuota{Period: "24h"}},
+		"quota without period":  {ManualRunQuota: &api.RunQuota{MaxRuns: 1}},
+		"malformed quota":       {ManualRunQuota: &api.RunQuota{MaxRuns: 1, Period: "daily"}},
+		"negative quota period": {ManualRunQuota: &api.RunQuota{MaxRuns: 1, Period: "-1h"}},
+	} {
+		assert.Error(t, validateRunLimits(limits), name)
+	}
+}